@@ -0,0 +1,370 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        (unknown)
+// source: satellite/v1/service.proto
+
+package satellitev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// GraphFilter restricts a graph to some kinds and/or namespaces. Empty lists
+// match everything.
+type GraphFilter struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Kinds      []string               `protobuf:"bytes,1,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	Namespaces []string               `protobuf:"bytes,2,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	// include_external keeps nodes outside the filter that are directly
+	// related to a matching node, marked with the "external" property.
+	IncludeExternal bool `protobuf:"varint,3,opt,name=include_external,json=includeExternal,proto3" json:"include_external,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GraphFilter) Reset() {
+	*x = GraphFilter{}
+	mi := &file_satellite_v1_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GraphFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GraphFilter) ProtoMessage() {}
+
+func (x *GraphFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_satellite_v1_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GraphFilter.ProtoReflect.Descriptor instead.
+func (*GraphFilter) Descriptor() ([]byte, []int) {
+	return file_satellite_v1_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GraphFilter) GetKinds() []string {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+func (x *GraphFilter) GetNamespaces() []string {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+func (x *GraphFilter) GetIncludeExternal() bool {
+	if x != nil {
+		return x.IncludeExternal
+	}
+	return false
+}
+
+type GetGraphRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *GraphFilter           `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGraphRequest) Reset() {
+	*x = GetGraphRequest{}
+	mi := &file_satellite_v1_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGraphRequest) ProtoMessage() {}
+
+func (x *GetGraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_satellite_v1_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGraphRequest.ProtoReflect.Descriptor instead.
+func (*GetGraphRequest) Descriptor() ([]byte, []int) {
+	return file_satellite_v1_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetGraphRequest) GetFilter() *GraphFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type WatchGraphRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *GraphFilter           `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchGraphRequest) Reset() {
+	*x = WatchGraphRequest{}
+	mi := &file_satellite_v1_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchGraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchGraphRequest) ProtoMessage() {}
+
+func (x *WatchGraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_satellite_v1_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchGraphRequest.ProtoReflect.Descriptor instead.
+func (*WatchGraphRequest) Descriptor() ([]byte, []int) {
+	return file_satellite_v1_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WatchGraphRequest) GetFilter() *GraphFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type WatchGraphResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*WatchGraphResponse_Snapshot
+	//	*WatchGraphResponse_Delta
+	Event         isWatchGraphResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchGraphResponse) Reset() {
+	*x = WatchGraphResponse{}
+	mi := &file_satellite_v1_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchGraphResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchGraphResponse) ProtoMessage() {}
+
+func (x *WatchGraphResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_satellite_v1_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchGraphResponse.ProtoReflect.Descriptor instead.
+func (*WatchGraphResponse) Descriptor() ([]byte, []int) {
+	return file_satellite_v1_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WatchGraphResponse) GetEvent() isWatchGraphResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *WatchGraphResponse) GetSnapshot() *Graph {
+	if x != nil {
+		if x, ok := x.Event.(*WatchGraphResponse_Snapshot); ok {
+			return x.Snapshot
+		}
+	}
+	return nil
+}
+
+func (x *WatchGraphResponse) GetDelta() *Delta {
+	if x != nil {
+		if x, ok := x.Event.(*WatchGraphResponse_Delta); ok {
+			return x.Delta
+		}
+	}
+	return nil
+}
+
+type isWatchGraphResponse_Event interface {
+	isWatchGraphResponse_Event()
+}
+
+type WatchGraphResponse_Snapshot struct {
+	Snapshot *Graph `protobuf:"bytes,1,opt,name=snapshot,proto3,oneof"`
+}
+
+type WatchGraphResponse_Delta struct {
+	Delta *Delta `protobuf:"bytes,2,opt,name=delta,proto3,oneof"`
+}
+
+func (*WatchGraphResponse_Snapshot) isWatchGraphResponse_Event() {}
+
+func (*WatchGraphResponse_Delta) isWatchGraphResponse_Event() {}
+
+var File_satellite_v1_service_proto protoreflect.FileDescriptor
+
+var file_satellite_v1_service_proto_rawDesc = string([]byte{
+	0x0a, 0x1a, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2f, 0x76, 0x31, 0x2f, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x73, 0x61,
+	0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x18, 0x73, 0x61, 0x74, 0x65,
+	0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2f, 0x76, 0x31, 0x2f, 0x67, 0x72, 0x61, 0x70, 0x68, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x22, 0x6e, 0x0a, 0x0b, 0x47, 0x72, 0x61, 0x70, 0x68, 0x46, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6b, 0x69, 0x6e, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x05, 0x6b, 0x69, 0x6e, 0x64, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x61, 0x6d,
+	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x6e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x69, 0x6e, 0x63,
+	0x6c, 0x75, 0x64, 0x65, 0x5f, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0f, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x45, 0x78, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x22, 0x44, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x47, 0x72, 0x61, 0x70, 0x68,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c,
+	0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x61, 0x70, 0x68, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x52, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x22, 0x46, 0x0a, 0x11, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x72, 0x61, 0x70, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x31, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x72, 0x61, 0x70, 0x68, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x06, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x22, 0x7d, 0x0a, 0x12, 0x57, 0x61, 0x74, 0x63, 0x68, 0x47, 0x72, 0x61, 0x70, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x31, 0x0a, 0x08, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x73, 0x61, 0x74,
+	0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x61, 0x70, 0x68, 0x48,
+	0x00, 0x52, 0x08, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x2b, 0x0a, 0x05, 0x64,
+	0x65, 0x6c, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x73, 0x61, 0x74,
+	0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x48,
+	0x00, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e,
+	0x74, 0x32, 0xa1, 0x01, 0x0a, 0x0c, 0x47, 0x72, 0x61, 0x70, 0x68, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x12, 0x3e, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x47, 0x72, 0x61, 0x70, 0x68, 0x12, 0x1d,
+	0x2e, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65,
+	0x74, 0x47, 0x72, 0x61, 0x70, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e,
+	0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x61,
+	0x70, 0x68, 0x12, 0x51, 0x0a, 0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x47, 0x72, 0x61, 0x70, 0x68,
+	0x12, 0x1f, 0x2e, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x47, 0x72, 0x61, 0x70, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x20, 0x2e, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2e, 0x76, 0x31,
+	0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x47, 0x72, 0x61, 0x70, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x74, 0x68, 0x75, 0x77, 0x6e, 0x67, 0x2f, 0x73, 0x61, 0x74, 0x65,
+	0x6c, 0x6c, 0x69, 0x74, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c,
+	0x69, 0x74, 0x65, 0x2f, 0x76, 0x31, 0x3b, 0x73, 0x61, 0x74, 0x65, 0x6c, 0x6c, 0x69, 0x74, 0x65,
+	0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+})
+
+var (
+	file_satellite_v1_service_proto_rawDescOnce sync.Once
+	file_satellite_v1_service_proto_rawDescData []byte
+)
+
+func file_satellite_v1_service_proto_rawDescGZIP() []byte {
+	file_satellite_v1_service_proto_rawDescOnce.Do(func() {
+		file_satellite_v1_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_satellite_v1_service_proto_rawDesc), len(file_satellite_v1_service_proto_rawDesc)))
+	})
+	return file_satellite_v1_service_proto_rawDescData
+}
+
+var file_satellite_v1_service_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_satellite_v1_service_proto_goTypes = []any{
+	(*GraphFilter)(nil),        // 0: satellite.v1.GraphFilter
+	(*GetGraphRequest)(nil),    // 1: satellite.v1.GetGraphRequest
+	(*WatchGraphRequest)(nil),  // 2: satellite.v1.WatchGraphRequest
+	(*WatchGraphResponse)(nil), // 3: satellite.v1.WatchGraphResponse
+	(*Graph)(nil),              // 4: satellite.v1.Graph
+	(*Delta)(nil),              // 5: satellite.v1.Delta
+}
+var file_satellite_v1_service_proto_depIdxs = []int32{
+	0, // 0: satellite.v1.GetGraphRequest.filter:type_name -> satellite.v1.GraphFilter
+	0, // 1: satellite.v1.WatchGraphRequest.filter:type_name -> satellite.v1.GraphFilter
+	4, // 2: satellite.v1.WatchGraphResponse.snapshot:type_name -> satellite.v1.Graph
+	5, // 3: satellite.v1.WatchGraphResponse.delta:type_name -> satellite.v1.Delta
+	1, // 4: satellite.v1.GraphService.GetGraph:input_type -> satellite.v1.GetGraphRequest
+	2, // 5: satellite.v1.GraphService.WatchGraph:input_type -> satellite.v1.WatchGraphRequest
+	4, // 6: satellite.v1.GraphService.GetGraph:output_type -> satellite.v1.Graph
+	3, // 7: satellite.v1.GraphService.WatchGraph:output_type -> satellite.v1.WatchGraphResponse
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_satellite_v1_service_proto_init() }
+func file_satellite_v1_service_proto_init() {
+	if File_satellite_v1_service_proto != nil {
+		return
+	}
+	file_satellite_v1_graph_proto_init()
+	file_satellite_v1_service_proto_msgTypes[3].OneofWrappers = []any{
+		(*WatchGraphResponse_Snapshot)(nil),
+		(*WatchGraphResponse_Delta)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_satellite_v1_service_proto_rawDesc), len(file_satellite_v1_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_satellite_v1_service_proto_goTypes,
+		DependencyIndexes: file_satellite_v1_service_proto_depIdxs,
+		MessageInfos:      file_satellite_v1_service_proto_msgTypes,
+	}.Build()
+	File_satellite_v1_service_proto = out.File
+	file_satellite_v1_service_proto_goTypes = nil
+	file_satellite_v1_service_proto_depIdxs = nil
+}
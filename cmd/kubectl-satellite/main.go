@@ -0,0 +1,141 @@
+// Command kubectl-satellite is a kubectl plugin writing a one-shot graph of
+// the current namespace to stdout, without deploying anything:
+//
+//	kubectl satellite graph -n shop -o dot | dot -Tpng > shop.png
+//
+// It honours KUBECONFIG and the --kubeconfig, --context and --namespace
+// flags like kubectl, and needs only list permission.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/oneshot"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `Usage: kubectl satellite graph [flags]
+
+Lists the objects of a namespace (the current context's by default) and
+writes their graph to stdout.
+
+Flags:
+`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "graph" {
+		if len(os.Args) > 1 && os.Args[1] == "version" {
+			fmt.Println(version)
+			return
+		}
+		fmt.Fprint(os.Stderr, usage)
+		newFlags().PrintDefaults()
+		os.Exit(2)
+	}
+	flags := newFlags()
+	_ = flags.Parse(os.Args[2:]) // ExitOnError
+
+	log.SetOutput(os.Stderr)
+	level, err := log.ParseLevel(flags.logLevel)
+	if err != nil {
+		log.Fatalf("Invalid --log-level %q: %v", flags.logLevel, err)
+	}
+	log.SetLevel(level)
+
+	if err := run(flags); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// graphFlags holds the plugin's flags; the kubectl ones accept their short
+// forms too.
+type graphFlags struct {
+	*flag.FlagSet
+	kubeconfig    string
+	context       string
+	namespace     string
+	allNamespaces bool
+	output        string
+	kinds         string
+	timeout       time.Duration
+	logLevel      string
+}
+
+// newFlags defines the flags of the graph subcommand.
+func newFlags() *graphFlags {
+	f := &graphFlags{FlagSet: flag.NewFlagSet("graph", flag.ExitOnError)}
+	f.StringVar(&f.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG, then ~/.kube/config).")
+	f.StringVar(&f.context, "context", "", "Kubeconfig context to use (default: the current context).")
+	f.StringVar(&f.namespace, "namespace", "", "Namespace to graph (default: the context's namespace).")
+	f.StringVar(&f.namespace, "n", "", "Shorthand for --namespace.")
+	f.BoolVar(&f.allNamespaces, "all-namespaces", false, "Graph every namespace.")
+	f.BoolVar(&f.allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	f.StringVar(&f.output, "output", "json", "Output format (json, dot).")
+	f.StringVar(&f.output, "o", "json", "Shorthand for --output.")
+	f.StringVar(&f.kinds, "kinds", "", "Comma-separated kinds to list (default: every kind Satellite watches).")
+	f.DurationVar(&f.timeout, "request-timeout", time.Minute, "Maximum time for listing and building.")
+	f.StringVar(&f.logLevel, "log-level", "warn", "Log level of the messages written to stderr.")
+	return f
+}
+
+// run lists, builds and writes one graph.
+func run(f *graphFlags) error {
+	if f.output != "json" && f.output != "dot" {
+		return fmt.Errorf("unknown output format %q (want json or dot)", f.output)
+	}
+	kinds, err := k8s.ParseKinds(f.kinds)
+	if err != nil {
+		return fmt.Errorf("invalid --kinds: %w", err)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: f.context}
+	overrides.Context.Namespace = f.namespace
+	clientCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	cfg, err := clientCfg.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	opts := oneshot.Options{Kinds: kinds}
+	if !f.allNamespaces {
+		if opts.Namespace, _, err = clientCfg.Namespace(); err != nil {
+			return fmt.Errorf("failed to resolve namespace: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	g, err := oneshot.Build(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+	clusterName := f.context
+	if raw, err := clientCfg.RawConfig(); err == nil && clusterName == "" {
+		clusterName = raw.CurrentContext
+	}
+	graph.StampClusterName(&g, clusterName, false)
+	g.Meta().BuiltAt = time.Now().UTC()
+
+	if f.output == "dot" {
+		return graph.WriteDOT(os.Stdout, g)
+	}
+	return emitter.EmitGraph(ctx, g, emitter.StdoutTarget)
+}
@@ -0,0 +1,66 @@
+// Command satellite-gen writes a synthetic cluster, see internal/fixtures,
+// as JSON or YAML, so a cluster of any size can be inspected and shared
+// without access to one. The objects are written as informers deliver them,
+// uid, resourceVersion and status included.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tthuwng/satellite/internal/fixtures"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	objects := flag.Int("objects", 1000, "Approximate number of objects; sets the apps and nodes unless given explicitly.")
+	seed := flag.Int64("seed", 1, "Random seed; the same flags always generate the same objects.")
+	namespaces := flag.Int("namespaces", 10, "Number of namespaces the apps are spread over.")
+	apps := flag.Int("apps", 0, "Number of apps (Deployment, ReplicaSets, pods, Service, ConfigMap); 0 derives it from --objects.")
+	podsPerApp := flag.Int("pods-per-app", 8, "Mean number of pods per app.")
+	nodes := flag.Int("nodes", 0, "Number of nodes the pods are spread over; 0 derives it from --objects.")
+	format := flag.String("format", "yaml", "Output format (json for a v1 List, yaml for a document stream).")
+	output := flag.String("o", "-", "File to write to ('-' writes to stdout).")
+	flag.Parse()
+
+	opts := fixtures.ForObjects(*objects, *seed)
+	opts.Namespaces = *namespaces
+	opts.PodsPerApp = *podsPerApp
+	if *apps > 0 {
+		opts.Apps = *apps
+	}
+	if *nodes > 0 {
+		opts.Nodes = *nodes
+	}
+	cluster := fixtures.Generate(opts)
+
+	if err := write(cluster, *output, *format); err != nil {
+		log.Fatal(err)
+	}
+	log.WithFields(log.Fields{"objects": len(cluster.Objects()), "seed": *seed}).Info("Generated synthetic cluster")
+}
+
+// write writes cluster to path in format.
+func write(cluster *fixtures.Cluster, path, format string) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+	if err := cluster.Write(buf, format); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+)
+
+// loadAnonymizer returns the anonymizer keyed by the contents of keyFile,
+// keeping the comma-separated namespaces and label keys.
+func loadAnonymizer(keyFile, namespaces, labels string) (*graph.Anonymizer, error) {
+	if keyFile == "" {
+		return nil, fmt.Errorf("--anonymize requires --anonymize-key-file")
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anonymization key: %w", err)
+	}
+	return graph.NewAnonymizer(bytes.TrimSpace(key), graph.AnonymizeOptions{
+		Namespaces: splitList(namespaces),
+		Labels:     splitList(labels),
+	})
+}
+
+// loadSecretHashKey keys the Secrets' data hashes with the contents of
+// keyFile.
+func loadSecretHashKey(keyFile string) error {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read secret hash key: %w", err)
+	}
+	return k8s.SetSecretHashKey(bytes.TrimSpace(key))
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/server"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// serveAuthenticator builds the authenticator for the serving endpoints from
+// the --serve-auth-* flags, or returns nil if none is configured.
+func serveAuthenticator(token, tokenFile string, tokenReview bool) (server.Authenticator, error) {
+	if token != "" && tokenFile != "" {
+		return nil, errors.New("--serve-auth-token and --serve-auth-token-file are mutually exclusive")
+	}
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read auth token file: %w", err)
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return nil, fmt.Errorf("auth token file %s is empty", tokenFile)
+		}
+	}
+
+	var auths server.AnyOf
+	if token != "" {
+		auths = append(auths, server.NewStaticToken(token))
+	}
+	if tokenReview {
+		cfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("TokenReview auth requires running in-cluster: %w", err)
+		}
+		client, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error building kubernetes clientset: %w", err)
+		}
+		auths = append(auths, server.NewTokenReviewer(client, nil))
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+	return auths, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/config"
+	"github.com/tthuwng/satellite/internal/cost"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/hooks"
+	"github.com/tthuwng/satellite/internal/pipeline"
+	"github.com/tthuwng/satellite/internal/types"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// errNoSyncedClusters is returned by buildGraph while no cluster has synced yet.
+var errNoSyncedClusters = errors.New("no cluster has completed its initial sync")
+
+// defaultClusterName derives a cluster name from the current kubeconfig
+// context, falling back to the apiserver host.
+func defaultClusterName(contextName string, cfg *rest.Config) string {
+	if contextName != "" {
+		return contextName
+	}
+	if u, err := url.Parse(cfg.Host); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return graph.UnknownCluster
+}
+
+// restConfigFor resolves the client config for a configured cluster.
+func restConfigFor(cl config.ClusterConfig) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if cl.Kubeconfig != "" {
+		rules.ExplicitPath = cl.Kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cl.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+// stopInformers shuts down the informers of pipelines and waits for their
+// event handlers to return, giving up once ctx expires.
+func stopInformers(ctx context.Context, pipelines []*pipeline.Pipeline) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, p := range pipelines {
+			p.Shutdown()
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("informers not stopped within shutdown timeout: %w", ctx.Err())
+	}
+}
+
+// graphBuilder builds the emitted graph from every cluster pipeline.
+type graphBuilder struct {
+	pipelines []*pipeline.Pipeline
+	// clusterName identifies the graph in metadata and file names.
+	clusterName string
+	// stampClusterProperty adds a "cluster" property to every node.
+	stampClusterProperty bool
+	// collector, if set, is added to every graph as a Collector node.
+	collector *graph.Collector
+	// onBuilt is called with every successfully built graph.
+	onBuilt []func(graph.Graph)
+	// costs, if set, stamps the hourly cost of every Node.
+	costs *cost.Table
+	// hooks, if set, run after onBuilt, before the graph is emitted.
+	hooks *hooks.Registry
+	// anonymizer, if set, anonymizes the graph before anything sees it.
+	anonymizer *graph.Anonymizer
+}
+
+// build builds the graph and stamps the cluster identity on it.
+func (b *graphBuilder) build(ctx context.Context, revision uint64) (graph.Graph, error) {
+	g, err := buildGraph(ctx, b.pipelines, revision)
+	if err != nil {
+		return graph.Graph{}, err
+	}
+	if b.collector != nil {
+		graph.AddCollector(&g, *b.collector, b.clusterName)
+	}
+	graph.StampClusterName(&g, b.clusterName, b.stampClusterProperty)
+	if b.costs != nil {
+		b.costs.Stamp(&g)
+	}
+	if b.anonymizer != nil {
+		g = b.anonymizer.Anonymize(g)
+	}
+	g.Meta().BuiltAt = time.Now().UTC()
+	for _, fn := range b.onBuilt {
+		fn(g)
+	}
+	if b.hooks != nil {
+		b.hooks.Run(ctx, g)
+	}
+	return g, nil
+}
+
+// buildGraph builds the graph of a single unnamed cluster as-is, or merges the
+// graphs of every synced cluster with Cluster pseudo-nodes.
+func buildGraph(ctx context.Context, pipelines []*pipeline.Pipeline, revision uint64) (graph.Graph, error) {
+	if len(pipelines) == 1 && pipelines[0].Name() == "" {
+		if !pipelines[0].Synced() {
+			return graph.Graph{}, errNoSyncedClusters
+		}
+		return pipelines[0].Build(ctx, revision)
+	}
+
+	parts := make([]graph.ClusterGraph, 0, len(pipelines))
+	for _, p := range pipelines {
+		if !p.Synced() {
+			continue
+		}
+		g, err := p.Build(ctx, revision)
+		if err != nil {
+			return graph.Graph{}, fmt.Errorf("cluster %s: %w", p.Name(), err)
+		}
+		parts = append(parts, graph.ClusterGraph{Cluster: p.Name(), Graph: g})
+	}
+	if len(parts) == 0 {
+		return graph.Graph{}, errNoSyncedClusters
+	}
+	return graph.MergeClusters(revision, parts), nil
+}
+
+// changeSeq sums the change counters of the synced pipelines. It only grows:
+// counters never decrease and pipelines never become unsynced.
+func changeSeq(pipelines []*pipeline.Pipeline) func() uint64 {
+	return func() uint64 {
+		var seq uint64
+		for _, p := range pipelines {
+			if p.Synced() {
+				seq += p.Cache().Seq()
+			}
+		}
+		return seq
+	}
+}
+
+// watchedKinds returns the kinds enabled in any of the pipelines.
+func watchedKinds(pipelines []*pipeline.Pipeline) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, p := range pipelines {
+		for _, kind := range p.Kinds() {
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	return kinds
+}
+
+// pipelineObjects serves /object lookups from the pipelines' caches.
+type pipelineObjects []*pipeline.Pipeline
+
+func (ps pipelineObjects) Object(cluster string, key types.EntityKey) (runtime.Object, bool) {
+	for _, p := range ps {
+		if p.Name() == cluster {
+			return p.Cache().Get(key)
+		}
+	}
+	return nil, false
+}
+
+func (ps pipelineObjects) ObjectByUID(uid string) (runtime.Object, bool) {
+	for _, p := range ps {
+		if obj, ok := p.Cache().GetByUID(k8stypes.UID(uid)); ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/tthuwng/satellite/internal/cost"
+	"github.com/tthuwng/satellite/internal/runner"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadCosts reloads costs on every signal from sigCh and fires trigger so
+// the new costs are emitted. A failed reload keeps the previous costs.
+func reloadCosts(ctx context.Context, costs *cost.Table, sigCh <-chan os.Signal, trigger *runner.Trigger) {
+	for {
+		select {
+		case <-sigCh:
+			if err := costs.Reload(); err != nil {
+				log.WithError(err).Error("Failed to reload node costs, keeping the previous ones")
+				continue
+			}
+			log.Infof("Reloaded the hourly cost of %d instance types", costs.Len())
+			trigger.Fire()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/tthuwng/satellite/internal/server"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// startGRPC serves the GraphService of srv on addr, with TLS if certFile and
+// keyFile are set. Binding errors are returned synchronously.
+func startGRPC(addr, certFile, keyFile string, srv *server.Server) (*grpc.Server, error) {
+	opts := srv.GRPCServerOptions()
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--grpc-tls-cert and --grpc-tls-key must be set together")
+	}
+	if certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on gRPC address %s: %w", addr, err)
+	}
+	gs := grpc.NewServer(opts...)
+	srv.RegisterGRPC(gs)
+	go func() {
+		if err := gs.Serve(ln); err != nil {
+			log.Errorf("gRPC server on %s stopped: %v", addr, err)
+		}
+	}()
+	log.WithField("tls", certFile != "").Infof("gRPC server listening on %s", ln.Addr())
+	return gs, nil
+}
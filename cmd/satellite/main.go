@@ -1,37 +1,146 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"flag"
 	"os"
 	"os/signal"
-	"satellite/internal/cache"
-	"satellite/internal/emitter"
-	"satellite/internal/graph"
-	"sync"
 	"syscall"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/admin"
+	"github.com/tthuwng/satellite/internal/audit"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/churn"
+	"github.com/tthuwng/satellite/internal/config"
+	"github.com/tthuwng/satellite/internal/cost"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/hooks"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/pipeline"
+	"github.com/tthuwng/satellite/internal/ratelog"
+	"github.com/tthuwng/satellite/internal/rules"
+	"github.com/tthuwng/satellite/internal/runner"
+	"github.com/tthuwng/satellite/internal/server"
+	"github.com/tthuwng/satellite/internal/shard"
+	"github.com/tthuwng/satellite/internal/timing"
+	"github.com/tthuwng/satellite/internal/ui"
 
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
-	cachepkg "k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-var currentGraphRevision uint64 = 0
-var revisionMu sync.Mutex
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// multiClusterName is the default --cluster-name of a merged multi-cluster graph.
+const multiClusterName = "federated"
 
 func main() {
 	// --- CLI Flags ---
-	outputDir := flag.String("output-dir", "./data", "Directory to write graph JSON files.")
+	outputDir := flag.String("output-dir", "./data", "Directory to write graph JSON files ('-' writes to stdout).")
 	logLevelStr := flag.String("log-level", "info", "Log level (debug, info, warn, error, fatal, panic).")
+	eventLogBurst := flag.Int("event-log-burst", ratelog.DefaultBurst, "Per-event log lines written per kind and event type in each --event-log-interval; the rest are counted and summarized (0 disables limiting). Errors are never suppressed.")
+	eventLogInterval := flag.Duration("event-log-interval", ratelog.DefaultInterval, "Window for --event-log-burst.")
+	auditDir := flag.String("event-log-dir", "", "Directory to append a JSONL audit log of every cache event to (disabled if empty).")
+	auditMaxSizeMB := flag.Int("event-log-max-size-mb", audit.DefaultMaxSize>>20, "Size (MiB) at which the audit log is rotated.")
+	auditMaxFiles := flag.Int("event-log-max-files", audit.DefaultMaxFiles, "Number of rotated audit log files to keep (0 keeps all).")
+	auditObjects := flag.Bool("event-log-objects", true, "Include the redacted object in every audit log record (needed to replay the log).")
+	auditDiff := flag.Bool("event-log-diff", false, "Add the changed fields to audit log UPDATE records.")
+	eventRateWindow := flag.Duration("event-rate-window", churn.DefaultWindow, "Sliding window of the per-kind event rates; a summary of the busiest kinds is logged once per window (0 disables the summary).")
+	logFormat := flag.String("log-format", "text", "Log format (text, json).")
+	retain := flag.Int("retain", 0, "Number of graph files to keep per output directory (0 keeps all).")
+	writeLatest := flag.Bool("write-latest", false, "Also atomically replace latest.json in each output directory on every emit.")
+	diskSlackMB := flag.Int("disk-slack-mb", 64, "Free space (MiB) that must remain on the output filesystem after each write.")
+	emitFull := flag.Bool("emit-full", true, "Emit the full graph file.")
+	emitPerNamespace := flag.Bool("emit-per-namespace", false, "Also emit one file per namespace under <output-dir>/<namespace>/.")
+	namespaceTombstones := flag.Bool("namespace-tombstones", false, "Write a TOMBSTONE marker into the directory of a namespace that disappeared.")
+	revisionHistory := flag.Int("revision-history", 100, "Number of emitted revisions listed by /revisions.")
+	memoryHistory := flag.Int("memory-history", 0, "Number of built graphs kept in memory for /graph?revision= and ?at= time-travel queries (0 disables).")
+	memoryHistoryMB := flag.Int64("memory-history-max-mb", 256, "Approximate memory cap, in MiB, of the graphs kept by --memory-history; the oldest are evicted first.")
+	minEmitInterval := flag.Duration("min-emit-interval", 0, "Minimum time between two emits; newer graphs replace held ones (0 disables).")
+	heartbeatInterval := flag.Duration("heartbeat-interval", 0, "Re-emit the last graph, marked metadata.heartbeat=true, once nothing has been emitted for this long (0 disables).")
+	slowBuild := flag.Duration("slow-build-threshold", timing.DefaultSlowBuild, "Log a warning with the phase breakdown for builds slower than this (0 disables).")
+	timingWindow := flag.Int("timing-window", timing.DefaultWindow, "Number of recent builds and emits the phase duration percentiles are computed over.")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "Maximum time to spend stopping the informers and on the final build and emit during shutdown.")
+	healthAddr := flag.String("health-addr", "", "Address to serve /healthz and /readyz on (disabled if empty).")
+	serveAddr := flag.String("serve-addr", "", "Address to serve the latest graph on at /graph (disabled if empty).")
+	serveUI := flag.Bool("serve-ui", true, "Serve the embedded graph viewer at /ui/ on --serve-addr.")
+	serveTLSCert := flag.String("serve-tls-cert", "", "TLS certificate file for --serve-addr (plaintext if empty).")
+	serveTLSKey := flag.String("serve-tls-key", "", "TLS private key file for --serve-addr.")
+	serveAuthToken := flag.String("serve-auth-token", "", "Static bearer token required on the graph endpoints (HTTP and gRPC).")
+	serveAuthTokenFile := flag.String("serve-auth-token-file", "", "File containing the static bearer token (alternative to --serve-auth-token).")
+	serveAuthTokenReview := flag.Bool("serve-auth-tokenreview", false, "Also accept any token the Kubernetes API authenticates (TokenReview, in-cluster only).")
+	maxNeighborDepth := flag.Int("max-neighbor-depth", server.DefaultMaxNeighborDepth, "Maximum depth accepted by /graph/neighbors.")
+	maxPathDepth := flag.Int("max-path-depth", server.DefaultMaxPathDepth, "Maximum number of hops searched by /graph/path.")
+	sseHeartbeat := flag.Duration("sse-heartbeat", server.DefaultSSEHeartbeat, "Interval between heartbeat comments on /graph/events.")
+	grpcAddr := flag.String("grpc-addr", "", "Address to serve the satellite.v1.GraphService gRPC API on (disabled if empty).")
+	grpcTLSCert := flag.String("grpc-tls-cert", "", "TLS certificate file for --grpc-addr (plaintext if empty).")
+	grpcTLSKey := flag.String("grpc-tls-key", "", "TLS private key file for --grpc-addr.")
+	syncTimeout := flag.Duration("sync-timeout", 10*time.Minute, "Maximum time to wait for the initial sync of every informer before exiting (0 waits forever).")
+	allowPartialSync := flag.Bool("allow-partial-sync", false, "When --sync-timeout expires, continue with the synced kinds instead of exiting; the others are listed in metadata.unsyncedKinds until they sync.")
+	exitOnWatchFailure := flag.Int("exit-on-watch-failure", 0, "Exit once a kind's list/watch has failed this many times in a row, so the orchestrator restarts the pod (0 never exits).")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/gRPC collector address (host:port) to export build and emit traces to (tracing disabled if empty).")
+	otelInsecure := flag.Bool("otel-insecure", false, "Connect to --otel-endpoint without TLS.")
+	pprofAddr := flag.String("pprof-addr", "", "Address to serve net/http/pprof on (disabled if empty). Debugging only.")
+	ignoredFields := flag.String("ignored-fields", cache.DefaultIgnoredFields.String(), "Comma-separated kind:path fields ('*' for every kind, '[]' for every list element) whose changes alone are stored without triggering a rebuild; empty makes every update trigger one.")
+	trimObjects := flag.Bool("trim-objects", false, "Keep only the fields the graph is built from in the informer stores and cache, cutting memory; /object then returns the trimmed objects and the audit log records them.")
+	deletedLinger := flag.Duration("deleted-linger", 30*time.Second, "Keep deleted objects in the graph, marked deleted=true and state=TERMINATING, for this long before removing them (0 removes them immediately).")
+	metadataOnlyKinds := flag.String("metadata-only-kinds", "", "Comma-separated kinds (e.g. ConfigMap) to watch through metadata-only informers, which keep only their metadata in memory; their nodes lose spec- and data-derived properties and relationships.")
+	enableKinds := flag.String("enable-kinds", "", "Comma-separated opt-in kinds (Secret) to watch along with the default ones. Secrets are stored with their keys only, never their values.")
+	secretCerts := flag.String("secret-certs", "", "TLS Secrets whose certificate (tls.crt, never tls.key) is kept to derive tls.notAfter, tls.issuer and tls.sanCount: '*' for all, or comma-separated namespace/name Secrets.")
+	secretHashKeyFile := flag.String("secret-hash-key-file", "", "File holding the key (at least 16 bytes) of the Secrets' data.hash, so hashes compare across restarts and instances. Without it a random key is used per process.")
+	degradedOK := flag.Bool("degraded-ok", false, "Disable kinds that fail the RBAC preflight check instead of exiting.")
+	missingPlaceholders := flag.Bool("missing-placeholders", false, "Add a placeholder node, flagged missing=true, for every absent object a required reference (e.g. a non-optional ConfigMap volume) points at.")
+	extraResources := flag.String("extra-resources", "", "Comma-separated group/version/resource (e.g. cert-manager.io/v1/certificates, argoproj.io/v1alpha1/applications) to watch through the dynamic client as generic nodes.")
+	normalEvents := flag.Bool("normal-events", false, "Also watch Normal events, not only Warning ones, and add them to the graph. They are far more frequent.")
+	kindRetryInterval := flag.Duration("kind-retry-interval", k8s.DefaultKindRetryInterval, "How often to retry kinds disabled because listing them is forbidden (by the RBAC preflight with --degraded-ok, or by the apiserver during the initial sync).")
+	skipPreflight := flag.Bool("skip-rbac-preflight", false, "Skip the startup RBAC (SelfSubjectAccessReview) check.")
+	shardIndex := flag.Int("shard-index", 0, "Index of this instance when sharding namespaces across instances.")
+	shardCount := flag.Int("shard-count", 1, "Number of instances sharding namespaces (1 disables sharding).")
+	clusterNameFlag := flag.String("cluster-name", "", "Cluster name recorded in graph metadata and file names (default: kube context name, then apiserver host).")
+	stampClusterProperty := flag.Bool("stamp-cluster-property", false, "Add a 'cluster' property to every node.")
+	collectorNode := flag.Bool("collector-node", true, "Add a Collector node describing this Satellite instance (identity from POD_NAME/POD_NAMESPACE, else the hostname).")
+	onBuildExec := flag.String("on-build-exec", "", "Command run after every build, before the emit, with the path of a temporary file holding the graph JSON as its last argument (disabled if empty).")
+	hookTimeout := flag.Duration("hook-timeout", hooks.DefaultTimeout, "Maximum time each build hook (e.g. --on-build-exec) may run before it is abandoned.")
+	nodeCostFile := flag.String("node-cost-file", "", "YAML file mapping instance types to hourly costs (e.g. 'm5.large: 0.096'), stamped as cost.hourly on every Node of a listed type; reloaded on SIGHUP.")
+	configPath := flag.String("config", "", "Path to a YAML config file (e.g. listing clusters for multi-cluster mode).")
+	replayDir := flag.String("replay", "", "Rebuild graphs offline from the audit log in this directory (see --event-log-dir) instead of watching a cluster, then exit.")
+	replayInterval := flag.Duration("replay-interval", 0, "With --replay, also emit a graph every interval of log time (at least 1s; 0 emits only the final state).")
+	replayAt := flag.String("replay-at", "", "With --replay, stop at this RFC 3339 time (e.g. 2024-05-01T14:32:00Z) and emit the graph as of then.")
+	fromManifests := flag.String("from-manifests", "", "Build the graph of the YAML or JSON manifests in this file or directory ('-' reads stdin), e.g. a rendered Helm chart, instead of watching a cluster, then exit.")
+	manifestsNamespace := flag.String("manifests-namespace", "default", "With --from-manifests, the namespace of namespaced objects that set none.")
+	anonymize := flag.Bool("anonymize", false, "Pseudonymize names, namespaces, label and annotation values, IPs and other identifying strings in every graph, with an HMAC keyed by --anonymize-key-file, for sharing graphs outside the organization.")
+	anonymizeKeyFile := flag.String("anonymize-key-file", "", "With --anonymize, file holding the secret key (at least 16 bytes) of the pseudonyms; the same key gives the same pseudonyms.")
+	anonymizeKeepNamespaces := flag.String("anonymize-keep-namespaces", "", "With --anonymize, comma-separated namespaces kept as is (e.g. kube-system,default).")
+	anonymizeKeepLabels := flag.String("anonymize-keep-labels", "", "With --anonymize, comma-separated label and annotation keys whose values are kept (e.g. app.kubernetes.io/name).")
+	maxNodes := flag.Int("max-nodes", 0, "Maximum nodes of a cluster's graph; a deterministic sample is kept over it, and the drops are recorded in metadata.truncation (0 = unlimited).")
+	maxNodesPerKind := flag.String("max-nodes-per-kind", "", "Maximum nodes of each kind, as a bound for all kinds and/or Kind=N bounds, e.g. '10000,ConfigMap=2000' (empty = unlimited).")
 	flag.Parse()
 
 	// --- Logger Setup ---
-	log.SetFormatter(&log.TextFormatter{
-		FullTimestamp: true,
-	})
-	log.SetOutput(os.Stdout)
+	switch *logFormat {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	case "text":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp: true,
+		})
+	default:
+		log.Fatalf("Invalid log format '%s', expected 'text' or 'json'", *logFormat)
+	}
+	if *outputDir == emitter.StdoutTarget {
+		// stdout carries the graph documents, keep it free of log lines
+		log.SetOutput(os.Stderr)
+	} else {
+		log.SetOutput(os.Stdout)
+	}
 	level, err := log.ParseLevel(*logLevelStr)
 	if err != nil {
 		log.Warnf("Invalid log level '%s', defaulting to 'info': %v", *logLevelStr, err)
@@ -39,107 +148,430 @@ func main() {
 	}
 	log.SetLevel(level)
 	log.Infof("Log level set to: %s", level.String())
-	log.Info("Starting Satellite...")
+	ratelog.Default.Configure(*eventLogBurst, *eventLogInterval)
+	timing.Default.Configure(*timingWindow, *slowBuild)
+	churn.Default.Configure(*eventRateWindow)
+	log.Infof("Starting Satellite %s...", version)
+	if *secretHashKeyFile != "" {
+		if err := loadSecretHashKey(*secretHashKeyFile); err != nil {
+			log.Fatalf("Invalid --secret-hash-key-file: %v", err)
+		}
+	}
 
-	// --- K8s Client Setup ---
-	cfg, err := clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
-	if err != nil {
-		log.Fatalf("Error building kubeconfig: %s", err.Error())
+	// --- Admin Endpoints ---
+	adminServer := admin.NewServer()
+	health := &admin.Health{}
+	trigger := runner.NewTrigger()
+	if *healthAddr != "" {
+		mux := adminServer.Mux(*healthAddr)
+		health.Register(mux)
+		mux.Handle("/trigger", trigger.Handler())
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+	graphServer := server.New()
+	graphServer.MaxNeighborDepth = *maxNeighborDepth
+	graphServer.MaxPathDepth = *maxPathDepth
+	graphServer.SSEHeartbeat = *sseHeartbeat
+	if graphServer.Auth, err = serveAuthenticator(*serveAuthToken, *serveAuthTokenFile, *serveAuthTokenReview); err != nil {
+		log.Fatalf("Invalid serving auth flags: %v", err)
+	}
+	if *serveAddr != "" {
+		graphServer.Register(adminServer.Mux(*serveAddr))
+		if *serveUI {
+			ui.NewHandler().Register(adminServer.Mux(*serveAddr))
+		}
+		if (*serveTLSCert == "") != (*serveTLSKey == "") {
+			log.Fatal("--serve-tls-cert and --serve-tls-key must be set together")
+		}
+		if *serveTLSCert != "" {
+			adminServer.SetTLS(*serveAddr, *serveTLSCert, *serveTLSKey)
+		}
+		if graphServer.Auth == nil {
+			log.Warnf("Graph endpoints on %s are unauthenticated; they expose the cluster topology", *serveAddr)
+		}
+	}
+	if *pprofAddr != "" {
+		admin.RegisterPprof(adminServer.Mux(*pprofAddr))
+		log.Warnf("pprof debugging endpoints ENABLED on %s/debug/pprof/ - do not expose this address outside the pod", *pprofAddr)
+	}
+	if err := adminServer.Start(); err != nil {
+		log.Fatalf("Error starting admin server: %v", err)
+	}
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		grpcServer, err = startGRPC(*grpcAddr, *grpcTLSCert, *grpcTLSKey, graphServer)
+		if err != nil {
+			log.Fatalf("Error starting gRPC server: %v", err)
+		}
 	}
 
-	client, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		log.Fatalf("Error building kubernetes clientset: %s", err.Error())
-	}
-
-	// --- Informers & Cache Setup ---
-	factory := informers.NewSharedInformerFactory(client, 0)
-	resourceCache := cache.NewResourceCache()
-	podInf := factory.Core().V1().Pods().Informer()
-	podInf.AddEventHandler(resourceCache.AddEventHandler("Pod"))
-	rsInf := factory.Apps().V1().ReplicaSets().Informer()
-	rsInf.AddEventHandler(resourceCache.AddEventHandler("ReplicaSet"))
-	deployInf := factory.Apps().V1().Deployments().Informer()
-	deployInf.AddEventHandler(resourceCache.AddEventHandler("Deployment"))
-	nodeInf := factory.Core().V1().Nodes().Informer()
-	nodeInf.AddEventHandler(resourceCache.AddEventHandler("Node"))
-	svcInf := factory.Core().V1().Services().Informer()
-	svcInf.AddEventHandler(resourceCache.AddEventHandler("Service"))
-	cmInf := factory.Core().V1().ConfigMaps().Informer()
-	cmInf.AddEventHandler(resourceCache.AddEventHandler("ConfigMap"))
+	// --- Emit Sinks ---
+	var sinks []emitter.EmitFunc
+	spaceGuard := &emitter.SpaceGuard{
+		Slack: uint64(*diskSlackMB) << 20,
+		OnChange: func(degraded bool, reason string) {
+			if degraded {
+				log.WithField("reason", reason).Error("Output disk is full, skipping emits")
+			} else {
+				log.Info("Output disk has room again, resuming emits")
+			}
+			health.SetDegraded("disk", reason)
+		},
+	}
+	revisions := emitter.NewRevisionLog(*revisionHistory)
+	graphServer.Revisions = revisions
+	if *memoryHistory > 0 {
+		graphServer.History = graph.NewGraphHistory(*memoryHistory, *memoryHistoryMB<<20)
+	}
+	fileSink := emitter.FileSink{Dir: *outputDir, Retain: *retain, WriteLatest: *writeLatest, Guard: spaceGuard, Revisions: revisions, NameByBuiltAt: *replayDir != ""}
+	if *outputDir == emitter.StdoutTarget {
+		if *emitPerNamespace {
+			log.Fatal("--emit-per-namespace requires an output directory, not stdout")
+		}
+		sinks = append(sinks, emitter.Instrument("stdout", func(ctx context.Context, g graph.Graph) error {
+			if err := emitter.EmitGraph(ctx, g, emitter.StdoutTarget); err != nil {
+				return err
+			}
+			revisions.Record(g, "")
+			return nil
+		}))
+	} else if *emitFull {
+		sinks = append(sinks, emitter.Instrument("file", fileSink.Emit))
+	}
+	if *emitPerNamespace {
+		nsSink := &emitter.NamespaceSink{Base: fileSink, Tombstones: *namespaceTombstones}
+		sinks = append(sinks, emitter.Instrument("namespace", nsSink.Emit))
+	}
+	if len(sinks) == 0 {
+		log.Fatal("Nothing to emit: enable --emit-full or --emit-per-namespace")
+	}
+	var lastRevision uint64
+	if *outputDir != emitter.StdoutTarget {
+		if removed, err := emitter.CleanTempFiles(*outputDir, emitter.DefaultTempMaxAge); err != nil {
+			log.WithError(err).Warn("Failed to clean up stale temporary files")
+		} else if removed > 0 {
+			log.Infof("Removed %d stale temporary files left by a previous run", removed)
+		}
+		// continue numbering where the previous process stopped
+		if lastRevision, err = emitter.RecoverRevision(*outputDir); err != nil {
+			lastRevision = emitter.FallbackRevision(time.Now())
+			log.WithError(err).Warnf("Could not recover the last emitted revision, continuing from timestamp-derived revision %d", lastRevision)
+		} else if lastRevision > 0 {
+			log.Infof("Continuing from revision %d emitted by a previous run", lastRevision)
+		}
+		sinks = append(sinks, emitter.StateSink{Dir: *outputDir}.Emit)
+	}
+	emitFunc := func(ctx context.Context, g graph.Graph) error {
+		var errs []error
+		for _, sink := range sinks {
+			if err := sink(ctx, g); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	var anonymizer *graph.Anonymizer
+	if *anonymize {
+		if anonymizer, err = loadAnonymizer(*anonymizeKeyFile, *anonymizeKeepNamespaces, *anonymizeKeepLabels); err != nil {
+			log.Fatalf("Invalid anonymization flags: %v", err)
+		}
+		log.Info("Anonymizing every graph")
+	}
+
+	// --- Offline Replay ---
+	if *replayDir != "" {
+		replay := replayOptions{
+			ReplayOptions:        audit.ReplayOptions{Dir: *replayDir, Interval: *replayInterval},
+			clusterName:          *clusterNameFlag,
+			stampClusterProperty: *stampClusterProperty,
+			anonymizer:           anonymizer,
+			revision:             lastRevision,
+		}
+		if *replayInterval != 0 && *replayInterval < time.Second {
+			log.Fatal("--replay-interval must be at least 1s: graph files are named by the second")
+		}
+		if replay.At, err = parseReplayAt(*replayAt); err != nil {
+			log.Fatal(err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := runReplay(ctx, replay, emitFunc)
+		stop()
+		if err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+
+	// --- Offline Manifests ---
+	if *fromManifests != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := runManifests(ctx, manifestOptions{
+			path:                 *fromManifests,
+			namespace:            *manifestsNamespace,
+			clusterName:          *clusterNameFlag,
+			stampClusterProperty: *stampClusterProperty,
+			anonymizer:           anonymizer,
+			revision:             lastRevision,
+		}, emitFunc)
+		stop()
+		if err != nil {
+			log.Fatalf("Building the graph of manifests failed: %v", err)
+		}
+		return
+	}
+
+	// --- K8s Client & Informer Setup ---
+	var clusterCfgs []config.ClusterConfig
+	var annotationProperties *graph.AnnotationProperties
+	var relationshipRules *graph.RelationshipRules
+	var changeRules []rules.Rule
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		clusterCfgs = cfg.Clusters
+		changeRules = cfg.Rules
+		if len(cfg.AnnotationProperties) > 0 {
+			// validated by config.Load
+			annotationProperties, _ = graph.NewAnnotationProperties(cfg.AnnotationProperties)
+		}
+		if len(cfg.RelationshipRules) > 0 {
+			relationshipRules, _ = graph.NewRelationshipRules(cfg.RelationshipRules)
+		}
+	}
+
+	opts := pipeline.Options{
+		SkipPreflight:        *skipPreflight,
+		DegradedOK:           *degradedOK,
+		Shard:                shard.Shard{Index: *shardIndex, Count: *shardCount},
+		ExitOnWatchFailure:   *exitOnWatchFailure,
+		SyncTimeout:          *syncTimeout,
+		AllowPartialSync:     *allowPartialSync,
+		TrimObjects:          *trimObjects,
+		DeletedLinger:        *deletedLinger,
+		KindRetryInterval:    *kindRetryInterval,
+		AnnotationProperties: annotationProperties,
+		RelationshipRules:    relationshipRules,
+		MissingPlaceholders:  *missingPlaceholders,
+		NormalEvents:         *normalEvents,
+	}
+	opts.SizeCaps.MaxNodes = *maxNodes
+	if opts.SizeCaps.MaxNodesPerKind, opts.SizeCaps.PerKind, err = graph.ParseKindCaps(*maxNodesPerKind); err != nil {
+		log.Fatalf("Invalid --max-nodes-per-kind: %v", err)
+	}
+	if opts.IgnoredFields, err = cache.ParseIgnoredFields(*ignoredFields); err != nil {
+		log.Fatalf("Invalid --ignored-fields: %v", err)
+	}
+	if opts.MetadataOnly, err = k8s.ParseKinds(*metadataOnlyKinds); err != nil {
+		log.Fatalf("Invalid --metadata-only-kinds: %v", err)
+	}
+	if opts.EnableKinds, err = k8s.ParseKinds(*enableKinds); err != nil {
+		log.Fatalf("Invalid --enable-kinds: %v", err)
+	}
+	if opts.ExtraResources, err = k8s.ParseResources(*extraResources); err != nil {
+		log.Fatalf("Invalid --extra-resources: %v", err)
+	}
+	if opts.SecretCerts, err = k8s.ParseCertPolicy(*secretCerts); err != nil {
+		log.Fatalf("Invalid --secret-certs: %v", err)
+	}
+	if err := opts.Shard.Validate(); err != nil {
+		log.Fatalf("Invalid sharding flags: %v", err)
+	}
+	if opts.Shard.Enabled() {
+		log.Infof("Sharding enabled: processing namespaces of shard %d of %d", opts.Shard.Index, opts.Shard.Count)
+	}
+	var pipelines []*pipeline.Pipeline
+	clusterName := *clusterNameFlag
+	if len(clusterCfgs) == 0 {
+		clientCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: clientcmd.RecommendedHomeFile},
+			&clientcmd.ConfigOverrides{})
+		cfg, err := clientCfg.ClientConfig()
+		if err != nil {
+			log.Fatalf("Error building kubeconfig: %s", err.Error())
+		}
+		if clusterName == "" {
+			var contextName string
+			if raw, err := clientCfg.RawConfig(); err == nil {
+				contextName = raw.CurrentContext
+			}
+			clusterName = defaultClusterName(contextName, cfg)
+		}
+		p, err := pipeline.NewForConfig("", cfg, opts)
+		if err != nil {
+			log.Fatalf("Error setting up informers: %v", err)
+		}
+		pipelines = append(pipelines, p)
+	} else {
+		// a misconfigured cluster is reported but doesn't take down the others
+		for _, cl := range clusterCfgs {
+			cfg, err := restConfigFor(cl)
+			if err == nil {
+				var p *pipeline.Pipeline
+				if p, err = pipeline.NewForConfig(cl.Name, cfg, opts); err == nil {
+					pipelines = append(pipelines, p)
+					continue
+				}
+			}
+			log.WithField("cluster", cl.Name).WithError(err).Error("Skipping cluster")
+			health.SetReady("cluster/"+cl.Name, false)
+		}
+		if len(pipelines) == 0 {
+			log.Fatal("No configured cluster could be set up")
+		}
+		log.Infof("Multi-cluster mode: watching %d of %d configured clusters", len(pipelines), len(clusterCfgs))
+		if clusterName == "" {
+			clusterName = multiClusterName
+		}
+	}
+	log.Infof("Cluster name: %s", clusterName)
+
+	// --- Audit Log ---
+	var auditLog *audit.Writer
+	if *auditDir != "" {
+		auditLog, err = audit.NewWriter(audit.Options{
+			Dir:      *auditDir,
+			MaxSize:  int64(*auditMaxSizeMB) << 20,
+			MaxFiles: *auditMaxFiles,
+			Objects:  *auditObjects,
+			Diff:     *auditDiff,
+		})
+		if err != nil {
+			log.Fatalf("Error opening audit log: %v", err)
+		}
+		for _, p := range pipelines {
+			p.Cache().OnEvent(auditLog.Observer(p.Name()))
+		}
+		go auditLog.Run()
+		log.Infof("Writing the cache event audit log to %s", *auditDir)
+	}
+
+	// --- Tracing ---
+	shutdownTracing := func(context.Context) error { return nil }
+	if *otelEndpoint != "" {
+		shutdownTracing, err = setupTracing(context.Background(), *otelEndpoint, *otelInsecure, clusterName)
+		if err != nil {
+			log.Fatalf("Error setting up tracing: %v", err)
+		}
+		log.Infof("Exporting traces to %s", *otelEndpoint)
+	}
+	graphServer.Objects = pipelineObjects(pipelines)
+	builder := &graphBuilder{
+		pipelines:            pipelines,
+		clusterName:          clusterName,
+		stampClusterProperty: *stampClusterProperty,
+		anonymizer:           anonymizer,
+	}
+	if *collectorNode {
+		collector := graph.CollectorFromEnv(version)
+		collector.Kinds = watchedKinds(pipelines)
+		collector.Shard = opts.Shard
+		builder.collector = &collector
+		log.WithField("inCluster", collector.InCluster).Infof("Collector node: %s", collector.Name)
+	}
+	if *nodeCostFile != "" {
+		if builder.costs, err = cost.Load(*nodeCostFile); err != nil {
+			log.Fatalf("Error loading node costs: %v", err)
+		}
+		log.Infof("Stamping the hourly cost of %d instance types from %s", builder.costs.Len(), *nodeCostFile)
+	}
+	hooks.Default.Timeout = *hookTimeout
+	if *onBuildExec != "" {
+		hook, err := hooks.Exec(*onBuildExec)
+		if err != nil {
+			log.Fatalf("Invalid --on-build-exec: %v", err)
+		}
+		hooks.RegisterOnGraphBuilt(hook)
+	}
+	if len(changeRules) > 0 {
+		hooks.RegisterOnGraphBuilt(rules.NewEngine(changeRules).OnGraphBuilt)
+		log.Infof("Evaluating %d change rules after every build", len(changeRules))
+	}
+	builder.hooks = hooks.Default
+	if *serveAddr != "" || *grpcAddr != "" {
+		builder.onBuilt = append(builder.onBuilt, func(g graph.Graph) {
+			if err := graphServer.Update(g); err != nil {
+				log.WithError(err).Error("Error updating served graph")
+			}
+			if graphServer.History != nil {
+				graphServer.History.Add(g)
+			}
+		})
+	}
 
 	// --- Signal Handling & Start ---
-	stopCh := make(chan struct{})
-	shutdownCh := make(chan struct{})
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	changed := make(chan struct{}, 1)
+	for _, p := range pipelines {
+		p.Start(ctx, health, changed)
+	}
 
+	queue := emitter.NewQueue(emitFunc, *minEmitInterval)
+	queue.Heartbeat = *heartbeatInterval
+	queueDone := make(chan struct{})
 	go func() {
-		sig := <-sigCh
-		log.Infof("Received signal: %s. Shutting down...", sig)
-		close(shutdownCh)
-		close(stopCh)
+		defer close(queueDone)
+		queue.Run(ctx)
 	}()
 
-	factory.Start(stopCh)
+	go ratelog.Default.Run(ctx)
+	go churn.Default.Run(ctx)
 
-	// --- Wait for Sync ---
-	log.Info("Waiting for initial cache sync...")
-	if !cachepkg.WaitForCacheSync(stopCh,
-		podInf.HasSynced,
-		rsInf.HasSynced,
-		deployInf.HasSynced,
-		nodeInf.HasSynced,
-		svcInf.HasSynced,
-		cmInf.HasSynced) {
-		log.Fatal("Failed to sync caches")
+	// --- On-demand Snapshots ---
+	usr1Ch := make(chan os.Signal, 1)
+	notifySnapshotSignals(usr1Ch)
+	go trigger.ForwardSignals(ctx, usr1Ch)
+	if builder.costs != nil {
+		hupCh := make(chan os.Signal, 1)
+		notifyReloadSignals(hupCh)
+		go reloadCosts(ctx, builder.costs, hupCh, trigger)
 	}
-	log.Info("Caches synced.")
 
 	// --- Graph Build Loop ---
-	log.Info("Starting graph build loop...")
-Loop:
-	for {
-		select {
-		case <-resourceCache.Changed():
-			drain(resourceCache.Changed())
-			revisionMu.Lock()
-			currentGraphRevision++
-			graphRevision := currentGraphRevision
-			revisionMu.Unlock()
-
-			log.Debugf("Cache changed: Building graph revision %d", graphRevision)
-			graphData := graph.BuildGraph(resourceCache, graphRevision)
-
-			if err := emitter.EmitGraph(graphData, *outputDir); err != nil {
-				log.Errorf("Error emitting graph revision %d: %v", graphRevision, err)
-			}
+	loop := runner.New(builder.build, queue, changed, trigger.C())
+	loop.Resume(lastRevision)
+	loop.Seq = changeSeq(pipelines)
+	loop.Run(ctx)
 
-		case <-shutdownCh:
-			log.Info("Shutdown signal received, exiting build loop for final emit.")
-			break Loop
-		}
+	// stop informers and wait for their handler goroutines before the final
+	// build, so it sees every event delivered so far
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := stopInformers(shutdownCtx, pipelines); err != nil {
+		log.WithError(err).Warn("Building the final graph while informers are still stopping")
+	}
+	if auditLog != nil {
+		auditLog.Close()
 	}
+	<-queueDone
 
-	log.Info("Performing final graph build and emit...")
-	revisionMu.Lock()
-	currentGraphRevision++
-	finalGraphRevision := currentGraphRevision
-	revisionMu.Unlock()
+	exitCode := 0
+	if err := loop.Final(shutdownCtx); err != nil {
+		log.WithError(err).Error("Final graph emit abandoned")
+		exitCode = 1
+	}
 
-	finalGraphData := graph.BuildGraph(resourceCache, finalGraphRevision)
-	if err := emitter.EmitGraph(finalGraphData, *outputDir); err != nil {
-		log.Errorf("Error emitting final graph revision %d: %v", finalGraphRevision, err)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.WithError(err).Warn("Error flushing traces")
+	}
+	graphServer.Close()
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	adminCtx, adminCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer adminCancel()
+	if err := adminServer.Shutdown(adminCtx); err != nil {
+		log.Errorf("Error shutting down admin server: %v", err)
 	}
 
 	log.Info("Shutdown complete.")
-}
-func drain(ch <-chan struct{}) {
-	for {
-		select {
-		case <-ch:
-		default:
-			return
-		}
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
 }
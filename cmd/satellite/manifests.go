@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/manifests"
+	"github.com/tthuwng/satellite/internal/pipeline"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// manifestOptions configures an offline build from manifests.
+type manifestOptions struct {
+	// path is a manifest file, directory, or manifests.Stdin.
+	path string
+	// namespace is given to namespaced objects without one.
+	namespace            string
+	clusterName          string
+	stampClusterProperty bool
+	anonymizer           *graph.Anonymizer
+	// revision is the revision of the last graph emitted to the output.
+	revision uint64
+}
+
+// runManifests builds the graph that applying the manifests of opts.path
+// would create, without a cluster, and emits it. Objects only a cluster
+// fills in, such as pod placement and status, are absent.
+func runManifests(ctx context.Context, opts manifestOptions, emit emitter.EmitFunc) error {
+	p := pipeline.NewOffline(opts.clusterName)
+	loader := manifests.NewLoader(p.Cache(), opts.namespace)
+	if err := loader.LoadPath(opts.path); err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(loader.Skipped))
+	for kind := range loader.Skipped {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		log.WithField("kind", kind).WithField("objects", loader.Skipped[kind]).Warn("Skipping manifests of a kind Satellite does not watch")
+	}
+
+	builder := &graphBuilder{clusterName: opts.clusterName, stampClusterProperty: opts.stampClusterProperty, anonymizer: opts.anonymizer, pipelines: []*pipeline.Pipeline{p}}
+	g, err := builder.build(ctx, opts.revision+1)
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+	if err := emit(ctx, g); err != nil {
+		return fmt.Errorf("failed to emit graph: %w", err)
+	}
+	log.WithFields(log.Fields{"objects": loader.Loaded, "nodes": len(g.Nodes), "relationships": len(g.Relationships)}).Info("Emitted graph of manifests")
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySnapshotSignals relays the on-demand snapshot signal (SIGUSR1) to ch.
+func notifySnapshotSignals(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGUSR1)
+}
+
+// notifyReloadSignals relays the reload signal (SIGHUP) to ch.
+func notifyReloadSignals(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGHUP)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifySnapshotSignals is a no-op: Windows has no SIGUSR1, use POST /trigger.
+func notifySnapshotSignals(ch chan<- os.Signal) {}
+
+// notifyReloadSignals is a no-op: Windows has no SIGHUP.
+func notifyReloadSignals(ch chan<- os.Signal) {}
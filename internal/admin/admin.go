@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Server multiplexes the admin HTTP endpoints (health, pprof, ...) onto one
+// listener per configured address. Endpoints configured on the same address
+// share a single listener and mux.
+type Server struct {
+	mu      sync.Mutex
+	muxes   map[string]*http.ServeMux
+	tls     map[string]tlsFiles
+	order   []string
+	servers []*http.Server
+}
+
+// tlsFiles is the certificate and key served on one address.
+type tlsFiles struct {
+	certFile, keyFile string
+}
+
+// creates a new admin server with no listeners.
+func NewServer() *Server {
+	return &Server{
+		muxes: make(map[string]*http.ServeMux),
+		tls:   make(map[string]tlsFiles),
+	}
+}
+
+// Mux returns the mux serving addr, creating it on first use.
+func (s *Server) Mux(addr string) *http.ServeMux {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mux, ok := s.muxes[addr]
+	if !ok {
+		mux = http.NewServeMux()
+		s.muxes[addr] = mux
+		s.order = append(s.order, addr)
+	}
+	return mux
+}
+
+// SetTLS serves addr over TLS. Every endpoint sharing addr is affected.
+func (s *Server) SetTLS(addr, certFile, keyFile string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tls[addr] = tlsFiles{certFile: certFile, keyFile: keyFile}
+}
+
+// Start binds every configured address and serves it in the background.
+// Binding errors are returned synchronously so misconfiguration fails fast.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, addr := range s.order {
+		srv := &http.Server{Handler: s.muxes[addr]}
+		files, useTLS := s.tls[addr]
+		if useTLS {
+			cert, err := tls.LoadX509KeyPair(files.certFile, files.keyFile)
+			if err != nil {
+				return fmt.Errorf("failed to load TLS certificate for %s: %w", addr, err)
+			}
+			srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on admin address %s: %w", addr, err)
+		}
+		if useTLS {
+			ln = tls.NewListener(ln, srv.TLSConfig)
+		}
+		s.servers = append(s.servers, srv)
+
+		go func(addr string) {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("Admin server on %s stopped: %v", addr, err)
+			}
+		}(addr)
+		log.WithField("tls", useTLS).Infof("Admin server listening on %s", ln.Addr())
+	}
+	return nil
+}
+
+// Shutdown gracefully stops every listener started by Start.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers := s.servers
+	s.servers = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterPprof mounts the net/http/pprof handlers under /debug/pprof/.
+func RegisterPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// Health tracks liveness/readiness and serves /healthz and /readyz.
+// Readiness is the conjunction of named components (e.g. one per cluster), so
+// /readyz reports exactly which component is holding it back. Degraded
+// conditions (e.g. a full disk) are reported by both endpoints without
+// failing them.
+type Health struct {
+	mu         sync.RWMutex
+	components map[string]bool
+	degraded   map[string]string
+}
+
+// SetDegraded records a degraded condition with its reason; an empty reason
+// clears it.
+func (h *Health) SetDegraded(condition, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if reason == "" {
+		delete(h.degraded, condition)
+		return
+	}
+	if h.degraded == nil {
+		h.degraded = make(map[string]string)
+	}
+	h.degraded[condition] = reason
+}
+
+// Degraded returns a copy of the current degraded conditions.
+func (h *Health) Degraded() map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]string, len(h.degraded))
+	for k, v := range h.degraded {
+		out[k] = v
+	}
+	return out
+}
+
+// SetReady records the readiness of a single component.
+func (h *Health) SetReady(component string, ready bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.components == nil {
+		h.components = make(map[string]bool)
+	}
+	h.components[component] = ready
+}
+
+// Ready reports whether at least one component is registered and all are ready.
+func (h *Health) Ready() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.components) == 0 {
+		return false
+	}
+	for _, ready := range h.components {
+		if !ready {
+			return false
+		}
+	}
+	return true
+}
+
+// Register mounts the health handlers on mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n" + h.degradedReport()))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		if !h.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(h.report() + h.degradedReport()))
+	})
+}
+
+// degradedReport renders one "degraded: condition: reason" line per condition.
+func (h *Health) degradedReport() string {
+	degraded := h.Degraded()
+	names := make([]string, 0, len(degraded))
+	for name := range degraded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "degraded: %s: %s\n", name, degraded[name])
+	}
+	return b.String()
+}
+
+// report renders one "component: ready|not ready" line per component, sorted.
+func (h *Health) report() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.components))
+	for name := range h.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		state := "ready"
+		if !h.components[name] {
+			state = "not ready"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", name, state)
+	}
+	return b.String()
+}
@@ -0,0 +1,322 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/ratelog"
+
+	log "github.com/sirupsen/logrus"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// CurrentFile is the file events are appended to. Rotated files are
+	// renamed to events-<UTC time>.jsonl, which sort before it.
+	CurrentFile = "events.jsonl"
+
+	DefaultMaxSize  = 100 << 20
+	DefaultMaxFiles = 10
+	DefaultBuffer   = 4096
+
+	// rotatedTimeFormat sorts lexically in time order.
+	rotatedTimeFormat = "20060102T150405.000000000Z"
+)
+
+// Record is one line of the audit log.
+type Record struct {
+	Time            time.Time `json:"time"`
+	Cluster         string    `json:"cluster,omitempty"` // set in multi-cluster mode
+	Type            string    `json:"type"`              // ADD, UPDATE or DELETE
+	Kind            string    `json:"kind"`
+	APIVersion      string    `json:"apiVersion,omitempty"`
+	Namespace       string    `json:"namespace,omitempty"`
+	Name            string    `json:"name"`
+	UID             string    `json:"uid,omitempty"`
+	ResourceVersion string    `json:"resourceVersion,omitempty"`
+	// Changed lists the fields an UPDATE changed, e.g. "spec.replicas".
+	Changed []string `json:"changed,omitempty"`
+	// Object is the redacted object as observed.
+	Object json.RawMessage `json:"object,omitempty"`
+}
+
+// Options configures a Writer.
+type Options struct {
+	Dir string
+	// MaxSize is the size in bytes at which the current file is rotated.
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept (0 keeps all).
+	MaxFiles int
+	// Objects includes the redacted object in every record.
+	Objects bool
+	// Diff adds the changed fields to UPDATE records.
+	Diff bool
+	// Buffer is the number of events queued before new ones are dropped.
+	Buffer int
+}
+
+// pending is an observed event waiting to be written.
+type pending struct {
+	at      time.Time
+	cluster string
+	ev      cache.Event
+}
+
+// Writer appends cache events to a size-rotated JSONL log in the background.
+// Observe never blocks: when the writer falls behind, events are dropped and
+// counted instead of stalling the informer handlers.
+type Writer struct {
+	opts Options
+
+	mu     sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
+	queue  chan pending
+	done   chan struct{}
+
+	file *os.File
+	size int64
+}
+
+// creates a writer appending to opts.Dir, which is created if needed.
+func NewWriter(opts Options) (*Writer, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory %s: %w", opts.Dir, err)
+	}
+	w := &Writer{
+		opts:  opts,
+		queue: make(chan pending, opts.Buffer),
+		done:  make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Observe queues ev for writing, dropping it if the queue is full.
+func (w *Writer) Observe(ev cache.Event) {
+	w.observe("", ev)
+}
+
+// Observer returns an observer recording events as coming from cluster.
+func (w *Writer) Observer(cluster string) func(cache.Event) {
+	return func(ev cache.Event) { w.observe(cluster, ev) }
+}
+
+// observe queues ev from cluster, dropping it if the queue is full.
+func (w *Writer) observe(cluster string, ev cache.Event) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- pending{at: time.Now().UTC(), cluster: cluster, ev: ev}:
+	default:
+		metrics.AuditEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Run writes queued events until Close is called.
+func (w *Writer) Run() {
+	defer close(w.done)
+	for p := range w.queue {
+		if err := w.write(p); err != nil {
+			metrics.AuditEvents.WithLabelValues("failed").Inc()
+			ratelog.Default.Log(log.WithError(err), log.WarnLevel, "audit write", "Failed to write audit log record")
+			continue
+		}
+		metrics.AuditEvents.WithLabelValues("written").Inc()
+	}
+	if err := w.file.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close audit log")
+	}
+}
+
+// Close stops accepting events, waits for Run to write the queued ones and
+// closes the log. Run must have been started.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+// write encodes p and appends it, rotating the file first if it would grow
+// past MaxSize.
+func (w *Writer) write(p pending) error {
+	line, err := w.encode(p)
+	if err != nil {
+		return err
+	}
+	if w.size > 0 && w.size+int64(len(line)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to append to audit log: %w", err)
+	}
+	return nil
+}
+
+// encode renders p as one newline-terminated JSON record.
+func (w *Writer) encode(p pending) ([]byte, error) {
+	rec := Record{Time: p.at, Cluster: p.cluster, Type: p.ev.Type, Kind: p.ev.Kind, APIVersion: k8s.APIVersion(p.ev.Object)}
+	if meta, err := apimeta.Accessor(p.ev.Object); err == nil {
+		rec.Namespace = meta.GetNamespace()
+		rec.Name = meta.GetName()
+		rec.UID = string(meta.GetUID())
+		rec.ResourceVersion = meta.GetResourceVersion()
+	}
+	if w.opts.Objects || (w.opts.Diff && p.ev.Old != nil) {
+		obj := k8s.Redact(p.ev.Object)
+		if w.opts.Objects {
+			raw, err := json.Marshal(obj)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s %s/%s: %w", rec.Kind, rec.Namespace, rec.Name, err)
+			}
+			rec.Object = raw
+		}
+		if w.opts.Diff && p.ev.Old != nil {
+			rec.Changed = ChangedFields(k8s.Redact(p.ev.Old), obj)
+		}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// open opens the current file for appending.
+func (w *Writer) open() error {
+	f, err := os.OpenFile(filepath.Join(w.opts.Dir, CurrentFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// rotate renames the current file aside, reopens a fresh one and prunes the
+// oldest rotated files beyond MaxFiles.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	rotated := filepath.Join(w.opts.Dir, "events-"+time.Now().UTC().Format(rotatedTimeFormat)+".jsonl")
+	if err := os.Rename(filepath.Join(w.opts.Dir, CurrentFile), rotated); err != nil {
+		// keep appending to the oversized file rather than losing events
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.opts.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := Files(w.opts.Dir)
+	if err != nil {
+		return err
+	}
+	rotatedFiles := files[:len(files)-1] // the current file sorts last
+	for len(rotatedFiles) > w.opts.MaxFiles {
+		if err := os.Remove(rotatedFiles[0]); err != nil {
+			return fmt.Errorf("failed to prune audit log: %w", err)
+		}
+		rotatedFiles = rotatedFiles[1:]
+	}
+	return nil
+}
+
+// Files lists the audit log files in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	rotated, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+	current := filepath.Join(dir, CurrentFile)
+	if _, err := os.Stat(current); err == nil {
+		rotated = append(rotated, current)
+	}
+	return rotated, nil
+}
+
+// ChangedFields returns the dotted paths, down to the second level, whose
+// values differ between old and new (e.g. "metadata.labels", "spec.replicas").
+// resourceVersion is ignored since it changes on every update.
+func ChangedFields(old, new runtime.Object) []string {
+	oldMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(old)
+	if err != nil {
+		return nil
+	}
+	newMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(new)
+	if err != nil {
+		return nil
+	}
+	var changed []string
+	diffFields(oldMap, newMap, "", 2, &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+// diffFields appends the paths under prefix whose values differ, descending
+// depth levels into nested maps.
+func diffFields(old, new map[string]interface{}, prefix string, depth int, changed *[]string) {
+	keys := make(map[string]bool, len(old)+len(new))
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+	for k := range keys {
+		path := strings.TrimPrefix(prefix+"."+k, ".")
+		if path == "metadata.resourceVersion" {
+			continue
+		}
+		o, n := old[k], new[k]
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		oMap, oOK := o.(map[string]interface{})
+		nMap, nOK := n.(map[string]interface{})
+		if depth > 1 && oOK && nOK {
+			diffFields(oMap, nMap, path, depth-1, changed)
+			continue
+		}
+		*changed = append(*changed, path)
+	}
+}
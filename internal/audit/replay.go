@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	log "github.com/sirupsen/logrus"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// maxLine bounds a single audit log line; objects are at most ~1.5 MiB.
+const maxLine = 4 << 20
+
+// LineError is an audit log line that was skipped.
+type LineError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// errOutOfOrder marks a record older than the one before it.
+var errOutOfOrder = errors.New("record is older than the previous one")
+
+// Read calls fn with every record of the audit log in dir, oldest file
+// first, and its decoded object. Corrupt lines, records that can't be
+// decoded and records older than the previous record are passed to skipped,
+// if set, and left out. An error of fn stops the read.
+func Read(dir string, fn func(Record, runtime.Object) error, skipped func(*LineError)) error {
+	files, err := Files(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list audit log files: %w", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no audit log files in %s", dir)
+	}
+	skip := func(e *LineError) {
+		if skipped != nil {
+			skipped(e)
+		}
+	}
+	var last time.Time
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, maxLine)
+		line := 0
+		for scanner.Scan() {
+			line++
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				skip(&LineError{File: path, Line: line, Err: err})
+				continue
+			}
+			if rec.Time.IsZero() || rec.Kind == "" || rec.Name == "" {
+				skip(&LineError{File: path, Line: line, Err: errors.New("record lacks time, kind or name")})
+				continue
+			}
+			if rec.Time.Before(last) {
+				skip(&LineError{File: path, Line: line, Err: errOutOfOrder})
+				continue
+			}
+			obj, err := rec.Decode()
+			if err != nil {
+				skip(&LineError{File: path, Line: line, Err: err})
+				continue
+			}
+			last = rec.Time
+			if err := fn(rec, obj); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			// e.g. a line over maxLine: the rest of the file can't be framed
+			skip(&LineError{File: path, Line: line + 1, Err: err})
+		}
+	}
+	return nil
+}
+
+// Decode returns the object rec recorded, typed at its recorded apiVersion
+// if the scheme knows it and unstructured otherwise, e.g. for resources
+// watched with --extra-resources. A DELETE recorded without its object
+// decodes to an object of only its identity, which is enough to remove it
+// from a cache.
+func (rec Record) Decode() (runtime.Object, error) {
+	if rec.Type != "ADD" && rec.Type != "UPDATE" && rec.Type != "DELETE" {
+		return nil, fmt.Errorf("unknown event type %q", rec.Type)
+	}
+	gvk, err := rec.groupVersionKind()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", rec.Kind, err)
+	}
+	obj, err := scheme.Scheme.New(gvk)
+	if runtime.IsNotRegisteredError(err) {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		obj, err = u, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", rec.Kind, err)
+	}
+	if len(rec.Object) == 0 {
+		if rec.Type != "DELETE" {
+			return nil, fmt.Errorf("%s record has no object; the log was written with --event-log-objects=false", rec.Type)
+		}
+		meta, err := apimeta.Accessor(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", rec.Kind, err)
+		}
+		meta.SetNamespace(rec.Namespace)
+		meta.SetName(rec.Name)
+		meta.SetUID(k8stypes.UID(rec.UID))
+		meta.SetResourceVersion(rec.ResourceVersion)
+		return obj, nil
+	}
+	if err := json.Unmarshal(rec.Object, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s/%s: %w", rec.Kind, rec.Namespace, rec.Name, err)
+	}
+	return obj, nil
+}
+
+// groupVersionKind returns the kind rec recorded: at its apiVersion, or that
+// of its object in logs written before records carried one, or else the
+// version watched for its kind.
+func (rec Record) groupVersionKind() (schema.GroupVersionKind, error) {
+	apiVersion := rec.APIVersion
+	if apiVersion == "" && len(rec.Object) > 0 {
+		var meta metav1.TypeMeta
+		if err := json.Unmarshal(rec.Object, &meta); err != nil {
+			return schema.GroupVersionKind{}, err
+		}
+		apiVersion = meta.APIVersion
+	}
+	if apiVersion == "" {
+		return schema.GroupVersionKind{Group: k8s.KindGroup(rec.Kind), Version: k8s.KindVersion(rec.Kind), Kind: rec.Kind}, nil
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return gv.WithKind(rec.Kind), nil
+}
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	Dir string
+	// Interval, if positive, emits the state every Interval of log time.
+	Interval time.Duration
+	// At, if set, stops the replay at this time; the last emit shows the
+	// state as of At.
+	At time.Time
+	// Skipped, if set, is called with every line that was skipped.
+	Skipped func(*LineError)
+}
+
+// Replay applies the audit log in opts.Dir to the caches returned by
+// cacheFor, one per recorded cluster, and calls emit with the log time of
+// every state to emit: each Interval boundary passed, and the end of the
+// log or opts.At. Nothing is emitted for an empty log.
+func Replay(ctx context.Context, opts ReplayOptions, cacheFor func(cluster string) *cache.ResourceCache, emit func(ctx context.Context, at time.Time) error) error {
+	var next, last time.Time // next Interval boundary, last applied record
+	errStop := errors.New("stop")
+	err := Read(opts.Dir, func(rec Record, obj runtime.Object) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opts.At.IsZero() && rec.Time.After(opts.At) {
+			return errStop
+		}
+		if opts.Interval > 0 {
+			if next.IsZero() {
+				next = rec.Time.Truncate(opts.Interval).Add(opts.Interval)
+			}
+			if !rec.Time.Before(next) {
+				if err := emit(ctx, next); err != nil {
+					return err
+				}
+				// intervals without records would emit the same state again
+				next = rec.Time.Truncate(opts.Interval).Add(opts.Interval)
+			}
+		}
+		apply(cacheFor(rec.Cluster), rec.Type, obj)
+		last = rec.Time
+		return nil
+	}, opts.Skipped)
+	if err != nil && err != errStop {
+		return err
+	}
+	if last.IsZero() {
+		log.WithField("dir", opts.Dir).Warn("No audit log records to replay")
+		return nil
+	}
+	at := last
+	if !opts.At.IsZero() {
+		at = opts.At
+	}
+	return emit(ctx, at)
+}
+
+// apply applies one recorded event to c.
+func apply(c *cache.ResourceCache, eventType string, obj runtime.Object) {
+	switch eventType {
+	case "ADD", "UPDATE":
+		c.Upsert(obj)
+	case "DELETE":
+		c.Delete(obj)
+	}
+}
@@ -1,60 +1,175 @@
 package cache
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"satellite/internal/k8s"
-	"satellite/internal/types"
+	"github.com/tthuwng/satellite/internal/churn"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/ratelog"
+	"github.com/tthuwng/satellite/internal/types"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	cache "k8s.io/client-go/tools/cache"
 )
 
 // ResourceCache holds the state of observed Kubernetes resources.
 type ResourceCache struct {
-	store     map[types.EntityKey]runtime.Object
-	mu        sync.RWMutex
-	changedCh chan struct{}
+	// IgnoredFields are the fields whose changes alone are stored without
+	// signalling a change. Set it before starting the informers.
+	IgnoredFields IgnoredFields
+	// Linger is how long a deleted object is kept, marked deleted, before it
+	// is removed, so terminating pods keep their relationships while they
+	// may still be serving. 0 removes deleted objects immediately. Set it
+	// before starting the informers.
+	Linger time.Duration
+
+	store       map[types.EntityKey]runtime.Object
+	byUID       map[k8stypes.UID]types.EntityKey
+	byNamespace map[string]map[types.EntityKey]struct{} // namespaced keys only
+	deleted     map[types.EntityKey]time.Time           // lingering objects and when they were deleted
+	warnings    *warnings                               // Warning events by involved object
+	events      *recentEvents                           // the last Events of each involved object, reduced
+	mu          sync.RWMutex
+	changedCh   chan struct{}
+	waitMu      sync.Mutex
+	waitCh      chan struct{} // closed and replaced by every change; see wait.go
+	observers   []func(Event)
+	seq         atomic.Uint64 // incremented, under mu, by every change
+	freshness   freshness     // resourceVersions and bookmarks per kind; see freshness.go
+}
+
+// Event is a single informer event delivered to the cache.
+type Event struct {
+	Type   string // ADD, UPDATE or DELETE
+	Kind   string
+	Old    runtime.Object // previous object of an UPDATE
+	Object runtime.Object
 }
 
 // creates a new empty cache.
 func NewResourceCache() *ResourceCache {
 	return &ResourceCache{
-		store:     make(map[types.EntityKey]runtime.Object),
-		changedCh: make(chan struct{}, 1), // enough to signal change
+		IgnoredFields: DefaultIgnoredFields,
+		store:         make(map[types.EntityKey]runtime.Object),
+		byUID:         make(map[k8stypes.UID]types.EntityKey),
+		byNamespace:   make(map[string]map[types.EntityKey]struct{}),
+		deleted:       make(map[types.EntityKey]time.Time),
+		warnings:      newWarnings(MaxWarningObjects),
+		events:        newRecentEvents(MaxEventObjects, MaxEventsPerObject),
+		changedCh:     make(chan struct{}, 1), // enough to signal change
+	}
+}
+
+// OnEvent registers fn to be called with every informer event before it is
+// applied. fn runs on the informer's handler goroutine and must not block.
+// Register observers before starting the informers.
+func (c *ResourceCache) OnEvent(fn func(Event)) {
+	c.observers = append(c.observers, fn)
+}
+
+// observe hands ev to the registered observers.
+func (c *ResourceCache) observe(ev Event) {
+	for _, fn := range c.observers {
+		fn(ev)
 	}
 }
 
+// Seq returns the change counter. It increases with every change to the
+// cache's content, so a build that started at Seq() == n is stale once Seq()
+// returns anything else.
+func (c *ResourceCache) Seq() uint64 {
+	return c.seq.Load()
+}
+
 // returns a channel that signals when the cache content has changed.
 func (c *ResourceCache) Changed() <-chan struct{} {
 	return c.changedCh
 }
 
-// Upsert adds or updates an object in the cache.
+// Upsert adds or updates an object in the cache. The cache keeps obj itself,
+// so it must not be modified afterwards; informers hand out a new object for
+// every change. An update changing only IgnoredFields is stored without
+// signalling a change. An object replacing a lingering deleted one, e.g. a
+// pod recreated under the same name, ends the linger. Events are not stored
+// as objects: the last MaxEventsPerObject of each involved object are kept
+// reduced, and Warning events are also summarized onto it.
 func (c *ResourceCache) Upsert(obj runtime.Object) {
 	key, ok := k8s.GetKey(obj)
 	if !ok {
 		return
 	}
+	if key.Kind == "Event" {
+		c.upsertEvent(nil, obj)
+		return
+	}
 
 	newMeta := k8s.GetObjectMeta(obj)
+	c.observeVersion(key.Kind, newMeta.ResourceVersion)
+
+	// Significant converts both objects, which is too slow to hold the
+	// write lock for under churn: it compares against the object read
+	// here, and the update counts as significant if that was replaced
+	// meanwhile.
+	c.mu.RLock()
+	prevObj, prevExists := c.store[key]
+	_, prevDeleted := c.deleted[key]
+	c.mu.RUnlock()
+	significant := true
+	var prevVersion string
+	if prevExists && !prevDeleted {
+		prevVersion = k8s.GetObjectMeta(prevObj).ResourceVersion
+		if prevVersion != newMeta.ResourceVersion {
+			significant = c.IgnoredFields.Significant(key.Kind, prevObj, obj)
+		}
+	}
 
 	c.mu.Lock()
 	oldObj, exists := c.store[key]
+	_, wasDeleted := c.deleted[key]
 
 	shouldUpdate := true
-	if exists {
+	if exists && !wasDeleted {
 		oldMeta := k8s.GetObjectMeta(oldObj)
 		if oldMeta.ResourceVersion == newMeta.ResourceVersion {
 			shouldUpdate = false
-			log.Tracef("Cache Upsert Skipped (same ResourceVersion): %s %s/%s V:%s", key.Kind, key.Namespace, key.Name, newMeta.ResourceVersion) // Trace level
+			metrics.CacheNoopUpdates.WithLabelValues(key.Kind).Inc()
+			churn.Default.Observe(key.Kind, churn.Noop)
+			ratelog.Default.Log(logKey(key).WithField("resourceVersion", newMeta.ResourceVersion), log.TraceLevel, "cache upsert skipped "+key.Kind, "Cache Upsert skipped (same ResourceVersion)")
 		}
 	}
 
 	if shouldUpdate {
-		log.Debugf("Cache Upsert: %s %s/%s V:%s", key.Kind, key.Namespace, key.Name, newMeta.ResourceVersion)
+		ratelog.Default.Log(logKey(key).WithField("resourceVersion", newMeta.ResourceVersion), log.DebugLevel, "cache upsert "+key.Kind, "Cache Upsert")
+		if exists {
+			// the object may have been recreated under a new UID
+			delete(c.byUID, k8s.GetObjectMeta(oldObj).UID)
+		} else {
+			metrics.CacheObjects.WithLabelValues(key.Kind).Inc()
+			c.indexNamespace(key)
+		}
+		delete(c.deleted, key)
 		c.store[key] = obj
+		if newMeta.UID != "" {
+			c.byUID[newMeta.UID] = key
+		}
+		compared := prevExists && !prevDeleted && exists && !wasDeleted &&
+			k8s.GetObjectMeta(oldObj).ResourceVersion == prevVersion
+		if compared && !significant {
+			// stored to stay fresh, but not worth a rebuild
+			c.mu.Unlock()
+			metrics.CacheInsignificantUpdates.WithLabelValues(key.Kind).Inc()
+			return
+		}
+		c.seq.Add(1)
 		c.mu.Unlock()
 		c.signalChange()
 	} else {
@@ -62,39 +177,245 @@ func (c *ResourceCache) Upsert(obj runtime.Object) {
 	}
 }
 
-// Delete removes an object from the cache.
-func (c *ResourceCache) Delete(obj interface{}) {
-	var robj runtime.Object
-	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-	if ok {
-		robj, ok = tombstone.Obj.(runtime.Object)
-		if !ok {
-			log.Errorf("Tombstone contained non-runtime.Object: %T", tombstone.Obj)
-			return
-		}
-	} else {
-		robj, ok = obj.(runtime.Object)
-		if !ok {
-			log.Errorf("Delete event received non-runtime.Object and non-tombstone: %T", obj)
-			return
-		}
+// upsertEvent records the event obj, whose previous version was old (nil if
+// unknown), signalling a change if it counted.
+func (c *ResourceCache) upsertEvent(old, obj runtime.Object) {
+	ev, ok := obj.(*corev1.Event)
+	if !ok {
+		return // metadata-only events carry no involved object
+	}
+	c.observeVersion("Event", ev.ResourceVersion)
+	oldEv, _ := old.(*corev1.Event)
+	warned := c.warnings.observe(oldEv, ev, time.Now())
+	if !c.events.observe(ev) && !warned {
+		return
 	}
+	c.mu.Lock()
+	c.seq.Add(1)
+	c.mu.Unlock()
+	c.signalChange()
+}
 
-	key, ok := k8s.GetKey(robj)
+// Delete removes an object from the cache. A tombstone is resolved through
+// its inner object; the informer handlers also know the kind and resolve it
+// through the tombstone's key instead.
+func (c *ResourceCache) Delete(obj interface{}) {
+	key, uid, ok := deletedKey("", obj)
 	if !ok {
 		return
 	}
+	if robj, ok := obj.(runtime.Object); ok {
+		c.observeVersion(key.Kind, k8s.GetObjectMeta(robj).ResourceVersion)
+	}
+	c.deleteKey(key, uid)
+}
 
+// deleteKey removes key from the cache, along with the UID index entries of
+// the cached object and of uid, the UID the delete event carried. With a
+// Linger the cached object is only marked deleted and removed once the
+// linger expires.
+func (c *ResourceCache) deleteKey(key types.EntityKey, uid k8stypes.UID) {
+	if key.Kind == "Event" {
+		if c.events.remove(key.Namespace, key.Name) {
+			c.mu.Lock()
+			c.seq.Add(1)
+			c.mu.Unlock()
+			c.signalChange()
+		}
+		return
+	}
 	c.mu.Lock()
-	_, exists := c.store[key]
+	cached, exists := c.store[key]
+	linger := exists && c.Linger > 0
+	// a lingering object stays reachable through its own UID
+	if uid != "" && c.byUID[uid] == key && !(linger && k8s.GetObjectMeta(cached).UID == uid) {
+		delete(c.byUID, uid)
+	}
+	if linger {
+		if _, lingering := c.deleted[key]; lingering {
+			c.mu.Unlock()
+			return
+		}
+		ratelog.Default.Log(logKey(key), log.DebugLevel, "cache linger "+key.Kind, "Cache Delete, lingering")
+		c.deleted[key] = time.Now()
+		c.seq.Add(1)
+		c.mu.Unlock()
+		c.signalChange()
+		time.AfterFunc(c.Linger, func() { c.expire(key, cached) })
+		if key.Kind == "Namespace" && key.Namespace == "" {
+			c.EvictNamespace(key.Name)
+		}
+		return
+	}
 	if exists {
-		log.Debugf("Cache Delete: %s %s/%s", key.Kind, key.Namespace, key.Name)
+		ratelog.Default.Log(logKey(key), log.DebugLevel, "cache delete "+key.Kind, "Cache Delete")
 		delete(c.store, key)
+		delete(c.byUID, k8s.GetObjectMeta(cached).UID)
+		c.unindexNamespace(key)
+		metrics.CacheObjects.WithLabelValues(key.Kind).Dec()
+		c.seq.Add(1)
 		c.mu.Unlock()
 		c.signalChange()
 	} else {
 		c.mu.Unlock()
 	}
+	if key.Kind == "Namespace" && key.Namespace == "" {
+		c.EvictNamespace(key.Name)
+	}
+}
+
+// expire removes the lingering deleted obj under key, unless it has been
+// replaced since.
+func (c *ResourceCache) expire(key types.EntityKey, obj runtime.Object) {
+	c.mu.Lock()
+	_, lingering := c.deleted[key]
+	if cached, ok := c.store[key]; !ok || !lingering || cached != obj {
+		c.mu.Unlock()
+		return
+	}
+	ratelog.Default.Log(logKey(key), log.DebugLevel, "cache delete "+key.Kind, "Cache Delete")
+	delete(c.store, key)
+	delete(c.deleted, key)
+	if uid := k8s.GetObjectMeta(obj).UID; c.byUID[uid] == key {
+		delete(c.byUID, uid)
+	}
+	c.unindexNamespace(key)
+	metrics.CacheObjects.WithLabelValues(key.Kind).Dec()
+	c.seq.Add(1)
+	c.mu.Unlock()
+	c.signalChange()
+}
+
+// EvictNamespace removes every cached object of namespace at once, lingering
+// ones included, and returns how many there were. A Namespace delete is
+// authoritative: its objects are gone even if the watch dropped their delete
+// events, as happens during mass deletions.
+func (c *ResourceCache) EvictNamespace(namespace string) int {
+	c.mu.Lock()
+	keys := c.byNamespace[namespace]
+	for key := range keys {
+		if obj, ok := c.store[key]; ok {
+			if uid := k8s.GetObjectMeta(obj).UID; c.byUID[uid] == key {
+				delete(c.byUID, uid)
+			}
+			delete(c.store, key)
+			metrics.CacheObjects.WithLabelValues(key.Kind).Dec()
+		}
+		delete(c.deleted, key)
+	}
+	delete(c.byNamespace, namespace)
+	evicted := len(keys)
+	if evicted == 0 {
+		c.mu.Unlock()
+		return 0
+	}
+	c.seq.Add(1)
+	c.mu.Unlock()
+	c.signalChange()
+	log.WithFields(log.Fields{"namespace": namespace, "evicted": evicted}).Info("Namespace deleted; force-evicted its remaining cached objects")
+	return evicted
+}
+
+// ListByNamespace returns the cached objects of namespace, lingering deleted
+// ones included.
+func (c *ResourceCache) ListByNamespace(namespace string) []runtime.Object {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := c.byNamespace[namespace]
+	list := make([]runtime.Object, 0, len(keys))
+	for key := range keys {
+		list = append(list, c.store[key])
+	}
+	return list
+}
+
+// indexNamespace adds a newly stored key to the namespace index. Callers
+// hold c.mu.
+func (c *ResourceCache) indexNamespace(key types.EntityKey) {
+	if key.Namespace == "" {
+		return
+	}
+	keys, ok := c.byNamespace[key.Namespace]
+	if !ok {
+		keys = make(map[types.EntityKey]struct{})
+		c.byNamespace[key.Namespace] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// unindexNamespace removes a key no longer stored from the namespace index.
+// Callers hold c.mu.
+func (c *ResourceCache) unindexNamespace(key types.EntityKey) {
+	keys, ok := c.byNamespace[key.Namespace]
+	if !ok {
+		return
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(c.byNamespace, key.Namespace)
+	}
+}
+
+// deletedKey returns the key and UID of the object a delete event of kind
+// removes. For a tombstone the key is parsed from tombstone.Key, which the
+// informer computed itself, since the inner object may be stale, nil or of a
+// type GetKey doesn't know. The inner object's own key is only the fallback,
+// and the only option when kind is unknown.
+func deletedKey(kind string, obj interface{}) (types.EntityKey, k8stypes.UID, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok && kind != "" {
+		var uid k8stypes.UID
+		if inner := tombstoneObject(tombstone); inner != nil {
+			if meta, err := apimeta.Accessor(inner); err == nil {
+				uid = meta.GetUID()
+			}
+		}
+		namespace, name, err := cache.SplitMetaNamespaceKey(tombstone.Key)
+		if err == nil && name != "" {
+			return types.EntityKey{Kind: kind, APIGroup: k8s.KindGroup(kind), Namespace: namespace, Name: name}, uid, true
+		}
+		log.WithFields(log.Fields{"kind": kind, "key": tombstone.Key}).Warn("Could not parse tombstone key, falling back to its object")
+	}
+
+	robj, ok := deletedObject(obj)
+	if !ok {
+		return types.EntityKey{}, "", false
+	}
+	key, ok := k8s.GetKey(robj)
+	if !ok {
+		log.WithField("type", fmt.Sprintf("%T", robj)).Error("Dropping delete event without a usable key")
+		return types.EntityKey{}, "", false
+	}
+	return key, k8s.GetObjectMeta(robj).UID, true
+}
+
+// deletedObject returns the object of a delete event, unwrapping tombstones.
+func deletedObject(obj interface{}) (runtime.Object, bool) {
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if ok {
+		robj := tombstoneObject(tombstone)
+		if robj == nil {
+			log.WithField("type", fmt.Sprintf("%T", tombstone.Obj)).Error("Tombstone contained no runtime.Object")
+		}
+		return robj, robj != nil
+	}
+	robj, ok := obj.(runtime.Object)
+	if !ok {
+		log.WithField("type", fmt.Sprintf("%T", obj)).Error("Delete event received non-runtime.Object and non-tombstone")
+	}
+	return robj, ok
+}
+
+// tombstoneObject returns the inner object of tombstone, or nil if it has
+// none, including a typed nil pointer.
+func tombstoneObject(tombstone cache.DeletedFinalStateUnknown) runtime.Object {
+	robj, ok := tombstone.Obj.(runtime.Object)
+	if !ok {
+		return nil
+	}
+	if v := reflect.ValueOf(robj); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+	return robj
 }
 
 // Get retrieves an object by key.
@@ -105,6 +426,18 @@ func (c *ResourceCache) Get(key types.EntityKey) (runtime.Object, bool) {
 	return obj, found
 }
 
+// GetByUID retrieves an object by its metadata.uid.
+func (c *ResourceCache) GetByUID(uid k8stypes.UID) (runtime.Object, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.byUID[uid]
+	if !ok {
+		return nil, false
+	}
+	obj, found := c.store[key]
+	return obj, found
+}
+
 // List returns a snapshot of all objects currently in the cache.
 func (c *ResourceCache) List() []runtime.Object {
 	c.mu.RLock()
@@ -117,11 +450,99 @@ func (c *ResourceCache) List() []runtime.Object {
 	return list
 }
 
-// signalChange sends a non-blocking signal to changedCh.
+// Snapshot is an immutable point-in-time view of the cache. Later upserts and
+// deletes don't affect it.
+type Snapshot struct {
+	objects          map[types.EntityKey]runtime.Object
+	deleted          map[types.EntityKey]time.Time
+	warnings         map[k8stypes.UID]WarningSummary
+	events           []RecentEvent
+	resourceVersions map[string]string
+	bookmarks        map[string]time.Time
+	seq              uint64
+}
+
+// Snapshot copies the cache's index. Objects are shared with the cache,
+// which never modifies a stored object in place.
+func (c *ResourceCache) Snapshot() *Snapshot {
+	resourceVersions, bookmarks := c.ResourceVersions(), c.Bookmarks()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	objects := make(map[types.EntityKey]runtime.Object, len(c.store))
+	for k, v := range c.store {
+		objects[k] = v
+	}
+	var deleted map[types.EntityKey]time.Time
+	if len(c.deleted) > 0 {
+		deleted = make(map[types.EntityKey]time.Time, len(c.deleted))
+		for k, v := range c.deleted {
+			deleted[k] = v
+		}
+	}
+	return &Snapshot{
+		objects:          objects,
+		deleted:          deleted,
+		warnings:         c.warnings.summaries(time.Now()),
+		events:           c.events.list(),
+		resourceVersions: resourceVersions,
+		bookmarks:        bookmarks,
+		seq:              c.seq.Load(),
+	}
+}
+
+// Seq returns the cache's change counter at the time of the snapshot.
+func (s *Snapshot) Seq() uint64 {
+	return s.seq
+}
+
+// Get retrieves an object of the snapshot by key.
+func (s *Snapshot) Get(key types.EntityKey) (runtime.Object, bool) {
+	obj, found := s.objects[key]
+	return obj, found
+}
+
+// DeletedAt returns when the object under key was deleted, if it is a
+// deleted object kept for the cache's Linger.
+func (s *Snapshot) DeletedAt(key types.EntityKey) (time.Time, bool) {
+	at, ok := s.deleted[key]
+	return at, ok
+}
+
+// Warnings returns the summary of the recent Warning events of the object
+// with uid, as of the snapshot.
+func (s *Snapshot) Warnings(uid k8stypes.UID) (WarningSummary, bool) {
+	summary, ok := s.warnings[uid]
+	return summary, ok
+}
+
+// Events returns the kept events, sorted by namespace and name.
+func (s *Snapshot) Events() []RecentEvent {
+	return s.events
+}
+
+// List returns the objects of the snapshot.
+func (s *Snapshot) List() []runtime.Object {
+	list := make([]runtime.Object, 0, len(s.objects))
+	for _, obj := range s.objects {
+		list = append(list, obj)
+	}
+	return list
+}
+
+// Len returns the number of objects in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.objects)
+}
+
+// signalChange sends a non-blocking signal to changedCh and wakes the
+// waiters.
 func (c *ResourceCache) signalChange() {
+	c.wake()
 	select {
 	case c.changedCh <- struct{}{}:
+		metrics.ChangeSignals.WithLabelValues("sent").Inc()
 	default:
+		metrics.ChangeSignals.WithLabelValues("coalesced").Inc()
 	}
 }
 
@@ -130,18 +551,70 @@ func (c *ResourceCache) AddEventHandler(resourceType string) cache.ResourceEvent
 	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			meta := k8s.GetObjectMeta(obj) // Use k8s.GetObjectMeta
-			log.Debugf("ADD %s: %s/%s", resourceType, meta.Namespace, meta.Name)
+			logEvent("ADD", resourceType, meta.Namespace, meta.Name)
+			metrics.InformerEvents.WithLabelValues(resourceType, "add").Inc()
+			churn.Default.Observe(resourceType, "add")
+			c.observe(Event{Type: "ADD", Kind: resourceType, Object: obj.(runtime.Object)})
 			c.Upsert(obj.(runtime.Object))
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			meta := k8s.GetObjectMeta(newObj) // Use k8s.GetObjectMeta
-			log.Debugf("UPDATE %s: %s/%s", resourceType, meta.Namespace, meta.Name)
+			logEvent("UPDATE", resourceType, meta.Namespace, meta.Name)
+			metrics.InformerEvents.WithLabelValues(resourceType, "update").Inc()
+			churn.Default.Observe(resourceType, "update")
+			old, _ := oldObj.(runtime.Object)
+			c.observe(Event{Type: "UPDATE", Kind: resourceType, Old: old, Object: newObj.(runtime.Object)})
+			if resourceType == "Event" {
+				c.upsertEvent(old, newObj.(runtime.Object))
+				return
+			}
 			c.Upsert(newObj.(runtime.Object))
 		},
 		DeleteFunc: func(obj interface{}) {
-			meta := k8s.GetObjectMeta(obj) // Use k8s.GetObjectMeta
-			log.Debugf("DELETE %s: %s/%s", resourceType, meta.Namespace, meta.Name)
-			c.Delete(obj)
+			metrics.InformerEvents.WithLabelValues(resourceType, "delete").Inc()
+			churn.Default.Observe(resourceType, "delete")
+			key, uid, ok := deletedKey(resourceType, obj)
+			if !ok {
+				return
+			}
+			logEvent("DELETE", resourceType, key.Namespace, key.Name)
+			robj, ok := obj.(runtime.Object)
+			if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+				robj = tombstoneObject(tombstone)
+				ok = robj != nil
+			}
+			if ok {
+				c.observe(Event{Type: "DELETE", Kind: resourceType, Object: robj})
+				if _, isTombstone := obj.(cache.DeletedFinalStateUnknown); !isTombstone {
+					// a tombstone's object is the last known state, not the deletion
+					c.observeVersion(resourceType, k8s.GetObjectMeta(robj).ResourceVersion)
+				}
+			}
+			c.deleteKey(key, uid)
 		},
 	}
 }
+
+// logKey returns a log entry carrying the structured identity of key.
+func logKey(key types.EntityKey) *log.Entry {
+	return log.WithFields(log.Fields{
+		"kind":      key.Kind,
+		"namespace": key.Namespace,
+		"name":      key.Name,
+	})
+}
+
+// logEvent logs a single informer event at debug level, rate-limited per
+// kind and event type.
+func logEvent(eventType, resourceType, namespace, name string) {
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	entry := log.WithFields(log.Fields{
+		"event":     eventType,
+		"kind":      resourceType,
+		"namespace": namespace,
+		"name":      name,
+	})
+	ratelog.Default.Log(entry, log.DebugLevel, resourceType+" "+eventType, "Informer event")
+}
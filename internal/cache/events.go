@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// MaxEventsPerObject bounds the events kept per involved object. Past it,
+// the one that occurred least recently is dropped.
+const MaxEventsPerObject = 5
+
+// MaxEventObjects bounds the number of objects events are kept for. Past
+// it, the events of the object with the least recent event are dropped.
+const MaxEventObjects = 10000
+
+// RecentEvent is an Event reduced to what the graph shows of it.
+type RecentEvent struct {
+	Name, Namespace string
+	Type            string
+	Reason          string
+	Message         string // truncated to MaxWarningMessage
+	Count           int32
+	LastTime        time.Time
+	InvolvedObject  corev1.ObjectReference
+}
+
+// eventObject holds the events of one involved object, least recent first.
+type eventObject struct {
+	ref    corev1.ObjectReference // kind, namespace and name only
+	events []RecentEvent
+}
+
+// recentEvents keeps the last events of each involved object, matched by
+// kind, namespace and name. Objects are kept most recently evented about
+// first.
+type recentEvents struct {
+	mu        sync.Mutex
+	max       int
+	perObject int
+	byObject  map[corev1.ObjectReference]*list.Element
+	byEvent   map[k8stypes.NamespacedName]corev1.ObjectReference
+	lru       *list.List // of *eventObject
+}
+
+func newRecentEvents(max, perObject int) *recentEvents {
+	return &recentEvents{
+		max:       max,
+		perObject: perObject,
+		byObject:  make(map[corev1.ObjectReference]*list.Element),
+		byEvent:   make(map[k8stypes.NamespacedName]corev1.ObjectReference),
+		lru:       list.New(),
+	}
+}
+
+// involvedRef returns the key events about ev's involved object share.
+func involvedRef(ev *corev1.Event) corev1.ObjectReference {
+	inv := ev.InvolvedObject
+	return corev1.ObjectReference{Kind: inv.Kind, Namespace: inv.Namespace, Name: inv.Name}
+}
+
+// observe stores ev, replacing its previous version. It returns whether
+// anything changed: events without an involved object are ignored.
+func (r *recentEvents) observe(ev *corev1.Event) bool {
+	ref := involvedRef(ev)
+	if ref.Kind == "" || ref.Name == "" {
+		return false
+	}
+	rec := RecentEvent{
+		Name:           ev.Name,
+		Namespace:      ev.Namespace,
+		Type:           ev.Type,
+		Reason:         ev.Reason,
+		Message:        truncateMessage(ev.Message),
+		Count:          max(ev.Count, 1),
+		LastTime:       eventTime(ev),
+		InvolvedObject: ev.InvolvedObject,
+	}
+	if ev.Series != nil && ev.Series.Count > rec.Count {
+		rec.Count = ev.Series.Count
+	}
+	name := k8stypes.NamespacedName{Namespace: ev.Namespace, Name: ev.Name}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.byEvent[name]; ok && prev != ref {
+		// the event was reused for another object
+		r.removeLocked(name)
+	}
+	var obj *eventObject
+	if el, ok := r.byObject[ref]; ok {
+		r.lru.MoveToFront(el)
+		obj = el.Value.(*eventObject)
+	} else {
+		obj = &eventObject{ref: ref}
+		r.byObject[ref] = r.lru.PushFront(obj)
+		if r.lru.Len() > r.max {
+			r.dropLocked(r.lru.Back())
+		}
+	}
+	for i, e := range obj.events {
+		if e.Name == rec.Name && e.Namespace == rec.Namespace {
+			if e == rec {
+				return false
+			}
+			obj.events = append(obj.events[:i], obj.events[i+1:]...)
+			break
+		}
+	}
+	obj.events = append(obj.events, rec)
+	sort.SliceStable(obj.events, func(i, j int) bool { return obj.events[i].LastTime.Before(obj.events[j].LastTime) })
+	r.byEvent[name] = ref
+	for len(obj.events) > r.perObject {
+		delete(r.byEvent, k8stypes.NamespacedName{Namespace: obj.events[0].Namespace, Name: obj.events[0].Name})
+		obj.events = obj.events[1:]
+	}
+	return true
+}
+
+// remove drops the event namespace/name, returning whether it was kept.
+func (r *recentEvents) remove(namespace, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removeLocked(k8stypes.NamespacedName{Namespace: namespace, Name: name})
+}
+
+func (r *recentEvents) removeLocked(name k8stypes.NamespacedName) bool {
+	ref, ok := r.byEvent[name]
+	if !ok {
+		return false
+	}
+	delete(r.byEvent, name)
+	el := r.byObject[ref]
+	obj := el.Value.(*eventObject)
+	for i, e := range obj.events {
+		if e.Name == name.Name && e.Namespace == name.Namespace {
+			obj.events = append(obj.events[:i], obj.events[i+1:]...)
+			break
+		}
+	}
+	if len(obj.events) == 0 {
+		r.lru.Remove(el)
+		delete(r.byObject, ref)
+	}
+	return true
+}
+
+// dropLocked drops the object at el with all its events.
+func (r *recentEvents) dropLocked(el *list.Element) {
+	obj := r.lru.Remove(el).(*eventObject)
+	delete(r.byObject, obj.ref)
+	for _, e := range obj.events {
+		delete(r.byEvent, k8stypes.NamespacedName{Namespace: e.Namespace, Name: e.Name})
+	}
+}
+
+// list returns every kept event, sorted by namespace and name.
+func (r *recentEvents) list() []RecentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.byEvent) == 0 {
+		return nil
+	}
+	out := make([]RecentEvent, 0, len(r.byEvent))
+	for el := r.lru.Front(); el != nil; el = el.Next() {
+		out = append(out, el.Value.(*eventObject).events...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/metrics"
+)
+
+// freshness tracks, per kind, the highest resourceVersion the cache has
+// seen and when its watch last confirmed being current without an object
+// change: a bookmark. resourceVersions are opaque in general but integers
+// on etcd-backed servers; ones that don't parse as such are ignored.
+type freshness struct {
+	mu    sync.Mutex
+	kinds map[string]*kindFreshness
+}
+
+type kindFreshness struct {
+	resourceVersion uint64
+	bookmarkAt      time.Time
+}
+
+// advance raises kind's resourceVersion to rv, reporting whether it grew.
+// Callers hold f.mu.
+func (f *freshness) advance(kind, rv string) (*kindFreshness, bool) {
+	v, err := strconv.ParseUint(rv, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	if f.kinds == nil {
+		f.kinds = make(map[string]*kindFreshness)
+	}
+	k := f.kinds[kind]
+	if k == nil {
+		k = &kindFreshness{}
+		f.kinds[kind] = k
+	}
+	if v <= k.resourceVersion {
+		return k, false
+	}
+	k.resourceVersion = v
+	metrics.WatchProgress.WithLabelValues(kind).SetToCurrentTime()
+	return k, true
+}
+
+// observeVersion records the resourceVersion of an object of kind the cache
+// received.
+func (c *ResourceCache) observeVersion(kind, rv string) {
+	c.freshness.mu.Lock()
+	defer c.freshness.mu.Unlock()
+	c.freshness.advance(kind, rv)
+}
+
+// Bookmark records that kind's watch is current as of rv, as reported by
+// its informer after a bookmark or relist. An rv above every object
+// received counts as a bookmark at the time of the call.
+func (c *ResourceCache) Bookmark(kind, rv string) {
+	c.freshness.mu.Lock()
+	defer c.freshness.mu.Unlock()
+	if k, advanced := c.freshness.advance(kind, rv); advanced {
+		k.bookmarkAt = time.Now().UTC()
+	}
+}
+
+// ResourceVersions returns the highest resourceVersion seen per kind.
+func (c *ResourceCache) ResourceVersions() map[string]string {
+	c.freshness.mu.Lock()
+	defer c.freshness.mu.Unlock()
+	return c.freshness.resourceVersions()
+}
+
+// Bookmarks returns the time of the latest bookmark per kind.
+func (c *ResourceCache) Bookmarks() map[string]time.Time {
+	c.freshness.mu.Lock()
+	defer c.freshness.mu.Unlock()
+	return c.freshness.bookmarks()
+}
+
+func (f *freshness) resourceVersions() map[string]string {
+	if len(f.kinds) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(f.kinds))
+	for kind, k := range f.kinds {
+		out[kind] = strconv.FormatUint(k.resourceVersion, 10)
+	}
+	return out
+}
+
+func (f *freshness) bookmarks() map[string]time.Time {
+	var out map[string]time.Time
+	for kind, k := range f.kinds {
+		if k.bookmarkAt.IsZero() {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]time.Time)
+		}
+		out[kind] = k.bookmarkAt
+	}
+	return out
+}
+
+// ResourceVersions returns the highest resourceVersion seen per kind as of
+// the snapshot.
+func (s *Snapshot) ResourceVersions() map[string]string {
+	return maps.Clone(s.resourceVersions)
+}
+
+// Bookmarks returns the time of the latest bookmark per kind as of the
+// snapshot.
+func (s *Snapshot) Bookmarks() map[string]time.Time {
+	return maps.Clone(s.bookmarks)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AllKinds is the IgnoredFields key whose paths apply to every kind.
+const AllKinds = "*"
+
+// IgnoredFields lists, per kind, the field paths whose changes alone don't
+// make an update significant. Paths are dotted; a "[]" suffix applies the
+// rest of the path to every element of a list, e.g.
+// "status.conditions[].lastHeartbeatTime".
+type IgnoredFields map[string][]string
+
+// DefaultIgnoredFields ignores bookkeeping every object carries and the Node
+// heartbeat timestamps the kubelet bumps every few seconds.
+var DefaultIgnoredFields = IgnoredFields{
+	AllKinds: {"metadata.managedFields", "metadata.resourceVersion"},
+	"Node":   {"status.conditions[].lastHeartbeatTime"},
+}
+
+// ParseIgnoredFields parses a comma-separated list of kind:path entries, e.g.
+// "*:metadata.managedFields,Node:status.conditions[].lastHeartbeatTime".
+func ParseIgnoredFields(list string) (IgnoredFields, error) {
+	fields := make(IgnoredFields)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, path, ok := strings.Cut(entry, ":")
+		if !ok || kind == "" || path == "" {
+			return nil, fmt.Errorf("invalid ignored field %q, expected kind:path", entry)
+		}
+		fields[kind] = append(fields[kind], path)
+	}
+	return fields, nil
+}
+
+// String renders f in the format ParseIgnoredFields accepts.
+func (f IgnoredFields) String() string {
+	var entries []string
+	for kind, paths := range f {
+		for _, path := range paths {
+			entries = append(entries, kind+":"+path)
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// Significant reports whether the update of a kind object from old to new
+// changes anything besides the ignored fields.
+func (f IgnoredFields) Significant(kind string, old, new runtime.Object) bool {
+	paths := append(append([]string(nil), f[AllKinds]...), f[kind]...)
+	if len(paths) == 0 {
+		return true
+	}
+	oldMap, err := toMap(old)
+	if err != nil {
+		return true
+	}
+	newMap, err := toMap(new)
+	if err != nil {
+		return true
+	}
+	for _, path := range paths {
+		removeField(oldMap, strings.Split(path, "."))
+		removeField(newMap, strings.Split(path, "."))
+	}
+	return !reflect.DeepEqual(oldMap, newMap)
+}
+
+// toMap converts obj to a map the caller may modify: unstructured objects
+// are copied, as the converter returns their own content.
+func toMap(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return runtime.DeepCopyJSON(u.UnstructuredContent()), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// removeField deletes path from obj, descending into every element of the
+// lists marked with "[]".
+func removeField(obj map[string]interface{}, path []string) {
+	name, list := strings.CutSuffix(path[0], "[]")
+	if len(path) == 1 {
+		delete(obj, name)
+		return
+	}
+	if !list {
+		if child, ok := obj[name].(map[string]interface{}); ok {
+			removeField(child, path[1:])
+		}
+		return
+	}
+	items, _ := obj[name].([]interface{})
+	for _, item := range items {
+		if child, ok := item.(map[string]interface{}); ok {
+			removeField(child, path[1:])
+		}
+	}
+}
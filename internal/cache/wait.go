@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/types"
+)
+
+// changes returns a channel that is closed by the next change to the cache.
+// Unlike Changed, which has a single consumer (the runner), any number of
+// callers can wait on it.
+func (c *ResourceCache) changes() <-chan struct{} {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	if c.waitCh == nil {
+		c.waitCh = make(chan struct{})
+	}
+	return c.waitCh
+}
+
+// wake closes the channel of the current waiters.
+func (c *ResourceCache) wake() {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	if c.waitCh != nil {
+		close(c.waitCh)
+		c.waitCh = nil
+	}
+}
+
+// waitUntil returns once cond holds, checking it after every change, or
+// ctx's error once ctx is done.
+func (c *ResourceCache) waitUntil(ctx context.Context, cond func() bool) error {
+	for {
+		// subscribe before checking, so a change in between isn't missed
+		changed := c.changes()
+		if cond() {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WaitForKey returns once an object is cached under key, or ctx's error
+// once ctx is done.
+func (c *ResourceCache) WaitForKey(ctx context.Context, key types.EntityKey) error {
+	return c.waitUntil(ctx, func() bool {
+		_, found := c.Get(key)
+		return found
+	})
+}
+
+// WaitForAbsent returns once no object is cached under key, or ctx's error
+// once ctx is done. A lingering deleted object is still cached.
+func (c *ResourceCache) WaitForAbsent(ctx context.Context, key types.EntityKey) error {
+	return c.waitUntil(ctx, func() bool {
+		_, found := c.Get(key)
+		return !found
+	})
+}
+
+// Quiesce returns once the cache has gone quietPeriod without a change, or
+// ctx's error once ctx is done. Changes that are stored without being
+// signalled, see IgnoredFields, don't count.
+func (c *ResourceCache) Quiesce(ctx context.Context, quietPeriod time.Duration) error {
+	timer := time.NewTimer(quietPeriod)
+	defer timer.Stop()
+	for {
+		select {
+		case <-c.changes():
+			timer.Reset(quietPeriod)
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
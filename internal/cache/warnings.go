@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// WarningWindow is the window recent warnings are counted over.
+const WarningWindow = 15 * time.Minute
+
+// warningBucket is the resolution of the warning counts: occurrences are
+// counted per minute, so an object costs the same whatever its event rate.
+const warningBucket = time.Minute
+
+// warningBuckets is the number of buckets covering WarningWindow.
+const warningBuckets = int(WarningWindow / warningBucket)
+
+// MaxWarningObjects bounds the number of objects warnings are kept for. Past
+// it, the object warned about least recently is dropped.
+const MaxWarningObjects = 10000
+
+// MaxWarningMessage is the length, in bytes, warning messages are truncated
+// to.
+const MaxWarningMessage = 256
+
+// WarningSummary summarizes the recent Warning events of one object.
+type WarningSummary struct {
+	Count       int // occurrences within WarningWindow
+	LastReason  string
+	LastMessage string // truncated to MaxWarningMessage
+	LastTime    time.Time
+}
+
+// warningRecord holds the per-minute warning counts of one object.
+type warningRecord struct {
+	uid     k8stypes.UID
+	buckets [warningBuckets]struct {
+		minute int64
+		count  int
+	}
+	last WarningSummary
+}
+
+// warnings aggregates Warning events by the UID of their involved object.
+// Records are kept most recently warned about first and dropped once their
+// last warning falls out of WarningWindow.
+type warnings struct {
+	mu    sync.Mutex
+	max   int
+	byUID map[k8stypes.UID]*list.Element
+	lru   *list.List // of *warningRecord
+}
+
+func newWarnings(max int) *warnings {
+	return &warnings{max: max, byUID: make(map[k8stypes.UID]*list.Element), lru: list.New()}
+}
+
+// observe records the occurrences of ev since old, its previous version if
+// it was seen before. It returns whether anything was recorded: events that
+// aren't warnings, have no involved object UID or happened before the window
+// are ignored. Occurrences are attributed to the event's last timestamp.
+func (w *warnings) observe(old, ev *corev1.Event, now time.Time) bool {
+	uid := ev.InvolvedObject.UID
+	at := eventTime(ev)
+	if ev.Type != corev1.EventTypeWarning || uid == "" || at.Before(now.Add(-WarningWindow)) {
+		return false
+	}
+	n := int(max(ev.Count, 1))
+	if old != nil {
+		if old.ResourceVersion == ev.ResourceVersion {
+			return false
+		}
+		n = max(int(ev.Count-old.Count), 1)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var rec *warningRecord
+	if el, ok := w.byUID[uid]; ok {
+		w.lru.MoveToFront(el)
+		rec = el.Value.(*warningRecord)
+	} else {
+		rec = &warningRecord{uid: uid}
+		w.byUID[uid] = w.lru.PushFront(rec)
+		if w.lru.Len() > w.max {
+			oldest := w.lru.Remove(w.lru.Back()).(*warningRecord)
+			delete(w.byUID, oldest.uid)
+		}
+	}
+
+	minute := at.Unix() / int64(warningBucket/time.Second)
+	b := &rec.buckets[minute%int64(warningBuckets)]
+	switch {
+	case b.minute == minute:
+		b.count += n
+	case b.minute < minute:
+		b.minute, b.count = minute, n
+	}
+	if !at.Before(rec.last.LastTime) {
+		rec.last.LastReason = ev.Reason
+		rec.last.LastMessage = truncateMessage(ev.Message)
+		rec.last.LastTime = at
+	}
+	return true
+}
+
+// summaries returns the summary of every object warned about within the
+// window as of now, dropping the records that expired.
+func (w *warnings) summaries(now time.Time) map[k8stypes.UID]WarningSummary {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lru.Len() == 0 {
+		return nil
+	}
+	nowMinute := now.Unix() / int64(warningBucket/time.Second)
+	out := make(map[k8stypes.UID]WarningSummary, w.lru.Len())
+	for el := w.lru.Front(); el != nil; {
+		next := el.Next()
+		rec := el.Value.(*warningRecord)
+		summary := rec.last
+		summary.Count = 0
+		for _, b := range rec.buckets {
+			if nowMinute-b.minute < int64(warningBuckets) {
+				summary.Count += b.count
+			}
+		}
+		if summary.Count == 0 {
+			w.lru.Remove(el)
+			delete(w.byUID, rec.uid)
+		} else {
+			out[rec.uid] = summary
+		}
+		el = next
+	}
+	return out
+}
+
+// eventTime returns when ev last occurred.
+func eventTime(ev *corev1.Event) time.Time {
+	switch {
+	case ev.Series != nil && !ev.Series.LastObservedTime.IsZero():
+		return ev.Series.LastObservedTime.Time
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	default:
+		return ev.CreationTimestamp.Time
+	}
+}
+
+// truncateMessage cuts message to MaxWarningMessage bytes, on a rune
+// boundary, marking the cut with an ellipsis.
+func truncateMessage(message string) string {
+	if len(message) <= MaxWarningMessage {
+		return message
+	}
+	cut := MaxWarningMessage - len("…")
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + "…"
+}
@@ -0,0 +1,196 @@
+package churn
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultWindow is the sliding window rates are computed over.
+	DefaultWindow = 5 * time.Minute
+	// buckets is the number of slices a window is divided into.
+	buckets = 10
+	// summaryTop is the number of kind/type pairs in the summary line.
+	summaryTop = 10
+)
+
+// Event types tracked besides the informer's add, update and delete.
+const (
+	// Noop is an event that left the cache unchanged (same resourceVersion).
+	Noop = "noop"
+)
+
+// Default is the tracker fed by the cache's event handlers.
+var Default = New(DefaultWindow)
+
+// Key identifies one series of events.
+type Key struct {
+	Kind string
+	Type string
+}
+
+// Rate is the event rate of one series over the window.
+type Rate struct {
+	Key
+	Count     uint64  // events in the window
+	PerSecond float64 // Count divided by the window
+}
+
+// Tracker counts events per kind and type over a sliding window. Observe is
+// cheap enough to call from the informer handlers.
+type Tracker struct {
+	// Logger receives the periodic summary; nil means the standard logger.
+	Logger *log.Logger
+
+	mu      sync.Mutex
+	window  time.Duration
+	buckets []map[Key]uint64 // ring; current is the one being filled
+	current int
+	filled  int // buckets that cover elapsed time, up to len(buckets)
+}
+
+// creates a tracker over window.
+func New(window time.Duration) *Tracker {
+	t := &Tracker{}
+	t.Configure(window)
+	return t
+}
+
+// Configure sets the window and discards the counts so far.
+func (t *Tracker) Configure(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.window = window
+	t.buckets = make([]map[Key]uint64, buckets)
+	for i := range t.buckets {
+		t.buckets[i] = make(map[Key]uint64)
+	}
+	t.current, t.filled = 0, 1
+}
+
+// Observe counts one event of kind and type.
+func (t *Tracker) Observe(kind, typ string) {
+	t.mu.Lock()
+	t.buckets[t.current][Key{Kind: kind, Type: typ}]++
+	t.mu.Unlock()
+}
+
+// Rates returns the rate of every series seen in the window, busiest first.
+// While the first window is still filling, rates are computed over the
+// buckets started so far.
+func (t *Tracker) Rates() []Rate {
+	t.mu.Lock()
+	counts := make(map[Key]uint64)
+	for _, b := range t.buckets {
+		for k, n := range b {
+			counts[k] += n
+		}
+	}
+	span := t.window * time.Duration(t.filled) / time.Duration(len(t.buckets))
+	t.mu.Unlock()
+
+	rates := make([]Rate, 0, len(counts))
+	for k, n := range counts {
+		r := Rate{Key: k, Count: n}
+		if span > 0 {
+			r.PerSecond = float64(n) / span.Seconds()
+		}
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Count != rates[j].Count {
+			return rates[i].Count > rates[j].Count
+		}
+		if rates[i].Kind != rates[j].Kind {
+			return rates[i].Kind < rates[j].Kind
+		}
+		return rates[i].Type < rates[j].Type
+	})
+	return rates
+}
+
+// Run slides the window and logs a summary once per window until ctx is
+// cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	t.mu.Lock()
+	window := t.window
+	t.mu.Unlock()
+	if window <= 0 {
+		return
+	}
+	ticker := time.NewTicker(window / buckets)
+	defer ticker.Stop()
+	for ticks := 1; ; ticks++ {
+		select {
+		case <-ticker.C:
+			if ticks%buckets == 0 {
+				t.logSummary(window)
+			}
+			t.advance()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// advance starts a new bucket, dropping the oldest one.
+func (t *Tracker) advance() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = (t.current + 1) % len(t.buckets)
+	t.buckets[t.current] = make(map[Key]uint64)
+	if t.filled < len(t.buckets) {
+		t.filled++
+	}
+}
+
+// logSummary logs the busiest series, e.g. "ConfigMap 8.4k updates".
+func (t *Tracker) logSummary(window time.Duration) {
+	rates := t.Rates()
+	if len(rates) == 0 {
+		return
+	}
+	var total uint64
+	for _, r := range rates {
+		total += r.Count
+	}
+	parts := make([]string, 0, summaryTop)
+	for i, r := range rates {
+		if i == summaryTop {
+			parts = append(parts, fmt.Sprintf("%d more", len(rates)-summaryTop))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", r.Kind, formatCount(r.Count), plural(r.Type)))
+	}
+	logger := t.Logger
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	logger.WithField("events", total).Infof("Informer events, last %s: %s", window, strings.Join(parts, ", "))
+}
+
+// plural names events of typ, e.g. "updates", "no-op updates".
+func plural(typ string) string {
+	if typ == Noop {
+		return "no-op updates"
+	}
+	return typ + "s"
+}
+
+// formatCount abbreviates large counts, e.g. 8400 as "8.4k".
+func formatCount(n uint64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
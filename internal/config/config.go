@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/rules"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the optional file-based configuration (--config).
+type Config struct {
+	// Clusters lists the clusters to watch. Empty means a single cluster from
+	// the default kubeconfig.
+	Clusters []ClusterConfig `json:"clusters,omitempty"`
+	// AnnotationProperties promotes annotations, by exact key or prefix, to
+	// node properties.
+	AnnotationProperties []graph.AnnotationMapping `json:"annotationProperties,omitempty"`
+	// RelationshipRules derive relationships from object fields.
+	RelationshipRules []graph.RelationshipRule `json:"relationshipRules,omitempty"`
+	// Rules run actions on matching changes between graph builds.
+	Rules []rules.Rule `json:"rules,omitempty"`
+}
+
+// ClusterConfig selects one cluster by kubeconfig path and/or context.
+type ClusterConfig struct {
+	// Name identifies the cluster in graph keys. Defaults to the context name,
+	// then the kubeconfig file name.
+	Name       string `json:"name,omitempty"`
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// Load reads and validates the YAML (or JSON) config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate defaults cluster names and checks they are usable and unique,
+// and checks the annotation mappings and the relationship and change rules.
+func (c *Config) Validate() error {
+	if _, err := graph.NewAnnotationProperties(c.AnnotationProperties); err != nil {
+		return fmt.Errorf("annotationProperties: %w", err)
+	}
+	if _, err := graph.NewRelationshipRules(c.RelationshipRules); err != nil {
+		return fmt.Errorf("relationshipRules: %w", err)
+	}
+	if err := rules.Validate(c.Rules); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(c.Clusters))
+	for i := range c.Clusters {
+		cl := &c.Clusters[i]
+		if cl.Name == "" {
+			switch {
+			case cl.Context != "":
+				cl.Name = cl.Context
+			case cl.Kubeconfig != "":
+				cl.Name = strings.TrimSuffix(filepath.Base(cl.Kubeconfig), filepath.Ext(cl.Kubeconfig))
+			default:
+				return fmt.Errorf("clusters[%d]: one of name, context or kubeconfig is required", i)
+			}
+		}
+		if seen[cl.Name] {
+			return fmt.Errorf("clusters[%d]: duplicate cluster name %q", i, cl.Name)
+		}
+		seen[cl.Name] = true
+	}
+	return nil
+}
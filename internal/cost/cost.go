@@ -0,0 +1,80 @@
+// Package cost stamps the hourly cost of each Node's instance type, from a
+// user-supplied mapping file, onto the graph.
+package cost
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Table maps instance types to their hourly cost. It is read from a YAML (or
+// JSON) file of instance-type: cost pairs, e.g.
+//
+//	m5.large: 0.096
+//	n2-standard-4: 0.194
+//
+// and can be reloaded while graphs are being built.
+type Table struct {
+	path  string
+	costs atomic.Pointer[map[string]float64]
+}
+
+// Load reads the table at path.
+func Load(path string) (*Table, error) {
+	t := &Table{path: path}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload re-reads the file. On error the previous costs are kept.
+func (t *Table) Reload() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return fmt.Errorf("failed to read cost file %s: %w", t.path, err)
+	}
+	var costs map[string]float64
+	if err := yaml.UnmarshalStrict(data, &costs); err != nil {
+		return fmt.Errorf("failed to parse cost file %s: %w", t.path, err)
+	}
+	for instanceType, hourly := range costs {
+		if hourly < 0 {
+			return fmt.Errorf("invalid cost file %s: negative cost %v for %s", t.path, hourly, instanceType)
+		}
+	}
+	t.costs.Store(&costs)
+	return nil
+}
+
+// Len returns the number of instance types with a cost.
+func (t *Table) Len() int {
+	return len(*t.costs.Load())
+}
+
+// Hourly returns the hourly cost of instanceType.
+func (t *Table) Hourly(instanceType string) (float64, bool) {
+	hourly, ok := (*t.costs.Load())[instanceType]
+	return hourly, ok
+}
+
+// Stamp sets the cost.hourly property of every Node whose instance type has
+// a cost. Nodes without one are left without the property.
+func (t *Table) Stamp(g *graph.Graph) {
+	costs := *t.costs.Load()
+	for i := range g.Nodes {
+		node := &g.Nodes[i]
+		if node.Key.Kind != "Node" {
+			continue
+		}
+		if hourly, ok := costs[node.Properties[graph.InstanceTypeProperty]]; ok {
+			node.Properties[graph.HourlyCostProperty] = strconv.FormatFloat(hourly, 'f', -1, 64)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WriteStep is a step of an atomic file write.
+type WriteStep string
+
+const (
+	StepCreate  WriteStep = "create temporary file"
+	StepWrite   WriteStep = "write temporary file"
+	StepSync    WriteStep = "sync temporary file"
+	StepClose   WriteStep = "close temporary file"
+	StepRename  WriteStep = "rename temporary file into place"
+	StepInPlace WriteStep = "write in place"
+	StepSyncDir WriteStep = "sync directory"
+)
+
+// WriteError is the failure of one step of an atomic write of Path.
+type WriteError struct {
+	Step WriteStep
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("%s: failed to %s: %v", e.Path, e.Step, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// crossDeviceWarned limits the in-place write warning to once per process.
+var crossDeviceWarned atomic.Bool
+
+// writeFileAtomic writes data to a temporary file in the destination
+// directory and renames it over path once it is fully synced, then syncs the
+// directory so the rename itself is durable. Every file-writing sink goes
+// through here. If the rename crosses filesystems, as when path is itself a
+// mount, data is written over path in place instead, which is not atomic.
+// Errors are *WriteError.
+func writeFileAtomic(ctx context.Context, path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), tempPattern)
+	if err != nil {
+		return &WriteError{Step: StepCreate, Path: path, Err: err}
+	}
+	defer func() {
+		if tempFile != nil {
+			_ = tempFile.Close()
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	if _, err := tempFile.Write(data); err != nil {
+		return &WriteError{Step: StepWrite, Path: path, Err: err}
+	}
+	if err := tempFile.Sync(); err != nil {
+		return &WriteError{Step: StepSync, Path: path, Err: err}
+	}
+	if err := tempFile.Close(); err != nil {
+		return &WriteError{Step: StepClose, Path: path, Err: err}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("emit cancelled before rename: %w", err)
+	}
+
+	err = replaceFile(tempFile.Name(), path)
+	if isCrossDevice(err) {
+		if crossDeviceWarned.CompareAndSwap(false, true) {
+			log.WithField("file", path).Warn("Output file is on a different filesystem than its directory, writing it in place; readers may see partial writes")
+		}
+		if err := writeInPlace(path, data); err != nil {
+			return &WriteError{Step: StepInPlace, Path: path, Err: err}
+		}
+		// the deferred cleanup removes the temporary file
+		return nil
+	}
+	if err != nil {
+		return &WriteError{Step: StepRename, Path: path, Err: err}
+	}
+	tempFile = nil
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return &WriteError{Step: StepSyncDir, Path: path, Err: err}
+	}
+	return nil
+}
+
+// writeInPlace overwrites path with data and syncs it.
+func writeInPlace(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,92 @@
+package emitter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInsufficientSpace is returned (wrapped) when a write is skipped because
+// the output filesystem doesn't have room for it.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// SpaceGuard refuses writes that would not fit on the output filesystem,
+// so a full volume produces one clear error per skipped emit instead of
+// half-written temp files.
+type SpaceGuard struct {
+	// Slack is the free space, in bytes, that must remain after the write.
+	Slack uint64
+	// OnChange is called when the guard enters or leaves the degraded state.
+	OnChange func(degraded bool, reason string)
+	// available overrides the platform free-space lookup (tests).
+	available func(dir string) (uint64, bool, error)
+
+	mu       sync.Mutex
+	degraded bool
+	skipped  atomic.Uint64
+}
+
+// Skipped returns the number of writes skipped for lack of space.
+func (g *SpaceGuard) Skipped() uint64 {
+	return g.skipped.Load()
+}
+
+// SetAvailableFunc replaces the free-space lookup, e.g. to simulate a full disk.
+func (g *SpaceGuard) SetAvailableFunc(f func(dir string) (uint64, bool, error)) {
+	g.available = f
+}
+
+// ensure checks that need bytes fit in dir, running cleanup once if they
+// don't. It returns an error wrapping ErrInsufficientSpace if the write
+// must be skipped.
+func (g *SpaceGuard) ensure(dir string, need uint64, cleanup func() error) error {
+	avail, ok, err := g.lookup(dir)
+	if err != nil || !ok {
+		if err != nil {
+			log.WithField("dir", dir).WithError(err).Warn("Could not determine free disk space, writing anyway")
+		}
+		return nil
+	}
+
+	if avail < need+g.Slack && cleanup != nil {
+		log.WithFields(log.Fields{"dir": dir, "available": avail, "needed": need + g.Slack}).
+			Warn("Low disk space, running retention cleanup early")
+		if err := cleanup(); err != nil {
+			log.WithField("dir", dir).WithError(err).Warn("Early retention cleanup failed")
+		}
+		if avail, _, err = g.lookup(dir); err != nil {
+			avail = 0
+		}
+	}
+
+	if avail < need+g.Slack {
+		g.skipped.Add(1)
+		reason := fmt.Sprintf("%s: %d bytes available, %d needed", dir, avail, need+g.Slack)
+		g.setDegraded(true, reason)
+		return fmt.Errorf("%w: %s", ErrInsufficientSpace, reason)
+	}
+	g.setDegraded(false, "")
+	return nil
+}
+
+func (g *SpaceGuard) lookup(dir string) (uint64, bool, error) {
+	if g.available != nil {
+		return g.available(dir)
+	}
+	return availableBytes(dir)
+}
+
+// setDegraded records the state and reports transitions to OnChange.
+func (g *SpaceGuard) setDegraded(degraded bool, reason string) {
+	g.mu.Lock()
+	changed := g.degraded != degraded
+	g.degraded = degraded
+	g.mu.Unlock()
+
+	if changed && g.OnChange != nil {
+		g.OnChange(degraded, reason)
+	}
+}
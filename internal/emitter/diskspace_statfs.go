@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package emitter
+
+import "syscall"
+
+// availableBytes returns the space available to unprivileged users on the
+// filesystem holding dir. ok is false if it can't be determined.
+func availableBytes(dir string) (avail uint64, ok bool, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true, nil
+}
@@ -1,63 +1,202 @@
 package emitter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
-	"satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/timing"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// StdoutTarget is the output directory value that selects writing graphs to
+// stdout instead of to files.
+const StdoutTarget = "-"
+
+// LatestFilename is the file FileSink.WriteLatest keeps pointing at the newest graph.
+const LatestFilename = "latest.json"
+
 // marshals the graph to JSON and writes it atomically to a timestamped file
-// in the specified output directory.
-func EmitGraph(g graph.Graph, outputDir string) error {
-	err := os.MkdirAll(outputDir, 0755)
+// in the specified output directory, or to stdout if outputDir is StdoutTarget.
+// The write is abandoned (and the temporary file removed) if ctx is cancelled
+// before the final rename.
+func EmitGraph(ctx context.Context, g graph.Graph, outputDir string) error {
+	if outputDir == StdoutTarget {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("emit cancelled: %w", err)
+		}
+		return emitToStdout(g)
+	}
+	return FileSink{Dir: outputDir}.Emit(ctx, g)
+}
+
+// FileSink writes graphs to timestamped files in a directory.
+type FileSink struct {
+	Dir string
+	// Retain is the number of graph files kept in Dir; 0 keeps everything.
+	Retain int
+	// WriteLatest also (atomically) replaces Dir/latest.json with each graph.
+	WriteLatest bool
+	// Guard, if set, skips writes that don't fit on the output filesystem.
+	Guard *SpaceGuard
+	// Revisions, if set, records every graph file written.
+	Revisions *RevisionLog
+	// NameByBuiltAt names files by the graph's metadata.builtAt instead of
+	// the write time, so replayed graphs are named by the log time they show.
+	NameByBuiltAt bool
+}
+
+// Emit writes g to a new timestamped file with its checksum and done
+// marker, then updates latest.json and applies retention.
+func (s FileSink) Emit(ctx context.Context, g graph.Graph) (err error) {
+	ctx, span := tracer.Start(ctx, "emitter.FileSink.Emit", graphAttributes(g), trace.WithAttributes(attribute.String("satellite.dir", s.Dir)))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("emit cancelled: %w", err)
+	}
+
+	err = os.MkdirAll(s.Dir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+		return fmt.Errorf("failed to create output directory %s: %w", s.Dir, err)
 	}
 
+	marshalStart := time.Now()
 	jsonData, err := json.MarshalIndent(g, "", "  ") // Use MarshalIndent for readability
 	if err != nil {
 		return fmt.Errorf("failed to marshal graph to JSON: %w", err)
 	}
+	timing.Default.Observe(timing.EmitMarshal, time.Since(marshalStart))
 
-	tempFile, err := os.CreateTemp(outputDir, "graph-*.json.tmp")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
+	if s.Guard != nil {
+		need := uint64(len(jsonData))
+		if s.WriteLatest {
+			need *= 2
+		}
+		var cleanup func() error
+		if s.Retain > 1 {
+			// make room for the file about to be written, but never delete
+			// the last good graph before its replacement is on disk
+			cleanup = func() error { return Prune(s.Dir, s.Retain-1) }
+		}
+		if err := s.Guard.ensure(s.Dir, need, cleanup); err != nil {
+			return fmt.Errorf("skipped graph revision %d: %w", g.GraphRevision, err)
+		}
+	}
+
+	writeStart := time.Now()
+	nameTime := writeStart
+	if s.NameByBuiltAt && g.Metadata != nil && !g.Metadata.BuiltAt.IsZero() {
+		nameTime = g.Metadata.BuiltAt
+	}
+	finalFilename := filepath.Join(s.Dir, graphFilename(g, nameTime))
+	if err := writeGraphSet(ctx, finalFilename, jsonData); err != nil {
+		return err
+	}
+	timing.Default.Observe(timing.EmitWrite, time.Since(writeStart))
+	log.WithFields(log.Fields{
+		"revision": g.GraphRevision,
+		"file":     finalFilename,
+	}).Info("Successfully emitted graph")
+	s.Revisions.Record(g, finalFilename)
+	span.SetAttributes(attribute.String("satellite.file", finalFilename))
+
+	if s.WriteLatest {
+		if err := writeFileAtomic(ctx, filepath.Join(s.Dir, LatestFilename), jsonData); err != nil {
+			return err
+		}
 	}
-	defer func() {
-		if tempFile != nil {
-			_ = tempFile.Close()
-			_ = os.Remove(tempFile.Name())
+	if s.Retain > 0 {
+		if err := Prune(s.Dir, s.Retain); err != nil {
+			return err
 		}
-	}()
+	}
+	return nil
+}
 
-	_, err = tempFile.Write(jsonData)
+// ListGraphFiles returns the timestamped graph files in dir, oldest first.
+func ListGraphFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "graph-*.json"))
 	if err != nil {
-		return fmt.Errorf("failed to write to temporary file %s: %w", tempFile.Name(), err)
+		return nil, fmt.Errorf("failed to list graph files in %s: %w", dir, err)
 	}
-	err = tempFile.Sync()
-	if err != nil {
-		return fmt.Errorf("failed to sync temporary file %s: %w", tempFile.Name(), err)
+	sort.Slice(matches, func(i, j int) bool {
+		return fileTimestamp(matches[i]) < fileTimestamp(matches[j])
+	})
+	return matches, nil
+}
+
+// fileTimestamp returns the fixed-width "<date>-<time>.json" suffix of a graph
+// file name, which sorts chronologically regardless of the cluster prefix.
+func fileTimestamp(path string) string {
+	const suffixLen = len("20060102-150405.json")
+	base := filepath.Base(path)
+	if len(base) < suffixLen {
+		return base
 	}
-	err = tempFile.Close()
+	return base[len(base)-suffixLen:]
+}
+
+// Prune deletes all but the newest keep graph files in dir, each with its
+// checksum and done marker.
+func Prune(dir string, keep int) error {
+	files, err := ListGraphFiles(dir)
 	if err != nil {
-		return fmt.Errorf("failed to close temporary file %s: %w", tempFile.Name(), err)
+		return err
+	}
+	if len(files) <= keep {
+		return nil
+	}
+	for _, f := range files[:len(files)-keep] {
+		if err := removeGraphSet(f); err != nil {
+			return err
+		}
+		log.WithField("file", f).Debug("Retention: removed old graph file")
 	}
+	return nil
+}
+
+// graphFilename returns graph-<ts>.json, or graph-<cluster>-<ts>.json when the
+// graph carries a cluster name.
+func graphFilename(g graph.Graph, now time.Time) string {
+	timestamp := now.Format("20060102-150405")
+	if g.Metadata != nil && g.Metadata.ClusterName != "" {
+		return fmt.Sprintf("graph-%s-%s.json", sanitizeFilenamePart(g.Metadata.ClusterName), timestamp)
+	}
+	return fmt.Sprintf("graph-%s.json", timestamp)
+}
 
-	timestamp := time.Now().Format("20060102-150405")
-	finalFilename := filepath.Join(outputDir, fmt.Sprintf("graph-%s.json", timestamp))
+// sanitizeFilenamePart replaces every character outside [A-Za-z0-9._-] with '-'.
+func sanitizeFilenamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, s)
+}
 
-	err = os.Rename(tempFile.Name(), finalFilename)
+// writes the graph as a single JSON document followed by a newline to stdout.
+func emitToStdout(g graph.Graph) error {
+	jsonData, err := json.Marshal(g)
 	if err != nil {
-		return fmt.Errorf("failed to rename temporary file %s to %s: %w", tempFile.Name(), finalFilename, err)
+		return fmt.Errorf("failed to marshal graph to JSON: %w", err)
 	}
-
-	tempFile = nil
-	log.Infof("Successfully emitted graph revision %d to %s", g.GraphRevision, finalFilename)
+	jsonData = append(jsonData, '\n')
+	if _, err := os.Stdout.Write(jsonData); err != nil {
+		return fmt.Errorf("failed to write graph to stdout: %w", err)
+	}
+	log.WithField("revision", g.GraphRevision).Info("Successfully emitted graph to stdout")
 	return nil
 }
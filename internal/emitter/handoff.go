@@ -0,0 +1,137 @@
+package emitter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Graph files are handed off to consumers in sets: graph-<ts>.json, then
+// its checksum graph-<ts>.json.sha256 (in sha256sum format), then the
+// zero-byte marker graph-<ts>.done last. Retention deletes a set in the
+// reverse order. A graph file with a marker is therefore complete and
+// stays so until it is deleted; consumers should read only those, e.g.
+// with OpenLatestComplete.
+const (
+	ChecksumSuffix = ".sha256"
+	DoneSuffix     = ".done"
+)
+
+// openAttempts bounds how often OpenLatestComplete retries when the
+// directory changed while it was read: a set rewritten by two emits within
+// a second, or every listed set deleted by retention.
+const openAttempts = 5
+
+// ErrNoCompleteGraph is returned by OpenLatestComplete if no graph file
+// has a done marker.
+var ErrNoCompleteGraph = errors.New("no complete graph file")
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumPath returns the path of the checksum of the graph file at path.
+func ChecksumPath(path string) string {
+	return path + ChecksumSuffix
+}
+
+// DonePath returns the path of the done marker of the graph file at path.
+func DonePath(path string) string {
+	return strings.TrimSuffix(path, ".json") + DoneSuffix
+}
+
+// writeGraphSet writes data to the graph file at path, then its checksum,
+// then its done marker.
+func writeGraphSet(ctx context.Context, path string, data []byte) error {
+	// a set rewritten within the same second must not look complete halfway
+	if err := os.Remove(DonePath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove done marker %s: %w", DonePath(path), err)
+	}
+	if err := writeFileAtomic(ctx, path, data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:]) + "  " + filepath.Base(path) + "\n"
+	if err := writeFileAtomic(ctx, ChecksumPath(path), []byte(checksum)); err != nil {
+		return err
+	}
+	return writeFileAtomic(ctx, DonePath(path), nil)
+}
+
+// removeGraphSet deletes the graph file at path with its checksum and done
+// marker, marker first.
+func removeGraphSet(path string) error {
+	for _, p := range []string{DonePath(path), ChecksumPath(path), path} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old graph file %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// OpenLatestComplete opens the newest graph file in dir that has a done
+// marker, after checking it against its checksum. Consumers of the output
+// directory should read graphs through it, or follow the same protocol:
+// the open file stays complete even if retention deletes it meanwhile. It
+// returns an error wrapping ErrNoCompleteGraph if there is none.
+func OpenLatestComplete(dir string) (*os.File, error) {
+	var err error
+	for range openAttempts {
+		var f *os.File
+		f, err = openLatestComplete(dir)
+		if !errors.Is(err, errChecksumMismatch) && !errors.Is(err, ErrNoCompleteGraph) {
+			return f, err
+		}
+	}
+	return nil, err
+}
+
+func openLatestComplete(dir string) (*os.File, error) {
+	files, err := ListGraphFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		f, err := openComplete(files[i])
+		if errors.Is(err, os.ErrNotExist) {
+			// not complete yet, or deleted by retention meanwhile
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("%w in %s", ErrNoCompleteGraph, dir)
+}
+
+// openComplete opens the graph file at path if its set is complete.
+func openComplete(path string) (*os.File, error) {
+	if _, err := os.Stat(DonePath(path)); err != nil {
+		return nil, err
+	}
+	checksum, err := os.ReadFile(ChecksumPath(path))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read graph file %s: %w", path, err)
+	}
+	want, _, _ := strings.Cut(string(checksum), " ")
+	if want != hex.EncodeToString(h.Sum(nil)) {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", errChecksumMismatch, path)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read graph file %s: %w", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,82 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TombstoneFilename marks a namespace directory whose namespace disappeared.
+const TombstoneFilename = "TOMBSTONE"
+
+// NamespaceSink writes one subgraph per namespace to <Base.Dir>/<namespace>/,
+// each including the cluster-scoped nodes its relationships point at
+// (marked external). Retention and latest.json apply per directory.
+type NamespaceSink struct {
+	Base FileSink
+	// Tombstones writes a TOMBSTONE marker into the directory of a namespace
+	// that no longer appears in the graph.
+	Tombstones bool
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+// Emit writes the per-namespace subgraphs of g. Namespaces that disappeared
+// since the previous emit stop receiving files.
+func (s *NamespaceSink) Emit(ctx context.Context, g graph.Graph) (err error) {
+	ctx, span := tracer.Start(ctx, "emitter.NamespaceSink.Emit", graphAttributes(g))
+	defer func() { endSpan(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := make(map[string]bool)
+	var errs []error
+	for _, ns := range graph.Namespaces(g) {
+		current[ns] = true
+		sub := graph.Subgraph(g, graph.Filter{Namespaces: []string{ns}, IncludeExternal: true})
+
+		sink := s.Base
+		sink.Dir = filepath.Join(s.Base.Dir, sanitizeFilenamePart(ns))
+		sink.Revisions = nil // revisions track the full graph only
+		if err := sink.Emit(ctx, sub); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", ns, err))
+			continue
+		}
+		if s.Tombstones && !s.known[ns] {
+			// the namespace may have come back after being tombstoned
+			if err := os.Remove(filepath.Join(sink.Dir, TombstoneFilename)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("namespace %s: failed to remove tombstone: %w", ns, err))
+			}
+		}
+	}
+
+	for ns := range s.known {
+		if current[ns] {
+			continue
+		}
+		log.WithField("namespace", ns).Info("Namespace disappeared, no longer emitting its graph")
+		if s.Tombstones {
+			dir := filepath.Join(s.Base.Dir, sanitizeFilenamePart(ns))
+			marker := "revision=" + strconv.FormatUint(g.GraphRevision, 10) + "\ntime=" + time.Now().UTC().Format(time.RFC3339) + "\n"
+			if err := writeFileAtomic(ctx, filepath.Join(dir, TombstoneFilename), []byte(marker)); err != nil {
+				errs = append(errs, fmt.Errorf("namespace %s tombstone: %w", ns, err))
+			}
+		}
+	}
+	s.known = current
+	span.SetAttributes(attribute.Int("satellite.namespaces", len(current)))
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,277 @@
+package emitter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/timing"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EmitFunc writes one graph to a sink.
+type EmitFunc func(ctx context.Context, g graph.Graph) error
+
+// Instrument wraps emit so its duration and outcome are recorded in the
+// emit metrics under the given sink name, and traced as an emitter.Emit span.
+func Instrument(sink string, emit EmitFunc) EmitFunc {
+	return func(ctx context.Context, g graph.Graph) error {
+		start := time.Now()
+		ctx, span := tracer.Start(ctx, "emitter.Emit", graphAttributes(g), trace.WithAttributes(attribute.String("satellite.sink", sink)))
+		err := emit(ctx, g)
+		endSpan(span, err)
+		metrics.ObserveEmit(sink, start, err)
+		timing.Default.Observe("emit."+sink, time.Since(start))
+		return err
+	}
+}
+
+// QueueStats are cumulative counters of a Queue.
+type QueueStats struct {
+	Submitted  uint64 // graphs handed to Submit
+	Emitted    uint64 // graphs successfully emitted
+	Failed     uint64 // emits that returned an error
+	Held       uint64 // emits delayed by the minimum interval
+	Merged     uint64 // pending graphs replaced by a newer one before being emitted
+	Heartbeats uint64 // heartbeat re-emits of an unchanged graph
+}
+
+// Queue decouples building from emitting. It holds at most one pending graph:
+// a newer submission replaces an older one that hasn't been written yet, so
+// the newest state always wins. Emits are spaced at least minInterval apart.
+type Queue struct {
+	// Heartbeat, if positive, re-emits the last graph once nothing has been
+	// emitted for that long, so an idle cluster still produces output. Set it
+	// before calling Run.
+	Heartbeat time.Duration
+
+	emit        EmitFunc
+	minInterval time.Duration
+	started     time.Time
+
+	mu       sync.Mutex
+	pending  *graph.Graph
+	now      bool         // the pending graph skips the minimum interval
+	last     *graph.Graph // last successfully emitted graph
+	lastEmit time.Time
+	wake     chan struct{}
+	emits    chan struct{}
+
+	submitted, emitted, failed, held, merged, heartbeats atomic.Uint64
+}
+
+// creates a queue emitting through emit, at most once per minInterval.
+func NewQueue(emit EmitFunc, minInterval time.Duration) *Queue {
+	return &Queue{
+		emit:        emit,
+		minInterval: minInterval,
+		started:     time.Now(),
+		wake:        make(chan struct{}, 1),
+		emits:       make(chan struct{}, 1),
+	}
+}
+
+// Submit enqueues g, replacing any graph still waiting to be emitted.
+func (q *Queue) Submit(g graph.Graph) {
+	q.submit(g, false)
+}
+
+// SubmitNow enqueues g like Submit but emits it without waiting for the
+// minimum interval, e.g. for an on-demand snapshot.
+func (q *Queue) SubmitNow(g graph.Graph) {
+	q.submit(g, true)
+}
+
+func (q *Queue) submit(g graph.Graph, now bool) {
+	q.submitted.Add(1)
+	q.mu.Lock()
+	if q.pending != nil {
+		q.merged.Add(1)
+		metrics.EmitQueueMerged.Inc()
+		log.WithFields(log.Fields{
+			"revision":         g.GraphRevision,
+			"replacedRevision": q.pending.GraphRevision,
+		}).Debug("Emit queue: replaced pending graph")
+	}
+	q.pending = &g
+	// a newer graph replacing an immediate one is emitted immediately too
+	q.now = q.now || now
+	metrics.EmitQueuePending.Set(1)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run emits submitted graphs until ctx is cancelled. A graph still pending at
+// that point is left for Flush.
+func (q *Queue) Run(ctx context.Context) {
+	var heartbeat *time.Timer
+	var heartbeatC <-chan time.Time
+	if q.Heartbeat > 0 {
+		heartbeat = time.NewTimer(q.untilHeartbeat())
+		heartbeatC = heartbeat.C
+		defer heartbeat.Stop()
+	}
+
+	for {
+		select {
+		case <-q.wake:
+		case <-heartbeatC:
+			if !q.submitHeartbeat() {
+				heartbeat.Reset(q.untilHeartbeat())
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		if wait := q.untilAllowed(); wait > 0 {
+			q.held.Add(1)
+			log.WithField("wait", wait).Debug("Emit queue: holding graph for minimum emit interval")
+			if !q.hold(ctx, wait) {
+				return
+			}
+		}
+
+		q.emitPending(ctx)
+		if heartbeat != nil {
+			heartbeat.Reset(q.untilHeartbeat())
+		}
+	}
+}
+
+// Emitted returns a channel signalled after every emit attempt. Signals
+// coalesce while nobody receives them.
+func (q *Queue) Emitted() <-chan struct{} {
+	return q.emits
+}
+
+// Flush synchronously emits the pending graph, if any, ignoring the minimum
+// interval. Intended for the final emit after Run has returned.
+func (q *Queue) Flush(ctx context.Context) error {
+	return q.emitPending(ctx)
+}
+
+// Stats returns a snapshot of the queue's counters.
+func (q *Queue) Stats() QueueStats {
+	return QueueStats{
+		Submitted:  q.submitted.Load(),
+		Emitted:    q.emitted.Load(),
+		Failed:     q.failed.Load(),
+		Held:       q.held.Load(),
+		Merged:     q.merged.Load(),
+		Heartbeats: q.heartbeats.Load(),
+	}
+}
+
+// hold waits out the minimum interval, ending early if a submission asks for
+// an immediate emit. It returns false if ctx was cancelled.
+func (q *Queue) hold(ctx context.Context, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-q.wake:
+			if q.untilAllowed() <= 0 {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// untilAllowed returns how long to wait before the next emit is allowed.
+func (q *Queue) untilAllowed() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.now || q.minInterval <= 0 || q.lastEmit.IsZero() {
+		return 0
+	}
+	return time.Until(q.lastEmit.Add(q.minInterval))
+}
+
+// untilHeartbeat returns how long until a heartbeat is due.
+func (q *Queue) untilHeartbeat() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.last == nil {
+		// nothing to repeat yet; check again after a full interval
+		return q.Heartbeat
+	}
+	return time.Until(q.lastEmit.Add(q.Heartbeat))
+}
+
+// submitHeartbeat queues a copy of the last emitted graph marked as a
+// heartbeat if nothing has been emitted or queued for a full interval. It
+// reports whether a heartbeat was queued.
+func (q *Queue) submitHeartbeat() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending != nil || q.last == nil || time.Since(q.lastEmit) < q.Heartbeat {
+		return false
+	}
+	hb := *q.last
+	meta := *hb.Metadata // emitted graphs always carry metadata
+	meta.Heartbeat = true
+	hb.Metadata = &meta
+	q.pending = &hb
+	q.heartbeats.Add(1)
+	metrics.EmitHeartbeats.Inc()
+	log.WithField("revision", hb.GraphRevision).Debug("Emit queue: nothing emitted for the heartbeat interval, re-emitting the last graph")
+	return true
+}
+
+// emitPending takes the newest pending graph and emits it, stamped with the
+// emit time.
+func (q *Queue) emitPending(ctx context.Context) error {
+	q.mu.Lock()
+	g := q.pending
+	q.pending = nil
+	q.now = false
+	metrics.EmitQueuePending.Set(0)
+	q.mu.Unlock()
+	if g == nil {
+		return nil
+	}
+
+	// the metadata may be shared with other readers of the built graph
+	var meta graph.GraphMetadata
+	if g.Metadata != nil {
+		meta = *g.Metadata
+	}
+	meta.EmittedAt = time.Now().UTC()
+	g.Metadata = &meta
+
+	err := q.emit(ctx, *g)
+
+	q.mu.Lock()
+	q.lastEmit = time.Now()
+	if err == nil {
+		q.last = g
+	}
+	q.mu.Unlock()
+	select {
+	case q.emits <- struct{}{}:
+	default:
+	}
+
+	if err != nil {
+		q.failed.Add(1)
+		log.WithField("revision", g.GraphRevision).WithError(err).Error("Error emitting graph")
+		return err
+	}
+	q.emitted.Add(1)
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package emitter
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// replaceFile renames from over to, replacing it atomically.
+func replaceFile(from, to string) error {
+	return os.Rename(from, to)
+}
+
+// isCrossDevice reports whether err is a rename across filesystems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package emitter
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorNotSameDevice    syscall.Errno = 17
+	errorSharingViolation syscall.Errno = 32
+
+	replaceAttempts = 10
+	replaceBackoff  = 20 * time.Millisecond
+)
+
+// replaceFile renames from over to. os.Rename replaces existing files on
+// Windows too, but fails while another process, such as a consumer or a
+// virus scanner, has to open without FILE_SHARE_DELETE; the rename is
+// retried with backoff, then tried once more after removing to.
+func replaceFile(from, to string) error {
+	var err error
+	for attempt := range replaceAttempts {
+		if err = os.Rename(from, to); err == nil || !isSharingError(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * replaceBackoff)
+	}
+	if rmErr := os.Remove(to); rmErr != nil && !os.IsNotExist(rmErr) {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func isSharingError(err error) bool {
+	return errors.Is(err, errorAccessDenied) || errors.Is(err, errorSharingViolation)
+}
+
+// isCrossDevice reports whether err is a rename across volumes.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
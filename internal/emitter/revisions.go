@@ -0,0 +1,86 @@
+package emitter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// RevisionRecord describes one emitted graph revision.
+type RevisionRecord struct {
+	Revision      uint64    `json:"revision"`
+	BuiltAt       time.Time `json:"builtAt,omitzero"`
+	Hash          string    `json:"hash"`
+	Nodes         int       `json:"nodes"`
+	Relationships int       `json:"relationships"`
+	// File is where the full graph was written; empty for stdout.
+	File string `json:"file,omitempty"`
+}
+
+// RevisionLog is a bounded, concurrency-safe index of the most recently
+// emitted revisions.
+type RevisionLog struct {
+	mu      sync.RWMutex
+	max     int
+	records []RevisionRecord // oldest first
+}
+
+// creates a log keeping the newest max revisions.
+func NewRevisionLog(max int) *RevisionLog {
+	return &RevisionLog{max: max}
+}
+
+// Record adds g, written to file, to the log, evicting the oldest entry when
+// full. Recording a revision again replaces its entry.
+func (l *RevisionLog) Record(g graph.Graph, file string) {
+	if l == nil || l.max <= 0 {
+		return
+	}
+	rec := RevisionRecord{
+		Revision:      g.GraphRevision,
+		Hash:          graph.ContentHash(g),
+		Nodes:         len(g.Nodes),
+		Relationships: len(g.Relationships),
+		File:          file,
+	}
+	if g.Metadata != nil {
+		rec.BuiltAt = g.Metadata.BuiltAt
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.records {
+		if l.records[i].Revision == rec.Revision {
+			l.records[i] = rec
+			return
+		}
+	}
+	if len(l.records) >= l.max {
+		l.records = append(l.records[:0], l.records[len(l.records)-l.max+1:]...)
+	}
+	l.records = append(l.records, rec)
+}
+
+// List returns the recorded revisions, newest first.
+func (l *RevisionLog) List() []RevisionRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]RevisionRecord, 0, len(l.records))
+	for i := len(l.records) - 1; i >= 0; i-- {
+		out = append(out, l.records[i])
+	}
+	return out
+}
+
+// Get returns the record of one revision.
+func (l *RevisionLog) Get(revision uint64) (RevisionRecord, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, rec := range l.records {
+		if rec.Revision == revision {
+			return rec, true
+		}
+	}
+	return RevisionRecord{}, false
+}
@@ -0,0 +1,129 @@
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StateFilename is the file StateSink keeps the last emitted revision in.
+const StateFilename = ".satellite-state.json"
+
+// State is the content of StateFilename.
+type State struct {
+	Revision  uint64    `json:"revision"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// StateSink records the revision of every emitted graph in Dir/StateFilename,
+// so a restarted process can continue numbering with RecoverRevision.
+type StateSink struct {
+	Dir string
+}
+
+// Emit atomically replaces the state file with g's revision.
+func (s StateSink) Emit(ctx context.Context, g graph.Graph) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", s.Dir, err)
+	}
+	data, err := json.Marshal(State{Revision: g.GraphRevision, UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := writeFileAtomic(ctx, filepath.Join(s.Dir, StateFilename), data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// ReadState reads the state file in dir. It returns an error satisfying
+// errors.Is(err, fs.ErrNotExist) if there is none.
+func ReadState(dir string) (State, error) {
+	var st State
+	data, err := os.ReadFile(filepath.Join(dir, StateFilename))
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("corrupt state file %s: %w", filepath.Join(dir, StateFilename), err)
+	}
+	return st, nil
+}
+
+// RecoverRevision returns the last revision emitted to dir: the larger of the
+// state file's and the newest readable graph file's. It returns 0 for a
+// directory that was never emitted to, and an error if dir holds output but
+// no revision could be recovered from it.
+func RecoverRevision(dir string) (uint64, error) {
+	var revision uint64
+	found := false
+	consider := func(rev uint64) {
+		revision, found = max(revision, rev), true
+	}
+
+	st, stateErr := ReadState(dir)
+	switch {
+	case stateErr == nil:
+		consider(st.Revision)
+	case errors.Is(stateErr, os.ErrNotExist):
+		stateErr = nil
+	default:
+		log.WithError(stateErr).Warn("Ignoring unreadable state file, recovering the revision from the graph files")
+	}
+
+	files, err := ListGraphFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	// the newest file that parses; older ones can only hold smaller revisions
+	for i := len(files) - 1; i >= 0; i-- {
+		rev, err := readGraphRevision(files[i])
+		if err == nil {
+			consider(rev)
+			break
+		}
+		log.WithField("file", files[i]).WithError(err).Warn("Skipping unreadable graph file")
+	}
+	if rev, err := readGraphRevision(filepath.Join(dir, LatestFilename)); err == nil {
+		consider(rev)
+	}
+
+	if !found && (stateErr != nil || len(files) > 0) {
+		return 0, fmt.Errorf("no revision recoverable from %s", dir)
+	}
+	return revision, nil
+}
+
+// FallbackRevision derives a starting revision from now for when
+// RecoverRevision fails. Revisions count builds from 1, so the Unix time in
+// milliseconds is far above any revision a counter could have reached.
+func FallbackRevision(now time.Time) uint64 {
+	return uint64(now.UnixMilli())
+}
+
+// readGraphRevision decodes the graphRevision of the graph file at path.
+func readGraphRevision(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var doc struct {
+		GraphRevision *uint64 `json:"graphRevision"`
+	}
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if doc.GraphRevision == nil {
+		return 0, fmt.Errorf("%s has no graphRevision", path)
+	}
+	return *doc.GraphRevision, nil
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package emitter
+
+// syncDir is not supported on this platform (directories can't be opened
+// for syncing on Windows); renames are left to the filesystem here.
+func syncDir(dir string) error {
+	return nil
+}
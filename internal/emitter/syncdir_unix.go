@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd
+
+package emitter
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// syncDir fsyncs dir so a rename into it survives a crash. Filesystems that
+// don't support syncing directories, and directories that can be written
+// but not opened, are treated as a no-op: the rename already happened.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if errors.Is(err, os.ErrPermission) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+	return nil
+}
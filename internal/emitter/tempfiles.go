@@ -0,0 +1,65 @@
+package emitter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// tempPattern names the temporary files writeFileAtomic renames into place.
+	tempPattern = "graph-*.json.tmp"
+	// tempSuffix is what every temporary file name ends in.
+	tempSuffix = ".json.tmp"
+
+	// DefaultTempMaxAge is how old a temporary file must be before
+	// CleanTempFiles considers it orphaned. Younger files may belong to a
+	// write still in progress, e.g. by the previous pod during a rollout.
+	DefaultTempMaxAge = 5 * time.Minute
+)
+
+// CleanTempFiles removes temporary files older than maxAge that a crashed
+// process left behind in dir and its per-namespace subdirectories. It
+// returns the number of files removed.
+func CleanTempFiles(dir string, maxAge time.Duration) (int, error) {
+	dirs := []string{dir}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read output directory %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	var errs []error
+	for _, d := range dirs {
+		matches, err := filepath.Glob(filepath.Join(d, "*"+tempSuffix))
+		if err != nil {
+			return removed, err
+		}
+		for _, path := range matches {
+			info, err := os.Lstat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("failed to remove stale temporary file %s: %w", path, err))
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, errors.Join(errs...)
+}
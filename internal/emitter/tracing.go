@@ -0,0 +1,32 @@
+package emitter
+
+import (
+	"github.com/tthuwng/satellite/internal/graph"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is resolved through the global provider, so spans are no-ops until
+// the application installs one.
+var tracer = otel.Tracer("github.com/tthuwng/satellite/internal/emitter")
+
+// graphAttributes describes g on a span.
+func graphAttributes(g graph.Graph) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attribute.Int64("satellite.revision", int64(g.GraphRevision)),
+		attribute.Int("satellite.nodes", len(g.Nodes)),
+		attribute.Int("satellite.relationships", len(g.Relationships)),
+	)
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,203 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Target receives the changes of Churn.
+type Target interface {
+	Create(obj runtime.Object) error
+	Update(obj runtime.Object) error
+	Delete(obj runtime.Object) error
+}
+
+// Churn applies about rate changes per second to target until ctx is done,
+// then returns ctx's error, or the first error of target. The sequence of
+// changes depends only on the cluster's seed.
+func (c *Cluster) Churn(ctx context.Context, rate float64, target Target) error {
+	if rate <= 0 {
+		return fmt.Errorf("failed to churn: rate must be positive, got %v", rate)
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Step(target); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Step applies the next change to target, mimicking a busy cluster: 70% are
+// pod status updates (a container restarts and flips readiness), 20% replace
+// a pod with a new one of the same app, as a rollout or eviction would, and
+// 10% edit a ConfigMap.
+func (c *Cluster) Step(target Target) error {
+	switch roll := c.rng.Intn(10); {
+	case roll < 7:
+		return c.restartPod(target)
+	case roll < 9:
+		return c.replacePod(target)
+	default:
+		return c.editConfigMap(target)
+	}
+}
+
+// restartPod bumps the restart count of a random pod and flips its
+// readiness.
+func (c *Cluster) restartPod(target Target) error {
+	if len(c.pods) == 0 {
+		return nil
+	}
+	i := c.rng.Intn(len(c.pods))
+	pod := c.pods[i].DeepCopy()
+	bumpResourceVersion(pod)
+	for j := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[j].RestartCount++
+		pod.Status.ContainerStatuses[j].Ready = !pod.Status.ContainerStatuses[j].Ready
+	}
+	c.replace(pod, c.pods[i])
+	c.pods[i] = pod
+	return target.Update(pod)
+}
+
+// replacePod deletes a random pod and creates a new one of the same app.
+func (c *Cluster) replacePod(target Target) error {
+	if len(c.pods) == 0 {
+		return nil
+	}
+	i := c.rng.Intn(len(c.pods))
+	old := c.pods[i]
+	if err := target.Delete(old); err != nil {
+		return err
+	}
+	c.generation++
+	app := old.Labels["app"]
+	name := fmt.Sprintf("%s-current-r%d", app, c.generation)
+	pod := c.newPod(old.Namespace, app, name, old.Labels["team"])
+	c.replace(pod, old)
+	c.pods[i] = pod
+	return target.Create(pod)
+}
+
+// editConfigMap changes the data of a random ConfigMap.
+func (c *Cluster) editConfigMap(target Target) error {
+	if len(c.configMaps) == 0 {
+		return nil
+	}
+	i := c.rng.Intn(len(c.configMaps))
+	cm := c.configMaps[i].DeepCopy()
+	bumpResourceVersion(cm)
+	cm.Data["revision"] = cm.ResourceVersion
+	c.replace(cm, c.configMaps[i])
+	c.configMaps[i] = cm
+	return target.Update(cm)
+}
+
+// replace swaps old for obj in the cluster's objects.
+func (c *Cluster) replace(obj, old runtime.Object) {
+	for i, o := range c.objects {
+		if o == old {
+			c.objects[i] = obj
+			return
+		}
+	}
+}
+
+// bumpResourceVersion increments the resourceVersion of obj.
+func bumpResourceVersion(obj metav1.Object) {
+	rv, _ := strconv.Atoi(obj.GetResourceVersion())
+	obj.SetResourceVersion(strconv.Itoa(rv + 1))
+}
+
+// cacheTarget applies changes to a ResourceCache directly.
+type cacheTarget struct {
+	cache *cache.ResourceCache
+}
+
+// CacheTarget returns a Target upserting into and deleting from resourceCache.
+func CacheTarget(resourceCache *cache.ResourceCache) Target {
+	return cacheTarget{cache: resourceCache}
+}
+
+func (t cacheTarget) Create(obj runtime.Object) error { t.cache.Upsert(obj); return nil }
+func (t cacheTarget) Update(obj runtime.Object) error { t.cache.Upsert(obj); return nil }
+func (t cacheTarget) Delete(obj runtime.Object) error { t.cache.Delete(obj); return nil }
+
+// clientsetTarget applies changes to a fake clientset's object tracker, so
+// informers on the clientset see them as watch events.
+type clientsetTarget struct {
+	client *fake.Clientset
+}
+
+// ClientsetTarget returns a Target changing the objects of client.
+func ClientsetTarget(client *fake.Clientset) Target {
+	return clientsetTarget{client: client}
+}
+
+func (t clientsetTarget) Create(obj runtime.Object) error {
+	gvr, ns, err := resourceOf(obj)
+	if err != nil {
+		return err
+	}
+	return t.client.Tracker().Create(gvr, obj, ns)
+}
+
+func (t clientsetTarget) Update(obj runtime.Object) error {
+	gvr, ns, err := resourceOf(obj)
+	if err != nil {
+		return err
+	}
+	return t.client.Tracker().Update(gvr, obj, ns)
+}
+
+func (t clientsetTarget) Delete(obj runtime.Object) error {
+	gvr, ns, err := resourceOf(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	return t.client.Tracker().Delete(gvr, ns, accessor.GetName())
+}
+
+// Clientset returns a fake clientset serving the cluster's objects.
+func (c *Cluster) Clientset() *fake.Clientset {
+	objects := make([]runtime.Object, len(c.objects))
+	for i, obj := range c.objects {
+		objects[i] = obj.DeepCopyObject()
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+// resourceOf returns the resource and namespace of obj.
+func resourceOf(obj runtime.Object) (schema.GroupVersionResource, string, error) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("failed to resolve kind of %T: %w", obj, err)
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return schema.GroupVersionResource{}, "", err
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gvks[0])
+	return gvr, accessor.GetNamespace(), nil
+}
@@ -0,0 +1,255 @@
+// Package fixtures generates synthetic clusters: namespaces of apps, each a
+// Deployment with its ReplicaSets and pods, a Service selecting them and a
+// ConfigMap they mount, with the pods spread over a pool of nodes. The
+// objects depend only on the Options, including the seed, so benchmarks and
+// tests can pin the graphs built from them.
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// Created is the creation timestamp of every generated object.
+var Created = metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+// Options sizes a synthetic cluster. Zero fields take their defaults.
+type Options struct {
+	// Seed drives every random choice; the same Options generate the same
+	// objects.
+	Seed int64
+	// Namespaces the apps are spread over round-robin; default 10.
+	Namespaces int
+	// Apps is the number of Deployments; default 1.
+	Apps int
+	// PodsPerApp is the mean replica count of an app, which varies between
+	// half and one and a half times it; default 8.
+	PodsPerApp int
+	// Nodes the pods are scheduled on at random; default 1.
+	Nodes int
+}
+
+// ForObjects returns Options for a cluster of about n objects, with one
+// node per 100 objects.
+func ForObjects(n int, seed int64) Options {
+	const podsPerApp = 8
+	nodes := max(n/100, 1)
+	// per app: a Deployment, two ReplicaSets, a Service, a ConfigMap and the pods
+	return Options{
+		Seed:       seed,
+		Namespaces: 10,
+		Apps:       max((n-nodes)/(podsPerApp+5), 1),
+		PodsPerApp: podsPerApp,
+		Nodes:      nodes,
+	}
+}
+
+// withDefaults fills in the zero fields of o.
+func (o Options) withDefaults() Options {
+	if o.Namespaces <= 0 {
+		o.Namespaces = 10
+	}
+	if o.Apps <= 0 {
+		o.Apps = 1
+	}
+	if o.PodsPerApp <= 0 {
+		o.PodsPerApp = 8
+	}
+	if o.Nodes <= 0 {
+		o.Nodes = 1
+	}
+	return o
+}
+
+// Cluster is a generated object set. Churn mutates it in place.
+type Cluster struct {
+	Options Options
+
+	rng        *rand.Rand
+	objects    []runtime.Object
+	pods       []*corev1.Pod // in generation order, replaced pods swapped in place
+	configMaps []*corev1.ConfigMap
+	generation int // suffixes the names of replacement pods
+}
+
+// Generate builds the cluster described by opts.
+func Generate(opts Options) *Cluster {
+	opts = opts.withDefaults()
+	c := &Cluster{Options: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+
+	for i := 0; i < opts.Nodes; i++ {
+		c.add(&corev1.Node{
+			ObjectMeta: objectMeta("Node", "", fmt.Sprintf("node-%d", i), map[string]string{
+				"kubernetes.io/os":            "linux",
+				"topology.kubernetes.io/zone": fmt.Sprintf("zone-%c", 'a'+i%3),
+			}),
+			Spec: corev1.NodeSpec{PodCIDR: fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)},
+			Status: corev1.NodeStatus{
+				Capacity:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8"), corev1.ResourceMemory: resource.MustParse("32Gi")},
+				Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("7800m"), corev1.ResourceMemory: resource.MustParse("30Gi")},
+				NodeInfo:    corev1.NodeSystemInfo{KubeletVersion: "v1.30.0", OSImage: "Ubuntu 22.04", ContainerRuntimeVersion: "containerd://1.7.0"},
+			},
+		})
+	}
+	for i := 0; i < opts.Namespaces; i++ {
+		ns := &corev1.Namespace{ObjectMeta: objectMeta("Namespace", "", namespaceName(i), nil)}
+		ns.Status.Phase = corev1.NamespaceActive
+		c.add(ns)
+	}
+	for a := 0; a < opts.Apps; a++ {
+		c.addApp(a)
+	}
+	return c
+}
+
+// addApp generates app a: a Deployment with a current and a scaled-down old
+// ReplicaSet, the current one's pods, a Service and a ConfigMap.
+func (c *Cluster) addApp(a int) {
+	ns := namespaceName(a % c.Options.Namespaces)
+	app := fmt.Sprintf("app-%d", a)
+	appLabels := map[string]string{"app": app, "team": fmt.Sprintf("team-%d", a%7)}
+	replicas := int32(c.Options.PodsPerApp/2 + c.rng.Intn(c.Options.PodsPerApp+1))
+	zero := int32(0)
+
+	c.add(&appsv1.Deployment{
+		ObjectMeta: objectMeta("Deployment", ns, app, appLabels),
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}},
+		Status:     appsv1.DeploymentStatus{Replicas: replicas, UpdatedReplicas: replicas, ReadyReplicas: replicas, AvailableReplicas: replicas},
+	})
+	deployRef := ownerRef("Deployment", ns, app)
+	for r, hash := range []string{"current", "old"} {
+		rsLabels := map[string]string{"app": app, "pod-template-hash": hash}
+		rs := &appsv1.ReplicaSet{
+			ObjectMeta: objectMeta("ReplicaSet", ns, app+"-"+hash, rsLabels),
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: rsLabels}},
+			Status:     appsv1.ReplicaSetStatus{Replicas: replicas, ReadyReplicas: replicas, AvailableReplicas: replicas},
+		}
+		rs.OwnerReferences = []metav1.OwnerReference{deployRef}
+		if r > 0 {
+			rs.Spec.Replicas = &zero
+			rs.Status = appsv1.ReplicaSetStatus{}
+		}
+		c.add(rs)
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: objectMeta("Service", ns, app, appLabels),
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeClusterIP, Selector: map[string]string{"app": app},
+			ClusterIP: fmt.Sprintf("10.96.%d.%d", a/256%256, a%256),
+			Ports:     []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}},
+		},
+	}
+	if c.rng.Intn(10) == 0 {
+		svc.Spec.Type = corev1.ServiceTypeNodePort
+	}
+	svc.Spec.ClusterIPs = []string{svc.Spec.ClusterIP}
+	c.add(svc)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta("ConfigMap", ns, app+"-config", appLabels),
+		Data:       map[string]string{"config.yaml": fmt.Sprintf("replicas: %d", replicas)},
+	}
+	c.add(cm)
+	c.configMaps = append(c.configMaps, cm)
+
+	for p := 0; p < int(replicas); p++ {
+		pod := c.newPod(ns, app, fmt.Sprintf("%s-current-%d", app, p), appLabels["team"])
+		c.add(pod)
+		c.pods = append(c.pods, pod)
+	}
+}
+
+// newPod returns a pod of app's current ReplicaSet on a random node. One in
+// twenty is still Pending and one in fifty has Failed.
+func (c *Cluster) newPod(ns, app, name, team string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: objectMeta("Pod", ns, name, map[string]string{"app": app, "pod-template-hash": "current", "team": team}),
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "registry.example.com/" + app + ":1.0"}},
+			Volumes: []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: app + "-config"}},
+			}}},
+		},
+	}
+	pod.OwnerReferences = []metav1.OwnerReference{ownerRef("ReplicaSet", ns, app+"-current")}
+	pod.Annotations = map[string]string{"prometheus.io/scrape": "true"}
+	switch roll := c.rng.Intn(100); {
+	case roll < 5:
+		pod.Status.Phase = corev1.PodPending
+	default:
+		pod.Spec.NodeName = fmt.Sprintf("node-%d", c.rng.Intn(c.Options.Nodes))
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning, StartTime: &Created, HostIP: "192.168.0.1",
+			PodIP:             fmt.Sprintf("10.%d.%d.%d", c.rng.Intn(256), c.rng.Intn(256), c.rng.Intn(256)),
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true, Image: pod.Spec.Containers[0].Image}},
+		}
+		if roll < 7 {
+			pod.Status.Phase = corev1.PodFailed
+			pod.Status.ContainerStatuses[0].Ready = false
+		}
+	}
+	return pod
+}
+
+// add appends obj to the cluster's objects.
+func (c *Cluster) add(obj runtime.Object) {
+	c.objects = append(c.objects, obj)
+}
+
+// Objects returns the cluster's current objects: nodes, namespaces, then
+// every app's objects. The objects are shared with the cluster.
+func (c *Cluster) Objects() []runtime.Object {
+	return c.objects
+}
+
+// Cache returns a new cache holding the cluster's objects.
+func (c *Cluster) Cache() *cache.ResourceCache {
+	resourceCache := cache.NewResourceCache()
+	c.Load(resourceCache)
+	return resourceCache
+}
+
+// Load upserts the cluster's objects into resourceCache.
+func (c *Cluster) Load(resourceCache *cache.ResourceCache) {
+	for _, obj := range c.objects {
+		resourceCache.Upsert(obj)
+	}
+}
+
+// namespaceName returns the name of the i-th namespace.
+func namespaceName(i int) string {
+	return fmt.Sprintf("ns-%d", i)
+}
+
+// objectMeta returns the metadata of a generated object; its UID is derived
+// from kind, namespace and name.
+func objectMeta(kind, namespace, name string, labels map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name: name, Namespace: namespace, Labels: labels,
+		UID:               uid(kind, namespace, name),
+		ResourceVersion:   "1",
+		CreationTimestamp: Created,
+	}
+}
+
+// ownerRef returns a controller reference to a generated object.
+func ownerRef(kind, namespace, name string) metav1.OwnerReference {
+	controller := true
+	return metav1.OwnerReference{APIVersion: "apps/v1", Kind: kind, Name: name, UID: uid(kind, namespace, name), Controller: &controller}
+}
+
+// uid returns the UID of a generated object.
+func uid(kind, namespace, name string) apitypes.UID {
+	return apitypes.UID(kind + "/" + namespace + "/" + name)
+}
@@ -0,0 +1,63 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Write writes the cluster's objects to w in format: "json" for a v1 List,
+// "yaml" for a stream of documents. Both can be applied with kubectl.
+func (c *Cluster) Write(w io.Writer, format string) error {
+	items := make([]runtime.RawExtension, 0, len(c.objects))
+	for _, obj := range c.objects {
+		typed, err := withTypeMeta(obj)
+		if err != nil {
+			return err
+		}
+		items = append(items, runtime.RawExtension{Object: typed})
+	}
+
+	switch format {
+	case "json":
+		list := struct {
+			metav1.TypeMeta `json:",inline"`
+			Items           []runtime.RawExtension `json:"items"`
+		}{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"}, Items: items}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			return fmt.Errorf("failed to write fixtures: %w", err)
+		}
+	case "yaml":
+		for _, item := range items {
+			data, err := yaml.Marshal(item.Object)
+			if err != nil {
+				return fmt.Errorf("failed to marshal fixture: %w", err)
+			}
+			if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+				return fmt.Errorf("failed to write fixtures: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown fixture format %q (want json or yaml)", format)
+	}
+	return nil
+}
+
+// withTypeMeta returns a copy of obj with its apiVersion and kind set, which
+// generated objects, like those from informers, leave empty.
+func withTypeMeta(obj runtime.Object) (runtime.Object, error) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve kind of %T: %w", obj, err)
+	}
+	typed := obj.DeepCopyObject()
+	typed.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return typed, nil
+}
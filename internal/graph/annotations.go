@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AnnotationMapping promotes an annotation to a node property: either the
+// annotation key Annotation to the property Property, or every annotation
+// under Prefix to Property followed by the rest of its key, e.g. prefix
+// "acme.io/" and property "" map acme.io/team to team.
+type AnnotationMapping struct {
+	Annotation string `json:"annotation,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	Property   string `json:"property"`
+}
+
+// reservedProperties identify an object or its deletion and can't be
+// promoted to.
+var reservedProperties = map[string]bool{
+	"uid":                     true,
+	"resourceVersion":         true,
+	"creationTimestamp":       true,
+	StateProperty:             true,
+	DeletedProperty:           true,
+	DeletionTimestampProperty: true,
+	MetadataOnlyProperty:      true,
+}
+
+// AnnotationProperties promotes annotations to node properties. Promoted
+// properties override extracted ones of the same name. An annotation is
+// promoted by its exact mapping, else by its longest matching prefix, and
+// when two annotations promote to one property the exact mapping, then the
+// longer prefix, wins.
+type AnnotationProperties struct {
+	keys     map[string]string // annotation -> property
+	prefixes []AnnotationMapping
+}
+
+// NewAnnotationProperties validates mappings: each sets exactly one of
+// Annotation, a valid annotation key, and Prefix; exact mappings need a
+// property; annotations, prefixes and exact properties are unique, and no
+// property is reserved.
+func NewAnnotationProperties(mappings []AnnotationMapping) (*AnnotationProperties, error) {
+	a := &AnnotationProperties{keys: make(map[string]string)}
+	prefixes := make(map[string]bool)
+	properties := make(map[string]string)
+	for i, m := range mappings {
+		switch {
+		case (m.Annotation == "") == (m.Prefix == ""):
+			return nil, fmt.Errorf("mapping %d: exactly one of annotation and prefix is required", i)
+		case strings.ContainsAny(m.Property, " \t\r\n"):
+			return nil, fmt.Errorf("mapping %d: property %q contains whitespace", i, m.Property)
+		case reservedProperties[m.Property]:
+			return nil, fmt.Errorf("mapping %d: property %q is reserved", i, m.Property)
+		}
+		if m.Prefix != "" {
+			if prefixes[m.Prefix] {
+				return nil, fmt.Errorf("mapping %d: duplicate prefix %q", i, m.Prefix)
+			}
+			prefixes[m.Prefix] = true
+			a.prefixes = append(a.prefixes, m)
+			continue
+		}
+		if errs := validation.IsQualifiedName(m.Annotation); len(errs) > 0 {
+			return nil, fmt.Errorf("mapping %d: invalid annotation %q: %s", i, m.Annotation, strings.Join(errs, "; "))
+		}
+		if m.Property == "" {
+			return nil, fmt.Errorf("mapping %d: annotation %q needs a property", i, m.Annotation)
+		}
+		if _, ok := a.keys[m.Annotation]; ok {
+			return nil, fmt.Errorf("mapping %d: duplicate annotation %q", i, m.Annotation)
+		}
+		if other, ok := properties[m.Property]; ok {
+			return nil, fmt.Errorf("mapping %d: property %q is already mapped from %q", i, m.Property, other)
+		}
+		a.keys[m.Annotation] = m.Property
+		properties[m.Property] = m.Annotation
+	}
+	slices.SortFunc(a.prefixes, func(x, y AnnotationMapping) int {
+		if c := cmp.Compare(len(y.Prefix), len(x.Prefix)); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Prefix, y.Prefix)
+	})
+	return a, nil
+}
+
+// apply sets the properties promoted from annotations, eliding long values
+// like the annotations property does.
+func (a *AnnotationProperties) apply(props map[string]string, annotations map[string]string) {
+	if a == nil || len(annotations) == 0 {
+		return
+	}
+	promoted := make(map[string]bool)
+	claimed := make(map[string]bool)
+	for annotation, property := range a.keys {
+		if v, ok := annotations[annotation]; ok {
+			props[property] = k8s.ElideValue(v)
+			promoted[property] = true
+			claimed[annotation] = true
+		}
+	}
+	for _, m := range a.prefixes {
+		for annotation, v := range annotations {
+			rest, ok := strings.CutPrefix(annotation, m.Prefix)
+			if !ok || rest == "" || claimed[annotation] {
+				continue
+			}
+			claimed[annotation] = true
+			property := m.Property + rest
+			if promoted[property] || reservedProperties[property] {
+				continue
+			}
+			props[property] = k8s.ElideValue(v)
+			promoted[property] = true
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package graph
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MinAnonymizeKeyLen is the shortest key NewAnonymizer accepts.
+const MinAnonymizeKeyLen = 16
+
+// anonymousPrefix starts every pseudonym.
+const anonymousPrefix = "anon-"
+
+// keptProperties hold enumerations and versions, not names, and keep their
+// values.
+var keptProperties = map[string]bool{
+	StateProperty: true, "status.phase": true, "spec.type": true, "type": true,
+	"status.condition": true, "status.conditionReason": true, "scalingLimitedReason": true,
+	"lastWarningReason": true, "reason": true, "capacityType": true, "arch": true, "os": true, InstanceTypeProperty: true,
+	"status.nodeInfo.kubeletVersion": true, "status.nodeInfo.osImage": true, "status.nodeInfo.containerRuntimeVersion": true,
+	"version": true, "kinds": true, "shard": true, "spec.podManagementPolicy": true, "spec.updateStrategy": true,
+	UsageProperty: true, "spec.persistentVolumeReclaimPolicy": true, "spec.accessModes": true,
+	"spec.ingressClassName": true, "addressType": true, "rules.verbs": true, "spec.policyTypes": true,
+	"apiVersion": true, "status.ready": true, "status.readyReason": true, "preemptionPolicy": true, "handler": true,
+	"failurePolicy": true, "rules.operations": true,
+}
+
+// keptContainerProperties are the kept "containers.<name>.<field>" fields.
+var keptContainerProperties = map[string]bool{"state": true, "reason": true, "lastTerminated.reason": true}
+
+// AnonymizeOptions lists what an Anonymizer keeps as is.
+type AnonymizeOptions struct {
+	// Namespaces are kept, e.g. kube-system; the names of their objects
+	// are not.
+	Namespaces []string
+	// Labels are label and annotation keys whose values are kept, e.g.
+	// app.kubernetes.io/name.
+	Labels []string
+}
+
+// Anonymizer pseudonymizes graphs for sharing: names, namespaces, clusters,
+// UIDs, label and annotation values, IPs and any other string property
+// become an HMAC of the value, stable for a key but irreversible without
+// it, while kinds, relationships, property keys and numeric, boolean, time
+// and enumerated values are kept. The same value always gets the same
+// pseudonym, so e.g. a selector still matches the labels it selects.
+type Anonymizer struct {
+	key        []byte
+	namespaces map[string]bool
+	labels     map[string]bool
+}
+
+// NewAnonymizer returns an anonymizer keyed with key, of at least
+// MinAnonymizeKeyLen bytes.
+func NewAnonymizer(key []byte, opts AnonymizeOptions) (*Anonymizer, error) {
+	if len(key) < MinAnonymizeKeyLen {
+		return nil, fmt.Errorf("anonymization key is %d bytes, want at least %d", len(key), MinAnonymizeKeyLen)
+	}
+	a := &Anonymizer{key: key, namespaces: make(map[string]bool), labels: make(map[string]bool)}
+	for _, ns := range opts.Namespaces {
+		a.namespaces[ns] = true
+	}
+	for _, l := range opts.Labels {
+		a.labels[l] = true
+	}
+	return a, nil
+}
+
+// Anonymize returns an anonymized copy of g.
+func (a *Anonymizer) Anonymize(g Graph) Graph {
+	out := Graph{
+		Nodes:         make([]GraphNode, len(g.Nodes)),
+		Relationships: make([]GraphRelationship, len(g.Relationships)),
+		GraphRevision: g.GraphRevision,
+		index:         new(lazyIndex),
+	}
+	for i, n := range g.Nodes {
+		out.Nodes[i] = GraphNode{Key: a.entityKey(n.Key), Properties: a.properties(n.Properties), Revision: n.Revision}
+	}
+	for i, r := range g.Relationships {
+		out.Relationships[i] = GraphRelationship{
+			Source:           a.entityKey(r.Source),
+			Target:           a.entityKey(r.Target),
+			RelationshipType: r.RelationshipType,
+			Properties:       a.properties(r.Properties),
+			Revision:         r.Revision,
+		}
+	}
+	if g.Metadata != nil {
+		m := *g.Metadata
+		m.ClusterName = a.name(m.ClusterName)
+		m.ResourceVersions = anonymizeClusterKeys(a, m.ResourceVersions)
+		m.Bookmarks = anonymizeClusterKeys(a, m.Bookmarks)
+		out.Metadata = &m
+	}
+	return out
+}
+
+// anonymizeClusterKeys anonymizes the cluster of "cluster/kind" keys.
+func anonymizeClusterKeys[V any](a *Anonymizer, m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]V, len(m))
+	for k, v := range m {
+		if cluster, kind, ok := strings.Cut(k, "/"); ok {
+			k = a.name(cluster) + "/" + kind
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// name returns the pseudonym of s; empty stays empty.
+func (a *Anonymizer) name(s string) string {
+	if s == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(s))
+	return anonymousPrefix + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+func (a *Anonymizer) namespace(ns string) string {
+	if a.namespaces[ns] {
+		return ns
+	}
+	return a.name(ns)
+}
+
+func (a *Anonymizer) entityKey(k GraphEntityKey) GraphEntityKey {
+	k.Namespace = a.namespace(k.Namespace)
+	if k.Kind == "Namespace" {
+		k.Name = a.namespace(k.Name)
+	} else {
+		k.Name = a.name(k.Name)
+	}
+	k.Cluster = a.name(k.Cluster)
+	return k
+}
+
+// properties anonymizes the keys and values of props.
+func (a *Anonymizer) properties(props map[string]string) map[string]string {
+	if props == nil {
+		return nil
+	}
+	out := make(map[string]string, len(props))
+	for k, v := range props {
+		out[a.propertyKey(k)] = a.value(k, v)
+	}
+	return out
+}
+
+// propertyKey anonymizes the container name of "containers.<name>.<field>"
+// keys; other keys name no object.
+func (a *Anonymizer) propertyKey(k string) string {
+	rest, ok := strings.CutPrefix(k, "containers.")
+	if !ok {
+		return k
+	}
+	container, field, ok := strings.Cut(rest, ".")
+	if !ok {
+		return k
+	}
+	return "containers." + a.name(container) + "." + field
+}
+
+// value anonymizes the value v of property k.
+func (a *Anonymizer) value(k, v string) string {
+	if v == "" || keptProperties[k] {
+		return v
+	}
+	if rest, ok := strings.CutPrefix(k, "containers."); ok {
+		if _, field, ok := strings.Cut(rest, "."); ok && keptContainerProperties[field] {
+			return v
+		}
+	}
+	switch k {
+	case LabelsProperty, "spec.selector", "spec.podSelector":
+		return a.selector(v)
+	case "annotations":
+		return a.annotations(v)
+	case "data.keys", "spec.clusterIPs", "dockerconfig.registries", "secrets", "imagePullSecrets", "status.loadBalancer.ips", "status.loadBalancer.hostnames", "host", "path":
+		parts := strings.Split(v, ",")
+		for i, p := range parts {
+			if !strings.HasPrefix(p, "+") {
+				parts[i] = a.scalar(p)
+			}
+		}
+		return strings.Join(parts, ",")
+	case "spec.scaleTargetRef", "roleRef":
+		if kind, name, ok := strings.Cut(v, "/"); ok {
+			return kind + "/" + a.name(name)
+		}
+	}
+	return a.scalar(v)
+}
+
+// scalar keeps numbers, booleans, quantities, times and durations, gives
+// IPs and CIDRs a pseudonymous address of the same family, and the
+// pseudonym of anything else.
+func (a *Anonymizer) scalar(v string) string {
+	if keptValue(v) {
+		return v
+	}
+	if addr, err := netip.ParseAddr(v); err == nil {
+		return a.addr(addr).String()
+	}
+	if prefix, err := netip.ParsePrefix(v); err == nil {
+		return netip.PrefixFrom(a.addr(prefix.Addr()), prefix.Bits()).Masked().String()
+	}
+	return a.name(v)
+}
+
+// addr returns a pseudonymous address in a range never assigned to hosts:
+// 240.0.0.0/4 for IPv4, 100::/64 for IPv6.
+func (a *Anonymizer) addr(addr netip.Addr) netip.Addr {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write(addr.AsSlice())
+	sum := mac.Sum(nil)
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte{240 | sum[0]&0x0f, sum[1], sum[2], sum[3]})
+	}
+	var b [16]byte
+	b[0], b[1] = 0x01, 0x00
+	copy(b[8:], sum[:8])
+	return netip.AddrFrom16(b)
+}
+
+// keptValue reports whether v is a number, boolean, quantity, time or
+// duration.
+func keptValue(v string) bool {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseBool(v); err == nil {
+		return true
+	}
+	if _, err := resource.ParseQuantity(v); err == nil {
+		return true
+	}
+	if _, err := time.ParseDuration(v); err == nil {
+		return true
+	}
+	for _, layout := range []string{time.RFC3339, time.RFC3339Nano, "2006-01-02 15:04:05 -0700 MST"} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// selector anonymizes the values of labels or a selector, keeping those of
+// allowed keys.
+func (a *Anonymizer) selector(v string) string {
+	sel, err := labels.Parse(v)
+	if err != nil {
+		return a.name(v)
+	}
+	reqs, _ := sel.Requirements()
+	parts := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		values := req.ValuesUnsorted()
+		if !a.labels[req.Key()] {
+			for i, value := range values {
+				values[i] = a.labelValue(value)
+			}
+		}
+		anon, err := labels.NewRequirement(req.Key(), req.Operator(), values)
+		if err != nil {
+			// a pseudonym is a valid label value, so this is unreachable
+			return a.name(v)
+		}
+		parts = append(parts, anon.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// labelValue keeps numbers, e.g. of Gt requirements, and booleans.
+func (a *Anonymizer) labelValue(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	if _, err := strconv.ParseBool(v); err == nil {
+		return v
+	}
+	return a.name(v)
+}
+
+// annotations anonymizes the values of "key=value" pairs, whose values may
+// hold commas: a part is a new pair only if it starts with a key and "=".
+func (a *Anonymizer) annotations(v string) string {
+	var keys []string
+	values := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		if k, value, ok := strings.Cut(part, "="); ok && isAnnotationKey(k) {
+			keys = append(keys, k)
+			values[k] = value
+			continue
+		}
+		if len(keys) == 0 {
+			return a.name(v)
+		}
+		values[keys[len(keys)-1]] += "," + part
+	}
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		value := values[k]
+		if !a.labels[k] {
+			value = a.scalar(value)
+		}
+		parts[i] = k + "=" + value
+	}
+	return strings.Join(parts, ",")
+}
+
+func isAnnotationKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("./-_", r)) {
+			return false
+		}
+	}
+	return true
+}
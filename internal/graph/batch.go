@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// MissedLastRunProperty is set on a CronJob to "true" when its most recent
+// scheduled run did not start, "false" otherwise. It is left out when the
+// schedule can't be parsed.
+const MissedLastRunProperty = "missedLastRun"
+
+// missedRunGrace is how late a run without a startingDeadlineSeconds may
+// start before it counts as missed.
+const missedRunGrace = time.Minute
+
+// addJobProperties sets a Job's outcome: the Complete or Failed condition
+// and its reason, start and completion times, the duration of a finished
+// Job, its active, succeeded and failed pods, its backoffLimit and its pod
+// selector.
+func addJobProperties(props map[string]string, job *batchv1.Job) {
+	props["spec.backoffLimit"] = int32PtrToString(job.Spec.BackoffLimit)
+	props["spec.selector"] = labelSelectorToString(job.Spec.Selector)
+	props["status.active"] = formatInt(job.Status.Active)
+	props["status.succeeded"] = formatInt(job.Status.Succeeded)
+	props["status.failed"] = formatInt(job.Status.Failed)
+	props["status.startTime"] = timePtrToString(job.Status.StartTime)
+	props["status.completionTime"] = timePtrToString(job.Status.CompletionTime)
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue || (cond.Type != batchv1.JobComplete && cond.Type != batchv1.JobFailed) {
+			continue
+		}
+		props["status.condition"] = string(cond.Type)
+		props["status.conditionReason"] = cond.Reason
+
+		finished := cond.LastTransitionTime
+		if job.Status.CompletionTime != nil {
+			finished = *job.Status.CompletionTime
+		}
+		if job.Status.StartTime != nil && !finished.IsZero() {
+			props["durationSeconds"] = strconv.FormatFloat(finished.Sub(job.Status.StartTime.Time).Seconds(), 'f', -1, 64)
+		}
+	}
+}
+
+// addCronJobProperties sets a CronJob's schedule, suspend state, number of
+// active Jobs, last schedule and success times and, as of now,
+// missedLastRun.
+func addCronJobProperties(props map[string]string, cj *batchv1.CronJob, now time.Time) {
+	props["spec.schedule"] = cj.Spec.Schedule
+	if cj.Spec.TimeZone != nil {
+		props["spec.timeZone"] = *cj.Spec.TimeZone
+	}
+	props["spec.suspend"] = strconv.FormatBool(cj.Spec.Suspend != nil && *cj.Spec.Suspend)
+	props["status.active"] = strconv.Itoa(len(cj.Status.Active))
+	props["status.lastScheduleTime"] = timePtrToString(cj.Status.LastScheduleTime)
+	props["status.lastSuccessfulTime"] = timePtrToString(cj.Status.LastSuccessfulTime)
+	if missed, err := MissedLastRun(cj, now); err == nil {
+		props[MissedLastRunProperty] = strconv.FormatBool(missed)
+	}
+}
+
+// MissedLastRun reports whether cj has a scheduled run, after its last
+// scheduled one (or its creation if it never ran), that should have started
+// by now. A run may start up to startingDeadlineSeconds, or a minute without
+// one, late. The schedule is read as the CronJob controller does: standard
+// cron fields or a descriptor such as @hourly or @every 90m, in spec.timeZone,
+// a CRON_TZ= prefix or else UTC. A suspended CronJob never misses a run.
+func MissedLastRun(cj *batchv1.CronJob, now time.Time) (bool, error) {
+	spec := cj.Spec.Schedule
+	if cj.Spec.TimeZone != nil {
+		if _, err := time.LoadLocation(*cj.Spec.TimeZone); err != nil {
+			return false, fmt.Errorf("invalid time zone %q: %w", *cj.Spec.TimeZone, err)
+		}
+		spec = "CRON_TZ=" + *cj.Spec.TimeZone + " " + spec
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse schedule %q: %w", cj.Spec.Schedule, err)
+	}
+	if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+		return false, nil
+	}
+
+	last := cj.CreationTimestamp.Time
+	if cj.Status.LastScheduleTime != nil {
+		last = cj.Status.LastScheduleTime.Time
+	}
+	if last.IsZero() {
+		return false, nil
+	}
+	grace := missedRunGrace
+	if cj.Spec.StartingDeadlineSeconds != nil {
+		grace = time.Duration(*cj.Spec.StartingDeadlineSeconds) * time.Second
+	}
+	return !schedule.Next(last.UTC()).Add(grace).After(now), nil
+}
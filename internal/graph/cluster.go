@@ -0,0 +1,113 @@
+package graph
+
+import "time"
+
+// ClusterKind is the Kind of the pseudo-node anchoring each cluster's partition.
+const ClusterKind = "Cluster"
+
+// UnknownCluster is recorded when no cluster name could be determined.
+const UnknownCluster = "unknown"
+
+// ClusterProperty is the node property StampClusterName optionally sets.
+const ClusterProperty = "cluster"
+
+// ClusterGraph is the graph built from a single cluster's cache.
+type ClusterGraph struct {
+	Cluster string
+	Graph   Graph
+}
+
+// MergeClusters stamps every key of each partition with its cluster name and
+// merges the partitions into one graph. Each partition gets a Cluster
+// pseudo-node and an IN_CLUSTER relationship from each of its nodes. Per-cluster
+// metadata entries are qualified as "<cluster>/<entry>".
+func MergeClusters(revision uint64, parts []ClusterGraph) Graph {
+	merged := Graph{
+		Nodes:         make([]GraphNode, 0),
+		Relationships: make([]GraphRelationship, 0),
+		GraphRevision: revision,
+		index:         new(lazyIndex),
+	}
+
+	for _, part := range parts {
+		clusterKey := GraphEntityKey{Name: part.Cluster, Kind: ClusterKind, Cluster: part.Cluster}
+		merged.Nodes = append(merged.Nodes, GraphNode{
+			Key:        clusterKey,
+			Properties: map[string]string{},
+			Revision:   revision,
+		})
+
+		for _, node := range part.Graph.Nodes {
+			node.Key.Cluster = part.Cluster
+			merged.Nodes = append(merged.Nodes, node)
+			merged.Relationships = append(merged.Relationships, GraphRelationship{
+				Source:           node.Key,
+				Target:           clusterKey,
+				RelationshipType: "IN_CLUSTER",
+				Revision:         revision,
+			})
+		}
+		for _, rel := range part.Graph.Relationships {
+			rel.Source.Cluster = part.Cluster
+			rel.Target.Cluster = part.Cluster
+			merged.Relationships = append(merged.Relationships, rel)
+		}
+		if part.Graph.Metadata != nil {
+			if part.Graph.Metadata.Shard != nil {
+				merged.Meta().Shard = part.Graph.Metadata.Shard
+			}
+			for _, kind := range part.Graph.Metadata.DisabledKinds {
+				meta := merged.Meta()
+				meta.DisabledKinds = append(meta.DisabledKinds, part.Cluster+"/"+kind)
+			}
+			for _, kind := range part.Graph.Metadata.StaleKinds {
+				meta := merged.Meta()
+				meta.StaleKinds = append(meta.StaleKinds, part.Cluster+"/"+kind)
+			}
+			for _, kind := range part.Graph.Metadata.UnsyncedKinds {
+				meta := merged.Meta()
+				meta.UnsyncedKinds = append(meta.UnsyncedKinds, part.Cluster+"/"+kind)
+			}
+			for kind, rv := range part.Graph.Metadata.ResourceVersions {
+				meta := merged.Meta()
+				if meta.ResourceVersions == nil {
+					meta.ResourceVersions = make(map[string]string)
+				}
+				meta.ResourceVersions[part.Cluster+"/"+kind] = rv
+			}
+			for kind, at := range part.Graph.Metadata.Bookmarks {
+				meta := merged.Meta()
+				if meta.Bookmarks == nil {
+					meta.Bookmarks = make(map[string]time.Time)
+				}
+				meta.Bookmarks[part.Cluster+"/"+kind] = at
+			}
+		}
+	}
+	return merged
+}
+
+// StampClusterName records name (or UnknownCluster if empty) in the graph
+// metadata and, if asProperty is set, as a "cluster" property on every node.
+// Nodes whose key already carries a cluster keep that cluster instead.
+func StampClusterName(g *Graph, name string, asProperty bool) {
+	if name == "" {
+		name = UnknownCluster
+	}
+	g.Meta().ClusterName = name
+
+	if !asProperty {
+		return
+	}
+	for i := range g.Nodes {
+		node := &g.Nodes[i]
+		if node.Properties == nil {
+			node.Properties = make(map[string]string)
+		}
+		if node.Key.Cluster != "" {
+			node.Properties[ClusterProperty] = node.Key.Cluster
+		} else {
+			node.Properties[ClusterProperty] = name
+		}
+	}
+}
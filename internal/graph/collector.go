@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/shard"
+)
+
+// CollectorKind is the Kind of the node describing the Satellite instance
+// that produced the graph.
+const CollectorKind = "Collector"
+
+// Collector identifies the running Satellite instance.
+type Collector struct {
+	// Name and Namespace are the pod's identity when running in-cluster;
+	// outside a cluster Name is the hostname and Namespace is empty.
+	Name      string
+	Namespace string
+	InCluster bool
+	Version   string
+	// Leader reports whether this instance is the one emitting the graph.
+	// Without leader election every instance emits, so it is always true.
+	Leader bool
+	// Kinds are the kinds this instance watches.
+	Kinds []string
+	Shard shard.Shard
+}
+
+// CollectorFromEnv identifies the instance from the downward API
+// (POD_NAME/POD_NAMESPACE), falling back to the hostname.
+func CollectorFromEnv(version string) Collector {
+	c := Collector{Version: version, Leader: true}
+	if name := os.Getenv("POD_NAME"); name != "" {
+		c.Name = name
+		c.Namespace = os.Getenv("POD_NAMESPACE")
+		c.InCluster = true
+		return c
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		c.Name = host
+	} else {
+		c.Name = "satellite"
+	}
+	return c
+}
+
+// key is the node key of the collector.
+func (c Collector) key() GraphEntityKey {
+	return GraphEntityKey{Name: c.Name, Namespace: c.Namespace, Kind: CollectorKind}
+}
+
+// properties renders the collector's description as node properties.
+func (c Collector) properties() map[string]string {
+	kinds := append([]string(nil), c.Kinds...)
+	sort.Strings(kinds)
+	props := map[string]string{
+		"version":   c.Version,
+		"leader":    strconv.FormatBool(c.Leader),
+		"inCluster": strconv.FormatBool(c.InCluster),
+		"kinds":     strings.Join(kinds, ","),
+	}
+	if c.Shard.Enabled() {
+		props["shard"] = fmt.Sprintf("%d/%d", c.Shard.Index, c.Shard.Count)
+	}
+	return props
+}
+
+// AddCollector adds the Collector node to g with an OBSERVES relationship to
+// every Cluster pseudo-node and, when running in-cluster, a RUNS_AS
+// relationship to its own Pod if the graph contains it. A graph without
+// Cluster pseudo-nodes (single-cluster mode) gets one named cluster.
+func AddCollector(g *Graph, c Collector, cluster string) {
+	if cluster == "" {
+		cluster = UnknownCluster
+	}
+	self := c.key()
+	g.Nodes = append(g.Nodes, GraphNode{Key: self, Properties: c.properties(), Revision: g.GraphRevision})
+
+	var clusters []GraphEntityKey
+	var pod GraphEntityKey
+	ownPod := false
+	for _, node := range g.Nodes {
+		switch key := node.Key; {
+		case key.Kind == ClusterKind:
+			clusters = append(clusters, key)
+		case c.InCluster && !ownPod && key.Kind == "Pod" && key.Name == c.Name && key.Namespace == c.Namespace:
+			pod, ownPod = key, true
+		}
+	}
+	if len(clusters) == 0 {
+		clusterKey := GraphEntityKey{Name: cluster, Kind: ClusterKind}
+		g.Nodes = append(g.Nodes, GraphNode{Key: clusterKey, Properties: map[string]string{}, Revision: g.GraphRevision})
+		clusters = append(clusters, clusterKey)
+	}
+
+	for _, clusterKey := range clusters {
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           self,
+			Target:           clusterKey,
+			RelationshipType: "OBSERVES",
+			Revision:         g.GraphRevision,
+		})
+	}
+	if ownPod {
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           self,
+			Target:           pod,
+			RelationshipType: "RUNS_AS",
+			Revision:         g.GraphRevision,
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// On a pod's relationship to a ConfigMap, UsageProperty lists UsageVolume,
+// UsageEnvFrom and UsageEnvKey, and KeyProperty the keys read as UsageEnvKey.
+const (
+	UsageEnvFrom = "envFrom"
+	UsageEnvKey  = "envKey"
+	KeyProperty  = "key"
+)
+
+// configMapUse is how a pod uses one ConfigMap.
+type configMapUse struct {
+	name     string
+	usages   []string
+	keys     []string
+	required bool
+}
+
+// podConfigMapUses returns the ConfigMaps pod references through volumes,
+// projected volumes, and the env and envFrom of all its containers, in
+// order of first reference, each once.
+func podConfigMapUses(pod *corev1.Pod) []*configMapUse {
+	var uses []*configMapUse
+	byName := make(map[string]*configMapUse)
+	add := func(name, usage, key string, optional *bool) {
+		if name == "" {
+			return
+		}
+		use := byName[name]
+		if use == nil {
+			use = &configMapUse{name: name}
+			byName[name] = use
+			uses = append(uses, use)
+		}
+		use.usages = append(use.usages, usage)
+		if key != "" {
+			use.keys = append(use.keys, key)
+		}
+		if optional == nil || !*optional {
+			use.required = true
+		}
+	}
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.ConfigMap != nil {
+			add(vol.ConfigMap.Name, UsageVolume, "", vol.ConfigMap.Optional)
+		}
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(source.ConfigMap.Name, UsageVolume, "", source.ConfigMap.Optional)
+				}
+			}
+		}
+	}
+	addEnv := func(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) {
+		for _, e := range env {
+			if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil {
+				ref := e.ValueFrom.ConfigMapKeyRef
+				add(ref.Name, UsageEnvKey, ref.Key, ref.Optional)
+			}
+		}
+		for _, from := range envFrom {
+			if from.ConfigMapRef != nil {
+				add(from.ConfigMapRef.Name, UsageEnvFrom, "", from.ConfigMapRef.Optional)
+			}
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	for _, c := range pod.Spec.Containers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	return uses
+}
+
+// podConfigMapRelationships returns one relationship per ConfigMap pod uses:
+// MOUNTS if it is mounted as a volume, USES_CONFIG otherwise, with its
+// usages and the keys its environment reads. All are kept, optional ones
+// included, so references to absent ConfigMaps show; those the pod can't
+// start without are checked by deps.
+func podConfigMapRelationships(pod *corev1.Pod, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	var rels []GraphRelationship
+	for _, use := range podConfigMapUses(pod) {
+		relType := "USES_CONFIG"
+		if slices.Contains(use.usages, UsageVolume) {
+			relType = "MOUNTS"
+		}
+		props := map[string]string{UsageProperty: joinSorted(use.usages)}
+		if len(use.keys) > 0 {
+			props[KeyProperty] = joinSorted(use.keys)
+		}
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: use.name, Namespace: pod.Namespace, Kind: "ConfigMap"},
+			RelationshipType: relType,
+			Properties:       props,
+			Revision:         revision,
+		}
+		if use.required {
+			deps.check(&rel)
+		}
+		rels = append(rels, rel)
+	}
+	return rels
+}
@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// OOMKilledProperty is set to "true" on a pod with a container whose current
+// or last termination reason is OOMKilled.
+const OOMKilledProperty = "oomKilled"
+
+// oomKilledReason is the termination reason of a container killed for
+// exceeding its memory limit.
+const oomKilledReason = "OOMKilled"
+
+// addContainerStatusProperties sets, for every container status, its state,
+// the reason it is waiting or terminated, its restart count and its last
+// termination, as containers.<name>.* properties, plus the pod's total
+// restartCount, the time of its most recent container termination
+// (lastTerminated) and oomKilled. Restarts within a time window are not
+// derivable from a single object.
+func addContainerStatusProperties(props map[string]string, statuses []corev1.ContainerStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+	var restarts int32
+	var lastTerminated *corev1.ContainerStateTerminated
+	for _, cs := range statuses {
+		prefix := "containers." + cs.Name + "."
+		restarts += cs.RestartCount
+		props[prefix+"restartCount"] = formatInt(cs.RestartCount)
+
+		switch {
+		case cs.State.Running != nil:
+			props[prefix+"state"] = "running"
+		case cs.State.Waiting != nil:
+			props[prefix+"state"] = "waiting"
+			props[prefix+"reason"] = cs.State.Waiting.Reason
+		case cs.State.Terminated != nil:
+			props[prefix+"state"] = "terminated"
+			props[prefix+"reason"] = cs.State.Terminated.Reason
+			props[prefix+"exitCode"] = strconv.Itoa(int(cs.State.Terminated.ExitCode))
+			if cs.State.Terminated.Reason == oomKilledReason {
+				props[OOMKilledProperty] = "true"
+			}
+		}
+
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			props[prefix+"lastTerminated.reason"] = t.Reason
+			props[prefix+"lastTerminated.exitCode"] = strconv.Itoa(int(t.ExitCode))
+			if !t.FinishedAt.IsZero() {
+				props[prefix+"lastTerminated.finishedAt"] = timePtrToString(&t.FinishedAt)
+			}
+			if t.Reason == oomKilledReason {
+				props[OOMKilledProperty] = "true"
+			}
+			if lastTerminated == nil || t.FinishedAt.After(lastTerminated.FinishedAt.Time) {
+				lastTerminated = t
+			}
+		}
+	}
+	props["restartCount"] = formatInt(restarts)
+	if lastTerminated != nil && !lastTerminated.FinishedAt.IsZero() {
+		props["lastTerminated"] = timePtrToString(&lastTerminated.FinishedAt)
+	}
+}
@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"maps"
+	"sort"
+)
+
+// Delta is the difference between two builds of the graph. It is the wire
+// format for incremental updates: applying it to the graph at FromRevision
+// yields the graph at ToRevision.
+type Delta struct {
+	FromRevision         uint64               `json:"fromRevision"`
+	ToRevision           uint64               `json:"toRevision"`
+	AddedNodes           []GraphNode          `json:"addedNodes,omitempty"`
+	UpdatedNodes         []NodeUpdate         `json:"updatedNodes,omitempty"`
+	RemovedNodes         []GraphEntityKey     `json:"removedNodes,omitempty"`
+	AddedRelationships   []GraphRelationship  `json:"addedRelationships,omitempty"`
+	UpdatedRelationships []RelationshipUpdate `json:"updatedRelationships,omitempty"`
+	RemovedRelationships []GraphRelationship  `json:"removedRelationships,omitempty"`
+}
+
+// NodeUpdate is the new state of a changed node and the property keys that
+// were added, removed or modified.
+type NodeUpdate struct {
+	GraphNode
+	ChangedKeys []string `json:"changedKeys"`
+}
+
+// RelationshipUpdate is the new state of a changed relationship and the
+// property keys that were added, removed or modified.
+type RelationshipUpdate struct {
+	GraphRelationship
+	ChangedKeys []string `json:"changedKeys"`
+}
+
+// Empty reports whether the delta contains no changes.
+func (d Delta) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.UpdatedNodes) == 0 && len(d.RemovedNodes) == 0 &&
+		len(d.AddedRelationships) == 0 && len(d.UpdatedRelationships) == 0 && len(d.RemovedRelationships) == 0
+}
+
+// relationshipKey identifies a relationship independently of its properties.
+type relationshipKey struct {
+	Source, Target GraphEntityKey
+	Type           string
+}
+
+func relKey(r GraphRelationship) relationshipKey {
+	return relationshipKey{Source: r.Source, Target: r.Target, Type: r.RelationshipType}
+}
+
+// Diff computes the delta from prev to next. Nodes are matched by key and
+// relationships by (source, target, type); either is "updated" when its
+// properties changed. Revision numbers alone don't count as a change.
+func Diff(prev, next Graph) Delta {
+	d := Delta{FromRevision: prev.GraphRevision, ToRevision: next.GraphRevision}
+
+	prevNodes := make(map[GraphEntityKey]GraphNode, len(prev.Nodes))
+	for _, n := range prev.Nodes {
+		prevNodes[n.Key] = n
+	}
+	for _, n := range next.Nodes {
+		old, ok := prevNodes[n.Key]
+		switch {
+		case !ok:
+			d.AddedNodes = append(d.AddedNodes, n)
+		case !maps.Equal(old.Properties, n.Properties):
+			d.UpdatedNodes = append(d.UpdatedNodes, NodeUpdate{GraphNode: n, ChangedKeys: changedKeys(old.Properties, n.Properties)})
+		}
+		delete(prevNodes, n.Key)
+	}
+	for _, n := range prev.Nodes {
+		if _, gone := prevNodes[n.Key]; gone {
+			d.RemovedNodes = append(d.RemovedNodes, n.Key)
+		}
+	}
+
+	prevRels := make(map[relationshipKey]GraphRelationship, len(prev.Relationships))
+	for _, r := range prev.Relationships {
+		prevRels[relKey(r)] = r
+	}
+	for _, r := range next.Relationships {
+		old, ok := prevRels[relKey(r)]
+		switch {
+		case !ok:
+			d.AddedRelationships = append(d.AddedRelationships, r)
+		case !maps.Equal(old.Properties, r.Properties):
+			d.UpdatedRelationships = append(d.UpdatedRelationships, RelationshipUpdate{GraphRelationship: r, ChangedKeys: changedKeys(old.Properties, r.Properties)})
+		}
+		delete(prevRels, relKey(r))
+	}
+	for _, r := range prev.Relationships {
+		if _, gone := prevRels[relKey(r)]; gone {
+			d.RemovedRelationships = append(d.RemovedRelationships, r)
+		}
+	}
+	return d
+}
+
+// changedKeys returns the sorted keys whose presence or value differs.
+func changedKeys(old, new map[string]string) []string {
+	var keys []string
+	for k, v := range new {
+		if ov, ok := old[k]; !ok || ov != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyDelta returns g with d applied, without modifying g: the graph Diff
+// was computed against, up to ordering and revision numbers. Nodes and
+// relationships keep their order; added ones are appended unless already
+// present, as Diff reports repeats of a relationship as added.
+func ApplyDelta(g Graph, d Delta) Graph {
+	out := Graph{
+		Nodes:         make([]GraphNode, 0, max(len(g.Nodes)+len(d.AddedNodes)-len(d.RemovedNodes), 0)),
+		Relationships: make([]GraphRelationship, 0, max(len(g.Relationships)+len(d.AddedRelationships)-len(d.RemovedRelationships), 0)),
+		GraphRevision: d.ToRevision,
+		Metadata:      g.Metadata,
+		index:         new(lazyIndex),
+	}
+
+	removedNodes := make(map[GraphEntityKey]bool, len(d.RemovedNodes))
+	for _, k := range d.RemovedNodes {
+		removedNodes[k] = true
+	}
+	updatedNodes := make(map[GraphEntityKey]GraphNode, len(d.UpdatedNodes))
+	for _, u := range d.UpdatedNodes {
+		updatedNodes[u.Key] = u.GraphNode
+	}
+	for _, n := range g.Nodes {
+		if removedNodes[n.Key] {
+			continue
+		}
+		if u, ok := updatedNodes[n.Key]; ok {
+			n = u
+		}
+		out.Nodes = append(out.Nodes, n)
+	}
+	out.Nodes = append(out.Nodes, d.AddedNodes...)
+
+	removedRels := make(map[relationshipKey]bool, len(d.RemovedRelationships))
+	for _, r := range d.RemovedRelationships {
+		removedRels[relKey(r)] = true
+	}
+	updatedRels := make(map[relationshipKey]GraphRelationship, len(d.UpdatedRelationships))
+	for _, u := range d.UpdatedRelationships {
+		updatedRels[relKey(u.GraphRelationship)] = u.GraphRelationship
+	}
+	present := make(map[relationshipKey]bool, len(g.Relationships))
+	for _, r := range g.Relationships {
+		if removedRels[relKey(r)] {
+			continue
+		}
+		if u, ok := updatedRels[relKey(r)]; ok {
+			r = u
+		}
+		out.Relationships = append(out.Relationships, r)
+		present[relKey(r)] = true
+	}
+	for _, r := range d.AddedRelationships {
+		if !present[relKey(r)] {
+			out.Relationships = append(out.Relationships, r)
+		}
+	}
+	return out
+}
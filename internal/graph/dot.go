@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// dotColors fills the nodes of the common kinds; other kinds stay white.
+var dotColors = map[string]string{
+	"Pod":         "lightblue",
+	"ReplicaSet":  "lightgoldenrod",
+	"Deployment":  "orange",
+	"StatefulSet": "darkorange",
+	"DaemonSet":   "sandybrown",
+	"Service":     "palegreen",
+	"Ingress":     "mediumseagreen",
+	"ConfigMap":   "lightgrey",
+	"Node":        "plum",
+	"Namespace":   "lightpink",
+}
+
+// WriteDOT renders g in Graphviz DOT, e.g. for `dot -Tpng`: one box per node
+// labelled with its kind and name, one edge per relationship labelled with
+// its type.
+func WriteDOT(w io.Writer, g Graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph satellite {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, `  node [shape=box, style="rounded,filled", fillcolor=white, fontname="Helvetica"];`)
+	fmt.Fprintln(bw, `  edge [fontname="Helvetica", fontsize=10];`)
+	for _, node := range g.Nodes {
+		label := node.Key.Kind + "\n" + node.Key.Name
+		if node.Key.Namespace != "" {
+			label = node.Key.Kind + "\n" + node.Key.Namespace + "/" + node.Key.Name
+		}
+		attrs := "label=" + strconv.Quote(label)
+		if color, ok := dotColors[node.Key.Kind]; ok {
+			attrs += ", fillcolor=" + color
+		}
+		if node.Properties[ExternalProperty] == "true" {
+			attrs += `, style="rounded,dashed"`
+		}
+		fmt.Fprintf(bw, "  %s [%s];\n", dotID(node.Key), attrs)
+	}
+	for _, rel := range g.Relationships {
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotID(rel.Source), dotID(rel.Target), strconv.Quote(rel.RelationshipType))
+	}
+	fmt.Fprintln(bw, "}")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write DOT graph: %w", err)
+	}
+	return nil
+}
+
+// dotID returns the quoted DOT identifier of key.
+func dotID(key GraphEntityKey) string {
+	id := key.Kind + "/" + key.Namespace + "/" + key.Name
+	if key.APIGroup != "" {
+		id = key.APIGroup + "/" + id
+	}
+	if key.Cluster != "" {
+		id = key.Cluster + ":" + id
+	}
+	return strconv.Quote(id)
+}
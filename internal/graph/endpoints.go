@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"strconv"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// addEndpointSliceProperties sets an EndpointSlice's service, address type
+// and how many of its endpoints there are and are ready.
+func addEndpointSliceProperties(props map[string]string, slice *discoveryv1.EndpointSlice) {
+	props["service"] = slice.Labels[discoveryv1.LabelServiceName]
+	props["addressType"] = string(slice.AddressType)
+	ready := 0
+	for _, ep := range slice.Endpoints {
+		if endpointReady(ep) {
+			ready++
+		}
+	}
+	props["endpoints"] = formatInt(int32(len(slice.Endpoints)))
+	props["endpoints.ready"] = formatInt(int32(ready))
+}
+
+// endpointReady reads ep's ready condition, which is unknown, and to be
+// taken as ready, if unset.
+func endpointReady(ep discoveryv1.Endpoint) bool {
+	return ep.Conditions.Ready == nil || *ep.Conditions.Ready
+}
+
+// endpoint is a pod in rotation behind a Service, merged across the
+// Service's EndpointSlices, e.g. its IPv4 and IPv6 ones.
+type endpoint struct {
+	service, pod       GraphEntityKey
+	ready, terminating bool
+}
+
+// addEndpoints adds a ROUTES_TO relationship from each Service to each pod
+// its EndpointSlices list, with ready=true or false from the endpoint's
+// conditions and terminating=true for a pod shutting down. Unlike SELECTS,
+// they list the endpoints kube-proxy routes by, also for Services without a
+// selector, and only ready ones are in rotation.
+func addEndpoints(g *Graph, keyed []keyedObject, revision uint64) {
+	var endpoints []*endpoint
+	seen := make(map[[2]GraphEntityKey]*endpoint)
+	for _, ko := range keyed {
+		slice, ok := ko.obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			continue
+		}
+		name := slice.Labels[discoveryv1.LabelServiceName]
+		if name == "" {
+			continue
+		}
+		service := GraphEntityKey{Name: name, Namespace: slice.Namespace, Kind: "Service"}
+		for _, ep := range slice.Endpoints {
+			ref := ep.TargetRef
+			if ref == nil || ref.Kind != "Pod" || ref.Name == "" {
+				continue
+			}
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = slice.Namespace
+			}
+			pod := GraphEntityKey{Name: ref.Name, Namespace: namespace, Kind: "Pod"}
+			e, ok := seen[[2]GraphEntityKey{service, pod}]
+			if !ok {
+				e = &endpoint{service: service, pod: pod}
+				seen[[2]GraphEntityKey{service, pod}] = e
+				endpoints = append(endpoints, e)
+			}
+			e.ready = e.ready || endpointReady(ep)
+			e.terminating = e.terminating || ep.Conditions.Terminating != nil && *ep.Conditions.Terminating
+		}
+	}
+	for _, e := range endpoints {
+		props := map[string]string{"ready": strconv.FormatBool(e.ready)}
+		if e.terminating {
+			props["terminating"] = "true"
+		}
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           e.service,
+			Target:           e.pod,
+			RelationshipType: "ROUTES_TO",
+			Properties:       props,
+			Revision:         revision,
+		})
+	}
+}
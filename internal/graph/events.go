@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+)
+
+// eventKey returns the key of the node of ev.
+func eventKey(ev cache.RecentEvent) GraphEntityKey {
+	return GraphEntityKey{Name: ev.Name, Namespace: ev.Namespace, Kind: "Event"}
+}
+
+// eventNodes returns a node for each event, with its type, reason, message,
+// count and the time it last occurred.
+func eventNodes(events []cache.RecentEvent, revision uint64) []GraphNode {
+	nodes := make([]GraphNode, 0, len(events))
+	for _, ev := range events {
+		nodes = append(nodes, GraphNode{
+			Key: eventKey(ev),
+			Properties: map[string]string{
+				"type":          ev.Type,
+				"reason":        ev.Reason,
+				"message":       ev.Message,
+				"count":         strconv.Itoa(int(ev.Count)),
+				"lastTimestamp": ev.LastTime.UTC().Format(time.RFC3339),
+			},
+			Revision: revision,
+		})
+	}
+	return nodes
+}
+
+// addEventRelationships adds an ABOUT relationship from each event to the
+// object it involves, kept whether or not the object still exists.
+func addEventRelationships(g *Graph, events []cache.RecentEvent, revision uint64) {
+	for _, ev := range events {
+		inv := ev.InvolvedObject
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           eventKey(ev),
+			Target:           refKey(inv.APIVersion, inv.Kind, inv.Name, inv.Namespace),
+			RelationshipType: "ABOUT",
+			Revision:         revision,
+		})
+	}
+}
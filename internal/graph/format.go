@@ -0,0 +1,145 @@
+package graph
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// propertiesHint sizes the property map of a node; it covers the common
+// properties plus those of the kind with the most (Node).
+const propertiesHint = 16
+
+// formatBuffer is the scratch space of one formatting call.
+type formatBuffer struct {
+	keys []string
+	buf  []byte
+}
+
+// formatBuffers reuses scratch space across the formatting of every node;
+// only the final string is allocated.
+var formatBuffers = sync.Pool{New: func() any { return &formatBuffer{} }}
+
+// formatLabels renders m like labels.Set.String, e.g. "app=web,tier=api":
+// "key=value" pairs sorted as whole strings.
+func formatLabels(m map[string]string) string {
+	return formatPairs(m, func(a, b string) int {
+		return comparePairs(a, m[a], b, m[b])
+	}, nil)
+}
+
+// formatAnnotations renders m as "key=value" pairs in map order, eliding
+// long values.
+func formatAnnotations(m map[string]string) string {
+	return formatPairs(m, nil, k8s.ElideValue)
+}
+
+// formatMatchLabels renders a selector of only matchLabels like
+// metav1.LabelSelectorAsSelector's String: "key=value" pairs sorted by key.
+func formatMatchLabels(m map[string]string) string {
+	return formatPairs(m, strings.Compare, nil)
+}
+
+// formatPairs joins the "key=value" pairs of m with commas, ordering the keys
+// with cmp and mapping the values with value, if set.
+func formatPairs(m map[string]string, cmp func(a, b string) int, value func(string) string) string {
+	fb := formatBuffers.Get().(*formatBuffer)
+	defer formatBuffers.Put(fb)
+
+	fb.keys = fb.keys[:0]
+	for k := range m {
+		fb.keys = append(fb.keys, k)
+	}
+	if cmp != nil {
+		slices.SortFunc(fb.keys, cmp)
+	}
+	fb.buf = fb.buf[:0]
+	for i, k := range fb.keys {
+		if i > 0 {
+			fb.buf = append(fb.buf, ',')
+		}
+		fb.buf = append(fb.buf, k...)
+		fb.buf = append(fb.buf, '=')
+		if value != nil {
+			fb.buf = append(fb.buf, value(m[k])...)
+		} else {
+			fb.buf = append(fb.buf, m[k]...)
+		}
+	}
+	return string(fb.buf)
+}
+
+// comparePairs compares ak+"="+av with bk+"="+bv without building either.
+func comparePairs(ak, av, bk, bv string) int {
+	n := min(len(ak), len(bk))
+	if c := strings.Compare(ak[:n], bk[:n]); c != 0 {
+		return c
+	}
+	switch {
+	case len(ak) == len(bk):
+		return strings.Compare(av, bv)
+	case len(ak) < len(bk) && bk[n] != '=':
+		// a continues with "=", b with the rest of its key
+		return strings.Compare("=", bk[n:n+1])
+	case len(ak) > len(bk) && ak[n] != '=':
+		return strings.Compare(ak[n:n+1], "=")
+	}
+	// keys containing "=" aren't valid labels; compare the pairs in full
+	return strings.Compare(ak+"="+av, bk+"="+bv)
+}
+
+// simpleSelector reports whether sel has only valid matchLabels, so
+// formatMatchLabels renders it without building a labels.Selector.
+func simpleSelector(sel *metav1.LabelSelector) bool {
+	if len(sel.MatchExpressions) > 0 {
+		return false
+	}
+	for k, v := range sel.MatchLabels {
+		if len(validation.IsQualifiedName(k)) > 0 || len(validation.IsValidLabelValue(v)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxDataKeys is the number of keys the data.keys property lists before
+// summarizing the rest as "+N more".
+const MaxDataKeys = 32
+
+// formatDataKeys renders the sorted keys of data, at most MaxDataKeys of
+// them, e.g. "a.lua,b.lua,+40 more".
+func formatDataKeys[V any](data map[string]V) string {
+	fb := formatBuffers.Get().(*formatBuffer)
+	defer formatBuffers.Put(fb)
+
+	fb.keys = fb.keys[:0]
+	for k := range data {
+		fb.keys = append(fb.keys, k)
+	}
+	slices.Sort(fb.keys)
+	fb.buf = fb.buf[:0]
+	for i, k := range fb.keys {
+		if i > 0 {
+			fb.buf = append(fb.buf, ',')
+		}
+		if i == MaxDataKeys {
+			fb.buf = append(fb.buf, '+')
+			fb.buf = strconv.AppendInt(fb.buf, int64(len(fb.keys)-MaxDataKeys), 10)
+			fb.buf = append(fb.buf, " more"...)
+			break
+		}
+		fb.buf = append(fb.buf, k...)
+	}
+	return string(fb.buf)
+}
+
+// formatInt renders n in decimal.
+func formatInt(n int32) string {
+	return strconv.FormatInt(int64(n), 10)
+}
@@ -1,20 +1,41 @@
 package graph
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-
-	"satellite/internal/cache"
-	"satellite/internal/k8s"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/ratelog"
+	"github.com/tthuwng/satellite/internal/shard"
+	"github.com/tthuwng/satellite/internal/timing"
+	"github.com/tthuwng/satellite/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Exported GraphEntityKey
@@ -22,6 +43,8 @@ type GraphEntityKey struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace,omitempty"`
 	Kind      string `json:"kind"`
+	APIGroup  string `json:"apiGroup,omitempty"` // empty for the core API group
+	Cluster   string `json:"cluster,omitempty"`
 }
 
 // Exported GraphNode
@@ -45,33 +68,317 @@ type Graph struct {
 	Nodes         []GraphNode         `json:"nodes"`
 	Relationships []GraphRelationship `json:"relationships"`
 	GraphRevision uint64              `json:"graphRevision"`
+	Metadata      *GraphMetadata      `json:"metadata,omitempty"`
+
+	index *lazyIndex // backs the query methods, see indexed
+}
+
+// GraphMetadata describes how the graph was produced, e.g. which kinds are
+// missing from it.
+type GraphMetadata struct {
+	ClusterName   string       `json:"clusterName,omitempty"`
+	DisabledKinds []string     `json:"disabledKinds,omitempty"`
+	Shard         *shard.Shard `json:"shard,omitempty"` // Set when this is a partial, sharded graph
+	BuiltAt       time.Time    `json:"builtAt,omitzero"`
+	// StaleKinds are kinds whose watch is failing, so their part of the
+	// graph may be out of date.
+	StaleKinds []string `json:"staleKinds,omitempty"`
+	// UnsyncedKinds are kinds that had not completed their initial sync
+	// when the sync timeout expired, so they are missing from the graph.
+	UnsyncedKinds []string `json:"unsyncedKinds,omitempty"`
+	// EmittedAt is when the graph was handed to the sinks.
+	EmittedAt time.Time `json:"emittedAt,omitzero"`
+	// MissingDependencies counts the relationships flagged missingTarget:
+	// required references to absent objects of watched kinds.
+	MissingDependencies int `json:"missingDependencies,omitempty"`
+	// ResourceVersions is the highest resourceVersion the cache had seen
+	// per kind: the graph is accurate for each kind as of its version.
+	ResourceVersions map[string]string `json:"perKindResourceVersion,omitempty"`
+	// Bookmarks is when each kind's watch last confirmed being current
+	// without an object change.
+	Bookmarks map[string]time.Time `json:"bookmarks,omitempty"`
+	// Truncation, if set, records the nodes and relationships the size caps
+	// dropped: the graph is incomplete.
+	Truncation *Truncation `json:"truncation,omitempty"`
+	// Heartbeat marks a re-emit of an unchanged graph; consumers that already
+	// processed this revision can skip it.
+	Heartbeat bool `json:"heartbeat,omitempty"`
+}
+
+// Meta returns the graph's metadata block, creating it if needed.
+func (g *Graph) Meta() *GraphMetadata {
+	if g.Metadata == nil {
+		g.Metadata = &GraphMetadata{}
+	}
+	return g.Metadata
 }
 
 // Exported BuildGraph
-func BuildGraph(resourceCache *cache.ResourceCache, currentGraphRevision uint64) Graph {
+// Every pass works on one snapshot of the cache taken at the start, so the
+// graph reflects a single cache state even while informers keep updating it.
+// Returns ctx.Err() if the context is cancelled before the build completes.
+func BuildGraph(ctx context.Context, resourceCache *cache.ResourceCache, currentGraphRevision uint64, opts ...BuildOption) (Graph, error) {
+	return buildGraph(ctx, resourceCache.Snapshot, currentGraphRevision, opts)
+}
+
+// BuildGraphFromSnapshot builds the graph of a cache snapshot.
+func BuildGraphFromSnapshot(ctx context.Context, snap *cache.Snapshot, currentGraphRevision uint64, opts ...BuildOption) (Graph, error) {
+	return buildGraph(ctx, func() *cache.Snapshot { return snap }, currentGraphRevision, opts)
+}
+
+// buildOptions holds the BuildOptions of one build.
+type buildOptions struct {
+	annotations  *AnnotationProperties
+	relRules     *RelationshipRules
+	caps         SizeCaps
+	watched      []string
+	placeholders bool
+}
+
+// BuildOption configures a graph build.
+type BuildOption func(*buildOptions)
+
+// WithAnnotationProperties promotes annotations to properties on every node.
+func WithAnnotationProperties(a *AnnotationProperties) BuildOption {
+	return func(o *buildOptions) { o.annotations = a }
+}
+
+// WithRelationshipRules adds the relationships rules derive.
+func WithRelationshipRules(r *RelationshipRules) BuildOption {
+	return func(o *buildOptions) { o.relRules = r }
+}
+
+// WithSizeCaps drops the nodes over caps, and their relationships,
+// recording the drops in the metadata.
+func WithSizeCaps(caps SizeCaps) BuildOption {
+	return func(o *buildOptions) { o.caps = caps }
+}
+
+// WithWatchedKinds lists the kinds whose objects are all in the cache, so
+// that required references to absent objects of these kinds are flagged
+// with MissingTargetProperty and counted in the metadata.
+func WithWatchedKinds(kinds []string) BuildOption {
+	return func(o *buildOptions) { o.watched = kinds }
+}
+
+// WithMissingPlaceholders adds a node flagged MissingProperty for every
+// missing target flagged.
+func WithMissingPlaceholders() BuildOption {
+	return func(o *buildOptions) { o.placeholders = true }
+}
+
+// MetadataOnlyProperty marks nodes of kinds watched metadata-only, whose
+// spec-, status- and data-derived properties are missing.
+const MetadataOnlyProperty = "metadataOnly"
+
+// Properties of objects that are being deleted: ones with a
+// deletionTimestamp and deleted ones the cache keeps for its linger window.
+const (
+	StateProperty             = "state"
+	DeletedProperty           = "deleted"
+	DeletionTimestampProperty = "deletionTimestamp"
+
+	// StateTerminating is the StateProperty of objects being deleted.
+	StateTerminating = "TERMINATING"
+)
+
+// keyedObject is a snapshot object with its keys, computed once per build.
+type keyedObject struct {
+	key      types.EntityKey
+	graphKey GraphEntityKey
+	obj      runtime.Object
+}
+
+// podLabel is one label of the pods of a namespace.
+type podLabel struct {
+	namespace, key, value string
+}
+
+// podIndex narrows the pods a Service or NetworkPolicy may select down to
+// those carrying the first label of its selector, instead of every pod of
+// the namespace. Pods are indexed only under the label keys some selector
+// starts with, and by namespace for selectors without labels to match.
+type podIndex struct {
+	byLabel     map[podLabel][]keyedObject
+	byNamespace map[string][]keyedObject
+}
+
+// creates the pod index for the Services and NetworkPolicies among keyed.
+func newPodIndex(keyed []keyedObject) podIndex {
+	selectorKeys := make(map[string]bool)
+	for _, ko := range keyed {
+		switch o := ko.obj.(type) {
+		case *corev1.Service:
+			if len(o.Spec.Selector) > 0 {
+				selectorKeys[firstKey(o.Spec.Selector)] = true
+			}
+		case *networkingv1.NetworkPolicy:
+			if len(o.Spec.PodSelector.MatchLabels) > 0 {
+				selectorKeys[firstKey(o.Spec.PodSelector.MatchLabels)] = true
+			}
+		}
+	}
+	idx := podIndex{byLabel: make(map[podLabel][]keyedObject), byNamespace: make(map[string][]keyedObject)}
+	for _, ko := range keyed {
+		pod, ok := ko.obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		idx.byNamespace[pod.Namespace] = append(idx.byNamespace[pod.Namespace], ko)
+		for k := range selectorKeys {
+			if v, ok := pod.Labels[k]; ok {
+				l := podLabel{namespace: pod.Namespace, key: k, value: v}
+				idx.byLabel[l] = append(idx.byLabel[l], ko)
+			}
+		}
+	}
+	return idx
+}
+
+// candidates returns the pods of namespace carrying the first label of
+// selector; the caller still matches the whole selector.
+func (idx podIndex) candidates(namespace string, selector map[string]string) []keyedObject {
+	k := firstKey(selector)
+	return idx.byLabel[podLabel{namespace: namespace, key: k, value: selector[k]}]
+}
+
+// selected returns the pods of namespace sel selects, matchExpressions
+// included; the empty selector selects every pod of the namespace. An
+// invalid selector selects none.
+func (idx podIndex) selected(namespace string, sel metav1.LabelSelector) []keyedObject {
+	selector, err := metav1.LabelSelectorAsSelector(&sel)
+	if err != nil {
+		ratelog.Default.Log(log.WithError(err), log.WarnLevel, "invalid selector", "BuildGraph: Invalid label selector")
+		return nil
+	}
+	candidates := idx.byNamespace[namespace]
+	if len(sel.MatchLabels) > 0 {
+		candidates = idx.candidates(namespace, sel.MatchLabels)
+	}
+	var out []keyedObject
+	for _, ko := range candidates {
+		if selector.Matches(labels.Set(ko.obj.(*corev1.Pod).Labels)) {
+			out = append(out, ko)
+		}
+	}
+	return out
+}
+
+// firstKey returns the smallest key of m.
+func firstKey(m map[string]string) string {
+	first, found := "", false
+	for k := range m {
+		if !found || k < first {
+			first, found = k, true
+		}
+	}
+	return first
+}
+
+// toGraphKey converts a cache key to a graph key.
+func toGraphKey(key types.EntityKey) GraphEntityKey {
+	return GraphEntityKey{Name: key.Name, Namespace: key.Namespace, Kind: key.Kind, APIGroup: key.APIGroup}
+}
+
+// ownerKey returns the key of the owner ref points to, in namespace unless
+// its kind is a watched cluster-scoped one, e.g. the Node of a mirror pod.
+func ownerKey(ref metav1.OwnerReference, namespace string) GraphEntityKey {
+	if k8s.KindClusterScoped(ref.Kind) {
+		namespace = ""
+	}
+	return refKey(ref.APIVersion, ref.Kind, ref.Name, namespace)
+}
+
+// ownerRelationships returns an OWNED_BY relationship from source to each
+// owner refs name, whatever its kind and whether or not it is watched, with
+// the controller and blockOwnerDeletion flags the reference sets.
+func ownerRelationships(refs []metav1.OwnerReference, namespace string, source GraphEntityKey, revision uint64) []GraphRelationship {
+	rels := make([]GraphRelationship, 0, len(refs))
+	for _, ref := range refs {
+		var props map[string]string
+		if ref.Controller != nil || ref.BlockOwnerDeletion != nil {
+			props = make(map[string]string, 2)
+			if ref.Controller != nil {
+				props["controller"] = strconv.FormatBool(*ref.Controller)
+			}
+			if ref.BlockOwnerDeletion != nil {
+				props["blockOwnerDeletion"] = strconv.FormatBool(*ref.BlockOwnerDeletion)
+			}
+		}
+		rels = append(rels, GraphRelationship{
+			Source:           source,
+			Target:           ownerKey(ref, namespace),
+			RelationshipType: "OWNED_BY",
+			Properties:       props,
+			Revision:         revision,
+		})
+	}
+	return rels
+}
+
+// refKey returns the key of the object of kind and name in namespace. The
+// group comes from apiVersion, or the watched kind's if it is empty.
+func refKey(apiVersion, kind, name, namespace string) GraphEntityKey {
+	group := k8s.KindGroup(kind)
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && apiVersion != "" {
+		group = gv.Group
+	}
+	return GraphEntityKey{Name: name, Namespace: namespace, Kind: kind, APIGroup: group}
+}
+
+// buildGraph builds the graph of the snapshot returned by takeSnapshot.
+func buildGraph(ctx context.Context, takeSnapshot func() *cache.Snapshot, currentGraphRevision uint64, opts []BuildOption) (_ Graph, err error) {
+	start := time.Now()
+	var o buildOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx, span := tracer.Start(ctx, "graph.BuildGraph", trace.WithAttributes(attribute.Int64("satellite.revision", int64(currentGraphRevision))))
+	defer func() { endSpan(span, err) }()
+
+	var phases timing.Breakdown
+	_, phase := tracer.Start(ctx, "graph.snapshot")
+	snap := takeSnapshot()
+	objects := snap.List()
+	phase.SetAttributes(attribute.Int("satellite.objects", len(objects)))
+	phase.End()
+	phaseStart := phases.Since(timing.BuildSnapshot, start)
+
+	// sized for every object and about two relationships each
 	graph := Graph{
-		Nodes:         make([]GraphNode, 0),
-		Relationships: make([]GraphRelationship, 0),
+		Nodes:         make([]GraphNode, 0, len(objects)),
+		Relationships: make([]GraphRelationship, 0, 2*len(objects)),
 		GraphRevision: currentGraphRevision,
+		index:         new(lazyIndex),
 	}
 
-	objects := resourceCache.List()
+	if rvs := snap.ResourceVersions(); len(rvs) > 0 {
+		graph.Meta().ResourceVersions = rvs
+	}
+	if bookmarks := snap.Bookmarks(); len(bookmarks) > 0 {
+		graph.Meta().Bookmarks = bookmarks
+	}
 
 	// --- Node building ---
+	_, phase = tracer.Start(ctx, "graph.nodes")
+	keyed := make([]keyedObject, 0, len(objects))
 	for _, obj := range objects {
 		key, ok := k8s.GetKey(obj)
 		if !ok {
-			log.Warnf("BuildGraph: Skipping object, could not get key for %T", obj)
+			typ := fmt.Sprintf("%T", obj)
+			ratelog.Default.Log(log.WithField("type", typ), log.WarnLevel, "skip "+typ, "BuildGraph: Skipping object, could not get key")
 			continue
 		}
 
-		graphKey := GraphEntityKey{
-			Name:      key.Name,
-			Namespace: key.Namespace,
-			Kind:      key.Kind,
-		}
+		graphKey := toGraphKey(key)
+		keyed = append(keyed, keyedObject{key: key, graphKey: graphKey, obj: obj})
 
-		properties := extractProperties(obj)
+		properties := extractProperties(obj, o.annotations)
+		deletedAt, deleted := snap.DeletedAt(key)
+		markTerminating(properties, obj, deletedAt, deleted)
+		if summary, ok := snap.Warnings(k8s.GetObjectMeta(obj).UID); ok {
+			addWarningProperties(properties, summary)
+		}
 
 		node := GraphNode{
 			Key:        graphKey,
@@ -80,45 +387,30 @@ func BuildGraph(resourceCache *cache.ResourceCache, currentGraphRevision uint64)
 		}
 		graph.Nodes = append(graph.Nodes, node)
 	}
-
-	// --- Relationship building ---
-	// lookups for efficient relationship finding
-	podMap := make(map[GraphEntityKey]*corev1.Pod)
-	for _, obj := range objects {
-		if pod, ok := obj.(*corev1.Pod); ok {
-			key, _ := k8s.GetKey(pod)
-			graphKey := GraphEntityKey{Name: key.Name, Namespace: key.Namespace, Kind: key.Kind}
-			podMap[graphKey] = pod
-		}
+	events := snap.Events()
+	graph.Nodes = append(graph.Nodes, eventNodes(events, currentGraphRevision)...)
+	phase.SetAttributes(attribute.Int("satellite.nodes", len(graph.Nodes)))
+	phase.End()
+	phaseStart = phases.Since(timing.BuildNodes, phaseStart)
+
+	if err := ctx.Err(); err != nil {
+		return Graph{}, err
 	}
 
-	for _, obj := range objects {
-		sourceKey, ok := k8s.GetKey(obj)
-		if !ok {
-			continue
-		}
-		sourceGraphKey := GraphEntityKey{Name: sourceKey.Name, Namespace: sourceKey.Namespace, Kind: sourceKey.Kind}
-
-		switch o := obj.(type) {
+	// --- Relationship building ---
+	_, phase = tracer.Start(ctx, "graph.relationships")
+	pods := newPodIndex(keyed)
+	deps := newDependencies(o.watched, o.placeholders, keyed)
+	for _, ko := range keyed {
+		sourceKey, sourceGraphKey := ko.key, ko.graphKey
+
+		// Any object -> its owners (OwnerReference), e.g. Pod -> ReplicaSet,
+		// ReplicaSet -> Deployment, Job -> CronJob
+		meta := k8s.GetObjectMeta(ko.obj)
+		graph.Relationships = append(graph.Relationships, ownerRelationships(meta.OwnerReferences, meta.Namespace, sourceGraphKey, currentGraphRevision)...)
+
+		switch o := ko.obj.(type) {
 		case *corev1.Pod:
-			// Pod -> ReplicaSet (OwnerReference)
-			// Pod -> Deployment (OwnerReference - indirect via ReplicaSet)
-			for _, ownerRef := range o.OwnerReferences {
-				if ownerRef.Kind == "ReplicaSet" || ownerRef.Kind == "Deployment" {
-					targetGraphKey := GraphEntityKey{
-						Name:      ownerRef.Name,
-						Namespace: o.Namespace,
-						Kind:      ownerRef.Kind,
-					}
-					graph.Relationships = append(graph.Relationships, GraphRelationship{
-						Source:           sourceGraphKey,
-						Target:           targetGraphKey,
-						RelationshipType: "OWNED_BY", // Pod is owned by RS/Deploy
-						Revision:         currentGraphRevision,
-					})
-				}
-			}
-
 			// Pod -> Node (Scheduled On)
 			if o.Spec.NodeName != "" {
 				targetGraphKey := GraphEntityKey{
@@ -133,55 +425,30 @@ func BuildGraph(resourceCache *cache.ResourceCache, currentGraphRevision uint64)
 				})
 			}
 
-			// Pod -> ConfigMap (Mounts Volume)
-			for _, vol := range o.Spec.Volumes {
-				if vol.ConfigMap != nil {
-					targetGraphKey := GraphEntityKey{
-						Name:      vol.ConfigMap.Name,
-						Namespace: o.Namespace,
-						Kind:      "ConfigMap",
-					}
-					graph.Relationships = append(graph.Relationships, GraphRelationship{
-						Source:           sourceGraphKey,
-						Target:           targetGraphKey,
-						RelationshipType: "MOUNTS",
-						Revision:         currentGraphRevision,
-					})
-				}
-			}
+			// Pod -> ConfigMap (Mounts Volume, Uses Config)
+			graph.Relationships = append(graph.Relationships, podConfigMapRelationships(o, sourceGraphKey, deps, currentGraphRevision)...)
 
-		case *appsv1.ReplicaSet:
-			// ReplicaSet -> Deployment (OwnerReference)
-			for _, ownerRef := range o.OwnerReferences {
-				if ownerRef.Kind == "Deployment" {
-					targetGraphKey := GraphEntityKey{
-						Name:      ownerRef.Name,
-						Namespace: o.Namespace,
-						Kind:      ownerRef.Kind,
-					}
-					graph.Relationships = append(graph.Relationships, GraphRelationship{
-						Source:           sourceGraphKey,
-						Target:           targetGraphKey,
-						RelationshipType: "OWNED_BY", // RS is owned by Deploy
-						Revision:         currentGraphRevision,
-					})
-				}
-			}
-			// ReplicaSet -> Pod (Owns) - Implicitly handled by Pod -> ReplicaSet
+			// Pod -> Secret (Mounts Volume, Uses Secret)
+			graph.Relationships = append(graph.Relationships, podSecretRelationships(o, sourceGraphKey, deps, currentGraphRevision)...)
 
-		case *appsv1.Deployment:
-			// Deployment -> ReplicaSet (Owns) - Implicitly handled by ReplicaSet -> Deployment
+			// Pod -> ServiceAccount (Runs As)
+			graph.Relationships = append(graph.Relationships, podServiceAccount(o, sourceGraphKey, deps, currentGraphRevision))
+
+			// Pod -> PersistentVolumeClaim (Uses)
+			graph.Relationships = append(graph.Relationships, podClaimRelationships(o, sourceGraphKey, deps, currentGraphRevision)...)
+
+			// Pod -> PriorityClass (Has Priority), -> RuntimeClass (Uses Runtime)
+			graph.Relationships = append(graph.Relationships, podClassRelationships(o, sourceGraphKey, deps, currentGraphRevision)...)
 
 		case *corev1.Service:
 			// Service -> Pod (Selector)
 			if o.Spec.Selector != nil && len(o.Spec.Selector) > 0 {
 				sel := labels.SelectorFromSet(o.Spec.Selector)
-				for podKey, pod := range podMap {
-					// Check namespace match before label match
-					if pod.Namespace == o.Namespace && sel.Matches(labels.Set(pod.Labels)) {
+				for _, pod := range pods.candidates(o.Namespace, o.Spec.Selector) {
+					if sel.Matches(labels.Set(pod.obj.(*corev1.Pod).Labels)) {
 						graph.Relationships = append(graph.Relationships, GraphRelationship{
 							Source:           sourceGraphKey,
-							Target:           podKey,
+							Target:           pod.graphKey,
 							RelationshipType: "SELECTS",
 							Revision:         currentGraphRevision,
 						})
@@ -189,21 +456,111 @@ func BuildGraph(resourceCache *cache.ResourceCache, currentGraphRevision uint64)
 				}
 			}
 
-			// Node and ConfigMap do not originate relationships in this model
+		case *networkingv1.Ingress:
+			// Ingress -> Service (Routes To), Ingress -> Secret (Uses TLS)
+			graph.Relationships = append(graph.Relationships, ingressRelationships(o, sourceGraphKey, deps, currentGraphRevision)...)
+
+		case *corev1.ResourceQuota, *corev1.LimitRange:
+			// ResourceQuota, LimitRange -> Namespace (Scopes)
+			graph.Relationships = append(graph.Relationships, namespaceScope(sourceKey.Namespace, sourceGraphKey, currentGraphRevision))
+
+		case *networkingv1.NetworkPolicy:
+			// NetworkPolicy -> Pod (Applies To)
+			for _, pod := range pods.selected(o.Namespace, o.Spec.PodSelector) {
+				graph.Relationships = append(graph.Relationships, GraphRelationship{
+					Source:           sourceGraphKey,
+					Target:           pod.graphKey,
+					RelationshipType: "APPLIES_TO",
+					Revision:         currentGraphRevision,
+				})
+			}
+
+		case *rbacv1.RoleBinding:
+			// RoleBinding -> Role or ClusterRole (References), -> ServiceAccount (Grants To)
+			graph.Relationships = append(graph.Relationships, bindingRelationships(o.Namespace, o.RoleRef, o.Subjects, sourceGraphKey, deps, currentGraphRevision)...)
+
+		case *rbacv1.ClusterRoleBinding:
+			// ClusterRoleBinding -> ClusterRole (References), -> ServiceAccount (Grants To)
+			graph.Relationships = append(graph.Relationships, bindingRelationships("", o.RoleRef, o.Subjects, sourceGraphKey, deps, currentGraphRevision)...)
+
+		case *autoscalingv2.HorizontalPodAutoscaler:
+			// HPA -> scale target, whether or not its kind is watched
+			ref := o.Spec.ScaleTargetRef
+			rel := GraphRelationship{
+				Source:           sourceGraphKey,
+				Target:           refKey(ref.APIVersion, ref.Kind, ref.Name, o.Namespace),
+				RelationshipType: "SCALES",
+				Properties:       hpaProperties(o),
+				Revision:         currentGraphRevision,
+			}
+			deps.check(&rel)
+			graph.Relationships = append(graph.Relationships, rel)
+
+		case *admissionregistrationv1.MutatingWebhookConfiguration:
+			// MutatingWebhookConfiguration -> Service or URL (Calls)
+			graph.Relationships = append(graph.Relationships, webhookRelationships(mutatingWebhooks(o), sourceGraphKey, deps, currentGraphRevision)...)
+
+		case *admissionregistrationv1.ValidatingWebhookConfiguration:
+			// ValidatingWebhookConfiguration -> Service or URL (Calls)
+			graph.Relationships = append(graph.Relationships, webhookRelationships(validatingWebhooks(o), sourceGraphKey, deps, currentGraphRevision)...)
+
+			// ConfigMap and Namespace do not originate relationships in this
+			// model; Node topology is added by addTopology
 		}
 	}
+	addRuleRelationships(&graph, keyed, o.relRules, currentGraphRevision)
+	addRollouts(&graph, keyed, currentGraphRevision)
+	addBindings(&graph, keyed, currentGraphRevision)
+	addEndpoints(&graph, keyed, currentGraphRevision)
+	addEventRelationships(&graph, events, currentGraphRevision)
+	addImages(&graph, keyed, currentGraphRevision)
+	addTopology(&graph, keyed, currentGraphRevision)
+	addWebhookURLs(&graph, currentGraphRevision)
+	addSpread(&graph, keyed)
+	deps.finish(&graph, currentGraphRevision)
+	truncation := truncate(&graph, o.caps)
+	phase.SetAttributes(attribute.Int("satellite.relationships", len(graph.Relationships)))
+	phase.End()
+	phaseStart = phases.Since(timing.BuildRelationships, phaseStart)
+
+	if err := ctx.Err(); err != nil {
+		return Graph{}, err
+	}
 
-	log.Infof("Built graph revision %d with %d nodes and %d relationships",
-		currentGraphRevision, len(graph.Nodes), len(graph.Relationships))
-
-	return graph
+	// --- Validation (only worth its cost when traced) ---
+	if _, phase = tracer.Start(ctx, "graph.validate"); phase.IsRecording() {
+		phase.SetAttributes(attribute.Int("satellite.dangling_relationships", DanglingRelationships(graph)))
+	}
+	phase.End()
+	phases.Since(timing.BuildValidate, phaseStart)
+
+	elapsed := time.Since(start)
+	timing.Default.RecordBuild(currentGraphRevision, elapsed, &phases)
+	metrics.BuildDuration.Observe(elapsed.Seconds())
+	metrics.GraphNodes.Set(float64(len(graph.Nodes)))
+	metrics.GraphRelationships.Set(float64(len(graph.Relationships)))
+	if o.caps.Enabled() {
+		reportTruncation(truncation, currentGraphRevision)
+	}
+	log.WithFields(log.Fields{
+		"revision":      currentGraphRevision,
+		"nodes":         len(graph.Nodes),
+		"relationships": len(graph.Relationships),
+		"duration":      elapsed,
+	}).Info("Built graph")
+	span.SetAttributes(
+		attribute.Int("satellite.nodes", len(graph.Nodes)),
+		attribute.Int("satellite.relationships", len(graph.Relationships)),
+	)
+
+	return graph, nil
 }
 
 func int32PtrToString(ptr *int32) string {
 	if ptr == nil {
 		return ""
 	}
-	return fmt.Sprintf("%d", *ptr)
+	return formatInt(*ptr)
 }
 
 func timePtrToString(ptr *metav1.Time) string {
@@ -213,24 +570,62 @@ func timePtrToString(ptr *metav1.Time) string {
 	return ptr.Format(time.RFC3339)
 }
 
-// converts relevant fields from a runtime.Object into a flat map.
-func extractProperties(obj runtime.Object) map[string]string {
-	props := make(map[string]string)
+// labelSelectorToString renders sel, including its matchExpressions, e.g.
+// "app=web,tier in (api,frontend)", for every kind with a LabelSelector. A
+// nil or invalid selector renders empty.
+func labelSelectorToString(sel *metav1.LabelSelector) string {
+	if sel == nil {
+		return ""
+	}
+	if simpleSelector(sel) {
+		return formatMatchLabels(sel.MatchLabels)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		ratelog.Default.Log(log.WithError(err), log.WarnLevel, "invalid selector", "extractProperties: Invalid label selector")
+		return ""
+	}
+	return selector.String()
+}
+
+// markTerminating sets the deletion properties of obj, which was deleted at
+// deletedAt if deleted is set.
+func markTerminating(props map[string]string, obj runtime.Object, deletedAt time.Time, deleted bool) {
+	ts := k8s.GetObjectMeta(obj).DeletionTimestamp
+	switch {
+	case ts != nil:
+		props[DeletionTimestampProperty] = ts.UTC().Format(time.RFC3339)
+	case deleted:
+		props[DeletionTimestampProperty] = deletedAt.UTC().Format(time.RFC3339)
+	default:
+		return
+	}
+	props[StateProperty] = StateTerminating
+	if deleted {
+		props[DeletedProperty] = "true"
+	}
+}
+
+// converts relevant fields from a runtime.Object into a flat map, then
+// promotes its annotations as configured.
+func extractProperties(obj runtime.Object, annotations *AnnotationProperties) map[string]string {
+	props := make(map[string]string, propertiesHint)
 	meta := k8s.GetObjectMeta(obj)
+	objAnnotations := meta.Annotations
+	if k8s.IsSecret(obj) {
+		// by kind, as metadata-only Secrets bypass the Secret transform
+		objAnnotations = secretAnnotations(objAnnotations)
+	}
 
 	// common properties
 	props["uid"] = string(meta.UID)
 	props["resourceVersion"] = meta.ResourceVersion
 	props["creationTimestamp"] = meta.CreationTimestamp.String()
 	if len(meta.Labels) > 0 {
-		props["labels"] = labels.Set(meta.Labels).String()
+		props[LabelsProperty] = formatLabels(meta.Labels)
 	}
-	if len(meta.Annotations) > 0 {
-		annoStrings := []string{}
-		for k, v := range meta.Annotations {
-			annoStrings = append(annoStrings, fmt.Sprintf("%s=%s", k, v))
-		}
-		props["annotations"] = strings.Join(annoStrings, ",")
+	if len(objAnnotations) > 0 {
+		props["annotations"] = formatAnnotations(objAnnotations)
 	}
 
 	// type-specific properties
@@ -241,29 +636,45 @@ func extractProperties(obj runtime.Object) map[string]string {
 		props["status.podIP"] = o.Status.PodIP
 		props["status.hostIP"] = o.Status.HostIP
 		props["status.startTime"] = timePtrToString(o.Status.StartTime)
+		addContainerStatusProperties(props, o.Status.ContainerStatuses)
 
 	case *appsv1.ReplicaSet:
 		props["spec.replicas"] = int32PtrToString(o.Spec.Replicas)
-		props["status.replicas"] = fmt.Sprintf("%d", o.Status.Replicas)
-		props["status.readyReplicas"] = fmt.Sprintf("%d", o.Status.ReadyReplicas)
-		props["status.availableReplicas"] = fmt.Sprintf("%d", o.Status.AvailableReplicas)
-		if o.Spec.Selector != nil {
-			props["spec.selector"] = labels.SelectorFromSet(o.Spec.Selector.MatchLabels).String()
-		} else {
-			props["spec.selector"] = ""
-		}
+		props["status.replicas"] = formatInt(o.Status.Replicas)
+		props["status.readyReplicas"] = formatInt(o.Status.ReadyReplicas)
+		props["status.availableReplicas"] = formatInt(o.Status.AvailableReplicas)
+		props["spec.selector"] = labelSelectorToString(o.Spec.Selector)
+		addRolloutProperties(props, meta.Annotations)
 
 	case *appsv1.Deployment:
 		props["spec.replicas"] = int32PtrToString(o.Spec.Replicas)
-		props["status.replicas"] = fmt.Sprintf("%d", o.Status.Replicas)
-		props["status.updatedReplicas"] = fmt.Sprintf("%d", o.Status.UpdatedReplicas)
-		props["status.readyReplicas"] = fmt.Sprintf("%d", o.Status.ReadyReplicas)
-		props["status.availableReplicas"] = fmt.Sprintf("%d", o.Status.AvailableReplicas)
-		if o.Spec.Selector != nil {
-			props["spec.selector"] = labels.SelectorFromSet(o.Spec.Selector.MatchLabels).String()
-		} else {
-			props["spec.selector"] = ""
-		}
+		props["status.replicas"] = formatInt(o.Status.Replicas)
+		props["status.updatedReplicas"] = formatInt(o.Status.UpdatedReplicas)
+		props["status.readyReplicas"] = formatInt(o.Status.ReadyReplicas)
+		props["status.availableReplicas"] = formatInt(o.Status.AvailableReplicas)
+		props["spec.selector"] = labelSelectorToString(o.Spec.Selector)
+
+	case *appsv1.StatefulSet:
+		props["spec.replicas"] = int32PtrToString(o.Spec.Replicas)
+		props["spec.serviceName"] = o.Spec.ServiceName
+		props["spec.podManagementPolicy"] = string(o.Spec.PodManagementPolicy)
+		props["spec.updateStrategy"] = string(o.Spec.UpdateStrategy.Type)
+		props["status.replicas"] = formatInt(o.Status.Replicas)
+		props["status.readyReplicas"] = formatInt(o.Status.ReadyReplicas)
+		props["status.currentReplicas"] = formatInt(o.Status.CurrentReplicas)
+		props["status.updatedReplicas"] = formatInt(o.Status.UpdatedReplicas)
+		props["status.availableReplicas"] = formatInt(o.Status.AvailableReplicas)
+		props["spec.selector"] = labelSelectorToString(o.Spec.Selector)
+
+	case *appsv1.DaemonSet:
+		props["spec.updateStrategy"] = string(o.Spec.UpdateStrategy.Type)
+		props["status.desiredNumberScheduled"] = formatInt(o.Status.DesiredNumberScheduled)
+		props["status.currentNumberScheduled"] = formatInt(o.Status.CurrentNumberScheduled)
+		props["status.updatedNumberScheduled"] = formatInt(o.Status.UpdatedNumberScheduled)
+		props["status.numberReady"] = formatInt(o.Status.NumberReady)
+		props["status.numberAvailable"] = formatInt(o.Status.NumberAvailable)
+		props["status.numberMisscheduled"] = formatInt(o.Status.NumberMisscheduled)
+		props["spec.selector"] = labelSelectorToString(o.Spec.Selector)
 
 	case *corev1.Node:
 		props["spec.podCIDR"] = o.Spec.PodCIDR
@@ -271,9 +682,14 @@ func extractProperties(obj runtime.Object) map[string]string {
 		props["status.capacity.memory"] = o.Status.Capacity.Memory().String()
 		props["status.allocatable.cpu"] = o.Status.Allocatable.Cpu().String()
 		props["status.allocatable.memory"] = o.Status.Allocatable.Memory().String()
+		props["status.capacity.ephemeral-storage"] = o.Status.Capacity.StorageEphemeral().String()
+		props["status.allocatable.ephemeral-storage"] = o.Status.Allocatable.StorageEphemeral().String()
+		props["status.capacity.pods"] = o.Status.Capacity.Pods().String()
+		props["status.allocatable.pods"] = o.Status.Allocatable.Pods().String()
 		props["status.nodeInfo.kubeletVersion"] = o.Status.NodeInfo.KubeletVersion
 		props["status.nodeInfo.osImage"] = o.Status.NodeInfo.OSImage
 		props["status.nodeInfo.containerRuntimeVersion"] = o.Status.NodeInfo.ContainerRuntimeVersion
+		addNodeLabelProperties(props, meta.Labels)
 
 	case *corev1.Service:
 		props["spec.type"] = string(o.Spec.Type)
@@ -282,21 +698,97 @@ func extractProperties(obj runtime.Object) map[string]string {
 			props["spec.clusterIPs"] = strings.Join(o.Spec.ClusterIPs, ",")
 		}
 		if o.Spec.Selector != nil {
-			props["spec.selector"] = labels.Set(o.Spec.Selector).String()
+			props["spec.selector"] = formatLabels(o.Spec.Selector)
+		}
+
+	case *networkingv1.Ingress:
+		addIngressProperties(props, o)
+
+	case *discoveryv1.EndpointSlice:
+		addEndpointSliceProperties(props, o)
+
+	case *networkingv1.NetworkPolicy:
+		addNetworkPolicyProperties(props, o)
+
+	case *corev1.Namespace:
+		props["status.phase"] = string(o.Status.Phase)
+
+	case *corev1.ServiceAccount:
+		addServiceAccountProperties(props, o)
+
+	case *corev1.ResourceQuota:
+		addQuotaProperties(props, o)
+
+	case *corev1.LimitRange:
+		addLimitRangeProperties(props, o)
+
+	case *corev1.PersistentVolumeClaim:
+		addClaimProperties(props, o)
+
+	case *corev1.PersistentVolume:
+		addVolumeProperties(props, o)
+
+	case *corev1.Secret:
+		addSecretProperties(props, o)
+
+	case *batchv1.Job:
+		addJobProperties(props, o)
+
+	case *batchv1.CronJob:
+		addCronJobProperties(props, o, time.Now())
+
+	case *rbacv1.Role:
+		addRuleProperties(props, o.Rules)
+
+	case *rbacv1.ClusterRole:
+		addRuleProperties(props, o.Rules)
+		if o.AggregationRule != nil {
+			// its rules are filled in by the aggregation controller
+			props["aggregated"] = "true"
+		}
+
+	case *rbacv1.RoleBinding:
+		addBindingProperties(props, o.RoleRef, o.Subjects)
+
+	case *rbacv1.ClusterRoleBinding:
+		addBindingProperties(props, o.RoleRef, o.Subjects)
+
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		props["spec.scaleTargetRef"] = o.Spec.ScaleTargetRef.Kind + "/" + o.Spec.ScaleTargetRef.Name
+		maps.Copy(props, hpaProperties(o))
+
+	case *schedulingv1.PriorityClass:
+		addPriorityClassProperties(props, o)
+
+	case *nodev1.RuntimeClass:
+		addRuntimeClassProperties(props, o)
+
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		addWebhookProperties(props, mutatingWebhooks(o))
+
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		addWebhookProperties(props, validatingWebhooks(o))
+
+	case *metav1.PartialObjectMetadata:
+		// watched metadata-only: spec, status and data are unknown
+		props[MetadataOnlyProperty] = "true"
+		if o.Kind == "ReplicaSet" {
+			addRolloutProperties(props, meta.Annotations)
 		}
 
 	case *corev1.ConfigMap:
 		if len(o.Data) > 0 {
-			keys := make([]string, 0, len(o.Data))
-			for k := range o.Data {
-				keys = append(keys, k)
-			}
-			props["data.keys"] = strings.Join(keys, ",")
+			props["data.keys"] = formatDataKeys(o.Data)
 		}
 
+	case *unstructured.Unstructured:
+		addUnstructuredProperties(props, o)
+
 	default:
-		log.Debugf("extractProperties: Unhandled type %T", obj)
+		typ := fmt.Sprintf("%T", obj)
+		ratelog.Default.Log(log.WithField("type", typ), log.DebugLevel, "unhandled "+typ, "extractProperties: Unhandled type")
 	}
 
+	annotations.apply(props, objAnnotations)
 	return props
 }
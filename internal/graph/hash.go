@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+)
+
+// ContentHash returns a hex SHA-256 over the graph's nodes, relationships and
+// their properties, independent of ordering and of revision numbers, so two
+// builds of an unchanged cluster hash identically.
+func ContentHash(g Graph) string {
+	h := sha256.New()
+
+	nodes := make([]GraphNode, len(g.Nodes))
+	copy(nodes, g.Nodes)
+	sort.Slice(nodes, func(i, j int) bool { return keyLess(nodes[i].Key, nodes[j].Key) })
+	for _, n := range nodes {
+		writeField(h, "N")
+		writeKey(h, n.Key)
+		writeProperties(h, n.Properties)
+	}
+
+	rels := make([]GraphRelationship, len(g.Relationships))
+	copy(rels, g.Relationships)
+	sort.Slice(rels, func(i, j int) bool { return relationshipLess(rels[i], rels[j]) })
+	for _, r := range rels {
+		writeField(h, "R")
+		writeKey(h, r.Source)
+		writeKey(h, r.Target)
+		writeField(h, r.RelationshipType)
+		writeProperties(h, r.Properties)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keyLess orders keys by cluster, kind, API group, namespace, name.
+func keyLess(a, b GraphEntityKey) bool {
+	if a.Cluster != b.Cluster {
+		return a.Cluster < b.Cluster
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.APIGroup != b.APIGroup {
+		return a.APIGroup < b.APIGroup
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// relationshipLess orders relationships by source, target, type.
+func relationshipLess(a, b GraphRelationship) bool {
+	if a.Source != b.Source {
+		return keyLess(a.Source, b.Source)
+	}
+	if a.Target != b.Target {
+		return keyLess(a.Target, b.Target)
+	}
+	return a.RelationshipType < b.RelationshipType
+}
+
+func writeKey(w io.Writer, k GraphEntityKey) {
+	writeField(w, k.Cluster)
+	writeField(w, k.Kind)
+	writeField(w, k.APIGroup)
+	writeField(w, k.Namespace)
+	writeField(w, k.Name)
+}
+
+func writeProperties(w io.Writer, props map[string]string) {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeField(w, k)
+		writeField(w, props[k])
+	}
+	writeField(w, "")
+}
+
+// writeField writes s NUL-terminated so adjacent fields can't run together.
+func writeField(w io.Writer, s string) {
+	_, _ = io.WriteString(w, s)
+	_, _ = w.Write([]byte{0})
+}
@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// historyKeyframeInterval is how often a revision is kept whole; the ones in
+// between are kept as deltas from their predecessor.
+const historyKeyframeInterval = 8
+
+// GraphHistory keeps recently built graphs in memory for time-travel
+// queries. Every historyKeyframeInterval-th revision is kept whole and the
+// others as deltas, rebuilt on demand from the nearest whole one before
+// them. Revisions are evicted oldest first once there are more than
+// maxRevisions or they take more than maxBytes; the newest is always kept.
+//
+// Rebuilt graphs have the content of the original (same ContentHash and
+// metadata), with every node and relationship at the graph's revision, but
+// nodes and relationships added since the keyframe are listed last.
+type GraphHistory struct {
+	maxRevisions int
+	maxBytes     int64
+
+	mu      sync.RWMutex
+	entries []historyEntry // oldest first
+	bytes   int64
+	last    Graph // the newest revision, whole, to diff the next one against
+}
+
+// historyEntry is one revision, whole (keyframe) or as the delta from the
+// entry before it.
+type historyEntry struct {
+	revision uint64
+	builtAt  time.Time
+	meta     *GraphMetadata
+	keyframe *Graph
+	delta    *Delta
+	size     int64
+}
+
+// NewGraphHistory returns a history of at most maxRevisions revisions
+// taking at most maxBytes (0 for no limit).
+func NewGraphHistory(maxRevisions int, maxBytes int64) *GraphHistory {
+	return &GraphHistory{maxRevisions: maxRevisions, maxBytes: maxBytes}
+}
+
+// Add records g, which must be newer than the revisions already recorded
+// and not be modified afterwards. Its metadata is copied.
+func (h *GraphHistory) Add(g Graph) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxRevisions <= 0 {
+		return
+	}
+	if n := len(h.entries); n > 0 && g.GraphRevision <= h.entries[n-1].revision {
+		return
+	}
+
+	e := historyEntry{revision: g.GraphRevision, builtAt: time.Now().UTC()}
+	if g.Metadata != nil {
+		meta := *g.Metadata
+		e.meta = &meta
+		if !meta.BuiltAt.IsZero() {
+			e.builtAt = meta.BuiltAt
+		}
+	}
+	if h.sinceKeyframe() >= historyKeyframeInterval {
+		e.keyframe = &g
+		e.size = graphSize(g)
+	} else {
+		d := Diff(h.last, g)
+		e.delta = &d
+		e.size = deltaSize(d)
+	}
+	h.entries = append(h.entries, e)
+	h.bytes += e.size
+	h.last = g
+	h.evict()
+}
+
+// sinceKeyframe returns the number of entries since the newest keyframe,
+// or historyKeyframeInterval if there is none.
+func (h *GraphHistory) sinceKeyframe() int {
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].keyframe != nil {
+			return len(h.entries) - i
+		}
+	}
+	return historyKeyframeInterval
+}
+
+// evict drops the oldest entries past the bounds, turning the entry after
+// a dropped keyframe into one.
+func (h *GraphHistory) evict() {
+	for len(h.entries) > 1 && (len(h.entries) > h.maxRevisions || h.maxBytes > 0 && h.bytes > h.maxBytes) {
+		if next := &h.entries[1]; next.keyframe == nil {
+			g := h.rebuild(1)
+			h.bytes -= next.size
+			next.keyframe, next.delta, next.size = &g, nil, graphSize(g)
+			h.bytes += next.size
+		}
+		h.bytes -= h.entries[0].size
+		h.entries[0] = historyEntry{}
+		h.entries = h.entries[1:]
+	}
+}
+
+// rebuild returns the graph of entries[i].
+func (h *GraphHistory) rebuild(i int) Graph {
+	start := i
+	for h.entries[start].keyframe == nil {
+		start--
+	}
+	g := *h.entries[start].keyframe
+	for _, e := range h.entries[start+1 : i+1] {
+		g = ApplyDelta(g, *e.delta)
+	}
+	e := h.entries[i]
+	g.Metadata = nil
+	if e.meta != nil {
+		meta := *e.meta
+		g.Metadata = &meta
+	}
+	if start == i {
+		return g
+	}
+	g.GraphRevision = e.revision
+	for j := range g.Nodes {
+		g.Nodes[j].Revision = e.revision
+	}
+	for j := range g.Relationships {
+		g.Relationships[j].Revision = e.revision
+	}
+	return g
+}
+
+// GetRevision returns the graph of revision, if it is in the history.
+func (h *GraphHistory) GetRevision(revision uint64) (Graph, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	i := sort.Search(len(h.entries), func(i int) bool { return h.entries[i].revision >= revision })
+	if i == len(h.entries) || h.entries[i].revision != revision {
+		return Graph{}, false
+	}
+	return h.rebuild(i), true
+}
+
+// GetAt returns the newest graph built at or before t, if the history goes
+// back that far.
+func (h *GraphHistory) GetAt(t time.Time) (Graph, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	i := sort.Search(len(h.entries), func(i int) bool { return h.entries[i].builtAt.After(t) })
+	if i == 0 {
+		return Graph{}, false
+	}
+	return h.rebuild(i - 1), true
+}
+
+// Revisions returns the recorded revisions, oldest first.
+func (h *GraphHistory) Revisions() []uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	revisions := make([]uint64, len(h.entries))
+	for i, e := range h.entries {
+		revisions[i] = e.revision
+	}
+	return revisions
+}
+
+// Bytes returns the approximate memory the recorded revisions take.
+func (h *GraphHistory) Bytes() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.bytes
+}
+
+// Per-item overheads of graphSize, roughly those of the Go values.
+const (
+	nodeOverhead         = 128
+	relationshipOverhead = 160
+	propertyOverhead     = 48
+)
+
+// graphSize approximates the memory g takes from its content size.
+func graphSize(g Graph) int64 {
+	var size int64
+	for _, n := range g.Nodes {
+		size += nodeOverhead + keySize(n.Key) + propertiesSize(n.Properties)
+	}
+	for _, r := range g.Relationships {
+		size += relationshipSize(r)
+	}
+	return size
+}
+
+// deltaSize approximates the memory d takes, like graphSize.
+func deltaSize(d Delta) int64 {
+	var size int64
+	for _, n := range d.AddedNodes {
+		size += nodeOverhead + keySize(n.Key) + propertiesSize(n.Properties)
+	}
+	for _, n := range d.UpdatedNodes {
+		size += nodeOverhead + keySize(n.Key) + propertiesSize(n.Properties)
+	}
+	for _, k := range d.RemovedNodes {
+		size += keySize(k)
+	}
+	for _, r := range d.AddedRelationships {
+		size += relationshipSize(r)
+	}
+	for _, r := range d.UpdatedRelationships {
+		size += relationshipSize(r.GraphRelationship)
+	}
+	for _, r := range d.RemovedRelationships {
+		size += relationshipSize(r)
+	}
+	return size
+}
+
+func relationshipSize(r GraphRelationship) int64 {
+	return relationshipOverhead + keySize(r.Source) + keySize(r.Target) + int64(len(r.RelationshipType)) + propertiesSize(r.Properties)
+}
+
+func keySize(k GraphEntityKey) int64 {
+	return int64(len(k.Cluster) + len(k.Kind) + len(k.APIGroup) + len(k.Namespace) + len(k.Name))
+}
+
+func propertiesSize(props map[string]string) int64 {
+	var size int64
+	for k, v := range props {
+		size += propertyOverhead + int64(len(k)+len(v))
+	}
+	return size
+}
@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"strconv"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// AtMaxReplicasProperty is set to "true" on an HPA, and its SCALES
+// relationship, whose current replicas reached its maxReplicas.
+const AtMaxReplicasProperty = "atMaxReplicas"
+
+// hpaProperties returns the replica bounds and status of an HPA: min, max,
+// current and desired replicas, lastScaleTime, the ScalingLimited condition
+// and, per metric, its target and current value as metrics.<metric>.target
+// and metrics.<metric>.current, e.g. metrics.resource.cpu.target=80%. They
+// are set on the HPA and on its SCALES relationship. Values missing from a
+// status not yet reported are left out.
+func hpaProperties(hpa *autoscalingv2.HorizontalPodAutoscaler) map[string]string {
+	props := map[string]string{
+		"spec.minReplicas":       int32PtrToString(hpa.Spec.MinReplicas),
+		"spec.maxReplicas":       formatInt(hpa.Spec.MaxReplicas),
+		"status.currentReplicas": formatInt(hpa.Status.CurrentReplicas),
+		"status.desiredReplicas": formatInt(hpa.Status.DesiredReplicas),
+		AtMaxReplicasProperty:    strconv.FormatBool(hpa.Spec.MaxReplicas > 0 && hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas),
+	}
+	if hpa.Status.LastScaleTime != nil {
+		props["status.lastScaleTime"] = timePtrToString(hpa.Status.LastScaleTime)
+	}
+	for _, cond := range hpa.Status.Conditions {
+		if cond.Type == autoscalingv2.ScalingLimited {
+			props["scalingLimited"] = strconv.FormatBool(cond.Status == corev1.ConditionTrue)
+			if cond.Reason != "" {
+				props["scalingLimitedReason"] = cond.Reason
+			}
+		}
+	}
+
+	for _, m := range hpa.Spec.Metrics {
+		if name, target, ok := metricTarget(m); ok {
+			props["metrics."+name+".target"] = target
+		}
+	}
+	for _, m := range hpa.Status.CurrentMetrics {
+		if name, current, ok := metricCurrent(m); ok {
+			props["metrics."+name+".current"] = current
+		}
+	}
+	return props
+}
+
+// metricTarget returns the name of m, e.g. resource.cpu,
+// containerResource.app.memory or external.queue_depth, and its target.
+func metricTarget(m autoscalingv2.MetricSpec) (string, string, bool) {
+	var name string
+	var target autoscalingv2.MetricTarget
+	switch {
+	case m.Resource != nil:
+		name, target = "resource."+string(m.Resource.Name), m.Resource.Target
+	case m.ContainerResource != nil:
+		name, target = "containerResource."+m.ContainerResource.Container+"."+string(m.ContainerResource.Name), m.ContainerResource.Target
+	case m.Pods != nil:
+		name, target = "pods."+m.Pods.Metric.Name, m.Pods.Target
+	case m.Object != nil:
+		name, target = "object."+m.Object.Metric.Name, m.Object.Target
+	case m.External != nil:
+		name, target = "external."+m.External.Metric.Name, m.External.Target
+	default:
+		return "", "", false
+	}
+	value := metricValue(target.AverageUtilization, target.AverageValue, target.Value)
+	return name, value, value != ""
+}
+
+// metricCurrent returns the name of m, as metricTarget does, and its current
+// value.
+func metricCurrent(m autoscalingv2.MetricStatus) (string, string, bool) {
+	var name string
+	var current autoscalingv2.MetricValueStatus
+	switch {
+	case m.Resource != nil:
+		name, current = "resource."+string(m.Resource.Name), m.Resource.Current
+	case m.ContainerResource != nil:
+		name, current = "containerResource."+m.ContainerResource.Container+"."+string(m.ContainerResource.Name), m.ContainerResource.Current
+	case m.Pods != nil:
+		name, current = "pods."+m.Pods.Metric.Name, m.Pods.Current
+	case m.Object != nil:
+		name, current = "object."+m.Object.Metric.Name, m.Object.Current
+	case m.External != nil:
+		name, current = "external."+m.External.Metric.Name, m.External.Current
+	default:
+		return "", "", false
+	}
+	value := metricValue(current.AverageUtilization, current.AverageValue, current.Value)
+	return name, value, value != ""
+}
+
+// metricValue renders a utilization as a percentage, e.g. 80%, or else the
+// average or total value, e.g. 500m.
+func metricValue(utilization *int32, average, total *resource.Quantity) string {
+	switch {
+	case utilization != nil:
+		return formatInt(*utilization) + "%"
+	case average != nil:
+		return average.String()
+	case total != nil:
+		return total.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultRegistry is the registry of image references naming none.
+const defaultRegistry = "docker.io"
+
+// imageRef is a parsed container image reference.
+type imageRef struct {
+	registry, repository, tag, digest string
+}
+
+// parseImage parses an image reference the way the container runtime
+// resolves it: without a registry (a first component with a dot, a colon or
+// localhost) it is on docker.io, where single-component repositories are
+// under library/, and without a tag or digest it is tagged latest.
+func parseImage(image string) imageRef {
+	var ref imageRef
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.tag = name[:i], name[i+1:]
+	}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.registry, name = first, rest
+	} else {
+		ref.registry = defaultRegistry
+		if !ok {
+			name = "library/" + name
+		}
+	}
+	ref.repository = name
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+	return ref
+}
+
+// String returns the normalized reference, e.g. docker.io/library/nginx:latest.
+func (r imageRef) String() string {
+	s := r.registry + "/" + r.repository
+	if r.tag != "" {
+		s += ":" + r.tag
+	}
+	if r.digest != "" {
+		s += "@" + r.digest
+	}
+	return s
+}
+
+// addImages adds a node for each distinct image the pods' containers run,
+// named by its normalized reference and carrying its registry, repository,
+// tag and digest, and a RUNS_IMAGE relationship from each pod to each of
+// its images with the containers running it.
+func addImages(g *Graph, keyed []keyedObject, revision uint64) {
+	images := make(map[string]bool)
+	for _, ko := range keyed {
+		pod, ok := ko.obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		containers := make(map[string][]string)
+		var order []string
+		use := func(name, image string) {
+			if image == "" {
+				return
+			}
+			ref := parseImage(image)
+			key := ref.String()
+			if !images[key] {
+				images[key] = true
+				props := map[string]string{"registry": ref.registry, "repository": ref.repository}
+				if ref.tag != "" {
+					props["tag"] = ref.tag
+				}
+				if ref.digest != "" {
+					props["digest"] = ref.digest
+				}
+				g.Nodes = append(g.Nodes, GraphNode{Key: GraphEntityKey{Name: key, Kind: "Image"}, Properties: props, Revision: revision})
+			}
+			if _, ok := containers[key]; !ok {
+				order = append(order, key)
+			}
+			containers[key] = append(containers[key], name)
+		}
+		for _, c := range pod.Spec.InitContainers {
+			use(c.Name, c.Image)
+		}
+		for _, c := range pod.Spec.Containers {
+			use(c.Name, c.Image)
+		}
+		for _, c := range pod.Spec.EphemeralContainers {
+			use(c.Name, c.Image)
+		}
+		for _, key := range order {
+			g.Relationships = append(g.Relationships, GraphRelationship{
+				Source:           ko.graphKey,
+				Target:           GraphEntityKey{Name: key, Kind: "Image"},
+				RelationshipType: "RUNS_IMAGE",
+				Properties:       map[string]string{"container": joinSorted(containers[key])},
+				Revision:         revision,
+			})
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package graph
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Index is a read-only lookup structure over one graph: nodes by key and,
+// for each node, the relationships touching it in either direction. Search
+// entries, kind buckets and parsed labels are built on first use.
+type Index struct {
+	graph     Graph
+	nodes     map[GraphEntityKey]int
+	adjacency map[GraphEntityKey][]int // relationship indexes
+
+	searchOnce sync.Once
+	search     []searchEntry // parallel to graph.Nodes
+	kindsOnce  sync.Once
+	kinds      map[string][]int // node indexes by kind
+	labelsOnce sync.Once
+	labels     []labels.Set // parallel to graph.Nodes
+}
+
+// creates an index over g. g must not be modified afterwards.
+func NewIndex(g Graph) *Index {
+	idx := &Index{
+		graph:     g,
+		nodes:     make(map[GraphEntityKey]int, len(g.Nodes)),
+		adjacency: make(map[GraphEntityKey][]int, len(g.Nodes)),
+	}
+	for i, n := range g.Nodes {
+		idx.nodes[n.Key] = i
+	}
+	for i, r := range g.Relationships {
+		idx.adjacency[r.Source] = append(idx.adjacency[r.Source], i)
+		if r.Target != r.Source {
+			idx.adjacency[r.Target] = append(idx.adjacency[r.Target], i)
+		}
+	}
+	return idx
+}
+
+// Graph returns the indexed graph.
+func (idx *Index) Graph() Graph {
+	return idx.graph
+}
+
+// Node returns the node with the given key.
+func (idx *Index) Node(key GraphEntityKey) (GraphNode, bool) {
+	i, ok := idx.nodes[key]
+	if !ok {
+		return GraphNode{}, false
+	}
+	return idx.graph.Nodes[i], true
+}
+
+// Neighborhood returns the subgraph induced by every node within depth hops
+// of key, following relationships in both directions. The bool is false if
+// key is not in the graph.
+func (idx *Index) Neighborhood(key GraphEntityKey, depth int) (Graph, bool) {
+	return idx.Traverse(key, depth, nil)
+}
+
+// induced returns the nodes in keep and every relationship between them, in
+// the original graph order.
+func (idx *Index) induced(keep map[GraphEntityKey]bool) Graph {
+	out := Graph{
+		GraphRevision: idx.graph.GraphRevision,
+		Metadata:      idx.graph.Metadata,
+		Nodes:         make([]GraphNode, 0, len(keep)),
+		Relationships: make([]GraphRelationship, 0),
+		index:         new(lazyIndex),
+	}
+	// gathered from the index rather than by scanning the graph, so small
+	// neighborhoods of large graphs are cheap
+	nodes := make([]int, 0, len(keep))
+	rels := make(map[int]bool)
+	for k := range keep {
+		if i, ok := idx.nodes[k]; ok {
+			nodes = append(nodes, i)
+		}
+		for _, ri := range idx.adjacency[k] {
+			r := idx.graph.Relationships[ri]
+			if keep[r.Source] && keep[r.Target] {
+				rels[ri] = true
+			}
+		}
+	}
+	slices.Sort(nodes)
+	for _, i := range nodes {
+		out.Nodes = append(out.Nodes, idx.graph.Nodes[i])
+	}
+	for _, ri := range slices.Sorted(maps.Keys(rels)) {
+		out.Relationships = append(out.Relationships, idx.graph.Relationships[ri])
+	}
+	return out
+}
+
+// Path is a walk between two nodes; Relationships[i] connects Nodes[i] and
+// Nodes[i+1] in either direction.
+type Path struct {
+	Nodes         []GraphNode         `json:"nodes"`
+	Relationships []GraphRelationship `json:"relationships"`
+}
+
+// step is one BFS predecessor: the node reached from, via relationship rel.
+type step struct {
+	from GraphEntityKey
+	rel  int
+}
+
+// ShortestPaths returns up to limit shortest paths from one node to another,
+// following relationships in both directions and at most maxDepth hops. The
+// bool is false if either endpoint is not in the graph; no path is an empty
+// result.
+func (idx *Index) ShortestPaths(from, to GraphEntityKey, maxDepth, limit int) ([]Path, bool) {
+	if _, ok := idx.nodes[from]; !ok {
+		return nil, false
+	}
+	if _, ok := idx.nodes[to]; !ok {
+		return nil, false
+	}
+	if from == to {
+		return []Path{{Nodes: []GraphNode{idx.graph.Nodes[idx.nodes[from]]}, Relationships: []GraphRelationship{}}}, true
+	}
+
+	// level-synchronous BFS recording every predecessor on a shortest route
+	depth := map[GraphEntityKey]int{from: 0}
+	preds := make(map[GraphEntityKey][]step)
+	frontier := []GraphEntityKey{from}
+	for d := 1; d <= maxDepth && len(frontier) > 0 && preds[to] == nil; d++ {
+		var next []GraphEntityKey
+		for _, k := range frontier {
+			for _, ri := range idx.adjacency[k] {
+				r := idx.graph.Relationships[ri]
+				other := r.Target
+				if other == k {
+					other = r.Source
+				}
+				if _, ok := idx.nodes[other]; !ok {
+					continue // dangling reference
+				}
+				seenAt, seen := depth[other]
+				if !seen {
+					depth[other] = d
+					next = append(next, other)
+				} else if seenAt != d {
+					continue
+				}
+				preds[other] = append(preds[other], step{from: k, rel: ri})
+			}
+		}
+		frontier = next
+	}
+	if preds[to] == nil {
+		return []Path{}, true
+	}
+
+	// walk predecessors back from the target
+	var paths []Path
+	var keys []GraphEntityKey
+	var rels []int
+	var walk func(k GraphEntityKey)
+	walk = func(k GraphEntityKey) {
+		if len(paths) >= limit {
+			return
+		}
+		keys = append(keys, k)
+		defer func() { keys = keys[:len(keys)-1] }()
+		if k == from {
+			paths = append(paths, idx.pathFrom(keys, rels))
+			return
+		}
+		for _, p := range preds[k] {
+			rels = append(rels, p.rel)
+			walk(p.from)
+			rels = rels[:len(rels)-1]
+		}
+	}
+	walk(to)
+	return paths, true
+}
+
+// pathFrom builds a Path from target-to-source key and relationship stacks.
+func (idx *Index) pathFrom(keys []GraphEntityKey, rels []int) Path {
+	p := Path{
+		Nodes:         make([]GraphNode, 0, len(keys)),
+		Relationships: make([]GraphRelationship, 0, len(rels)),
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		p.Nodes = append(p.Nodes, idx.graph.Nodes[idx.nodes[keys[i]]])
+	}
+	for i := len(rels) - 1; i >= 0; i-- {
+		p.Relationships = append(p.Relationships, idx.graph.Relationships[rels[i]])
+	}
+	return p
+}
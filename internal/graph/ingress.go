@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// IngressClassAnnotation is the class annotation Ingresses used before
+// spec.ingressClassName.
+const IngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// addIngressProperties sets an Ingress's class and the IPs and hostnames its
+// load balancer reports.
+func addIngressProperties(props map[string]string, ing *networkingv1.Ingress) {
+	if ing.Spec.IngressClassName != nil {
+		props["spec.ingressClassName"] = *ing.Spec.IngressClassName
+	} else if class, ok := ing.Annotations[IngressClassAnnotation]; ok {
+		props["spec.ingressClassName"] = class
+	}
+	var ips, hostnames []string
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			ips = append(ips, lb.IP)
+		}
+		if lb.Hostname != "" {
+			hostnames = append(hostnames, lb.Hostname)
+		}
+	}
+	if len(ips) > 0 {
+		props["status.loadBalancer.ips"] = strings.Join(ips, ",")
+	}
+	if len(hostnames) > 0 {
+		props["status.loadBalancer.hostnames"] = strings.Join(hostnames, ",")
+	}
+}
+
+// route collects the hosts and paths an Ingress routes to one Service.
+type route struct {
+	hosts, paths   []string
+	defaultBackend bool
+}
+
+// ingressRelationships returns a ROUTES_TO relationship from ing to each
+// Service it routes to, carrying the sorted hosts and paths of its rules
+// ("*" for a rule without host) and defaultBackend=true if it is the default
+// backend, and a USES_TLS relationship to each Secret its TLS section names,
+// with the hosts it serves. Both are kept whether or not their target is
+// watched.
+func ingressRelationships(ing *networkingv1.Ingress, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	routes := make(map[string]*route)
+	var services []string
+	routeTo := func(b *networkingv1.IngressBackend) *route {
+		if b == nil || b.Service == nil || b.Service.Name == "" {
+			return nil
+		}
+		r, ok := routes[b.Service.Name]
+		if !ok {
+			r = &route{}
+			routes[b.Service.Name] = r
+			services = append(services, b.Service.Name)
+		}
+		return r
+	}
+	if r := routeTo(ing.Spec.DefaultBackend); r != nil {
+		r.defaultBackend = true
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		for _, p := range rule.HTTP.Paths {
+			if r := routeTo(&p.Backend); r != nil {
+				r.hosts = append(r.hosts, host)
+				if p.Path != "" {
+					r.paths = append(r.paths, p.Path)
+				}
+			}
+		}
+	}
+
+	var rels []GraphRelationship
+	for _, name := range services {
+		r := routes[name]
+		props := make(map[string]string, 3)
+		if len(r.hosts) > 0 {
+			props["host"] = joinSorted(r.hosts)
+		}
+		if len(r.paths) > 0 {
+			props["path"] = joinSorted(r.paths)
+		}
+		if r.defaultBackend {
+			props["defaultBackend"] = "true"
+		}
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: name, Namespace: ing.Namespace, Kind: "Service"},
+			RelationshipType: "ROUTES_TO",
+			Properties:       props,
+			Revision:         revision,
+		}
+		deps.check(&rel)
+		rels = append(rels, rel)
+	}
+
+	tlsHosts := make(map[string][]string)
+	var secrets []string
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		if _, ok := tlsHosts[tls.SecretName]; !ok {
+			secrets = append(secrets, tls.SecretName)
+		}
+		tlsHosts[tls.SecretName] = append(tlsHosts[tls.SecretName], tls.Hosts...)
+	}
+	for _, name := range secrets {
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: name, Namespace: ing.Namespace, Kind: "Secret"},
+			RelationshipType: "USES_TLS",
+			Revision:         revision,
+		}
+		if hosts := tlsHosts[name]; len(hosts) > 0 {
+			rel.Properties = map[string]string{"host": joinSorted(hosts)}
+		}
+		deps.check(&rel)
+		rels = append(rels, rel)
+	}
+	return rels
+}
+
+// joinSorted returns the distinct values, sorted and comma-separated.
+func joinSorted(values []string) string {
+	values = slices.Clone(values)
+	sort.Strings(values)
+	return strings.Join(slices.Compact(values), ",")
+}
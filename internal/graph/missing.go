@@ -0,0 +1,76 @@
+package graph
+
+// MissingTargetProperty marks relationships whose required target is absent
+// although its kind is watched.
+const MissingTargetProperty = "missingTarget"
+
+// MissingProperty marks the placeholder nodes of missing targets.
+const MissingProperty = "missing"
+
+// dependencies flags relationships to objects that must exist, e.g. the
+// ConfigMap of a non-optional volume, whose target is absent from the
+// snapshot. Targets of kinds that aren't watched are never flagged, since
+// their absence says nothing.
+type dependencies struct {
+	watched      map[string]bool // nil: nothing is flagged
+	present      map[GraphEntityKey]bool
+	placeholders bool
+	missing      []GraphEntityKey // targets flagged, in first-seen order
+	seen         map[GraphEntityKey]bool
+	count        int
+}
+
+func newDependencies(watched []string, placeholders bool, keyed []keyedObject) *dependencies {
+	d := &dependencies{placeholders: placeholders}
+	if watched == nil {
+		return d
+	}
+	d.watched = make(map[string]bool, len(watched))
+	for _, kind := range watched {
+		d.watched[kind] = true
+	}
+	d.present = make(map[GraphEntityKey]bool, len(keyed))
+	for _, ko := range keyed {
+		d.present[ko.graphKey] = true
+	}
+	return d
+}
+
+// check marks rel with MissingTargetProperty if its target is watched but
+// absent.
+func (d *dependencies) check(rel *GraphRelationship) {
+	if !d.watched[rel.Target.Kind] || d.present[rel.Target] {
+		return
+	}
+	if rel.Properties == nil {
+		rel.Properties = make(map[string]string, 1)
+	}
+	rel.Properties[MissingTargetProperty] = "true"
+	d.count++
+	if d.seen == nil {
+		d.seen = make(map[GraphEntityKey]bool)
+	}
+	if !d.seen[rel.Target] {
+		d.seen[rel.Target] = true
+		d.missing = append(d.missing, rel.Target)
+	}
+}
+
+// finish records the count of missing dependencies in g's metadata and, if
+// enabled, adds a placeholder node for each missing target.
+func (d *dependencies) finish(g *Graph, revision uint64) {
+	if d.count == 0 {
+		return
+	}
+	g.Meta().MissingDependencies = d.count
+	if !d.placeholders {
+		return
+	}
+	for _, key := range d.missing {
+		g.Nodes = append(g.Nodes, GraphNode{
+			Key:        key,
+			Properties: map[string]string{MissingProperty: "true"},
+			Revision:   revision,
+		})
+	}
+}
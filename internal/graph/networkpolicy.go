@@ -0,0 +1,35 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// addNetworkPolicyProperties sets a NetworkPolicy's pod selector, the
+// directions it isolates and how many ingress and egress rules it has. An
+// empty spec.podSelector selects every pod of the namespace.
+func addNetworkPolicyProperties(props map[string]string, np *networkingv1.NetworkPolicy) {
+	props["spec.podSelector"] = labelSelectorToString(&np.Spec.PodSelector)
+	props["spec.policyTypes"] = policyTypes(np)
+	props["ingressRules"] = strconv.Itoa(len(np.Spec.Ingress))
+	props["egressRules"] = strconv.Itoa(len(np.Spec.Egress))
+}
+
+// policyTypes returns the policy types of np, defaulted as the API server
+// does for policies without: Ingress, plus Egress if it has egress rules.
+func policyTypes(np *networkingv1.NetworkPolicy) string {
+	types := np.Spec.PolicyTypes
+	if len(types) == 0 {
+		types = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		if len(np.Spec.Egress) > 0 {
+			types = append(types, networkingv1.PolicyTypeEgress)
+		}
+	}
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = string(t)
+	}
+	return strings.Join(out, ",")
+}
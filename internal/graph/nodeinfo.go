@@ -0,0 +1,50 @@
+package graph
+
+// InstanceTypeProperty is the Node property holding the cloud instance type,
+// which cost tables are keyed by.
+const InstanceTypeProperty = "instanceType"
+
+// HourlyCostProperty is the Node property holding the instance type's
+// hourly cost, stamped from a cost table.
+const HourlyCostProperty = "cost.hourly"
+
+// nodeLabelProperties maps Node properties to the labels they are read
+// from, most current label first.
+var nodeLabelProperties = []struct {
+	property string
+	labels   []string
+}{
+	{InstanceTypeProperty, []string{"node.kubernetes.io/instance-type", "beta.kubernetes.io/instance-type"}},
+	{"region", []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}},
+	{"zone", []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}},
+	{"hostname", []string{"kubernetes.io/hostname"}},
+	{"arch", []string{"kubernetes.io/arch", "beta.kubernetes.io/arch"}},
+	{"os", []string{"kubernetes.io/os", "beta.kubernetes.io/os"}},
+	// spot or on-demand, as set by Karpenter, EKS, GKE and AKS
+	{"capacityType", []string{"karpenter.sh/capacity-type", "eks.amazonaws.com/capacityType", "kubernetes.azure.com/scalesetpriority"}},
+	// the group the node was provisioned from
+	{"nodePool", []string{
+		"karpenter.sh/nodepool",
+		"karpenter.sh/provisioner-name",
+		"eks.amazonaws.com/nodegroup",
+		"alpha.eksctl.io/nodegroup-name",
+		"cloud.google.com/gke-nodepool",
+		"kubernetes.azure.com/agentpool",
+	}},
+}
+
+// addNodeLabelProperties sets the instance properties found in a Node's
+// labels.
+func addNodeLabelProperties(props map[string]string, labels map[string]string) {
+	for _, p := range nodeLabelProperties {
+		for _, label := range p.labels {
+			if value := labels[label]; value != "" {
+				props[p.property] = value
+				break
+			}
+		}
+	}
+	if props["capacityType"] == "" && labels["cloud.google.com/gke-spot"] == "true" {
+		props["capacityType"] = "spot"
+	}
+}
@@ -0,0 +1,214 @@
+package graph
+
+import (
+	"slices"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Direction selects which relationships of a node Neighbors follows.
+type Direction int
+
+const (
+	// Outgoing follows relationships from the node to their targets.
+	Outgoing Direction = iota
+	// Incoming follows relationships to the node from their sources.
+	Incoming
+	// Both follows relationships either way.
+	Both
+)
+
+// TraverseFilter reports whether Traverse follows rel to node. node is the
+// other end of rel; for a dangling reference it carries only its key.
+type TraverseFilter func(rel GraphRelationship, node GraphNode) bool
+
+// lazyIndex holds the Index behind the query methods of a graph, built on
+// the first query and shared by copies of the graph value.
+type lazyIndex struct {
+	mu  sync.Mutex
+	idx *Index
+}
+
+// indexed returns the index of g, building it if g has none yet or has
+// since been given other node or relationship slices. Graphs not built by
+// this package, e.g. decoded from JSON, have nowhere to keep one and get a
+// fresh index per query; see Indexed.
+func (g Graph) indexed() *Index {
+	if g.index == nil {
+		return NewIndex(g)
+	}
+	g.index.mu.Lock()
+	defer g.index.mu.Unlock()
+	if idx := g.index.idx; idx != nil && sameSlice(idx.graph.Nodes, g.Nodes) && sameSlice(idx.graph.Relationships, g.Relationships) {
+		return idx
+	}
+	g.index.idx = NewIndex(g)
+	return g.index.idx
+}
+
+// sameSlice reports whether a and b are the same slice of the same array.
+func sameSlice[T any](a, b []T) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// Indexed returns g with room for an index, so repeated queries on it and
+// its copies are cheap. Graphs built by this package already have one.
+func (g Graph) Indexed() Graph {
+	if g.index == nil {
+		g.index = new(lazyIndex)
+	}
+	return g
+}
+
+// MatchNodes returns the nodes of g of kind in namespace whose labels match
+// selector, in graph order. An empty kind or namespace, or a nil selector,
+// matches any.
+//
+// Like the other query methods, it indexes g on first use; nodes and
+// relationships must not be modified in place afterwards.
+func (g Graph) MatchNodes(kind, namespace string, selector labels.Selector) []GraphNode {
+	return g.indexed().MatchNodes(kind, namespace, selector)
+}
+
+// Neighbors returns the nodes related to key in direction dir, by
+// relationships of relTypes (any if none), in relationship order and
+// without duplicates. Dangling references are skipped.
+func (g Graph) Neighbors(key GraphEntityKey, dir Direction, relTypes ...string) []GraphNode {
+	return g.indexed().Neighbors(key, dir, relTypes...)
+}
+
+// Traverse returns the subgraph induced by the nodes reachable from start
+// within depth hops, following relationships in both directions that filter
+// (nil follows all) accepts. The bool is false if start is not in g.
+func (g Graph) Traverse(start GraphEntityKey, depth int, filter TraverseFilter) (Graph, bool) {
+	sub, ok := g.indexed().Traverse(start, depth, filter)
+	if ok {
+		sub.GraphRevision, sub.Metadata = g.GraphRevision, g.Metadata
+	}
+	return sub, ok
+}
+
+// nodeLabels returns the parsed labels of every node, built on first use.
+func (idx *Index) nodeLabels() []labels.Set {
+	idx.labelsOnce.Do(func() {
+		idx.labels = make([]labels.Set, len(idx.graph.Nodes))
+		for i, n := range idx.graph.Nodes {
+			idx.labels[i] = NodeLabels(n)
+		}
+	})
+	return idx.labels
+}
+
+// kindNodes returns the indexes of the nodes of kind, built on first use.
+func (idx *Index) kindNodes(kind string) []int {
+	idx.kindsOnce.Do(func() {
+		idx.kinds = make(map[string][]int)
+		for i, n := range idx.graph.Nodes {
+			idx.kinds[n.Key.Kind] = append(idx.kinds[n.Key.Kind], i)
+		}
+	})
+	return idx.kinds[kind]
+}
+
+// MatchNodes is Graph.MatchNodes on the indexed graph.
+func (idx *Index) MatchNodes(kind, namespace string, selector labels.Selector) []GraphNode {
+	var candidates []int
+	if kind != "" {
+		candidates = idx.kindNodes(kind)
+	}
+	var nodeLabels []labels.Set
+	if selector != nil && !selector.Empty() {
+		nodeLabels = idx.nodeLabels()
+	}
+	match := func(i int) bool {
+		n := idx.graph.Nodes[i]
+		if namespace != "" && n.Key.Namespace != namespace {
+			return false
+		}
+		return nodeLabels == nil || selector.Matches(nodeLabels[i])
+	}
+
+	var out []GraphNode
+	if kind != "" {
+		for _, i := range candidates {
+			if match(i) {
+				out = append(out, idx.graph.Nodes[i])
+			}
+		}
+		return out
+	}
+	for i := range idx.graph.Nodes {
+		if match(i) {
+			out = append(out, idx.graph.Nodes[i])
+		}
+	}
+	return out
+}
+
+// Neighbors is Graph.Neighbors on the indexed graph.
+func (idx *Index) Neighbors(key GraphEntityKey, dir Direction, relTypes ...string) []GraphNode {
+	var out []GraphNode
+	seen := make(map[GraphEntityKey]bool)
+	for _, ri := range idx.adjacency[key] {
+		r := idx.graph.Relationships[ri]
+		if len(relTypes) > 0 && !slices.Contains(relTypes, r.RelationshipType) {
+			continue
+		}
+		var other GraphEntityKey
+		switch {
+		case r.Source == key && dir != Incoming:
+			other = r.Target
+		case r.Target == key && dir != Outgoing:
+			other = r.Source
+		default:
+			continue
+		}
+		i, ok := idx.nodes[other]
+		if !ok || seen[other] {
+			continue
+		}
+		seen[other] = true
+		out = append(out, idx.graph.Nodes[i])
+	}
+	return out
+}
+
+// Traverse is Graph.Traverse on the indexed graph.
+func (idx *Index) Traverse(start GraphEntityKey, depth int, filter TraverseFilter) (Graph, bool) {
+	if _, ok := idx.nodes[start]; !ok {
+		return Graph{}, false
+	}
+
+	seen := map[GraphEntityKey]bool{start: true}
+	frontier := []GraphEntityKey{start}
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []GraphEntityKey
+		for _, k := range frontier {
+			for _, ri := range idx.adjacency[k] {
+				r := idx.graph.Relationships[ri]
+				other := r.Target
+				if other == k {
+					other = r.Source
+				}
+				if seen[other] {
+					continue
+				}
+				if filter != nil {
+					node := GraphNode{Key: other}
+					if i, ok := idx.nodes[other]; ok {
+						node = idx.graph.Nodes[i]
+					}
+					if !filter(r, node) {
+						continue
+					}
+				}
+				seen[other] = true
+				next = append(next, other)
+			}
+		}
+		frontier = next
+	}
+
+	return idx.induced(seen), true
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// addQuotaProperties sets a ResourceQuota's hard limits and usage as one
+// property per resource, e.g. spec.hard.requests.cpu=4 and
+// status.used.requests.cpu=2500m.
+func addQuotaProperties(props map[string]string, quota *corev1.ResourceQuota) {
+	addQuantities(props, "spec.hard.", quota.Spec.Hard)
+	addQuantities(props, "status.used.", quota.Status.Used)
+}
+
+// addLimitRangeProperties sets a LimitRange's limits per type and resource,
+// e.g. limits.Container.default.memory=512Mi, for its default,
+// defaultRequest, min, max and maxLimitRequestRatio.
+func addLimitRangeProperties(props map[string]string, lr *corev1.LimitRange) {
+	for _, item := range lr.Spec.Limits {
+		prefix := "limits." + string(item.Type) + "."
+		addQuantities(props, prefix+"default.", item.Default)
+		addQuantities(props, prefix+"defaultRequest.", item.DefaultRequest)
+		addQuantities(props, prefix+"min.", item.Min)
+		addQuantities(props, prefix+"max.", item.Max)
+		addQuantities(props, prefix+"maxLimitRequestRatio.", item.MaxLimitRequestRatio)
+	}
+}
+
+// addQuantities sets one property per resource of list, under prefix.
+func addQuantities(props map[string]string, prefix string, list corev1.ResourceList) {
+	for name, q := range list {
+		props[prefix+string(name)] = q.String()
+	}
+}
+
+// namespaceScope returns the SCOPES relationship from source, a
+// ResourceQuota or LimitRange, to the Namespace it constrains.
+func namespaceScope(namespace string, source GraphEntityKey, revision uint64) GraphRelationship {
+	return GraphRelationship{
+		Source:           source,
+		Target:           GraphEntityKey{Name: namespace, Kind: "Namespace"},
+		RelationshipType: "SCOPES",
+		Revision:         revision,
+	}
+}
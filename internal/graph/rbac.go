@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// addRuleProperties summarizes the rules of a Role or ClusterRole: how many
+// there are, the verbs they grant, how many resources and non-resource URLs
+// they cover, and rules.wildcard=true if any grants "*" verbs, resources or
+// API groups. The rules themselves are not copied.
+func addRuleProperties(props map[string]string, rules []rbacv1.PolicyRule) {
+	props["rules"] = strconv.Itoa(len(rules))
+	verbs := make(map[string]bool)
+	resources := make(map[string]bool)
+	urls := make(map[string]bool)
+	wildcard := false
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			verbs[verb] = true
+		}
+		groups := rule.APIGroups
+		if len(groups) == 0 {
+			groups = []string{""}
+		}
+		for _, group := range groups {
+			for _, resource := range rule.Resources {
+				resources[group+"/"+resource] = true
+			}
+		}
+		for _, url := range rule.NonResourceURLs {
+			urls[url] = true
+		}
+		wildcard = wildcard || slices.Contains(rule.Verbs, rbacv1.VerbAll) ||
+			slices.Contains(rule.Resources, rbacv1.ResourceAll) || slices.Contains(rule.APIGroups, rbacv1.APIGroupAll)
+	}
+	if len(verbs) > 0 {
+		sorted := make([]string, 0, len(verbs))
+		for verb := range verbs {
+			sorted = append(sorted, verb)
+		}
+		sort.Strings(sorted)
+		props["rules.verbs"] = strings.Join(sorted, ",")
+	}
+	props["rules.resources"] = strconv.Itoa(len(resources))
+	if len(urls) > 0 {
+		props["rules.nonResourceURLs"] = strconv.Itoa(len(urls))
+	}
+	props["rules.wildcard"] = strconv.FormatBool(wildcard)
+}
+
+// addBindingProperties sets a RoleBinding's or ClusterRoleBinding's role
+// and how many subjects of each kind it has.
+func addBindingProperties(props map[string]string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+	props["roleRef"] = roleRef.Kind + "/" + roleRef.Name
+	counts := make(map[string]int)
+	for _, s := range subjects {
+		counts[s.Kind]++
+	}
+	for _, kind := range []string{rbacv1.ServiceAccountKind, rbacv1.UserKind, rbacv1.GroupKind} {
+		if counts[kind] > 0 {
+			props["subjects."+kind] = strconv.Itoa(counts[kind])
+		}
+	}
+}
+
+// bindingRelationships returns the REFERENCES relationship from a binding in
+// namespace ("" for a ClusterRoleBinding) to its Role or ClusterRole, and a
+// GRANTS_TO relationship to each ServiceAccount among its subjects. Users and
+// groups aren't objects and get none.
+func bindingRelationships(namespace string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	role := GraphEntityKey{Name: roleRef.Name, Kind: roleRef.Kind, APIGroup: roleRef.APIGroup}
+	if roleRef.Kind == "Role" {
+		role.Namespace = namespace
+	}
+	rel := GraphRelationship{
+		Source:           source,
+		Target:           role,
+		RelationshipType: "REFERENCES",
+		Revision:         revision,
+	}
+	deps.check(&rel)
+	rels := []GraphRelationship{rel}
+
+	seen := make(map[GraphEntityKey]bool)
+	for _, s := range subjects {
+		if s.Kind != rbacv1.ServiceAccountKind || s.Name == "" {
+			continue
+		}
+		sa := GraphEntityKey{Name: s.Name, Namespace: s.Namespace, Kind: "ServiceAccount"}
+		if sa.Namespace == "" {
+			sa.Namespace = namespace
+		}
+		if seen[sa] {
+			continue
+		}
+		seen[sa] = true
+		rels = append(rels, GraphRelationship{
+			Source:           source,
+			Target:           sa,
+			RelationshipType: "GRANTS_TO",
+			Revision:         revision,
+		})
+	}
+	return rels
+}
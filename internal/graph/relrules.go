@@ -0,0 +1,269 @@
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// RuleProperty names, on relationships derived by a RelationshipRule, the
+// rule that derived them.
+const RuleProperty = "rule"
+
+// RelationshipRule derives relationships of type Type from every object of
+// SourceKind to the targets its fields name, for conventions Satellite has
+// no built-in resolver for, e.g. an annotation naming a "parent app".
+type RelationshipRule struct {
+	// Name identifies the rule in the RuleProperty and metrics.
+	Name       string             `json:"name"`
+	SourceKind string             `json:"sourceKind"`
+	Type       string             `json:"type"`
+	Target     RelationshipTarget `json:"target"`
+}
+
+// RelationshipTarget names the targets of a RelationshipRule. Each field is
+// a literal, a JSONPath expression in braces such as
+// "{.metadata.annotations.acme\.io/parent}", or a field path such as
+// ".spec.tenantRef.name", evaluated on the source object. An expression may
+// yield several values, e.g. "{.spec.dependencies[*].name}", giving one
+// target per name; the other fields then yield one value for all of them or
+// one per name. Namespace defaults to the source's, or none if
+// ClusterScoped, and the API group comes from APIVersion, else the watched
+// kind's.
+type RelationshipTarget struct {
+	APIVersion    string `json:"apiVersion,omitempty"`
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace,omitempty"`
+	ClusterScoped bool   `json:"clusterScoped,omitempty"`
+}
+
+// RelationshipRules are validated relationship rules.
+type RelationshipRules struct {
+	rules []RelationshipRule
+}
+
+// NewRelationshipRules validates rules: each has a unique name, a source
+// kind, a type without whitespace, a target kind and name, and expressions
+// that parse.
+func NewRelationshipRules(rules []RelationshipRule) (*RelationshipRules, error) {
+	names := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		case names[r.Name]:
+			return nil, fmt.Errorf("rule %d: duplicate name %q", i, r.Name)
+		case r.SourceKind == "":
+			return nil, fmt.Errorf("rule %q: sourceKind is required", r.Name)
+		case r.Type == "" || strings.ContainsAny(r.Type, " \t\r\n"):
+			return nil, fmt.Errorf("rule %q: type %q must be non-empty without whitespace", r.Name, r.Type)
+		case r.Target.Kind == "" || r.Target.Name == "":
+			return nil, fmt.Errorf("rule %q: target kind and name are required", r.Name)
+		case r.Target.ClusterScoped && r.Target.Namespace != "":
+			return nil, fmt.Errorf("rule %q: a clusterScoped target has no namespace", r.Name)
+		}
+		names[r.Name] = true
+		for field, value := range r.Target.fields() {
+			if _, err := parseTargetField(value); err != nil {
+				return nil, fmt.Errorf("rule %q: target %s: %w", r.Name, field, err)
+			}
+		}
+	}
+	return &RelationshipRules{rules: rules}, nil
+}
+
+// Reads reports whether a rule derives relationships from objects of kind,
+// which must then be stored in full: rules may read any field.
+func (r *RelationshipRules) Reads(kind string) bool {
+	if r == nil {
+		return false
+	}
+	for _, rule := range r.rules {
+		if rule.SourceKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (t RelationshipTarget) fields() map[string]string {
+	return map[string]string{"apiVersion": t.APIVersion, "kind": t.Kind, "name": t.Name, "namespace": t.Namespace}
+}
+
+// targetField is one field of a RelationshipTarget: a literal, or an
+// expression.
+type targetField struct {
+	literal string
+	path    *jsonpath.JSONPath
+}
+
+// parseTargetField parses value. An expression must be one JSONPath
+// expression, not a template mixing text and expressions.
+func parseTargetField(value string) (targetField, error) {
+	if strings.HasPrefix(value, ".") {
+		value = "{" + value + "}"
+	}
+	if !strings.Contains(value, "{") {
+		return targetField{literal: value}, nil
+	}
+	parsed, err := jsonpath.Parse("target", value)
+	if err != nil {
+		return targetField{}, fmt.Errorf("invalid JSONPath %q: %w", value, err)
+	}
+	if len(parsed.Root.Nodes) != 1 || parsed.Root.Nodes[0].Type() != jsonpath.NodeList {
+		return targetField{}, fmt.Errorf("invalid JSONPath %q: want a single {...} expression", value)
+	}
+	path := jsonpath.New("target").AllowMissingKeys(true)
+	if err := path.Parse(value); err != nil {
+		return targetField{}, fmt.Errorf("invalid JSONPath %q: %w", value, err)
+	}
+	return targetField{path: path}, nil
+}
+
+// values returns the values of f on data: the literal, if not empty, or
+// the scalars the expression yields. A missing field yields none.
+func (f targetField) values(data map[string]any) ([]string, error) {
+	if f.path == nil {
+		if f.literal == "" {
+			return nil, nil
+		}
+		return []string{f.literal}, nil
+	}
+	results, err := f.path.FindResults(data)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			for v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			switch v.Kind() {
+			case reflect.String, reflect.Bool, reflect.Int64, reflect.Float64:
+				if s := fmt.Sprint(v.Interface()); s != "" {
+					values = append(values, s)
+				}
+			case reflect.Invalid:
+			default:
+				return nil, fmt.Errorf("expression yields a %s, not a scalar", v.Kind())
+			}
+		}
+	}
+	return values, nil
+}
+
+// compiledRule is a rule with its fields parsed for one build: evaluating
+// a JSONPath isn't safe for concurrent use.
+type compiledRule struct {
+	RelationshipRule
+	apiVersion, kind, name, namespace targetField
+}
+
+// compile parses the rules for one build, grouped by source kind.
+func (r *RelationshipRules) compile() map[string][]compiledRule {
+	if r == nil || len(r.rules) == 0 {
+		return nil
+	}
+	bySource := make(map[string][]compiledRule)
+	for _, rule := range r.rules {
+		c := compiledRule{RelationshipRule: rule}
+		// validated by NewRelationshipRules
+		c.apiVersion, _ = parseTargetField(rule.Target.APIVersion)
+		c.kind, _ = parseTargetField(rule.Target.Kind)
+		c.name, _ = parseTargetField(rule.Target.Name)
+		c.namespace, _ = parseTargetField(rule.Target.Namespace)
+		bySource[rule.SourceKind] = append(bySource[rule.SourceKind], c)
+	}
+	return bySource
+}
+
+// addRuleRelationships adds the relationships the rules derive. A rule
+// that fails on an object, e.g. on a non-scalar value, derives nothing from
+// it and counts an error.
+func addRuleRelationships(g *Graph, keyed []keyedObject, rules *RelationshipRules, revision uint64) {
+	bySource := rules.compile()
+	if bySource == nil {
+		return
+	}
+	for _, ko := range keyed {
+		compiled := bySource[ko.key.Kind]
+		if len(compiled) == 0 {
+			continue
+		}
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ko.obj)
+		if err != nil {
+			for _, c := range compiled {
+				metrics.RelationshipRuleErrors.WithLabelValues(c.Name).Inc()
+			}
+			continue
+		}
+		for _, c := range compiled {
+			targets, err := c.targets(data, ko.graphKey.Namespace)
+			if err != nil {
+				metrics.RelationshipRuleErrors.WithLabelValues(c.Name).Inc()
+				continue
+			}
+			for _, target := range targets {
+				g.Relationships = append(g.Relationships, GraphRelationship{
+					Source:           ko.graphKey,
+					Target:           target,
+					RelationshipType: c.Type,
+					Properties:       map[string]string{RuleProperty: c.Name},
+					Revision:         revision,
+				})
+			}
+		}
+	}
+}
+
+// targets evaluates the target fields of c on data, an object in
+// namespace.
+func (c compiledRule) targets(data map[string]any, namespace string) ([]GraphEntityKey, error) {
+	names, err := c.name.values(data)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	pick := func(f targetField, fallback string) (func(i int) string, error) {
+		values, err := f.values(data)
+		switch {
+		case err != nil:
+			return nil, err
+		case len(values) == 0:
+			return func(int) string { return fallback }, nil
+		case len(values) == 1:
+			return func(int) string { return values[0] }, nil
+		case len(values) == len(names):
+			return func(i int) string { return values[i] }, nil
+		}
+		return nil, fmt.Errorf("%d values for %d names", len(values), len(names))
+	}
+	if c.Target.ClusterScoped {
+		namespace = ""
+	}
+	apiVersion, err := pick(c.apiVersion, "")
+	if err != nil {
+		return nil, err
+	}
+	kind, err := pick(c.kind, "")
+	if err != nil {
+		return nil, err
+	}
+	ns, err := pick(c.namespace, namespace)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]GraphEntityKey, 0, len(names))
+	for i, name := range names {
+		if k := kind(i); k != "" {
+			targets = append(targets, refKey(apiVersion(i), k, name, ns(i)))
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+)
+
+const (
+	// RolloutRevisionProperty is the ReplicaSet property holding the
+	// Deployment revision the ReplicaSet was created for.
+	RolloutRevisionProperty = "rolloutRevision"
+
+	// IsCurrentProperty is set to "true" on the ReplicaSet of its
+	// Deployment's current revision.
+	IsCurrentProperty = "isCurrent"
+
+	// ChangeCauseProperty is the ReplicaSet property holding the change
+	// cause recorded for its revision.
+	ChangeCauseProperty = "changeCause"
+)
+
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// addRolloutProperties sets a ReplicaSet's rollout revision and change cause
+// from its annotations.
+func addRolloutProperties(props map[string]string, annotations map[string]string) {
+	if revision := annotations[revisionAnnotation]; revision != "" {
+		props[RolloutRevisionProperty] = revision
+	}
+	if cause := annotations[changeCauseAnnotation]; cause != "" {
+		props[ChangeCauseProperty] = cause
+	}
+}
+
+// rolloutRevision is a ReplicaSet of a Deployment and its revision.
+type rolloutRevision struct {
+	node     int
+	revision int64
+}
+
+// addRollouts marks the ReplicaSet of each Deployment's current revision
+// isCurrent and chains the ReplicaSets of a Deployment, newest first, with
+// SUPERSEDES relationships. ReplicaSets without a valid revision are left
+// out. g.Nodes must be in the order of keyed.
+func addRollouts(g *Graph, keyed []keyedObject, currentGraphRevision uint64) {
+	deployments := make(map[GraphEntityKey]int64)
+	rollouts := make(map[GraphEntityKey][]rolloutRevision)
+	var owners []GraphEntityKey
+	for i, ko := range keyed {
+		meta := k8s.GetObjectMeta(ko.obj)
+		switch ko.key.Kind {
+		case "Deployment":
+			if revision, err := strconv.ParseInt(meta.Annotations[revisionAnnotation], 10, 64); err == nil {
+				deployments[ko.graphKey] = revision
+			}
+		case "ReplicaSet":
+			revision, err := strconv.ParseInt(meta.Annotations[revisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			for _, ref := range meta.OwnerReferences {
+				if ref.Kind == "Deployment" {
+					owner := ownerKey(ref, meta.Namespace)
+					if _, seen := rollouts[owner]; !seen {
+						owners = append(owners, owner)
+					}
+					rollouts[owner] = append(rollouts[owner], rolloutRevision{node: i, revision: revision})
+					break
+				}
+			}
+		}
+	}
+
+	for _, owner := range owners {
+		revisions := rollouts[owner]
+		slices.SortStableFunc(revisions, func(a, b rolloutRevision) int {
+			return cmp.Compare(a.revision, b.revision)
+		})
+		current, known := deployments[owner]
+		for i, r := range revisions {
+			node := &g.Nodes[r.node]
+			if known && r.revision == current {
+				node.Properties[IsCurrentProperty] = "true"
+			}
+			if i > 0 {
+				g.Relationships = append(g.Relationships, GraphRelationship{
+					Source:           node.Key,
+					Target:           g.Nodes[revisions[i-1].node].Key,
+					RelationshipType: "SUPERSEDES",
+					Revision:         currentGraphRevision,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+)
+
+// addPriorityClassProperties sets a PriorityClass's value, whether it is the
+// global default and its preemption policy.
+func addPriorityClassProperties(props map[string]string, pc *schedulingv1.PriorityClass) {
+	props["value"] = formatInt(pc.Value)
+	props["globalDefault"] = strconv.FormatBool(pc.GlobalDefault)
+	if pc.PreemptionPolicy != nil {
+		props["preemptionPolicy"] = string(*pc.PreemptionPolicy)
+	}
+}
+
+// addRuntimeClassProperties sets a RuntimeClass's handler.
+func addRuntimeClassProperties(props map[string]string, rc *nodev1.RuntimeClass) {
+	props["handler"] = rc.Handler
+}
+
+// podClassRelationships returns the HAS_PRIORITY relationship from pod to
+// the PriorityClass it names and the USES_RUNTIME one to its RuntimeClass.
+// Only spec.priorityClassName is followed, not the priority admission
+// fills in for pods without one. A pod can't start without its
+// RuntimeClass, which deps checks; a PriorityClass is only read at
+// admission.
+func podClassRelationships(pod *corev1.Pod, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	var rels []GraphRelationship
+	if pod.Spec.PriorityClassName != "" {
+		rels = append(rels, GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: pod.Spec.PriorityClassName, Kind: "PriorityClass", APIGroup: schedulingv1.GroupName},
+			RelationshipType: "HAS_PRIORITY",
+			Revision:         revision,
+		})
+	}
+	if pod.Spec.RuntimeClassName != nil && *pod.Spec.RuntimeClassName != "" {
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: *pod.Spec.RuntimeClassName, Kind: "RuntimeClass", APIGroup: nodev1.GroupName},
+			RelationshipType: "USES_RUNTIME",
+			Revision:         revision,
+		}
+		deps.check(&rel)
+		rels = append(rels, rel)
+	}
+	return rels
+}
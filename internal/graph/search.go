@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// Match ranks for search results, best first.
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchSubstring = "substring"
+	MatchLabel     = "label"
+)
+
+var matchRank = map[string]int{MatchExact: 0, MatchPrefix: 1, MatchSubstring: 2, MatchLabel: 3}
+
+// SearchHit is one node matching a search.
+type SearchHit struct {
+	Node  GraphNode
+	Match string
+}
+
+// searchEntry holds the lowercased searchable strings of one node.
+type searchEntry struct {
+	name        string
+	labelValues []string
+}
+
+// buildSearchEntries lowercases every node name and label value once per
+// graph, so searches don't re-parse properties.
+func buildSearchEntries(g Graph) []searchEntry {
+	entries := make([]searchEntry, len(g.Nodes))
+	for i, n := range g.Nodes {
+		e := searchEntry{name: strings.ToLower(n.Key.Name)}
+		for _, v := range NodeLabels(n) {
+			e.labelValues = append(e.labelValues, strings.ToLower(v))
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+// searchEntries returns the search entries of the graph, built on first use.
+func (idx *Index) searchEntries() []searchEntry {
+	idx.searchOnce.Do(func() { idx.search = buildSearchEntries(idx.graph) })
+	return idx.search
+}
+
+// Search returns nodes whose name contains query (case-insensitive), then
+// nodes with a label value containing it, ranked exact name > name prefix >
+// name substring > label value, and by key within a rank. kind, if set,
+// restricts results to that kind. At most limit hits are returned; the bool
+// reports whether more matched.
+func (idx *Index) Search(query, kind string, limit int) ([]SearchHit, bool) {
+	query = strings.ToLower(query)
+	var hits []SearchHit
+	for i, e := range idx.searchEntries() {
+		node := idx.graph.Nodes[i]
+		if kind != "" && node.Key.Kind != kind {
+			continue
+		}
+		if match := e.match(query); match != "" {
+			hits = append(hits, SearchHit{Node: node, Match: match})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		ri, rj := matchRank[hits[i].Match], matchRank[hits[j].Match]
+		if ri != rj {
+			return ri < rj
+		}
+		return keyLess(hits[i].Node.Key, hits[j].Node.Key)
+	})
+	if len(hits) > limit {
+		return hits[:limit], true
+	}
+	return hits, false
+}
+
+// match returns the best way e matches query, or "" if it doesn't.
+func (e searchEntry) match(query string) string {
+	switch {
+	case e.name == query:
+		return MatchExact
+	case strings.HasPrefix(e.name, query):
+		return MatchPrefix
+	case strings.Contains(e.name, query):
+		return MatchSubstring
+	}
+	for _, v := range e.labelValues {
+		if strings.Contains(v, query) {
+			return MatchLabel
+		}
+	}
+	return ""
+}
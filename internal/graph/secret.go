@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// addSecretProperties sets a Secret's type, data keys and data hash, plus
+// the registry hosts of a dockerconfigjson Secret and, if its tls.crt was
+// kept, the expiry, issuer and SAN count of a TLS Secret's certificate.
+// Values are never copied: a certificate that doesn't parse only sets
+// tls.error, without the parser's error.
+func addSecretProperties(props map[string]string, s *corev1.Secret) {
+	props["type"] = string(s.Type)
+	if len(s.Data) > 0 {
+		props["data.keys"] = formatDataKeys(s.Data)
+	}
+	props["data.hash"] = dataHash(s)
+
+	switch s.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		if hosts := k8s.DockerConfigRegistries(s); len(hosts) > 0 {
+			props["dockerconfig.registries"] = strings.Join(hosts, ",")
+		}
+	case corev1.SecretTypeTLS:
+		crt := s.Data[corev1.TLSCertKey]
+		if len(crt) == 0 {
+			return
+		}
+		block, _ := pem.Decode(crt)
+		if block == nil || block.Type != "CERTIFICATE" {
+			props["tls.error"] = "no PEM certificate"
+			return
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			props["tls.error"] = "invalid certificate"
+			return
+		}
+		props["tls.notAfter"] = cert.NotAfter.UTC().Format(time.RFC3339)
+		props["tls.issuer"] = cert.Issuer.String()
+		props["tls.sanCount"] = strconv.Itoa(len(cert.DNSNames) + len(cert.IPAddresses) + len(cert.URIs) + len(cert.EmailAddresses))
+	}
+}
+
+// dataHash returns the hash TrimSecret recorded on a trimmed Secret, or
+// hashes the data of one that wasn't trimmed. The annotation can be
+// trusted: TrimSecret replaces any incoming one and metadata-only Secrets
+// are cached without it.
+func dataHash(s *corev1.Secret) string {
+	if hash, ok := s.Annotations[k8s.DataHashAnnotation]; ok {
+		return hash
+	}
+	return k8s.SecretDataHash(s)
+}
+
+// secretAnnotations returns annotations without the last-applied
+// configuration, which repeats the Secret's values, and the data hash,
+// which is a property of its own.
+func secretAnnotations(annotations map[string]string) map[string]string {
+	_, applied := annotations[k8s.LastAppliedAnnotation]
+	_, hashed := annotations[k8s.DataHashAnnotation]
+	if !applied && !hashed {
+		return annotations
+	}
+	out := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		out[k] = v
+	}
+	delete(out, k8s.LastAppliedAnnotation)
+	delete(out, k8s.DataHashAnnotation)
+	return out
+}
+
+// UsageProperty is set on a pod's relationship to a Secret to how the pod
+// uses it: a comma-separated list of UsageVolume, UsageEnv and
+// UsageImagePull. ConfigMaps have usages of their own.
+const (
+	UsageProperty  = "usage"
+	UsageVolume    = "volume"
+	UsageEnv       = "env"
+	UsageImagePull = "imagePull"
+)
+
+// secretUse is how a pod uses one Secret.
+type secretUse struct {
+	name     string
+	usages   map[string]bool
+	required bool
+}
+
+// podSecretUses returns the Secrets pod references through volumes,
+// projected volumes, container env and envFrom, and imagePullSecrets, in
+// order of first reference, each once.
+func podSecretUses(pod *corev1.Pod) []*secretUse {
+	var uses []*secretUse
+	byName := make(map[string]*secretUse)
+	add := func(name, usage string, optional *bool) {
+		if name == "" {
+			return
+		}
+		use := byName[name]
+		if use == nil {
+			use = &secretUse{name: name, usages: make(map[string]bool)}
+			byName[name] = use
+			uses = append(uses, use)
+		}
+		use.usages[usage] = true
+		// a pod doesn't start without a Secret it needs; pull secrets only
+		// warn
+		if usage != UsageImagePull && (optional == nil || !*optional) {
+			use.required = true
+		}
+	}
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Secret != nil {
+			add(vol.Secret.SecretName, UsageVolume, vol.Secret.Optional)
+		}
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.Secret != nil {
+					add(source.Secret.Name, UsageVolume, source.Secret.Optional)
+				}
+			}
+		}
+	}
+	addEnv := func(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) {
+		for _, e := range env {
+			if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+				add(e.ValueFrom.SecretKeyRef.Name, UsageEnv, e.ValueFrom.SecretKeyRef.Optional)
+			}
+		}
+		for _, from := range envFrom {
+			if from.SecretRef != nil {
+				add(from.SecretRef.Name, UsageEnv, from.SecretRef.Optional)
+			}
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	for _, c := range pod.Spec.Containers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		addEnv(c.Env, c.EnvFrom)
+	}
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		add(ref.Name, UsageImagePull, nil)
+	}
+	return uses
+}
+
+// podSecretRelationships returns one relationship per Secret pod uses:
+// MOUNTS if it is mounted as a volume, USES_SECRET otherwise, with its
+// usages. Secrets the pod can't start without are checked by deps.
+func podSecretRelationships(pod *corev1.Pod, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	var rels []GraphRelationship
+	for _, use := range podSecretUses(pod) {
+		relType := "USES_SECRET"
+		if use.usages[UsageVolume] {
+			relType = "MOUNTS"
+		}
+		usages := make([]string, 0, len(use.usages))
+		for usage := range use.usages {
+			usages = append(usages, usage)
+		}
+		sort.Strings(usages)
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: use.name, Namespace: pod.Namespace, Kind: "Secret"},
+			RelationshipType: relType,
+			Properties:       map[string]string{UsageProperty: strings.Join(usages, ",")},
+			Revision:         revision,
+		}
+		if use.required {
+			deps.check(&rel)
+		}
+		rels = append(rels, rel)
+	}
+	return rels
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// addServiceAccountProperties sets whether a ServiceAccount's token is
+// mounted into its pods by default, and the names of its Secrets and image
+// pull Secrets.
+func addServiceAccountProperties(props map[string]string, sa *corev1.ServiceAccount) {
+	if sa.AutomountServiceAccountToken != nil {
+		props["automountServiceAccountToken"] = strconv.FormatBool(*sa.AutomountServiceAccountToken)
+	}
+	if len(sa.Secrets) > 0 {
+		names := make([]string, len(sa.Secrets))
+		for i, s := range sa.Secrets {
+			names[i] = s.Name
+		}
+		props["secrets"] = strings.Join(names, ",")
+	}
+	if len(sa.ImagePullSecrets) > 0 {
+		names := make([]string, len(sa.ImagePullSecrets))
+		for i, s := range sa.ImagePullSecrets {
+			names[i] = s.Name
+		}
+		props["imagePullSecrets"] = strings.Join(names, ",")
+	}
+}
+
+// podServiceAccount returns the RUNS_AS relationship from pod to the
+// ServiceAccount it runs as, in its own namespace: spec.serviceAccountName,
+// the deprecated spec.serviceAccount, or "default". Every namespace gets a
+// default ServiceAccount from its controller, so only others are checked by
+// deps; manifests rarely include it.
+func podServiceAccount(pod *corev1.Pod, source GraphEntityKey, deps *dependencies, revision uint64) GraphRelationship {
+	name := pod.Spec.ServiceAccountName
+	if name == "" {
+		name = pod.Spec.DeprecatedServiceAccount
+	}
+	if name == "" {
+		name = "default"
+	}
+	rel := GraphRelationship{
+		Source:           source,
+		Target:           GraphEntityKey{Name: name, Namespace: pod.Namespace, Kind: "ServiceAccount"},
+		RelationshipType: "RUNS_AS",
+		Revision:         revision,
+	}
+	if name != "default" {
+		deps.check(&rel)
+	}
+	return rel
+}
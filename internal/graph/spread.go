@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Spread properties of workloads, from their live, scheduled pods.
+const (
+	SpreadPodsProperty           = "spread.pods"
+	SpreadNodesProperty          = "spread.nodes"
+	SpreadZonesProperty          = "spread.zones"
+	SpreadMaxPodsPerNodeProperty = "spread.maxPodsPerNode"
+	// SingleZoneProperty is "true" if every pod runs in the same zone,
+	// "false" if they span several, and unset if a pod's zone is unknown.
+	SingleZoneProperty = "singleZone"
+)
+
+// workloadKinds are the owners spread is summarized for.
+var workloadKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true}
+
+// workloadOwner returns the workload owning an object with refs, through
+// the ReplicaSet of a Deployment. parents maps owned objects, such as
+// ReplicaSets, to their own owner.
+func workloadOwner(refs []metav1.OwnerReference, namespace string, parents map[GraphEntityKey]GraphEntityKey) (GraphEntityKey, bool) {
+	for _, ref := range refs {
+		owner := ownerKey(ref, namespace)
+		if workloadKinds[owner.Kind] {
+			return owner, true
+		}
+		if parent, ok := parents[owner]; ok && workloadKinds[parent.Kind] {
+			return parent, true
+		}
+	}
+	return GraphEntityKey{}, false
+}
+
+// spread counts the pods of one workload per node.
+type spread struct {
+	pods    int
+	perNode map[string]int
+}
+
+// addSpread summarizes, on each workload node, how its live, scheduled
+// pods spread across nodes and the zones of those nodes. g.Nodes must be in
+// the order of keyed.
+func addSpread(g *Graph, keyed []keyedObject) {
+	parents := make(map[GraphEntityKey]GraphEntityKey)
+	zones := make(map[string]string)
+	for i, ko := range keyed {
+		switch ko.key.Kind {
+		case "ReplicaSet":
+			meta := ko.obj.(metav1.Object)
+			for _, ref := range meta.GetOwnerReferences() {
+				if workloadKinds[ref.Kind] {
+					parents[ko.graphKey] = ownerKey(ref, meta.GetNamespace())
+					break
+				}
+			}
+		case "Node":
+			zones[ko.graphKey.Name] = g.Nodes[i].Properties["zone"]
+		}
+	}
+
+	spreads := make(map[GraphEntityKey]*spread)
+	for i, ko := range keyed {
+		pod, ok := ko.obj.(*corev1.Pod)
+		if !ok || pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed ||
+			g.Nodes[i].Properties[StateProperty] == StateTerminating {
+			continue
+		}
+		owner, ok := workloadOwner(pod.OwnerReferences, pod.Namespace, parents)
+		if !ok {
+			continue
+		}
+		s := spreads[owner]
+		if s == nil {
+			s = &spread{perNode: make(map[string]int)}
+			spreads[owner] = s
+		}
+		s.pods++
+		s.perNode[pod.Spec.NodeName]++
+	}
+	if len(spreads) == 0 {
+		return
+	}
+
+	for i := range g.Nodes {
+		s := spreads[g.Nodes[i].Key]
+		if s == nil {
+			continue
+		}
+		props := g.Nodes[i].Properties
+		maxPods, zoneSet, zoneKnown := 0, make(map[string]bool), true
+		for node, n := range s.perNode {
+			maxPods = max(maxPods, n)
+			if zone := zones[node]; zone != "" {
+				zoneSet[zone] = true
+			} else {
+				zoneKnown = false
+			}
+		}
+		props[SpreadPodsProperty] = strconv.Itoa(s.pods)
+		props[SpreadNodesProperty] = strconv.Itoa(len(s.perNode))
+		props[SpreadZonesProperty] = strconv.Itoa(len(zoneSet))
+		props[SpreadMaxPodsPerNodeProperty] = strconv.Itoa(maxPods)
+		if zoneKnown {
+			props[SingleZoneProperty] = strconv.FormatBool(len(zoneSet) == 1)
+		}
+	}
+}
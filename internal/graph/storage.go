@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// addClaimProperties sets a PersistentVolumeClaim's phase, requested and
+// provisioned storage, storage class, access modes and bound volume. An
+// unbound claim has phase Pending and no volumeName.
+func addClaimProperties(props map[string]string, pvc *corev1.PersistentVolumeClaim) {
+	props["status.phase"] = string(pvc.Status.Phase)
+	if storage, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		props["spec.resources.requests.storage"] = storage.String()
+	}
+	if storage, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		props["status.capacity.storage"] = storage.String()
+	}
+	if pvc.Spec.StorageClassName != nil {
+		props["spec.storageClassName"] = *pvc.Spec.StorageClassName
+	}
+	if len(pvc.Spec.AccessModes) > 0 {
+		props["spec.accessModes"] = formatAccessModes(pvc.Spec.AccessModes)
+	}
+	props["spec.volumeName"] = pvc.Spec.VolumeName
+}
+
+// addVolumeProperties sets a PersistentVolume's capacity, reclaim policy,
+// phase, storage class, access modes and the claim it is bound to.
+func addVolumeProperties(props map[string]string, pv *corev1.PersistentVolume) {
+	if storage, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+		props["spec.capacity.storage"] = storage.String()
+	}
+	props["spec.persistentVolumeReclaimPolicy"] = string(pv.Spec.PersistentVolumeReclaimPolicy)
+	props["status.phase"] = string(pv.Status.Phase)
+	props["spec.storageClassName"] = pv.Spec.StorageClassName
+	if len(pv.Spec.AccessModes) > 0 {
+		props["spec.accessModes"] = formatAccessModes(pv.Spec.AccessModes)
+	}
+	if ref := pv.Spec.ClaimRef; ref != nil {
+		props["spec.claimRef"] = ref.Namespace + "/" + ref.Name
+	}
+}
+
+func formatAccessModes(modes []corev1.PersistentVolumeAccessMode) string {
+	out := make([]string, len(modes))
+	for i, mode := range modes {
+		out[i] = string(mode)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+// podClaimRelationships returns a USES relationship from pod to each
+// PersistentVolumeClaim its volumes reference, including the claims of
+// generic ephemeral volumes, named <pod>-<volume>. The pod can't start
+// without them, so deps checks each.
+func podClaimRelationships(pod *corev1.Pod, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	var rels []GraphRelationship
+	seen := make(map[string]bool)
+	for _, vol := range pod.Spec.Volumes {
+		var claim string
+		switch {
+		case vol.PersistentVolumeClaim != nil:
+			claim = vol.PersistentVolumeClaim.ClaimName
+		case vol.Ephemeral != nil:
+			claim = pod.Name + "-" + vol.Name
+		}
+		if claim == "" || seen[claim] {
+			continue
+		}
+		seen[claim] = true
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           GraphEntityKey{Name: claim, Namespace: pod.Namespace, Kind: "PersistentVolumeClaim"},
+			RelationshipType: "USES",
+			Revision:         revision,
+		}
+		deps.check(&rel)
+		rels = append(rels, rel)
+	}
+	return rels
+}
+
+// addBindings adds a BOUND_TO relationship from each PersistentVolumeClaim
+// to its PersistentVolume, from the claim's volumeName or, while binding is
+// in progress, the volume's claimRef. A claimRef to an earlier claim of the
+// same name, with another UID, is ignored.
+func addBindings(g *Graph, keyed []keyedObject, revision uint64) {
+	claims := make(map[GraphEntityKey]*corev1.PersistentVolumeClaim)
+	for _, ko := range keyed {
+		if pvc, ok := ko.obj.(*corev1.PersistentVolumeClaim); ok {
+			claims[ko.graphKey] = pvc
+		}
+	}
+	type binding struct{ claim, volume GraphEntityKey }
+	var bindings []binding
+	seen := make(map[binding]bool)
+	bind := func(b binding) {
+		if !seen[b] {
+			seen[b] = true
+			bindings = append(bindings, b)
+		}
+	}
+	for _, ko := range keyed {
+		switch o := ko.obj.(type) {
+		case *corev1.PersistentVolumeClaim:
+			if o.Spec.VolumeName != "" {
+				bind(binding{claim: ko.graphKey, volume: GraphEntityKey{Name: o.Spec.VolumeName, Kind: "PersistentVolume"}})
+			}
+		case *corev1.PersistentVolume:
+			ref := o.Spec.ClaimRef
+			if ref == nil || ref.Kind != "" && ref.Kind != "PersistentVolumeClaim" {
+				continue
+			}
+			claim := GraphEntityKey{Name: ref.Name, Namespace: ref.Namespace, Kind: "PersistentVolumeClaim"}
+			if pvc, ok := claims[claim]; ok && ref.UID != "" && pvc.UID != ref.UID {
+				continue
+			}
+			bind(binding{claim: claim, volume: ko.graphKey})
+		}
+	}
+	for _, b := range bindings {
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           b.claim,
+			Target:           b.volume,
+			RelationshipType: "BOUND_TO",
+			Revision:         revision,
+		})
+	}
+}
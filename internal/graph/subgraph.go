@@ -0,0 +1,131 @@
+package graph
+
+import "k8s.io/apimachinery/pkg/labels"
+
+// LabelsProperty is the node property holding the object's labels, rendered
+// as "k1=v1,k2=v2".
+const LabelsProperty = "labels"
+
+// ExternalProperty marks nodes included in a subgraph only because a kept
+// relationship points at them.
+const ExternalProperty = "external"
+
+// Filter selects the nodes kept by Subgraph. Empty fields match everything.
+type Filter struct {
+	Kinds      []string
+	Namespaces []string
+	// LabelSelector, if set, keeps only nodes whose labels it matches.
+	LabelSelector labels.Selector
+	// IncludeExternal keeps relationships with one endpoint outside the filter
+	// and adds that endpoint as a node marked external=true.
+	IncludeExternal bool
+}
+
+// Matches reports whether a node key passes the kind and namespace filters.
+func (f Filter) Matches(key GraphEntityKey) bool {
+	return matchesAny(f.Kinds, key.Kind) && matchesAny(f.Namespaces, key.Namespace)
+}
+
+// MatchesNode reports whether a node passes every filter, labels included.
+func (f Filter) MatchesNode(node GraphNode) bool {
+	if !f.Matches(node.Key) {
+		return false
+	}
+	return f.LabelSelector == nil || f.LabelSelector.Matches(NodeLabels(node))
+}
+
+// NodeLabels parses the labels property of a node.
+func NodeLabels(node GraphNode) labels.Set {
+	raw := node.Properties[LabelsProperty]
+	if raw == "" {
+		return labels.Set{}
+	}
+	set, err := labels.ConvertSelectorToLabelsMap(raw)
+	if err != nil {
+		return labels.Set{}
+	}
+	return set
+}
+
+// Subgraph returns the nodes of g matching f and the relationships between
+// them. Nodes and property maps are shared with g, except for external nodes
+// which get their own copy.
+func Subgraph(g Graph, f Filter) Graph {
+	sub := Graph{
+		Nodes:         make([]GraphNode, 0),
+		Relationships: make([]GraphRelationship, 0),
+		GraphRevision: g.GraphRevision,
+		Metadata:      g.Metadata,
+		index:         new(lazyIndex),
+	}
+
+	nodesByKey := make(map[GraphEntityKey]GraphNode, len(g.Nodes))
+	kept := make(map[GraphEntityKey]bool)
+	for _, node := range g.Nodes {
+		nodesByKey[node.Key] = node
+		if f.MatchesNode(node) {
+			kept[node.Key] = true
+			sub.Nodes = append(sub.Nodes, node)
+		}
+	}
+
+	external := make(map[GraphEntityKey]bool)
+	addExternal := func(key GraphEntityKey) {
+		if kept[key] || external[key] {
+			return
+		}
+		external[key] = true
+		node, ok := nodesByKey[key]
+		if !ok {
+			// dangling reference, e.g. a ConfigMap that doesn't exist
+			return
+		}
+		props := make(map[string]string, len(node.Properties)+1)
+		for k, v := range node.Properties {
+			props[k] = v
+		}
+		props[ExternalProperty] = "true"
+		node.Properties = props
+		sub.Nodes = append(sub.Nodes, node)
+	}
+
+	for _, rel := range g.Relationships {
+		srcIn, dstIn := kept[rel.Source], kept[rel.Target]
+		switch {
+		case srcIn && dstIn:
+			sub.Relationships = append(sub.Relationships, rel)
+		case f.IncludeExternal && (srcIn || dstIn):
+			addExternal(rel.Source)
+			addExternal(rel.Target)
+			sub.Relationships = append(sub.Relationships, rel)
+		}
+	}
+	return sub
+}
+
+// Namespaces returns the distinct non-empty namespaces of g's nodes.
+func Namespaces(g Graph) []string {
+	seen := make(map[string]bool)
+	namespaces := make([]string, 0)
+	for _, node := range g.Nodes {
+		ns := node.Key.Namespace
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// matchesAny reports whether value is in allowed, or allowed is empty.
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"strconv"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+)
+
+// topology counts the nodes of a Zone or Region.
+type topology struct {
+	key   GraphEntityKey
+	nodes int
+}
+
+// addTopology adds a Zone node for each zone and a Region node for each
+// region the Nodes' topology labels name, with how many nodes are in them,
+// an IN_ZONE relationship from each Node to its zone and an IN_REGION one
+// from each zone to its region. Nodes without a zone label get neither, and
+// zones without a region no IN_REGION.
+func addTopology(g *Graph, keyed []keyedObject, revision uint64) {
+	var domains []*topology
+	seen := make(map[GraphEntityKey]*topology)
+	count := func(key GraphEntityKey) {
+		t, ok := seen[key]
+		if !ok {
+			t = &topology{key: key}
+			seen[key] = t
+			domains = append(domains, t)
+		}
+		t.nodes++
+	}
+	inRegion := make(map[[2]GraphEntityKey]bool)
+	for _, ko := range keyed {
+		if ko.key.Kind != "Node" {
+			continue
+		}
+		labels := make(map[string]string)
+		addNodeLabelProperties(labels, k8s.GetObjectMeta(ko.obj).Labels)
+		if labels["zone"] == "" {
+			continue
+		}
+		zone := GraphEntityKey{Name: labels["zone"], Kind: "Zone"}
+		count(zone)
+		g.Relationships = append(g.Relationships, GraphRelationship{
+			Source:           ko.graphKey,
+			Target:           zone,
+			RelationshipType: "IN_ZONE",
+			Revision:         revision,
+		})
+		if labels["region"] == "" {
+			continue
+		}
+		region := GraphEntityKey{Name: labels["region"], Kind: "Region"}
+		count(region)
+		if !inRegion[[2]GraphEntityKey{zone, region}] {
+			inRegion[[2]GraphEntityKey{zone, region}] = true
+			g.Relationships = append(g.Relationships, GraphRelationship{
+				Source:           zone,
+				Target:           region,
+				RelationshipType: "IN_REGION",
+				Revision:         revision,
+			})
+		}
+	}
+	for _, t := range domains {
+		g.Nodes = append(g.Nodes, GraphNode{
+			Key:        t.key,
+			Properties: map[string]string{"nodes": strconv.Itoa(t.nodes)},
+			Revision:   revision,
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package graph
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is resolved through the global provider, so spans are no-ops until
+// the application installs one.
+var tracer = otel.Tracer("github.com/tthuwng/satellite/internal/graph")
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// DanglingRelationships counts relationships whose source or target is not a
+// node of g, e.g. a Pod mounting a ConfigMap that doesn't exist.
+func DanglingRelationships(g Graph) int {
+	nodes := make(map[GraphEntityKey]struct{}, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[n.Key] = struct{}{}
+	}
+	dangling := 0
+	for _, r := range g.Relationships {
+		_, src := nodes[r.Source]
+		_, dst := nodes[r.Target]
+		if !src || !dst {
+			dangling++
+		}
+	}
+	return dangling
+}
@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SizeCaps bound the nodes of a graph, so a runaway controller creating
+// objects by the hundred thousand can't fill the disk with graph files.
+// Zero means unlimited.
+type SizeCaps struct {
+	// MaxNodes bounds all nodes.
+	MaxNodes int `json:"maxNodes,omitempty"`
+	// MaxNodesPerKind bounds the nodes of each kind, unless PerKind sets
+	// the kind's own bound.
+	MaxNodesPerKind int            `json:"maxNodesPerKind,omitempty"`
+	PerKind         map[string]int `json:"perKind,omitempty"`
+}
+
+// ParseKindCaps parses a comma-separated list of "Kind=N" bounds and at
+// most one bare "N" bound for the other kinds, e.g. "10000,ConfigMap=2000".
+func ParseKindCaps(list string) (maxPerKind int, perKind map[string]int, err error) {
+	defaultSet := false
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, value, hasKind := strings.Cut(item, "=")
+		if !hasKind {
+			value = kind
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, nil, fmt.Errorf("invalid bound %q: want a non-negative integer", item)
+		}
+		switch {
+		case !hasKind && defaultSet:
+			return 0, nil, fmt.Errorf("more than one bound for all kinds in %q", list)
+		case !hasKind:
+			maxPerKind, defaultSet = n, true
+		case kind == "":
+			return 0, nil, fmt.Errorf("invalid bound %q: missing kind", item)
+		default:
+			if perKind == nil {
+				perKind = make(map[string]int)
+			}
+			perKind[kind] = n
+		}
+	}
+	return maxPerKind, perKind, nil
+}
+
+// Enabled reports whether any bound is set.
+func (c SizeCaps) Enabled() bool {
+	return c.MaxNodes > 0 || c.MaxNodesPerKind > 0 || len(c.PerKind) > 0
+}
+
+// kindCap returns the bound of kind, 0 if none.
+func (c SizeCaps) kindCap(kind string) int {
+	if n, ok := c.PerKind[kind]; ok {
+		return n
+	}
+	return c.MaxNodesPerKind
+}
+
+// Truncation records what SizeCaps dropped from a graph.
+type Truncation struct {
+	// DroppedNodes counts the nodes dropped per kind.
+	DroppedNodes map[string]int `json:"droppedNodes"`
+	// DroppedRelationships counts the relationships dropped with them.
+	DroppedRelationships int      `json:"droppedRelationships"`
+	Caps                 SizeCaps `json:"caps"`
+}
+
+// sampleRank orders the nodes kept under a cap: nodes with relationships
+// first, then by a hash of the key. Only the key and whether a node is
+// connected decide, so the sample is the same from one build to the next
+// unless the objects themselves change, and a new object displaces at most
+// one kept node.
+type sampleRank struct {
+	isolated bool
+	hash     uint64
+	index    int
+}
+
+func compareRanks(a, b sampleRank) int {
+	if a.isolated != b.isolated {
+		if a.isolated {
+			return 1
+		}
+		return -1
+	}
+	if c := cmp.Compare(a.hash, b.hash); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.index, b.index)
+}
+
+func keyHash(k GraphEntityKey) uint64 {
+	h := fnv.New64a()
+	for _, s := range []string{k.Cluster, k.APIGroup, k.Kind, k.Namespace, k.Name} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// truncate drops the nodes of g over caps, and the relationships touching
+// them, keeping a deterministic sample of each kind and then of the whole
+// graph. It records the drops in g's metadata and returns them, nil if
+// nothing was dropped.
+func truncate(g *Graph, caps SizeCaps) *Truncation {
+	if !caps.Enabled() {
+		return nil
+	}
+	connected := make(map[GraphEntityKey]bool, len(g.Nodes))
+	for _, r := range g.Relationships {
+		connected[r.Source] = true
+		connected[r.Target] = true
+	}
+	byKind := make(map[string][]sampleRank)
+	for i, n := range g.Nodes {
+		byKind[n.Key.Kind] = append(byKind[n.Key.Kind], sampleRank{isolated: !connected[n.Key], hash: keyHash(n.Key), index: i})
+	}
+
+	var kept []sampleRank
+	for kind, ranks := range byKind {
+		if limit := caps.kindCap(kind); limit > 0 && len(ranks) > limit {
+			slices.SortFunc(ranks, compareRanks)
+			ranks = ranks[:limit]
+		}
+		kept = append(kept, ranks...)
+	}
+	if caps.MaxNodes > 0 && len(kept) > caps.MaxNodes {
+		slices.SortFunc(kept, compareRanks)
+		kept = kept[:caps.MaxNodes]
+	}
+	if len(kept) == len(g.Nodes) {
+		return nil
+	}
+
+	keep := make([]bool, len(g.Nodes))
+	for _, r := range kept {
+		keep[r.index] = true
+	}
+	t := &Truncation{DroppedNodes: make(map[string]int), Caps: caps}
+	dropped := make(map[GraphEntityKey]bool, len(g.Nodes)-len(kept))
+	nodes := g.Nodes[:0]
+	for i, n := range g.Nodes {
+		if keep[i] {
+			nodes = append(nodes, n)
+			continue
+		}
+		dropped[n.Key] = true
+		t.DroppedNodes[n.Key.Kind]++
+	}
+	clear(g.Nodes[len(nodes):])
+	g.Nodes = nodes
+
+	rels := g.Relationships[:0]
+	for _, r := range g.Relationships {
+		if dropped[r.Source] || dropped[r.Target] {
+			t.DroppedRelationships++
+			continue
+		}
+		rels = append(rels, r)
+	}
+	clear(g.Relationships[len(rels):])
+	g.Relationships = rels
+	g.Meta().Truncation = t
+	return t
+}
+
+// reportTruncation logs and exports the drops of the last build, t nil if
+// none.
+func reportTruncation(t *Truncation, revision uint64) {
+	metrics.TruncatedNodes.Reset()
+	if t == nil {
+		metrics.TruncatedRelationships.Set(0)
+		return
+	}
+	total := 0
+	fields := log.Fields{"revision": revision, "droppedRelationships": t.DroppedRelationships}
+	for _, kind := range slices.Sorted(maps.Keys(t.DroppedNodes)) {
+		n := t.DroppedNodes[kind]
+		total += n
+		fields["dropped."+kind] = n
+		metrics.TruncatedNodes.WithLabelValues(kind).Set(float64(n))
+	}
+	metrics.TruncatedRelationships.Set(float64(t.DroppedRelationships))
+	log.WithFields(fields).Warnf("Graph truncated: dropped %d nodes over the size caps; the graph is incomplete", total)
+}
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// addUnstructuredProperties sets what is known of any resource watched
+// through the dynamic client: its apiVersion and, if it reports a Ready
+// condition as many controllers do, that condition's status and reason.
+func addUnstructuredProperties(props map[string]string, u *unstructured.Unstructured) {
+	props["apiVersion"] = u.GetAPIVersion()
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+		if status, ok := cond["status"].(string); ok {
+			props["status.ready"] = status
+		}
+		if reason, ok := cond["reason"].(string); ok && reason != "" {
+			props["status.readyReason"] = reason
+		}
+	}
+}
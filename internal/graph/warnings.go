@@ -0,0 +1,21 @@
+package graph
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+)
+
+// WarningCountProperty is the number of Warning events about an object
+// within the last 15 minutes (cache.WarningWindow).
+const WarningCountProperty = "warningCount15m"
+
+// addWarningProperties sets the summary of an object's recent Warning
+// events: their count and the reason, message and time of the last one.
+func addWarningProperties(props map[string]string, summary cache.WarningSummary) {
+	props[WarningCountProperty] = strconv.Itoa(summary.Count)
+	props["lastWarningReason"] = summary.LastReason
+	props["lastWarningMessage"] = summary.LastMessage
+	props["lastWarningTime"] = summary.LastTime.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"net/url"
+	"strconv"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+)
+
+// webhook is what the graph reads of a mutating or validating webhook.
+type webhook struct {
+	name          string
+	clientConfig  admissionregistrationv1.WebhookClientConfig
+	rules         []admissionregistrationv1.RuleWithOperations
+	failurePolicy string
+}
+
+// newWebhook returns the webhook name, with failure policy Fail if unset,
+// the v1 default.
+func newWebhook(name string, cc admissionregistrationv1.WebhookClientConfig, rules []admissionregistrationv1.RuleWithOperations, policy *admissionregistrationv1.FailurePolicyType) webhook {
+	wh := webhook{name: name, clientConfig: cc, rules: rules, failurePolicy: string(admissionregistrationv1.Fail)}
+	if policy != nil {
+		wh.failurePolicy = string(*policy)
+	}
+	return wh
+}
+
+func mutatingWebhooks(cfg *admissionregistrationv1.MutatingWebhookConfiguration) []webhook {
+	out := make([]webhook, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		out = append(out, newWebhook(wh.Name, wh.ClientConfig, wh.Rules, wh.FailurePolicy))
+	}
+	return out
+}
+
+func validatingWebhooks(cfg *admissionregistrationv1.ValidatingWebhookConfiguration) []webhook {
+	out := make([]webhook, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		out = append(out, newWebhook(wh.Name, wh.ClientConfig, wh.Rules, wh.FailurePolicy))
+	}
+	return out
+}
+
+// addWebhookProperties summarizes the webhooks of a configuration: how many
+// there are, their failure policies, how many rules they have, the
+// operations and how many group/resource pairs those cover, and
+// rules.wildcard=true if any matches "*" groups or resources.
+func addWebhookProperties(props map[string]string, webhooks []webhook) {
+	props["webhooks"] = strconv.Itoa(len(webhooks))
+	var policies, operations []string
+	resources := make(map[string]bool)
+	rules := 0
+	wildcard := false
+	for _, wh := range webhooks {
+		policies = append(policies, wh.failurePolicy)
+		for _, rule := range wh.rules {
+			rules++
+			for _, op := range rule.Operations {
+				operations = append(operations, string(op))
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					resources[group+"/"+resource] = true
+					wildcard = wildcard || group == "*" || resource == "*" || resource == "*/*"
+				}
+			}
+		}
+	}
+	if len(policies) > 0 {
+		props["failurePolicy"] = joinSorted(policies)
+	}
+	props["rules"] = strconv.Itoa(rules)
+	if len(operations) > 0 {
+		props["rules.operations"] = joinSorted(operations)
+	}
+	props["rules.resources"] = strconv.Itoa(len(resources))
+	props["rules.wildcard"] = strconv.FormatBool(wildcard)
+}
+
+// webhookCall collects the webhooks of a configuration calling one target.
+type webhookCall struct {
+	target          GraphEntityKey
+	url             string
+	names, policies []string
+}
+
+// webhookRelationships returns a CALLS relationship from a webhook
+// configuration to each Service its webhooks are served by, and to a URL
+// node for each URL they call, carrying the url. Both list the webhooks in
+// webhook and their failure policies in failurePolicy. A Service that is
+// missing fails its webhooks, so it is checked by deps.
+func webhookRelationships(webhooks []webhook, source GraphEntityKey, deps *dependencies, revision uint64) []GraphRelationship {
+	var calls []*webhookCall
+	byTarget := make(map[GraphEntityKey]*webhookCall)
+	for _, wh := range webhooks {
+		var call webhookCall
+		switch cc := wh.clientConfig; {
+		case cc.Service != nil && cc.Service.Name != "":
+			call.target = GraphEntityKey{Name: cc.Service.Name, Namespace: cc.Service.Namespace, Kind: "Service"}
+		case cc.URL != nil && *cc.URL != "":
+			call.url = webhookURL(*cc.URL)
+			call.target = GraphEntityKey{Name: call.url, Kind: "URL"}
+		default:
+			continue
+		}
+		c, ok := byTarget[call.target]
+		if !ok {
+			c = &call
+			byTarget[call.target] = c
+			calls = append(calls, c)
+		}
+		c.names = append(c.names, wh.name)
+		c.policies = append(c.policies, wh.failurePolicy)
+	}
+
+	var rels []GraphRelationship
+	for _, c := range calls {
+		rel := GraphRelationship{
+			Source:           source,
+			Target:           c.target,
+			RelationshipType: "CALLS",
+			Properties:       map[string]string{"webhook": joinSorted(c.names), "failurePolicy": joinSorted(c.policies)},
+			Revision:         revision,
+		}
+		if c.url != "" {
+			rel.Properties["url"] = c.url
+		} else {
+			deps.check(&rel)
+		}
+		rels = append(rels, rel)
+	}
+	return rels
+}
+
+// addWebhookURLs adds a URL node for each URL the CALLS relationships of
+// webhook configurations point to.
+func addWebhookURLs(g *Graph, revision uint64) {
+	seen := make(map[GraphEntityKey]bool)
+	for _, r := range g.Relationships {
+		if r.RelationshipType != "CALLS" || r.Target.Kind != "URL" || seen[r.Target] {
+			continue
+		}
+		seen[r.Target] = true
+		g.Nodes = append(g.Nodes, GraphNode{Key: r.Target, Properties: map[string]string{"url": r.Target.Name}, Revision: revision})
+	}
+}
+
+// webhookURL returns raw without user info, query or fragment, which the
+// API rejects but which would carry credentials if they got through.
+func webhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "invalid"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
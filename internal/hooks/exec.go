@@ -0,0 +1,64 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Exec returns a hook running command, split on whitespace, with the path of
+// a temporary file holding the graph JSON appended as its last argument. The
+// file is removed once the command exits. SATELLITE_REVISION and
+// SATELLITE_CHANGED (the number of changed nodes) are added to its
+// environment. The command is killed when the hook times out.
+func Exec(command string) (OnGraphBuiltFunc, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty hook command")
+	}
+	return func(ctx context.Context, g graph.Graph, changed []types.EntityKey) {
+		if err := runCommand(ctx, args, g, changed); err != nil {
+			log.WithField("command", args[0]).WithField("revision", g.GraphRevision).WithError(err).Error("Build hook command failed")
+		}
+	}, nil
+}
+
+// runCommand writes g to a temporary file and runs args with its path.
+func runCommand(ctx context.Context, args []string, g graph.Graph, changed []types.EntityKey) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("failed to marshal graph to JSON: %w", err)
+	}
+	f, err := os.CreateTemp("", "satellite-graph-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create graph file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write graph file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], f.Name())...)
+	cmd.Env = append(os.Environ(),
+		"SATELLITE_REVISION="+strconv.FormatUint(g.GraphRevision, 10),
+		"SATELLITE_CHANGED="+strconv.Itoa(len(changed)))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	log.WithField("command", args[0]).WithField("revision", g.GraphRevision).Debugf("Build hook command output: %s", strings.TrimSpace(string(out)))
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package hooks runs custom logic after every graph build, e.g. pushing a
+// metric or running a policy check, without writing a whole emitter.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout bounds each hook call unless the registry sets its own.
+const DefaultTimeout = 10 * time.Second
+
+// OnGraphBuiltFunc is called with every built graph and the keys of the
+// nodes added, updated or removed since the previous build (every node on
+// the first one). Hooks must not modify g.
+type OnGraphBuiltFunc func(ctx context.Context, g graph.Graph, changed []types.EntityKey)
+
+// Registry holds the registered hooks. They are run one after another, in
+// registration order, after a graph is built and validated and before it is
+// handed to the emit queue, so a slow hook delays the emit by up to Timeout.
+type Registry struct {
+	// Timeout bounds each hook call; its context is cancelled then, and a
+	// hook still running is abandoned. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []OnGraphBuiltFunc
+	prev  graph.Graph
+}
+
+// Default is the registry the binary's graph builds run.
+var Default = &Registry{}
+
+// RegisterOnGraphBuilt adds fn to the Default registry.
+func RegisterOnGraphBuilt(fn OnGraphBuiltFunc) {
+	Default.RegisterOnGraphBuilt(fn)
+}
+
+// RegisterOnGraphBuilt adds fn after the hooks already registered.
+func (r *Registry) RegisterOnGraphBuilt(fn OnGraphBuiltFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, fn)
+}
+
+// Len returns the number of registered hooks.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.hooks)
+}
+
+// Run calls every hook with g. A hook that panics or times out is logged
+// and the next one still runs. Builds are expected one at a time.
+func (r *Registry) Run(ctx context.Context, g graph.Graph) {
+	r.mu.Lock()
+	hooks := append([]OnGraphBuiltFunc(nil), r.hooks...)
+	prev := r.prev
+	r.prev = g
+	r.mu.Unlock()
+	if len(hooks) == 0 {
+		return
+	}
+
+	changed := changedKeys(graph.Diff(prev, g))
+	for i, fn := range hooks {
+		if err := r.call(ctx, fn, g, changed); err != nil {
+			log.WithField("hook", i).WithField("revision", g.GraphRevision).WithError(err).Error("OnGraphBuilt hook failed")
+		}
+	}
+}
+
+// call runs one hook with the timeout, recovering a panic into an error.
+func (r *Registry) call(ctx context.Context, fn OnGraphBuiltFunc, g graph.Graph, changed []types.EntityKey) error {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+			}
+		}()
+		fn(ctx, g, changed)
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("hook abandoned: %w", ctx.Err())
+	}
+}
+
+// changedKeys returns the keys of the nodes d adds, updates or removes.
+func changedKeys(d graph.Delta) []types.EntityKey {
+	keys := make([]types.EntityKey, 0, len(d.AddedNodes)+len(d.UpdatedNodes)+len(d.RemovedNodes))
+	for _, n := range d.AddedNodes {
+		keys = append(keys, entityKey(n.Key))
+	}
+	for _, n := range d.UpdatedNodes {
+		keys = append(keys, entityKey(n.Key))
+	}
+	for _, k := range d.RemovedNodes {
+		keys = append(keys, entityKey(k))
+	}
+	return keys
+}
+
+// entityKey converts a graph key back to a cache key.
+func entityKey(k graph.GraphEntityKey) types.EntityKey {
+	return types.EntityKey{Kind: k.Kind, APIGroup: k.APIGroup, Namespace: k.Namespace, Name: k.Name, Cluster: k.Cluster}
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+var (
+	dynamicMu    sync.RWMutex
+	dynamicKinds = make(map[string]WatchedKind)
+)
+
+// ParseResources parses a comma-separated list of resources to watch
+// through the dynamic client, each group/version/resource, e.g.
+// "cert-manager.io/v1/certificates", or version/resource for the core group.
+func ParseResources(list string) ([]schema.GroupVersionResource, error) {
+	var gvrs []schema.GroupVersionResource
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "/")
+		if len(parts) == 2 {
+			parts = append([]string{""}, parts...)
+		}
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resource %q, expected group/version/resource", entry)
+		}
+		gvrs = append(gvrs, schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]})
+	}
+	return gvrs, nil
+}
+
+// ResolveResources looks up the kind and scope of each resource through
+// discovery and returns their WatchedKinds, registered so KindGroup knows
+// them. Resources the server doesn't serve, and kinds already watched, are
+// skipped with a warning rather than failing the startup.
+func ResolveResources(disc discovery.DiscoveryInterface, gvrs []schema.GroupVersionResource) []WatchedKind {
+	var kinds []WatchedKind
+	seen := make(map[string]bool)
+	for _, wk := range WatchedKinds {
+		seen[wk.Kind] = true
+	}
+	for _, gvr := range gvrs {
+		logger := log.WithField("resource", gvr.String())
+		list, err := disc.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err != nil {
+			logger.WithError(err).Warn("Could not discover extra resource; not watching it")
+			continue
+		}
+		found := false
+		for _, res := range list.APIResources {
+			if res.Name != gvr.Resource {
+				continue
+			}
+			found = true
+			if seen[res.Kind] {
+				logger.WithField("kind", res.Kind).Warn("Extra resource's kind is already watched; not watching it")
+				break
+			}
+			seen[res.Kind] = true
+			kinds = append(kinds, WatchedKind{
+				Kind:          res.Kind,
+				Group:         gvr.Group,
+				Version:       gvr.Version,
+				Resource:      gvr.Resource,
+				ClusterScoped: !res.Namespaced,
+				Dynamic:       true,
+			})
+			break
+		}
+		if !found {
+			logger.Warn("Extra resource is not served; not watching it")
+		}
+	}
+	RegisterDynamicKinds(kinds)
+	return kinds
+}
+
+// RegisterDynamicKinds records kinds watched through the dynamic client,
+// for KindGroup and KindClusterScoped.
+func RegisterDynamicKinds(kinds []WatchedKind) {
+	dynamicMu.Lock()
+	defer dynamicMu.Unlock()
+	for _, wk := range kinds {
+		dynamicKinds[wk.Kind] = wk
+	}
+}
+
+// dynamicKind returns the registered dynamic kind.
+func dynamicKind(kind string) (WatchedKind, bool) {
+	dynamicMu.RLock()
+	defer dynamicMu.RUnlock()
+	wk, ok := dynamicKinds[kind]
+	return wk, ok
+}
+
+// NewDynamicInformer returns the shared informer from factory watching wk,
+// delivering *unstructured.Unstructured objects.
+func NewDynamicInformer(factory dynamicinformer.DynamicSharedInformerFactory, wk WatchedKind) cache.SharedIndexInformer {
+	return factory.ForResource(wk.GroupVersionResource()).Informer()
+}
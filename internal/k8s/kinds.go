@@ -0,0 +1,229 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/metadata/metadatainformer"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// WatchedKind describes a resource kind Satellite builds informers for.
+type WatchedKind struct {
+	Kind          string
+	Group         string // Empty for the core API group
+	Version       string // v1 if empty
+	Resource      string
+	ClusterScoped bool
+	FieldSelector string // restricts the objects listed and watched
+	OptIn         bool   // only watched when selected explicitly
+	Dynamic       bool   // watched through the dynamic client, see ResolveResources
+}
+
+// WatchedKinds lists every kind Satellite watches, in informer start order.
+var WatchedKinds = []WatchedKind{
+	{Kind: "Pod", Group: "", Resource: "pods"},
+	{Kind: "ReplicaSet", Group: "apps", Resource: "replicasets"},
+	{Kind: "Deployment", Group: "apps", Resource: "deployments"},
+	{Kind: "StatefulSet", Group: "apps", Resource: "statefulsets"},
+	{Kind: "DaemonSet", Group: "apps", Resource: "daemonsets"},
+	{Kind: "Node", Group: "", Resource: "nodes", ClusterScoped: true},
+	{Kind: "Service", Group: "", Resource: "services"},
+	{Kind: "ConfigMap", Group: "", Resource: "configmaps"},
+	{Kind: "EndpointSlice", Group: "discovery.k8s.io", Resource: "endpointslices"},
+	{Kind: "Ingress", Group: "networking.k8s.io", Resource: "ingresses"},
+	{Kind: "NetworkPolicy", Group: "networking.k8s.io", Resource: "networkpolicies"},
+	{Kind: "ServiceAccount", Group: "", Resource: "serviceaccounts"},
+	{Kind: "PersistentVolumeClaim", Group: "", Resource: "persistentvolumeclaims"},
+	{Kind: "PersistentVolume", Group: "", Resource: "persistentvolumes", ClusterScoped: true},
+	{Kind: "Namespace", Group: "", Resource: "namespaces", ClusterScoped: true},
+	{Kind: "ResourceQuota", Group: "", Resource: "resourcequotas"},
+	{Kind: "LimitRange", Group: "", Resource: "limitranges"},
+	{Kind: "Job", Group: "batch", Resource: "jobs"},
+	{Kind: "CronJob", Group: "batch", Resource: "cronjobs"},
+	{Kind: "Role", Group: "rbac.authorization.k8s.io", Resource: "roles"},
+	{Kind: "ClusterRole", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", ClusterScoped: true},
+	{Kind: "RoleBinding", Group: "rbac.authorization.k8s.io", Resource: "rolebindings"},
+	{Kind: "ClusterRoleBinding", Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings", ClusterScoped: true},
+	{Kind: "HorizontalPodAutoscaler", Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+	{Kind: "PriorityClass", Group: "scheduling.k8s.io", Resource: "priorityclasses", ClusterScoped: true},
+	{Kind: "RuntimeClass", Group: "node.k8s.io", Resource: "runtimeclasses", ClusterScoped: true},
+	{Kind: "MutatingWebhookConfiguration", Group: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations", ClusterScoped: true},
+	{Kind: "ValidatingWebhookConfiguration", Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations", ClusterScoped: true},
+	// keys only: values are dropped as they are received
+	{Kind: "Secret", Group: "", Resource: "secrets", OptIn: true},
+	// kept, reduced, per involved object and summarized onto it
+	{Kind: "Event", Group: "", Resource: "events", FieldSelector: "type=Warning"},
+}
+
+// NewInformer returns the shared informer for kind from factory.
+func NewInformer(factory informers.SharedInformerFactory, kind string) (cache.SharedIndexInformer, bool) {
+	switch kind {
+	case "Pod":
+		return factory.Core().V1().Pods().Informer(), true
+	case "ReplicaSet":
+		return factory.Apps().V1().ReplicaSets().Informer(), true
+	case "Deployment":
+		return factory.Apps().V1().Deployments().Informer(), true
+	case "StatefulSet":
+		return factory.Apps().V1().StatefulSets().Informer(), true
+	case "DaemonSet":
+		return factory.Apps().V1().DaemonSets().Informer(), true
+	case "Node":
+		return factory.Core().V1().Nodes().Informer(), true
+	case "Service":
+		return factory.Core().V1().Services().Informer(), true
+	case "ConfigMap":
+		return factory.Core().V1().ConfigMaps().Informer(), true
+	case "EndpointSlice":
+		return factory.Discovery().V1().EndpointSlices().Informer(), true
+	case "Ingress":
+		return factory.Networking().V1().Ingresses().Informer(), true
+	case "NetworkPolicy":
+		return factory.Networking().V1().NetworkPolicies().Informer(), true
+	case "ServiceAccount":
+		return factory.Core().V1().ServiceAccounts().Informer(), true
+	case "PersistentVolumeClaim":
+		return factory.Core().V1().PersistentVolumeClaims().Informer(), true
+	case "PersistentVolume":
+		return factory.Core().V1().PersistentVolumes().Informer(), true
+	case "Namespace":
+		return factory.Core().V1().Namespaces().Informer(), true
+	case "ResourceQuota":
+		return factory.Core().V1().ResourceQuotas().Informer(), true
+	case "LimitRange":
+		return factory.Core().V1().LimitRanges().Informer(), true
+	case "Job":
+		return factory.Batch().V1().Jobs().Informer(), true
+	case "CronJob":
+		return factory.Batch().V1().CronJobs().Informer(), true
+	case "Role":
+		return factory.Rbac().V1().Roles().Informer(), true
+	case "ClusterRole":
+		return factory.Rbac().V1().ClusterRoles().Informer(), true
+	case "RoleBinding":
+		return factory.Rbac().V1().RoleBindings().Informer(), true
+	case "ClusterRoleBinding":
+		return factory.Rbac().V1().ClusterRoleBindings().Informer(), true
+	case "HorizontalPodAutoscaler":
+		return factory.Autoscaling().V2().HorizontalPodAutoscalers().Informer(), true
+	case "PriorityClass":
+		return factory.Scheduling().V1().PriorityClasses().Informer(), true
+	case "RuntimeClass":
+		return factory.Node().V1().RuntimeClasses().Informer(), true
+	case "MutatingWebhookConfiguration":
+		return factory.Admissionregistration().V1().MutatingWebhookConfigurations().Informer(), true
+	case "ValidatingWebhookConfiguration":
+		return factory.Admissionregistration().V1().ValidatingWebhookConfigurations().Informer(), true
+	case "Secret":
+		return factory.Core().V1().Secrets().Informer(), true
+	case "Event":
+		return factory.Core().V1().Events().Informer(), true
+	default:
+		return nil, false
+	}
+}
+
+// TweakListOptions applies wk's FieldSelector to list and watch calls and
+// asks for watch bookmarks, which the server ignores on lists.
+func (wk WatchedKind) TweakListOptions(opts *metav1.ListOptions) {
+	opts.FieldSelector = wk.FieldSelector
+	opts.AllowWatchBookmarks = true
+}
+
+// KindGroup returns the API group of the watched kind, dynamic ones
+// included, empty for the core group and for unknown kinds.
+func KindGroup(kind string) string {
+	for _, wk := range WatchedKinds {
+		if wk.Kind == kind {
+			return wk.Group
+		}
+	}
+	wk, _ := dynamicKind(kind)
+	return wk.Group
+}
+
+// KindVersion returns the API version watched for the kind, dynamic ones
+// included, v1 for unknown kinds.
+func KindVersion(kind string) string {
+	for _, wk := range WatchedKinds {
+		if wk.Kind == kind {
+			return wk.GroupVersionResource().Version
+		}
+	}
+	if wk, ok := dynamicKind(kind); ok {
+		return wk.GroupVersionResource().Version
+	}
+	return "v1"
+}
+
+// KindClusterScoped reports whether the watched kind, dynamic ones
+// included, is cluster-scoped; false for unknown kinds.
+func KindClusterScoped(kind string) bool {
+	for _, wk := range WatchedKinds {
+		if wk.Kind == kind {
+			return wk.ClusterScoped
+		}
+	}
+	wk, _ := dynamicKind(kind)
+	return wk.ClusterScoped
+}
+
+// GroupVersionResource returns the API resource of wk.
+func (wk WatchedKind) GroupVersionResource() schema.GroupVersionResource {
+	version := wk.Version
+	if version == "" {
+		version = "v1"
+	}
+	return schema.GroupVersionResource{Group: wk.Group, Version: version, Resource: wk.Resource}
+}
+
+// NewMetadataInformer returns the shared informer from factory watching only
+// the metadata of wk, delivered as *metav1.PartialObjectMetadata. The metadata
+// API leaves their kind and apiVersion empty; they are filled in so GetKey
+// recognises the objects. Secrets lose their last-applied-configuration
+// annotation, which repeats their values, and any data hash annotation.
+func NewMetadataInformer(factory metadatainformer.SharedInformerFactory, wk WatchedKind) (cache.SharedIndexInformer, error) {
+	gvr := wk.GroupVersionResource()
+	inf := factory.ForResource(gvr).Informer()
+	err := inf.SetTransform(func(obj interface{}) (interface{}, error) {
+		if m, ok := obj.(*metav1.PartialObjectMetadata); ok {
+			m.TypeMeta = metav1.TypeMeta{APIVersion: gvr.GroupVersion().String(), Kind: wk.Kind}
+			if IsSecret(m) {
+				// values never reach the informer's store, and the data
+				// hash is only ever set by TrimSecret
+				dropAnnotation(m, LastAppliedAnnotation)
+				dropAnnotation(m, DataHashAnnotation)
+			}
+		}
+		return obj, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set metadata transform for %s: %w", wk.Kind, err)
+	}
+	return inf, nil
+}
+
+// ParseKinds parses a comma-separated list of watched kinds, e.g.
+// "ConfigMap,Secret", into a set.
+func ParseKinds(list string) (map[string]bool, error) {
+	kinds := make(map[string]bool)
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		known := false
+		for _, wk := range WatchedKinds {
+			known = known || wk.Kind == kind
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown kind %q", kind)
+		}
+		kinds[kind] = true
+	}
+	return kinds, nil
+}
@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/pager"
+)
+
+// listPage returns the list call of wk in namespace; namespace is ignored for
+// cluster-scoped kinds.
+func listPage(client kubernetes.Interface, wk WatchedKind, namespace string) (pager.ListPageFunc, bool) {
+	if wk.ClusterScoped {
+		namespace = ""
+	}
+	switch wk.Kind {
+	case "Pod":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods(namespace).List(ctx, opts)
+		}, true
+	case "ReplicaSet":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+		}, true
+	case "Deployment":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().Deployments(namespace).List(ctx, opts)
+		}, true
+	case "StatefulSet":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		}, true
+	case "DaemonSet":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		}, true
+	case "Node":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Nodes().List(ctx, opts)
+		}, true
+	case "Service":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Services(namespace).List(ctx, opts)
+		}, true
+	case "ConfigMap":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		}, true
+	case "EndpointSlice":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.DiscoveryV1().EndpointSlices(namespace).List(ctx, opts)
+		}, true
+	case "Ingress":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+		}, true
+	case "NetworkPolicy":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.NetworkingV1().NetworkPolicies(namespace).List(ctx, opts)
+		}, true
+	case "ResourceQuota":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ResourceQuotas(namespace).List(ctx, opts)
+		}, true
+	case "LimitRange":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().LimitRanges(namespace).List(ctx, opts)
+		}, true
+	case "ServiceAccount":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		}, true
+	case "PersistentVolumeClaim":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		}, true
+	case "PersistentVolume":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumes().List(ctx, opts)
+		}, true
+	case "Namespace":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Namespaces().List(ctx, opts)
+		}, true
+	case "Job":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.BatchV1().Jobs(namespace).List(ctx, opts)
+		}, true
+	case "CronJob":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.BatchV1().CronJobs(namespace).List(ctx, opts)
+		}, true
+	case "Secret":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Secrets(namespace).List(ctx, opts)
+		}, true
+	case "Event":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Events(namespace).List(ctx, opts)
+		}, true
+	case "Role":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().Roles(namespace).List(ctx, opts)
+		}, true
+	case "ClusterRole":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoles().List(ctx, opts)
+		}, true
+	case "RoleBinding":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().RoleBindings(namespace).List(ctx, opts)
+		}, true
+	case "ClusterRoleBinding":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		}, true
+	case "HorizontalPodAutoscaler":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, opts)
+		}, true
+	case "PriorityClass":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.SchedulingV1().PriorityClasses().List(ctx, opts)
+		}, true
+	case "RuntimeClass":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.NodeV1().RuntimeClasses().List(ctx, opts)
+		}, true
+	case "MutatingWebhookConfiguration":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, opts)
+		}, true
+	case "ValidatingWebhookConfiguration":
+		return func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, opts)
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// List returns every object of wk in namespace ("" for all namespaces) with
+// plain, paged list calls, so it needs no watch permission. Callers check
+// apierrors.IsForbidden on the error to skip kinds they may not list.
+func List(ctx context.Context, client kubernetes.Interface, wk WatchedKind, namespace string) ([]runtime.Object, error) {
+	page, ok := listPage(client, wk, namespace)
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q", wk.Kind)
+	}
+	var objects []runtime.Object
+	var opts metav1.ListOptions
+	wk.TweakListOptions(&opts)
+	err := pager.New(page).EachListItem(ctx, opts, func(obj runtime.Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", wk.Resource, err)
+	}
+	return objects, nil
+}
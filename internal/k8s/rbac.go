@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// informerVerbs are the verbs a reflector needs to list and watch a resource.
+var informerVerbs = []string{"list", "watch"}
+
+// MissingPermission is a verb on a resource the current identity may not use.
+type MissingPermission struct {
+	Kind     string
+	Resource string
+	Verb     string
+}
+
+// String renders the permission as "<resource>.<verb>", e.g. "pods.watch".
+func (m MissingPermission) String() string {
+	return m.Resource + "." + m.Verb
+}
+
+// MissingPermissionsError is the consolidated preflight failure.
+type MissingPermissionsError struct {
+	Missing []MissingPermission
+}
+
+func (e *MissingPermissionsError) Error() string {
+	perms := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		perms = append(perms, m.String())
+	}
+	return "missing permissions: " + strings.Join(perms, ", ")
+}
+
+// CheckPermissions runs a SelfSubjectAccessReview for the list and watch verbs
+// of every kind, cluster-wide. It returns a *MissingPermissionsError naming
+// every denied verb, or another error if a review could not be performed.
+func CheckPermissions(ctx context.Context, client kubernetes.Interface, kinds []WatchedKind) error {
+	var missing []MissingPermission
+	for _, wk := range kinds {
+		for _, verb := range informerVerbs {
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:     verb,
+						Group:    wk.Group,
+						Resource: wk.Resource,
+					},
+				},
+			}
+			resp, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to review %s.%s access: %w", wk.Resource, verb, err)
+			}
+			if !resp.Status.Allowed {
+				missing = append(missing, MissingPermission{Kind: wk.Kind, Resource: wk.Resource, Verb: verb})
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingPermissionsError{Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// RedactedValue replaces data values removed by Redact.
+const RedactedValue = "<redacted>"
+
+// LastAppliedAnnotation holds the full manifest as applied by kubectl,
+// including any data it contained.
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Redact returns a copy of obj that is safe to hand out: Secret and
+// ConfigMap data values are replaced (their keys are kept, like the data.keys
+// property), the last-applied-configuration annotation that would repeat them
+// is dropped, also from metadata-only ones, and managedFields are cleared.
+// obj itself is not modified.
+func Redact(obj runtime.Object) runtime.Object {
+	out := obj.DeepCopyObject()
+	switch o := out.(type) {
+	case *corev1.Secret:
+		for k := range o.Data {
+			o.Data[k] = []byte(RedactedValue)
+		}
+		for k := range o.StringData {
+			o.StringData[k] = RedactedValue
+		}
+		delete(o.Annotations, LastAppliedAnnotation)
+	case *corev1.ConfigMap:
+		for k := range o.Data {
+			o.Data[k] = RedactedValue
+		}
+		for k := range o.BinaryData {
+			o.BinaryData[k] = []byte(RedactedValue)
+		}
+		delete(o.Annotations, LastAppliedAnnotation)
+	}
+	if m, err := apimeta.Accessor(out); err == nil {
+		if IsSecret(out) {
+			dropAnnotation(m, LastAppliedAnnotation)
+			if _, typed := out.(*corev1.Secret); !typed {
+				// not computed by TrimSecret, so it may be anything
+				dropAnnotation(m, DataHashAnnotation)
+			}
+		} else if isCoreKind(out, "ConfigMap") {
+			dropAnnotation(m, LastAppliedAnnotation)
+		}
+		m.SetManagedFields(nil)
+	}
+	setTypeMeta(out)
+	return out
+}
+
+// setTypeMeta fills in apiVersion and kind, which informers leave empty.
+func setTypeMeta(obj runtime.Object) {
+	if !obj.GetObjectKind().GroupVersionKind().Empty() {
+		return
+	}
+	if gvk, ok := schemeKind(obj); ok {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+}
+
+// APIVersion returns obj's apiVersion, looked up in the scheme if informers
+// left it empty, or "" if unknown.
+func APIVersion(obj runtime.Object) string {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk.GroupVersion().String()
+	}
+	if gvk, ok := schemeKind(obj); ok {
+		return gvk.GroupVersion().String()
+	}
+	return ""
+}
+
+// schemeKind returns the kind the scheme registers obj's type as.
+func schemeKind(obj runtime.Object) (schema.GroupVersionKind, bool) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return schema.GroupVersionKind{}, false
+	}
+	return gvks[0], true
+}
+
+// dropAnnotation removes the annotation key from m, which may hold a copy of
+// its annotations, as unstructured objects do.
+func dropAnnotation(m metav1.Object, key string) {
+	annotations := m.GetAnnotations()
+	if _, ok := annotations[key]; ok {
+		delete(annotations, key)
+		m.SetAnnotations(annotations)
+	}
+}
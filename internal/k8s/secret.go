@@ -0,0 +1,192 @@
+package k8s
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DataHashAnnotation holds the hash of a trimmed Secret's data, computed
+// before its values were dropped. Only TrimSecret sets it: one found on an
+// incoming Secret is replaced.
+const DataHashAnnotation = "satellite.tthuwng.io/data-hash"
+
+// MinSecretHashKeyLen is the minimum length of a Secret data hash key.
+const MinSecretHashKeyLen = 16
+
+// secretHashKey keys SecretDataHash. It is random unless SetSecretHashKey
+// configured one.
+var secretHashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate the secret hash key: %v", err))
+	}
+	return key
+}()
+
+// SetSecretHashKey keys SecretDataHash with key, of at least
+// MinSecretHashKeyLen bytes, instead of a random per-process key, so hashes
+// compare across restarts and instances. Call it before any Secret is
+// trimmed.
+func SetSecretHashKey(key []byte) error {
+	if len(key) < MinSecretHashKeyLen {
+		return fmt.Errorf("secret hash key is %d bytes, want at least %d", len(key), MinSecretHashKeyLen)
+	}
+	secretHashKey = slices.Clone(key)
+	return nil
+}
+
+// IsSecret reports whether obj is a Secret, typed or, as metadata-only
+// informers and dynamic clients return them, by its kind.
+func IsSecret(obj runtime.Object) bool {
+	return isCoreKind(obj, "Secret")
+}
+
+// isCoreKind reports whether obj is of the core group kind, typed or by
+// its TypeMeta.
+func isCoreKind(obj runtime.Object, kind string) bool {
+	switch obj.(type) {
+	case *corev1.Secret:
+		return kind == "Secret"
+	case *corev1.ConfigMap:
+		return kind == "ConfigMap"
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == kind
+}
+
+// CertPolicy selects the kubernetes.io/tls Secrets whose certificate
+// (tls.crt, never tls.key) is kept to derive its expiry, issuer and SANs.
+// The zero policy keeps none.
+type CertPolicy struct {
+	All     bool
+	Secrets map[string]bool // namespace/name
+}
+
+// ParseCertPolicy parses "*" (every TLS Secret) or a comma-separated list of
+// namespace/name Secrets. An empty list keeps no certificate.
+func ParseCertPolicy(list string) (CertPolicy, error) {
+	if strings.TrimSpace(list) == "*" {
+		return CertPolicy{All: true}, nil
+	}
+	var p CertPolicy
+	for _, ref := range strings.Split(list, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		namespace, name, ok := strings.Cut(ref, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return CertPolicy{}, fmt.Errorf("invalid secret %q: want namespace/name", ref)
+		}
+		if p.Secrets == nil {
+			p.Secrets = make(map[string]bool)
+		}
+		p.Secrets[ref] = true
+	}
+	return p, nil
+}
+
+// Allows reports whether the certificate of the Secret namespace/name is
+// kept.
+func (p CertPolicy) Allows(namespace, name string) bool {
+	return p.All || p.Secrets[namespace+"/"+name]
+}
+
+// SecretDataHash returns a hash of s's data for drift detection: an
+// HMAC-SHA256, keyed by the secret hash key, over the Secret's UID and its
+// sorted keys and values, so equal values in different Secrets don't hash
+// alike and values can't be guessed from the hash without the key. Any
+// DataHashAnnotation on s is ignored.
+func SecretDataHash(s *corev1.Secret) string {
+	mac := hmac.New(sha256.New, secretHashKey)
+	fmt.Fprintf(mac, "%d:%s", len(s.UID), s.UID)
+	keys := make([]string, 0, len(s.Data))
+	for k := range s.Data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(mac, "%d:%s%d:", len(k), k, len(s.Data[k]))
+		mac.Write(s.Data[k])
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DockerConfigRegistries returns the sorted registry hosts of a
+// kubernetes.io/dockerconfigjson Secret. Its credentials are never read
+// beyond the JSON decoder, and a malformed config yields no hosts rather
+// than an error that could quote it.
+func DockerConfigRegistries(s *corev1.Secret) []string {
+	var config struct {
+		Auths map[string]json.RawMessage `json:"auths"`
+	}
+	if json.Unmarshal(s.Data[corev1.DockerConfigJsonKey], &config) != nil {
+		return nil
+	}
+	hosts := make([]string, 0, len(config.Auths))
+	for host := range config.Auths {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+// TrimSecret returns a copy of s keeping its metadata, type and data keys,
+// with every value emptied. The hash of the data is recorded in the
+// DataHashAnnotation first. A dockerconfigjson keeps its registry hosts,
+// without credentials, and with keepCert a TLS Secret keeps tls.crt.
+func TrimSecret(s *corev1.Secret, keepCert bool) *corev1.Secret {
+	out := &corev1.Secret{TypeMeta: s.TypeMeta, ObjectMeta: trimMeta(s.ObjectMeta), Type: s.Type}
+	out.Annotations = make(map[string]string, len(s.Annotations)+1)
+	for k, v := range s.Annotations {
+		if k != LastAppliedAnnotation && k != DataHashAnnotation {
+			out.Annotations[k] = ElideValue(v)
+		}
+	}
+	out.Annotations[DataHashAnnotation] = SecretDataHash(s)
+	if s.Data != nil {
+		out.Data = make(map[string][]byte, len(s.Data))
+		for k := range s.Data {
+			out.Data[k] = nil
+		}
+	}
+	switch {
+	case s.Type == corev1.SecretTypeDockerConfigJson && s.Data != nil:
+		if _, ok := s.Data[corev1.DockerConfigJsonKey]; ok {
+			auths := make(map[string]struct{})
+			for _, host := range DockerConfigRegistries(s) {
+				auths[host] = struct{}{}
+			}
+			out.Data[corev1.DockerConfigJsonKey], _ = json.Marshal(map[string]any{"auths": auths})
+		}
+	case s.Type == corev1.SecretTypeTLS && keepCert:
+		if crt, ok := s.Data[corev1.TLSCertKey]; ok {
+			out.Data[corev1.TLSCertKey] = crt
+		}
+	}
+	return out
+}
+
+// SecretTransform is an informer transform applying TrimSecret to Secrets,
+// keeping the certificates certs allows, and Trim to anything else if trim
+// is set. Secret values are thus never stored.
+func SecretTransform(certs CertPolicy, trim bool) func(interface{}) (interface{}, error) {
+	return func(obj interface{}) (interface{}, error) {
+		if s, ok := obj.(*corev1.Secret); ok {
+			return TrimSecret(s, certs.Allows(s.Namespace, s.Name)), nil
+		}
+		if trim {
+			return TrimTransform(obj)
+		}
+		return obj, nil
+	}
+}
@@ -0,0 +1,279 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// DefaultKindRetryInterval is how often a disabled kind's informer is retried.
+const DefaultKindRetryInterval = 5 * time.Minute
+
+// InformerFunc creates a new, unstarted informer with its event handlers
+// registered. It is called again for every retry of a disabled kind, since a
+// stopped informer can't be restarted.
+type InformerFunc func() (cache.SharedIndexInformer, error)
+
+// KindSupervisor runs one informer per kind and disables a kind whose initial
+// list is forbidden, instead of leaving its reflector to retry, and log,
+// while the initial sync waits for it forever. Disabled kinds are retried
+// with a new informer every RetryInterval, so they come back once RBAC is
+// fixed. A kind that is forbidden after its initial sync is left to Watch.
+type KindSupervisor struct {
+	// Cluster labels log lines; empty in single-cluster mode.
+	Cluster string
+	// RetryInterval overrides DefaultKindRetryInterval.
+	RetryInterval time.Duration
+	// Watch, if set, tracks every other list/watch failure.
+	Watch *WatchHealth
+	// OnChange is called whenever a kind is disabled or re-enabled.
+	OnChange func()
+
+	mu      sync.Mutex
+	kinds   []*supervisedKind // in Add order
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// supervisedKind is the informer state of one kind.
+type supervisedKind struct {
+	kind        string
+	newInformer InformerFunc
+	inf         cache.SharedIndexInformer // nil while disabled
+	stop        context.CancelFunc
+	// disabled is set from a forbidden initial list until a retried
+	// informer has synced.
+	disabled bool
+}
+
+// Add supervises kind. Unless disabled, its informer is created right away,
+// so errors surface before anything starts; a disabled kind, e.g. one that
+// failed an RBAC preflight, only gets one once a retry is due. Add must be
+// called before Start.
+func (s *KindSupervisor) Add(kind string, newInformer InformerFunc, disabled bool) error {
+	k := &supervisedKind{kind: kind, newInformer: newInformer, disabled: disabled}
+	if !disabled {
+		inf, err := s.create(k)
+		if err != nil {
+			return err
+		}
+		k.inf = inf
+	}
+	s.mu.Lock()
+	s.kinds = append(s.kinds, k)
+	s.mu.Unlock()
+	return nil
+}
+
+// Start runs the informers of the enabled kinds and retries the disabled
+// ones until ctx is cancelled or Shutdown is called.
+func (s *KindSupervisor) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for _, k := range s.kinds {
+		if k.inf != nil {
+			s.run(k, k.inf)
+		}
+	}
+	s.wg.Add(1)
+	go s.retryLoop()
+}
+
+// Shutdown stops every informer and waits for them to return.
+func (s *KindSupervisor) Shutdown() {
+	s.mu.Lock()
+	s.stopped = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// HasSynced reports whether kind no longer holds up the initial sync: its
+// informer has synced, or the kind is disabled.
+func (s *KindSupervisor) HasSynced(kind string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.kinds {
+		if k.kind == kind {
+			return k.disabled || (k.inf != nil && k.inf.HasSynced())
+		}
+	}
+	return false
+}
+
+// LastSyncResourceVersions returns, per running kind, the resourceVersion
+// its informer last synced to: that of the latest list, watch event or
+// watch bookmark.
+func (s *KindSupervisor) LastSyncResourceVersions() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.kinds))
+	for _, k := range s.kinds {
+		if k.inf != nil {
+			if rv := k.inf.LastSyncResourceVersion(); rv != "" {
+				out[k.kind] = rv
+			}
+		}
+	}
+	return out
+}
+
+// Disabled returns the disabled kinds, in Add order.
+func (s *KindSupervisor) Disabled() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var disabled []string
+	for _, k := range s.kinds {
+		if k.disabled {
+			disabled = append(disabled, k.kind)
+		}
+	}
+	return disabled
+}
+
+// create builds a new informer for k whose forbidden initial list disables k.
+func (s *KindSupervisor) create(k *supervisedKind) (cache.SharedIndexInformer, error) {
+	inf, err := k.newInformer()
+	if err != nil {
+		return nil, err
+	}
+	handler := func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(context.Background(), r, err)
+	}
+	if s.Watch != nil {
+		handler = s.Watch.Handler(k.kind)
+	}
+	err = inf.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		if apierrors.IsForbidden(err) && !inf.HasSynced() {
+			s.disable(k, inf, err)
+			return
+		}
+		handler(r, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inf, nil
+}
+
+// run starts inf as k's informer. Callers hold s.mu.
+func (s *KindSupervisor) run(k *supervisedKind, inf cache.SharedIndexInformer) {
+	if s.stopped {
+		return
+	}
+	ctx, stop := context.WithCancel(s.ctx)
+	k.inf, k.stop = inf, stop
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		inf.Run(ctx.Done())
+	}()
+}
+
+// disable stops inf, k's informer, after its initial list was forbidden.
+func (s *KindSupervisor) disable(k *supervisedKind, inf cache.SharedIndexInformer, err error) {
+	s.mu.Lock()
+	if k.inf != inf {
+		s.mu.Unlock()
+		return // already replaced or stopped
+	}
+	k.stop()
+	k.inf, k.stop = nil, nil
+	retried := k.disabled
+	k.disabled = true
+	s.mu.Unlock()
+
+	entry := s.logger(k.kind).WithError(err)
+	if retried {
+		entry.Debug("Kind still forbidden; keeping it disabled")
+		return
+	}
+	entry.Warnf("Not allowed to list this kind; disabling it and retrying every %s", s.retryInterval())
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+// retryLoop starts a new informer for every disabled kind each interval.
+func (s *KindSupervisor) retryLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.retryInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.retry()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// retry starts a new informer for every disabled kind that has none running.
+func (s *KindSupervisor) retry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.kinds {
+		if !k.disabled || k.inf != nil || s.stopped {
+			continue
+		}
+		inf, err := s.create(k)
+		if err != nil {
+			s.logger(k.kind).WithError(err).Warn("Failed to create informer for disabled kind")
+			continue
+		}
+		s.run(k, inf)
+		ctx := s.ctx
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.awaitSync(ctx, k, inf)
+		}()
+	}
+}
+
+// awaitSync re-enables k once inf, a retried informer, has synced.
+func (s *KindSupervisor) awaitSync(ctx context.Context, k *supervisedKind, inf cache.SharedIndexInformer) {
+	if !cache.WaitForCacheSync(ctx.Done(), func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return k.inf != inf || inf.HasSynced()
+	}) {
+		return
+	}
+	s.mu.Lock()
+	enabled := k.inf == inf && k.disabled
+	if enabled {
+		k.disabled = false
+	}
+	s.mu.Unlock()
+	if !enabled {
+		return // disabled again
+	}
+	s.logger(k.kind).Info("Kind is allowed again; re-enabled")
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+// retryInterval returns RetryInterval or its default.
+func (s *KindSupervisor) retryInterval() time.Duration {
+	if s.RetryInterval > 0 {
+		return s.RetryInterval
+	}
+	return DefaultKindRetryInterval
+}
+
+// logger returns a log entry tagged with the cluster and kind.
+func (s *KindSupervisor) logger(kind string) *log.Entry {
+	return log.WithFields(log.Fields{"cluster": s.Cluster, "kind": kind})
+}
@@ -0,0 +1,521 @@
+package k8s
+
+import (
+	"strconv"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// MaxAnnotationValue is the length above which an annotation value, e.g. a
+// last-applied-configuration repeating a large ConfigMap, is replaced by
+// ElideValue's placeholder.
+const MaxAnnotationValue = 4096
+
+// ElideValue returns v, or a placeholder naming its size if it is longer
+// than MaxAnnotationValue.
+func ElideValue(v string) string {
+	if len(v) <= MaxAnnotationValue {
+		return v
+	}
+	return "<" + strconv.Itoa(len(v)) + " bytes elided>"
+}
+
+// Trim returns a copy of obj holding only the fields the graph is built from:
+// identity, labels, annotations and owners, plus the spec and status fields
+// property extraction and relationship building read. ConfigMap data keeps
+// its keys with empty values and binaryData is dropped, so ConfigMaps near
+// the 1MiB limit cost no more than their key names; long annotation values
+// are elided. Secrets are trimmed by TrimSecret, keeping tls.crt. Kinds Trim
+// doesn't know are returned as is.
+//
+// Fields read by the graph code must be kept here as well; TestTrim_Parity
+// builds a graph from full and trimmed objects and fails on any difference.
+func Trim(obj runtime.Object) runtime.Object {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		out := &corev1.Pod{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.NodeName = o.Spec.NodeName
+		out.Spec.ServiceAccountName = o.Spec.ServiceAccountName
+		out.Spec.DeprecatedServiceAccount = o.Spec.DeprecatedServiceAccount
+		out.Spec.PriorityClassName = o.Spec.PriorityClassName
+		out.Spec.RuntimeClassName = o.Spec.RuntimeClassName
+		for _, vol := range o.Spec.Volumes {
+			if v, ok := trimVolume(vol); ok {
+				out.Spec.Volumes = append(out.Spec.Volumes, v)
+			}
+		}
+		out.Spec.InitContainers = trimContainers(o.Spec.InitContainers)
+		out.Spec.Containers = trimContainers(o.Spec.Containers)
+		for _, c := range o.Spec.EphemeralContainers {
+			env, envFrom := trimEnvRefs(c.Env, c.EnvFrom)
+			out.Spec.EphemeralContainers = append(out.Spec.EphemeralContainers, corev1.EphemeralContainer{
+				EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: c.Name, Image: c.Image, Env: env, EnvFrom: envFrom},
+			})
+		}
+		out.Spec.ImagePullSecrets = o.Spec.ImagePullSecrets
+		out.Status.Phase = o.Status.Phase
+		out.Status.PodIP = o.Status.PodIP
+		out.Status.HostIP = o.Status.HostIP
+		out.Status.StartTime = o.Status.StartTime
+		out.Status.ContainerStatuses = trimContainerStatuses(o.Status.ContainerStatuses)
+		return out
+	case *appsv1.ReplicaSet:
+		out := &appsv1.ReplicaSet{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Replicas = o.Spec.Replicas
+		out.Spec.Selector = o.Spec.Selector
+		out.Status = appsv1.ReplicaSetStatus{
+			Replicas:          o.Status.Replicas,
+			ReadyReplicas:     o.Status.ReadyReplicas,
+			AvailableReplicas: o.Status.AvailableReplicas,
+		}
+		return out
+	case *appsv1.Deployment:
+		out := &appsv1.Deployment{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Replicas = o.Spec.Replicas
+		out.Spec.Selector = o.Spec.Selector
+		out.Status = appsv1.DeploymentStatus{
+			Replicas:          o.Status.Replicas,
+			UpdatedReplicas:   o.Status.UpdatedReplicas,
+			ReadyReplicas:     o.Status.ReadyReplicas,
+			AvailableReplicas: o.Status.AvailableReplicas,
+		}
+		return out
+	case *appsv1.StatefulSet:
+		out := &appsv1.StatefulSet{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Replicas = o.Spec.Replicas
+		out.Spec.Selector = o.Spec.Selector
+		out.Spec.ServiceName = o.Spec.ServiceName
+		out.Spec.PodManagementPolicy = o.Spec.PodManagementPolicy
+		out.Spec.UpdateStrategy.Type = o.Spec.UpdateStrategy.Type
+		out.Status = appsv1.StatefulSetStatus{
+			Replicas:          o.Status.Replicas,
+			ReadyReplicas:     o.Status.ReadyReplicas,
+			CurrentReplicas:   o.Status.CurrentReplicas,
+			UpdatedReplicas:   o.Status.UpdatedReplicas,
+			AvailableReplicas: o.Status.AvailableReplicas,
+		}
+		return out
+	case *appsv1.DaemonSet:
+		out := &appsv1.DaemonSet{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Selector = o.Spec.Selector
+		out.Spec.UpdateStrategy.Type = o.Spec.UpdateStrategy.Type
+		out.Status = appsv1.DaemonSetStatus{
+			DesiredNumberScheduled: o.Status.DesiredNumberScheduled,
+			CurrentNumberScheduled: o.Status.CurrentNumberScheduled,
+			UpdatedNumberScheduled: o.Status.UpdatedNumberScheduled,
+			NumberReady:            o.Status.NumberReady,
+			NumberAvailable:        o.Status.NumberAvailable,
+			NumberMisscheduled:     o.Status.NumberMisscheduled,
+		}
+		return out
+	case *corev1.Node:
+		out := &corev1.Node{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.PodCIDR = o.Spec.PodCIDR
+		out.Status.Capacity = o.Status.Capacity
+		out.Status.Allocatable = o.Status.Allocatable
+		out.Status.NodeInfo = corev1.NodeSystemInfo{
+			KubeletVersion:          o.Status.NodeInfo.KubeletVersion,
+			OSImage:                 o.Status.NodeInfo.OSImage,
+			ContainerRuntimeVersion: o.Status.NodeInfo.ContainerRuntimeVersion,
+		}
+		return out
+	case *corev1.Service:
+		out := &corev1.Service{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Type = o.Spec.Type
+		out.Spec.ClusterIP = o.Spec.ClusterIP
+		out.Spec.ClusterIPs = o.Spec.ClusterIPs
+		out.Spec.Selector = o.Spec.Selector
+		return out
+	case *corev1.ConfigMap:
+		out := &corev1.ConfigMap{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		if o.Data != nil {
+			out.Data = make(map[string]string, len(o.Data))
+			for k := range o.Data {
+				out.Data[k] = ""
+			}
+		}
+		return out
+	case *discoveryv1.EndpointSlice:
+		out := &discoveryv1.EndpointSlice{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), AddressType: o.AddressType}
+		out.Endpoints = make([]discoveryv1.Endpoint, len(o.Endpoints))
+		for i, ep := range o.Endpoints {
+			out.Endpoints[i] = discoveryv1.Endpoint{Conditions: ep.Conditions}
+			if ref := ep.TargetRef; ref != nil {
+				out.Endpoints[i].TargetRef = &corev1.ObjectReference{Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name, UID: ref.UID}
+			}
+		}
+		return out
+	case *networkingv1.Ingress:
+		out := &networkingv1.Ingress{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = networkingv1.IngressSpec{
+			IngressClassName: o.Spec.IngressClassName,
+			DefaultBackend:   trimIngressBackend(o.Spec.DefaultBackend),
+			TLS:              o.Spec.TLS,
+		}
+		for _, rule := range o.Spec.Rules {
+			r := networkingv1.IngressRule{Host: rule.Host}
+			if rule.HTTP != nil {
+				r.HTTP = &networkingv1.HTTPIngressRuleValue{Paths: make([]networkingv1.HTTPIngressPath, len(rule.HTTP.Paths))}
+				for i, p := range rule.HTTP.Paths {
+					r.HTTP.Paths[i] = networkingv1.HTTPIngressPath{Path: p.Path, Backend: *trimIngressBackend(&p.Backend)}
+				}
+			}
+			out.Spec.Rules = append(out.Spec.Rules, r)
+		}
+		for _, lb := range o.Status.LoadBalancer.Ingress {
+			out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, networkingv1.IngressLoadBalancerIngress{IP: lb.IP, Hostname: lb.Hostname})
+		}
+		return out
+	case *networkingv1.NetworkPolicy:
+		// rule counts are graphed, not their peers and ports
+		out := &networkingv1.NetworkPolicy{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = networkingv1.NetworkPolicySpec{
+			PodSelector: o.Spec.PodSelector,
+			PolicyTypes: o.Spec.PolicyTypes,
+			Ingress:     make([]networkingv1.NetworkPolicyIngressRule, len(o.Spec.Ingress)),
+			Egress:      make([]networkingv1.NetworkPolicyEgressRule, len(o.Spec.Egress)),
+		}
+		return out
+	case *corev1.ResourceQuota:
+		out := &corev1.ResourceQuota{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.Hard = o.Spec.Hard
+		out.Status.Used = o.Status.Used
+		return out
+	case *corev1.LimitRange:
+		return &corev1.LimitRange{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), Spec: o.Spec}
+	case *corev1.ServiceAccount:
+		out := &corev1.ServiceAccount{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), AutomountServiceAccountToken: o.AutomountServiceAccountToken}
+		for _, s := range o.Secrets {
+			out.Secrets = append(out.Secrets, corev1.ObjectReference{Name: s.Name})
+		}
+		out.ImagePullSecrets = o.ImagePullSecrets
+		return out
+	case *corev1.PersistentVolumeClaim:
+		out := &corev1.PersistentVolumeClaim{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = corev1.PersistentVolumeClaimSpec{
+			AccessModes:      o.Spec.AccessModes,
+			Resources:        corev1.VolumeResourceRequirements{Requests: o.Spec.Resources.Requests},
+			StorageClassName: o.Spec.StorageClassName,
+			VolumeName:       o.Spec.VolumeName,
+		}
+		out.Status = corev1.PersistentVolumeClaimStatus{Phase: o.Status.Phase, Capacity: o.Status.Capacity}
+		return out
+	case *corev1.PersistentVolume:
+		out := &corev1.PersistentVolume{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = corev1.PersistentVolumeSpec{
+			Capacity:                      o.Spec.Capacity,
+			AccessModes:                   o.Spec.AccessModes,
+			ClaimRef:                      o.Spec.ClaimRef,
+			PersistentVolumeReclaimPolicy: o.Spec.PersistentVolumeReclaimPolicy,
+			StorageClassName:              o.Spec.StorageClassName,
+		}
+		out.Status.Phase = o.Status.Phase
+		return out
+	case *corev1.Namespace:
+		out := &corev1.Namespace{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Status.Phase = o.Status.Phase
+		return out
+	case *batchv1.Job:
+		out := &batchv1.Job{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec.BackoffLimit = o.Spec.BackoffLimit
+		out.Spec.Selector = o.Spec.Selector
+		out.Status = batchv1.JobStatus{
+			Conditions:     trimJobConditions(o.Status.Conditions),
+			StartTime:      o.Status.StartTime,
+			CompletionTime: o.Status.CompletionTime,
+			Active:         o.Status.Active,
+			Succeeded:      o.Status.Succeeded,
+			Failed:         o.Status.Failed,
+		}
+		return out
+	case *batchv1.CronJob:
+		out := &batchv1.CronJob{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = batchv1.CronJobSpec{
+			Schedule:                o.Spec.Schedule,
+			TimeZone:                o.Spec.TimeZone,
+			StartingDeadlineSeconds: o.Spec.StartingDeadlineSeconds,
+			Suspend:                 o.Spec.Suspend,
+		}
+		out.Status = batchv1.CronJobStatus{
+			Active:             o.Status.Active,
+			LastScheduleTime:   o.Status.LastScheduleTime,
+			LastSuccessfulTime: o.Status.LastSuccessfulTime,
+		}
+		return out
+	case *corev1.Secret:
+		return TrimSecret(o, true)
+	case *corev1.Event:
+		return &corev1.Event{
+			TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta),
+			InvolvedObject: corev1.ObjectReference{Kind: o.InvolvedObject.Kind, Namespace: o.InvolvedObject.Namespace, Name: o.InvolvedObject.Name, UID: o.InvolvedObject.UID},
+			Type:           o.Type,
+			Reason:         o.Reason,
+			Message:        o.Message,
+			Count:          o.Count,
+			LastTimestamp:  o.LastTimestamp,
+			EventTime:      o.EventTime,
+			Series:         o.Series,
+		}
+	case *rbacv1.Role:
+		return &rbacv1.Role{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), Rules: o.Rules}
+	case *rbacv1.ClusterRole:
+		out := &rbacv1.ClusterRole{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), Rules: o.Rules}
+		if o.AggregationRule != nil {
+			out.AggregationRule = &rbacv1.AggregationRule{}
+		}
+		return out
+	case *rbacv1.RoleBinding:
+		return &rbacv1.RoleBinding{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), RoleRef: o.RoleRef, Subjects: o.Subjects}
+	case *rbacv1.ClusterRoleBinding:
+		return &rbacv1.ClusterRoleBinding{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), RoleRef: o.RoleRef, Subjects: o.Subjects}
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		out := &autoscalingv2.HorizontalPodAutoscaler{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		out.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: o.Spec.ScaleTargetRef,
+			MinReplicas:    o.Spec.MinReplicas,
+			MaxReplicas:    o.Spec.MaxReplicas,
+			Metrics:        o.Spec.Metrics,
+		}
+		out.Status = autoscalingv2.HorizontalPodAutoscalerStatus{
+			LastScaleTime:   o.Status.LastScaleTime,
+			CurrentReplicas: o.Status.CurrentReplicas,
+			DesiredReplicas: o.Status.DesiredReplicas,
+			CurrentMetrics:  o.Status.CurrentMetrics,
+			Conditions:      trimHPAConditions(o.Status.Conditions),
+		}
+		return out
+	case *schedulingv1.PriorityClass:
+		return &schedulingv1.PriorityClass{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta),
+			Value: o.Value, GlobalDefault: o.GlobalDefault, PreemptionPolicy: o.PreemptionPolicy}
+	case *nodev1.RuntimeClass:
+		return &nodev1.RuntimeClass{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta), Handler: o.Handler}
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		out := &admissionregistrationv1.MutatingWebhookConfiguration{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		for _, wh := range o.Webhooks {
+			out.Webhooks = append(out.Webhooks, admissionregistrationv1.MutatingWebhook{
+				Name: wh.Name, ClientConfig: trimClientConfig(wh.ClientConfig), Rules: wh.Rules, FailurePolicy: wh.FailurePolicy,
+			})
+		}
+		return out
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		out := &admissionregistrationv1.ValidatingWebhookConfiguration{TypeMeta: o.TypeMeta, ObjectMeta: trimMeta(o.ObjectMeta)}
+		for _, wh := range o.Webhooks {
+			out.Webhooks = append(out.Webhooks, admissionregistrationv1.ValidatingWebhook{
+				Name: wh.Name, ClientConfig: trimClientConfig(wh.ClientConfig), Rules: wh.Rules, FailurePolicy: wh.FailurePolicy,
+			})
+		}
+		return out
+	default:
+		return obj
+	}
+}
+
+// TrimTransform is an informer transform applying Trim, so the informer's
+// own store holds trimmed objects too.
+func TrimTransform(obj interface{}) (interface{}, error) {
+	if robj, ok := obj.(runtime.Object); ok {
+		return Trim(robj), nil
+	}
+	return obj, nil
+}
+
+// trimMeta keeps the metadata the graph reads, dropping managedFields and
+// finalizers and eliding long annotation values.
+func trimMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:              meta.Name,
+		Namespace:         meta.Namespace,
+		UID:               meta.UID,
+		ResourceVersion:   meta.ResourceVersion,
+		Generation:        meta.Generation,
+		CreationTimestamp: meta.CreationTimestamp,
+		DeletionTimestamp: meta.DeletionTimestamp,
+		Labels:            meta.Labels,
+		Annotations:       elideAnnotations(meta.Annotations),
+		OwnerReferences:   meta.OwnerReferences,
+	}
+}
+
+// elideAnnotations returns annotations with long values elided, copying the
+// map only if any is.
+func elideAnnotations(annotations map[string]string) map[string]string {
+	for _, v := range annotations {
+		if len(v) <= MaxAnnotationValue {
+			continue
+		}
+		out := make(map[string]string, len(annotations))
+		for k, v := range annotations {
+			out[k] = ElideValue(v)
+		}
+		return out
+	}
+	return annotations
+}
+
+// trimVolume keeps the ConfigMap, Secret and claim references of vol, reporting
+// whether it has any.
+func trimVolume(vol corev1.Volume) (corev1.Volume, bool) {
+	out := corev1.Volume{Name: vol.Name}
+	switch {
+	case vol.ConfigMap != nil:
+		out.ConfigMap = &corev1.ConfigMapVolumeSource{LocalObjectReference: vol.ConfigMap.LocalObjectReference, Optional: vol.ConfigMap.Optional}
+	case vol.PersistentVolumeClaim != nil:
+		out.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: vol.PersistentVolumeClaim.ClaimName}
+	case vol.Ephemeral != nil:
+		// its claim is named after the pod and volume
+		out.Ephemeral = &corev1.EphemeralVolumeSource{}
+	case vol.Secret != nil:
+		out.Secret = &corev1.SecretVolumeSource{SecretName: vol.Secret.SecretName, Optional: vol.Secret.Optional}
+	case vol.Projected != nil:
+		var sources []corev1.VolumeProjection
+		for _, source := range vol.Projected.Sources {
+			if source.Secret != nil {
+				sources = append(sources, corev1.VolumeProjection{Secret: &corev1.SecretProjection{
+					LocalObjectReference: source.Secret.LocalObjectReference, Optional: source.Secret.Optional,
+				}})
+			}
+			if source.ConfigMap != nil {
+				sources = append(sources, corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: source.ConfigMap.LocalObjectReference, Optional: source.ConfigMap.Optional,
+				}})
+			}
+		}
+		if sources == nil {
+			return out, false
+		}
+		out.Projected = &corev1.ProjectedVolumeSource{Sources: sources}
+	default:
+		return out, false
+	}
+	return out, true
+}
+
+// trimContainers keeps the names and images of containers and the Secret
+// and ConfigMap references of their environment.
+func trimContainers(containers []corev1.Container) []corev1.Container {
+	var out []corev1.Container
+	for _, c := range containers {
+		env, envFrom := trimEnvRefs(c.Env, c.EnvFrom)
+		out = append(out, corev1.Container{Name: c.Name, Image: c.Image, Env: env, EnvFrom: envFrom})
+	}
+	return out
+}
+
+// trimEnvRefs keeps the Secret and ConfigMap references of env and
+// envFrom, never a value.
+func trimEnvRefs(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) ([]corev1.EnvVar, []corev1.EnvFromSource) {
+	var outEnv []corev1.EnvVar
+	for _, e := range env {
+		switch {
+		case e.ValueFrom == nil:
+		case e.ValueFrom.SecretKeyRef != nil:
+			ref := e.ValueFrom.SecretKeyRef
+			outEnv = append(outEnv, corev1.EnvVar{Name: e.Name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: ref.LocalObjectReference, Key: ref.Key, Optional: ref.Optional,
+			}}})
+		case e.ValueFrom.ConfigMapKeyRef != nil:
+			ref := e.ValueFrom.ConfigMapKeyRef
+			outEnv = append(outEnv, corev1.EnvVar{Name: e.Name, ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: ref.LocalObjectReference, Key: ref.Key, Optional: ref.Optional,
+			}}})
+		}
+	}
+	var outFrom []corev1.EnvFromSource
+	for _, from := range envFrom {
+		if from.SecretRef != nil || from.ConfigMapRef != nil {
+			outFrom = append(outFrom, corev1.EnvFromSource{Prefix: from.Prefix, SecretRef: from.SecretRef, ConfigMapRef: from.ConfigMapRef})
+		}
+	}
+	return outEnv, outFrom
+}
+
+// trimContainerStatuses keeps the names, restart counts, states and last
+// terminations of statuses, without messages.
+func trimContainerStatuses(statuses []corev1.ContainerStatus) []corev1.ContainerStatus {
+	if statuses == nil {
+		return nil
+	}
+	out := make([]corev1.ContainerStatus, len(statuses))
+	for i, cs := range statuses {
+		out[i] = corev1.ContainerStatus{
+			Name:                 cs.Name,
+			RestartCount:         cs.RestartCount,
+			State:                trimContainerState(cs.State),
+			LastTerminationState: corev1.ContainerState{Terminated: trimTerminated(cs.LastTerminationState.Terminated)},
+		}
+	}
+	return out
+}
+
+// trimContainerState keeps which state a container is in, its waiting reason
+// and its termination.
+func trimContainerState(state corev1.ContainerState) corev1.ContainerState {
+	var out corev1.ContainerState
+	if state.Running != nil {
+		out.Running = &corev1.ContainerStateRunning{}
+	}
+	if state.Waiting != nil {
+		out.Waiting = &corev1.ContainerStateWaiting{Reason: state.Waiting.Reason}
+	}
+	out.Terminated = trimTerminated(state.Terminated)
+	return out
+}
+
+// trimTerminated keeps the reason, exit code and finish time of t.
+func trimTerminated(t *corev1.ContainerStateTerminated) *corev1.ContainerStateTerminated {
+	if t == nil {
+		return nil
+	}
+	return &corev1.ContainerStateTerminated{Reason: t.Reason, ExitCode: t.ExitCode, FinishedAt: t.FinishedAt}
+}
+
+// trimHPAConditions keeps the type, status and reason of conditions, without
+// messages.
+// trimIngressBackend keeps the Service backend references; resource
+// backends are not graphed.
+func trimIngressBackend(b *networkingv1.IngressBackend) *networkingv1.IngressBackend {
+	if b == nil {
+		return nil
+	}
+	return &networkingv1.IngressBackend{Service: b.Service}
+}
+
+func trimHPAConditions(conditions []autoscalingv2.HorizontalPodAutoscalerCondition) []autoscalingv2.HorizontalPodAutoscalerCondition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]autoscalingv2.HorizontalPodAutoscalerCondition, len(conditions))
+	for i, c := range conditions {
+		out[i] = autoscalingv2.HorizontalPodAutoscalerCondition{Type: c.Type, Status: c.Status, Reason: c.Reason}
+	}
+	return out
+}
+
+// trimJobConditions keeps the type, status, reason and transition time of
+// conditions, without messages.
+func trimJobConditions(conditions []batchv1.JobCondition) []batchv1.JobCondition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]batchv1.JobCondition, len(conditions))
+	for i, c := range conditions {
+		out[i] = batchv1.JobCondition{Type: c.Type, Status: c.Status, Reason: c.Reason, LastTransitionTime: c.LastTransitionTime}
+	}
+	return out
+}
+
+// trimClientConfig keeps where a webhook is called, dropping its CA bundle.
+func trimClientConfig(cc admissionregistrationv1.WebhookClientConfig) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{URL: cc.URL, Service: cc.Service}
+}
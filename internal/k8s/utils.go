@@ -1,14 +1,27 @@
 package k8s
 
 import (
+	"fmt"
+
 	log "github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	cache "k8s.io/client-go/tools/cache"
 
-	"satellite/internal/types"
+	"github.com/tthuwng/satellite/internal/ratelog"
+	"github.com/tthuwng/satellite/internal/types"
 )
 
 // GetObjectMeta extracts ObjectMeta, handling tombstones.
@@ -21,12 +34,64 @@ func GetObjectMeta(obj interface{}) metav1.ObjectMeta {
 		return o.ObjectMeta
 	case *appsv1.Deployment:
 		return o.ObjectMeta
+	case *appsv1.StatefulSet:
+		return o.ObjectMeta
+	case *appsv1.DaemonSet:
+		return o.ObjectMeta
 	case *corev1.Node:
 		return o.ObjectMeta
 	case *corev1.Service:
 		return o.ObjectMeta
 	case *corev1.ConfigMap:
 		return o.ObjectMeta
+	case *discoveryv1.EndpointSlice:
+		return o.ObjectMeta
+	case *networkingv1.Ingress:
+		return o.ObjectMeta
+	case *networkingv1.NetworkPolicy:
+		return o.ObjectMeta
+	case *corev1.ServiceAccount:
+		return o.ObjectMeta
+	case *corev1.ResourceQuota:
+		return o.ObjectMeta
+	case *corev1.LimitRange:
+		return o.ObjectMeta
+	case *corev1.PersistentVolumeClaim:
+		return o.ObjectMeta
+	case *corev1.PersistentVolume:
+		return o.ObjectMeta
+	case *corev1.Namespace:
+		return o.ObjectMeta
+	case *batchv1.Job:
+		return o.ObjectMeta
+	case *batchv1.CronJob:
+		return o.ObjectMeta
+	case *rbacv1.Role:
+		return o.ObjectMeta
+	case *rbacv1.ClusterRole:
+		return o.ObjectMeta
+	case *rbacv1.RoleBinding:
+		return o.ObjectMeta
+	case *rbacv1.ClusterRoleBinding:
+		return o.ObjectMeta
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		return o.ObjectMeta
+	case *schedulingv1.PriorityClass:
+		return o.ObjectMeta
+	case *nodev1.RuntimeClass:
+		return o.ObjectMeta
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		return o.ObjectMeta
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		return o.ObjectMeta
+	case *corev1.Secret:
+		return o.ObjectMeta
+	case *corev1.Event:
+		return o.ObjectMeta
+	case *metav1.PartialObjectMetadata: // metadata-only informers
+		return o.ObjectMeta
+	case *unstructured.Unstructured: // dynamic informers
+		return accessorMeta(o)
 	case cache.DeletedFinalStateUnknown: // Handle Tombstone
 		if o.Obj != nil {
 			// Recursively call on the object within the tombstone
@@ -36,26 +101,54 @@ func GetObjectMeta(obj interface{}) metav1.ObjectMeta {
 			return metav1.ObjectMeta{}
 		}
 	default:
-		log.Warnf("Unknown object type in GetObjectMeta: %T", obj)
+		if m, err := apimeta.Accessor(obj); err == nil {
+			return accessorMeta(m)
+		}
+		typ := fmt.Sprintf("%T", obj)
+		ratelog.Default.Log(log.WithField("type", typ), log.WarnLevel, "unknown type "+typ, "Unknown object type in GetObjectMeta")
 		return metav1.ObjectMeta{}
 	}
 }
 
+// accessorMeta copies the metadata of an object only known through its
+// accessor, e.g. an unstructured one.
+func accessorMeta(m metav1.Object) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:              m.GetName(),
+		Namespace:         m.GetNamespace(),
+		UID:               m.GetUID(),
+		ResourceVersion:   m.GetResourceVersion(),
+		Generation:        m.GetGeneration(),
+		CreationTimestamp: m.GetCreationTimestamp(),
+		DeletionTimestamp: m.GetDeletionTimestamp(),
+		Labels:            m.GetLabels(),
+		Annotations:       m.GetAnnotations(),
+		OwnerReferences:   m.GetOwnerReferences(),
+		Finalizers:        m.GetFinalizers(),
+	}
+}
+
 // GetKey extracts the EntityKey from a Kubernetes object.
 func GetKey(obj runtime.Object) (types.EntityKey, bool) {
 	meta := GetObjectMeta(obj)
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	kind := gvk.Kind
+	kind, group := gvk.Kind, gvk.Group
+	if _, partial := obj.(*metav1.PartialObjectMetadata); partial && (kind == "" || kind == "PartialObjectMetadata") {
+		log.WithFields(log.Fields{"namespace": meta.Namespace, "name": meta.Name}).Warn("Metadata-only object without its kind")
+		return types.EntityKey{}, false
+	}
 	if kind == "" {
 		kind = getKindFromType(obj)
 		if kind == "" {
-			log.Warnf("Could not determine Kind for object %s/%s", meta.Namespace, meta.Name)
+			log.WithFields(log.Fields{"namespace": meta.Namespace, "name": meta.Name}).Warn("Could not determine Kind for object")
 			return types.EntityKey{}, false
 		}
+		group = KindGroup(kind)
 	}
 
 	key := types.EntityKey{
 		Kind:      kind,
+		APIGroup:  group,
 		Namespace: meta.Namespace,
 		Name:      meta.Name,
 	}
@@ -71,14 +164,63 @@ func getKindFromType(obj runtime.Object) string {
 		return "ReplicaSet"
 	case *appsv1.Deployment:
 		return "Deployment"
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
 	case *corev1.Node:
 		return "Node"
 	case *corev1.Service:
 		return "Service"
 	case *corev1.ConfigMap:
 		return "ConfigMap"
+	case *discoveryv1.EndpointSlice:
+		return "EndpointSlice"
+	case *networkingv1.Ingress:
+		return "Ingress"
+	case *networkingv1.NetworkPolicy:
+		return "NetworkPolicy"
+	case *corev1.ServiceAccount:
+		return "ServiceAccount"
+	case *corev1.ResourceQuota:
+		return "ResourceQuota"
+	case *corev1.LimitRange:
+		return "LimitRange"
+	case *corev1.PersistentVolumeClaim:
+		return "PersistentVolumeClaim"
+	case *corev1.PersistentVolume:
+		return "PersistentVolume"
+	case *corev1.Namespace:
+		return "Namespace"
+	case *batchv1.Job:
+		return "Job"
+	case *batchv1.CronJob:
+		return "CronJob"
+	case *rbacv1.Role:
+		return "Role"
+	case *rbacv1.ClusterRole:
+		return "ClusterRole"
+	case *rbacv1.RoleBinding:
+		return "RoleBinding"
+	case *rbacv1.ClusterRoleBinding:
+		return "ClusterRoleBinding"
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		return "HorizontalPodAutoscaler"
+	case *schedulingv1.PriorityClass:
+		return "PriorityClass"
+	case *nodev1.RuntimeClass:
+		return "RuntimeClass"
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		return "MutatingWebhookConfiguration"
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		return "ValidatingWebhookConfiguration"
+	case *corev1.Secret:
+		return "Secret"
+	case *corev1.Event:
+		return "Event"
 	default:
-		log.Warnf("Unknown type in getKindFromType: %T", obj)
+		typ := fmt.Sprintf("%T", obj)
+		ratelog.Default.Log(log.WithField("type", typ), log.WarnLevel, "unknown kind "+typ, "Unknown type in getKindFromType")
 		return ""
 	}
 }
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// DefaultWatchRecoveryWindow is how long a kind must go without list/watch
+// failures to count as recovered. It exceeds the reflector's 30s maximum
+// backoff, so a kind failing on every retry never looks recovered.
+const DefaultWatchRecoveryWindow = time.Minute
+
+// WatchHealth tracks list/watch failures per kind. client-go retries failed
+// watches on its own; WatchHealth makes those failures visible. A kind is
+// stale from its first failure until it has gone RecoveryWindow without one.
+type WatchHealth struct {
+	// Cluster labels log lines; empty in single-cluster mode.
+	Cluster string
+	// RecoveryWindow overrides DefaultWatchRecoveryWindow.
+	RecoveryWindow time.Duration
+	// ExitThreshold, if positive, is the number of consecutive failures of one
+	// kind after which OnExhausted is called.
+	ExitThreshold int
+	// OnExhausted is called (from the reflector goroutine) once a kind reaches
+	// ExitThreshold consecutive failures.
+	OnExhausted func(kind string, failures int, err error)
+
+	mu    sync.Mutex
+	kinds map[string]*watchState
+}
+
+// watchState is the failure streak of one kind.
+type watchState struct {
+	failures    int
+	lastFailure time.Time
+}
+
+// Register installs the watch error handler on inf. It must be called before
+// the informer starts.
+func (h *WatchHealth) Register(kind string, inf cache.SharedIndexInformer) error {
+	return inf.SetWatchErrorHandler(h.Handler(kind))
+}
+
+// Handler returns the watch error handler tracking kind, starting a new
+// failure streak for it.
+func (h *WatchHealth) Handler(kind string) cache.WatchErrorHandler {
+	h.mu.Lock()
+	if h.kinds == nil {
+		h.kinds = make(map[string]*watchState)
+	}
+	state := &watchState{}
+	h.kinds[kind] = state
+	h.mu.Unlock()
+
+	return func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(context.Background(), r, err)
+		if !isWatchFailure(err) {
+			return
+		}
+		h.failed(kind, state, err)
+	}
+}
+
+// failed records one failure of kind.
+func (h *WatchHealth) failed(kind string, state *watchState, err error) {
+	metrics.WatchErrors.WithLabelValues(kind).Inc()
+
+	now := time.Now()
+	h.mu.Lock()
+	if !h.failing(state, now) {
+		state.failures = 0
+	}
+	state.failures++
+	state.lastFailure = now
+	failures := state.failures
+	h.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"cluster":  h.Cluster,
+		"kind":     kind,
+		"failures": failures,
+	}).WithError(err).Warn("List/watch failed; client-go will retry with backoff")
+
+	if h.ExitThreshold > 0 && failures == h.ExitThreshold && h.OnExhausted != nil {
+		h.OnExhausted(kind, failures, err)
+	}
+}
+
+// failing reports whether state's failure streak is still ongoing at now.
+// Callers hold h.mu.
+func (h *WatchHealth) failing(state *watchState, now time.Time) bool {
+	window := h.RecoveryWindow
+	if window <= 0 {
+		window = DefaultWatchRecoveryWindow
+	}
+	return state.failures > 0 && now.Sub(state.lastFailure) < window
+}
+
+// StaleKinds returns the kinds whose list/watch is currently failing, sorted.
+func (h *WatchHealth) StaleKinds() []string {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var stale []string
+	for kind, state := range h.kinds {
+		if h.failing(state, now) {
+			stale = append(stale, kind)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// isWatchFailure reports whether err is a real failure rather than a normal
+// watch close or an expired resource version, which the reflector handles
+// with a relist.
+func isWatchFailure(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return false
+	case apierrors.IsResourceExpired(err), apierrors.IsGone(err):
+		return false
+	}
+	return true
+}
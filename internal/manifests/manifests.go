@@ -0,0 +1,246 @@
+// Package manifests loads Kubernetes manifests, such as a rendered Helm
+// chart or kustomize overlay, into a cache, so the graph they would create
+// can be built without a cluster.
+package manifests
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Stdin is the path reading manifests from standard input.
+const Stdin = "-"
+
+// DocumentError is a manifest document that could not be loaded. Index
+// counts the documents of File from 0, like yq does.
+type DocumentError struct {
+	File  string
+	Index int
+	Err   error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("%s: document %d: %v", e.File, e.Index, e.Err)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// Loader decodes manifests into Cache. Objects of the watched kinds, at the
+// API version Satellite watches, are loaded; any other object is counted in
+// Skipped by apiVersion and kind.
+type Loader struct {
+	// Namespace is given to namespaced objects without one, as by kubectl
+	// apply; "default" if empty.
+	Namespace string
+	Cache     *cache.ResourceCache
+	// Stdin is read for the Stdin path; os.Stdin if nil.
+	Stdin   io.Reader
+	Loaded  int
+	Skipped map[string]int
+}
+
+// NewLoader returns a loader into c.
+func NewLoader(c *cache.ResourceCache, namespace string) *Loader {
+	return &Loader{Namespace: namespace, Cache: c, Skipped: make(map[string]int)}
+}
+
+// LoadPath loads the manifests of path: a file, Stdin, or a directory whose
+// .yaml, .yml and .json files are loaded recursively, in lexical order.
+// Every document is loaded even if some fail; their errors, each a
+// *DocumentError, are returned joined.
+func (l *Loader) LoadPath(path string) error {
+	if path == Stdin {
+		stdin := l.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		return l.Load(stdin, "<stdin>")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifests: %w", err)
+	}
+	if !info.IsDir() {
+		return l.loadFile(path)
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list manifests in %s: %w", path, err)
+	}
+	sort.Strings(files)
+	var errs []error
+	for _, file := range files {
+		if err := l.loadFile(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Loader) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifests: %w", err)
+	}
+	defer f.Close()
+	return l.Load(f, path)
+}
+
+// Load loads the YAML or JSON documents of r, named name in errors.
+func (l *Loader) Load(r io.Reader, name string) error {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	var errs []error
+	for index := 0; ; index++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, &DocumentError{File: name, Index: index, Err: err})
+			break
+		}
+		if err := l.loadDocument(doc); err != nil {
+			errs = append(errs, &DocumentError{File: name, Index: index, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadDocument decodes one document, unpacking a List.
+func (l *Loader) loadDocument(doc []byte) error {
+	if isEmpty(doc) {
+		return nil
+	}
+	obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	// these errors quote the document, which may hold secret values
+	switch {
+	case runtime.IsMissingKind(err):
+		return errors.New("not a Kubernetes object: missing kind")
+	case runtime.IsMissingVersion(err):
+		return errors.New("not a Kubernetes object: missing apiVersion")
+	case runtime.IsNotRegisteredError(err):
+		var typeMeta runtime.TypeMeta
+		if err := utilyaml.Unmarshal(doc, &typeMeta); err != nil {
+			return errors.New("failed to decode kind")
+		}
+		l.Skipped[typeMeta.APIVersion+" "+typeMeta.Kind]++
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to decode: %w", err)
+	}
+	if list, ok := obj.(*corev1.List); ok {
+		var errs []error
+		for i, item := range list.Items {
+			if err := l.loadDocument(item.Raw); err != nil {
+				errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	l.add(obj, *gvk)
+	return nil
+}
+
+// add loads obj, of gvk, if it is of a watched kind and version.
+func (l *Loader) add(obj runtime.Object, gvk schema.GroupVersionKind) {
+	wk, ok := watchedKind(gvk)
+	if !ok {
+		l.Skipped[gvk.GroupVersion().String()+" "+gvk.Kind]++
+		return
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		l.Skipped[gvk.GroupVersion().String()+" "+gvk.Kind]++
+		return
+	}
+	if !wk.ClusterScoped && m.GetNamespace() == "" {
+		namespace := l.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		m.SetNamespace(namespace)
+	}
+	if m.GetUID() == "" {
+		// unapplied objects have none; keep them apart in the UID index
+		m.SetUID(k8stypes.UID("manifest:" + gvk.Kind + "/" + m.GetNamespace() + "/" + m.GetName()))
+	}
+	if s, ok := obj.(*corev1.Secret); ok {
+		obj = trimSecret(s)
+	}
+	l.Cache.Upsert(obj)
+	l.Loaded++
+}
+
+// trimSecret drops the values of s, after merging stringData into data as
+// the API server does on write.
+func trimSecret(s *corev1.Secret) *corev1.Secret {
+	if len(s.StringData) > 0 {
+		if s.Data == nil {
+			s.Data = make(map[string][]byte, len(s.StringData))
+		}
+		for k, v := range s.StringData {
+			s.Data[k] = []byte(v)
+		}
+		s.StringData = nil
+	}
+	return k8s.TrimSecret(s, false)
+}
+
+// watchedKind returns the watched kind of gvk, if Satellite watches it at
+// that API version.
+func watchedKind(gvk schema.GroupVersionKind) (k8s.WatchedKind, bool) {
+	for _, wk := range k8s.WatchedKinds {
+		version := wk.Version
+		if version == "" {
+			version = "v1"
+		}
+		if wk.Kind == gvk.Kind && wk.Group == gvk.Group && version == gvk.Version {
+			return wk, true
+		}
+	}
+	return k8s.WatchedKind{}, false
+}
+
+// isEmpty reports whether doc holds only whitespace and comments.
+func isEmpty(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && line != "---" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"expvar"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func init() {
+	expvar.Publish(namespace, expvar.Func(Snapshot))
+}
+
+// Snapshot flattens the satellite_* metrics of Registry for /debug/vars, so
+// expvar reads the same counters as Prometheus instead of keeping its own.
+// Names lose the "satellite_" prefix; labelled series are keyed by their
+// label values joined with "/", and histograms report count and sum.
+func Snapshot() any {
+	families, err := Registry.Gather()
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	out := make(map[string]any)
+	for _, mf := range families {
+		name, ok := strings.CutPrefix(mf.GetName(), namespace+"_")
+		if !ok {
+			continue
+		}
+		if len(mf.GetMetric()) == 1 && len(mf.GetMetric()[0].GetLabel()) == 0 {
+			out[name] = metricValue(mf.GetMetric()[0])
+			continue
+		}
+		series := make(map[string]any, len(mf.GetMetric()))
+		for _, m := range mf.GetMetric() {
+			values := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				values = append(values, l.GetValue())
+			}
+			series[strings.Join(values, "/")] = metricValue(m)
+		}
+		out[name] = series
+	}
+	return out
+}
+
+// metricValue returns the value of a counter or gauge, or the count and
+// sum of a histogram.
+func metricValue(m *dto.Metric) any {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Histogram != nil:
+		return map[string]any{"count": m.Histogram.GetSampleCount(), "sum": m.Histogram.GetSampleSum()}
+	case m.Untyped != nil:
+		return m.Untyped.GetValue()
+	}
+	return nil
+}
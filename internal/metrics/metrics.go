@@ -0,0 +1,307 @@
+// Package metrics holds Satellite's Prometheus collectors. Metric names are
+// part of the public interface: dashboards and alerts depend on them, so
+// rename only with a deprecation period.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/churn"
+	"github.com/tthuwng/satellite/internal/ratelog"
+	"github.com/tthuwng/satellite/internal/timing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "satellite"
+
+// Registry holds every Satellite metric plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// InformerEvents counts informer events by kind and type (add, update, delete).
+	InformerEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "informer_events_total",
+		Help:      "Informer events received, by kind and event type.",
+	}, []string{"kind", "type"})
+
+	// CacheObjects is the number of cached objects per kind, summed over clusters.
+	CacheObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_objects",
+		Help:      "Objects in the resource cache, by kind (summed over clusters).",
+	}, []string{"kind"})
+
+	// CacheNoopUpdates counts upserts skipped because the resourceVersion was unchanged.
+	CacheNoopUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_noop_updates_total",
+		Help:      "Cache upserts skipped because the object's resourceVersion was unchanged, by kind.",
+	}, []string{"kind"})
+
+	// CacheInsignificantUpdates counts updates stored without signalling a
+	// change because only ignored fields changed.
+	CacheInsignificantUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_insignificant_updates_total",
+		Help:      "Cache updates that changed only ignored fields (e.g. Node heartbeats) and triggered no rebuild, by kind.",
+	}, []string{"kind"})
+
+	// ChangeSignals counts cache change signals, by whether they were sent or
+	// coalesced into one already pending.
+	ChangeSignals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_change_signals_total",
+		Help:      "Cache change signals, by result (sent, coalesced).",
+	}, []string{"result"})
+
+	// WatchErrors counts failed list/watch calls per kind.
+	WatchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watch_errors_total",
+		Help:      "Failed informer list/watch calls, by kind. client-go retries them with backoff.",
+	}, []string{"kind"})
+
+	// BuildDuration observes BuildGraph run times.
+	BuildDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graph_build_duration_seconds",
+		Help:      "Time taken to build a graph from the cache.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10), // 1ms .. ~4m
+	})
+
+	// Builds counts build cycles by result (success, error).
+	Builds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graph_builds_total",
+		Help:      "Graph build cycles, by result (success, error).",
+	}, []string{"result"})
+
+	// GraphRevision is the revision of the last successfully built graph.
+	GraphRevision = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "graph_revision",
+		Help:      "Revision of the most recently built graph.",
+	})
+
+	// TruncatedNodes is the number of nodes of each kind the size caps
+	// dropped from the last built graph.
+	TruncatedNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "graph_truncated_nodes",
+		Help:      "Nodes dropped from the most recently built graph by the size caps, by kind. Non-zero means the graph is incomplete.",
+	}, []string{"kind"})
+
+	// TruncatedRelationships is the number of relationships dropped with
+	// the truncated nodes of the last built graph.
+	TruncatedRelationships = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "graph_truncated_relationships",
+		Help:      "Relationships dropped from the most recently built graph with the nodes the size caps dropped.",
+	})
+
+	// GraphNodes is the node count of the last built graph.
+	GraphNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "graph_nodes",
+		Help:      "Nodes in the most recently built graph.",
+	})
+
+	// GraphRelationships is the relationship count of the last built graph.
+	GraphRelationships = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "graph_relationships",
+		Help:      "Relationships in the most recently built graph.",
+	})
+
+	// EmitDuration observes emit times per sink.
+	EmitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "emit_duration_seconds",
+		Help:      "Time taken to emit a graph, by sink.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"sink"})
+
+	// EmitFailures counts failed emits per sink.
+	EmitFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emit_failures_total",
+		Help:      "Failed graph emits, by sink.",
+	}, []string{"sink"})
+
+	// LastEmitSuccess is the Unix time of the last successful emit per sink.
+	LastEmitSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_emit_timestamp_seconds",
+		Help:      "Unix time of the last successful graph emit, by sink.",
+	}, []string{"sink"})
+
+	// EmitQueuePending is the number of graphs waiting in the emit queue.
+	EmitQueuePending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "emit_queue_pending",
+		Help:      "Graphs waiting in the emit queue (0 or 1).",
+	})
+
+	// SuppressedLogs reports the log lines dropped by ratelog.Default.
+	SuppressedLogs = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suppressed_log_lines_total",
+		Help:      "Per-event log lines dropped by rate limiting.",
+	}, func() float64 { return float64(ratelog.Default.Suppressed()) })
+
+	// EmitQueueMerged counts pending graphs superseded before being emitted.
+	EmitQueueMerged = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emit_queue_merged_total",
+		Help:      "Pending graphs replaced by a newer one before being emitted.",
+	})
+
+	// EmitHeartbeats counts re-emits of an unchanged graph.
+	EmitHeartbeats = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emit_heartbeats_total",
+		Help:      "Heartbeat re-emits of the last graph after --heartbeat-interval without an emit.",
+	})
+
+	// WatchProgress is the Unix time each kind's resourceVersion last
+	// advanced, through an object or a watch bookmark.
+	WatchProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watch_progress_timestamp_seconds",
+		Help:      "Unix time the highest resourceVersion seen of a kind last advanced, by an object or a watch bookmark.",
+	}, []string{"kind"})
+
+	// AuditEvents counts cache events written to the audit log, by result.
+	AuditEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_total",
+		Help:      "Cache events handed to the audit log, by result (written, dropped, failed).",
+	}, []string{"result"})
+
+	// RelationshipRuleErrors counts the objects a relationship rule failed
+	// to evaluate on.
+	RelationshipRuleErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relationship_rule_errors_total",
+		Help:      "Objects a configured relationship rule failed to evaluate on, by rule.",
+	}, []string{"rule"})
+
+	// RuleEvents counts the events matched by change rules, by rule and
+	// result.
+	RuleEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_events_total",
+		Help:      "Graph changes matched by rules, by rule and result (fired, limited, failed).",
+	}, []string{"rule", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		InformerEvents,
+		CacheObjects,
+		CacheNoopUpdates,
+		CacheInsignificantUpdates,
+		EventRates,
+		ChangeSignals,
+		WatchErrors,
+		WatchProgress,
+		BuildDuration,
+		Builds,
+		GraphRevision,
+		GraphNodes,
+		GraphRelationships,
+		TruncatedNodes,
+		TruncatedRelationships,
+		EmitDuration,
+		EmitFailures,
+		LastEmitSuccess,
+		EmitQueueMerged,
+		EmitQueuePending,
+		EmitHeartbeats,
+		SuppressedLogs,
+		AuditEvents,
+		RuleEvents,
+		RelationshipRuleErrors,
+		PhaseDurations,
+	)
+}
+
+// EventRates exports the sliding-window rates of churn.Default as
+// satellite_informer_event_rate{kind, type}, in events per second. type is
+// add, update, delete or noop.
+var EventRates prometheus.Collector = rateCollector{
+	desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "informer_event_rate"),
+		"Informer events per second over the recent window, by kind and event type (add, update, delete, noop).",
+		[]string{"kind", "type"}, nil,
+	),
+}
+
+// rateCollector reads churn.Default on every scrape.
+type rateCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c rateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c rateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range churn.Default.Rates() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, r.PerSecond, r.Kind, r.Type)
+	}
+}
+
+// PhaseDurations exports the rolling percentiles of timing.Default as
+// satellite_phase_duration_seconds{phase, quantile}, with quantile "1" for
+// the maximum.
+var PhaseDurations prometheus.Collector = phaseCollector{
+	desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "phase_duration_seconds"),
+		"Build and emit phase durations over the recent window, by phase and quantile (0.5, 0.95, 1 = max).",
+		[]string{"phase", "quantile"}, nil,
+	),
+}
+
+// phaseCollector reads timing.Default on every scrape.
+type phaseCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c phaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c phaseCollector) Collect(ch chan<- prometheus.Metric) {
+	for phase, sum := range timing.Default.Summaries() {
+		for _, q := range []struct {
+			quantile string
+			value    time.Duration
+		}{{"0.5", sum.P50}, {"0.95", sum.P95}, {"1", sum.Max}} {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, q.value.Seconds(), phase, q.quantile)
+		}
+	}
+}
+
+// ObserveEmit records the outcome of one emit to sink that started at start.
+func ObserveEmit(sink string, start time.Time, err error) {
+	EmitDuration.WithLabelValues(sink).Observe(time.Since(start).Seconds())
+	if err != nil {
+		EmitFailures.WithLabelValues(sink).Inc()
+		return
+	}
+	LastEmitSuccess.WithLabelValues(sink).SetToCurrentTime()
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,111 @@
+// Package oneshot builds a single graph from plain list calls instead of
+// informers, for short-lived invocations such as the kubectl plugin: it
+// needs only list permission and returns as soon as the lists do.
+package oneshot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options selects what a one-shot graph covers.
+type Options struct {
+	// Namespace limits the graph to one namespace and the cluster-scoped
+	// objects its objects point at; empty covers every namespace.
+	Namespace string
+	// Kinds lists the kinds to list; empty lists every watched kind that
+	// isn't opt-in.
+	Kinds map[string]bool
+}
+
+// kinds returns the kinds to list. Namespaces are only listed across every
+// namespace, since a namespaced graph has no relationship to them.
+func (o Options) kinds() []k8s.WatchedKind {
+	var kinds []k8s.WatchedKind
+	for _, wk := range k8s.WatchedKinds {
+		if len(o.Kinds) > 0 && !o.Kinds[wk.Kind] || len(o.Kinds) == 0 && wk.OptIn {
+			continue
+		}
+		if wk.Kind == "Namespace" && o.Namespace != "" {
+			continue
+		}
+		kinds = append(kinds, wk)
+	}
+	return kinds
+}
+
+// Load lists the selected kinds concurrently into a new cache. Kinds the
+// caller may not list are skipped with a warning and returned; any other
+// list error fails the load.
+func Load(ctx context.Context, client kubernetes.Interface, opts Options) (*cache.ResourceCache, []string, error) {
+	kinds := opts.kinds()
+	results := make([][]runtime.Object, len(kinds))
+	errs := make([]error, len(kinds))
+	var wg sync.WaitGroup
+	for i, wk := range kinds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = k8s.List(ctx, client, wk, opts.Namespace)
+		}()
+	}
+	wg.Wait()
+
+	resourceCache := cache.NewResourceCache()
+	var forbidden []string
+	for i, wk := range kinds {
+		if apierrors.IsForbidden(errs[i]) {
+			log.WithField("kind", wk.Kind).Warn("Not allowed to list this kind; leaving it out of the graph")
+			forbidden = append(forbidden, wk.Kind)
+			continue
+		}
+		if errs[i] != nil {
+			return nil, nil, errs[i]
+		}
+		for _, obj := range results[i] {
+			if s, ok := obj.(*corev1.Secret); ok {
+				obj = k8s.TrimSecret(s, false)
+			}
+			resourceCache.Upsert(obj)
+		}
+	}
+	return resourceCache, forbidden, nil
+}
+
+// Build lists the selected kinds and builds their graph, as revision 1. Kinds
+// that may not be listed are recorded in metadata.disabledKinds.
+func Build(ctx context.Context, client kubernetes.Interface, opts Options) (graph.Graph, error) {
+	resourceCache, forbidden, err := Load(ctx, client, opts)
+	if err != nil {
+		return graph.Graph{}, err
+	}
+	var listed []string
+	for _, wk := range opts.kinds() {
+		if !slices.Contains(forbidden, wk.Kind) {
+			listed = append(listed, wk.Kind)
+		}
+	}
+	g, err := graph.BuildGraph(ctx, resourceCache, 1, graph.WithWatchedKinds(listed))
+	if err != nil {
+		return graph.Graph{}, fmt.Errorf("failed to build graph: %w", err)
+	}
+	if opts.Namespace != "" {
+		g = graph.Subgraph(g, graph.Filter{Namespaces: []string{opts.Namespace}, IncludeExternal: true})
+	}
+	if len(forbidden) > 0 {
+		g.Meta().DisabledKinds = forbidden
+	}
+	return g, nil
+}
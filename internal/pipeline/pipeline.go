@@ -0,0 +1,578 @@
+// Package pipeline runs the informers and cache of one cluster: the RBAC
+// preflight, one supervised informer per watched kind, the initial sync and
+// the watch health reporting. The graph builds and emits are left to the
+// caller.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/admin"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/shard"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	cachepkg "k8s.io/client-go/tools/cache"
+)
+
+// Options configures a pipeline.
+type Options struct {
+	// Kinds, if set, restricts the pipeline to these kinds. Otherwise every
+	// watched kind is, except opt-in ones not in EnableKinds.
+	Kinds map[string]bool
+	// EnableKinds lists the opt-in kinds, e.g. Secret, watched along with
+	// the default ones.
+	EnableKinds map[string]bool
+	// SecretCerts selects the TLS Secrets whose certificate is kept.
+	SecretCerts k8s.CertPolicy
+	// Namespaces, if set, restricts the namespaced kinds, and the Namespace
+	// objects, to these namespaces. A single namespace is watched with
+	// namespace-scoped informers; several are filtered from cluster-wide ones.
+	Namespaces []string
+	// ResyncPeriod is the informers' resync period (0 disables resyncs).
+	ResyncPeriod time.Duration
+	// SkipPreflight disables the RBAC preflight check.
+	SkipPreflight bool
+	// DegradedOK disables kinds failing the RBAC preflight instead of failing.
+	DegradedOK bool
+	// Shard restricts the pipeline to the namespaces owned by this instance.
+	Shard shard.Shard
+	// ExitOnWatchFailure, if positive, exits the process once a kind's
+	// list/watch has failed this many times in a row.
+	ExitOnWatchFailure int
+	// SyncTimeout, if positive, bounds the wait for the initial sync.
+	SyncTimeout time.Duration
+	// AllowPartialSync continues with the synced kinds when SyncTimeout
+	// expires instead of exiting.
+	AllowPartialSync bool
+	// MetadataOnly lists the kinds watched through metadata-only informers.
+	MetadataOnly map[string]bool
+	// IgnoredFields are the fields whose changes alone trigger no rebuild.
+	IgnoredFields cache.IgnoredFields
+	// TrimObjects stores objects trimmed to the fields the graph reads,
+	// except those of the source kinds of RelationshipRules.
+	TrimObjects bool
+	// KindRetryInterval is how often kinds disabled for lack of
+	// permission are retried.
+	KindRetryInterval time.Duration
+	// DeletedLinger keeps deleted objects in the graph, marked
+	// TERMINATING, for this long.
+	DeletedLinger time.Duration
+	// AnnotationProperties, if set, promotes annotations to properties.
+	AnnotationProperties *graph.AnnotationProperties
+	// RelationshipRules, if set, derive relationships from object fields.
+	RelationshipRules *graph.RelationshipRules
+	// SizeCaps bound the nodes of each built graph.
+	SizeCaps graph.SizeCaps
+	// MissingPlaceholders adds a placeholder node for every missing
+	// dependency.
+	MissingPlaceholders bool
+	// NormalEvents also watches Normal events, not only Warning ones.
+	NormalEvents bool
+	// ExtraResources are watched through the dynamic client, e.g. custom
+	// resources, as generic nodes.
+	ExtraResources []schema.GroupVersionResource
+}
+
+// watchedKinds returns the kinds selected by o.Kinds and o.EnableKinds, with
+// Events unfiltered if o.NormalEvents is set.
+func (o Options) watchedKinds() []k8s.WatchedKind {
+	var kinds []k8s.WatchedKind
+	for _, wk := range k8s.WatchedKinds {
+		if o.Kinds != nil && o.Kinds[wk.Kind] || o.Kinds == nil && (!wk.OptIn || o.EnableKinds[wk.Kind]) {
+			if wk.Kind == "Event" && o.NormalEvents {
+				wk.FieldSelector = ""
+			}
+			kinds = append(kinds, wk)
+		}
+	}
+	return kinds
+}
+
+// preflightTimeout bounds the RBAC access reviews run at startup.
+const preflightTimeout = 30 * time.Second
+
+// Pipeline owns the informers and cache of a single cluster.
+type Pipeline struct {
+	name        string // empty in single-cluster mode
+	supervisor  *k8s.KindSupervisor
+	cache       *cache.ResourceCache
+	informers   []informerSync
+	synced      atomic.Bool
+	kinds       []string // kinds enabled at startup
+	shard       shard.Shard
+	watch       *k8s.WatchHealth
+	syncTimeout time.Duration
+	partialSync bool
+	annotations *graph.AnnotationProperties
+	relRules    *graph.RelationshipRules
+	caps        graph.SizeCaps
+	placeholder bool
+
+	mu       sync.Mutex
+	unsynced []string // kinds still unsynced when the sync timeout expired
+}
+
+// informerSync reports the initial sync of one kind's informer.
+type informerSync struct {
+	kind      string
+	hasSynced cachepkg.InformerSynced
+}
+
+// NewForConfig builds the clients for cfg and the pipeline using them.
+func NewForConfig(name string, cfg *rest.Config, opts Options) (*Pipeline, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error building kubernetes clientset: %w", err)
+	}
+	metaClient, err := metadata.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error building metadata client: %w", err)
+	}
+	dynClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error building dynamic client: %w", err)
+	}
+	return New(name, client, metaClient, dynClient, opts)
+}
+
+// New builds the informers and cache for the cluster behind client, runs the
+// RBAC preflight and registers the cache event handlers for every permitted
+// kind. Kinds denied by the preflight are added disabled, to be retried.
+// metaClient is only used for opts.MetadataOnly kinds and dynClient for
+// opts.ExtraResources. Nothing is started yet.
+func New(name string, client kubernetes.Interface, metaClient metadata.Interface, dynClient dynamic.Interface, opts Options) (*Pipeline, error) {
+	p := &Pipeline{
+		name:  name,
+		cache: cache.NewResourceCache(),
+		shard: opts.Shard,
+
+		syncTimeout: opts.SyncTimeout,
+		partialSync: opts.AllowPartialSync,
+		annotations: opts.AnnotationProperties,
+		relRules:    opts.RelationshipRules,
+		caps:        opts.SizeCaps,
+		placeholder: opts.MissingPlaceholders,
+	}
+	p.cache.IgnoredFields = opts.IgnoredFields
+	p.cache.Linger = opts.DeletedLinger
+	p.watch = &k8s.WatchHealth{
+		Cluster:       name,
+		ExitThreshold: opts.ExitOnWatchFailure,
+		OnExhausted: func(kind string, failures int, err error) {
+			p.logger().WithField("kind", kind).WithError(err).Fatalf("List/watch failed %d times in a row; exiting (--exit-on-watch-failure)", failures)
+		},
+	}
+	p.supervisor = &k8s.KindSupervisor{
+		Cluster:       name,
+		RetryInterval: opts.KindRetryInterval,
+		Watch:         p.watch,
+	}
+
+	kinds := opts.watchedKinds()
+	if len(opts.ExtraResources) > 0 {
+		if dynClient == nil {
+			return nil, errors.New("extra resources need a dynamic client")
+		}
+		kinds = append(kinds, k8s.ResolveResources(client.Discovery(), opts.ExtraResources)...)
+	}
+	var denied map[string]bool
+	if !opts.SkipPreflight {
+		var err error
+		if denied, err = p.preflight(client, kinds, opts.DegradedOK); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, wk := range kinds {
+		if opts.MetadataOnly[wk.Kind] && metaClient == nil {
+			return nil, fmt.Errorf("metadata-only %s needs a metadata client", wk.Kind)
+		}
+		newInformer := func() (cachepkg.SharedIndexInformer, error) {
+			return p.newInformer(client, metaClient, dynClient, wk, opts)
+		}
+		if err := p.supervisor.Add(wk.Kind, newInformer, denied[wk.Kind]); err != nil {
+			return nil, err
+		}
+		kind := wk.Kind
+		p.informers = append(p.informers, informerSync{kind: kind, hasSynced: func() bool { return p.supervisor.HasSynced(kind) }})
+		if !denied[wk.Kind] {
+			p.kinds = append(p.kinds, wk.Kind)
+		}
+	}
+	return p, nil
+}
+
+// NewOffline returns a pipeline without informers whose cache is filled by
+// the caller, e.g. from an audit log. It counts as synced.
+func NewOffline(name string) *Pipeline {
+	p := &Pipeline{name: name, cache: cache.NewResourceCache(), supervisor: &k8s.KindSupervisor{}, watch: &k8s.WatchHealth{}}
+	p.synced.Store(true)
+	return p
+}
+
+// newInformer creates an informer for wk, from a factory of its own so it
+// can be stopped and recreated independently of the other kinds, and
+// registers the cache event handlers on it.
+func (p *Pipeline) newInformer(client kubernetes.Interface, metaClient metadata.Interface, dynClient dynamic.Interface, wk k8s.WatchedKind, opts Options) (cachepkg.SharedIndexInformer, error) {
+	namespace := ""
+	if len(opts.Namespaces) == 1 && !wk.ClusterScoped {
+		namespace = opts.Namespaces[0]
+	}
+	var inf cachepkg.SharedIndexInformer
+	if wk.Dynamic {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynClient, opts.ResyncPeriod, namespace, wk.TweakListOptions)
+		inf = k8s.NewDynamicInformer(factory, wk)
+	} else if opts.MetadataOnly[wk.Kind] {
+		var err error
+		factory := metadatainformer.NewFilteredSharedInformerFactory(metaClient, opts.ResyncPeriod, namespace, wk.TweakListOptions)
+		if inf, err = k8s.NewMetadataInformer(factory, wk); err != nil {
+			return nil, err
+		}
+	} else {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, opts.ResyncPeriod, informers.WithNamespace(namespace), informers.WithTweakListOptions(wk.TweakListOptions))
+		inf, _ = k8s.NewInformer(factory, wk.Kind)
+		// relationship rules read fields trimming would drop
+		trim := opts.TrimObjects && !opts.RelationshipRules.Reads(wk.Kind)
+		if wk.Kind == "Secret" {
+			// values never reach the informer's store
+			if err := inf.SetTransform(k8s.SecretTransform(opts.SecretCerts, trim)); err != nil {
+				return nil, fmt.Errorf("failed to set secret transform: %w", err)
+			}
+		} else if trim {
+			if err := inf.SetTransform(k8s.TrimTransform); err != nil {
+				return nil, fmt.Errorf("failed to set trim transform for %s: %w", wk.Kind, err)
+			}
+		}
+	}
+	var handler cachepkg.ResourceEventHandler = p.cache.AddEventHandler(wk.Kind)
+	if filter := p.filter(wk, opts.Namespaces); filter != nil {
+		handler = cachepkg.FilteringResourceEventHandler{FilterFunc: filter, Handler: handler}
+	}
+	if _, err := inf.AddEventHandler(handler); err != nil {
+		return nil, fmt.Errorf("failed to add event handler for %s: %w", wk.Kind, err)
+	}
+	return inf, nil
+}
+
+// filter returns the event filter of wk for the shard and the namespaces,
+// or nil if every object is kept.
+func (p *Pipeline) filter(wk k8s.WatchedKind, namespaces []string) func(obj interface{}) bool {
+	scoped := len(namespaces) > 1 && !wk.ClusterScoped || len(namespaces) > 0 && wk.Kind == "Namespace"
+	if !p.shard.Enabled() && !scoped {
+		return nil
+	}
+	return func(obj interface{}) bool {
+		meta := k8s.GetObjectMeta(obj)
+		if scoped {
+			namespace := meta.Namespace
+			if wk.Kind == "Namespace" {
+				namespace = meta.Name
+			}
+			if !slices.Contains(namespaces, namespace) {
+				return false
+			}
+		}
+		return p.shard.Owns(meta.Namespace)
+	}
+}
+
+// preflight checks list/watch access for kinds and returns the denied ones.
+// Without degradedOK any missing permission is fatal.
+func (p *Pipeline) preflight(client kubernetes.Interface, kinds []k8s.WatchedKind, degradedOK bool) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+
+	err := k8s.CheckPermissions(ctx, client, kinds)
+	var missingErr *k8s.MissingPermissionsError
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.As(err, &missingErr) || !degradedOK {
+		return nil, fmt.Errorf("RBAC preflight failed: %w", err)
+	}
+
+	denied := make(map[string]bool)
+	var disabled []string
+	for _, m := range missingErr.Missing {
+		if !denied[m.Kind] {
+			disabled = append(disabled, m.Kind)
+		}
+		denied[m.Kind] = true
+	}
+	p.logger().WithField("disabledKinds", disabled).Warnf("%v; continuing without these kinds until they are allowed (--degraded-ok)", err)
+	return denied, nil
+}
+
+// Name returns the cluster name, empty in single-cluster mode.
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Cache returns the pipeline's cache.
+func (p *Pipeline) Cache() *cache.ResourceCache {
+	return p.cache
+}
+
+// Kinds returns the kinds enabled at startup.
+func (p *Pipeline) Kinds() []string {
+	return p.kinds
+}
+
+// Synced reports whether the initial sync has completed.
+func (p *Pipeline) Synced() bool {
+	return p.synced.Load()
+}
+
+// component is the readiness component name for this pipeline.
+func (p *Pipeline) component() string {
+	if p.name == "" {
+		return "informers"
+	}
+	return "cluster/" + p.name
+}
+
+// logger returns a log entry tagged with the pipeline's cluster.
+func (p *Pipeline) logger() *log.Entry {
+	return log.WithField("cluster", p.name)
+}
+
+// Start runs the informers and, in the background, waits for the initial
+// sync. Cache changes are forwarded to changed only once the pipeline has
+// synced, so one slow or failing cluster never blocks the others.
+func (p *Pipeline) Start(ctx context.Context, health *admin.Health, changed chan<- struct{}) {
+	health.SetReady(p.component(), false)
+	p.supervisor.OnChange = func() {
+		if p.synced.Load() {
+			notify(changed)
+		}
+	}
+	p.supervisor.Start(ctx)
+
+	go func() {
+		p.logger().Info("Waiting for initial cache sync...")
+		if !p.waitForSync(ctx, health, changed) {
+			p.logger().Error("Failed to sync caches")
+			return
+		}
+		p.synced.Store(true)
+		health.SetReady(p.component(), true)
+		notify(changed)
+
+		ticker := time.NewTicker(watchHealthInterval)
+		defer ticker.Stop()
+		bookmarks := time.NewTicker(bookmarkPollInterval)
+		defer bookmarks.Stop()
+		for {
+			select {
+			case <-p.cache.Changed():
+				notify(changed)
+			case <-ticker.C:
+				p.reportWatchHealth(health)
+			case <-bookmarks.C:
+				p.pollBookmarks()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the informers and waits for their event handlers to return.
+func (p *Pipeline) Shutdown() {
+	p.supervisor.Shutdown()
+}
+
+// syncPollInterval is how often informers are checked during the initial sync.
+const syncPollInterval = 100 * time.Millisecond
+
+// pendingKinds returns the kinds whose informer hasn't synced yet.
+func (p *Pipeline) pendingKinds() []string {
+	var pending []string
+	for _, inf := range p.informers {
+		if !inf.hasSynced() {
+			pending = append(pending, inf.kind)
+		}
+	}
+	return pending
+}
+
+// waitForSync waits for every informer to sync. Once syncTimeout expires it
+// either exits, naming the kinds still unsynced, or with partialSync records
+// them, marks the pipeline degraded and keeps waiting for them in the
+// background. It returns false if ctx is cancelled first.
+func (p *Pipeline) waitForSync(ctx context.Context, health *admin.Health, changed chan<- struct{}) bool {
+	var timeout <-chan time.Time
+	if p.syncTimeout > 0 {
+		timer := time.NewTimer(p.syncTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := p.pendingKinds()
+		if len(pending) == 0 {
+			p.logger().Info("Caches synced.")
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			entry := p.logger().WithField("unsyncedKinds", pending)
+			if !p.partialSync {
+				entry.Fatalf("Informers not synced within %s (--sync-timeout); check RBAC and apiserver connectivity, or continue without them with --allow-partial-sync", p.syncTimeout)
+			}
+			entry.Errorf("Informers not synced within %s (--sync-timeout); continuing without these kinds (--allow-partial-sync)", p.syncTimeout)
+			p.setUnsynced(pending, health)
+			go p.awaitLateSync(ctx, health, changed)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// awaitLateSync keeps checking the kinds left unsynced by the sync timeout
+// until all have synced, triggering a rebuild whenever one does.
+func (p *Pipeline) awaitLateSync(ctx context.Context, health *admin.Health, changed chan<- struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		pending := p.pendingKinds()
+		if len(pending) == len(p.unsyncedKinds()) {
+			continue
+		}
+		p.logger().WithField("unsyncedKinds", pending).Info("Late informer sync")
+		p.setUnsynced(pending, health)
+		notify(changed)
+		if len(pending) == 0 {
+			return
+		}
+	}
+}
+
+// setUnsynced records the unsynced kinds and reports them to health.
+func (p *Pipeline) setUnsynced(kinds []string, health *admin.Health) {
+	p.mu.Lock()
+	p.unsynced = kinds
+	p.mu.Unlock()
+
+	reason := ""
+	if len(kinds) > 0 {
+		reason = "initial sync incomplete for " + strings.Join(kinds, ", ")
+	}
+	health.SetDegraded("sync/"+p.component(), reason)
+}
+
+// completeKinds returns the enabled kinds, except the disabled and unsynced
+// ones, whose objects are thus all in the cache.
+func (p *Pipeline) completeKinds(disabled, unsynced []string) []string {
+	kinds := make([]string, 0, len(p.kinds))
+	for _, kind := range p.kinds {
+		if !slices.Contains(disabled, kind) && !slices.Contains(unsynced, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// unsyncedKinds returns the kinds left unsynced by the sync timeout.
+func (p *Pipeline) unsyncedKinds() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.unsynced...)
+}
+
+// bookmarkPollInterval is how often the informers' resourceVersions are
+// checked for bookmarks, bounding the error of recorded bookmark times.
+const bookmarkPollInterval = time.Second
+
+// pollBookmarks hands the informers' resourceVersions to the cache, which
+// records those past every object it received as bookmarks. They only show
+// in the next graph built for another reason: a bookmark alone changes
+// nothing worth a rebuild.
+func (p *Pipeline) pollBookmarks() {
+	for kind, rv := range p.supervisor.LastSyncResourceVersions() {
+		p.cache.Bookmark(kind, rv)
+	}
+}
+
+// watchHealthInterval is how often stale kinds are reported to health.
+const watchHealthInterval = 5 * time.Second
+
+// reportWatchHealth marks the pipeline degraded while any kind's watch is failing.
+func (p *Pipeline) reportWatchHealth(health *admin.Health) {
+	reason := ""
+	if stale := p.watch.StaleKinds(); len(stale) > 0 {
+		reason = "list/watch failing for " + strings.Join(stale, ", ")
+	}
+	health.SetDegraded("watch/"+p.component(), reason)
+}
+
+// Build builds this cluster's graph and records its disabled kinds.
+func (p *Pipeline) Build(ctx context.Context, revision uint64) (graph.Graph, error) {
+	disabled := p.supervisor.Disabled()
+	unsynced := p.unsyncedKinds()
+	buildOpts := []graph.BuildOption{
+		graph.WithAnnotationProperties(p.annotations),
+		graph.WithRelationshipRules(p.relRules),
+		graph.WithWatchedKinds(p.completeKinds(disabled, unsynced)),
+	}
+	if p.placeholder {
+		buildOpts = append(buildOpts, graph.WithMissingPlaceholders())
+	}
+	if p.caps.Enabled() {
+		buildOpts = append(buildOpts, graph.WithSizeCaps(p.caps))
+	}
+	g, err := graph.BuildGraph(ctx, p.cache, revision, buildOpts...)
+	if err != nil {
+		return graph.Graph{}, err
+	}
+	if len(disabled) > 0 {
+		g.Meta().DisabledKinds = disabled
+	}
+	if stale := p.watch.StaleKinds(); len(stale) > 0 {
+		g.Meta().StaleKinds = stale
+	}
+	if len(unsynced) > 0 {
+		g.Meta().UnsyncedKinds = unsynced
+	}
+	if p.shard.Enabled() {
+		s := p.shard
+		g.Meta().Shard = &s
+	}
+	return g, nil
+}
+
+// notify sends a non-blocking notification on a single-slot channel.
+func notify(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,159 @@
+// Package ratelog rate-limits high-volume log lines, such as one line per
+// informer event, without ever suppressing errors.
+package ratelog
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBurst is the number of lines per key let through per interval.
+	DefaultBurst = 100
+	// DefaultInterval is the window Burst applies to.
+	DefaultInterval = time.Minute
+)
+
+// Default is the limiter used for per-event logging.
+var Default = New(DefaultBurst, DefaultInterval)
+
+// Limiter lets through the first burst lines per key in each interval and
+// reports how many it dropped when the interval rolls over.
+type Limiter struct {
+	// Logger receives the suppression summaries; nil means the standard logger.
+	Logger *log.Logger
+
+	mu          sync.Mutex
+	burst       int
+	interval    time.Duration
+	windowStart time.Time
+	counts      map[string]int
+	suppressed  uint64
+}
+
+// creates a limiter; burst <= 0 disables limiting.
+func New(burst int, interval time.Duration) *Limiter {
+	return &Limiter{burst: burst, interval: interval, counts: make(map[string]int)}
+}
+
+// Configure changes the limits, starting a new interval.
+func (l *Limiter) Configure(burst int, interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.burst = burst
+	l.interval = interval
+	l.windowStart = time.Time{}
+	l.counts = make(map[string]int)
+}
+
+// Log writes msg on entry at level unless key has used up its burst.
+// Errors and worse are always written. Lines below the logger's level are
+// dropped without being counted.
+func (l *Limiter) Log(entry *log.Entry, level log.Level, key, msg string) {
+	if level <= log.ErrorLevel {
+		entry.Log(level, msg)
+		return
+	}
+	if !entry.Logger.IsLevelEnabled(level) {
+		return
+	}
+	if l.allow(key) {
+		entry.Log(level, msg)
+	}
+}
+
+// Run reports dropped lines every interval even when no new lines arrive,
+// until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	l.mu.Lock()
+	interval := l.interval
+	l.mu.Unlock()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			var summary map[string]int
+			if now := time.Now(); now.Sub(l.windowStart) >= l.interval {
+				summary = l.rollover()
+				l.windowStart = now
+			}
+			l.mu.Unlock()
+			l.logSummary(summary, interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Suppressed returns the number of lines dropped so far.
+func (l *Limiter) Suppressed() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.suppressed
+}
+
+// allow counts one line for key and reports whether it fits the burst.
+func (l *Limiter) allow(key string) bool {
+	l.mu.Lock()
+	if l.burst <= 0 {
+		l.mu.Unlock()
+		return true
+	}
+	var summary map[string]int
+	if now := time.Now(); now.Sub(l.windowStart) >= l.interval {
+		summary = l.rollover()
+		l.windowStart = now
+	}
+	l.counts[key]++
+	ok := l.counts[key] <= l.burst
+	if !ok {
+		l.suppressed++
+	}
+	interval := l.interval
+	l.mu.Unlock()
+
+	l.logSummary(summary, interval)
+	return ok
+}
+
+// rollover resets the counts and returns the lines dropped per key in the
+// interval that just ended. Callers hold l.mu.
+func (l *Limiter) rollover() map[string]int {
+	dropped := make(map[string]int)
+	for key, n := range l.counts {
+		if n > l.burst {
+			dropped[key] = n - l.burst
+		}
+	}
+	l.counts = make(map[string]int)
+	return dropped
+}
+
+// logSummary writes one line per key that had lines dropped.
+func (l *Limiter) logSummary(dropped map[string]int, interval time.Duration) {
+	logger := l.Logger
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	keys := make([]string, 0, len(dropped))
+	for key := range dropped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		logger.WithFields(log.Fields{
+			"key":        key,
+			"suppressed": dropped[key],
+			"interval":   interval,
+		}).Infof("Suppressed %d %q log lines", dropped[key], key)
+	}
+}
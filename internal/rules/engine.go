@@ -0,0 +1,170 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout bounds each action unless the engine sets its own.
+const DefaultTimeout = 10 * time.Second
+
+// Engine evaluates rules against the change of every build and runs the
+// actions of the events, in the background so builds never wait on them.
+type Engine struct {
+	// Timeout bounds each action. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Client posts webhooks; http.DefaultClient if nil.
+	Client *http.Client
+
+	rules    []Rule
+	byID     map[string]int
+	limiters []*limiter
+
+	mu      sync.Mutex
+	prev    graph.Graph
+	started bool
+	running sync.WaitGroup
+}
+
+// NewEngine returns an engine for rules, which must be valid.
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{rules: rules, byID: make(map[string]int, len(rules)), limiters: make([]*limiter, len(rules))}
+	for i, r := range rules {
+		e.byID[r.ID] = i
+		events, interval := r.limits()
+		e.limiters[i] = &limiter{events: events, interval: interval}
+	}
+	return e
+}
+
+// OnGraphBuilt is a hooks.OnGraphBuiltFunc evaluating the rules against
+// the change from the previous build. The first build only sets the
+// baseline: the objects already there are not changes.
+func (e *Engine) OnGraphBuilt(ctx context.Context, g graph.Graph, _ []types.EntityKey) {
+	e.mu.Lock()
+	prev, started := e.prev, e.started
+	e.prev, e.started = g, true
+	e.mu.Unlock()
+	if !started {
+		return
+	}
+	for _, ev := range Evaluate(e.rules, prev, g) {
+		e.fire(ev)
+	}
+}
+
+// Wait blocks until the actions started so far have finished.
+func (e *Engine) Wait() {
+	e.running.Wait()
+}
+
+// fire runs the action of ev's rule unless the rule is rate limited.
+func (e *Engine) fire(ev Event) {
+	i := e.byID[ev.Rule]
+	r := e.rules[i]
+	if !e.limiters[i].allow(time.Now()) {
+		metrics.RuleEvents.WithLabelValues(r.ID, "limited").Inc()
+		log.WithField("rule", r.ID).WithField("revision", ev.Revision).Debug("Rule rate limited, dropping event")
+		return
+	}
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		timeout := e.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := e.run(ctx, r.Action, ev); err != nil {
+			metrics.RuleEvents.WithLabelValues(r.ID, "failed").Inc()
+			log.WithField("rule", r.ID).WithField("revision", ev.Revision).WithError(err).Error("Rule action failed")
+			return
+		}
+		metrics.RuleEvents.WithLabelValues(r.ID, "fired").Inc()
+	}()
+}
+
+// run posts ev to the webhook of a, or runs its command with ev on stdin.
+func (e *Engine) run(ctx context.Context, a Action, ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if a.Webhook != "" {
+		return e.post(ctx, a.Webhook, payload)
+	}
+	args := strings.Fields(a.Command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "SATELLITE_RULE="+ev.Rule, "SATELLITE_EVENT="+ev.Event)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// post sends payload to target, failing on a non-2xx response. The URL is
+// left out of errors as it may carry a token.
+func (e *Engine) post(ctx context.Context, target string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return errors.New("failed to create webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// limiter lets through events actions per interval.
+type limiter struct {
+	mu          sync.Mutex
+	events      int
+	interval    time.Duration
+	windowStart time.Time
+	count       int
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.windowStart) >= l.interval {
+		l.windowStart, l.count = now, 0
+	}
+	l.count++
+	return l.count <= l.events
+}
@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"slices"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// Event is one change a rule matched, the payload of its action.
+type Event struct {
+	Rule     string `json:"rule"`
+	Event    string `json:"event"`
+	Revision uint64 `json:"revision"`
+	// Node is the node matched, or the source of the relationships counted.
+	Node *graph.GraphEntityKey `json:"node,omitempty"`
+	// Properties are the node's properties, as of the build for all but
+	// nodeRemoved.
+	Properties   map[string]string        `json:"properties,omitempty"`
+	ChangedKeys  []string                 `json:"changedKeys,omitempty"`
+	Relationship *graph.GraphRelationship `json:"relationship,omitempty"`
+	// From and To are the old and new targets of a relationshipMoved.
+	From  *graph.GraphEntityKey `json:"from,omitempty"`
+	To    *graph.GraphEntityKey `json:"to,omitempty"`
+	Count *Count                `json:"count,omitempty"`
+}
+
+// Count is the change a countBelow matched.
+type Count struct {
+	Previous int `json:"previous"`
+	Current  int `json:"current"`
+	Below    int `json:"below"`
+}
+
+// Evaluate returns the events of rules in the change from prev to next, in
+// rule order.
+func Evaluate(rules []Rule, prev, next graph.Graph) []Event {
+	d := graph.Diff(prev, next)
+	var events []Event
+	for _, r := range rules {
+		for _, e := range r.Match.events(d, prev, next) {
+			e.Rule, e.Event, e.Revision = r.ID, r.Match.Event, next.GraphRevision
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// matches reports whether k passes the Kind and Namespace filters.
+func (m Match) matches(k graph.GraphEntityKey) bool {
+	return (m.Kind == "" || m.Kind == k.Kind) && (m.Namespace == "" || m.Namespace == k.Namespace)
+}
+
+// matchesRel reports whether rel passes the filters.
+func (m Match) matchesRel(rel graph.GraphRelationship) bool {
+	return m.matches(rel.Source) && (m.RelationshipType == "" || m.RelationshipType == rel.RelationshipType)
+}
+
+// events returns the changes of d, from prev to next, m matches.
+func (m Match) events(d graph.Delta, prev, next graph.Graph) []Event {
+	var events []Event
+	switch m.Event {
+	case NodeAdded:
+		for _, n := range d.AddedNodes {
+			if m.matches(n.Key) {
+				events = append(events, Event{Node: &n.Key, Properties: n.Properties})
+			}
+		}
+	case NodeRemoved:
+		removed := make(map[graph.GraphEntityKey]bool, len(d.RemovedNodes))
+		for _, k := range d.RemovedNodes {
+			removed[k] = true
+		}
+		for _, n := range prev.Nodes {
+			if removed[n.Key] && m.matches(n.Key) {
+				events = append(events, Event{Node: &n.Key, Properties: n.Properties})
+			}
+		}
+	case NodeUpdated:
+		for _, u := range d.UpdatedNodes {
+			if m.matches(u.Key) && (m.Property == "" || slices.Contains(u.ChangedKeys, m.Property)) {
+				events = append(events, Event{Node: &u.Key, Properties: u.Properties, ChangedKeys: u.ChangedKeys})
+			}
+		}
+	case RelationshipAdded, RelationshipRemoved:
+		rels := d.AddedRelationships
+		if m.Event == RelationshipRemoved {
+			rels = d.RemovedRelationships
+		}
+		for _, rel := range rels {
+			if m.matchesRel(rel) {
+				events = append(events, Event{Relationship: &rel})
+			}
+		}
+	case RelationshipMoved:
+		events = m.moves(d)
+	case CountBelow:
+		events = m.counts(prev, next)
+	case MissingDependency:
+		for _, rel := range d.AddedRelationships {
+			if rel.Properties[graph.MissingTargetProperty] == "true" && m.matchesRel(rel) {
+				events = append(events, Event{Relationship: &rel})
+			}
+		}
+		for _, u := range d.UpdatedRelationships {
+			if u.Properties[graph.MissingTargetProperty] == "true" && slices.Contains(u.ChangedKeys, graph.MissingTargetProperty) && m.matchesRel(u.GraphRelationship) {
+				events = append(events, Event{Relationship: &u.GraphRelationship})
+			}
+		}
+	}
+	return events
+}
+
+// moves pairs, per source, the first removed and added relationship of the
+// type.
+func (m Match) moves(d graph.Delta) []Event {
+	from := make(map[graph.GraphEntityKey]graph.GraphEntityKey)
+	for _, rel := range d.RemovedRelationships {
+		if _, ok := from[rel.Source]; !ok && m.matchesRel(rel) {
+			from[rel.Source] = rel.Target
+		}
+	}
+	var events []Event
+	for _, rel := range d.AddedRelationships {
+		old, ok := from[rel.Source]
+		if !ok || !m.matchesRel(rel) || old == rel.Target {
+			continue
+		}
+		delete(from, rel.Source)
+		events = append(events, Event{Node: &rel.Source, Relationship: &rel, From: &old, To: &rel.Target})
+	}
+	return events
+}
+
+// counts returns the drops below m.Below: of the number of matching nodes,
+// or of the relationships of the type of each matching source present in
+// both graphs, since a source going away loses nothing.
+func (m Match) counts(prev, next graph.Graph) []Event {
+	if m.RelationshipType == "" {
+		before, after := m.countNodes(prev), m.countNodes(next)
+		if before >= m.Below && after < m.Below {
+			return []Event{{Count: &Count{Previous: before, Current: after, Below: m.Below}}}
+		}
+		return nil
+	}
+
+	before, after := m.countRels(prev), m.countRels(next)
+	var events []Event
+	for _, n := range next.Nodes {
+		b, ok := before[n.Key]
+		if !ok || b < m.Below || after[n.Key] >= m.Below {
+			continue
+		}
+		events = append(events, Event{Node: &n.Key, Properties: n.Properties, Count: &Count{Previous: b, Current: after[n.Key], Below: m.Below}})
+	}
+	return events
+}
+
+func (m Match) countNodes(g graph.Graph) int {
+	n := 0
+	for _, node := range g.Nodes {
+		if m.matches(node.Key) {
+			n++
+		}
+	}
+	return n
+}
+
+// countRels counts the relationships of the type per matching node of g,
+// including nodes with none.
+func (m Match) countRels(g graph.Graph) map[graph.GraphEntityKey]int {
+	counts := make(map[graph.GraphEntityKey]int)
+	for _, n := range g.Nodes {
+		if m.matches(n.Key) {
+			counts[n.Key] = 0
+		}
+	}
+	for _, rel := range g.Relationships {
+		if _, ok := counts[rel.Source]; ok && rel.RelationshipType == m.RelationshipType {
+			counts[rel.Source]++
+		}
+	}
+	return counts
+}
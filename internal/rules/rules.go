@@ -0,0 +1,150 @@
+// Package rules runs actions, such as paging through a webhook, when a
+// graph build brings a change a configured rule matches: a pod moving
+// nodes, a Service losing its last backend, a dependency going missing.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Event types a rule matches.
+const (
+	// NodeAdded and NodeRemoved match nodes appearing and disappearing.
+	NodeAdded   = "nodeAdded"
+	NodeRemoved = "nodeRemoved"
+	// NodeUpdated matches nodes whose properties changed, or only Property
+	// if set.
+	NodeUpdated = "nodeUpdated"
+	// RelationshipAdded and RelationshipRemoved match relationships by
+	// their source.
+	RelationshipAdded   = "relationshipAdded"
+	RelationshipRemoved = "relationshipRemoved"
+	// RelationshipMoved matches a source losing a relationship of a type
+	// and gaining one of the same type to another target in the same
+	// build, e.g. a pod's SCHEDULED_ON.
+	RelationshipMoved = "relationshipMoved"
+	// CountBelow matches the number of matching nodes, or with a
+	// RelationshipType the number of such relationships of each matching
+	// source, dropping below Below.
+	CountBelow = "countBelow"
+	// MissingDependency matches a relationship becoming flagged with
+	// graph.MissingTargetProperty.
+	MissingDependency = "missingDependency"
+)
+
+var eventTypes = []string{NodeAdded, NodeRemoved, NodeUpdated, RelationshipAdded, RelationshipRemoved, RelationshipMoved, CountBelow, MissingDependency}
+
+// Default rate limit of a rule.
+const (
+	DefaultRateLimitEvents   = 10
+	DefaultRateLimitInterval = time.Minute
+)
+
+// Rule runs Action for every change of a build that Match matches.
+type Rule struct {
+	// ID identifies the rule in payloads, logs and metrics.
+	ID        string     `json:"id"`
+	Match     Match      `json:"match"`
+	Action    Action     `json:"action"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// Match selects changes. Kind and Namespace filter nodes, or the source of
+// relationships; empty matches any.
+type Match struct {
+	Event            string `json:"event"`
+	Kind             string `json:"kind,omitempty"`
+	Namespace        string `json:"namespace,omitempty"`
+	RelationshipType string `json:"relationshipType,omitempty"`
+	Property         string `json:"property,omitempty"`
+	Below            int    `json:"below,omitempty"`
+}
+
+// Action is run with each matched Event: POSTed as JSON to Webhook, or
+// written to the stdin of Command, split on whitespace. Exactly one is set.
+type Action struct {
+	Webhook string `json:"webhook,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// RateLimit lets through at most Events actions of a rule per Interval;
+// the others are dropped and counted.
+type RateLimit struct {
+	Events   int             `json:"events"`
+	Interval metav1.Duration `json:"interval"`
+}
+
+// limits returns the rate limit of r, defaulted.
+func (r Rule) limits() (int, time.Duration) {
+	if r.RateLimit == nil {
+		return DefaultRateLimitEvents, DefaultRateLimitInterval
+	}
+	return r.RateLimit.Events, r.RateLimit.Interval.Duration
+}
+
+// Validate checks rules have unique IDs, known events with the fields they
+// need, and one usable action each.
+func Validate(rules []Rule) error {
+	seen := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			if r.ID != "" {
+				return fmt.Errorf("rules[%d] (%s): %w", i, r.ID, err)
+			}
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("rules[%d]: duplicate rule id %q", i, r.ID)
+		}
+		seen[r.ID] = true
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	if r.ID == "" {
+		return errors.New("id is required")
+	}
+	m := r.Match
+	switch m.Event {
+	case NodeAdded, NodeRemoved, NodeUpdated, MissingDependency:
+	case RelationshipAdded, RelationshipRemoved, RelationshipMoved:
+		if m.RelationshipType == "" && m.Event == RelationshipMoved {
+			return errors.New("match.relationshipType is required for relationshipMoved")
+		}
+	case CountBelow:
+		if m.Below <= 0 {
+			return errors.New("match.below must be positive for countBelow")
+		}
+	case "":
+		return errors.New("match.event is required")
+	default:
+		return fmt.Errorf("unknown match.event %q (want one of %s)", m.Event, strings.Join(eventTypes, ", "))
+	}
+	if m.Property != "" && m.Event != NodeUpdated {
+		return errors.New("match.property only applies to nodeUpdated")
+	}
+
+	switch a := r.Action; {
+	case (a.Webhook == "") == (a.Command == ""):
+		return errors.New("exactly one of action.webhook and action.command is required")
+	case a.Webhook != "":
+		u, err := url.Parse(a.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("action.webhook is not an http(s) URL")
+		}
+	case strings.TrimSpace(a.Command) == "":
+		return errors.New("action.command is empty")
+	}
+
+	if l := r.RateLimit; l != nil && (l.Events <= 0 || l.Interval.Duration <= 0) {
+		return errors.New("rateLimit.events and rateLimit.interval must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/tthuwng/satellite/internal/runner")
+
+// BuildFunc builds the graph for the given revision.
+type BuildFunc func(ctx context.Context, revision uint64) (graph.Graph, error)
+
+// Runner drives the build loop: it rebuilds the graph whenever the cache
+// changes or a snapshot is triggered, and hands the result to the emit queue.
+type Runner struct {
+	// Seq, if set, returns the change counter of the built state (see
+	// cache.ResourceCache.Seq). The loop rebuilds whenever it differs from
+	// the value read before the last build, so a change is never lost to a
+	// coalesced or drained notification. Set it before calling Run.
+	Seq func() uint64
+
+	build   BuildFunc
+	queue   *emitter.Queue
+	changed <-chan struct{}
+	trigger <-chan struct{}
+
+	mu       sync.Mutex
+	revision uint64
+	builtSeq uint64 // Seq() read before the last build
+	failed   bool   // the last build failed
+}
+
+// creates a runner building on every signal from changed or trigger.
+// trigger may be nil.
+func New(build BuildFunc, queue *emitter.Queue, changed, trigger <-chan struct{}) *Runner {
+	return &Runner{
+		build:   build,
+		queue:   queue,
+		changed: changed,
+		trigger: trigger,
+	}
+}
+
+// Run builds and submits graphs until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	log.Info("Starting graph build loop...")
+	for {
+		if r.stale() && ctx.Err() == nil {
+			log.Debug("Cache changed during the last build: Rebuilding graph")
+			_ = r.BuildAndSubmit(ctx)
+			continue
+		}
+
+		select {
+		case <-r.changed:
+			drain(r.changed)
+			log.Debug("Cache changed: Building graph")
+			_ = r.BuildAndSubmit(ctx)
+
+		case <-r.trigger:
+			drain(r.trigger)
+			log.Info("Snapshot triggered: Building graph")
+			_ = r.buildAndSubmit(ctx, true)
+
+		case <-r.emitted():
+			// only a chance to check for changes missed since the last build
+
+		case <-ctx.Done():
+			log.Info("Shutdown signal received, exiting build loop for final emit.")
+			return
+		}
+	}
+}
+
+// BuildAndSubmit builds the next revision and submits it to the emit queue.
+// Each call is traced as one build cycle span parenting the build's spans.
+func (r *Runner) BuildAndSubmit(ctx context.Context) error {
+	return r.buildAndSubmit(ctx, false)
+}
+
+// buildAndSubmit is BuildAndSubmit; if now is set the graph skips the emit
+// queue's minimum interval.
+func (r *Runner) buildAndSubmit(ctx context.Context, now bool) error {
+	// read before the snapshot, so changes racing with it cause a rebuild
+	// rather than being missed
+	if r.Seq != nil {
+		seq := r.Seq()
+		r.mu.Lock()
+		r.builtSeq = seq
+		r.mu.Unlock()
+	}
+	revision := r.nextRevision()
+	ctx, span := tracer.Start(ctx, "runner.BuildCycle", trace.WithAttributes(attribute.Int64("satellite.revision", int64(revision))))
+	defer span.End()
+
+	g, err := r.build(ctx, revision)
+	r.mu.Lock()
+	r.failed = err != nil
+	r.mu.Unlock()
+	if err != nil {
+		log.WithField("revision", revision).WithError(err).Error("Error building graph")
+		metrics.Builds.WithLabelValues("error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(
+		attribute.Int("satellite.nodes", len(g.Nodes)),
+		attribute.Int("satellite.relationships", len(g.Relationships)),
+	)
+	metrics.Builds.WithLabelValues("success").Inc()
+	metrics.GraphRevision.Set(float64(g.GraphRevision))
+	if now {
+		r.queue.SubmitNow(g)
+	} else {
+		r.queue.Submit(g)
+	}
+	return nil
+}
+
+// Final builds the last graph and flushes it through the emit queue,
+// superseding any held graph and bypassing the minimum interval. If Seq is
+// set and nothing changed since the last successful build, no new revision is
+// built and only a held graph is flushed. It gives up once ctx expires even if
+// the output sink is still blocked. Run must have returned, and the informers
+// must have stopped, before Final is called.
+func (r *Runner) Final(ctx context.Context) error {
+	rebuild := drained(r.changed) || r.Seq == nil || r.stale() || r.lastFailed()
+	if rebuild {
+		log.Info("Performing final graph build and emit...")
+	} else {
+		log.Info("No changes since the last build, skipping the final build")
+	}
+	done := make(chan error, 1)
+	go func() {
+		if rebuild {
+			if err := r.BuildAndSubmit(ctx); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- r.queue.Flush(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("final graph: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("final graph not emitted within shutdown timeout: %w", ctx.Err())
+	}
+}
+
+// Revision returns the last revision number handed out.
+func (r *Runner) Revision() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revision
+}
+
+// emitted returns the queue's emit notifications if Seq is set, nil otherwise.
+func (r *Runner) emitted() <-chan struct{} {
+	if r.Seq == nil {
+		return nil
+	}
+	return r.queue.Emitted()
+}
+
+// stale reports whether the state changed since the last build started.
+func (r *Runner) stale() bool {
+	if r.Seq == nil {
+		return false
+	}
+	seq := r.Seq()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return seq != r.builtSeq
+}
+
+// lastFailed reports whether the last build failed.
+func (r *Runner) lastFailed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// Resume continues numbering after revision, e.g. the last one emitted by a
+// previous process. Call it before Run.
+func (r *Runner) Resume(revision uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revision = revision
+}
+
+// nextRevision increments and returns the graph revision.
+func (r *Runner) nextRevision() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revision++
+	return r.revision
+}
+
+// drained drains ch and reports whether it held a notification.
+func drained(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		drain(ch)
+		return true
+	default:
+		return false
+	}
+}
+
+func drain(ch <-chan struct{}) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
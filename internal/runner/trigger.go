@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Trigger requests on-demand snapshots. Requests that arrive while one is
+// already pending coalesce into it rather than queueing.
+type Trigger struct {
+	ch chan struct{}
+}
+
+// creates a new trigger with nothing pending.
+func NewTrigger() *Trigger {
+	return &Trigger{ch: make(chan struct{}, 1)}
+}
+
+// Fire requests a snapshot.
+func (t *Trigger) Fire() {
+	select {
+	case t.ch <- struct{}{}:
+	default:
+	}
+}
+
+// C returns the channel signalled when a snapshot has been requested.
+func (t *Trigger) C() <-chan struct{} {
+	return t.ch
+}
+
+// ForwardSignals fires the trigger for every signal received on sigCh until
+// ctx is cancelled.
+func (t *Trigger) ForwardSignals(ctx context.Context, sigCh <-chan os.Signal) {
+	for {
+		select {
+		case sig := <-sigCh:
+			log.WithField("signal", sig.String()).Info("Snapshot requested by signal")
+			t.Fire()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Handler serves POST requests that fire the trigger.
+func (t *Trigger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		log.WithField("remote", r.RemoteAddr).Info("Snapshot requested over HTTP")
+		t.Fire()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
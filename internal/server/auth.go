@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authnv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Authenticator validates bearer tokens.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (bool, error)
+}
+
+// StaticToken accepts exactly one preconfigured token.
+type StaticToken struct {
+	token []byte
+}
+
+// creates an authenticator accepting token.
+func NewStaticToken(token string) *StaticToken {
+	return &StaticToken{token: []byte(token)}
+}
+
+// Authenticate compares in constant time.
+func (s *StaticToken) Authenticate(_ context.Context, token string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(token), s.token) == 1, nil
+}
+
+// tokenReviewTTL is how long a successful TokenReview is cached.
+const tokenReviewTTL = time.Minute
+
+// maxCachedReviews bounds the TokenReview cache.
+const maxCachedReviews = 1024
+
+// TokenReviewer accepts any token the Kubernetes API server authenticates,
+// such as ServiceAccount tokens. Successful reviews are cached briefly.
+type TokenReviewer struct {
+	client    kubernetes.Interface
+	audiences []string
+
+	mu    sync.Mutex
+	valid map[[sha256.Size]byte]time.Time // token hash -> expiry
+}
+
+// creates a TokenReview authenticator; audiences may be empty.
+func NewTokenReviewer(client kubernetes.Interface, audiences []string) *TokenReviewer {
+	return &TokenReviewer{client: client, audiences: audiences, valid: make(map[[sha256.Size]byte]time.Time)}
+}
+
+// Authenticate submits a TokenReview unless the token was recently accepted.
+func (t *TokenReviewer) Authenticate(ctx context.Context, token string) (bool, error) {
+	sum := sha256.Sum256([]byte(token))
+	now := time.Now()
+	t.mu.Lock()
+	expiry, ok := t.valid[sum]
+	t.mu.Unlock()
+	if ok && now.Before(expiry) {
+		return true, nil
+	}
+
+	review, err := t.client.AuthenticationV1().TokenReviews().Create(ctx, &authnv1.TokenReview{
+		Spec: authnv1.TokenReviewSpec{Token: token, Audiences: t.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return false, nil
+	}
+
+	t.mu.Lock()
+	if len(t.valid) >= maxCachedReviews {
+		t.valid = make(map[[sha256.Size]byte]time.Time)
+	}
+	t.valid[sum] = now.Add(tokenReviewTTL)
+	t.mu.Unlock()
+	return true, nil
+}
+
+// AnyOf accepts a token if any of its authenticators does.
+type AnyOf []Authenticator
+
+// Authenticate tries each authenticator in order.
+func (a AnyOf) Authenticate(ctx context.Context, token string) (bool, error) {
+	var firstErr error
+	for _, auth := range a {
+		ok, err := auth.Authenticate(ctx, token)
+		if ok {
+			return true, nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return false, firstErr
+}
+
+// bearerToken extracts the token of an "Authorization: Bearer <token>" value.
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticate reports whether the token is accepted; errors count as a
+// rejection.
+func (s *Server) authenticate(ctx context.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	ok, err := s.Auth.Authenticate(ctx, token)
+	if err != nil {
+		log.WithError(err).Warn("Error authenticating request")
+	}
+	return ok
+}
+
+// requireAuth rejects requests without an accepted bearer token with 401,
+// before any routing, so the response doesn't reveal which paths exist.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth != nil && !s.authenticate(r.Context(), bearerToken(r.Header.Get("Authorization"))) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="satellite"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GRPCServerOptions returns the interceptors enforcing Auth on gRPC calls.
+func (s *Server) GRPCServerOptions() []grpc.ServerOption {
+	check := func(ctx context.Context) error {
+		if s.Auth == nil {
+			return nil
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		var token string
+		if v := md.Get("authorization"); len(v) > 0 {
+			token = bearerToken(v[0])
+		}
+		if !s.authenticate(ctx, token) {
+			return status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// handleDiff serves the graph.Delta between ?from= and ?to= (default: the
+// current revision). Identical revisions yield an empty delta.
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	from, err := strconv.ParseUint(q.Get("from"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid from revision %q", q.Get("from")), http.StatusBadRequest)
+		return
+	}
+
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	to := snap.revision
+	if v := q.Get("to"); v != "" && v != "latest" {
+		if to, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid to revision %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromGraph, err := s.revisionGraph(snap, from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	toGraph, err := s.revisionGraph(snap, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, graph.Diff(fromGraph, toGraph))
+}
+
+// revisionGraph returns the graph of a revision: the served one and those in
+// the in-memory history from memory, older ones from their file in the
+// revision history.
+func (s *Server) revisionGraph(snap *snapshot, revision uint64) (graph.Graph, error) {
+	if revision == snap.revision {
+		return snap.graph, nil
+	}
+	if s.History != nil {
+		if g, ok := s.History.GetRevision(revision); ok {
+			return g, nil
+		}
+	}
+	if s.Revisions == nil {
+		return graph.Graph{}, fmt.Errorf("revision %d is not available", revision)
+	}
+	rec, ok := s.Revisions.Get(revision)
+	if !ok || rec.File == "" {
+		return graph.Graph{}, fmt.Errorf("revision %d is not available", revision)
+	}
+	data, err := os.ReadFile(rec.File)
+	if err != nil {
+		return graph.Graph{}, fmt.Errorf("revision %d is no longer on disk", revision)
+	}
+	var g graph.Graph
+	if err := json.Unmarshal(data, &g); err != nil {
+		return graph.Graph{}, fmt.Errorf("revision %d could not be read: %w", revision, err)
+	}
+	return g, nil
+}
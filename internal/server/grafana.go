@@ -0,0 +1,261 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// The Grafana Node Graph API data source reads a graph from
+// <url>/api/graph/fields and <url>/api/graph/data?<query>, and checks
+// <url>/api/health; satellite serves it under /grafana.
+const grafanaPrefix = "/grafana/api"
+
+// GrafanaField describes one field of the nodes or edges frame.
+type GrafanaField struct {
+	Name        string `json:"field_name"`
+	Type        string `json:"type"`
+	Color       string `json:"color,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// GrafanaFields is the /grafana/api/graph/fields response body.
+type GrafanaFields struct {
+	Edges []GrafanaField `json:"edges_fields"`
+	Nodes []GrafanaField `json:"nodes_fields"`
+}
+
+// GrafanaNode is one row of the nodes frame. The arc fields sum to 1 and
+// color the node's circle by health.
+type GrafanaNode struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	SubTitle      string  `json:"subTitle"`
+	MainStat      string  `json:"mainStat"`
+	SecondaryStat string  `json:"secondaryStat"`
+	ArcHealthy    float64 `json:"arc__healthy"`
+	ArcDegraded   float64 `json:"arc__degraded"`
+	ArcFailed     float64 `json:"arc__failed"`
+	ArcUnknown    float64 `json:"arc__unknown"`
+	Namespace     string  `json:"detail__namespace"`
+}
+
+// GrafanaEdge is one row of the edges frame.
+type GrafanaEdge struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	MainStat string `json:"mainStat"`
+}
+
+// GrafanaData is the /grafana/api/graph/data response body.
+type GrafanaData struct {
+	Nodes []GrafanaNode `json:"nodes"`
+	Edges []GrafanaEdge `json:"edges"`
+}
+
+// grafanaFields lists the fields of GrafanaNode and GrafanaEdge.
+var grafanaFields = GrafanaFields{
+	Edges: []GrafanaField{
+		{Name: "id", Type: "string"},
+		{Name: "source", Type: "string"},
+		{Name: "target", Type: "string"},
+		{Name: "mainStat", Type: "string", DisplayName: "Relationship"},
+	},
+	Nodes: []GrafanaField{
+		{Name: "id", Type: "string"},
+		{Name: "title", Type: "string"},
+		{Name: "subTitle", Type: "string"},
+		{Name: "mainStat", Type: "string"},
+		{Name: "secondaryStat", Type: "string"},
+		{Name: "arc__healthy", Type: "number", Color: "green", DisplayName: "Healthy"},
+		{Name: "arc__degraded", Type: "number", Color: "yellow", DisplayName: "Degraded"},
+		{Name: "arc__failed", Type: "number", Color: "red", DisplayName: "Failed"},
+		{Name: "arc__unknown", Type: "number", Color: "gray", DisplayName: "No status"},
+		{Name: "detail__namespace", Type: "string", DisplayName: "Namespace"},
+	},
+}
+
+// handleGrafana serves the Grafana Node Graph API endpoints.
+func (s *Server) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, grafanaPrefix) {
+	case "/health":
+		writeJSON(w, r, map[string]string{"status": "ok"})
+	case "/graph/fields":
+		writeJSON(w, r, grafanaFields)
+	case "/graph/data":
+		s.handleGrafanaData(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleGrafanaData serves the current graph as nodes and edges frames,
+// filtered like /graph. The panel's query string is passed through, so
+// kind= and namespace= are accepted too, with Grafana's {a,b} formatting of
+// multi-value variables.
+func (s *Server) handleGrafanaData(w http.ResponseWriter, r *http.Request) {
+	gq, err := parseGraphQuery(grafanaQuery(r.URL.Query()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	g := snap.graph
+	past, ok, err := s.pastGraph(gq, snap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if ok {
+		g = past
+	}
+	if !gq.unfiltered() {
+		g = gq.apply(g)
+	}
+	writeJSON(w, r, grafanaData(g))
+}
+
+// grafanaQuery folds kind= and namespace= into kinds= and namespaces=,
+// unwrapping {a,b} lists.
+func grafanaQuery(q url.Values) url.Values {
+	out := make(url.Values, len(q))
+	for key, values := range q {
+		switch key {
+		case "kind":
+			key = "kinds"
+		case "namespace":
+			key = "namespaces"
+		}
+		for _, v := range values {
+			if key == "kinds" || key == "namespaces" {
+				v = strings.TrimSuffix(strings.TrimPrefix(v, "{"), "}")
+			}
+			out[key] = append(out[key], v)
+		}
+	}
+	for _, key := range []string{"kinds", "namespaces"} {
+		if len(out[key]) > 1 {
+			out.Set(key, strings.Join(out[key], ","))
+		}
+	}
+	return out
+}
+
+// grafanaData projects g into the frames, dropping relationships to nodes
+// not in g, which the panel can't draw.
+func grafanaData(g graph.Graph) GrafanaData {
+	data := GrafanaData{
+		Nodes: make([]GrafanaNode, 0, len(g.Nodes)),
+		Edges: make([]GrafanaEdge, 0, len(g.Relationships)),
+	}
+	ids := make(map[graph.GraphEntityKey]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		node := grafanaNode(n)
+		ids[n.Key] = node.ID
+		data.Nodes = append(data.Nodes, node)
+	}
+	for i, r := range g.Relationships {
+		source, ok := ids[r.Source]
+		if !ok {
+			continue
+		}
+		target, ok := ids[r.Target]
+		if !ok {
+			continue
+		}
+		data.Edges = append(data.Edges, GrafanaEdge{ID: strconv.Itoa(i), Source: source, Target: target, MainStat: r.RelationshipType})
+	}
+	return data
+}
+
+// grafanaID returns a node id that is stable across builds.
+func grafanaID(key graph.GraphEntityKey) string {
+	id := key.Kind + "/" + key.Namespace + "/" + key.Name
+	if key.APIGroup != "" {
+		id = key.APIGroup + "/" + id
+	}
+	if key.Cluster != "" {
+		id = key.Cluster + ":" + id
+	}
+	return id
+}
+
+// grafanaNode derives the stats of n from its properties: the phase of a
+// pod, ready replicas of a workload, the outcome of a job, and recent
+// warnings or restarts.
+func grafanaNode(n graph.GraphNode) GrafanaNode {
+	props := n.Properties
+	node := GrafanaNode{
+		ID:        grafanaID(n.Key),
+		Title:     n.Key.Name,
+		SubTitle:  n.Key.Kind,
+		Namespace: n.Key.Namespace,
+	}
+
+	switch n.Key.Kind {
+	case "Pod":
+		node.MainStat = props["status.phase"]
+		switch node.MainStat {
+		case "Running", "Succeeded":
+			node.ArcHealthy = 1
+		case "Pending":
+			node.ArcDegraded = 1
+		case "Failed":
+			node.ArcFailed = 1
+		default:
+			node.ArcUnknown = 1
+		}
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet":
+		desiredProp, readyProp := "spec.replicas", "status.readyReplicas"
+		if n.Key.Kind == "DaemonSet" {
+			desiredProp, readyProp = "status.desiredNumberScheduled", "status.numberReady"
+		}
+		desired, err := strconv.Atoi(props[desiredProp])
+		if err != nil {
+			node.ArcUnknown = 1
+			break
+		}
+		ready, _ := strconv.Atoi(props[readyProp])
+		node.MainStat = fmt.Sprintf("%d/%d ready", ready, desired)
+		if desired == 0 {
+			node.ArcUnknown = 1
+			break
+		}
+		node.ArcHealthy = float64(min(ready, desired)) / float64(desired)
+		node.ArcFailed = 1 - node.ArcHealthy
+	case "Job":
+		switch props["status.condition"] {
+		case "Complete":
+			node.MainStat, node.ArcHealthy = "Complete", 1
+		case "Failed":
+			node.MainStat, node.ArcFailed = "Failed", 1
+		default:
+			node.MainStat, node.ArcDegraded = "Running", 1
+		}
+	default:
+		node.ArcUnknown = 1
+	}
+
+	var secondary []string
+	if v := props[graph.WarningCountProperty]; v != "" && v != "0" {
+		secondary = append(secondary, v+" warnings")
+	}
+	if v := props["restartCount"]; v != "" && v != "0" {
+		secondary = append(secondary, v+" restarts")
+	}
+	node.SecondaryStat = strings.Join(secondary, ", ")
+	return node
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+
+	satellitev1 "github.com/tthuwng/satellite/api/satellite/v1"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/shard"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcService implements satellite.v1.GraphService on top of a Server.
+type grpcService struct {
+	satellitev1.UnimplementedGraphServiceServer
+	s *Server
+}
+
+// RegisterGRPC registers the GraphService (and server reflection, for
+// grpcurl) on gs.
+func (s *Server) RegisterGRPC(gs *grpc.Server) {
+	satellitev1.RegisterGraphServiceServer(gs, &grpcService{s: s})
+	reflection.Register(gs)
+}
+
+func (g *grpcService) GetGraph(ctx context.Context, req *satellitev1.GetGraphRequest) (*satellitev1.Graph, error) {
+	current, ok := g.s.Current()
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "graph not built yet")
+	}
+	return toProtoGraph(applyFilter(current, req.GetFilter())), nil
+}
+
+func (g *grpcService) WatchGraph(req *satellitev1.WatchGraphRequest, stream satellitev1.GraphService_WatchGraphServer) error {
+	snap, sub, ok := g.s.subscribe()
+	if !ok {
+		return status.Error(codes.Unavailable, serverShutdown)
+	}
+	defer g.s.unsubscribe(sub)
+
+	filter := req.GetFilter()
+	// last is the (filtered) graph the watcher has; nil until the first snapshot
+	var last *graph.Graph
+	send := func(next graph.Graph, delta *graph.Delta) error {
+		filtered := applyFilter(next, filter)
+		var resp *satellitev1.WatchGraphResponse
+		switch {
+		case last == nil:
+			resp = &satellitev1.WatchGraphResponse{Event: &satellitev1.WatchGraphResponse_Snapshot{Snapshot: toProtoGraph(filtered)}}
+		case delta != nil && isUnfiltered(filter) && delta.FromRevision == last.GraphRevision:
+			// the published delta already describes exactly this step
+			resp = &satellitev1.WatchGraphResponse{Event: &satellitev1.WatchGraphResponse_Delta{Delta: toProtoDelta(*delta)}}
+		default:
+			d := graph.Diff(*last, filtered)
+			resp = &satellitev1.WatchGraphResponse{Event: &satellitev1.WatchGraphResponse_Delta{Delta: toProtoDelta(d)}}
+		}
+		last = &filtered
+		return stream.Send(resp)
+	}
+
+	if snap != nil {
+		if err := send(snap.graph, nil); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case ev := <-sub.ch:
+			if err := send(ev.snap.graph, ev.delta); err != nil {
+				return err
+			}
+		case <-sub.done:
+			if sub.reason == slowConsumer {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind; reconnect for a fresh snapshot")
+			}
+			return status.Error(codes.Unavailable, sub.reason)
+		case <-stream.Context().Done():
+			log.Debug("gRPC watcher disconnected")
+			return nil
+		}
+	}
+}
+
+// isUnfiltered reports whether f selects the whole graph.
+func isUnfiltered(f *satellitev1.GraphFilter) bool {
+	return f == nil || (len(f.Kinds) == 0 && len(f.Namespaces) == 0)
+}
+
+// applyFilter returns the subgraph selected by f, or g itself without one.
+func applyFilter(g graph.Graph, f *satellitev1.GraphFilter) graph.Graph {
+	if isUnfiltered(f) {
+		return g
+	}
+	return graph.Subgraph(g, graph.Filter{
+		Kinds:           f.Kinds,
+		Namespaces:      f.Namespaces,
+		IncludeExternal: f.IncludeExternal,
+	})
+}
+
+// --- graph <-> protobuf conversion ---
+
+func toProtoKey(k graph.GraphEntityKey) *satellitev1.EntityKey {
+	return &satellitev1.EntityKey{Kind: k.Kind, ApiGroup: k.APIGroup, Namespace: k.Namespace, Name: k.Name, Cluster: k.Cluster}
+}
+
+func toProtoNode(n graph.GraphNode) *satellitev1.Node {
+	return &satellitev1.Node{Key: toProtoKey(n.Key), Properties: n.Properties, Revision: n.Revision}
+}
+
+func toProtoRelationship(r graph.GraphRelationship) *satellitev1.Relationship {
+	return &satellitev1.Relationship{
+		Source:     toProtoKey(r.Source),
+		Target:     toProtoKey(r.Target),
+		Type:       r.RelationshipType,
+		Properties: r.Properties,
+		Revision:   r.Revision,
+	}
+}
+
+func toProtoNodes(nodes []graph.GraphNode) []*satellitev1.Node {
+	out := make([]*satellitev1.Node, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, toProtoNode(n))
+	}
+	return out
+}
+
+func toProtoRelationships(rels []graph.GraphRelationship) []*satellitev1.Relationship {
+	out := make([]*satellitev1.Relationship, 0, len(rels))
+	for _, r := range rels {
+		out = append(out, toProtoRelationship(r))
+	}
+	return out
+}
+
+func toProtoGraph(g graph.Graph) *satellitev1.Graph {
+	out := &satellitev1.Graph{
+		Nodes:         toProtoNodes(g.Nodes),
+		Relationships: toProtoRelationships(g.Relationships),
+		Revision:      g.GraphRevision,
+	}
+	if m := g.Metadata; m != nil {
+		out.Metadata = &satellitev1.GraphMetadata{ClusterName: m.ClusterName, DisabledKinds: m.DisabledKinds, StaleKinds: m.StaleKinds, UnsyncedKinds: m.UnsyncedKinds}
+		if m.Shard != nil {
+			out.Metadata.Shard = toProtoShard(*m.Shard)
+		}
+		if !m.BuiltAt.IsZero() {
+			out.Metadata.BuiltAt = timestamppb.New(m.BuiltAt)
+		}
+	}
+	return out
+}
+
+func toProtoShard(s shard.Shard) *satellitev1.Shard {
+	return &satellitev1.Shard{Index: int32(s.Index), Count: int32(s.Count)}
+}
+
+func toProtoDelta(d graph.Delta) *satellitev1.Delta {
+	updatedNodes := make([]*satellitev1.Node, 0, len(d.UpdatedNodes))
+	for _, u := range d.UpdatedNodes {
+		updatedNodes = append(updatedNodes, toProtoNode(u.GraphNode))
+	}
+	updatedRels := make([]*satellitev1.Relationship, 0, len(d.UpdatedRelationships))
+	for _, u := range d.UpdatedRelationships {
+		updatedRels = append(updatedRels, toProtoRelationship(u.GraphRelationship))
+	}
+	removed := make([]*satellitev1.EntityKey, 0, len(d.RemovedNodes))
+	for _, k := range d.RemovedNodes {
+		removed = append(removed, toProtoKey(k))
+	}
+	return &satellitev1.Delta{
+		FromRevision:         d.FromRevision,
+		ToRevision:           d.ToRevision,
+		AddedNodes:           toProtoNodes(d.AddedNodes),
+		UpdatedNodes:         updatedNodes,
+		RemovedNodes:         removed,
+		AddedRelationships:   toProtoRelationships(d.AddedRelationships),
+		UpdatedRelationships: updatedRels,
+		RemovedRelationships: toProtoRelationships(d.RemovedRelationships),
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/types"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ObjectSource looks up the cached Kubernetes objects behind graph nodes.
+type ObjectSource interface {
+	// Object returns the object with key in the given cluster ("" in
+	// single-cluster mode).
+	Object(cluster string, key types.EntityKey) (runtime.Object, bool)
+	// ObjectByUID returns the object with the given metadata.uid.
+	ObjectByUID(uid string) (runtime.Object, bool)
+}
+
+// handleObject serves the full cached object identified by
+// ?kind=&namespace=&name= (and ?cluster=), or by ?uid=, with secrets redacted.
+func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Objects == nil {
+		http.Error(w, "object lookups are not available", http.StatusNotImplemented)
+		return
+	}
+
+	q := r.URL.Query()
+	var (
+		obj  runtime.Object
+		ok   bool
+		what string
+	)
+	if uid := q.Get("uid"); uid != "" {
+		obj, ok = s.Objects.ObjectByUID(uid)
+		what = "uid " + uid
+	} else {
+		key, err := entityKeyFromQuery(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		obj, ok = s.Objects.Object(key.Cluster, types.EntityKey{Kind: key.Kind, APIGroup: key.APIGroup, Namespace: key.Namespace, Name: key.Name})
+		what = fmt.Sprintf("%s %s", key.Kind, qualifiedName(key))
+	}
+	if !ok {
+		http.Error(w, what+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, k8s.Redact(obj))
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// /graph?relationships= modes.
+const (
+	RelationshipsAll  = "all"  // nodes and relationships (default)
+	RelationshipsNone = "none" // nodes only
+	RelationshipsOnly = "only" // relationships only
+)
+
+// graphQuery is a parsed /graph filter.
+type graphQuery struct {
+	filter        graph.Filter
+	relationships string
+	// revision or at, if set, select a past graph from the history.
+	revision uint64
+	at       time.Time
+}
+
+// parseGraphQuery reads kinds= and namespaces= (comma-separated),
+// labelSelector=, external=, relationships= and one of revision= and at=
+// (RFC 3339).
+func parseGraphQuery(q url.Values) (graphQuery, error) {
+	gq := graphQuery{
+		filter: graph.Filter{
+			Kinds:      splitList(q.Get("kinds")),
+			Namespaces: splitList(q.Get("namespaces")),
+		},
+		relationships: RelationshipsAll,
+	}
+	if v := q.Get("labelSelector"); v != "" {
+		sel, err := labels.Parse(v)
+		if err != nil {
+			return gq, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+		gq.filter.LabelSelector = sel
+	}
+	if v := q.Get("external"); v != "" {
+		external, err := strconv.ParseBool(v)
+		if err != nil {
+			return gq, fmt.Errorf("invalid external %q: %w", v, err)
+		}
+		gq.filter.IncludeExternal = external
+	}
+	if v := q.Get("revision"); v != "" {
+		revision, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || revision == 0 {
+			return gq, fmt.Errorf("invalid revision %q", v)
+		}
+		gq.revision = revision
+	}
+	if v := q.Get("at"); v != "" {
+		if gq.revision != 0 {
+			return gq, fmt.Errorf("only one of revision and at may be set")
+		}
+		at, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return gq, fmt.Errorf("invalid at %q: %w", v, err)
+		}
+		gq.at = at
+	}
+	switch v := q.Get("relationships"); v {
+	case "":
+	case RelationshipsAll, RelationshipsNone, RelationshipsOnly:
+		gq.relationships = v
+	default:
+		return gq, fmt.Errorf("invalid relationships %q, expected all, none or only", v)
+	}
+	return gq, nil
+}
+
+// unfiltered reports whether the query returns the graph unchanged.
+func (gq graphQuery) unfiltered() bool {
+	f := gq.filter
+	return len(f.Kinds) == 0 && len(f.Namespaces) == 0 && f.LabelSelector == nil &&
+		gq.relationships == RelationshipsAll
+}
+
+// apply returns the subgraph selected by the query.
+func (gq graphQuery) apply(g graph.Graph) graph.Graph {
+	sub := graph.Subgraph(g, gq.filter)
+	switch gq.relationships {
+	case RelationshipsNone:
+		sub.Relationships = make([]graph.GraphRelationship, 0)
+	case RelationshipsOnly:
+		sub.Nodes = make([]graph.GraphNode, 0)
+	}
+	return sub
+}
+
+// splitList splits a comma-separated parameter, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
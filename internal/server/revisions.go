@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RevisionsResponse is the /revisions response body.
+type RevisionsResponse struct {
+	Revisions []emitter.RevisionRecord `json:"revisions"`
+}
+
+// handleRevisions lists the recently emitted revisions, newest first.
+func (s *Server) handleRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Revisions == nil {
+		http.Error(w, "revision history is not available", http.StatusNotImplemented)
+		return
+	}
+	writeJSON(w, r, RevisionsResponse{Revisions: s.Revisions.List()})
+}
+
+// handleRevision streams the graph file of /revisions/<n> back from disk.
+func (s *Server) handleRevision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Revisions == nil {
+		http.Error(w, "revision history is not available", http.StatusNotImplemented)
+		return
+	}
+	raw := strings.TrimPrefix(r.URL.Path, "/revisions/")
+	revision, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid revision %q", raw), http.StatusBadRequest)
+		return
+	}
+
+	rec, ok := s.Revisions.Get(revision)
+	if !ok {
+		http.Error(w, fmt.Sprintf("revision %d is not in the history", revision), http.StatusNotFound)
+		return
+	}
+	if rec.File == "" {
+		http.Error(w, fmt.Sprintf("revision %d was not written to a file", revision), http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(rec.File)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("revision %d has been rotated away", revision), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("file", rec.File).Error("Error opening revision file")
+		http.Error(w, "failed to open revision file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to stat revision file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Graph-Revision", strconv.FormatUint(revision, 10))
+	http.ServeContent(w, r, filepath.Base(rec.File), info.ModTime(), f)
+}
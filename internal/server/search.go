@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 200
+)
+
+// headlineProperties are copied into search results when a node has them.
+var headlineProperties = []string{"status.phase", "spec.type", "spec.replicas", "status.readyReplicas", "spec.nodeName"}
+
+// SearchResult is one /search match.
+type SearchResult struct {
+	Key        graph.GraphEntityKey `json:"key"`
+	Match      string               `json:"match"`
+	Properties map[string]string    `json:"properties,omitempty"`
+}
+
+// SearchResponse is the /search response body.
+type SearchResponse struct {
+	Revision  uint64         `json:"revision"`
+	Results   []SearchResult `json:"results"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+// handleSearch serves /search?q=<substring>&kind=&limit=, matching node names
+// and label values case-insensitively.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	query := q.Get("q")
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxSearchLimit)
+	}
+
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	hits, truncated := snap.index.Search(query, q.Get("kind"), limit)
+	resp := SearchResponse{
+		Revision:  snap.revision,
+		Results:   make([]SearchResult, 0, len(hits)),
+		Truncated: truncated,
+	}
+	for _, hit := range hits {
+		result := SearchResult{Key: hit.Node.Key, Match: hit.Match}
+		for _, name := range headlineProperties {
+			if v, ok := hit.Node.Properties[name]; ok && v != "" {
+				if result.Properties == nil {
+					result.Properties = make(map[string]string)
+				}
+				result.Properties[name] = v
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,459 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxNeighborDepth is the default cap on /graph/neighbors?depth=.
+	DefaultMaxNeighborDepth = 5
+	// DefaultMaxPathDepth is the default cap on /graph/path?maxDepth=.
+	DefaultMaxPathDepth = 8
+	// maxPaths bounds the equally short paths returned by /graph/path.
+	maxPaths = 16
+)
+
+// Server serves the most recently built graph over HTTP.
+type Server struct {
+	// MaxNeighborDepth caps the depth accepted by /graph/neighbors.
+	MaxNeighborDepth int
+	// MaxPathDepth caps the number of hops searched by /graph/path.
+	MaxPathDepth int
+	// SSEHeartbeat is the interval between /graph/events heartbeat comments.
+	SSEHeartbeat time.Duration
+	// Objects, if set, backs /object with the cached Kubernetes objects.
+	Objects ObjectSource
+	// Revisions, if set, backs /revisions with the emitted revision history.
+	Revisions *emitter.RevisionLog
+	// History, if set, serves past graphs from memory: /graph?revision= and
+	// ?at=, and /diff before falling back to Revisions.
+	History *graph.GraphHistory
+	// Auth, if set, is required on every endpoint, HTTP and gRPC.
+	Auth Authenticator
+
+	current atomic.Pointer[snapshot]
+
+	mu     sync.Mutex // serializes publishing with (un)subscribing
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+// snapshot is an immutable, pre-serialized graph. Handlers load the pointer
+// once per request, so a concurrent Update never mixes two graphs.
+type snapshot struct {
+	document
+	graph    graph.Graph
+	index    *graph.Index
+	revision uint64
+}
+
+// document is a graph serialized for HTTP responses.
+type document struct {
+	json    []byte
+	gzipped []byte // nil unless rendered with compression
+	hash    string
+	etag    string
+	gzipTag string // ETag of the gzipped representation
+}
+
+// render serializes g and, if compress is set, gzips it.
+func render(g graph.Graph, compress bool) (document, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return document{}, fmt.Errorf("failed to marshal graph to JSON: %w", err)
+	}
+	hash := graph.ContentHash(g)
+	doc := document{json: data, hash: hash, etag: `"` + hash + `"`}
+	if !compress {
+		return doc, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return document{}, fmt.Errorf("failed to gzip graph: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return document{}, fmt.Errorf("failed to gzip graph: %w", err)
+	}
+	doc.gzipped = buf.Bytes()
+	doc.gzipTag = `"` + hash + `-gzip"`
+	return doc, nil
+}
+
+// creates a server with no graph yet; /graph returns 503 until Update.
+func New() *Server {
+	return &Server{
+		MaxNeighborDepth: DefaultMaxNeighborDepth,
+		MaxPathDepth:     DefaultMaxPathDepth,
+		SSEHeartbeat:     DefaultSSEHeartbeat,
+	}
+}
+
+// Update replaces the served graph. Serialization and compression happen
+// here, once per build, rather than per request.
+func (s *Server) Update(g graph.Graph) error {
+	doc, err := render(g, true)
+	if err != nil {
+		return err
+	}
+	next := &snapshot{
+		document: doc,
+		graph:    g,
+		index:    graph.NewIndex(g),
+		revision: g.GraphRevision,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.current.Swap(next)
+	s.publish(prev, next)
+	return nil
+}
+
+// Current returns the served graph, or false before the first Update.
+func (s *Server) Current() (graph.Graph, bool) {
+	snap := s.current.Load()
+	if snap == nil {
+		return graph.Graph{}, false
+	}
+	return snap.graph, true
+}
+
+// Register mounts the graph endpoints on mux, behind Auth if set.
+func (s *Server) Register(mux *http.ServeMux) {
+	routes := http.NewServeMux()
+	routes.HandleFunc("/graph", s.handleGraph)
+	routes.HandleFunc("/graph/neighbors", s.handleNeighbors)
+	routes.HandleFunc("/graph/path", s.handlePath)
+	routes.HandleFunc("/graph/stream", s.handleStream)
+	routes.HandleFunc("/graph/events", s.handleEvents)
+	routes.HandleFunc("/object", s.handleObject)
+	routes.HandleFunc("/revisions", s.handleRevisions)
+	routes.HandleFunc("/revisions/", s.handleRevision)
+	routes.HandleFunc("/diff", s.handleDiff)
+	routes.HandleFunc("/search", s.handleSearch)
+	routes.HandleFunc(grafanaPrefix+"/", s.handleGrafana)
+
+	// whole subtrees go through auth before routing
+	protected := s.requireAuth(routes)
+	for _, prefix := range []string{"/graph", "/graph/", "/object", "/object/", "/revisions", "/revisions/", "/diff", "/diff/", "/search", "/search/", "/grafana/"} {
+		mux.Handle(prefix, protected)
+	}
+}
+
+// load returns the current snapshot, answering 503 itself if there is none yet.
+func (s *Server) load(w http.ResponseWriter) *snapshot {
+	snap := s.current.Load()
+	if snap == nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "graph not built yet", http.StatusServiceUnavailable)
+	}
+	return snap
+}
+
+// handleGraph serves the current graph, or with ?revision= or ?at= a past
+// one from the in-memory history, optionally filtered by
+// ?kinds=&namespaces=&labelSelector=&external=&relationships=.
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	gq, err := parseGraphQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	doc, g, revision := snap.document, snap.graph, snap.revision
+	past, ok, err := s.pastGraph(gq, snap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if ok {
+		g, revision = past, past.GraphRevision
+	}
+	if ok || !gq.unfiltered() {
+		if !gq.unfiltered() {
+			g = gq.apply(g)
+		}
+		doc, err = render(g, acceptsGzip(r.Header.Get("Accept-Encoding")))
+		if err != nil {
+			log.WithError(err).Error("Error rendering filtered graph")
+			http.Error(w, "failed to render graph", http.StatusInternalServerError)
+			return
+		}
+	}
+	serveDocument(w, r, doc, revision)
+}
+
+// pastGraph returns the graph gq selects from the history, if it selects
+// one other than the served one.
+func (s *Server) pastGraph(gq graphQuery, snap *snapshot) (graph.Graph, bool, error) {
+	switch {
+	case gq.revision != 0 && gq.revision != snap.revision:
+		if s.History != nil {
+			if g, ok := s.History.GetRevision(gq.revision); ok {
+				return g, true, nil
+			}
+		}
+		return graph.Graph{}, false, fmt.Errorf("revision %d is not in the in-memory history", gq.revision)
+	case !gq.at.IsZero():
+		if s.History != nil {
+			if g, ok := s.History.GetAt(gq.at); ok {
+				return g, g.GraphRevision != snap.revision, nil
+			}
+		}
+		return graph.Graph{}, false, fmt.Errorf("no graph at %s in the in-memory history", gq.at.Format(time.RFC3339))
+	}
+	return graph.Graph{}, false, nil
+}
+
+// serveDocument writes doc honoring If-None-Match and Accept-Encoding.
+func serveDocument(w http.ResponseWriter, r *http.Request, doc document, revision uint64) {
+	// each encoding is a distinct representation with its own ETag
+	body, etag, gzipped := doc.json, doc.etag, false
+	if doc.gzipped != nil && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		body, etag, gzipped = doc.gzipped, doc.gzipTag, true
+	}
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept-Encoding")
+	h.Set("X-Graph-Revision", strconv.FormatUint(revision, 10))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", "application/json")
+	if gzipped {
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		log.WithError(err).Debug("Error writing /graph response")
+	}
+}
+
+// handleNeighbors serves the subgraph within ?depth= hops (default 1) of the
+// entity identified by ?kind=&namespace=&name= (and ?cluster= when merged).
+func (s *Server) handleNeighbors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	key, err := entityKeyFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	depth := 1
+	if v := q.Get("depth"); v != "" {
+		if depth, err = strconv.Atoi(v); err != nil || depth < 0 {
+			http.Error(w, fmt.Sprintf("invalid depth %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if depth > s.MaxNeighborDepth {
+		http.Error(w, fmt.Sprintf("depth %d exceeds the maximum of %d", depth, s.MaxNeighborDepth), http.StatusBadRequest)
+		return
+	}
+
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	sub, ok := snap.index.Neighborhood(key, depth)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s %s not found", key.Kind, qualifiedName(key)), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, sub)
+}
+
+// PathResponse is the /graph/path response body.
+type PathResponse struct {
+	Paths []graph.Path `json:"paths"`
+}
+
+// handlePath serves the shortest paths between ?from= and ?to=, both given as
+// kind/namespace/name (kind/name for cluster-scoped objects), searching at
+// most ?maxDepth= hops.
+func (s *Server) handlePath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	from, err := parseEntityRef(q.Get("from"), q.Get("cluster"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseEntityRef(q.Get("to"), q.Get("cluster"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxDepth := s.MaxPathDepth
+	if v := q.Get("maxDepth"); v != "" {
+		if maxDepth, err = strconv.Atoi(v); err != nil || maxDepth < 0 {
+			http.Error(w, fmt.Sprintf("invalid maxDepth %q", v), http.StatusBadRequest)
+			return
+		}
+		if maxDepth > s.MaxPathDepth {
+			http.Error(w, fmt.Sprintf("maxDepth %d exceeds the maximum of %d", maxDepth, s.MaxPathDepth), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snap := s.load(w)
+	if snap == nil {
+		return
+	}
+	paths, ok := snap.index.ShortestPaths(from, to, maxDepth, maxPaths)
+	switch {
+	case !ok:
+		http.Error(w, "from or to not found in the graph", http.StatusNotFound)
+	case len(paths) == 0:
+		http.Error(w, fmt.Sprintf("no path within %d hops", maxDepth), http.StatusNotFound)
+	default:
+		writeJSON(w, r, PathResponse{Paths: paths})
+	}
+}
+
+// parseEntityRef parses kind/namespace/name or kind/name. The kind may be
+// qualified with its API group as kind.group, e.g. Deployment.apps.
+func parseEntityRef(ref, cluster string) (graph.GraphEntityKey, error) {
+	parts := strings.Split(ref, "/")
+	for _, p := range parts {
+		if p == "" {
+			return graph.GraphEntityKey{}, fmt.Errorf("expected kind/namespace/name or kind/name, got %q", ref)
+		}
+	}
+	kind, group := splitKind(parts[0], "")
+	switch len(parts) {
+	case 2:
+		return graph.GraphEntityKey{Kind: kind, APIGroup: group, Name: parts[1], Cluster: cluster}, nil
+	case 3:
+		return graph.GraphEntityKey{Kind: kind, APIGroup: group, Namespace: parts[1], Name: parts[2], Cluster: cluster}, nil
+	default:
+		return graph.GraphEntityKey{}, fmt.Errorf("expected kind/namespace/name or kind/name, got %q", ref)
+	}
+}
+
+// entityKeyFromQuery reads kind, group, namespace, name and cluster query
+// parameters.
+func entityKeyFromQuery(q url.Values) (graph.GraphEntityKey, error) {
+	kind, group := splitKind(q.Get("kind"), q.Get("group"))
+	key := graph.GraphEntityKey{
+		Kind:      kind,
+		APIGroup:  group,
+		Namespace: q.Get("namespace"),
+		Name:      q.Get("name"),
+		Cluster:   q.Get("cluster"),
+	}
+	if key.Kind == "" || key.Name == "" {
+		return key, fmt.Errorf("kind and name are required")
+	}
+	return key, nil
+}
+
+// splitKind splits a kind qualified as kind.group. Without a group, neither
+// qualified nor given, it is the watched kind's, so clients can keep naming
+// built-in kinds without their group.
+func splitKind(kind, group string) (string, string) {
+	if k, g, ok := strings.Cut(kind, "."); ok {
+		return k, g
+	}
+	if group == "" {
+		group = k8s.KindGroup(kind)
+	}
+	return kind, group
+}
+
+// qualifiedName renders namespace/name, or just name for cluster-scoped keys.
+func qualifiedName(key graph.GraphEntityKey) string {
+	if key.Namespace == "" {
+		return key.Name
+	}
+	return key.Namespace + "/" + key.Name
+}
+
+// writeJSON writes v as a JSON response, gzipped if the client accepts it.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Vary", "Accept-Encoding")
+	out := io.Writer(w)
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		h.Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		out = zw
+	}
+	if err := json.NewEncoder(out).Encode(v); err != nil {
+		log.WithError(err).Debug("Error writing JSON response")
+	}
+}
+
+// etagMatches implements the If-None-Match comparison (weak, per RFC 9110).
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultSSEHeartbeat is the default interval between /graph/events heartbeats.
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// /graph/events event types.
+const (
+	// EventSnapshot tells the client to (re-)fetch /graph.
+	EventSnapshot = "snapshot"
+	// EventDelta carries the graph.Delta of a build that changed the graph.
+	EventDelta = "delta"
+	// EventRevision announces a build whose content is unchanged.
+	EventRevision = "revision"
+)
+
+// RevisionInfo is the data of snapshot and revision events.
+type RevisionInfo struct {
+	Revision uint64 `json:"revision"`
+	Hash     string `json:"hash"`
+}
+
+// sseEvent renders one event; the id is the graph revision so EventSource
+// reconnects with Last-Event-ID set to the last revision seen.
+func sseEvent(event string, revision uint64, data interface{}) ([]byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", revision, event, payload)), nil
+}
+
+func sseSnapshotEvent(snap *snapshot) ([]byte, error) {
+	return sseEvent(EventSnapshot, snap.revision, RevisionInfo{Revision: snap.revision, Hash: snap.hash})
+}
+
+// handleEvents serves build notifications as Server-Sent Events. A new client
+// gets a snapshot event; a reconnecting client whose Last-Event-ID is the
+// current revision resumes silently, any other gets a snapshot event so it
+// knows it missed revisions and must re-fetch.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	snap, sub, ok := s.subscribe()
+	if !ok {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(sub)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	if snap != nil && !upToDate(r.Header.Get("Last-Event-ID"), snap.revision) {
+		ev, err := sseSnapshotEvent(snap)
+		if err != nil {
+			log.WithError(err).Error("Error rendering snapshot event")
+			return
+		}
+		if _, err := w.Write(ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.WithError(err).Debug("SSE response cannot be flushed")
+		return
+	}
+
+	heartbeat := s.SSEHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultSSEHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		var chunk []byte
+		select {
+		case ev := <-sub.ch:
+			chunk = ev.sse
+		case <-ticker.C:
+			chunk = []byte(": heartbeat\n\n")
+		case <-sub.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// upToDate reports whether a Last-Event-ID names the given revision.
+func upToDate(lastEventID string, revision uint64) bool {
+	if lastEventID == "" {
+		return false
+	}
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	return err == nil && id == revision
+}
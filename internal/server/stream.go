@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// streamBuffer is the number of messages queued per client before it is
+	// considered a slow consumer and disconnected.
+	streamBuffer = 16
+	// pingInterval is how often clients are pinged; a client that hasn't
+	// answered within pongWait is dropped.
+	pingInterval = 30 * time.Second
+	pongWait     = 2 * pingInterval
+	// writeWait bounds a single frame write.
+	writeWait = 10 * time.Second
+)
+
+// Message types of /graph/stream frames.
+const (
+	MessageSnapshot = "snapshot"
+	MessageDelta    = "delta"
+)
+
+// Message is one /graph/stream frame: the full graph on connect (and on the
+// first build), then one graph.Delta per build.
+type Message struct {
+	Type     string          `json:"type"`
+	Revision uint64          `json:"revision"`
+	Graph    json.RawMessage `json:"graph,omitempty"`
+	Delta    *graph.Delta    `json:"delta,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 32 * 1024,
+}
+
+// snapshotFrame renders the full-graph message for snap.
+func snapshotFrame(snap *snapshot) ([]byte, error) {
+	return json.Marshal(Message{Type: MessageSnapshot, Revision: snap.revision, Graph: snap.json})
+}
+
+// handleStream upgrades to a WebSocket, sends the current graph and then
+// forwards every published build until the client leaves, falls behind or
+// the server closes.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		log.WithError(err).Debug("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	snap, sub, ok := s.subscribe()
+	if !ok {
+		closeStream(conn, websocket.CloseGoingAway, serverShutdown)
+		return
+	}
+	defer s.unsubscribe(sub)
+	logger := log.WithField("remote", r.RemoteAddr)
+	logger.Debug("Stream client connected")
+
+	// the read side only exists to process pongs and notice the client leaving
+	readDone := make(chan struct{})
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		defer close(readDone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if snap != nil {
+		frame, err := snapshotFrame(snap)
+		if err == nil {
+			err = writeFrame(conn, frame)
+		}
+		if err != nil {
+			logger.WithError(err).Debug("Error sending initial snapshot")
+			return
+		}
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-sub.ch:
+			if err := writeFrame(conn, ev.frame); err != nil {
+				logger.WithError(err).Debug("Error writing stream frame")
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-sub.done:
+			closeStream(conn, websocket.CloseGoingAway, sub.reason)
+			return
+		case <-readDone:
+			logger.Debug("Stream client disconnected")
+			return
+		}
+	}
+}
+
+func writeFrame(conn *websocket.Conn, frame []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// closeStream sends a close frame; the connection is closed by the caller.
+func closeStream(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// drop reasons, sent to WebSocket clients in the close frame.
+const (
+	slowConsumer   = "slow consumer"
+	serverShutdown = "server shutting down"
+)
+
+// streamEvent is one published build, pre-rendered once for all clients.
+type streamEvent struct {
+	snap  *snapshot
+	delta *graph.Delta // nil for the first build
+	frame []byte       // /graph/stream message
+	sse   []byte       // /graph/events event
+}
+
+// subscriber is one streaming client. The hub closes done (after setting
+// reason) when it drops the client.
+type subscriber struct {
+	ch     chan streamEvent
+	done   chan struct{}
+	reason string
+}
+
+// subscribe registers a client and returns the snapshot it should start
+// from. No build published after that snapshot is missed. The bool is false
+// once the server is closed.
+func (s *Server) subscribe() (*snapshot, *subscriber, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, nil, false
+	}
+	sub := &subscriber{ch: make(chan streamEvent, streamBuffer), done: make(chan struct{})}
+	if s.subs == nil {
+		s.subs = make(map[*subscriber]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	return s.current.Load(), sub, true
+}
+
+// unsubscribe removes a client that went away on its own.
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, sub)
+}
+
+// drop disconnects a client. Must be called with s.mu held.
+func (s *Server) drop(sub *subscriber, reason string) {
+	if _, ok := s.subs[sub]; !ok {
+		return
+	}
+	delete(s.subs, sub)
+	sub.reason = reason
+	close(sub.done)
+}
+
+// publish hands a new build to every client. Clients whose buffer is full are
+// dropped rather than allowed to hold back the build loop. Must be called
+// with s.mu held.
+func (s *Server) publish(prev, next *snapshot) {
+	if len(s.subs) == 0 {
+		return
+	}
+
+	ev := streamEvent{snap: next}
+	var err error
+	if prev == nil {
+		ev.frame, err = snapshotFrame(next)
+		if err == nil {
+			ev.sse, err = sseSnapshotEvent(next)
+		}
+	} else {
+		d := graph.Diff(prev.graph, next.graph)
+		ev.delta = &d
+		ev.frame, err = json.Marshal(Message{Type: MessageDelta, Revision: next.revision, Delta: &d})
+		if err == nil && d.Empty() {
+			ev.sse, err = sseEvent(EventRevision, next.revision, RevisionInfo{Revision: next.revision, Hash: next.hash})
+		} else if err == nil {
+			ev.sse, err = sseEvent(EventDelta, next.revision, d)
+		}
+	}
+	if err != nil {
+		log.WithError(err).Error("Error rendering stream message")
+		return
+	}
+
+	for sub := range s.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			log.Warn("Disconnecting slow stream client")
+			s.drop(sub, slowConsumer)
+		}
+	}
+}
+
+// Close disconnects every streaming client and refuses new ones. Hijacked
+// connections are not tracked by http.Server.Shutdown, so call this first.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for sub := range s.subs {
+		s.drop(sub, serverShutdown)
+	}
+}
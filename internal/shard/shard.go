@@ -0,0 +1,51 @@
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Shard selects the namespaces owned by one instance of a sharded deployment.
+//
+// A namespace belongs to shard FNV-1a-32(namespace) mod Count. The hash only
+// depends on the namespace name, so assignments are stable across restarts
+// and identical on every instance. Cluster-scoped objects (empty namespace)
+// belong to shard 0 only.
+type Shard struct {
+	Index int `json:"index"`
+	Count int `json:"count"`
+}
+
+// Enabled reports whether sharding is in effect.
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// Validate checks that Index is within [0, Count).
+func (s Shard) Validate() error {
+	if s.Count < 1 {
+		return fmt.Errorf("shard count must be at least 1, got %d", s.Count)
+	}
+	if s.Index < 0 || s.Index >= s.Count {
+		return fmt.Errorf("shard index must be in [0, %d), got %d", s.Count, s.Index)
+	}
+	return nil
+}
+
+// Owns reports whether objects in namespace are processed by this shard.
+func (s Shard) Owns(namespace string) bool {
+	if !s.Enabled() {
+		return true
+	}
+	if namespace == "" {
+		return s.Index == 0
+	}
+	return For(namespace, s.Count) == s.Index
+}
+
+// For returns the shard index a namespace maps to among count shards.
+func For(namespace string, count int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(count))
+}
@@ -0,0 +1,219 @@
+package timing
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultWindow is the number of recent samples percentiles are computed over.
+	DefaultWindow = 100
+	// DefaultSlowBuild is the build duration above which a warning is logged.
+	DefaultSlowBuild = 10 * time.Second
+)
+
+// Phase names recorded by BuildGraph and the emitters.
+const (
+	Build              = "build"
+	BuildSnapshot      = "build.snapshot"
+	BuildNodes         = "build.nodes"
+	BuildRelationships = "build.relationships"
+	BuildValidate      = "build.validate"
+	EmitMarshal        = "emit.marshal"
+	EmitWrite          = "emit.write"
+)
+
+// Default is the recorder BuildGraph and the emitters report to.
+var Default = NewRecorder(DefaultWindow, DefaultSlowBuild)
+
+// Summary describes the recent durations of one phase.
+type Summary struct {
+	Count int           `json:"count"` // samples in the window
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	Max   time.Duration `json:"max"`
+}
+
+// Recorder keeps the most recent durations of each phase and warns about
+// slow builds. The zero value is not usable; use NewRecorder.
+type Recorder struct {
+	// Logger receives slow-build warnings; nil means the standard logger.
+	Logger *log.Logger
+
+	mu        sync.Mutex
+	window    int
+	slowBuild time.Duration
+	samples   map[string]*ring
+}
+
+// creates a recorder keeping window samples per phase and warning about
+// builds slower than slowBuild (0 disables the warning).
+func NewRecorder(window int, slowBuild time.Duration) *Recorder {
+	r := &Recorder{samples: make(map[string]*ring)}
+	r.Configure(window, slowBuild)
+	return r
+}
+
+// Configure changes the window and slow-build threshold. Samples already
+// recorded beyond the new window are discarded.
+func (r *Recorder) Configure(window int, slowBuild time.Duration) {
+	if window < 1 {
+		window = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.window, r.slowBuild = window, slowBuild
+	for _, s := range r.samples {
+		s.resize(window)
+	}
+}
+
+// Observe records one duration of phase.
+func (r *Recorder) Observe(phase string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.samples[phase]
+	if !ok {
+		s = &ring{}
+		s.resize(r.window)
+		r.samples[phase] = s
+	}
+	s.add(d)
+}
+
+// Summaries returns the summary of every recorded phase.
+func (r *Recorder) Summaries() map[string]Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]Summary, len(r.samples))
+	for phase, s := range r.samples {
+		out[phase] = s.summary()
+	}
+	return out
+}
+
+// Breakdown collects the phase durations of a single build or emit.
+type Breakdown struct {
+	phases []string
+	times  []time.Duration
+}
+
+// Add records that phase took d.
+func (b *Breakdown) Add(phase string, d time.Duration) {
+	b.phases = append(b.phases, phase)
+	b.times = append(b.times, d)
+}
+
+// Since records that phase took the time since start and returns now, so
+// consecutive phases can be chained.
+func (b *Breakdown) Since(phase string, start time.Time) time.Time {
+	now := time.Now()
+	b.Add(phase, now.Sub(start))
+	return now
+}
+
+// Fields renders the breakdown as log fields.
+func (b *Breakdown) Fields() log.Fields {
+	fields := make(log.Fields, len(b.phases))
+	for i, phase := range b.phases {
+		fields[phase] = b.times[i]
+	}
+	return fields
+}
+
+// Record observes every phase of b and, if set, the total of the whole
+// operation under phase total.
+func (r *Recorder) Record(total string, d time.Duration, b *Breakdown) {
+	for i, phase := range b.phases {
+		r.Observe(phase, b.times[i])
+	}
+	if total != "" {
+		r.Observe(total, d)
+	}
+}
+
+// RecordBuild records a build of revision taking d and logs a warning with
+// the phase breakdown if it exceeded the slow-build threshold.
+func (r *Recorder) RecordBuild(revision uint64, d time.Duration, b *Breakdown) {
+	r.Record(Build, d, b)
+
+	r.mu.Lock()
+	threshold := r.slowBuild
+	r.mu.Unlock()
+	if threshold > 0 && d > threshold {
+		logger := r.Logger
+		if logger == nil {
+			logger = log.StandardLogger()
+		}
+		logger.WithFields(b.Fields()).WithFields(log.Fields{
+			"revision":  revision,
+			"duration":  d,
+			"threshold": threshold,
+		}).Warn("Slow graph build")
+	}
+}
+
+// ring is a fixed-size buffer of the most recent samples.
+type ring struct {
+	buf  []time.Duration
+	next int
+	full bool
+}
+
+// resize keeps the newest samples that fit in size.
+func (s *ring) resize(size int) {
+	kept := s.ordered()
+	if len(kept) > size {
+		kept = kept[len(kept)-size:]
+	}
+	s.buf = make([]time.Duration, size)
+	copy(s.buf, kept)
+	s.next = len(kept) % size
+	s.full = len(kept) == size
+}
+
+// add records d, overwriting the oldest sample when full.
+func (s *ring) add(d time.Duration) {
+	s.buf[s.next] = d
+	s.next = (s.next + 1) % len(s.buf)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// ordered returns the samples oldest first.
+func (s *ring) ordered() []time.Duration {
+	if !s.full {
+		return append([]time.Duration(nil), s.buf[:s.next]...)
+	}
+	return append(append([]time.Duration(nil), s.buf[s.next:]...), s.buf[:s.next]...)
+}
+
+// summary computes nearest-rank percentiles over the samples.
+func (s *ring) summary() Summary {
+	sorted := s.ordered()
+	if len(sorted) == 0 {
+		return Summary{}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return sorted[i]
+	}
+	return Summary{
+		Count: len(sorted),
+		P50:   rank(0.50),
+		P95:   rank(0.95),
+		Max:   sorted[len(sorted)-1],
+	}
+}
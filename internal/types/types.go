@@ -3,6 +3,8 @@ package types
 // EntityKey uniquely identifies a Kubernetes resource.
 type EntityKey struct {
 	Kind      string
+	APIGroup  string // Empty for the core API group
 	Namespace string // Empty for non-namespaced resources like Node
 	Name      string
+	Cluster   string // Empty in single-cluster mode
 }
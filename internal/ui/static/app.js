@@ -0,0 +1,302 @@
+// Satellite graph viewer: fetches /graph and renders a force-directed layout
+// on a canvas. Deliberately dependency-free so the binary stays hermetic.
+(function () {
+    'use strict';
+
+    const canvas = document.getElementById('graph');
+    const ctx = canvas.getContext('2d');
+    const statusEl = document.getElementById('status');
+    const form = document.getElementById('controls');
+    const kindsEl = document.getElementById('kinds');
+    const namespacesEl = document.getElementById('namespaces');
+    const tokenEl = document.getElementById('token');
+    const details = document.getElementById('details');
+
+    const RADIUS = 6;
+
+    let nodes = [];     // {key, props, x, y, vx, vy, color}
+    let edges = [];     // {source, target, type} (node objects)
+    let alpha = 0;      // simulation temperature; 0 = settled
+    let view = { x: 0, y: 0, scale: 1 };
+    let selected = null;
+
+    // --- state in the URL / session ---
+
+    const params = new URLSearchParams(location.search);
+    kindsEl.value = params.get('kinds') || '';
+    namespacesEl.value = params.get('namespaces') || '';
+    tokenEl.value = sessionStorage.getItem('satellite.token') || '';
+
+    function setStatus(text, isError) {
+        statusEl.textContent = text;
+        statusEl.className = isError ? 'error' : '';
+    }
+
+    // --- loading ---
+
+    async function load() {
+        const q = new URLSearchParams();
+        if (kindsEl.value.trim()) q.set('kinds', kindsEl.value.trim());
+        if (namespacesEl.value.trim()) q.set('namespaces', namespacesEl.value.trim());
+        history.replaceState(null, '', '?' + q.toString());
+
+        const token = tokenEl.value.trim();
+        sessionStorage.setItem('satellite.token', token);
+        const headers = token ? { Authorization: 'Bearer ' + token } : {};
+
+        setStatus('loading…');
+        let resp;
+        try {
+            resp = await fetch('../graph?' + q.toString(), { headers });
+        } catch (err) {
+            setStatus('request failed: ' + err, true);
+            return;
+        }
+        if (resp.status === 401) {
+            setStatus('unauthorized: enter a valid token', true);
+            return;
+        }
+        if (resp.status === 503) {
+            setStatus('graph not built yet, retrying…', true);
+            setTimeout(load, 5000);
+            return;
+        }
+        if (!resp.ok) {
+            setStatus(resp.status + ' ' + (await resp.text()).trim(), true);
+            return;
+        }
+        const g = await resp.json();
+        setGraph(g);
+        setStatus('revision ' + g.graphRevision + ': ' + g.nodes.length + ' nodes, ' +
+            g.relationships.length + ' relationships');
+    }
+
+    function keyId(k) {
+        return [k.cluster || '', k.kind, k.apiGroup || '', k.namespace || '', k.name].join('/');
+    }
+
+    function kindColor(kind) {
+        let h = 0;
+        for (let i = 0; i < kind.length; i++) h = (h * 31 + kind.charCodeAt(i)) % 360;
+        return 'hsl(' + h + ', 65%, 50%)';
+    }
+
+    function setGraph(g) {
+        const old = new Map(nodes.map(n => [n.id, n]));
+        const byId = new Map();
+        nodes = g.nodes.map((n, i) => {
+            const id = keyId(n.key);
+            const prev = old.get(id);
+            const angle = i * 2.399963; // golden angle spiral for the initial layout
+            const r = 10 * Math.sqrt(i);
+            const node = {
+                id, key: n.key, props: n.properties || {},
+                x: prev ? prev.x : r * Math.cos(angle),
+                y: prev ? prev.y : r * Math.sin(angle),
+                vx: 0, vy: 0,
+                color: kindColor(n.key.kind),
+            };
+            byId.set(id, node);
+            return node;
+        });
+        edges = [];
+        for (const r of g.relationships) {
+            const source = byId.get(keyId(r.source));
+            const target = byId.get(keyId(r.target));
+            if (source && target) edges.push({ source, target, type: r.relationshipType });
+        }
+        if (selected) selected = byId.get(selected.id) || null;
+        showDetails(selected);
+        alpha = 1;
+        requestAnimationFrame(frame);
+    }
+
+    // --- simulation ---
+
+    function tick() {
+        const n = nodes.length;
+        const repulsion = 400 * alpha;
+        for (let i = 0; i < n; i++) {
+            const a = nodes[i];
+            for (let j = i + 1; j < n; j++) {
+                const b = nodes[j];
+                let dx = a.x - b.x, dy = a.y - b.y;
+                let d2 = dx * dx + dy * dy;
+                if (d2 < 1) { dx = Math.random() - 0.5; dy = Math.random() - 0.5; d2 = 1; }
+                if (d2 > 90000) continue; // ignore far-away pairs
+                const f = repulsion / d2;
+                a.vx += dx * f; a.vy += dy * f;
+                b.vx -= dx * f; b.vy -= dy * f;
+            }
+        }
+        for (const e of edges) {
+            const dx = e.target.x - e.source.x, dy = e.target.y - e.source.y;
+            const d = Math.sqrt(dx * dx + dy * dy) || 1;
+            const f = (d - 40) * 0.05 * alpha / d;
+            e.source.vx += dx * f; e.source.vy += dy * f;
+            e.target.vx -= dx * f; e.target.vy -= dy * f;
+        }
+        for (const node of nodes) {
+            if (node === dragging) continue;
+            node.vx = (node.vx - node.x * 0.002 * alpha) * 0.6;
+            node.vy = (node.vy - node.y * 0.002 * alpha) * 0.6;
+            node.x += node.vx;
+            node.y += node.vy;
+        }
+        alpha *= 0.98;
+        if (alpha < 0.01) alpha = 0;
+    }
+
+    // --- rendering ---
+
+    function resize() {
+        const dpr = window.devicePixelRatio || 1;
+        canvas.width = canvas.clientWidth * dpr;
+        canvas.height = canvas.clientHeight * dpr;
+        draw();
+    }
+
+    function draw() {
+        const dpr = window.devicePixelRatio || 1;
+        ctx.setTransform(dpr, 0, 0, dpr, 0, 0);
+        ctx.clearRect(0, 0, canvas.clientWidth, canvas.clientHeight);
+        ctx.translate(canvas.clientWidth / 2 + view.x, canvas.clientHeight / 2 + view.y);
+        ctx.scale(view.scale, view.scale);
+
+        ctx.strokeStyle = '#bbb';
+        ctx.lineWidth = 1 / view.scale;
+        ctx.beginPath();
+        for (const e of edges) {
+            ctx.moveTo(e.source.x, e.source.y);
+            ctx.lineTo(e.target.x, e.target.y);
+        }
+        ctx.stroke();
+
+        for (const node of nodes) {
+            ctx.beginPath();
+            ctx.arc(node.x, node.y, RADIUS, 0, 2 * Math.PI);
+            ctx.fillStyle = node.color;
+            ctx.fill();
+            if (node === selected) {
+                ctx.lineWidth = 3 / view.scale;
+                ctx.strokeStyle = '#000';
+                ctx.stroke();
+            }
+        }
+
+        if (view.scale > 0.8) {
+            ctx.fillStyle = '#333';
+            ctx.font = (11 / view.scale) + 'px system-ui, sans-serif';
+            for (const node of nodes) ctx.fillText(node.key.name, node.x + RADIUS + 2, node.y + 4 / view.scale);
+        }
+    }
+
+    function frame() {
+        if (alpha > 0) tick();
+        draw();
+        if (alpha > 0) requestAnimationFrame(frame);
+    }
+
+    // --- interaction ---
+
+    let dragging = null;   // node being dragged
+    let panning = null;    // {x, y, vx, vy} at pan start
+    let moved = false;
+
+    function toWorld(ev) {
+        const rect = canvas.getBoundingClientRect();
+        return {
+            x: (ev.clientX - rect.left - canvas.clientWidth / 2 - view.x) / view.scale,
+            y: (ev.clientY - rect.top - canvas.clientHeight / 2 - view.y) / view.scale,
+        };
+    }
+
+    function nodeAt(p) {
+        const r2 = (RADIUS + 3) * (RADIUS + 3);
+        for (let i = nodes.length - 1; i >= 0; i--) {
+            const dx = nodes[i].x - p.x, dy = nodes[i].y - p.y;
+            if (dx * dx + dy * dy <= r2) return nodes[i];
+        }
+        return null;
+    }
+
+    canvas.addEventListener('mousedown', ev => {
+        moved = false;
+        dragging = nodeAt(toWorld(ev));
+        if (!dragging) panning = { x: ev.clientX, y: ev.clientY, vx: view.x, vy: view.y };
+    });
+
+    window.addEventListener('mousemove', ev => {
+        if (!dragging && !panning) return;
+        moved = true;
+        if (dragging) {
+            const p = toWorld(ev);
+            dragging.x = p.x; dragging.y = p.y;
+            dragging.vx = dragging.vy = 0;
+            if (alpha < 0.1) { alpha = 0.1; requestAnimationFrame(frame); }
+        } else {
+            view.x = panning.vx + ev.clientX - panning.x;
+            view.y = panning.vy + ev.clientY - panning.y;
+            if (alpha === 0) draw();
+        }
+    });
+
+    window.addEventListener('mouseup', ev => {
+        if (!moved && ev.target === canvas) {
+            selected = nodeAt(toWorld(ev));
+            showDetails(selected);
+            if (alpha === 0) draw();
+        }
+        dragging = null;
+        panning = null;
+    });
+
+    canvas.addEventListener('wheel', ev => {
+        ev.preventDefault();
+        const factor = Math.exp(-ev.deltaY * 0.001);
+        const scale = Math.min(8, Math.max(0.05, view.scale * factor));
+        const rect = canvas.getBoundingClientRect();
+        const cx = ev.clientX - rect.left - canvas.clientWidth / 2;
+        const cy = ev.clientY - rect.top - canvas.clientHeight / 2;
+        // keep the point under the cursor fixed
+        view.x = cx - (cx - view.x) * scale / view.scale;
+        view.y = cy - (cy - view.y) * scale / view.scale;
+        view.scale = scale;
+        if (alpha === 0) draw();
+    }, { passive: false });
+
+    function showDetails(node) {
+        if (!node) {
+            details.hidden = true;
+            return;
+        }
+        const k = node.key;
+        document.getElementById('details-title').textContent =
+            k.kind + ' ' + (k.namespace ? k.namespace + '/' : '') + k.name;
+        const table = document.getElementById('details-props');
+        table.replaceChildren();
+        const rows = [['cluster', k.cluster]].concat(Object.keys(node.props).sort().map(p => [p, node.props[p]]));
+        for (const [name, value] of rows) {
+            if (!value) continue;
+            const tr = table.insertRow();
+            tr.insertCell().textContent = name;
+            tr.insertCell().textContent = value;
+        }
+        details.hidden = false;
+    }
+
+    document.getElementById('close').addEventListener('click', () => {
+        selected = null;
+        showDetails(null);
+        if (alpha === 0) draw();
+    });
+
+    form.addEventListener('submit', ev => {
+        ev.preventDefault();
+        load();
+    });
+
+    window.addEventListener('resize', resize);
+    resize();
+    load();
+})();
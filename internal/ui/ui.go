@@ -0,0 +1,95 @@
+// Package ui embeds the dependency-free graph viewer served at /ui/.
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Prefix is the path the viewer is served under.
+const Prefix = "/ui/"
+
+//go:embed static
+var static embed.FS
+
+// asset is one embedded file with its precomputed validator.
+type asset struct {
+	content     []byte
+	contentType string
+	etag        string
+}
+
+// Handler serves the embedded viewer. Every response carries a content-hash
+// ETag with "Cache-Control: no-cache", so browsers revalidate cheaply and
+// never run a stale viewer after an upgrade.
+type Handler struct {
+	assets map[string]asset
+}
+
+// creates a handler over the embedded assets.
+func NewHandler() *Handler {
+	h := &Handler{assets: make(map[string]asset)}
+	err := fs.WalkDir(static, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := static.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		ctype := mime.TypeByExtension(path.Ext(p))
+		if ctype == "" {
+			ctype = http.DetectContentType(content)
+		}
+		h.assets[strings.TrimPrefix(p, "static/")] = asset{
+			content:     content,
+			contentType: ctype,
+			etag:        fmt.Sprintf(`"%x"`, sha256.Sum256(content)),
+		}
+		return nil
+	})
+	if err != nil {
+		// the assets are compiled in; this can only be a build defect
+		panic(fmt.Sprintf("failed to load embedded UI: %v", err))
+	}
+	return h
+}
+
+// Register mounts the viewer on mux. The viewer shell itself holds no cluster
+// data and is served without auth so a browser can load it; the data it
+// fetches goes through the API's auth with the token entered in the viewer.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.Handle(Prefix, h)
+	mux.Handle(strings.TrimSuffix(Prefix, "/"), http.RedirectHandler(Prefix, http.StatusMovedPermanently))
+}
+
+// ServeHTTP serves one embedded asset, index.html for the directory itself.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, Prefix)
+	if name == "" {
+		name = "index.html"
+	}
+	a, ok := h.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("ETag", a.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(a.content))
+}
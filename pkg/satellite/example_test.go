@@ -0,0 +1,159 @@
+package satellite_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/tthuwng/satellite/pkg/satellite"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Writes the graph of the shop namespace to stdout on every change, using
+// the kubeconfig of the current user.
+func Example() {
+	c, err := satellite.New(
+		satellite.WithNamespaces("shop"),
+		satellite.WithEmitter(func(_ context.Context, g satellite.Graph) error {
+			return json.NewEncoder(os.Stdout).Encode(g)
+		}),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := c.Run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func ExampleCollector_Subscribe() {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "node-uid"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "pod-uid"},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+		},
+	)
+	c, err := satellite.New(satellite.WithClient(client), satellite.WithKinds("Pod", "Node"))
+	if err != nil {
+		panic(err)
+	}
+	// the snapshot is empty until the initial sync, so the first delta
+	// holds the whole graph
+	_, deltas, _ := c.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	d := <-deltas
+	var added []string
+	for _, node := range d.AddedNodes {
+		added = append(added, node.Key.Kind+"/"+node.Key.Name)
+	}
+	slices.Sort(added)
+	fmt.Println(added)
+	for _, rel := range d.AddedRelationships {
+		fmt.Println(rel.Source.Name, rel.RelationshipType, rel.Target.Name)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		panic(err)
+	}
+	fmt.Println(c.Snapshot().GraphRevision > 0)
+	// Output:
+	// [Node/node-a Pod/web]
+	// web SCHEDULED_ON node-a
+	// web RUNS_AS default
+	// true
+}
+
+// queryGraph returns the graph of a small shop: two web pods on a node,
+// selected by a service, and a worker pod.
+func queryGraph() satellite.Graph {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "node-uid"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop", UID: "web-1", Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "shop", UID: "web-2", Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop", UID: "worker", Labels: map[string]string{"app": "worker"}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "svc-uid"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+		},
+	)
+	c, err := satellite.New(satellite.WithClient(client), satellite.WithKinds("Pod", "Node", "Service"))
+	if err != nil {
+		panic(err)
+	}
+	_, deltas, _ := c.Subscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	<-deltas
+	cancel()
+	if err := <-done; err != nil {
+		panic(err)
+	}
+	return c.Snapshot()
+}
+
+func ExampleGraph_MatchNodes() {
+	g := queryGraph()
+	var names []string
+	for _, pod := range g.MatchNodes("Pod", "shop", labels.SelectorFromSet(labels.Set{"app": "web"})) {
+		names = append(names, pod.Key.Name)
+	}
+	slices.Sort(names)
+	fmt.Println(names)
+	// Output:
+	// [web-1 web-2]
+}
+
+func ExampleGraph_Neighbors() {
+	g := queryGraph()
+	node := satellite.GraphEntityKey{Kind: "Node", Name: "node-a"}
+	var names []string
+	for _, pod := range g.Neighbors(node, satellite.Incoming, "SCHEDULED_ON") {
+		names = append(names, pod.Key.Name)
+	}
+	slices.Sort(names)
+	fmt.Println(names)
+	// Output:
+	// [web-1 web-2]
+}
+
+func ExampleGraph_Traverse() {
+	g := queryGraph()
+	svc := satellite.GraphEntityKey{Kind: "Service", Namespace: "shop", Name: "web"}
+	// the pods behind the service and the nodes they run on
+	sub, _ := g.Traverse(svc, 2, func(rel satellite.GraphRelationship, _ satellite.GraphNode) bool {
+		return rel.RelationshipType == "SELECTS" || rel.RelationshipType == "SCHEDULED_ON"
+	})
+	var names []string
+	for _, node := range sub.Nodes {
+		names = append(names, node.Key.Kind+"/"+node.Key.Name)
+	}
+	slices.Sort(names)
+	fmt.Println(names, len(sub.Relationships))
+	// Output:
+	// [Node/node-a Pod/web-1 Pod/web-2 Service/web] 4
+}
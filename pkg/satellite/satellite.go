@@ -0,0 +1,376 @@
+// Package satellite embeds the Satellite collector in other programs: a
+// Collector watches a cluster, keeps its graph up to date and hands every
+// build to an emitter, to Snapshot callers and, as deltas, to subscribers.
+//
+//	c, err := satellite.New(satellite.WithClient(client), satellite.WithNamespaces("shop"))
+//	if err != nil {
+//		return err
+//	}
+//	go c.Run(ctx)
+//	g, deltas, cancel := c.Subscribe()
+package satellite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/admin"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/hooks"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/pipeline"
+	"github.com/tthuwng/satellite/internal/runner"
+	"github.com/tthuwng/satellite/internal/types"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// The graph types, as emitted by the satellite binary.
+type (
+	Graph              = graph.Graph
+	GraphMetadata      = graph.GraphMetadata
+	GraphNode          = graph.GraphNode
+	GraphRelationship  = graph.GraphRelationship
+	GraphEntityKey     = graph.GraphEntityKey
+	Delta              = graph.Delta
+	NodeUpdate         = graph.NodeUpdate
+	RelationshipUpdate = graph.RelationshipUpdate
+)
+
+// Graph queries: see Graph.MatchNodes, Graph.Neighbors and Graph.Traverse.
+type (
+	Direction      = graph.Direction
+	TraverseFilter = graph.TraverseFilter
+)
+
+// The directions of Graph.Neighbors.
+const (
+	Outgoing = graph.Outgoing
+	Incoming = graph.Incoming
+	Both     = graph.Both
+)
+
+// EntityKey identifies a cluster object, as passed to OnGraphBuiltFunc.
+type EntityKey = types.EntityKey
+
+// OnGraphBuiltFunc is called with every built graph and the keys of the nodes
+// changed since the previous build; see Collector.RegisterOnGraphBuilt.
+type OnGraphBuiltFunc = hooks.OnGraphBuiltFunc
+
+// EmitFunc receives every built graph, e.g. to write it somewhere. An error
+// is logged; the next build is emitted regardless.
+type EmitFunc = emitter.EmitFunc
+
+// subscriberBuffer is the number of deltas a subscriber may fall behind by
+// before it is dropped.
+const subscriberBuffer = 16
+
+// shutdownTimeout bounds the final build and emit once Run's context ends.
+const shutdownTimeout = 30 * time.Second
+
+// errNotSynced is returned by builds before the initial sync has completed.
+var errNotSynced = errors.New("initial sync not completed")
+
+// Option configures a Collector.
+type Option func(*Collector) error
+
+// WithClient sets the client the cluster is watched through. By default the
+// client is built like kubectl's: from $KUBECONFIG or ~/.kube/config, or the
+// in-cluster config.
+func WithClient(client kubernetes.Interface) Option {
+	return func(c *Collector) error {
+		c.client = client
+		return nil
+	}
+}
+
+// WithKinds restricts the collector to these kinds, e.g. "Pod", "Service".
+// By default every kind Satellite knows is watched.
+func WithKinds(kinds ...string) Option {
+	return func(c *Collector) error {
+		set, err := k8s.ParseKinds(strings.Join(kinds, ","))
+		if err != nil {
+			return err
+		}
+		c.kinds = set
+		return nil
+	}
+}
+
+// WithNamespaces restricts the namespaced kinds to these namespaces. A single
+// namespace only needs namespace-scoped list and watch permissions.
+func WithNamespaces(namespaces ...string) Option {
+	return func(c *Collector) error {
+		c.namespaces = append([]string(nil), namespaces...)
+		return nil
+	}
+}
+
+// WithResyncPeriod sets the informers' resync period (default 0, no resyncs).
+func WithResyncPeriod(period time.Duration) Option {
+	return func(c *Collector) error {
+		if period < 0 {
+			return fmt.Errorf("negative resync period %s", period)
+		}
+		c.resync = period
+		return nil
+	}
+}
+
+// WithEmitter hands every built graph to emit. Graphs are emitted one at a
+// time; while emit is busy only the newest build is kept.
+func WithEmitter(emit EmitFunc) Option {
+	return func(c *Collector) error {
+		c.emit = emit
+		return nil
+	}
+}
+
+// WithHookTimeout bounds each OnGraphBuilt hook call (default 10s).
+func WithHookTimeout(timeout time.Duration) Option {
+	return func(c *Collector) error {
+		c.hooks.Timeout = timeout
+		return nil
+	}
+}
+
+// WithHistory keeps the last revisions built graphs, taking at most about
+// maxBytes (0 for no limit), in memory for Revision and At.
+func WithHistory(revisions int, maxBytes int64) Option {
+	return func(c *Collector) error {
+		if revisions < 0 || maxBytes < 0 {
+			return fmt.Errorf("negative history bounds %d revisions, %d bytes", revisions, maxBytes)
+		}
+		c.history = graph.NewGraphHistory(revisions, maxBytes)
+		return nil
+	}
+}
+
+// WithClusterName records name as the cluster of every graph.
+func WithClusterName(name string) Option {
+	return func(c *Collector) error {
+		c.clusterName = name
+		return nil
+	}
+}
+
+// Collector watches one cluster and builds its graph on every change.
+type Collector struct {
+	client      kubernetes.Interface
+	kinds       map[string]bool
+	namespaces  []string
+	resync      time.Duration
+	emit        EmitFunc
+	clusterName string
+	hooks       hooks.Registry
+	history     *graph.GraphHistory
+
+	running atomic.Bool
+
+	mu       sync.Mutex
+	snapshot Graph
+	subs     map[chan Delta]struct{}
+	stopped  bool // Run has returned
+}
+
+// New returns a collector configured by opts. Nothing is watched until Run.
+func New(opts ...Option) (*Collector, error) {
+	c := &Collector{}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.client == nil {
+		cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		if c.client, err = kubernetes.NewForConfig(cfg); err != nil {
+			return nil, fmt.Errorf("failed to create client: %w", err)
+		}
+	}
+	if c.emit == nil {
+		c.emit = func(context.Context, Graph) error { return nil }
+	}
+	return c, nil
+}
+
+// Run watches the cluster and builds a graph after the initial sync and on
+// every change, until ctx is cancelled. It then builds and emits a final
+// graph and closes every subscription. Run may only be called once.
+func (c *Collector) Run(ctx context.Context) error {
+	if !c.running.CompareAndSwap(false, true) {
+		return errors.New("collector already started")
+	}
+	defer c.closeSubscribers()
+
+	// forbidden kinds are disabled by the informers themselves; the
+	// preflight only knows cluster-wide access
+	p, err := pipeline.New("", c.client, nil, nil, pipeline.Options{
+		Kinds:         c.kinds,
+		Namespaces:    c.namespaces,
+		ResyncPeriod:  c.resync,
+		SkipPreflight: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up informers: %w", err)
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	changed := make(chan struct{}, 1)
+	p.Start(runCtx, &admin.Health{}, changed)
+
+	queue := emitter.NewQueue(c.emit, 0)
+	queueDone := make(chan struct{})
+	go func() {
+		defer close(queueDone)
+		queue.Run(runCtx)
+	}()
+
+	loop := runner.New(func(ctx context.Context, revision uint64) (Graph, error) {
+		return c.build(ctx, p, revision)
+	}, queue, changed, nil)
+	loop.Seq = func() uint64 {
+		if !p.Synced() {
+			return 0
+		}
+		return p.Cache().Seq()
+	}
+	loop.Run(runCtx)
+
+	stop()
+	p.Shutdown()
+	<-queueDone
+	if !p.Synced() {
+		return nil
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return loop.Final(shutdownCtx)
+}
+
+// build builds the graph, records it as the snapshot and publishes its delta.
+func (c *Collector) build(ctx context.Context, p *pipeline.Pipeline, revision uint64) (Graph, error) {
+	if !p.Synced() {
+		return Graph{}, errNotSynced
+	}
+	g, err := p.Build(ctx, revision)
+	if err != nil {
+		return Graph{}, err
+	}
+	if c.clusterName != "" {
+		graph.StampClusterName(&g, c.clusterName, false)
+	}
+	g.Meta().BuiltAt = time.Now().UTC()
+
+	c.publish(g)
+	if c.history != nil {
+		c.history.Add(g)
+	}
+	c.hooks.Run(ctx, g)
+	return g, nil
+}
+
+// publish records g as the snapshot and sends its delta to the subscribers.
+func (c *Collector) publish(g Graph) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev := c.snapshot
+	c.snapshot = g
+	if len(c.subs) == 0 {
+		return
+	}
+	d := graph.Diff(prev, g)
+	for ch := range c.subs {
+		select {
+		case ch <- d:
+		default:
+			log.Warn("Dropping slow graph subscriber")
+			delete(c.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// RegisterOnGraphBuilt adds fn to the hooks run after every build. Hooks run
+// one at a time in registration order, after the snapshot and subscribers
+// are updated and before the graph is emitted. A hook that panics or
+// outlives its timeout is logged and abandoned, and the next one still runs.
+func (c *Collector) RegisterOnGraphBuilt(fn OnGraphBuiltFunc) {
+	c.hooks.RegisterOnGraphBuilt(fn)
+}
+
+// Snapshot returns the last built graph; an empty graph at revision 0 before
+// the initial sync.
+func (c *Collector) Snapshot() Graph {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.snapshot
+}
+
+// Revision returns the graph of revision, if it is still in the history
+// kept by WithHistory.
+func (c *Collector) Revision(revision uint64) (Graph, bool) {
+	if c.history == nil {
+		return Graph{}, false
+	}
+	return c.history.GetRevision(revision)
+}
+
+// At returns the newest graph built at or before t, if the history kept by
+// WithHistory goes back that far.
+func (c *Collector) At(t time.Time) (Graph, bool) {
+	if c.history == nil {
+		return Graph{}, false
+	}
+	return c.history.GetAt(t)
+}
+
+// Subscribe returns the current snapshot and a channel receiving the delta of
+// every later build, in order, so applying them to the snapshot tracks the
+// graph. The channel is closed when cancel is called, when Run returns, or
+// when the subscriber falls behind; subscribe again to resynchronize.
+func (c *Collector) Subscribe() (snapshot Graph, deltas <-chan Delta, cancel func()) {
+	ch := make(chan Delta, subscriberBuffer)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		close(ch)
+		return c.snapshot, ch, func() {}
+	}
+	if c.subs == nil {
+		c.subs = make(map[chan Delta]struct{})
+	}
+	c.subs[ch] = struct{}{}
+	return c.snapshot, ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.subs[ch]; ok {
+			delete(c.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// closeSubscribers ends every subscription.
+func (c *Collector) closeSubscribers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subs {
+		close(ch)
+	}
+	c.subs = nil
+	c.stopped = true
+}
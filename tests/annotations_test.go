@@ -0,0 +1,108 @@
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/config"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestAnnotationProperties verifies annotations are promoted by exact key and prefix, exact mappings
+// and longer prefixes winning, and promoted properties overriding extracted ones.
+func TestAnnotationProperties(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "satellite.yaml")
+	if err := os.WriteFile(path, []byte(`
+annotationProperties:
+  - annotation: acme.io/team
+    property: owner
+  - annotation: acme.io/phase
+    property: status.phase
+  - prefix: acme.io/
+    property: ""
+  - prefix: acme.io/oncall-
+    property: oncall.
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	promote, err := graph.NewAnnotationProperties(cfg.AnnotationProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid", Annotations: map[string]string{
+			"acme.io/team":           "payments",
+			"acme.io/tier":           "1",
+			"acme.io/phase":          "Canary",
+			"acme.io/owner":          "loses to the exact mapping",
+			"acme.io/oncall-primary": "alice",
+			"acme.io/uid":            "reserved",
+			"other.io/tier":          "ignored",
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithAnnotationProperties(promote))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := g.Nodes[0].Properties
+	want := map[string]string{
+		"owner":          "payments",
+		"tier":           "1",
+		"status.phase":   "Canary",
+		"oncall.primary": "alice",
+		"uid":            "web-uid",
+	}
+	for key, value := range want {
+		if props[key] != value {
+			t.Errorf("%s = %q, want %q", key, props[key], value)
+		}
+	}
+	for _, key := range []string{"team", "phase", "oncall-primary"} {
+		if _, ok := props[key]; ok {
+			t.Errorf("Annotation promoted twice, as %s", key)
+		}
+	}
+
+	// without the option, nothing is promoted
+	g, err = graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Nodes[0].Properties["owner"]; ok || g.Nodes[0].Properties["status.phase"] != "Running" {
+		t.Errorf("Annotations promoted by default: %v", g.Nodes[0].Properties)
+	}
+}
+
+// TestAnnotationProperties_Invalid verifies invalid mappings are rejected.
+func TestAnnotationProperties_Invalid(t *testing.T) {
+	cases := map[string][]graph.AnnotationMapping{
+		"neither key nor prefix": {{Property: "team"}},
+		"key and prefix":         {{Annotation: "acme.io/team", Prefix: "acme.io/", Property: "team"}},
+		"invalid annotation":     {{Annotation: "acme.io/team/lead", Property: "lead"}},
+		"missing property":       {{Annotation: "acme.io/team"}},
+		"reserved property":      {{Annotation: "acme.io/id", Property: "uid"}},
+		"whitespace":             {{Annotation: "acme.io/team", Property: "the team"}},
+		"duplicate annotation":   {{Annotation: "acme.io/team", Property: "team"}, {Annotation: "acme.io/team", Property: "owner"}},
+		"duplicate prefix":       {{Prefix: "acme.io/", Property: ""}, {Prefix: "acme.io/", Property: "acme."}},
+		"duplicate property":     {{Annotation: "acme.io/team", Property: "team"}, {Annotation: "corp.io/team", Property: "team"}},
+	}
+	for name, mappings := range cases {
+		if _, err := graph.NewAnnotationProperties(mappings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var anonymizeKey = []byte("0123456789abcdef-test-key")
+
+// TestAnonymize verifies no name, namespace, label or annotation value, IP, image or UID of the cache
+// appears in the anonymized graph, while its shape and numeric properties are kept.
+func TestAnonymize(t *testing.T) {
+	c := syntheticCache(300)
+	c.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "coredns-5d78c9869d-abcde", Namespace: "kube-system", UID: "coredns-uid",
+			Labels:      map[string]string{"app.kubernetes.io/name": "coredns", "owner": "platform-team"},
+			Annotations: map[string]string{"acme.io/contact": "oncall@acme.example,pager", "acme.io/replicas": "3"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-0", Containers: []corev1.Container{{Name: "coredns", Image: "registry.acme.example/coredns:1.11"}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "fd00::1234", ContainerStatuses: []corev1.ContainerStatus{{Name: "coredns", RestartCount: 2}}},
+	})
+	g, err := graph.BuildGraph(context.Background(), c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.StampClusterName(&g, "acme-prod-eu", true)
+
+	a, err := graph.NewAnonymizer(anonymizeKey, graph.AnonymizeOptions{Namespaces: []string{"kube-system"}, Labels: []string{"app.kubernetes.io/name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon := a.Anonymize(g)
+	data, err := json.Marshal(anon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+
+	originals := map[string]bool{"acme-prod-eu": true}
+	for _, obj := range c.Snapshot().List() {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		originals[m.GetName()] = true
+		originals[m.GetNamespace()] = true
+		originals[string(m.GetUID())] = true
+		for _, v := range m.GetLabels() {
+			originals[v] = true
+		}
+		for _, v := range m.GetAnnotations() {
+			originals[v] = true
+		}
+		switch o := obj.(type) {
+		case *corev1.Pod:
+			originals[o.Status.PodIP], originals[o.Status.HostIP] = true, true
+			for _, cs := range o.Spec.Containers {
+				originals[cs.Name], originals[cs.Image] = true, true
+			}
+		case *corev1.Service:
+			originals[o.Spec.ClusterIP] = true
+		case *corev1.Node:
+			originals[o.Spec.PodCIDR] = true
+		case *corev1.ConfigMap:
+			for k := range o.Data {
+				originals[k] = true
+			}
+		}
+	}
+	// kept on purpose: booleans and numbers, the allowed namespace and label value, the os property
+	// and "app", also a label key
+	for _, kept := range []string{"", "true", "3", "kube-system", "coredns", "linux", "app"} {
+		delete(originals, kept)
+	}
+	for original := range originals {
+		if len(original) >= 3 && strings.Contains(output, original) {
+			t.Errorf("Anonymized graph holds %q", original)
+		}
+	}
+
+	if len(anon.Nodes) != len(g.Nodes) || len(anon.Relationships) != len(g.Relationships) {
+		t.Fatalf("Anonymized graph has %d nodes, %d relationships, want %d, %d", len(anon.Nodes), len(anon.Relationships), len(g.Nodes), len(g.Relationships))
+	}
+	for i, n := range anon.Nodes {
+		orig := g.Nodes[i]
+		if n.Key.Kind != orig.Key.Kind || n.Properties["spec.replicas"] != orig.Properties["spec.replicas"] || n.Properties["status.phase"] != orig.Properties["status.phase"] ||
+			n.Properties["restartCount"] != orig.Properties["restartCount"] || n.Properties["creationTimestamp"] != orig.Properties["creationTimestamp"] {
+			t.Errorf("Node %v anonymized to %v", orig, n)
+		}
+		if orig.Key.Name == "coredns-5d78c9869d-abcde" {
+			if n.Key.Namespace != "kube-system" || !strings.Contains(n.Properties["labels"], "app.kubernetes.io/name=coredns") ||
+				!strings.Contains(n.Properties["annotations"], "acme.io/replicas=3") || n.Properties["restartCount"] != "2" {
+				t.Errorf("kube-system pod anonymized to %v", n)
+			}
+		}
+	}
+	for _, n := range anon.Nodes {
+		for k := range n.Properties {
+			if strings.HasPrefix(k, "containers.") && !strings.HasPrefix(k, "containers.anon-") {
+				t.Errorf("Container name kept in property %s", k)
+			}
+		}
+	}
+	var types, anonTypes []string
+	for i := range g.Relationships {
+		types = append(types, g.Relationships[i].RelationshipType)
+		anonTypes = append(anonTypes, anon.Relationships[i].RelationshipType)
+	}
+	if !slices.Equal(types, anonTypes) {
+		t.Error("Relationship types changed")
+	}
+
+	// stable across emits, different for another key
+	again, _ := json.Marshal(a.Anonymize(g))
+	if string(again) != output {
+		t.Error("Anonymization is not deterministic")
+	}
+	other, err := graph.NewAnonymizer([]byte("another-key-of-16+bytes"), graph.AnonymizeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherNode := other.Anonymize(g).Nodes[0]; otherNode.Key.Name == anon.Nodes[0].Key.Name {
+		t.Error("Pseudonyms do not depend on the key")
+	}
+	if _, err := graph.NewAnonymizer([]byte("short"), graph.AnonymizeOptions{}); err == nil {
+		t.Error("Short key accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// gateway returns a Gateway of the given API version, as a metadata-only object.
+func gateway(apiVersion string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: "Gateway"},
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "edge", ResourceVersion: "1"},
+	}
+}
+
+// TestAPIGroup_SameKindDifferentGroups verifies same-named kinds from different API groups don't collide.
+func TestAPIGroup_SameKindDifferentGroups(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(gateway("networking.istio.io/v1"))
+	resourceCache.Upsert(gateway("gateway.networking.k8s.io/v1"))
+
+	istio := types.EntityKey{Kind: "Gateway", APIGroup: "networking.istio.io", Namespace: "edge", Name: "ingress"}
+	gatewayAPI := types.EntityKey{Kind: "Gateway", APIGroup: "gateway.networking.k8s.io", Namespace: "edge", Name: "ingress"}
+	for _, key := range []types.EntityKey{istio, gatewayAPI} {
+		if _, found := resourceCache.Get(key); !found {
+			t.Errorf("Missing %+v in the cache", key)
+		}
+	}
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string]bool{}
+	for _, n := range g.Nodes {
+		groups[n.Key.APIGroup] = true
+	}
+	if len(g.Nodes) != 2 || !groups["networking.istio.io"] || !groups["gateway.networking.k8s.io"] {
+		t.Errorf("Expected one Gateway node per group, got %+v", g.Nodes)
+	}
+
+	resourceCache.Delete(gateway("networking.istio.io/v1"))
+	if _, found := resourceCache.Get(gatewayAPI); !found {
+		t.Errorf("Deleting the Istio Gateway removed the Gateway API one")
+	}
+}
+
+// TestAPIGroup_JSONOmitsCoreGroup verifies core keys serialize unchanged while grouped keys carry apiGroup.
+func TestAPIGroup_JSONOmitsCoreGroup(t *testing.T) {
+	core, err := json.Marshal(graph.GraphEntityKey{Kind: "Pod", Namespace: "default", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(core) != `{"name":"web","namespace":"default","kind":"Pod"}` {
+		t.Errorf("Core key JSON = %s", core)
+	}
+	apps, err := json.Marshal(graph.GraphEntityKey{Kind: "Deployment", APIGroup: "apps", Namespace: "default", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(apps), `"apiGroup":"apps"`) {
+		t.Errorf("apps key JSON lacks apiGroup: %s", apps)
+	}
+}
+
+// TestAPIGroup_ServerQueries verifies queries resolve built-in kinds without a group and accept kind.group.
+func TestAPIGroup_ServerQueries(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(gateway("networking.istio.io/v1"))
+	resourceCache.Upsert(gateway("gateway.networking.k8s.io/v1"))
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "edge"}})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string]int{
+		"kind=Gateway.networking.istio.io&namespace=edge&name=ingress":             http.StatusOK,
+		"kind=Gateway&group=gateway.networking.k8s.io&namespace=edge&name=ingress": http.StatusOK,
+		"kind=Gateway.example.com&namespace=edge&name=ingress":                     http.StatusNotFound,
+		"kind=Pod&namespace=edge&name=web":                                         http.StatusOK,
+	} {
+		resp, err := http.Get(ts.URL + "/graph/neighbors?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// assertNoTempFiles fails if dir holds temporary files.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("Temporary files left after a failed write: %v", leftovers)
+	}
+}
+
+// TestFileSink_RenameFailure verifies a rename that fails, here onto a directory in the way, is
+// reported as that step of that file and leaves no temporary file behind.
+func TestFileSink_RenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, emitter.LatestFilename)
+	if err := os.MkdirAll(filepath.Join(blocker, "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := (emitter.FileSink{Dir: dir, WriteLatest: true}).Emit(context.Background(), graph.Graph{GraphRevision: 3})
+	var writeErr *emitter.WriteError
+	if !errors.As(err, &writeErr) || writeErr.Step != emitter.StepRename || writeErr.Path != blocker {
+		t.Fatalf("Emit error = %v, want a rename failure of %s", err, blocker)
+	}
+	if !strings.Contains(err.Error(), "failed to rename temporary file into place") {
+		t.Errorf("Error message %q does not name the failed step", err)
+	}
+	assertNoTempFiles(t, dir)
+	// the graph file set was written before latest.json
+	if f, err := emitter.OpenLatestComplete(dir); err != nil {
+		t.Errorf("Graph file missing after the latest.json failure: %v", err)
+	} else {
+		f.Close()
+	}
+}
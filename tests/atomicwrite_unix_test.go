@@ -0,0 +1,55 @@
+//go:build unix
+
+package main_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// skipIfRoot skips tests relying on permissions, which root bypasses.
+func skipIfRoot(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+}
+
+// TestFileSink_ReadOnlyDir verifies an emit into a read-only directory fails at creating the
+// temporary file, with nothing written.
+func TestFileSink_ReadOnlyDir(t *testing.T) {
+	skipIfRoot(t)
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	err := (emitter.FileSink{Dir: dir}).Emit(context.Background(), graph.Graph{GraphRevision: 1})
+	var writeErr *emitter.WriteError
+	if !errors.As(err, &writeErr) || writeErr.Step != emitter.StepCreate || !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Emit error = %v, want a permission failure creating the temporary file", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Failed emit left files: %v", entries)
+	}
+}
+
+// TestFileSink_WriteOnlyDir verifies a directory that can be written but not opened for syncing
+// doesn't fail an emit whose renames succeeded.
+func TestFileSink_WriteOnlyDir(t *testing.T) {
+	skipIfRoot(t)
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0333); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	if err := (emitter.FileSink{Dir: dir, WriteLatest: true}).Emit(context.Background(), graph.Graph{GraphRevision: 1}); err != nil {
+		t.Errorf("Emit into a write-only directory failed: %v", err)
+	}
+}
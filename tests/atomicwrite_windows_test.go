@@ -0,0 +1,43 @@
+//go:build windows
+
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// TestFileSink_ReplaceOpenFile verifies latest.json is replaced once a reader holding it open, which
+// blocks renames over it on Windows, lets go.
+func TestFileSink_ReplaceOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	sink := emitter.FileSink{Dir: dir, WriteLatest: true}
+	if err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 1}); err != nil {
+		t.Fatal(err)
+	}
+	latest := filepath.Join(dir, emitter.LatestFilename)
+	reader, err := os.Open(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		reader.Close()
+	}()
+
+	if err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 2}); err != nil {
+		t.Fatalf("Emit over an open latest.json failed: %v", err)
+	}
+	data, err := os.ReadFile(latest)
+	if err != nil || !strings.Contains(string(data), `"graphRevision": 2`) {
+		t.Errorf("latest.json = %s, %v; want revision 2", data, err)
+	}
+	assertNoTempFiles(t, dir)
+}
@@ -0,0 +1,153 @@
+package main_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/audit"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readAuditRecords decodes every record in the audit log files of dir.
+func readAuditRecords(t *testing.T, dir string) []audit.Record {
+	t.Helper()
+	files, err := audit.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []audit.Record
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var rec audit.Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				t.Fatalf("%s: invalid record %q: %v", path, scanner.Text(), err)
+			}
+			records = append(records, rec)
+		}
+		f.Close()
+	}
+	return records
+}
+
+// TestAuditLog_RecordsInformerEvents verifies events are written in order, redacted and diffed.
+func TestAuditLog_RecordsInformerEvents(t *testing.T) {
+	dir := t.TempDir()
+	w, err := audit.NewWriter(audit.Options{Dir: dir, Objects: true, Diff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Run()
+
+	resourceCache := cache.NewResourceCache()
+	resourceCache.OnEvent(w.Observer("east"))
+	secrets := resourceCache.AddEventHandler("Secret")
+	pods := resourceCache.AddEventHandler("Pod")
+
+	secrets.OnAdd(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default", UID: "s-1", ResourceVersion: "1"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}, false)
+	oldPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "p-1", ResourceVersion: "2"}}
+	newPod := oldPod.DeepCopy()
+	newPod.ResourceVersion = "3"
+	newPod.Labels = map[string]string{"app": "web"}
+	newPod.Spec.NodeName = "node-1"
+	pods.OnAdd(oldPod, false)
+	pods.OnUpdate(oldPod, newPod)
+	pods.OnDelete(newPod)
+	w.Close()
+
+	records := readAuditRecords(t, dir)
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(records))
+	}
+	var types []string
+	for _, rec := range records {
+		types = append(types, rec.Kind+" "+rec.Type)
+		if rec.Cluster != "east" {
+			t.Errorf("Record %s/%s has cluster %q, want east", rec.Kind, rec.Name, rec.Cluster)
+		}
+	}
+	if got := strings.Join(types, ","); got != "Secret ADD,Pod ADD,Pod UPDATE,Pod DELETE" {
+		t.Errorf("Unexpected record order: %s", got)
+	}
+	if records[0].Name != "creds" || records[0].UID != "s-1" {
+		t.Errorf("Unexpected secret identity: %+v", records[0])
+	}
+	if strings.Contains(string(records[0].Object), "aHVudGVyMg") || !strings.Contains(string(records[0].Object), "password") {
+		t.Errorf("Secret data not redacted: %s", records[0].Object)
+	}
+	update := records[2]
+	if update.UID != "p-1" || update.ResourceVersion != "3" {
+		t.Errorf("Unexpected update identity: %+v", update)
+	}
+	if got := strings.Join(update.Changed, ","); got != "metadata.labels,spec.nodeName" {
+		t.Errorf("Changed = %s, want metadata.labels,spec.nodeName", got)
+	}
+}
+
+// TestAuditLog_Rotation verifies the log rotates by size and keeps MaxFiles rotated files.
+func TestAuditLog_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := audit.NewWriter(audit.Options{Dir: dir, MaxSize: 300, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Run()
+	for i := 0; i < 20; i++ {
+		w.Observe(cache.Event{Type: "ADD", Kind: "Pod", Object: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", ResourceVersion: "1"},
+		}})
+	}
+	w.Close()
+
+	files, err := audit.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 2 rotated files plus the current one, got %v", files)
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s is %d bytes, over MaxSize", path, info.Size())
+		}
+	}
+}
+
+// TestAuditLog_DropsWhenFull verifies Observe never blocks when the writer falls behind.
+func TestAuditLog_DropsWhenFull(t *testing.T) {
+	w, err := audit.NewWriter(audit.Options{Dir: t.TempDir(), Buffer: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}}
+	before := testutil.ToFloat64(metrics.AuditEvents.WithLabelValues("dropped"))
+	// Run is not started yet, so only the first event fits
+	for i := 0; i < 3; i++ {
+		w.Observe(cache.Event{Type: "ADD", Kind: "Pod", Object: pod})
+	}
+	if dropped := testutil.ToFloat64(metrics.AuditEvents.WithLabelValues("dropped")) - before; dropped != 2 {
+		t.Errorf("Expected 2 dropped events, got %v", dropped)
+	}
+	go w.Run()
+	w.Close()
+}
@@ -0,0 +1,92 @@
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	satellitev1 "github.com/tthuwng/satellite/api/satellite/v1"
+	"github.com/tthuwng/satellite/internal/server"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestStaticToken(t *testing.T) {
+	auth := server.NewStaticToken("s3cret")
+	for token, want := range map[string]bool{"s3cret": true, "s3cre": false, "s3cret2": false, "": false} {
+		if ok, _ := auth.Authenticate(context.Background(), token); ok != want {
+			t.Errorf("Authenticate(%q) = %v, want %v", token, ok, want)
+		}
+	}
+}
+
+func TestGraphServer_BearerAuth(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	srv.Auth = server.NewStaticToken("s3cret")
+	if err := srv.Update(fixtureGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path, authorization string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, tc := range []struct {
+		path, authorization string
+		want                int
+	}{
+		{"/graph", "", http.StatusUnauthorized},
+		{"/graph", "Bearer wrong", http.StatusUnauthorized},
+		{"/graph", "Basic s3cret", http.StatusUnauthorized},
+		{"/graph/does-not-exist", "", http.StatusUnauthorized}, // not 404
+		{"/graph", "Bearer s3cret", http.StatusOK},
+		{"/graph/does-not-exist", "Bearer s3cret", http.StatusNotFound},
+	} {
+		resp := get(tc.path, tc.authorization)
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET %s (%q): status = %d, want %d", tc.path, tc.authorization, resp.StatusCode, tc.want)
+		}
+		if tc.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("GET %s: missing WWW-Authenticate", tc.path)
+		}
+	}
+}
+
+func TestGRPC_BearerAuth(t *testing.T) {
+	srv := server.New()
+	srv.Auth = server.NewStaticToken("s3cret")
+	if err := srv.Update(fixtureGraph()); err != nil {
+		t.Fatal(err)
+	}
+	client := newGRPCClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.GetGraph(ctx, &satellitev1.GetGraphRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("without token: err = %v, want Unauthenticated", err)
+	}
+	stream, err := client.WatchGraph(ctx, &satellitev1.WatchGraphRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("watch without token: err = %v, want Unauthenticated", err)
+	}
+
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret")
+	if _, err := client.GetGraph(authed, &satellitev1.GetGraphRequest{}); err != nil {
+		t.Fatalf("with token: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cronJob returns a CronJob created at 2024-05-01 00:00 UTC on schedule in timeZone ("" for
+// none), last scheduled at last if it isn't zero.
+func cronJob(schedule, timeZone string, last time.Time) *batchv1.CronJob {
+	cj := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "batch", UID: "report-uid",
+			CreationTimestamp: metav1.NewTime(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))},
+		Spec: batchv1.CronJobSpec{Schedule: schedule},
+	}
+	if timeZone != "" {
+		cj.Spec.TimeZone = &timeZone
+	}
+	if !last.IsZero() {
+		cj.Status.LastScheduleTime = &metav1.Time{Time: last}
+	}
+	return cj
+}
+
+// TestMissedLastRun verifies missed runs are derived from standard schedules, descriptors and
+// @every, in UTC, spec.timeZone or CRON_TZ, allowing for the starting deadline.
+func TestMissedLastRun(t *testing.T) {
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC) }
+	deadline := int64(3600)
+	suspend := true
+
+	cases := []struct {
+		name string
+		cj   *batchv1.CronJob
+		now  time.Time
+		want bool
+	}{
+		{"hourly ran on time", cronJob("0 * * * *", "", at(2, 10, 0)), at(2, 10, 30), false},
+		{"hourly skipped a run", cronJob("0 * * * *", "", at(2, 10, 0)), at(2, 11, 30), true},
+		{"within the default grace", cronJob("0 * * * *", "", at(2, 10, 0)), at(2, 11, 0).Add(30 * time.Second), false},
+		{"within the starting deadline", func() *batchv1.CronJob {
+			cj := cronJob("0 * * * *", "", at(2, 10, 0))
+			cj.Spec.StartingDeadlineSeconds = &deadline
+			return cj
+		}(), at(2, 11, 30), false},
+		{"never ran since creation", cronJob("@daily", "", time.Time{}), at(2, 1, 0), true},
+		{"not due since creation", cronJob("@weekly", "", time.Time{}), at(3, 0, 0), false},
+		{"every 90m ran on time", cronJob("@every 90m", "", at(2, 10, 0)), at(2, 11, 15), false},
+		{"every 90m skipped a run", cronJob("@every 90m", "", at(2, 10, 0)), at(2, 11, 45), true},
+		// 02:00 in New York (EDT, UTC-4) is 06:00 UTC
+		{"time zone not yet due", cronJob("0 2 * * *", "America/New_York", at(1, 6, 0)), at(2, 5, 30), false},
+		{"time zone skipped a run", cronJob("0 2 * * *", "America/New_York", at(1, 6, 0)), at(2, 6, 30), true},
+		{"CRON_TZ prefix", cronJob("CRON_TZ=Asia/Tokyo 0 9 * * *", "", at(1, 0, 0)), at(1, 23, 30), false},
+		{"CRON_TZ prefix skipped a run", cronJob("CRON_TZ=Asia/Tokyo 0 9 * * *", "", at(1, 0, 0)), at(2, 0, 30), true},
+		{"suspended", func() *batchv1.CronJob {
+			cj := cronJob("0 * * * *", "", at(2, 10, 0))
+			cj.Spec.Suspend = &suspend
+			return cj
+		}(), at(3, 0, 0), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := graph.MissedLastRun(tc.cj, tc.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("MissedLastRun = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	for _, cj := range []*batchv1.CronJob{cronJob("not a schedule", "", time.Time{}), cronJob("0 * * * *", "Mars/Olympus", time.Time{})} {
+		if _, err := graph.MissedLastRun(cj, at(2, 0, 0)); err == nil {
+			t.Errorf("Expected an error for schedule %q in %v", cj.Spec.Schedule, cj.Spec.TimeZone)
+		}
+	}
+}
+
+// TestJobOutcome verifies a failed Job's condition, duration and pod counts become properties,
+// and a CronJob with an invalid schedule gets no missedLastRun.
+func TestJobOutcome(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC))
+	failedAt := metav1.NewTime(started.Add(150 * time.Second))
+	backoff := int32(6)
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "report-1", Namespace: "batch", UID: "report-1-uid"},
+		Spec:       batchv1.JobSpec{BackoffLimit: &backoff},
+		Status: batchv1.JobStatus{
+			StartTime: &started, Failed: 7, Succeeded: 0,
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailureTarget, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", LastTransitionTime: failedAt},
+			},
+		},
+	})
+	resourceCache.Upsert(cronJob("every tuesday", "", time.Time{}))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := make(map[string]map[string]string)
+	for _, node := range g.Nodes {
+		props[node.Key.Kind] = node.Properties
+	}
+	want := map[string]string{
+		"status.condition":       "Failed",
+		"status.conditionReason": "BackoffLimitExceeded",
+		"status.startTime":       "2024-05-01T02:00:00Z",
+		"status.failed":          "7",
+		"status.succeeded":       "0",
+		"spec.backoffLimit":      "6",
+		"durationSeconds":        "150",
+	}
+	for key, value := range want {
+		if props["Job"][key] != value {
+			t.Errorf("Job %s = %q, want %q", key, props["Job"][key], value)
+		}
+	}
+	if props["CronJob"]["spec.schedule"] != "every tuesday" {
+		t.Errorf("CronJob properties: %v", props["CronJob"])
+	}
+	if _, ok := props["CronJob"][graph.MissedLastRunProperty]; ok {
+		t.Error("CronJob with an invalid schedule got missedLastRun")
+	}
+}
+
+// TestBatchOwnership verifies a pod of a CronJob-created Job is connected up to the CronJob:
+// Pod OWNED_BY Job OWNED_BY CronJob.
+func TestBatchOwnership(t *testing.T) {
+	last := time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+	cj := cronJob("0 2 * * *", "", last)
+	completed := metav1.NewTime(last.Add(time.Minute))
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "report-28571520", Namespace: "batch", UID: "job-uid",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "CronJob", Name: cj.Name, UID: cj.UID}},
+		},
+		Status: batchv1.JobStatus{Succeeded: 1, CompletionTime: &completed},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "report-28571520-x2v", Namespace: "batch", UID: "pod-uid",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: job.Name, UID: job.UID}},
+	}}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(cj)
+	resourceCache.Upsert(job)
+	resourceCache.Upsert(pod)
+	resourceCache.Upsert(defaultServiceAccount("batch"))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobKey := graph.GraphEntityKey{Kind: "Job", APIGroup: "batch", Namespace: "batch", Name: job.Name}
+	cronJobKey := graph.GraphEntityKey{Kind: "CronJob", APIGroup: "batch", Namespace: "batch", Name: cj.Name}
+	owners := make(map[graph.GraphEntityKey]graph.GraphEntityKey)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "OWNED_BY" {
+			owners[r.Source] = r.Target
+		}
+	}
+	if len(owners) != 2 || owners[graph.GraphEntityKey{Kind: "Pod", Namespace: "batch", Name: pod.Name}] != jobKey || owners[jobKey] != cronJobKey {
+		t.Fatalf("OWNED_BY relationships = %v, want Pod -> Job -> CronJob", owners)
+	}
+	if dangling := graph.DanglingRelationships(g); dangling != 0 {
+		t.Errorf("%d dangling relationships", dangling)
+	}
+
+	props := make(map[string]map[string]string)
+	for _, node := range g.Nodes {
+		props[node.Key.Kind] = node.Properties
+	}
+	if props["Job"]["status.succeeded"] != "1" || props["Job"]["status.completionTime"] != "2024-05-01T02:01:00Z" {
+		t.Errorf("Job properties: %v", props["Job"])
+	}
+	if props["CronJob"]["spec.schedule"] != "0 2 * * *" || props["CronJob"]["status.lastScheduleTime"] != "2024-05-01T02:00:00Z" {
+		t.Errorf("CronJob properties: %v", props["CronJob"])
+	}
+}
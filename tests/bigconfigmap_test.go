@@ -0,0 +1,85 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bigConfigMap returns a ConfigMap of 40 keys and about 800 KB, applied with
+// kubectl so its last-applied-configuration annotation repeats all of it.
+func bigConfigMap() *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "scripts", Namespace: "default", UID: "cm-uid", ResourceVersion: "1"},
+		Data:       make(map[string]string),
+		BinaryData: map[string][]byte{"ca.der": make([]byte, 100<<10)},
+	}
+	for i := 0; i < 40; i++ {
+		cm.Data[fmt.Sprintf("script-%02d.lua", i)] = strings.Repeat("-- lua\n", 1<<10)
+	}
+	applied, _ := json.Marshal(cm)
+	cm.Annotations = map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": string(applied),
+		"team": "edge",
+	}
+	return cm
+}
+
+// TestBigConfigMap_Bounded verifies a near-limit ConfigMap costs little once
+// trimmed and emits bounded properties in either mode.
+func TestBigConfigMap_Bounded(t *testing.T) {
+	cm := bigConfigMap()
+	full, _ := json.Marshal(cm)
+	if len(full) < 700<<10 {
+		t.Fatalf("Fixture too small: %d bytes", len(full))
+	}
+
+	for _, trim := range []bool{false, true} {
+		t.Run(fmt.Sprintf("trim=%v", trim), func(t *testing.T) {
+			obj := cm.DeepCopy()
+			resourceCache := cache.NewResourceCache()
+			if trim {
+				resourceCache.Upsert(k8s.Trim(obj))
+			} else {
+				resourceCache.Upsert(obj)
+			}
+
+			cached, found := resourceCache.Get(types.EntityKey{Kind: "ConfigMap", Namespace: "default", Name: "scripts"})
+			if !found {
+				t.Fatal("ConfigMap not cached")
+			}
+			if data, _ := json.Marshal(cached); trim && len(data) > 4<<10 {
+				t.Errorf("Trimmed ConfigMap is %d bytes cached", len(data))
+			}
+
+			g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			props := g.Nodes[0].Properties
+			size := 0
+			for k, v := range props {
+				size += len(k) + len(v)
+			}
+			if size > 4<<10 {
+				t.Errorf("Emitted properties are %d bytes", size)
+			}
+			if !strings.HasSuffix(props["data.keys"], ",script-31.lua,+8 more") || !strings.HasPrefix(props["data.keys"], "script-00.lua,script-01.lua,") {
+				t.Errorf("Unexpected data.keys: %s", props["data.keys"])
+			}
+			if !strings.Contains(props["annotations"], "team=edge") || !strings.Contains(props["annotations"], " bytes elided>") {
+				t.Errorf("Unexpected annotations: %s", props["annotations"])
+			}
+		})
+	}
+}
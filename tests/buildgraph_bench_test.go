@@ -0,0 +1,70 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/fixtures"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// syntheticCache returns a cache of a synthetic cluster of about n objects.
+func syntheticCache(n int) *cache.ResourceCache {
+	return fixtures.Generate(fixtures.ForObjects(n, 1)).Cache()
+}
+
+// TestBuildGraph_SyntheticGolden pins the graph of a synthetic cache, so
+// optimizations of BuildGraph provably leave its output unchanged.
+func TestBuildGraph_SyntheticGolden(t *testing.T) {
+	g, err := graph.BuildGraph(context.Background(), syntheticCache(1000), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1057 || len(g.Relationships) != 3593 {
+		t.Errorf("Unexpected synthetic graph: %d nodes, %d relationships", len(g.Nodes), len(g.Relationships))
+	}
+	const golden = "c39fbf7e922e02371d5c3260606fb618c3b532a6732e346e41088badf5dbce8e"
+	if hash := graph.ContentHash(g); hash != golden {
+		t.Errorf("Synthetic graph changed: content hash %s, want %s", hash, golden)
+	}
+}
+
+// BenchmarkBuildGraph builds the graph of synthetic caches of growing size.
+func BenchmarkBuildGraph(b *testing.B) {
+	level := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(level)
+
+	for _, n := range []int{1_000, 10_000, 50_000} {
+		resourceCache := syntheticCache(n)
+		b.Run(fmt.Sprintf("objects=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := graph.BuildGraph(context.Background(), resourceCache, uint64(i+1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestBuildGraph_LabelOrder verifies labels render in labels.Set order, which sorts whole "key=value" pairs.
+func TestBuildGraph_LabelOrder(t *testing.T) {
+	podLabels := map[string]string{"a": "1", "a.b": "2", "a-b": "3", "b": "0"}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-uid", Labels: podLabels}})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Nodes[0].Properties[graph.LabelsProperty], labels.Set(podLabels).String(); got != want {
+		t.Errorf("labels = %q, want %q", got, want)
+	}
+}
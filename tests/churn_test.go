@@ -0,0 +1,94 @@
+package main_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/churn"
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestChurn_RatesAndSummary verifies rates are ordered by volume and summarized once per window.
+func TestChurn_RatesAndSummary(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	// entries are recorded under the hook's lock, as Run logs from its own goroutine
+	hook := logtest.NewLocal(logger)
+	logged := make(chan struct{}, 1)
+	logger.AddHook(signalHook(logged))
+
+	tracker := churn.New(200 * time.Millisecond)
+	tracker.Logger = logger
+	for i := 0; i < 1200; i++ {
+		tracker.Observe("ConfigMap", "update")
+	}
+	for i := 0; i < 3; i++ {
+		tracker.Observe("Pod", "add")
+	}
+	tracker.Observe("Pod", churn.Noop)
+
+	rates := tracker.Rates()
+	if len(rates) != 3 || rates[0].Kind != "ConfigMap" || rates[0].Count != 1200 {
+		t.Fatalf("Unexpected rates %+v, want ConfigMap updates first", rates)
+	}
+	// the first bucket covers a tenth of the window
+	if got := rates[0].PerSecond; got < 59_999 || got > 60_001 {
+		t.Errorf("ConfigMap update rate = %v/s, want 60000/s", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracker.Run(ctx)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+	}
+	cancel()
+	<-done
+	entries := hook.AllEntries()
+	if len(entries) == 0 || !strings.Contains(entries[0].Message, "ConfigMap 1.2k updates, Pod 3 adds, Pod 1 no-op updates") {
+		t.Errorf("Unexpected summary: %+v", entries)
+	}
+}
+
+// signalHook signals ch, without blocking, for every entry logged.
+type signalHook chan struct{}
+
+func (h signalHook) Levels() []log.Level { return log.AllLevels }
+
+func (h signalHook) Fire(*log.Entry) error {
+	select {
+	case h <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// TestChurn_NoopUpdatesCounted verifies upserts with an unchanged resourceVersion are counted.
+func TestChurn_NoopUpdatesCounted(t *testing.T) {
+	before := testutil.ToFloat64(metrics.CacheNoopUpdates.WithLabelValues("Node"))
+	c := cache.NewResourceCache()
+	handler := c.AddEventHandler("Node")
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "churn-node", ResourceVersion: "5"}}
+	handler.OnAdd(node, false)
+	handler.OnUpdate(node, node.DeepCopy())
+	handler.OnUpdate(node, node.DeepCopy())
+
+	if got := testutil.ToFloat64(metrics.CacheNoopUpdates.WithLabelValues("Node")) - before; got != 2 {
+		t.Errorf("cache_noop_updates_total{kind=Node} grew by %v, want 2", got)
+	}
+}
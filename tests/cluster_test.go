@@ -0,0 +1,141 @@
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/config"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestMergeClusters verifies partitions are stamped with their cluster and anchored by pseudo-nodes.
+func TestMergeClusters(t *testing.T) {
+	parts := []graph.ClusterGraph{}
+	for _, name := range []string{"east", "west"} {
+		resourceCache := cache.NewResourceCache()
+		// same object name in both clusters must not collide
+		resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+		resourceCache.Upsert(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+		})
+		resourceCache.Upsert(defaultServiceAccount("default"))
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 7)
+		if err != nil {
+			t.Fatalf("BuildGraph failed: %v", err)
+		}
+		parts = append(parts, graph.ClusterGraph{Cluster: name, Graph: g})
+	}
+
+	merged := graph.MergeClusters(7, parts)
+
+	// 2 clusters x (pseudo-node + node + pod + service account)
+	if len(merged.Nodes) != 8 {
+		t.Fatalf("Expected 8 nodes, got %d", len(merged.Nodes))
+	}
+	nodeKeys := make(map[graph.GraphEntityKey]bool)
+	for _, n := range merged.Nodes {
+		if n.Key.Cluster == "" {
+			t.Errorf("Node %+v has no cluster", n.Key)
+		}
+		nodeKeys[n.Key] = true
+	}
+	if !nodeKeys[graph.GraphEntityKey{Kind: graph.ClusterKind, Name: "east", Cluster: "east"}] {
+		t.Errorf("Missing Cluster pseudo-node for east")
+	}
+
+	// 2 clusters x (SCHEDULED_ON + RUNS_AS + 3 IN_CLUSTER)
+	if len(merged.Relationships) != 10 {
+		t.Fatalf("Expected 10 relationships, got %d", len(merged.Relationships))
+	}
+	for _, rel := range merged.Relationships {
+		if rel.Source.Cluster != rel.Target.Cluster {
+			t.Errorf("Relationship crosses clusters: %+v -> %+v", rel.Source, rel.Target)
+		}
+		if !nodeKeys[rel.Source] || !nodeKeys[rel.Target] {
+			t.Errorf("Relationship endpoint missing from merged nodes: %+v -> %+v", rel.Source, rel.Target)
+		}
+	}
+}
+
+// TestMergeClusters_UnsyncedKinds verifies unsynced kinds are reported per cluster.
+func TestMergeClusters_UnsyncedKinds(t *testing.T) {
+	partial := graph.Graph{GraphRevision: 3}
+	partial.Meta().UnsyncedKinds = []string{"Secret"}
+	merged := graph.MergeClusters(3, []graph.ClusterGraph{
+		{Cluster: "east", Graph: graph.Graph{GraphRevision: 3}},
+		{Cluster: "west", Graph: partial},
+	})
+	if merged.Metadata == nil || !slices.Equal(merged.Metadata.UnsyncedKinds, []string{"west/Secret"}) {
+		t.Errorf("Expected unsynced kinds [west/Secret], got %+v", merged.Metadata)
+	}
+}
+
+// TestConfigLoad_Clusters verifies cluster name defaulting and duplicate detection.
+func TestConfigLoad_Clusters(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	if err := os.WriteFile(valid, []byte(`
+clusters:
+  - context: prod-eu
+  - kubeconfig: /etc/satellite/staging.kubeconfig
+  - name: dev
+    context: kind-dev
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(valid)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := []string{"prod-eu", "staging", "dev"}
+	for i, cl := range cfg.Clusters {
+		if cl.Name != want[i] {
+			t.Errorf("clusters[%d].Name = %q, want %q", i, cl.Name, want[i])
+		}
+	}
+
+	dup := filepath.Join(dir, "dup.yaml")
+	if err := os.WriteFile(dup, []byte(`
+clusters:
+  - context: a
+  - name: a
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(dup); err == nil {
+		t.Error("Expected error for duplicate cluster names, got nil")
+	}
+}
+
+// TestStampClusterName verifies the explicit "unknown" default and the optional node property.
+func TestStampClusterName(t *testing.T) {
+	g := graph.Graph{Nodes: []graph.GraphNode{
+		{Key: graph.GraphEntityKey{Kind: "Node", Name: "n1"}},
+		{Key: graph.GraphEntityKey{Kind: "Node", Name: "n2", Cluster: "east"}, Properties: map[string]string{}},
+	}}
+
+	graph.StampClusterName(&g, "", false)
+	if g.Metadata.ClusterName != graph.UnknownCluster {
+		t.Errorf("ClusterName = %q, want %q", g.Metadata.ClusterName, graph.UnknownCluster)
+	}
+	if _, ok := g.Nodes[1].Properties[graph.ClusterProperty]; ok {
+		t.Error("Cluster property stamped without asProperty")
+	}
+
+	graph.StampClusterName(&g, "prod", true)
+	if got := g.Nodes[0].Properties[graph.ClusterProperty]; got != "prod" {
+		t.Errorf("Node n1 cluster property = %q, want prod", got)
+	}
+	if got := g.Nodes[1].Properties[graph.ClusterProperty]; got != "east" {
+		t.Errorf("Node n2 cluster property = %q, want its key's cluster east", got)
+	}
+}
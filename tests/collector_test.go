@@ -0,0 +1,89 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestAddCollector_InCluster verifies the Collector node observes the cluster and links to its own pod.
+func TestAddCollector_InCluster(t *testing.T) {
+	t.Setenv("POD_NAME", "satellite-0")
+	t.Setenv("POD_NAMESPACE", "monitoring")
+
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "satellite-0", Namespace: "monitoring"}})
+	resourceCache.Upsert(defaultServiceAccount("monitoring"))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 3)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+
+	collector := graph.CollectorFromEnv("v1.2.3")
+	collector.Kinds = []string{"Pod", "Node"}
+	graph.AddCollector(&g, collector, "prod")
+
+	self := graph.GraphEntityKey{Name: "satellite-0", Namespace: "monitoring", Kind: graph.CollectorKind}
+	clusterKey := graph.GraphEntityKey{Name: "prod", Kind: graph.ClusterKind}
+	podKey := graph.GraphEntityKey{Name: "satellite-0", Namespace: "monitoring", Kind: "Pod"}
+
+	var found bool
+	for _, n := range g.Nodes {
+		if n.Key == self {
+			found = true
+			if n.Properties["version"] != "v1.2.3" || n.Properties["inCluster"] != "true" || n.Properties["kinds"] != "Node,Pod" {
+				t.Errorf("Unexpected Collector properties: %v", n.Properties)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("Collector node %+v missing", self)
+	}
+
+	rels := make(map[string]graph.GraphEntityKey)
+	for _, rel := range g.Relationships {
+		if rel.Source == self {
+			rels[rel.RelationshipType] = rel.Target
+		}
+	}
+	if rels["OBSERVES"] != clusterKey {
+		t.Errorf("OBSERVES target = %+v, want %+v", rels["OBSERVES"], clusterKey)
+	}
+	if rels["RUNS_AS"] != podKey {
+		t.Errorf("RUNS_AS target = %+v, want %+v", rels["RUNS_AS"], podKey)
+	}
+	if dangling := graph.DanglingRelationships(g); dangling != 0 {
+		t.Errorf("Expected no dangling relationships, got %d", dangling)
+	}
+}
+
+// TestAddCollector_OutOfCluster verifies the hostname identity and no RUNS_AS edge.
+func TestAddCollector_OutOfCluster(t *testing.T) {
+	t.Setenv("POD_NAME", "")
+
+	collector := graph.CollectorFromEnv("dev")
+	if collector.InCluster || collector.Name == "" {
+		t.Fatalf("Expected hostname identity, got %+v", collector)
+	}
+
+	merged := graph.MergeClusters(1, []graph.ClusterGraph{{Cluster: "east"}, {Cluster: "west"}})
+	graph.AddCollector(&merged, collector, "federated")
+
+	observes := 0
+	for _, rel := range merged.Relationships {
+		switch rel.RelationshipType {
+		case "OBSERVES":
+			observes++
+		case "RUNS_AS":
+			t.Errorf("Unexpected RUNS_AS outside a cluster: %+v", rel)
+		}
+	}
+	if observes != 2 {
+		t.Errorf("Expected OBSERVES to both clusters, got %d", observes)
+	}
+}
@@ -0,0 +1,105 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// configMapUsingPod returns a pod using the ConfigMaps settings and flags, and some missing ones, in every way a
+// pod can.
+func configMapUsingPod() *corev1.Pod {
+	optional := true
+	ref := func(name string) corev1.LocalObjectReference { return corev1.LocalObjectReference{Name: name} }
+	keyRef := func(name, key string, optional *bool) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: ref(name), Key: key, Optional: optional}}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid"},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "default",
+			Volumes: []corev1.Volume{
+				{Name: "settings", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: ref("settings")}}},
+				{Name: "bundle", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: ref("ca-bundle"), Optional: &optional}},
+				}}}},
+			},
+			InitContainers: []corev1.Container{{Name: "migrate", Env: []corev1.EnvVar{{Name: "SCHEMA", ValueFrom: keyRef("flags", "schema", nil)}}}},
+			Containers: []corev1.Container{{
+				Name: "web",
+				Env: []corev1.EnvVar{
+					{Name: "LEVEL", ValueFrom: keyRef("flags", "log-level", nil)},
+					{Name: "MODE", ValueFrom: keyRef("settings", "mode", nil)},
+					{Name: "TUNING", ValueFrom: keyRef("tuning", "gc", &optional)},
+				},
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: ref("flags")}}},
+			}},
+			EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name:    "debug",
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: ref("debug")}}},
+			}}},
+		},
+	}
+}
+
+// TestConfigMaps_PodUsage verifies one relationship per ConfigMap a pod uses, MOUNTS for volumes and USES_CONFIG
+// otherwise, with every usage and key, that optional references to absent ConfigMaps are kept, and that only
+// required ones are reported missing.
+func TestConfigMaps_PodUsage(t *testing.T) {
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		objects := []runtime.Object{
+			configMapUsingPod(),
+			defaultServiceAccount("shop"),
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "shop", UID: "settings-uid"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "flags", Namespace: "shop", UID: "flags-uid"}},
+		}
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "ServiceAccount", "ConfigMap"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		type use struct{ relType, usage, key, missing string }
+		got := make(map[string]use)
+		for _, r := range g.Relationships {
+			if r.Target.Kind != "ConfigMap" {
+				continue
+			}
+			if _, dup := got[r.Target.Name]; dup {
+				t.Errorf("trim=%v: duplicate relationship to %s", trim, r.Target.Name)
+			}
+			got[r.Target.Name] = use{r.RelationshipType, r.Properties[graph.UsageProperty], r.Properties[graph.KeyProperty], r.Properties[graph.MissingTargetProperty]}
+		}
+		want := map[string]use{
+			"settings":  {"MOUNTS", "envKey,volume", "mode", ""},
+			"ca-bundle": {"MOUNTS", "volume", "", ""},
+			"flags":     {"USES_CONFIG", "envFrom,envKey", "log-level,schema", ""},
+			"tuning":    {"USES_CONFIG", "envKey", "gc", ""},
+			"debug":     {"USES_CONFIG", "envFrom", "", "true"},
+		}
+		if len(got) != len(want) {
+			t.Errorf("trim=%v: relationships to ConfigMaps = %v, want %v", trim, got, want)
+		}
+		for name, w := range want {
+			if got[name] != w {
+				t.Errorf("trim=%v: %s = %+v, want %+v", trim, name, got[name], w)
+			}
+		}
+		if g.Metadata == nil || g.Metadata.MissingDependencies != 1 {
+			t.Errorf("trim=%v: metadata = %+v, want 1 missing dependency", trim, g.Metadata)
+		}
+	}
+}
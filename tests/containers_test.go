@@ -0,0 +1,94 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podWithStatuses builds the graph of a pod with the given container
+// statuses and returns the pod's properties.
+func podWithStatuses(t *testing.T, statuses ...corev1.ContainerStatus) map[string]string {
+	t.Helper()
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: statuses},
+	})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range g.Nodes {
+		if node.Key == (graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: "web"}) {
+			return node.Properties
+		}
+	}
+	t.Fatalf("Pod missing from %v", g.Nodes)
+	return nil
+}
+
+// TestContainerStatuses_OOMKilled verifies a running container whose last termination was an OOM
+// kill marks the pod oomKilled, next to a waiting and a completed container.
+func TestContainerStatuses_OOMKilled(t *testing.T) {
+	oomAt := metav1.NewTime(time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC))
+	crashAt := metav1.NewTime(time.Date(2024, 5, 1, 14, 20, 0, 0, time.UTC))
+	props := podWithStatuses(t,
+		corev1.ContainerStatus{
+			Name: "app", RestartCount: 3,
+			State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: oomAt}},
+		},
+		corev1.ContainerStatus{
+			Name: "sidecar", RestartCount: 1,
+			State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1, FinishedAt: crashAt}},
+		},
+		corev1.ContainerStatus{
+			Name:  "migrate",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+		},
+	)
+
+	want := map[string]string{
+		graph.OOMKilledProperty:                    "true",
+		"restartCount":                             "4",
+		"lastTerminated":                           "2024-05-01T14:30:00Z",
+		"containers.app.state":                     "running",
+		"containers.app.restartCount":              "3",
+		"containers.app.lastTerminated.reason":     "OOMKilled",
+		"containers.app.lastTerminated.exitCode":   "137",
+		"containers.app.lastTerminated.finishedAt": "2024-05-01T14:30:00Z",
+		"containers.sidecar.state":                 "waiting",
+		"containers.sidecar.reason":                "CrashLoopBackOff",
+		"containers.sidecar.lastTerminated.reason": "Error",
+		"containers.migrate.state":                 "terminated",
+		"containers.migrate.reason":                "Completed",
+		"containers.migrate.exitCode":              "0",
+	}
+	for key, value := range want {
+		if props[key] != value {
+			t.Errorf("%s = %q, want %q", key, props[key], value)
+		}
+	}
+	if _, ok := props["containers.migrate.lastTerminated.reason"]; ok {
+		t.Error("Container without a last termination got lastTerminated properties")
+	}
+}
+
+// TestContainerStatuses_Healthy verifies a pod that never restarted isn't marked oomKilled.
+func TestContainerStatuses_Healthy(t *testing.T) {
+	props := podWithStatuses(t, corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}})
+	if _, ok := props[graph.OOMKilledProperty]; ok {
+		t.Error("Healthy pod marked oomKilled")
+	}
+	if props["restartCount"] != "0" || props["lastTerminated"] != "" {
+		t.Errorf("Unexpected restart properties: restartCount=%q lastTerminated=%q", props["restartCount"], props["lastTerminated"])
+	}
+}
@@ -0,0 +1,122 @@
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/cost"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// costGraph builds the graph of a Karpenter spot node of type m5.large and a
+// node without an instance type.
+func costGraph(t *testing.T) graph.Graph {
+	t.Helper()
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "node-a-uid", Labels: map[string]string{
+			"node.kubernetes.io/instance-type": "m5.large",
+			"topology.kubernetes.io/region":    "eu-west-1",
+			"topology.kubernetes.io/zone":      "eu-west-1a",
+			"karpenter.sh/capacity-type":       "spot",
+			"karpenter.sh/nodepool":            "default",
+		}},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceEphemeralStorage: resource.MustParse("90Gi"),
+				corev1.ResourcePods:             resource.MustParse("100"),
+			},
+		},
+	})
+	resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b", UID: "node-b-uid"}})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// nodeProperties returns the properties of the named Node.
+func nodeProperties(g graph.Graph, name string) map[string]string {
+	for _, node := range g.Nodes {
+		if node.Key.Kind == "Node" && node.Key.Name == name {
+			return node.Properties
+		}
+	}
+	return nil
+}
+
+// TestNodeInstanceProperties verifies the instance labels and storage and pod capacity become Node properties.
+func TestNodeInstanceProperties(t *testing.T) {
+	props := nodeProperties(costGraph(t), "node-a")
+	want := map[string]string{
+		"instanceType":                         "m5.large",
+		"region":                               "eu-west-1",
+		"zone":                                 "eu-west-1a",
+		"capacityType":                         "spot",
+		"nodePool":                             "default",
+		"status.capacity.ephemeral-storage":    "100Gi",
+		"status.allocatable.ephemeral-storage": "90Gi",
+		"status.capacity.pods":                 "110",
+		"status.allocatable.pods":              "100",
+	}
+	for key, value := range want {
+		if props[key] != value {
+			t.Errorf("%s = %q, want %q", key, props[key], value)
+		}
+	}
+}
+
+// TestCostTable_StampAndReload verifies costs are stamped by instance type and that a broken
+// reload keeps the previous costs.
+func TestCostTable_StampAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.yaml")
+	if err := os.WriteFile(path, []byte("m5.large: 0.096\nm5.xlarge: 0.192\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := cost.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := costGraph(t)
+	table.Stamp(&g)
+	if got := nodeProperties(g, "node-a")[graph.HourlyCostProperty]; got != "0.096" {
+		t.Errorf("cost.hourly = %q, want 0.096", got)
+	}
+	if _, ok := nodeProperties(g, "node-b")[graph.HourlyCostProperty]; ok {
+		t.Error("Node without an instance type got a cost")
+	}
+
+	if err := os.WriteFile(path, []byte("m5.large: cheap\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Reload(); err == nil {
+		t.Error("Expected an error for a non-numeric cost")
+	}
+	if hourly, _ := table.Hourly("m5.large"); hourly != 0.096 {
+		t.Errorf("Failed reload replaced the costs: %v", hourly)
+	}
+
+	if err := os.WriteFile(path, []byte("m5.large: 0.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	g = costGraph(t)
+	table.Stamp(&g)
+	if got := nodeProperties(g, "node-a")[graph.HourlyCostProperty]; got != "0.1" {
+		t.Errorf("cost.hourly after reload = %q, want 0.1", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/admin"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/pipeline"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var certificates = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+// certificate returns a cert-manager Certificate owned by the Ingress web, Ready as given.
+func certificate(name, ready string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]interface{}{
+			"name": name, "namespace": "shop", "uid": name + "-uid", "resourceVersion": "1",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{"secretName": name + "-tls"},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Issuing", "status": "False"},
+			map[string]interface{}{"type": "Ready", "status": ready, "reason": "Ready"},
+		}},
+	}}
+	u.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "web", UID: "ingress-uid"}})
+	return u
+}
+
+// TestExtraResources_Parse verifies --extra-resources accepts group/version/resource and version/resource.
+func TestExtraResources_Parse(t *testing.T) {
+	gvrs, err := k8s.ParseResources("cert-manager.io/v1/certificates, v1/podtemplates,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gvrs) != 2 || gvrs[0] != certificates || gvrs[1] != (schema.GroupVersionResource{Version: "v1", Resource: "podtemplates"}) {
+		t.Errorf("Parsed %v", gvrs)
+	}
+	for _, bad := range []string{"certificates", "a/b/c/d", "cert-manager.io//certificates"} {
+		if _, err := k8s.ParseResources(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+// TestExtraResources_Resolve verifies resources are resolved to their kind and scope through discovery,
+// skipping unserved ones and kinds already watched.
+func TestExtraResources_Resolve(t *testing.T) {
+	disc := &fakediscovery.FakeDiscovery{Fake: &fake.NewSimpleClientset().Fake}
+	disc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "cert-manager.io/v1", APIResources: []metav1.APIResource{
+			{Name: "certificates", Kind: "Certificate", Namespaced: true},
+			{Name: "clusterissuers", Kind: "ClusterIssuer"},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+	}
+	kinds := k8s.ResolveResources(disc, []schema.GroupVersionResource{
+		certificates,
+		{Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers"},
+		{Group: "cert-manager.io", Version: "v1", Resource: "orders"},
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+	})
+	if len(kinds) != 2 || kinds[0].Kind != "Certificate" || kinds[0].ClusterScoped || !kinds[0].Dynamic ||
+		kinds[1].Kind != "ClusterIssuer" || !kinds[1].ClusterScoped {
+		t.Errorf("Resolved %+v", kinds)
+	}
+	if group := k8s.KindGroup("ClusterIssuer"); group != "cert-manager.io" {
+		t.Errorf("KindGroup(ClusterIssuer) = %q", group)
+	}
+}
+
+// TestExtraResources_Graph verifies custom resources are generic nodes with their labels and Ready
+// condition, OWNED_BY the owners they name, and that comparing updates leaves the cached ones intact.
+func TestExtraResources_Graph(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	old := certificate("web-cert", "False")
+	resourceCache.Upsert(old)
+	updated := certificate("web-cert", "True")
+	updated.SetResourceVersion("2")
+	resourceCache.Upsert(updated)
+	if old.GetResourceVersion() != "1" {
+		t.Errorf("Comparing updates modified the cached object: resourceVersion %q", old.GetResourceVersion())
+	}
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1 {
+		t.Fatalf("Nodes = %+v", g.Nodes)
+	}
+	n := g.Nodes[0]
+	if n.Key != (graph.GraphEntityKey{Name: "web-cert", Namespace: "shop", Kind: "Certificate", APIGroup: "cert-manager.io"}) {
+		t.Errorf("Key = %+v", n.Key)
+	}
+	want := map[string]string{"uid": "web-cert-uid", graph.LabelsProperty: "app=web", "apiVersion": "cert-manager.io/v1", "status.ready": "True", "status.readyReason": "Ready"}
+	for k, v := range want {
+		if n.Properties[k] != v {
+			t.Errorf("%s = %q, want %q", k, n.Properties[k], v)
+		}
+	}
+	if len(g.Relationships) != 1 || g.Relationships[0].RelationshipType != "OWNED_BY" ||
+		g.Relationships[0].Target != (graph.GraphEntityKey{Name: "web", Namespace: "shop", Kind: "Ingress", APIGroup: "networking.k8s.io"}) {
+		t.Errorf("Relationships = %+v", g.Relationships)
+	}
+}
+
+// TestExtraResources_Pipeline verifies a pipeline watches extra resources through the dynamic client.
+func TestExtraResources_Pipeline(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Resources = []*metav1.APIResourceList{{GroupVersion: "cert-manager.io/v1", APIResources: []metav1.APIResource{
+		{Name: "certificates", Kind: "Certificate", Namespaced: true},
+	}}}
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{certificates: "CertificateList"}, certificate("web-cert", "True"))
+
+	p, err := pipeline.New("", client, nil, dynClient, pipeline.Options{
+		Kinds:          map[string]bool{"Ingress": true},
+		SkipPreflight:  true,
+		ExtraResources: []schema.GroupVersionResource{certificates},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer p.Shutdown()
+	defer cancel()
+	// the pipeline signals changed once it has synced
+	changed := make(chan struct{}, 1)
+	p.Start(ctx, &admin.Health{}, changed)
+	select {
+	case <-changed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Pipeline not synced")
+	}
+	g, err := p.Build(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, n := range g.Nodes {
+		found = found || n.Key.Kind == "Certificate" && n.Key.Name == "web-cert"
+	}
+	if !found {
+		t.Errorf("Certificate not in the graph: %+v", g.Nodes)
+	}
+}
@@ -0,0 +1,95 @@
+package main_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/pkg/satellite"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// runCollector runs a collector on client with opts until the test ends and
+// returns it with its subscription, taken before the initial sync.
+func runCollector(t *testing.T, client *fake.Clientset, opts ...satellite.Option) (*satellite.Collector, <-chan satellite.Delta) {
+	t.Helper()
+	c, err := satellite.New(append(opts, satellite.WithClient(client))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deltas, _ := c.Subscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	return c, deltas
+}
+
+// nextDelta returns the next delta, failing the test after a few seconds.
+func nextDelta(t *testing.T, deltas <-chan satellite.Delta) satellite.Delta {
+	t.Helper()
+	select {
+	case d, ok := <-deltas:
+		if !ok {
+			t.Fatal("Subscription closed")
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a delta")
+		return satellite.Delta{}
+	}
+}
+
+// TestCollector_Namespaces verifies a collector restricted to several namespaces leaves out the
+// objects, and Namespace objects, of the others, and streams later changes as chained deltas.
+func TestCollector_Namespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", UID: "shop-uid"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "ks-uid"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cart", Namespace: "shop", UID: "cart-uid"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system", UID: "dns-uid"}},
+	)
+	c, deltas := runCollector(t, client, satellite.WithNamespaces("shop", "default"), satellite.WithKinds("Pod", "Namespace"))
+
+	first := nextDelta(t, deltas)
+	var keys []string
+	for _, node := range first.AddedNodes {
+		keys = append(keys, node.Key.Kind+"/"+node.Key.Name)
+	}
+	slices.Sort(keys)
+	if want := []string{"Namespace/shop", "Pod/cart", "Pod/web"}; !slices.Equal(keys, want) {
+		t.Errorf("Nodes %v, want %v", keys, want)
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", UID: "api-uid"}}
+	if _, err := client.CoreV1().Pods("shop").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	next := nextDelta(t, deltas)
+	if next.FromRevision != first.ToRevision {
+		t.Errorf("Delta from revision %d doesn't follow %d", next.FromRevision, first.ToRevision)
+	}
+	if len(next.AddedNodes) != 1 || next.AddedNodes[0].Key.Name != "api" {
+		t.Errorf("Expected only the api pod added, got %+v", next.AddedNodes)
+	}
+	if got := c.Snapshot(); got.GraphRevision < next.ToRevision || len(got.Nodes) != 4 {
+		t.Errorf("Snapshot at revision %d with %d nodes, want %d with 4", got.GraphRevision, len(got.Nodes), next.ToRevision)
+	}
+}
+
+// TestCollector_UnknownKind verifies options are validated by New.
+func TestCollector_UnknownKind(t *testing.T) {
+	if _, err := satellite.New(satellite.WithClient(fake.NewSimpleClientset()), satellite.WithKinds("Pod", "Gadget")); err == nil {
+		t.Error("Expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,310 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// recordingSink collects emitted revisions.
+type recordingSink struct {
+	mu        sync.Mutex
+	revisions []uint64
+	emitted   chan struct{} // signalled after every emit
+}
+
+func (r *recordingSink) emit(_ context.Context, g graph.Graph) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revisions = append(r.revisions, g.GraphRevision)
+	select {
+	case r.signal() <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// signal returns the emit notification channel; r.mu must be held.
+func (r *recordingSink) signal() chan struct{} {
+	if r.emitted == nil {
+		r.emitted = make(chan struct{}, 1)
+	}
+	return r.emitted
+}
+
+// wait waits until n graphs have been emitted.
+func (r *recordingSink) wait(t *testing.T, n int) {
+	t.Helper()
+	r.mu.Lock()
+	ch := r.signal()
+	r.mu.Unlock()
+	waitOn(t, ch, func() bool { return len(r.snapshot()) >= n })
+}
+
+func (r *recordingSink) snapshot() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]uint64(nil), r.revisions...)
+}
+
+// TestQueue_MinEmitInterval verifies graphs arriving within the interval are held and the newest wins.
+func TestQueue_MinEmitInterval(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, 200*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	queue.Submit(graph.Graph{GraphRevision: 1})
+	sink.wait(t, 1)
+
+	// within the interval: 2 is held, then replaced by 3
+	queue.Submit(graph.Graph{GraphRevision: 2})
+	queue.Submit(graph.Graph{GraphRevision: 3})
+
+	if got := sink.snapshot(); len(got) != 1 {
+		t.Fatalf("Emitted %v before the interval elapsed, want only [1]", got)
+	}
+	sink.wait(t, 2)
+
+	if got := sink.snapshot(); got[1] != 3 {
+		t.Errorf("Second emit was revision %d, want newest revision 3", got[1])
+	}
+	stats := queue.Stats()
+	if stats.Held < 1 || stats.Merged < 1 || stats.Emitted != 2 {
+		t.Errorf("Unexpected stats %+v, want Held>=1 Merged>=1 Emitted=2", stats)
+	}
+}
+
+// TestQueue_FlushBypassesInterval verifies the final flush emits immediately.
+func TestQueue_FlushBypassesInterval(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, time.Hour)
+
+	queue.Submit(graph.Graph{GraphRevision: 1})
+	queue.Submit(graph.Graph{GraphRevision: 2})
+	if err := queue.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got := sink.snapshot(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Flush emitted %v, want [2]", got)
+	}
+}
+
+// waitOn waits until cond holds, checking it whenever ch is signalled, for
+// up to a second.
+func waitOn(t *testing.T, ch <-chan struct{}, cond func() bool) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for !cond() {
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatal("Timed out waiting for condition")
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a second passes. It is for state no
+// event signals, such as counters; prefer waitOn where there is an event.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestEmitGraph_ClusterFilename verifies the cluster name is part of the emitted file name.
+func TestEmitGraph_ClusterFilename(t *testing.T) {
+	dir := t.TempDir()
+	g := graph.Graph{GraphRevision: 1}
+	graph.StampClusterName(&g, "prod/eu 1", false)
+
+	if err := emitter.EmitGraph(context.Background(), g, dir); err != nil {
+		t.Fatalf("EmitGraph failed: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "graph-prod-eu-1-*.json"))
+	if len(matches) != 1 {
+		entries, _ := os.ReadDir(dir)
+		t.Fatalf("Expected one graph-prod-eu-1-<ts>.json file, found %v", entries)
+	}
+}
+
+// TestFileSink_RetentionAndLatest verifies old files are pruned and latest.json tracks the newest graph.
+func TestFileSink_RetentionAndLatest(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"graph-20250101-000001.json", "graph-20250101-000002.json", "graph-20250101-000003.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := emitter.FileSink{Dir: dir, Retain: 2, WriteLatest: true}
+	if err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 9}); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	files, err := emitter.ListGraphFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "graph-20250101-000003.json" {
+		t.Errorf("Expected the newest 2 files to remain, got %v", files)
+	}
+	latest, err := os.ReadFile(filepath.Join(dir, emitter.LatestFilename))
+	if err != nil {
+		t.Fatalf("latest.json missing: %v", err)
+	}
+	if !strings.Contains(string(latest), `"graphRevision": 9`) {
+		t.Errorf("latest.json does not contain revision 9: %s", latest)
+	}
+}
+
+// TestNamespaceSink verifies one directory per namespace and tombstones for vanished namespaces.
+func TestNamespaceSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := &emitter.NamespaceSink{Base: emitter.FileSink{Dir: dir}, Tombstones: true}
+
+	if err := sink.Emit(context.Background(), fixtureGraph()); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	for _, ns := range []string{"team-a", "team-b"} {
+		files, _ := emitter.ListGraphFiles(filepath.Join(dir, ns))
+		if len(files) != 1 {
+			t.Errorf("Expected 1 graph file for %s, got %v", ns, files)
+		}
+	}
+
+	// team-b disappears
+	g := graph.Subgraph(fixtureGraph(), graph.Filter{Namespaces: []string{"team-a", ""}})
+	if err := sink.Emit(context.Background(), g); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "team-b", emitter.TombstoneFilename)); err != nil {
+		t.Errorf("Expected tombstone for team-b: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "team-a", emitter.TombstoneFilename)); !os.IsNotExist(err) {
+		t.Errorf("Unexpected tombstone for team-a")
+	}
+}
+
+// TestSpaceGuard verifies early retention cleanup, the skip error and the degraded transitions.
+func TestSpaceGuard(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"graph-20250101-000001.json", "graph-20250101-000002.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var avail uint64 = 10
+	var transitions []bool
+	guard := &emitter.SpaceGuard{OnChange: func(degraded bool, _ string) { transitions = append(transitions, degraded) }}
+	guard.SetAvailableFunc(func(string) (uint64, bool, error) { return avail, true, nil })
+	sink := emitter.FileSink{Dir: dir, Retain: 2, Guard: guard}
+
+	err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 1})
+	if !errors.Is(err, emitter.ErrInsufficientSpace) {
+		t.Fatalf("Expected ErrInsufficientSpace, got %v", err)
+	}
+	if guard.Skipped() != 1 {
+		t.Errorf("Skipped() = %d, want 1", guard.Skipped())
+	}
+	files, _ := emitter.ListGraphFiles(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected early cleanup to leave 1 file, got %v", files)
+	}
+
+	avail = 1 << 30
+	if err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 2}); err != nil {
+		t.Fatalf("Emit with free space failed: %v", err)
+	}
+	if len(transitions) != 2 || !transitions[0] || transitions[1] {
+		t.Errorf("Degraded transitions = %v, want [true false]", transitions)
+	}
+}
+
+// TestSpaceGuard_KeepsLastGraph verifies early cleanup with --retain=1 never deletes the only graph
+// when the new one can't be written.
+func TestSpaceGuard_KeepsLastGraph(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "graph-20250101-000001.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	guard := &emitter.SpaceGuard{}
+	guard.SetAvailableFunc(func(string) (uint64, bool, error) { return 10, true, nil })
+	sink := emitter.FileSink{Dir: dir, Retain: 1, Guard: guard}
+
+	if err := sink.Emit(context.Background(), graph.Graph{GraphRevision: 1}); !errors.Is(err, emitter.ErrInsufficientSpace) {
+		t.Fatalf("Expected ErrInsufficientSpace, got %v", err)
+	}
+	if files, _ := emitter.ListGraphFiles(dir); len(files) != 1 {
+		t.Errorf("Expected the last graph to survive, got %v", files)
+	}
+}
+
+// TestQueue_Heartbeat verifies an idle queue re-emits the last graph marked as a heartbeat.
+func TestQueue_Heartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var emitted []graph.Graph
+	queue := emitter.NewQueue(func(_ context.Context, g graph.Graph) error {
+		mu.Lock()
+		defer mu.Unlock()
+		emitted = append(emitted, g)
+		return nil
+	}, 0)
+	queue.Heartbeat = 100 * time.Millisecond
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(emitted)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	// no heartbeat before anything was emitted; asserting that nothing
+	// happens is timer-based by nature
+	time.Sleep(150 * time.Millisecond)
+	if n := count(); n != 0 {
+		t.Fatalf("Emitted %d graphs before the first submit", n)
+	}
+
+	queue.Submit(graph.Graph{GraphRevision: 1})
+	waitOn(t, queue.Emitted(), func() bool { return count() >= 2 })
+	cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+	first, beat := emitted[0], emitted[1]
+	if first.Metadata == nil || first.Metadata.Heartbeat || first.Metadata.EmittedAt.IsZero() {
+		t.Errorf("Unexpected metadata on the regular emit: %+v", first.Metadata)
+	}
+	if beat.GraphRevision != 1 || !beat.Metadata.Heartbeat {
+		t.Errorf("Expected a heartbeat of revision 1, got revision %d with %+v", beat.GraphRevision, beat.Metadata)
+	}
+	if !beat.Metadata.EmittedAt.After(first.Metadata.EmittedAt) {
+		t.Errorf("Heartbeat emittedAt %v not after %v", beat.Metadata.EmittedAt, first.Metadata.EmittedAt)
+	}
+	if first.Metadata.Heartbeat {
+		t.Error("Heartbeat modified the metadata of the earlier emit")
+	}
+	if stats := queue.Stats(); stats.Heartbeats < 1 {
+		t.Errorf("Unexpected stats %+v, want Heartbeats>=1", stats)
+	}
+}
@@ -0,0 +1,103 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func endpointSlice(name, service string, addressType discoveryv1.AddressType, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name), Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+		AddressType: addressType,
+		Endpoints:   endpoints,
+	}
+}
+
+func podEndpoint(pod string, ready, terminating *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{"10.0.0.1"},
+		Conditions: discoveryv1.EndpointConditions{Ready: ready, Terminating: terminating},
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: pod},
+	}
+}
+
+// TestEndpoints_RoutesTo verifies Services ROUTES_TO the pods their EndpointSlices list, with readiness from
+// the endpoint conditions: a pod matching the selector but not ready is SELECTED yet routed to with
+// ready=false, and a Service without a selector still routes to its endpoints.
+func TestEndpoints_RoutesTo(t *testing.T) {
+	yes, no := true, false
+	labels := map[string]string{"app": "web"}
+	objects := []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"}, Spec: corev1.ServiceSpec{Selector: labels}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "shop", UID: "legacy"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-ready", Namespace: "shop", UID: "web-ready", Labels: labels}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-starting", Namespace: "shop", UID: "web-starting", Labels: labels}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-stopping", Namespace: "shop", UID: "web-stopping", Labels: labels}},
+		endpointSlice("web-v4", "web", discoveryv1.AddressTypeIPv4,
+			podEndpoint("web-ready", &yes, nil), podEndpoint("web-starting", &no, nil), podEndpoint("web-stopping", &no, &yes)),
+		// the same pods again, for the other address family
+		endpointSlice("web-v6", "web", discoveryv1.AddressTypeIPv6, podEndpoint("web-ready", nil, nil), podEndpoint("web-starting", &no, nil)),
+		endpointSlice("legacy-1", "legacy", discoveryv1.AddressTypeIPv4,
+			podEndpoint("web-ready", &yes, nil), discoveryv1.Endpoint{Addresses: []string{"192.0.2.1"}}),
+	}
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, r := range g.Relationships {
+			switch r.RelationshipType {
+			case "ROUTES_TO":
+				got = append(got, r.Source.Name+" ROUTES_TO "+r.Target.Name+" ready="+r.Properties["ready"]+" terminating="+r.Properties["terminating"])
+			case "SELECTS":
+				got = append(got, r.Source.Name+" SELECTS "+r.Target.Name)
+			}
+		}
+		sort.Strings(got)
+		want := []string{
+			"legacy ROUTES_TO web-ready ready=true terminating=",
+			"web ROUTES_TO web-ready ready=true terminating=",
+			"web ROUTES_TO web-starting ready=false terminating=",
+			"web ROUTES_TO web-stopping ready=false terminating=true",
+			"web SELECTS web-ready",
+			"web SELECTS web-starting",
+			"web SELECTS web-stopping",
+		}
+		if len(got) != len(want) {
+			t.Fatalf("trim=%v: relationships = %q, want %q", trim, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("trim=%v: relationship %d = %q, want %q", trim, i, got[i], want[i])
+			}
+		}
+
+		for _, n := range g.Nodes {
+			if n.Key.Name == "web-v4" {
+				if p := n.Properties; p["service"] != "web" || p["addressType"] != "IPv4" || p["endpoints"] != "3" || p["endpoints.ready"] != "1" {
+					t.Errorf("trim=%v: EndpointSlice properties = %v", trim, p)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestEvents_Nodes verifies events become nodes ABOUT their involved object, resolved by kind, namespace
+// and name, and leave the graph when deleted.
+func TestEvents_Nodes(t *testing.T) {
+	now := time.Now()
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid"}})
+	resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-uid"}})
+	resourceCache.Upsert(defaultServiceAccount("shop"))
+	sched := warningEvent("sched", "web-uid", corev1.EventTypeWarning, "FailedScheduling", 4, now.Add(-time.Minute))
+	resourceCache.Upsert(sched)
+	oom := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "node-1.oom", Namespace: "default", ResourceVersion: "1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Node", Name: "node-1", UID: "node-1"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "OOMKilling",
+		Message:        "Memory cgroup out of memory",
+		Count:          1,
+		LastTimestamp:  metav1.NewTime(now),
+	}
+	resourceCache.Upsert(oom)
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []graph.GraphNode
+	for _, n := range g.Nodes {
+		if n.Key.Kind == "Event" {
+			events = append(events, n)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("Event nodes = %v, want 2", events)
+	}
+	for _, n := range events {
+		if n.Key.Name == "sched" {
+			want := map[string]string{"type": "Warning", "reason": "FailedScheduling", "message": "FailedScheduling happened", "count": "4",
+				"lastTimestamp": now.Add(-time.Minute).UTC().Format(time.RFC3339)}
+			for k, v := range want {
+				if n.Properties[k] != v {
+					t.Errorf("sched %s = %q, want %q", k, n.Properties[k], v)
+				}
+			}
+		}
+	}
+	about := make(map[string]graph.GraphEntityKey)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "ABOUT" {
+			about[r.Source.Name] = r.Target
+		}
+	}
+	if about["sched"] != (graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: "web"}) || about["node-1.oom"] != (graph.GraphEntityKey{Kind: "Node", Name: "node-1"}) {
+		t.Errorf("ABOUT = %v", about)
+	}
+	if dangling := graph.DanglingRelationships(g); dangling != 0 {
+		t.Errorf("%d dangling relationships", dangling)
+	}
+
+	resourceCache.AddEventHandler("Event").OnDelete(sched)
+	if events := resourceCache.Snapshot().Events(); len(events) != 1 || events[0].Name != "node-1.oom" {
+		t.Errorf("Events after delete = %+v", events)
+	}
+}
+
+// TestEvents_PerObjectBound verifies only the last MaxEventsPerObject events of an object are kept,
+// dropping the one that occurred least recently.
+func TestEvents_PerObjectBound(t *testing.T) {
+	now := time.Now()
+	resourceCache := cache.NewResourceCache()
+	for i := cache.MaxEventsPerObject; i >= 0; i-- {
+		resourceCache.Upsert(warningEvent(fmt.Sprintf("ev-%d", i), "web-uid", corev1.EventTypeWarning, "Unhealthy", 1, now.Add(-time.Duration(i)*time.Minute)))
+	}
+	events := resourceCache.Snapshot().Events()
+	if len(events) != cache.MaxEventsPerObject {
+		t.Fatalf("Kept %d events, want %d", len(events), cache.MaxEventsPerObject)
+	}
+	for _, ev := range events {
+		if ev.Name == fmt.Sprintf("ev-%d", cache.MaxEventsPerObject) {
+			t.Errorf("Oldest event kept past the bound")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	_ "github.com/tthuwng/satellite/internal/metrics" // publishes the "satellite" var
+	"github.com/tthuwng/satellite/internal/runner"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// debugVars fetches /debug/vars and returns the "satellite" var.
+func debugVars(t *testing.T) map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		Satellite map[string]any `json:"satellite"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	return vars.Satellite
+}
+
+func TestExpvar_CountersMoveAfterActivity(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(emitter.Instrument("expvar-test", sink.emit), 0)
+	loop := runner.New(func(_ context.Context, revision uint64) (graph.Graph, error) {
+		return graph.Graph{GraphRevision: revision + 1000}, nil
+	}, queue, nil, nil)
+	if err := loop.BuildAndSubmit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	before := debugVars(t)
+	for _, key := range []string{
+		"graph_builds_total", "graph_revision", "emit_queue_pending", "suppressed_log_lines_total",
+	} {
+		if _, ok := before[key]; !ok {
+			t.Errorf("/debug/vars is missing satellite.%s", key)
+		}
+	}
+	if before["emit_queue_pending"] != 1.0 {
+		t.Errorf("emit_queue_pending = %v, want 1", before["emit_queue_pending"])
+	}
+
+	if err := loop.BuildAndSubmit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cache.NewResourceCache().AddEventHandler("ExpvarKind").OnAdd(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p"}}, false)
+	if err := queue.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := debugVars(t)
+
+	builds := func(vars map[string]any) float64 {
+		return vars["graph_builds_total"].(map[string]any)["success"].(float64)
+	}
+	if builds(after) != builds(before)+1 {
+		t.Errorf("graph_builds_total/success went from %v to %v", builds(before), builds(after))
+	}
+	if after["graph_revision"] != 1002.0 || after["emit_queue_pending"] != 0.0 {
+		t.Errorf("graph_revision = %v, emit_queue_pending = %v", after["graph_revision"], after["emit_queue_pending"])
+	}
+	if events, _ := after["informer_events_total"].(map[string]any); events["ExpvarKind/add"] != 1.0 {
+		t.Errorf("informer_events_total = %v, want ExpvarKind/add = 1", after["informer_events_total"])
+	}
+	emits := after["emit_duration_seconds"].(map[string]any)["expvar-test"].(map[string]any)
+	if emits["count"] != 1.0 {
+		t.Errorf("emit_duration_seconds/expvar-test count = %v, want 1", emits["count"])
+	}
+}
@@ -0,0 +1,118 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/fixtures"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	"k8s.io/client-go/informers"
+	cachepkg "k8s.io/client-go/tools/cache"
+)
+
+// TestFixtures_Deterministic verifies the same options generate the same graph and another seed a different one.
+func TestFixtures_Deterministic(t *testing.T) {
+	hash := func(seed int64) string {
+		g, err := graph.BuildGraph(context.Background(), fixtures.Generate(fixtures.ForObjects(500, seed)).Cache(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return graph.ContentHash(g)
+	}
+	if hash(7) != hash(7) {
+		t.Errorf("Same seed generated different graphs")
+	}
+	if hash(7) == hash(8) {
+		t.Errorf("Different seeds generated the same graph")
+	}
+}
+
+// TestFixtures_Write verifies both output formats hold every object with its apiVersion and kind.
+func TestFixtures_Write(t *testing.T) {
+	cluster := fixtures.Generate(fixtures.Options{Seed: 1, Namespaces: 2, Apps: 3, Nodes: 2})
+	var out bytes.Buffer
+	if err := cluster.Write(&out, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Kind  string `json:"kind"`
+		Items []struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Kind != "List" || len(list.Items) != len(cluster.Objects()) {
+		t.Fatalf("Expected a List of %d items, got %s of %d", len(cluster.Objects()), list.Kind, len(list.Items))
+	}
+	for _, item := range list.Items {
+		if item.APIVersion == "" || item.Kind == "" {
+			t.Errorf("Item without apiVersion or kind: %+v", item)
+		}
+	}
+
+	out.Reset()
+	if err := cluster.Write(&out, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if docs := strings.Count(out.String(), "---\n"); docs != len(cluster.Objects()) {
+		t.Errorf("Expected %d YAML documents, got %d", len(cluster.Objects()), docs)
+	}
+	if err := cluster.Write(&out, "toml"); err == nil {
+		t.Errorf("Unknown format accepted")
+	}
+}
+
+// TestFixtures_ChurnThroughInformers verifies churn applied to a fake clientset reaches a cache through
+// informers, leaving it equal to the cluster's current objects.
+func TestFixtures_ChurnThroughInformers(t *testing.T) {
+	cluster := fixtures.Generate(fixtures.Options{Seed: 3, Namespaces: 2, Apps: 4, Nodes: 2})
+	client := cluster.Clientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	resourceCache := cache.NewResourceCache()
+	var synced []cachepkg.InformerSynced
+	for _, wk := range k8s.WatchedKinds {
+		inf, _ := k8s.NewInformer(factory, wk.Kind)
+		if _, err := inf.AddEventHandler(resourceCache.AddEventHandler(wk.Kind)); err != nil {
+			t.Fatal(err)
+		}
+		synced = append(synced, inf.HasSynced)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	cachepkg.WaitForCacheSync(stop, synced...)
+
+	target := fixtures.ClientsetTarget(client)
+	for i := 0; i < 50; i++ {
+		if err := cluster.Step(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := resourceCache.Quiesce(ctx, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := graph.BuildGraph(context.Background(), cluster.Cache(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graph.ContentHash(got) != graph.ContentHash(want) {
+		t.Errorf("Cache fed by informers (%d nodes) differs from the churned cluster (%d nodes)", len(got.Nodes), len(want.Nodes))
+	}
+}
@@ -0,0 +1,74 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func versionedPod(name, rv string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name), ResourceVersion: rv}}
+}
+
+// TestResourceVersionFreshness verifies the cache tracks the highest resourceVersion per kind
+// through upserts and deletes, and counts only informer versions past it as bookmarks.
+func TestResourceVersionFreshness(t *testing.T) {
+	c := cache.NewResourceCache()
+	c.Upsert(versionedPod("a", "5"))
+	c.Upsert(versionedPod("b", "12"))
+	c.Upsert(versionedPod("a", "7"))
+	c.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cfg", Namespace: "shop", UID: "cfg", ResourceVersion: "not-a-number"}})
+	if rvs := c.ResourceVersions(); len(rvs) != 1 || rvs["Pod"] != "12" {
+		t.Errorf("ResourceVersions = %v, want Pod at 12", rvs)
+	}
+	c.Delete(versionedPod("b", "15"))
+	if rv := c.ResourceVersions()["Pod"]; rv != "15" {
+		t.Errorf("Pod resourceVersion after delete = %s, want 15", rv)
+	}
+
+	c.Bookmark("Pod", "15")
+	if bookmarks := c.Bookmarks(); len(bookmarks) != 0 {
+		t.Errorf("Bookmark at the current version recorded: %v", bookmarks)
+	}
+	before := time.Now()
+	c.Bookmark("Pod", "20")
+	if at := c.Bookmarks()["Pod"]; at.Before(before.Add(-time.Second)) || c.ResourceVersions()["Pod"] != "20" {
+		t.Errorf("Bookmark at 20: %v, resourceVersion %s", at, c.ResourceVersions()["Pod"])
+	}
+
+	g, err := graph.BuildGraph(context.Background(), c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Metadata == nil || g.Metadata.ResourceVersions["Pod"] != "20" || g.Metadata.Bookmarks["Pod"].IsZero() {
+		t.Fatalf("Graph metadata = %+v", g.Metadata)
+	}
+	data, err := json.Marshal(g.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"perKindResourceVersion":{"Pod":"20"}`) || !strings.Contains(string(data), `"bookmarks":{"Pod":`) {
+		t.Errorf("Metadata JSON = %s", data)
+	}
+
+	// a snapshot is unaffected by later progress
+	snap := c.Snapshot()
+	c.Upsert(versionedPod("c", "30"))
+	if rv := snap.ResourceVersions()["Pod"]; rv != "20" {
+		t.Errorf("Snapshot resourceVersion = %s, want 20", rv)
+	}
+
+	merged := graph.MergeClusters(1, []graph.ClusterGraph{{Cluster: "east", Graph: g}})
+	if merged.Metadata == nil || merged.Metadata.ResourceVersions["east/Pod"] != "20" || merged.Metadata.Bookmarks["east/Pod"].IsZero() {
+		t.Errorf("Merged metadata = %+v", merged.Metadata)
+	}
+}
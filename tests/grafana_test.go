@@ -0,0 +1,172 @@
+package main_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/server"
+)
+
+// Requests of the Grafana Node Graph API data source, as recorded from Grafana 10 with the data
+// source URL set to http://satellite:8080/grafana: the "Save & test" health check, then a panel
+// whose query string uses a multi-value namespace variable.
+const (
+	grafanaHealthRequest = "GET /grafana/api/health HTTP/1.1\r\n" +
+		"Host: satellite:8080\r\n" +
+		"User-Agent: Grafana/10.4.1\r\n" +
+		"Accept-Encoding: gzip\r\n\r\n"
+	grafanaFieldsRequest = "GET /grafana/api/graph/fields HTTP/1.1\r\n" +
+		"Host: satellite:8080\r\n" +
+		"User-Agent: Grafana/10.4.1\r\n" +
+		"Accept: application/json\r\n" +
+		"Accept-Encoding: gzip\r\n\r\n"
+	grafanaDataRequest = "GET /grafana/api/graph/data?namespace=%7Bshop%2Cbilling%7D&kind=Pod&kind=Deployment&kind=Job HTTP/1.1\r\n" +
+		"Host: satellite:8080\r\n" +
+		"User-Agent: Grafana/10.4.1\r\n" +
+		"Accept: application/json\r\n" +
+		"Accept-Encoding: gzip\r\n\r\n"
+)
+
+func grafanaGraph() graph.Graph {
+	key := func(kind, ns, name string) graph.GraphEntityKey {
+		return graph.GraphEntityKey{Kind: kind, Namespace: ns, Name: name}
+	}
+	deploy := key("Deployment", "shop", "web")
+	pod := key("Pod", "shop", "web-1")
+	return graph.Graph{
+		GraphRevision: 7,
+		Nodes: []graph.GraphNode{
+			{Key: deploy, Properties: map[string]string{"spec.replicas": "4", "status.readyReplicas": "3"}},
+			{Key: pod, Properties: map[string]string{"status.phase": "Running", "restartCount": "2", graph.WarningCountProperty: "5"}},
+			{Key: key("Pod", "shop", "web-2"), Properties: map[string]string{"status.phase": "Pending"}},
+			{Key: key("Job", "billing", "invoice"), Properties: map[string]string{"status.condition": "Failed"}},
+			{Key: key("Pod", "other", "ignored"), Properties: map[string]string{"status.phase": "Running"}},
+			{Key: graph.GraphEntityKey{Kind: "Node", Name: "node-1"}, Properties: map[string]string{}},
+		},
+		Relationships: []graph.GraphRelationship{
+			{Source: pod, Target: deploy, RelationshipType: "OWNED_BY"},
+			{Source: pod, Target: graph.GraphEntityKey{Kind: "Node", Name: "node-1"}, RelationshipType: "SCHEDULED_ON"},
+		},
+	}
+}
+
+// replay sends a recorded request to the test server and decodes the JSON response into v.
+func replay(t *testing.T, baseURL, recorded string, v any) {
+	t.Helper()
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(recorded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := http.NewRequest(req.Method, baseURL+req.RequestURI, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Header = req.Header
+	resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("%s: %d %s", req.RequestURI, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body := io.Reader(resp.Body)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		if body, err = gzip.NewReader(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		t.Fatalf("%s: %v", req.RequestURI, err)
+	}
+}
+
+// TestGrafanaNodeGraphAPI replays recorded Grafana requests and checks the responses conform to
+// what the data source expects: every declared field in every row with its declared type, arcs
+// summing to 1, and edges between listed nodes.
+func TestGrafanaNodeGraphAPI(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	if err := srv.Update(grafanaGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	var health map[string]any
+	replay(t, ts.URL, grafanaHealthRequest, &health)
+
+	var fields server.GrafanaFields
+	replay(t, ts.URL, grafanaFieldsRequest, &fields)
+	var data struct {
+		Nodes []map[string]any `json:"nodes"`
+		Edges []map[string]any `json:"edges"`
+	}
+	replay(t, ts.URL, grafanaDataRequest, &data)
+
+	conforms := func(frame string, rows []map[string]any, declared []server.GrafanaField) {
+		if len(declared) == 0 || declared[0].Name != "id" {
+			t.Errorf("%s fields don't start with id: %v", frame, declared)
+		}
+		for _, f := range declared {
+			for _, row := range rows {
+				var ok bool
+				switch f.Type {
+				case "string":
+					_, ok = row[f.Name].(string)
+				case "number":
+					_, ok = row[f.Name].(float64)
+				}
+				if !ok {
+					t.Errorf("%s row %v: field %s is not a %s", frame, row["id"], f.Name, f.Type)
+				}
+			}
+		}
+	}
+	conforms("nodes", data.Nodes, fields.Nodes)
+	conforms("edges", data.Edges, fields.Edges)
+
+	byID := make(map[string]map[string]any)
+	for _, node := range data.Nodes {
+		byID[node["id"].(string)] = node
+		var arcs float64
+		for name, v := range node {
+			if strings.HasPrefix(name, "arc__") {
+				arcs += v.(float64)
+			}
+		}
+		if math.Abs(arcs-1) > 1e-9 {
+			t.Errorf("Arcs of %s sum to %v", node["id"], arcs)
+		}
+	}
+	want := map[string][2]string{
+		"Deployment/shop/web": {"3/4 ready", ""},
+		"Pod/shop/web-1":      {"Running", "5 warnings, 2 restarts"},
+		"Pod/shop/web-2":      {"Pending", ""},
+		"Job/billing/invoice": {"Failed", ""},
+	}
+	if len(byID) != len(want) {
+		t.Errorf("Nodes = %v, want the pods, deployment and job of shop and billing", byID)
+	}
+	for id, stats := range want {
+		node, ok := byID[id]
+		if !ok {
+			t.Errorf("Node %s missing", id)
+			continue
+		}
+		if node["mainStat"] != stats[0] || node["secondaryStat"] != stats[1] {
+			t.Errorf("%s stats = %q, %q, want %q, %q", id, node["mainStat"], node["secondaryStat"], stats[0], stats[1])
+		}
+	}
+	if arc := byID["Deployment/shop/web"]["arc__healthy"]; arc != 0.75 {
+		t.Errorf("Deployment healthy arc = %v, want 0.75", arc)
+	}
+	// SCHEDULED_ON leads to a Node outside the query and is dropped
+	if len(data.Edges) != 1 || data.Edges[0]["source"] != "Pod/shop/web-1" || data.Edges[0]["target"] != "Deployment/shop/web" || data.Edges[0]["mainStat"] != "OWNED_BY" {
+		t.Errorf("Edges = %v", data.Edges)
+	}
+}
@@ -1,12 +1,14 @@
 package main_test
 
 import (
+	"context"
 	"testing"
 
-	"satellite/internal/cache"
-	"satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
@@ -23,7 +25,7 @@ func TestBuildGraph_Relationships(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{Name: "test-deploy", Namespace: ns, UID: apitypes.UID("deploy-uid")},
 		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}},
 	}
-	deployGraphKey := graph.GraphEntityKey{Kind: "Deployment", Namespace: ns, Name: "test-deploy"}
+	deployGraphKey := graph.GraphEntityKey{Kind: "Deployment", APIGroup: "apps", Namespace: ns, Name: "test-deploy"}
 
 	// ReplicaSet owned by Deployment
 	rs := &appsv1.ReplicaSet{
@@ -35,7 +37,7 @@ func TestBuildGraph_Relationships(t *testing.T) {
 		},
 		Spec: appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}},
 	}
-	rsGraphKey := graph.GraphEntityKey{Kind: "ReplicaSet", Namespace: ns, Name: "test-rs"}
+	rsGraphKey := graph.GraphEntityKey{Kind: "ReplicaSet", APIGroup: "apps", Namespace: ns, Name: "test-rs"}
 
 	// Pod owned by ReplicaSet, scheduled on Node, mounting ConfigMap
 	cmName := "test-cm"
@@ -84,15 +86,18 @@ func TestBuildGraph_Relationships(t *testing.T) {
 
 	// --- Build Graph ---
 	graphRevision := uint64(1)
-	graphData := graph.BuildGraph(resourceCache, graphRevision)
+	graphData, err := graph.BuildGraph(context.Background(), resourceCache, graphRevision)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	// --- Assertions ---
 	if len(graphData.Nodes) != 6 {
 		t.Fatalf("Expected 6 nodes, got %d", len(graphData.Nodes))
 	}
 	// Check relationship count before detailed check
-	if len(graphData.Relationships) != 5 { // Pod->RS, RS->Deploy, Pod->Node, Pod->CM, Svc->Pod
-		t.Fatalf("Expected 5 relationships, got %d. Relationships: %+v", len(graphData.Relationships), graphData.Relationships)
+	if len(graphData.Relationships) != 6 { // Pod->RS, RS->Deploy, Pod->Node, Pod->CM, Pod->SA, Svc->Pod
+		t.Fatalf("Expected 6 relationships, got %d. Relationships: %+v", len(graphData.Relationships), graphData.Relationships)
 	}
 
 	expectedRelationships := map[string]graph.GraphRelationship{
@@ -101,6 +106,7 @@ func TestBuildGraph_Relationships(t *testing.T) {
 		"pod-scheduled-on-node": {Source: podGraphKey, Target: nodeGraphKey, RelationshipType: "SCHEDULED_ON"},
 		"pod-mounts-cm":         {Source: podGraphKey, Target: cmGraphKey, RelationshipType: "MOUNTS"},
 		"svc-selects-pod":       {Source: svcGraphKey, Target: podGraphKey, RelationshipType: "SELECTS"},
+		"pod-runs-as-default":   {Source: podGraphKey, Target: graph.GraphEntityKey{Kind: "ServiceAccount", Namespace: ns, Name: "default"}, RelationshipType: "RUNS_AS"},
 	}
 
 	foundRelationships := make(map[string]bool)
@@ -142,3 +148,271 @@ func TestBuildGraph_Relationships(t *testing.T) {
 		}
 	}
 }
+
+// TestBuildGraph_StatefulSet verifies a pod owned by a StatefulSet is OWNED_BY it and the StatefulSet
+// carries its replica counts and governing Service.
+func TestBuildGraph_StatefulSet(t *testing.T) {
+	ns := "data"
+	replicas := int32(3)
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "kafka", Namespace: ns, UID: apitypes.UID("sts-uid")},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas, ServiceName: "kafka-headless",
+			Selector:            &metav1.LabelSelector{MatchLabels: map[string]string{"app": "kafka"}},
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+		},
+		Status: appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 2},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "kafka-0", Namespace: ns, UID: apitypes.UID("pod-uid"),
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: sts.Name, UID: sts.UID}},
+	}}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(sts)
+	resourceCache.Upsert(pod)
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	stsGraphKey := graph.GraphEntityKey{Kind: "StatefulSet", APIGroup: "apps", Namespace: ns, Name: "kafka"}
+	want := graph.GraphRelationship{Source: graph.GraphEntityKey{Kind: "Pod", Namespace: ns, Name: "kafka-0"}, Target: stsGraphKey, RelationshipType: "OWNED_BY", Revision: 1}
+	// OWNED_BY, then RUNS_AS default
+	if len(g.Relationships) != 2 || g.Relationships[0].Source != want.Source || g.Relationships[0].Target != want.Target || g.Relationships[0].RelationshipType != want.RelationshipType {
+		t.Fatalf("Relationships = %+v, want %+v and RUNS_AS", g.Relationships, want)
+	}
+	for _, n := range g.Nodes {
+		if n.Key != stsGraphKey {
+			continue
+		}
+		for key, value := range map[string]string{
+			"spec.replicas": "3", "status.readyReplicas": "2", "spec.serviceName": "kafka-headless",
+			"spec.podManagementPolicy": "Parallel", "spec.selector": "app=kafka",
+		} {
+			if n.Properties[key] != value {
+				t.Errorf("StatefulSet %s = %q, want %q", key, n.Properties[key], value)
+			}
+		}
+	}
+}
+
+// TestBuildGraph_DaemonSet verifies a pod owned by a DaemonSet is OWNED_BY it and the DaemonSet carries its
+// scheduling counts.
+func TestBuildGraph_DaemonSet(t *testing.T) {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fluent-bit", Namespace: "logging", UID: apitypes.UID("ds-uid")},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, CurrentNumberScheduled: 3, NumberReady: 2},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "fluent-bit-x7k", Namespace: "logging", UID: apitypes.UID("pod-uid"),
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: ds.Name, UID: ds.UID}},
+	}}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(ds)
+	resourceCache.Upsert(pod)
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	dsGraphKey := graph.GraphEntityKey{Kind: "DaemonSet", APIGroup: "apps", Namespace: "logging", Name: "fluent-bit"}
+	if len(g.Relationships) != 2 || g.Relationships[0].Target != dsGraphKey || g.Relationships[0].RelationshipType != "OWNED_BY" {
+		t.Fatalf("Relationships = %+v, want the pod OWNED_BY the DaemonSet", g.Relationships)
+	}
+	for _, n := range g.Nodes {
+		if n.Key != dsGraphKey {
+			continue
+		}
+		for key, value := range map[string]string{"status.desiredNumberScheduled": "3", "status.currentNumberScheduled": "3", "status.numberReady": "2"} {
+			if n.Properties[key] != value {
+				t.Errorf("DaemonSet %s = %q, want %q", key, n.Properties[key], value)
+			}
+		}
+	}
+}
+
+// TestBuildGraph_CancelledContext verifies a cancelled build is reported instead of returning a partial graph.
+func TestBuildGraph_CancelledContext(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cancel-node"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := graph.BuildGraph(ctx, resourceCache, 1); err == nil {
+		t.Fatal("Expected error from BuildGraph with cancelled context, got nil")
+	}
+}
+
+// TestBuildGraph_WorkloadProperties verifies nil replicas don't panic and selectors keep their matchExpressions.
+func TestBuildGraph_WorkloadProperties(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "web"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "api"}},
+			{Key: "canary", Operator: metav1.LabelSelectorOpDoesNotExist},
+		},
+	}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Selector: selector},
+	})
+	resourceCache.Upsert(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       appsv1.ReplicaSetSpec{Selector: selector},
+	})
+	resourceCache.Upsert(&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"}})
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"web":   "app=web,!canary,tier in (api,frontend)",
+		"web-1": "app=web,!canary,tier in (api,frontend)",
+		"bare":  "",
+	}
+	for _, n := range g.Nodes {
+		if got := n.Properties["spec.selector"]; got != want[n.Key.Name] {
+			t.Errorf("%s spec.selector = %q, want %q", n.Key.Name, got, want[n.Key.Name])
+		}
+		if got := n.Properties["spec.replicas"]; got != "" {
+			t.Errorf("%s spec.replicas = %q, want empty for nil replicas", n.Key.Name, got)
+		}
+	}
+}
+
+// TestBuildGraph_SelectorExpressions verifies expression-only and combined selectors render in full on every
+// kind with a LabelSelector.
+func TestBuildGraph_SelectorExpressions(t *testing.T) {
+	expressionOnly := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "environment", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "staging"}},
+	}}
+	combined := &metav1.LabelSelector{
+		MatchLabels:      map[string]string{"app": "worker"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "environment", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"dev"}}},
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "default", UID: apitypes.UID("uid-" + name)}
+	}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&appsv1.StatefulSet{ObjectMeta: meta("sts"), Spec: appsv1.StatefulSetSpec{Selector: expressionOnly}})
+	resourceCache.Upsert(&appsv1.DaemonSet{ObjectMeta: meta("ds"), Spec: appsv1.DaemonSetSpec{Selector: combined}})
+	resourceCache.Upsert(&batchv1.Job{ObjectMeta: meta("job"), Spec: batchv1.JobSpec{Selector: expressionOnly}})
+	resourceCache.Upsert(&appsv1.Deployment{ObjectMeta: meta("deploy"), Spec: appsv1.DeploymentSpec{Selector: combined}})
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"sts":    "environment in (prod,staging)",
+		"ds":     "app=worker,environment notin (dev)",
+		"job":    "environment in (prod,staging)",
+		"deploy": "app=worker,environment notin (dev)",
+	}
+	for _, n := range g.Nodes {
+		if got := n.Properties["spec.selector"]; got != want[n.Key.Name] {
+			t.Errorf("%s spec.selector = %q, want %q", n.Key.Name, got, want[n.Key.Name])
+		}
+	}
+}
+
+// TestBuildGraph_GenericOwners verifies every kind gets OWNED_BY relationships to its owners, kept
+// dangling for owner kinds that aren't watched, carrying the reference's flags.
+func TestBuildGraph_GenericOwners(t *testing.T) {
+	yes := true
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "app-config", Namespace: "shop", UID: "cm-uid",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "argoproj.io/v1alpha1", Kind: "Application", Name: "shop", UID: "app-uid", Controller: &yes, BlockOwnerDeletion: &yes}},
+	}})
+	resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-uid"}})
+	resourceCache.Upsert(defaultServiceAccount("kube-system"))
+	resourceCache.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "etcd-node-1", Namespace: "kube-system", UID: "mirror-uid",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: "node-uid", Controller: &yes}},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+	})
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owners := make(map[string]graph.GraphRelationship)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "OWNED_BY" {
+			owners[r.Source.Name] = r
+		}
+	}
+	app := owners["app-config"]
+	if app.Target != (graph.GraphEntityKey{Name: "shop", Namespace: "shop", Kind: "Application", APIGroup: "argoproj.io"}) ||
+		app.Properties["controller"] != "true" || app.Properties["blockOwnerDeletion"] != "true" {
+		t.Errorf("ConfigMap owner = %+v", app)
+	}
+	mirror := owners["etcd-node-1"]
+	if mirror.Target != (graph.GraphEntityKey{Name: "node-1", Kind: "Node"}) || mirror.Properties["controller"] != "true" {
+		t.Errorf("Mirror pod owner = %+v", mirror)
+	}
+	if _, ok := mirror.Properties["blockOwnerDeletion"]; ok {
+		t.Errorf("Unset blockOwnerDeletion reported: %v", mirror.Properties)
+	}
+	if dangling := graph.DanglingRelationships(g); dangling != 1 {
+		t.Errorf("%d dangling relationships, want the one to the Application", dangling)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := graph.GraphEntityKey{Kind: "Pod", Namespace: "ns", Name: "a"}
+	b := graph.GraphEntityKey{Kind: "Pod", Namespace: "ns", Name: "b"}
+	c := graph.GraphEntityKey{Kind: "Pod", Namespace: "ns", Name: "c"}
+	n := graph.GraphEntityKey{Kind: "Node", Name: "n"}
+	prev := graph.Graph{
+		GraphRevision: 1,
+		Nodes: []graph.GraphNode{
+			{Key: a, Properties: map[string]string{"phase": "Running"}, Revision: 1},
+			{Key: b, Properties: map[string]string{"phase": "Running"}, Revision: 1},
+			{Key: n, Revision: 1},
+		},
+		Relationships: []graph.GraphRelationship{
+			{Source: a, Target: n, RelationshipType: "SCHEDULED_ON", Revision: 1},
+			{Source: b, Target: n, RelationshipType: "SCHEDULED_ON", Revision: 1},
+		},
+	}
+	next := graph.Graph{
+		GraphRevision: 2,
+		Nodes: []graph.GraphNode{
+			{Key: a, Properties: map[string]string{"phase": "Running"}, Revision: 2},
+			{Key: c, Properties: map[string]string{"phase": "Pending"}, Revision: 2},
+			{Key: n, Properties: map[string]string{"ready": "true"}, Revision: 2},
+		},
+		Relationships: []graph.GraphRelationship{
+			{Source: a, Target: n, RelationshipType: "SCHEDULED_ON", Revision: 2},
+		},
+	}
+
+	d := graph.Diff(prev, next)
+	if d.FromRevision != 1 || d.ToRevision != 2 {
+		t.Errorf("revisions = %d->%d, want 1->2", d.FromRevision, d.ToRevision)
+	}
+	if len(d.AddedNodes) != 1 || d.AddedNodes[0].Key != c {
+		t.Errorf("AddedNodes = %+v, want [c]", d.AddedNodes)
+	}
+	if len(d.UpdatedNodes) != 1 || d.UpdatedNodes[0].Key != n {
+		t.Errorf("UpdatedNodes = %+v, want [n] (a only changed revision)", d.UpdatedNodes)
+	} else if keys := d.UpdatedNodes[0].ChangedKeys; len(keys) != 1 || keys[0] != "ready" {
+		t.Errorf("ChangedKeys = %v, want [ready]", keys)
+	}
+	if len(d.RemovedNodes) != 1 || d.RemovedNodes[0] != b {
+		t.Errorf("RemovedNodes = %+v, want [b]", d.RemovedNodes)
+	}
+	if len(d.AddedRelationships) != 0 || len(d.RemovedRelationships) != 1 || d.RemovedRelationships[0].Source != b {
+		t.Errorf("relationships: added %+v, removed %+v", d.AddedRelationships, d.RemovedRelationships)
+	}
+	if graph.Diff(next, next).Empty() != true {
+		t.Error("diff of a graph with itself is not empty")
+	}
+}
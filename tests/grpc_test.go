@@ -0,0 +1,118 @@
+package main_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	satellitev1 "github.com/tthuwng/satellite/api/satellite/v1"
+	"github.com/tthuwng/satellite/internal/server"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves srv over an in-memory bufconn listener.
+func newGRPCClient(t *testing.T, srv *server.Server) satellitev1.GraphServiceClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(srv.GRPCServerOptions()...)
+	srv.RegisterGRPC(gs)
+	go func() { _ = gs.Serve(ln) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return satellitev1.NewGraphServiceClient(conn)
+}
+
+func TestGRPC_GetGraph(t *testing.T) {
+	srv := server.New()
+	client := newGRPCClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.GetGraph(ctx, &satellitev1.GetGraphRequest{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before first build: err = %v, want Unavailable", err)
+	}
+
+	if err := srv.Update(fixtureGraph()); err != nil {
+		t.Fatal(err)
+	}
+	g, err := client.GetGraph(ctx, &satellitev1.GetGraphRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 4 || len(g.Relationships) != 3 || g.Revision != 3 {
+		t.Errorf("full graph: %d nodes, %d relationships, revision %d", len(g.Nodes), len(g.Relationships), g.Revision)
+	}
+
+	g, err = client.GetGraph(ctx, &satellitev1.GetGraphRequest{
+		Filter: &satellitev1.GraphFilter{Kinds: []string{"Pod"}, Namespaces: []string{"team-b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].Key.Name != "pod-b" {
+		t.Errorf("filtered graph nodes = %v, want [pod-b]", g.Nodes)
+	}
+}
+
+func TestGRPC_WatchGraph(t *testing.T) {
+	srv := server.New()
+	client := newGRPCClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	g := fixtureGraph()
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.WatchGraph(ctx, &satellitev1.WatchGraphRequest{
+		Filter: &satellitev1.GraphFilter{Namespaces: []string{"team-a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := resp.GetSnapshot(); snap == nil || len(snap.Nodes) != 2 {
+		t.Fatalf("first message = %v, want a snapshot of team-a's 2 nodes", resp)
+	}
+
+	// the server keeps the previous graph, so change a copy
+	g.GraphRevision++
+	g.Nodes = slices.Clone(g.Nodes)
+	g.Nodes[1].Properties = map[string]string{"labels": "app=a2"}
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := resp.GetDelta()
+	if d == nil || d.FromRevision != 3 || d.ToRevision != 4 {
+		t.Fatalf("second message = %v, want a 3->4 delta", resp)
+	}
+	if len(d.UpdatedNodes) != 1 || d.UpdatedNodes[0].Key.Name != "pod-a" {
+		t.Errorf("delta updated nodes = %v, want [pod-a]", d.UpdatedNodes)
+	}
+
+	srv.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("after Close: err = %v, want Unavailable", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+var handoffEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// handoffGraph returns a graph named by its builtAt, second seconds after handoffEpoch.
+func handoffGraph(revision uint64, second int) graph.Graph {
+	g := graph.Graph{GraphRevision: revision}
+	g.Meta().BuiltAt = handoffEpoch.Add(time.Duration(second) * time.Second)
+	return g
+}
+
+// readLatestComplete returns the revision of the graph OpenLatestComplete opens in dir.
+func readLatestComplete(t *testing.T, dir string) uint64 {
+	t.Helper()
+	f, err := emitter.OpenLatestComplete(dir)
+	if err != nil {
+		t.Fatalf("OpenLatestComplete failed: %v", err)
+	}
+	defer f.Close()
+	var g graph.Graph
+	if err := json.NewDecoder(f).Decode(&g); err != nil {
+		t.Fatalf("Complete graph file does not decode: %v", err)
+	}
+	return g.GraphRevision
+}
+
+// TestHandoff_Sequence steps through the writes of an emit and the deletes of retention, verifying a
+// reader only ever opens a complete set.
+func TestHandoff_Sequence(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := emitter.OpenLatestComplete(dir); !errors.Is(err, emitter.ErrNoCompleteGraph) {
+		t.Errorf("Empty directory: %v, want ErrNoCompleteGraph", err)
+	}
+	sink := emitter.FileSink{Dir: dir, NameByBuiltAt: true}
+	if err := sink.Emit(context.Background(), handoffGraph(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	older, _ := emitter.ListGraphFiles(dir)
+	for _, p := range []string{older[0], emitter.ChecksumPath(older[0]), emitter.DonePath(older[0])} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("Emit did not write the whole set: %v", err)
+		}
+	}
+	if done, _ := os.ReadFile(emitter.DonePath(older[0])); len(done) != 0 {
+		t.Errorf("Done marker holds %q, want nothing", done)
+	}
+
+	// emit revision 2 into a second directory, then replay its writes into dir one by one
+	staging := t.TempDir()
+	if err := (emitter.FileSink{Dir: staging, NameByBuiltAt: true}).Emit(context.Background(), handoffGraph(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	staged, _ := emitter.ListGraphFiles(staging)
+	newer := filepath.Join(dir, filepath.Base(staged[0]))
+	for _, step := range []struct {
+		from, to string
+		want     uint64
+	}{
+		{staged[0], newer, 1},
+		{emitter.ChecksumPath(staged[0]), emitter.ChecksumPath(newer), 1},
+		{emitter.DonePath(staged[0]), emitter.DonePath(newer), 2},
+	} {
+		if err := os.Rename(step.from, step.to); err != nil {
+			t.Fatal(err)
+		}
+		if got := readLatestComplete(t, dir); got != step.want {
+			t.Errorf("After writing %s, read revision %d, want %d", filepath.Base(step.to), got, step.want)
+		}
+	}
+
+	// retention deleting revision 2, in its order
+	for _, p := range []string{emitter.DonePath(newer), emitter.ChecksumPath(newer)} {
+		if err := os.Remove(p); err != nil {
+			t.Fatal(err)
+		}
+		if got := readLatestComplete(t, dir); got != 1 {
+			t.Errorf("After deleting %s, read revision %d, want 1", filepath.Base(p), got)
+		}
+	}
+
+	// a graph file that doesn't match its checksum is reported
+	if err := os.WriteFile(older[0], []byte(`{"graphRevision": 7}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := emitter.OpenLatestComplete(dir); err == nil || errors.Is(err, emitter.ErrNoCompleteGraph) {
+		t.Errorf("Corrupted graph file opened: %v", err)
+	}
+}
+
+// TestHandoff_PruneRemovesSets verifies retention leaves no checksum or marker of a deleted file.
+func TestHandoff_PruneRemovesSets(t *testing.T) {
+	dir := t.TempDir()
+	sink := emitter.FileSink{Dir: dir, Retain: 2, NameByBuiltAt: true}
+	for i := range 5 {
+		if err := sink.Emit(context.Background(), handoffGraph(uint64(i+1), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 6 {
+		t.Errorf("Expected 2 sets of 3 files, found %v", entries)
+	}
+	if got := readLatestComplete(t, dir); got != 5 {
+		t.Errorf("Read revision %d, want 5", got)
+	}
+}
+
+// TestHandoff_Concurrent emits with retention while readers poll, including sets rewritten within the
+// same second, and verifies every read is a complete graph.
+func TestHandoff_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	sink := emitter.FileSink{Dir: dir, Retain: 2, NameByBuiltAt: true}
+	if err := sink.Emit(context.Background(), handoffGraph(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				f, err := emitter.OpenLatestComplete(dir)
+				if err != nil {
+					errs <- err
+					return
+				}
+				var g graph.Graph
+				err = json.NewDecoder(f).Decode(&g)
+				f.Close()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if g.GraphRevision == 0 {
+					errs <- errors.New("read a graph without a revision")
+					return
+				}
+			}
+		}()
+	}
+	for rev := uint64(2); rev <= 200; rev++ {
+		// pairs of revisions share a file name
+		if err := sink.Emit(context.Background(), handoffGraph(rev, int(rev/2))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,193 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// historyGraph returns revision of an evolving graph: pods come and go, change phase and move
+// between nodes, so consecutive revisions differ by added, updated and removed nodes and
+// relationships. It was built at base plus revision minutes.
+func historyGraph(revision uint64, base time.Time) graph.Graph {
+	g := graph.Graph{
+		GraphRevision: revision,
+		Metadata:      &graph.GraphMetadata{ClusterName: "test", BuiltAt: base.Add(time.Duration(revision) * time.Minute)},
+	}
+	for i := range 2 {
+		g.Nodes = append(g.Nodes, graph.GraphNode{Key: graph.GraphEntityKey{Kind: "Node", Name: fmt.Sprintf("node-%d", i)}, Properties: map[string]string{"uid": "n"}, Revision: revision})
+	}
+	for i := revision; i < revision+5; i++ {
+		pod := graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: fmt.Sprintf("pod-%d", i)}
+		phase := "Pending"
+		if i < revision+3 {
+			phase = "Running"
+		}
+		g.Nodes = append(g.Nodes, graph.GraphNode{Key: pod, Properties: map[string]string{"status.phase": phase}, Revision: revision})
+		g.Relationships = append(g.Relationships, graph.GraphRelationship{
+			Source:           pod,
+			Target:           graph.GraphEntityKey{Kind: "Node", Name: fmt.Sprintf("node-%d", (i+revision)%2)},
+			RelationshipType: "SCHEDULED_ON",
+			Revision:         revision,
+		})
+	}
+	return g
+}
+
+// TestGraphHistory verifies past revisions, kept whole or as deltas, are rebuilt with the content and
+// metadata they were added with, by revision and by time, and evicted oldest first.
+func TestGraphHistory(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := graph.NewGraphHistory(12, 0)
+	for revision := uint64(1); revision <= 20; revision++ {
+		h.Add(historyGraph(revision, base))
+	}
+
+	if revisions := h.Revisions(); len(revisions) != 12 || revisions[0] != 9 || revisions[11] != 20 {
+		t.Fatalf("Revisions = %v, want 9..20", revisions)
+	}
+	if _, ok := h.GetRevision(8); ok {
+		t.Error("Evicted revision 8 still returned")
+	}
+	for revision := uint64(9); revision <= 20; revision++ {
+		got, ok := h.GetRevision(revision)
+		if !ok {
+			t.Fatalf("Revision %d missing", revision)
+		}
+		want := historyGraph(revision, base)
+		if graph.ContentHash(got) != graph.ContentHash(want) || got.GraphRevision != revision {
+			t.Errorf("Revision %d rebuilt as %+v", revision, got)
+		}
+		if got.Metadata == nil || !got.Metadata.BuiltAt.Equal(want.Metadata.BuiltAt) {
+			t.Errorf("Revision %d metadata = %+v", revision, got.Metadata)
+		}
+		for _, n := range got.Nodes {
+			if n.Revision != revision {
+				t.Errorf("Revision %d has node %v at revision %d", revision, n.Key, n.Revision)
+			}
+		}
+	}
+
+	if got, ok := h.GetAt(base.Add(14*time.Minute + 30*time.Second)); !ok || got.GraphRevision != 14 {
+		t.Errorf("GetAt between revisions 14 and 15 = %d, %v", got.GraphRevision, ok)
+	}
+	if _, ok := h.GetAt(base.Add(8 * time.Minute)); ok {
+		t.Error("GetAt before the oldest revision found a graph")
+	}
+}
+
+// TestGraphHistory_MemoryCap verifies the byte cap evicts the oldest revisions, always keeping the
+// newest.
+func TestGraphHistory_MemoryCap(t *testing.T) {
+	base := time.Now()
+	h := graph.NewGraphHistory(100, 1)
+	for revision := uint64(1); revision <= 3; revision++ {
+		h.Add(historyGraph(revision, base))
+	}
+	if revisions := h.Revisions(); len(revisions) != 1 || revisions[0] != 3 {
+		t.Errorf("Revisions = %v, want only the newest", revisions)
+	}
+
+	h = graph.NewGraphHistory(100, 0)
+	for revision := uint64(1); revision <= 30; revision++ {
+		h.Add(historyGraph(revision, base))
+	}
+	full := h.Bytes()
+	capped := graph.NewGraphHistory(100, full/2)
+	for revision := uint64(1); revision <= 30; revision++ {
+		capped.Add(historyGraph(revision, base))
+	}
+	if capped.Bytes() > full/2 || len(capped.Revisions()) >= 30 {
+		t.Errorf("Capped history holds %d bytes in %d revisions, want at most %d", capped.Bytes(), len(capped.Revisions()), full/2)
+	}
+	oldest := capped.Revisions()[0]
+	if got, ok := capped.GetRevision(oldest); !ok || graph.ContentHash(got) != graph.ContentHash(historyGraph(oldest, base)) {
+		t.Errorf("Oldest kept revision %d not rebuilt after eviction", oldest)
+	}
+}
+
+// TestGraphHistory_Concurrent verifies queries run safely alongside the build loop adding revisions.
+func TestGraphHistory_Concurrent(t *testing.T) {
+	base := time.Now()
+	h := graph.NewGraphHistory(10, 0)
+	h.Add(historyGraph(1, base))
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				revisions := h.Revisions()
+				revision := revisions[len(revisions)/2]
+				if got, ok := h.GetRevision(revision); ok && graph.ContentHash(got) != graph.ContentHash(historyGraph(revision, base)) {
+					t.Errorf("Revision %d rebuilt wrong", revision)
+					return
+				}
+			}
+		}()
+	}
+	for revision := uint64(2); revision <= 100; revision++ {
+		h.Add(historyGraph(revision, base))
+	}
+	wg.Wait()
+}
+
+// TestGraphServer_PastRevision verifies /graph?revision= and ?at= serve past graphs from the history
+// and /diff reads them from it.
+func TestGraphServer_PastRevision(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	srv, ts := newGraphServer(t)
+	srv.History = graph.NewGraphHistory(10, 0)
+	for revision := uint64(1); revision <= 5; revision++ {
+		g := historyGraph(revision, base)
+		if err := srv.Update(g); err != nil {
+			t.Fatal(err)
+		}
+		srv.History.Add(g)
+	}
+
+	get := func(query string) (graph.Graph, int) {
+		resp, err := http.Get(ts.URL + "/graph?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var g graph.Graph
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return g, resp.StatusCode
+	}
+	if g, code := get("revision=2"); code != http.StatusOK || graph.ContentHash(g) != graph.ContentHash(historyGraph(2, base)) {
+		t.Errorf("revision=2: %d, revision %d", code, g.GraphRevision)
+	}
+	if g, code := get("at=2024-05-01T12:03:30Z&kinds=Pod"); code != http.StatusOK || g.GraphRevision != 3 || len(g.Nodes) != 5 {
+		t.Errorf("at=12:03:30 kinds=Pod: %d, revision %d with %d nodes", code, g.GraphRevision, len(g.Nodes))
+	}
+	if _, code := get("revision=42"); code != http.StatusNotFound {
+		t.Errorf("revision=42: %d, want 404", code)
+	}
+	if _, code := get("revision=2&at=2024-05-01T12:03:30Z"); code != http.StatusBadRequest {
+		t.Errorf("revision and at: %d, want 400", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/diff?from=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var d graph.Delta
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	if d.FromRevision != 4 || d.ToRevision != 5 || len(d.AddedNodes) != 1 || len(d.RemovedNodes) != 1 {
+		t.Errorf("Diff from the history: %+v", d)
+	}
+}
@@ -0,0 +1,117 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/hooks"
+	"github.com/tthuwng/satellite/internal/types"
+)
+
+// hookGraph returns a graph at revision with a node per pod name.
+func hookGraph(revision uint64, pods ...string) graph.Graph {
+	g := graph.Graph{GraphRevision: revision}
+	for _, name := range pods {
+		g.Nodes = append(g.Nodes, graph.GraphNode{Key: graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: name}})
+	}
+	return g
+}
+
+// TestHooks_OrderAndChanged verifies hooks run in registration order with the keys changed since
+// the previous build, and that a panicking or hanging hook doesn't stop the others.
+func TestHooks_OrderAndChanged(t *testing.T) {
+	registry := &hooks.Registry{Timeout: 50 * time.Millisecond}
+	var mu sync.Mutex
+	var calls []string
+	var changed [][]types.EntityKey
+	call := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+	registry.RegisterOnGraphBuilt(func(context.Context, graph.Graph, []types.EntityKey) {
+		call("first")
+		panic("boom")
+	})
+	registry.RegisterOnGraphBuilt(func(ctx context.Context, _ graph.Graph, _ []types.EntityKey) {
+		call("slow")
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // ignores the deadline a little longer
+	})
+	registry.RegisterOnGraphBuilt(func(_ context.Context, _ graph.Graph, keys []types.EntityKey) {
+		call("last")
+		changed = append(changed, keys)
+	})
+
+	start := time.Now()
+	registry.Run(context.Background(), hookGraph(1, "web", "cart"))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Hooks held the build for %s", elapsed)
+	}
+	mu.Lock()
+	if want := []string{"first", "slow", "last"}; !slices.Equal(calls, want) {
+		t.Fatalf("Calls %v, want %v", calls, want)
+	}
+	mu.Unlock()
+	registry.Run(context.Background(), hookGraph(2, "web", "api"))
+
+	if len(changed) != 2 || len(changed[0]) != 2 {
+		t.Fatalf("Expected both pods changed by the first build, got %v", changed)
+	}
+	var names []string
+	for _, key := range changed[1] {
+		names = append(names, key.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"api", "cart"}) {
+		t.Errorf("Expected api added and cart removed, got %v", changed[1])
+	}
+}
+
+// TestHooks_Exec verifies the exec hook passes the graph file and revision to the command and
+// removes the file afterwards.
+func TestHooks_Exec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hook.sh")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(script, []byte("cp \"$1\" "+out+".json && echo \"$SATELLITE_REVISION $SATELLITE_CHANGED $1\" > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := hooks.Exec("sh " + script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := &hooks.Registry{}
+	registry.RegisterOnGraphBuilt(hook)
+	registry.Run(context.Background(), hookGraph(7, "web"))
+
+	data, err := os.ReadFile(out + ".json")
+	if err != nil {
+		t.Fatalf("Hook command did not run: %v", err)
+	}
+	var g graph.Graph
+	if err := json.Unmarshal(data, &g); err != nil || g.GraphRevision != 7 || len(g.Nodes) != 1 {
+		t.Errorf("Unexpected graph file %s (%v)", data, err)
+	}
+	var revision, changed int
+	var path string
+	line, _ := os.ReadFile(out)
+	if _, err := fmt.Sscan(string(line), &revision, &changed, &path); err != nil || revision != 7 || changed != 1 {
+		t.Errorf("Unexpected environment %q", line)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Graph file %s not removed: %v", path, err)
+	}
+}
@@ -0,0 +1,183 @@
+package main_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// hpaGraph builds the graph of hpa and returns its node's properties and its SCALES relationship.
+func hpaGraph(t *testing.T, hpa *autoscalingv2.HorizontalPodAutoscaler) (map[string]string, graph.GraphRelationship) {
+	t.Helper()
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(hpa)
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var props map[string]string
+	for _, node := range g.Nodes {
+		if node.Key.Kind == "HorizontalPodAutoscaler" {
+			props = node.Properties
+		}
+	}
+	for _, rel := range g.Relationships {
+		if rel.RelationshipType == "SCALES" {
+			return props, rel
+		}
+	}
+	t.Fatalf("No SCALES relationship in %v", g.Relationships)
+	return nil, graph.GraphRelationship{}
+}
+
+// TestHPA_MetricsAtMax verifies every autoscaling/v2 metric type becomes target and current
+// properties, on the HPA and its SCALES relationship, for an HPA at its maximum.
+func TestHPA_MetricsAtMax(t *testing.T) {
+	cpu, minReplicas := int32(70), int32(2)
+	scaled := metav1.NewTime(time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC))
+	cpuNow, cpuValue := int32(93), resource.MustParse("930m")
+	queue, queueNow := resource.MustParse("30"), resource.MustParse("120")
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", UID: "api-hpa-uid"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    10,
+			Metrics: []autoscalingv2.MetricSpec{
+				{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+					Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &cpu},
+				}},
+				{Type: autoscalingv2.ContainerResourceMetricSourceType, ContainerResource: &autoscalingv2.ContainerResourceMetricSource{
+					Name: corev1.ResourceMemory, Container: "app", Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: resource.NewQuantity(512<<20, resource.BinarySI)},
+				}},
+				{Type: autoscalingv2.ExternalMetricSourceType, External: &autoscalingv2.ExternalMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{Name: "queue_depth"}, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &queue},
+				}},
+			},
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+			LastScaleTime:   &scaled,
+			CurrentReplicas: 10,
+			DesiredReplicas: 10,
+			CurrentMetrics: []autoscalingv2.MetricStatus{
+				{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricStatus{
+					Name: corev1.ResourceCPU, Current: autoscalingv2.MetricValueStatus{AverageUtilization: &cpuNow, AverageValue: &cpuValue},
+				}},
+				{Type: autoscalingv2.ExternalMetricSourceType, External: &autoscalingv2.ExternalMetricStatus{
+					Metric: autoscalingv2.MetricIdentifier{Name: "queue_depth"}, Current: autoscalingv2.MetricValueStatus{Value: &queueNow},
+				}},
+			},
+			Conditions: []autoscalingv2.HorizontalPodAutoscalerCondition{
+				{Type: autoscalingv2.AbleToScale, Status: corev1.ConditionTrue, Reason: "ReadyForNewScale"},
+				{Type: autoscalingv2.ScalingLimited, Status: corev1.ConditionTrue, Reason: "TooManyReplicas"},
+			},
+		},
+	}
+	props, rel := hpaGraph(t, hpa)
+
+	want := map[string]string{
+		graph.AtMaxReplicasProperty:                   "true",
+		"spec.minReplicas":                            "2",
+		"spec.maxReplicas":                            "10",
+		"status.currentReplicas":                      "10",
+		"status.lastScaleTime":                        "2024-05-01T14:30:00Z",
+		"scalingLimited":                              "true",
+		"scalingLimitedReason":                        "TooManyReplicas",
+		"metrics.resource.cpu.target":                 "70%",
+		"metrics.resource.cpu.current":                "93%",
+		"metrics.containerResource.app.memory.target": "512Mi",
+		"metrics.external.queue_depth.target":         "30",
+		"metrics.external.queue_depth.current":        "120",
+	}
+	for key, value := range want {
+		if props[key] != value {
+			t.Errorf("HPA %s = %q, want %q", key, props[key], value)
+		}
+		if rel.Properties[key] != value {
+			t.Errorf("SCALES %s = %q, want %q", key, rel.Properties[key], value)
+		}
+	}
+	if _, ok := props["metrics.containerResource.app.memory.current"]; ok {
+		t.Error("Metric without a current value got one")
+	}
+	if target := (graph.GraphEntityKey{Kind: "Deployment", Namespace: "shop", Name: "api", APIGroup: "apps"}); rel.Target != target {
+		t.Errorf("SCALES target = %+v, want %+v", rel.Target, target)
+	}
+}
+
+// TestHPA_NoStatus verifies a new HPA without a status gets its targets and isn't at its maximum.
+func TestHPA_NoStatus(t *testing.T) {
+	cpu := int32(50)
+	props, rel := hpaGraph(t, &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop", UID: "worker-hpa-uid"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "worker"},
+			MaxReplicas:    4,
+			Metrics: []autoscalingv2.MetricSpec{{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+				Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &cpu},
+			}}},
+		},
+	})
+	if props[graph.AtMaxReplicasProperty] != "false" || props["metrics.resource.cpu.target"] != "50%" {
+		t.Errorf("Unexpected properties: %v", props)
+	}
+	for _, key := range []string{"metrics.resource.cpu.current", "status.lastScaleTime", "scalingLimited"} {
+		if _, ok := props[key]; ok {
+			t.Errorf("HPA without a status got %s", key)
+		}
+	}
+	if target := (graph.GraphEntityKey{Kind: "Rollout", Namespace: "shop", Name: "worker", APIGroup: "argoproj.io"}); rel.Target != target {
+		t.Errorf("SCALES target = %+v, want %+v", rel.Target, target)
+	}
+}
+
+// TestHPA_Targets verifies SCALES relationships point at the watched workloads' nodes, with or without an
+// apiVersion in scaleTargetRef, and that a target of a watched kind that doesn't exist is reported missing.
+func TestHPA_Targets(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop", UID: "db-uid"}})
+	resourceCache.Upsert(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", UID: "api-uid"}})
+	for name, ref := range map[string]autoscalingv2.CrossVersionObjectReference{
+		"db":     {APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"},
+		"api":    {Kind: "Deployment", Name: "api"},
+		"legacy": {APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "legacy-1"},
+	} {
+		resourceCache.Upsert(&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID(name + "-hpa-uid")},
+			Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{ScaleTargetRef: ref, MaxReplicas: 3},
+		})
+	}
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"StatefulSet", "Deployment", "ReplicaSet", "HorizontalPodAutoscaler"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[graph.GraphEntityKey]bool)
+	for _, n := range g.Nodes {
+		nodes[n.Key] = true
+	}
+	scaled := make(map[string]bool)
+	for _, rel := range g.Relationships {
+		if rel.RelationshipType != "SCALES" {
+			continue
+		}
+		missing := rel.Properties[graph.MissingTargetProperty] == "true"
+		if nodes[rel.Target] == missing || rel.Target.APIGroup != "apps" {
+			t.Errorf("SCALES %s -> %+v: missing=%v", rel.Source.Name, rel.Target, missing)
+		}
+		scaled[rel.Source.Name] = !missing
+	}
+	if want := map[string]bool{"db": true, "api": true, "legacy": false}; !maps.Equal(scaled, want) {
+		t.Errorf("Scaled targets found = %v, want %v", scaled, want)
+	}
+}
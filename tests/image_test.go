@@ -0,0 +1,114 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// imagePod returns a pod in shop running the given container images, keyed by container name.
+func imagePod(name string, containers ...corev1.Container) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID(name + "-uid")},
+		Spec:       corev1.PodSpec{Containers: containers},
+	}
+}
+
+// TestImages_Normalized verifies each distinct image is one node named by its normalized reference,
+// with implicit registries, library/ repositories and latest tags filled in, and digests parsed.
+func TestImages_Normalized(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(imagePod("a",
+		corev1.Container{Name: "nginx", Image: "nginx"},
+		corev1.Container{Name: "redis", Image: "bitnami/redis:7.2"},
+		corev1.Container{Name: "app", Image: "ghcr.io/org/app@sha256:abc"},
+	))
+	resourceCache.Upsert(imagePod("b",
+		corev1.Container{Name: "proxy", Image: "docker.io/library/nginx:latest"},
+		corev1.Container{Name: "local", Image: "localhost:5000/tools:v1"},
+		corev1.Container{Name: "exporter", Image: "quay.io/prometheus/node-exporter:v1.8.0@sha256:def"},
+		corev1.Container{Name: "mirror", Image: "registry:5000/team/app"},
+	))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]string{
+		"docker.io/library/nginx:latest":                     {"registry": "docker.io", "repository": "library/nginx", "tag": "latest"},
+		"docker.io/bitnami/redis:7.2":                        {"registry": "docker.io", "repository": "bitnami/redis", "tag": "7.2"},
+		"ghcr.io/org/app@sha256:abc":                         {"registry": "ghcr.io", "repository": "org/app", "digest": "sha256:abc"},
+		"localhost:5000/tools:v1":                            {"registry": "localhost:5000", "repository": "tools", "tag": "v1"},
+		"registry:5000/team/app:latest":                      {"registry": "registry:5000", "repository": "team/app", "tag": "latest"},
+		"quay.io/prometheus/node-exporter:v1.8.0@sha256:def": {"registry": "quay.io", "repository": "prometheus/node-exporter", "tag": "v1.8.0", "digest": "sha256:def"},
+	}
+	images := 0
+	for _, n := range g.Nodes {
+		if n.Key.Kind != "Image" {
+			continue
+		}
+		images++
+		props, ok := want[n.Key.Name]
+		if !ok {
+			t.Errorf("Unexpected image %q", n.Key.Name)
+			continue
+		}
+		if len(n.Properties) != len(props) {
+			t.Errorf("%s properties = %v, want %v", n.Key.Name, n.Properties, props)
+		}
+		for k, v := range props {
+			if n.Properties[k] != v {
+				t.Errorf("%s %s = %q, want %q", n.Key.Name, k, n.Properties[k], v)
+			}
+		}
+	}
+	if images != len(want) {
+		t.Errorf("%d image nodes, want %d", images, len(want))
+	}
+}
+
+// TestImages_RunsImage verifies a pod RUNS_IMAGE each of its images once, listing the containers,
+// init and ephemeral ones included, that run it.
+func TestImages_RunsImage(t *testing.T) {
+	pod := imagePod("web",
+		corev1.Container{Name: "web", Image: "shop/web:1"},
+		corev1.Container{Name: "sidecar", Image: "docker.io/shop/web:1"},
+		corev1.Container{Name: "noimage"},
+	)
+	pod.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "shop/migrate:1"}}
+	pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: "busybox"}}}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(pod)
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := make(map[string]string)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "RUNS_IMAGE" {
+			if r.Source.Name != "web" || r.Target.Kind != "Image" {
+				t.Errorf("Unexpected RUNS_IMAGE %+v", r)
+			}
+			runs[r.Target.Name] = r.Properties["container"]
+		}
+	}
+	want := map[string]string{
+		"docker.io/shop/web:1":             "sidecar,web",
+		"docker.io/shop/migrate:1":         "migrate",
+		"docker.io/library/busybox:latest": "debug",
+	}
+	if len(runs) != len(want) {
+		t.Errorf("RUNS_IMAGE = %v, want %v", runs, want)
+	}
+	for image, containers := range want {
+		if runs[image] != containers {
+			t.Errorf("%s run by %q, want %q", image, runs[image], containers)
+		}
+	}
+}
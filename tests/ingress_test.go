@@ -0,0 +1,95 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func shopIngress() *networkingv1.Ingress {
+	backend := func(service string) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: service, Port: networkingv1.ServiceBackendPort{Number: 80}}}
+	}
+	paths := func(paths ...networkingv1.HTTPIngressPath) *networkingv1.HTTPIngressRuleValue {
+		return &networkingv1.HTTPIngressRuleValue{Paths: paths}
+	}
+	web := backend("web")
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop", UID: "shop-ingress", Annotations: map[string]string{graph.IngressClassAnnotation: "nginx"}},
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &web,
+			Rules: []networkingv1.IngressRule{
+				{Host: "shop.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths(
+					networkingv1.HTTPIngressPath{Path: "/", Backend: backend("web")},
+					networkingv1.HTTPIngressPath{Path: "/api", Backend: backend("api")},
+				)}},
+				{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths(
+					networkingv1.HTTPIngressPath{Path: "/admin", Backend: backend("api")},
+				)}},
+			},
+			TLS: []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}},
+		},
+		Status: networkingv1.IngressStatus{LoadBalancer: networkingv1.IngressLoadBalancerStatus{Ingress: []networkingv1.IngressLoadBalancerIngress{
+			{IP: "203.0.113.10"}, {Hostname: "lb.example.net"},
+		}}},
+	}
+}
+
+// TestIngress_Routes verifies one ROUTES_TO relationship per backend Service with its hosts and paths, the
+// USES_TLS relationship to the TLS Secret whether or not Secrets are watched, and the Ingress properties.
+func TestIngress_Routes(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(shopIngress())
+	resourceCache.Upsert(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-svc"}})
+
+	for _, watched := range [][]string{{"Ingress", "Service"}, {"Ingress", "Service", "Secret"}} {
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds(watched))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range g.Relationships {
+			got = append(got, r.RelationshipType+" "+r.Target.Kind+"/"+r.Target.Name+" host="+r.Properties["host"]+
+				" path="+r.Properties["path"]+" default="+r.Properties["defaultBackend"]+" missing="+r.Properties[graph.MissingTargetProperty])
+		}
+		sort.Strings(got)
+		secretMissing := ""
+		if len(watched) == 3 {
+			secretMissing = "true"
+		}
+		want := []string{
+			"ROUTES_TO Service/api host=*,shop.example.com path=/admin,/api default= missing=true",
+			"ROUTES_TO Service/web host=shop.example.com path=/ default=true missing=",
+			"USES_TLS Secret/shop-tls host=shop.example.com path= default= missing=" + secretMissing,
+		}
+		if len(got) != len(want) {
+			t.Fatalf("watching %v: relationships = %q, want %q", watched, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("watching %v: relationship %d = %q, want %q", watched, i, got[i], want[i])
+			}
+		}
+	}
+
+	g, _ := graph.BuildGraph(context.Background(), resourceCache, 1)
+	for _, n := range g.Nodes {
+		if n.Key.Kind != "Ingress" {
+			continue
+		}
+		if n.Key.APIGroup != "networking.k8s.io" {
+			t.Errorf("Ingress key = %+v", n.Key)
+		}
+		p := n.Properties
+		if p["spec.ingressClassName"] != "nginx" || p["status.loadBalancer.ips"] != "203.0.113.10" || p["status.loadBalancer.hostnames"] != "lb.example.net" {
+			t.Errorf("Ingress properties = %v", p)
+		}
+	}
+}
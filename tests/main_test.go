@@ -5,9 +5,9 @@ import (
 	"testing"
 	"time"
 
-	"satellite/internal/cache"
-	"satellite/internal/k8s"
-	"satellite/internal/types"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/types"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -44,11 +44,11 @@ func TestHandlerPutsObjectOnChannel(t *testing.T) {
 	_, _ = client.CoreV1().Pods("default").Create(
 		context.TODO(), pod, metav1.CreateOptions{})
 
-	time.Sleep(50 * time.Millisecond)
-
 	key := types.EntityKey{Kind: "Pod", Namespace: "default", Name: "unit-pod"}
-	if _, found := resourceCache.Get(key); !found {
-		t.Fatalf("Pod %v not found in cache after Add event", key)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := resourceCache.WaitForKey(ctx, key); err != nil {
+		t.Fatalf("Pod %v not found in cache after Add event: %v", key, err)
 	}
 }
 
@@ -286,18 +286,17 @@ func TestEventMatrix(t *testing.T) {
 // Helper for ReplicaSet/Deployment spec
 func int32Ptr(i int32) *int32 { return &i }
 
-// expectCacheState checks if an object with the given key exists (or not) in the cache.
+// expectCacheState waits until an object with the given key exists (or not) in the cache.
 func expectCacheState(t *testing.T, resCache *cache.ResourceCache, key types.EntityKey, shouldExist bool) {
 	t.Helper()
-	time.Sleep(100 * time.Millisecond)
-
-	_, found := resCache.Get(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	if found != shouldExist {
-		if shouldExist {
+	if shouldExist {
+		if err := resCache.WaitForKey(ctx, key); err != nil {
 			t.Errorf("Expected object with key %v to exist in cache, but it doesn't", key)
-		} else {
-			t.Errorf("Expected object with key %v NOT to exist in cache, but it does", key)
 		}
+	} else if err := resCache.WaitForAbsent(ctx, key); err != nil {
+		t.Errorf("Expected object with key %v NOT to exist in cache, but it does", key)
 	}
 }
@@ -0,0 +1,186 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/manifests"
+)
+
+const chartManifests = `# Source: shop/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels: {app: web}
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+      - name: web
+        image: shop/web:1.2
+---
+# Source: shop/templates/hpa.yaml
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef: {apiVersion: apps/v1, kind: Deployment, name: web}
+  maxReplicas: 4
+---
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web-credentials
+  namespace: shop
+stringData:
+  password: ` + secretCanary + `
+`
+
+const listManifest = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "shop"}},
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web-config", "namespace": "shop"}},
+    {"apiVersion": "policy/v1", "kind": "PodDisruptionBudget", "metadata": {"name": "web"}}
+  ]
+}`
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestManifestsGraph verifies a directory of multi-document YAML and JSON manifests builds a graph
+// without a cluster, and that secret values in them are never emitted.
+func TestManifestsGraph(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"chart.yaml":       chartManifests,
+		"nested/list.json": listManifest,
+		"README.md":        "not a manifest",
+	})
+	l := manifests.NewLoader(cache.NewResourceCache(), "shop")
+	if err := l.LoadPath(dir); err != nil {
+		t.Fatal(err)
+	}
+	if l.Loaded != 5 {
+		t.Errorf("Loaded = %d, want 5", l.Loaded)
+	}
+	if l.Skipped["monitoring.coreos.com/v1 ServiceMonitor"] != 1 || l.Skipped["policy/v1 PodDisruptionBudget"] != 1 {
+		t.Errorf("Skipped = %v", l.Skipped)
+	}
+
+	g, err := graph.BuildGraph(context.Background(), l.Cache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, node := range g.Nodes {
+		names = append(names, node.Key.Kind+"/"+node.Key.Namespace+"/"+node.Key.Name)
+	}
+	for _, want := range []string{"Deployment/shop/web", "HorizontalPodAutoscaler/shop/web", "ConfigMap/shop/web-config", "Secret/shop/web-credentials", "Namespace//shop"} {
+		if !strings.Contains(strings.Join(names, " "), want) {
+			t.Errorf("Graph lacks %s: %v", want, names)
+		}
+	}
+	scales := false
+	for _, rel := range g.Relationships {
+		switch rel.RelationshipType {
+		case "SCALES":
+			scales = rel.Source.Kind == "HorizontalPodAutoscaler" && rel.Target.Kind == "Deployment" && rel.Target.Name == "web"
+		case "SCHEDULED_ON":
+			t.Errorf("Manifests scheduled a pod: %+v", rel)
+		}
+	}
+	if !scales {
+		t.Error("HorizontalPodAutoscaler does not scale the Deployment")
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secretCanary) {
+		t.Error("Graph holds a secret value of the manifests")
+	}
+}
+
+// TestManifestsErrors verifies a document that fails to decode is reported by file and document
+// index, without quoting it, and the documents around it still load.
+func TestManifestsErrors(t *testing.T) {
+	broken := `apiVersion: v1
+kind: ConfigMap
+metadata: {name: first}
+---
+apiVersion: v1
+kind: Secret
+metadata: {name: broken}
+data:
+  password: [` + secretCanary + `]
+---
+kind: Ghost
+---
+just: ` + secretCanary + `
+---
+apiVersion: example.com/v1
+kind: Ghost
+metadata: {name: ` + secretCanary + `}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: last}
+`
+	dir := writeManifests(t, map[string]string{"broken.yaml": broken})
+	l := manifests.NewLoader(cache.NewResourceCache(), "")
+	err := l.LoadPath(dir)
+	if err == nil {
+		t.Fatal("LoadPath succeeded")
+	}
+	msg := err.Error()
+	file := filepath.Join(dir, "broken.yaml")
+	for _, want := range []string{file + ": document 1:", file + ": document 2: not a Kubernetes object: missing apiVersion", file + ": document 3: not a Kubernetes object: missing kind"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error %q does not report %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, secretCanary) {
+		t.Errorf("Error holds a secret value: %s", msg)
+	}
+	var docErr *manifests.DocumentError
+	if !errors.As(err, &docErr) || docErr.File != file {
+		t.Errorf("Error is not a DocumentError of %s: %v", file, err)
+	}
+	if l.Loaded != 2 || l.Skipped["v1 ConfigMap"] != 0 || l.Skipped["example.com/v1 Ghost"] != 1 {
+		t.Errorf("Loaded = %d, Skipped = %v", l.Loaded, l.Skipped)
+	}
+	if _, ok := l.Cache.GetByUID("manifest:ConfigMap/default/last"); !ok {
+		t.Error("ConfigMap after the broken documents not loaded in the default namespace")
+	}
+}
@@ -0,0 +1,121 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/metadata/metadatainformer"
+	cachepkg "k8s.io/client-go/tools/cache"
+)
+
+// TestMetadataOnly_Informer verifies metadata-only objects are keyed by their kind and keep name-based relationships.
+func TestMetadataOnly_Informer(t *testing.T) {
+	scheme := fake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	client := fake.NewSimpleMetadataClient(scheme, &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default", UID: "cm-1", ResourceVersion: "1"},
+	})
+	factory := metadatainformer.NewSharedInformerFactory(client, 0)
+	inf, err := k8s.NewMetadataInformer(factory, k8s.WatchedKind{Kind: "ConfigMap", Resource: "configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceCache := cache.NewResourceCache()
+	inf.AddEventHandler(resourceCache.AddEventHandler("ConfigMap"))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	cachepkg.WaitForCacheSync(stop, inf.HasSynced)
+
+	resourceCache.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "settings",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}},
+		}}},
+	})
+	resourceCache.Upsert(defaultServiceAccount("default"))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmKey := graph.GraphEntityKey{Kind: "ConfigMap", Namespace: "default", Name: "settings"}
+	var node *graph.GraphNode
+	for i := range g.Nodes {
+		if g.Nodes[i].Key == cmKey {
+			node = &g.Nodes[i]
+		}
+	}
+	if node == nil {
+		t.Fatalf("Missing ConfigMap node, got %+v", g.Nodes)
+	}
+	if node.Properties[graph.MetadataOnlyProperty] != "true" || node.Properties["uid"] != "cm-1" {
+		t.Errorf("Unexpected metadata-only properties: %v", node.Properties)
+	}
+	if dangling := graph.DanglingRelationships(g); len(g.Relationships) != 2 || dangling != 0 {
+		t.Errorf("Expected the MOUNTS and RUNS_AS relationships to resolve, got %+v (%d dangling)", g.Relationships, dangling)
+	}
+}
+
+// TestMetadataOnly_OwnerRelationships verifies metadata-only objects keep their OWNED_BY relationships.
+func TestMetadataOnly_OwnerRelationships(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "web"},
+		}},
+	})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Relationships) != 1 || g.Relationships[0].RelationshipType != "OWNED_BY" || g.Relationships[0].Target.Name != "web" {
+		t.Errorf("Expected web-1 OWNED_BY web, got %+v", g.Relationships)
+	}
+}
+
+// TestMetadataOnly_HeapFootprint compares the heap held by a cache of large ConfigMaps with its metadata-only equivalent.
+func TestMetadataOnly_HeapFootprint(t *testing.T) {
+	const count, size = 2000, 16 << 10
+	value := strings.Repeat("x", size)
+	measure := func(fill func(*cache.ResourceCache, int)) uint64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		resourceCache := cache.NewResourceCache()
+		for i := 0; i < count; i++ {
+			fill(resourceCache, i)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(resourceCache)
+		return after.HeapAlloc - min(before.HeapAlloc, after.HeapAlloc)
+	}
+	meta := func(i int) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: fmt.Sprintf("cm-%d", i), Namespace: "default", ResourceVersion: "1"}
+	}
+	full := measure(func(c *cache.ResourceCache, i int) {
+		// a fresh copy per object, as decoded by an informer
+		c.Upsert(&corev1.ConfigMap{ObjectMeta: meta(i), Data: map[string]string{"payload": strings.Clone(value)}})
+	})
+	partial := measure(func(c *cache.ResourceCache, i int) {
+		c.Upsert(&metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}, ObjectMeta: meta(i)})
+	})
+	t.Logf("%d ConfigMaps of %d KiB: full objects %d MiB, metadata-only %d KiB", count, size>>10, full>>20, partial>>10)
+	if partial*10 > full {
+		t.Errorf("Metadata-only cache (%d bytes) not an order of magnitude smaller than the full one (%d bytes)", partial, full)
+	}
+}
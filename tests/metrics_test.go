@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// TestMetrics_BuildEmitCycle scrapes the registry after a synthetic
+// event/build/emit cycle. The metric names are a stable interface.
+func TestMetrics_BuildEmitCycle(t *testing.T) {
+	pod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "metrics", UID: k8stypes.UID("uid-" + name), ResourceVersion: "1",
+		}}
+	}
+	podsBefore := testutil.ToFloat64(metrics.CacheObjects.WithLabelValues("Pod"))
+	addsBefore := testutil.ToFloat64(metrics.InformerEvents.WithLabelValues("Pod", "add"))
+
+	c := cache.NewResourceCache()
+	handler := c.AddEventHandler("Pod")
+	handler.OnAdd(pod("a"), false)
+	handler.OnAdd(pod("b"), false)
+	handler.OnDelete(pod("b"))
+
+	if got := testutil.ToFloat64(metrics.CacheObjects.WithLabelValues("Pod")) - podsBefore; got != 1 {
+		t.Errorf("cache_objects{kind=Pod} grew by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.InformerEvents.WithLabelValues("Pod", "add")) - addsBefore; got != 2 {
+		t.Errorf("informer_events_total{kind=Pod,type=add} grew by %v, want 2", got)
+	}
+
+	g, err := graph.BuildGraph(context.Background(), c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(metrics.GraphNodes); got != float64(len(g.Nodes)) {
+		t.Errorf("graph_nodes = %v, want %d", got, len(g.Nodes))
+	}
+
+	ok := emitter.Instrument("test-file", emitter.FileSink{Dir: t.TempDir()}.Emit)
+	if err := ok(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	broken := emitter.Instrument("test-broken", func(context.Context, graph.Graph) error { return errors.New("boom") })
+	if err := broken(context.Background(), g); err == nil {
+		t.Fatal("expected the broken sink to fail")
+	}
+	if got := testutil.ToFloat64(metrics.EmitFailures.WithLabelValues("test-broken")); got != 1 {
+		t.Errorf("emit_failures_total{sink=test-broken} = %v, want 1", got)
+	}
+	if testutil.ToFloat64(metrics.LastEmitSuccess.WithLabelValues("test-file")) == 0 {
+		t.Error("last_successful_emit_timestamp_seconds{sink=test-file} not set")
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, name := range []string{
+		"satellite_informer_events_total",
+		"satellite_cache_objects",
+		"satellite_cache_change_signals_total",
+		"satellite_graph_build_duration_seconds_bucket",
+		"satellite_graph_nodes",
+		"satellite_graph_relationships",
+		"satellite_emit_duration_seconds_bucket",
+		"satellite_emit_failures_total",
+		"satellite_last_successful_emit_timestamp_seconds",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), "\n"+name) {
+			t.Errorf("scrape is missing %s", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// configMapVolume returns a volume of the ConfigMap name.
+func configMapVolume(name string, optional bool) corev1.Volume {
+	return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Optional:             &optional,
+	}}}
+}
+
+// TestMissingDependencies verifies required references to absent objects are flagged only when their
+// kind is watched, counted in the metadata and, optionally, given placeholder nodes.
+func TestMissingDependencies(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "present", Namespace: "shop", UID: "present-uid"}})
+	for _, name := range []string{"web-a", "web-b"} {
+		resourceCache.Upsert(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name)},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+				configMapVolume("present", false),
+				configMapVolume("absent", false),
+				configMapVolume("absent-optional", true),
+			}},
+		})
+	}
+
+	missing := func(g graph.Graph) map[string]int {
+		out := make(map[string]int)
+		for _, rel := range g.Relationships {
+			if rel.Properties[graph.MissingTargetProperty] == "true" {
+				out[rel.Target.Name]++
+			}
+		}
+		return out
+	}
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "ConfigMap"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := missing(g); len(got) != 1 || got["absent"] != 2 {
+		t.Errorf("Missing targets = %v, want absent twice", got)
+	}
+	if g.Metadata == nil || g.Metadata.MissingDependencies != 2 {
+		t.Errorf("Metadata = %+v, want 2 missing dependencies", g.Metadata)
+	}
+	if len(g.Nodes) != 3 {
+		t.Errorf("Placeholders added without being asked for: %v", g.Nodes)
+	}
+
+	// ConfigMaps aren't watched: their absence says nothing
+	g, err = graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := missing(g); len(got) != 0 || g.Metadata != nil {
+		t.Errorf("Unwatched kind flagged missing: %v, %+v", got, g.Metadata)
+	}
+
+	g, err = graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "ConfigMap"}), graph.WithMissingPlaceholders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var placeholders []graph.GraphNode
+	for _, node := range g.Nodes {
+		if node.Properties[graph.MissingProperty] == "true" {
+			placeholders = append(placeholders, node)
+		}
+	}
+	if len(placeholders) != 1 || placeholders[0].Key != (graph.GraphEntityKey{Kind: "ConfigMap", Namespace: "shop", Name: "absent"}) {
+		t.Errorf("Placeholders = %v, want one for shop/absent", placeholders)
+	}
+	// only the optional volumes' and default ServiceAccounts' relationships still dangle
+	if dangling := graph.DanglingRelationships(g); dangling != 4 {
+		t.Errorf("%d dangling relationships with placeholders, want 4", dangling)
+	}
+}
@@ -0,0 +1,78 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// shopCache returns a cache keeping deleted objects for an hour, holding
+// the shop Namespace, a pod and a ConfigMap in it, and a pod in default.
+func shopCache() (*cache.ResourceCache, *corev1.Namespace, *corev1.Pod) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Linger = time.Hour
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", UID: "ns-uid", ResourceVersion: "1"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cart", Namespace: "shop", UID: "cart-uid", ResourceVersion: "1"}}
+	resourceCache.Upsert(ns)
+	resourceCache.Upsert(pod)
+	resourceCache.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cart-config", Namespace: "shop", UID: "cm-uid", ResourceVersion: "1"}})
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid", ResourceVersion: "1"}})
+	return resourceCache, ns, pod
+}
+
+// TestNamespaceDelete_EvictsMembers verifies deleting a Namespace removes its objects at once,
+// even with a linger and before their own deletes arrive, and leaves other namespaces alone.
+func TestNamespaceDelete_EvictsMembers(t *testing.T) {
+	resourceCache, ns, pod := shopCache()
+	if got := len(resourceCache.ListByNamespace("shop")); got != 2 {
+		t.Fatalf("Expected 2 objects in shop, got %d", got)
+	}
+
+	resourceCache.AddEventHandler("Namespace").OnDelete(ns)
+	if got := len(resourceCache.ListByNamespace("shop")); got != 0 {
+		t.Errorf("Expected shop to be evicted, %d objects left", got)
+	}
+	if _, found := resourceCache.GetByUID("cart-uid"); found {
+		t.Errorf("Evicted pod still indexed by UID")
+	}
+	if got := len(resourceCache.ListByNamespace("default")); got != 1 {
+		t.Errorf("Eviction of shop touched default: %d objects left", got)
+	}
+
+	// the pod's own delete arriving late is a no-op
+	seq := resourceCache.Seq()
+	resourceCache.AddEventHandler("Pod").OnDelete(pod)
+	if resourceCache.Seq() != seq {
+		t.Errorf("Late delete of an evicted pod changed the cache")
+	}
+}
+
+// TestNamespaceDelete_EvictsLingering verifies a Namespace delete ends the linger of objects already deleted.
+func TestNamespaceDelete_EvictsLingering(t *testing.T) {
+	resourceCache, ns, pod := shopCache()
+	resourceCache.AddEventHandler("Pod").OnDelete(pod)
+	snap := resourceCache.Snapshot()
+	if _, deleted := snap.DeletedAt(types.EntityKey{Kind: "Pod", Namespace: "shop", Name: "cart"}); !deleted {
+		t.Fatalf("Deleted pod not lingering")
+	}
+
+	if evicted := resourceCache.EvictNamespace("shop"); evicted != 2 {
+		t.Errorf("Expected 2 objects evicted, got %d", evicted)
+	}
+	if _, found := resourceCache.Get(types.EntityKey{Kind: "Pod", Namespace: "shop", Name: "cart"}); found {
+		t.Errorf("Lingering pod survived the eviction")
+	}
+	if _, found := resourceCache.Get(types.EntityKey{Kind: "Namespace", Name: "shop"}); !found {
+		t.Errorf("Eviction removed the cluster-scoped Namespace itself")
+	}
+
+	resourceCache.AddEventHandler("Namespace").OnDelete(ns)
+	if evicted := resourceCache.EvictNamespace("shop"); evicted != 0 {
+		t.Errorf("Expected nothing left to evict, got %d", evicted)
+	}
+}
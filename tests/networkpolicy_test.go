@@ -0,0 +1,107 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func networkPolicy(name string, selector metav1.LabelSelector, spec networkingv1.NetworkPolicySpec) *networkingv1.NetworkPolicy {
+	spec.PodSelector = selector
+	return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name)}, Spec: spec}
+}
+
+// TestNetworkPolicy_AppliesTo verifies policies APPLY_TO the pods of their namespace their selector matches,
+// matchExpressions included, that the empty selector applies to all of them, and the policy properties.
+func TestNetworkPolicy_AppliesTo(t *testing.T) {
+	pod := func(name, namespace string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: apitypes.UID("uid-" + name), Labels: labels}}
+	}
+	objects := []runtime.Object{
+		pod("web", "shop", map[string]string{"app": "web", "tier": "frontend"}),
+		pod("api", "shop", map[string]string{"app": "api", "tier": "backend"}),
+		pod("db", "shop", map[string]string{"app": "db", "tier": "backend", "pci": "true"}),
+		pod("other-web", "other", map[string]string{"app": "web", "tier": "frontend"}),
+		networkPolicy("default-deny", metav1.LabelSelector{}, networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		}),
+		networkPolicy("web", metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}, networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{}, {}},
+		}),
+		networkPolicy("backend", metav1.LabelSelector{
+			MatchLabels:      map[string]string{"tier": "backend"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "pci", Operator: metav1.LabelSelectorOpDoesNotExist}},
+		}, networkingv1.NetworkPolicySpec{Egress: []networkingv1.NetworkPolicyEgressRule{{}}}),
+		networkPolicy("tiers", metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "backend"}}},
+		}, networkingv1.NetworkPolicySpec{}),
+	}
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range g.Relationships {
+			if r.RelationshipType == "APPLIES_TO" {
+				got = append(got, r.Source.Name+" -> "+r.Target.Namespace+"/"+r.Target.Name)
+			}
+		}
+		sort.Strings(got)
+		want := []string{
+			"backend -> shop/api",
+			"default-deny -> shop/api",
+			"default-deny -> shop/db",
+			"default-deny -> shop/web",
+			"tiers -> shop/api",
+			"tiers -> shop/db",
+			"tiers -> shop/web",
+			"web -> shop/web",
+		}
+		if len(got) != len(want) {
+			t.Fatalf("trim=%v: APPLIES_TO = %q, want %q", trim, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("trim=%v: APPLIES_TO %d = %q, want %q", trim, i, got[i], want[i])
+			}
+		}
+
+		props := make(map[string]map[string]string)
+		for _, n := range g.Nodes {
+			if n.Key.Kind == "NetworkPolicy" {
+				props[n.Key.Name] = n.Properties
+			}
+		}
+		for name, want := range map[string][3]string{
+			"default-deny": {"Ingress,Egress", "0", "0"},
+			"web":          {"Ingress", "2", "0"},
+			"backend":      {"Ingress,Egress", "0", "1"},
+		} {
+			p := props[name]
+			if got := [3]string{p["spec.policyTypes"], p["ingressRules"], p["egressRules"]}; got != want {
+				t.Errorf("trim=%v: %s policyTypes, ingressRules, egressRules = %q, want %q", trim, name, got, want)
+			}
+		}
+		if sel := props["backend"]["spec.podSelector"]; sel != "!pci,tier=backend" {
+			t.Errorf("trim=%v: backend podSelector = %q", trim, sel)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/k8s"
+	"github.com/tthuwng/satellite/internal/server"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// cacheObjects serves /object from a single ResourceCache.
+type cacheObjects struct{ c *cache.ResourceCache }
+
+func (o cacheObjects) Object(_ string, key types.EntityKey) (runtime.Object, bool) {
+	return o.c.Get(key)
+}
+
+func (o cacheObjects) ObjectByUID(uid string) (runtime.Object, bool) {
+	return o.c.GetByUID(k8stypes.UID(uid))
+}
+
+func TestResourceCache_GetByUID(t *testing.T) {
+	c := cache.NewResourceCache()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cfg", Namespace: "ns", UID: "uid-1", ResourceVersion: "1"}}
+	c.Upsert(cm)
+	if obj, ok := c.GetByUID("uid-1"); !ok || obj != cm {
+		t.Fatalf("GetByUID(uid-1) = %v, %v", obj, ok)
+	}
+
+	// recreated under the same name: the old UID no longer resolves
+	recreated := cm.DeepCopy()
+	recreated.UID, recreated.ResourceVersion = "uid-2", "2"
+	c.Upsert(recreated)
+	if _, ok := c.GetByUID("uid-1"); ok {
+		t.Error("stale UID still resolves after recreation")
+	}
+	if _, ok := c.GetByUID("uid-2"); !ok {
+		t.Error("new UID does not resolve")
+	}
+
+	c.Delete(recreated)
+	if _, ok := c.GetByUID("uid-2"); ok {
+		t.Error("UID still resolves after delete")
+	}
+}
+
+func TestRedact_Secret(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "creds",
+			Annotations: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"password":"aHVudGVyMg=="}}`},
+		},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+		StringData: map[string]string{"token": "abc"},
+	}
+	out := k8s.Redact(secret).(*corev1.Secret)
+	if string(out.Data["password"]) != k8s.RedactedValue || out.StringData["token"] != k8s.RedactedValue {
+		t.Errorf("secret values not redacted: %v %v", out.Data, out.StringData)
+	}
+	if _, ok := out.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Error("last-applied-configuration annotation not dropped")
+	}
+	if out.Kind != "Secret" || out.APIVersion != "v1" {
+		t.Errorf("TypeMeta = %q %q, want v1 Secret", out.APIVersion, out.Kind)
+	}
+	if string(secret.Data["password"]) != "hunter2" {
+		t.Error("Redact modified the cached object")
+	}
+}
+
+func TestGraphServer_Object(t *testing.T) {
+	c := cache.NewResourceCache()
+	c.Upsert(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cfg", Namespace: "ns", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"db-url": "postgres://user:pass@db"},
+	})
+	srv := server.New()
+	srv.Objects = cacheObjects{c}
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, query := range []string{"kind=ConfigMap&namespace=ns&name=cfg", "uid=uid-1"} {
+		resp, err := http.Get(ts.URL + "/object?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cm corev1.ConfigMap
+		err = json.NewDecoder(resp.Body).Decode(&cm)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			t.Fatalf("%s: status %d, decode error %v", query, resp.StatusCode, err)
+		}
+		if cm.Name != "cfg" || cm.Kind != "ConfigMap" || cm.Data["db-url"] != k8s.RedactedValue {
+			t.Errorf("%s: got %+v", query, cm)
+		}
+	}
+
+	for _, query := range []string{"kind=ConfigMap&namespace=ns&name=missing", "uid=nope"} {
+		resp, err := http.Get(ts.URL + "/object?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", query, resp.StatusCode)
+		}
+	}
+}
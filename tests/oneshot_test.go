@@ -0,0 +1,101 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/oneshot"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// oneshotClient returns a fake client holding a pod and service in shop, a
+// pod in default and the node the shop pod runs on.
+func oneshotClient() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", UID: "node-uid"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b", UID: "node-b-uid"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "pod-uid", Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "svc-uid"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "other-uid"}},
+	)
+}
+
+// TestOneshot_Namespace verifies a namespaced one-shot graph holds the namespace's objects and only
+// the nodes they point at, without any watch.
+func TestOneshot_Namespace(t *testing.T) {
+	client := oneshotClient()
+	g, err := oneshot.Build(context.Background(), client, oneshot.Options{Namespace: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, node := range g.Nodes {
+		keys = append(keys, node.Key.Kind+"/"+node.Key.Name)
+	}
+	slices.Sort(keys)
+	if want := []string{"Node/node-a", "Pod/web", "Service/web"}; !slices.Equal(keys, want) {
+		t.Errorf("Nodes %v, want %v", keys, want)
+	}
+	if len(g.Relationships) != 3 {
+		t.Errorf("Expected SELECTS, SCHEDULED_ON and RUNS_AS, got %v", g.Relationships)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "list" {
+			t.Errorf("Unexpected %s of %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+// TestOneshot_ForbiddenKind verifies a kind that may not be listed is left out and recorded.
+func TestOneshot_ForbiddenKind(t *testing.T) {
+	client := oneshotClient()
+	client.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", nil)
+	})
+	g, err := oneshot.Build(context.Background(), client, oneshot.Options{Namespace: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 2 || !slices.Equal(g.Meta().DisabledKinds, []string{"Node"}) {
+		t.Errorf("Expected the pod and service with Node disabled, got %d nodes, disabled %v", len(g.Nodes), g.Meta().DisabledKinds)
+	}
+}
+
+// TestWriteDOT verifies the DOT rendering has a vertex per node and a labelled edge per relationship.
+func TestWriteDOT(t *testing.T) {
+	g, err := oneshot.Build(context.Background(), oneshotClient(), oneshot.Options{Namespace: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := graph.WriteDOT(&out, g); err != nil {
+		t.Fatal(err)
+	}
+	dot := out.String()
+	if !strings.HasPrefix(dot, "digraph satellite {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("Not a digraph: %s", dot)
+	}
+	if !strings.Contains(dot, `"Service/shop/web" -> "Pod/shop/web" [label="SELECTS"]`) {
+		t.Errorf("SELECTS edge missing: %s", dot)
+	}
+	if n := strings.Count(dot, "[label=\""); n != len(g.Nodes)+len(g.Relationships) {
+		t.Errorf("Expected %d labelled vertices and edges, got %d", len(g.Nodes)+len(g.Relationships), n)
+	}
+}
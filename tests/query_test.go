@@ -0,0 +1,164 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func nodeNames(nodes []graph.GraphNode) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Key.Name
+	}
+	return names
+}
+
+// TestGraph_MatchNodes verifies nodes are matched by kind, namespace and label selector, in graph order.
+func TestGraph_MatchNodes(t *testing.T) {
+	g := fixtureGraph().Indexed()
+	cases := []struct {
+		kind, namespace, selector string
+		want                      []string
+	}{
+		{"", "", "", []string{"node-1", "pod-a", "svc-a", "pod-b"}},
+		{"Pod", "", "", []string{"pod-a", "pod-b"}},
+		{"Pod", "team-b", "", []string{"pod-b"}},
+		{"", "", "app in (a,b)", []string{"pod-a", "pod-b"}},
+		{"Pod", "team-a", "app=b", nil},
+		{"Deployment", "", "", nil},
+	}
+	for _, c := range cases {
+		var selector labels.Selector
+		if c.selector != "" {
+			var err error
+			if selector, err = labels.Parse(c.selector); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := nodeNames(g.MatchNodes(c.kind, c.namespace, selector)); !equalStrings(got, c.want) {
+			t.Errorf("MatchNodes(%q, %q, %q) = %v, want %v", c.kind, c.namespace, c.selector, got, c.want)
+		}
+	}
+}
+
+// TestGraph_Neighbors verifies neighbors follow the direction and relationship types asked for.
+func TestGraph_Neighbors(t *testing.T) {
+	g := fixtureGraph()
+	node := graph.GraphEntityKey{Kind: "Node", Name: "node-1"}
+	podA := graph.GraphEntityKey{Kind: "Pod", Namespace: "team-a", Name: "pod-a"}
+
+	if got := nodeNames(g.Neighbors(node, graph.Incoming)); !equalStrings(got, []string{"pod-a", "pod-b"}) {
+		t.Errorf("Incoming neighbors of node-1 = %v", got)
+	}
+	if got := g.Neighbors(node, graph.Outgoing); len(got) != 0 {
+		t.Errorf("Outgoing neighbors of node-1 = %v", nodeNames(got))
+	}
+	if got := nodeNames(g.Neighbors(podA, graph.Both)); !equalStrings(got, []string{"node-1", "svc-a"}) {
+		t.Errorf("Neighbors of pod-a = %v", got)
+	}
+	if got := nodeNames(g.Neighbors(podA, graph.Both, "SELECTS")); !equalStrings(got, []string{"svc-a"}) {
+		t.Errorf("SELECTS neighbors of pod-a = %v", got)
+	}
+}
+
+// TestGraph_Traverse verifies traversals stop at depth and at relationships the filter rejects,
+// returning the induced subgraph, and that a graph's index follows changes to its slices.
+func TestGraph_Traverse(t *testing.T) {
+	g := fixtureGraph().Indexed()
+	svcA := graph.GraphEntityKey{Kind: "Service", Namespace: "team-a", Name: "svc-a"}
+
+	sub, ok := g.Traverse(svcA, 1, nil)
+	if !ok || !equalStrings(nodeNames(sub.Nodes), []string{"pod-a", "svc-a"}) || len(sub.Relationships) != 1 {
+		t.Errorf("Depth 1 from svc-a = %v, %d relationships", nodeNames(sub.Nodes), len(sub.Relationships))
+	}
+	sub, _ = g.Traverse(svcA, 3, nil)
+	if len(sub.Nodes) != 4 || len(sub.Relationships) != 3 || sub.GraphRevision != g.GraphRevision {
+		t.Errorf("Depth 3 from svc-a = %v, %d relationships", nodeNames(sub.Nodes), len(sub.Relationships))
+	}
+	sub, _ = g.Traverse(svcA, 3, func(rel graph.GraphRelationship, node graph.GraphNode) bool {
+		return node.Key.Namespace != "team-b"
+	})
+	if !equalStrings(nodeNames(sub.Nodes), []string{"node-1", "pod-a", "svc-a"}) {
+		t.Errorf("Filtered traversal from svc-a = %v", nodeNames(sub.Nodes))
+	}
+	if _, ok := g.Traverse(graph.GraphEntityKey{Kind: "Pod", Name: "absent"}, 1, nil); ok {
+		t.Error("Traverse from an absent node succeeded")
+	}
+
+	// the copy shares the index but not the slices: it must not see stale results
+	grown := g
+	grown.Nodes = append(append([]graph.GraphNode(nil), g.Nodes...), graph.GraphNode{Key: graph.GraphEntityKey{Kind: "Pod", Namespace: "team-a", Name: "pod-c"}})
+	if got := grown.MatchNodes("Pod", "team-a", nil); len(got) != 2 {
+		t.Errorf("Grown graph matched %v", nodeNames(got))
+	}
+	if got := g.MatchNodes("Pod", "team-a", nil); len(got) != 1 {
+		t.Errorf("Original graph matched %v", nodeNames(got))
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// queryBenchGraph returns the graph of a synthetic cache of about 10k objects.
+func queryBenchGraph(b *testing.B) graph.Graph {
+	level := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(level)
+	g, err := graph.BuildGraph(context.Background(), syntheticCache(10_000), 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return g
+}
+
+// BenchmarkGraph_MatchNodes measures repeated selector queries on one graph.
+func BenchmarkGraph_MatchNodes(b *testing.B) {
+	g := queryBenchGraph(b)
+	pod := g.MatchNodes("Pod", "", nil)[0]
+	selector := labels.SelectorFromSet(graph.NodeLabels(pod))
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if len(g.MatchNodes("Pod", pod.Key.Namespace, selector)) == 0 {
+			b.Fatal("no match")
+		}
+	}
+}
+
+// BenchmarkGraph_Neighbors measures repeated neighbor lookups on one graph.
+func BenchmarkGraph_Neighbors(b *testing.B) {
+	g := queryBenchGraph(b)
+	nodes := g.MatchNodes("Node", "", nil)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		g.Neighbors(nodes[i%len(nodes)].Key, graph.Incoming, "SCHEDULED_ON")
+	}
+}
+
+// BenchmarkGraph_Traverse measures repeated two-hop traversals on one graph.
+func BenchmarkGraph_Traverse(b *testing.B) {
+	g := queryBenchGraph(b)
+	services := g.MatchNodes("Service", "", nil)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := g.Traverse(services[i%len(services)].Key, 2, nil); !ok {
+			b.Fatal("service not found")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestQuota_Properties verifies ResourceQuotas and LimitRanges get one property per quantity, rendered like
+// Node capacity, and SCOPE their Namespace.
+func TestQuota_Properties(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", UID: "ns-shop"}})
+	resourceCache.Upsert(&corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "shop", UID: "quota-uid"},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			corev1.ResourceRequestsCPU: resource.MustParse("4"), corev1.ResourceLimitsMemory: resource.MustParse("8Gi"), "count/pods": resource.MustParse("20"),
+		}},
+		Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			corev1.ResourceRequestsCPU: resource.MustParse("2500m"), corev1.ResourceLimitsMemory: resource.MustParse("6144Mi"),
+		}},
+	})
+	resourceCache.Upsert(&corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "shop", UID: "lr-uid"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{
+				Type:           corev1.LimitTypeContainer,
+				Default:        corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+				DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				Max:            corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+			{Type: corev1.LimitTypePersistentVolumeClaim, Min: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}},
+		}},
+	})
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]string{
+		"compute": {
+			"spec.hard.requests.cpu": "4", "spec.hard.limits.memory": "8Gi", "spec.hard.count/pods": "20",
+			"status.used.requests.cpu": "2500m", "status.used.limits.memory": "6Gi",
+		},
+		"defaults": {
+			"limits.Container.default.memory": "512Mi", "limits.Container.defaultRequest.cpu": "100m",
+			"limits.Container.max.cpu": "2", "limits.PersistentVolumeClaim.min.storage": "1Gi",
+		},
+	}
+	for _, n := range g.Nodes {
+		for k, v := range want[n.Key.Name] {
+			if n.Properties[k] != v {
+				t.Errorf("%s %s = %q, want %q", n.Key.Name, k, n.Properties[k], v)
+			}
+		}
+	}
+	scoped := make(map[string]graph.GraphEntityKey)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "SCOPES" {
+			scoped[r.Source.Kind] = r.Target
+		}
+	}
+	ns := graph.GraphEntityKey{Kind: "Namespace", Name: "shop"}
+	if len(scoped) != 2 || scoped["ResourceQuota"] != ns || scoped["LimitRange"] != ns {
+		t.Errorf("SCOPES = %v, want both to %+v", scoped, ns)
+	}
+	if dangling := graph.DanglingRelationships(g); dangling != 0 {
+		t.Errorf("%d dangling relationships", dangling)
+	}
+}
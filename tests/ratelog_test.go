@@ -0,0 +1,58 @@
+package main_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/ratelog"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRatelog_LimitsPerKeyAndSummarizes(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetLevel(log.DebugLevel)
+	entry := log.NewEntry(logger)
+
+	limiter := ratelog.New(2, 100*time.Millisecond)
+	limiter.Logger = logger
+	for i := 0; i < 5; i++ {
+		limiter.Log(entry, log.DebugLevel, "Pod UPDATE", "pod update")
+		limiter.Log(entry, log.DebugLevel, "Node UPDATE", "node update")
+		limiter.Log(entry, log.ErrorLevel, "Pod UPDATE", "pod error")
+	}
+	if n := strings.Count(out.String(), "pod update"); n != 2 {
+		t.Errorf("pod update logged %d times, want 2", n)
+	}
+	if n := strings.Count(out.String(), "node update"); n != 2 {
+		t.Errorf("node update logged %d times, want 2 (keys are independent)", n)
+	}
+	if n := strings.Count(out.String(), "pod error"); n != 5 {
+		t.Errorf("errors logged %d times, want all 5", n)
+	}
+	if got := limiter.Suppressed(); got != 6 {
+		t.Errorf("Suppressed() = %d, want 6", got)
+	}
+
+	// the next line after the interval reports the previous window
+	time.Sleep(150 * time.Millisecond)
+	out.Reset()
+	limiter.Log(entry, log.DebugLevel, "Pod UPDATE", "pod update")
+	if !strings.Contains(out.String(), `Suppressed 3 \"Pod UPDATE\" log lines`) || !strings.Contains(out.String(), "pod update") {
+		t.Errorf("after rollover got %q", out.String())
+	}
+
+	// lines below the logger's level are neither written nor counted
+	logger.SetLevel(log.InfoLevel)
+	before := limiter.Suppressed()
+	for i := 0; i < 5; i++ {
+		limiter.Log(entry, log.DebugLevel, "Pod UPDATE", "pod update")
+	}
+	if limiter.Suppressed() != before {
+		t.Error("disabled-level lines were counted as suppressed")
+	}
+}
@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAccessReviews answers SelfSubjectAccessReviews, denying the given "<resource>.<verb>" pairs.
+func fakeAccessReviews(client *fake.Clientset, denied map[string]bool) {
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = !denied[attrs.Resource+"."+attrs.Verb]
+		return true, review, nil
+	})
+}
+
+func TestCheckPermissions_AllAllowed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeAccessReviews(client, nil)
+
+	if err := k8s.CheckPermissions(context.Background(), client, k8s.WatchedKinds); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestCheckPermissions_ConsolidatesMissing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeAccessReviews(client, map[string]bool{"pods.watch": true, "nodes.list": true})
+
+	err := k8s.CheckPermissions(context.Background(), client, k8s.WatchedKinds)
+	var missingErr *k8s.MissingPermissionsError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected *MissingPermissionsError, got %v", err)
+	}
+	if got, want := err.Error(), "missing permissions: pods.watch, nodes.list"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if missingErr.Missing[0].Kind != "Pod" || missingErr.Missing[1].Kind != "Node" {
+		t.Errorf("Unexpected kinds in %+v", missingErr.Missing)
+	}
+}
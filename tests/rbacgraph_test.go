@@ -0,0 +1,114 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func rbacMeta(name, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: namespace, UID: apitypes.UID("uid-" + namespace + "-" + name)}
+}
+
+// TestRBAC_Graph verifies bindings REFERENCE their role and are GRANTS_TO their ServiceAccount subjects, so
+// the roles a pod runs with can be followed from its RUNS_AS relationship, and the rule summaries.
+func TestRBAC_Graph(t *testing.T) {
+	group := rbacv1.GroupName
+	objects := []runtime.Object{
+		&corev1.Pod{ObjectMeta: rbacMeta("build-1", "ci"), Spec: corev1.PodSpec{ServiceAccountName: "builder"}},
+		&corev1.ServiceAccount{ObjectMeta: rbacMeta("builder", "ci")},
+		&rbacv1.Role{ObjectMeta: rbacMeta("deployer", "ci"), Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets"}, Verbs: []string{"get", "patch"}},
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+		}},
+		&rbacv1.ClusterRole{ObjectMeta: rbacMeta("view", ""), Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get", "list", "watch"}},
+			{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+		}},
+		&rbacv1.RoleBinding{ObjectMeta: rbacMeta("deployer", "ci"),
+			RoleRef:  rbacv1.RoleRef{APIGroup: group, Kind: "Role", Name: "deployer"},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "builder"}, {Kind: rbacv1.UserKind, APIGroup: group, Name: "alice"}},
+		},
+		&rbacv1.RoleBinding{ObjectMeta: rbacMeta("view", "ci"),
+			RoleRef:  rbacv1.RoleRef{APIGroup: group, Kind: "ClusterRole", Name: "view"},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Name: "builder"}},
+		},
+		// the builder in another namespace is a different ServiceAccount
+		&rbacv1.ClusterRoleBinding{ObjectMeta: rbacMeta("robots", ""),
+			RoleRef:  rbacv1.RoleRef{APIGroup: group, Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: "kube-system", Name: "builder"}, {Kind: rbacv1.GroupKind, APIGroup: group, Name: "system:masters"}},
+		},
+	}
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "ServiceAccount", "Role", "ClusterRole", "RoleBinding", "ClusterRoleBinding"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// what can build-1 do: its ServiceAccount, the bindings granting to it and their roles
+		pod := graph.GraphEntityKey{Kind: "Pod", Namespace: "ci", Name: "build-1"}
+		var sa graph.GraphEntityKey
+		grants := make(map[graph.GraphEntityKey][]graph.GraphEntityKey)
+		references := make(map[graph.GraphEntityKey]graph.GraphRelationship)
+		for _, r := range g.Relationships {
+			switch {
+			case r.Source == pod && r.RelationshipType == "RUNS_AS":
+				sa = r.Target
+			case r.RelationshipType == "GRANTS_TO":
+				grants[r.Target] = append(grants[r.Target], r.Source)
+			case r.RelationshipType == "REFERENCES":
+				references[r.Source] = r
+			}
+		}
+		var roles []string
+		for _, binding := range grants[sa] {
+			role := references[binding].Target
+			roles = append(roles, role.APIGroup+" "+role.Kind+" "+role.Namespace+"/"+role.Name)
+		}
+		sort.Strings(roles)
+		want := []string{group + " ClusterRole /view", group + " Role ci/deployer"}
+		if len(roles) != len(want) || roles[0] != want[0] || roles[1] != want[1] {
+			t.Errorf("trim=%v: roles of build-1 = %q, want %q", trim, roles, want)
+		}
+		if robots := grants[graph.GraphEntityKey{Kind: "ServiceAccount", Namespace: "kube-system", Name: "builder"}]; len(robots) != 1 || robots[0].Namespace != "" {
+			t.Errorf("trim=%v: kube-system/builder granted by %v, want the ClusterRoleBinding", trim, robots)
+		}
+		if r := references[graph.GraphEntityKey{Kind: "ClusterRoleBinding", APIGroup: group, Name: "robots"}]; r.Properties[graph.MissingTargetProperty] != "true" {
+			t.Errorf("trim=%v: reference to the absent cluster-admin = %+v", trim, r)
+		}
+
+		props := make(map[string]map[string]string)
+		for _, n := range g.Nodes {
+			props[n.Key.Kind+"/"+n.Key.Name] = n.Properties
+		}
+		for key, want := range map[string]map[string]string{
+			"Role/deployer":             {"rules": "2", "rules.verbs": "get,list,patch", "rules.resources": "3", "rules.wildcard": "false"},
+			"ClusterRole/view":          {"rules": "2", "rules.verbs": "get,list,watch", "rules.resources": "1", "rules.nonResourceURLs": "1", "rules.wildcard": "true"},
+			"RoleBinding/deployer":      {"roleRef": "Role/deployer", "subjects.ServiceAccount": "1", "subjects.User": "1"},
+			"ClusterRoleBinding/robots": {"roleRef": "ClusterRole/cluster-admin", "subjects.Group": "1"},
+		} {
+			for k, v := range want {
+				if props[key][k] != v {
+					t.Errorf("trim=%v: %s %s = %q, want %q", trim, key, k, props[key][k], v)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/admin"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/pipeline"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestRelationshipRules verifies rules derive relationships from annotations, nested spec fields
+// and list-valued expressions, and count objects they fail on.
+func TestRelationshipRules(t *testing.T) {
+	rules, err := graph.NewRelationshipRules([]graph.RelationshipRule{
+		{
+			Name: "parent-app", SourceKind: "Deployment", Type: "PART_OF",
+			Target: graph.RelationshipTarget{APIVersion: "argoproj.io/v1alpha1", Kind: "Application", Name: `{.metadata.annotations.acme\.io/parent-app}`, Namespace: "{.metadata.labels.tenant}"},
+		},
+		{
+			Name: "priority", SourceKind: "Deployment", Type: "USES_PRIORITY",
+			Target: graph.RelationshipTarget{APIVersion: "scheduling.k8s.io/v1", Kind: "PriorityClass", Name: ".spec.template.spec.priorityClassName", ClusterScoped: true},
+		},
+		{
+			Name: "claims", SourceKind: "Pod", Type: "CLAIMS",
+			Target: graph.RelationshipTarget{Kind: "PersistentVolumeClaim", Name: "{.spec.volumes[*].persistentVolumeClaim.claimName}"},
+		},
+		{
+			Name: "broken", SourceKind: "Pod", Type: "BROKEN",
+			Target: graph.RelationshipTarget{Kind: "Thing", Name: "{.spec.volumes}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cache.NewResourceCache()
+	c.Upsert(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web", Namespace: "shop", UID: "web",
+			Annotations: map[string]string{"acme.io/parent-app": "storefront"},
+			Labels:      map[string]string{"tenant": "argocd"},
+		},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{PriorityClassName: "critical"}}},
+	})
+	// no annotation and no priority class: nothing to derive, and no error
+	c.Upsert(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "shop", UID: "plain"}})
+	claim := func(name string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name}}}
+	}
+	c.Upsert(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "shop", UID: "db-0"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+			claim("data"),
+			{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			claim("wal"),
+		}},
+	})
+
+	errorsBefore := testutil.ToFloat64(metrics.RelationshipRuleErrors.WithLabelValues("broken"))
+	g, err := graph.BuildGraph(context.Background(), c, 1, graph.WithRelationshipRules(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rel := range g.Relationships {
+		if rule := rel.Properties[graph.RuleProperty]; rule != "" {
+			got = append(got, rule+": "+rel.Source.Name+" -"+rel.RelationshipType+"-> "+rel.Target.Kind+"/"+rel.Target.APIGroup+"/"+rel.Target.Namespace+"/"+rel.Target.Name)
+		}
+	}
+	slices.Sort(got)
+	want := []string{
+		"claims: db-0 -CLAIMS-> PersistentVolumeClaim//shop/data",
+		"claims: db-0 -CLAIMS-> PersistentVolumeClaim//shop/wal",
+		"parent-app: web -PART_OF-> Application/argoproj.io/argocd/storefront",
+		"priority: web -USES_PRIORITY-> PriorityClass/scheduling.k8s.io//critical",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Rule relationships:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if n := testutil.ToFloat64(metrics.RelationshipRuleErrors.WithLabelValues("broken")) - errorsBefore; n != 1 {
+		t.Errorf("broken rule errors = %v, want 1", n)
+	}
+}
+
+// TestRelationshipRules_TrimObjects verifies rules still see fields trimming would drop, such as a
+// Deployment's pod template, when a pipeline trims objects.
+func TestRelationshipRules_TrimObjects(t *testing.T) {
+	rules, err := graph.NewRelationshipRules([]graph.RelationshipRule{{
+		Name: "priority", SourceKind: "Deployment", Type: "USES_PRIORITY",
+		Target: graph.RelationshipTarget{APIVersion: "scheduling.k8s.io/v1", Kind: "PriorityClass", Name: ".spec.template.spec.priorityClassName", ClusterScoped: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"},
+		Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{PriorityClassName: "critical"}}},
+	})
+	p, err := pipeline.New("", client, nil, nil, pipeline.Options{
+		Kinds:             map[string]bool{"Deployment": true},
+		SkipPreflight:     true,
+		TrimObjects:       true,
+		RelationshipRules: rules,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer p.Shutdown()
+	defer cancel()
+	// the pipeline signals changed once it has synced
+	changed := make(chan struct{}, 1)
+	p.Start(ctx, &admin.Health{}, changed)
+	select {
+	case <-changed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Pipeline not synced")
+	}
+
+	g, err := p.Build(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := graph.GraphEntityKey{Kind: "PriorityClass", APIGroup: "scheduling.k8s.io", Name: "critical"}
+	for _, rel := range g.Relationships {
+		if rel.RelationshipType == "USES_PRIORITY" && rel.Target == target {
+			return
+		}
+	}
+	t.Errorf("No USES_PRIORITY relationship to %+v in %+v", target, g.Relationships)
+}
+
+// TestRelationshipRulesValidation verifies bad rules, including bad JSONPath syntax, are rejected.
+func TestRelationshipRulesValidation(t *testing.T) {
+	valid := graph.RelationshipRule{Name: "r", SourceKind: "Pod", Type: "USES", Target: graph.RelationshipTarget{Kind: "Thing", Name: "{.metadata.name}"}}
+	cases := map[string]func(r *graph.RelationshipRule){
+		"no name":             func(r *graph.RelationshipRule) { r.Name = "" },
+		"no source kind":      func(r *graph.RelationshipRule) { r.SourceKind = "" },
+		"type with space":     func(r *graph.RelationshipRule) { r.Type = "PART OF" },
+		"no target name":      func(r *graph.RelationshipRule) { r.Target.Name = "" },
+		"unterminated array":  func(r *graph.RelationshipRule) { r.Target.Name = "{.spec.volumes[}" },
+		"unclosed expression": func(r *graph.RelationshipRule) { r.Target.Namespace = "{.metadata.labels.tenant" },
+		"template":            func(r *graph.RelationshipRule) { r.Target.Name = "app-{.metadata.name}" },
+		"bad field path":      func(r *graph.RelationshipRule) { r.Target.Kind = ".spec[" },
+		"namespaced cluster":  func(r *graph.RelationshipRule) { r.Target.ClusterScoped, r.Target.Namespace = true, "shop" },
+	}
+	for name, mutate := range cases {
+		r := valid
+		mutate(&r)
+		if _, err := graph.NewRelationshipRules([]graph.RelationshipRule{r}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, err := graph.NewRelationshipRules([]graph.RelationshipRule{valid, valid}); err == nil {
+		t.Error("duplicate names accepted")
+	}
+
+	cfg, err := loadConfig(t, `
+relationshipRules:
+  - name: parent-app
+    sourceKind: Deployment
+    type: PART_OF
+    target: {kind: Application, name: '{.metadata.annotations.acme\.io/parent-app}'}
+`)
+	if err != nil || len(cfg.RelationshipRules) != 1 {
+		t.Fatalf("Load = %+v, %v", cfg, err)
+	}
+	if _, err := loadConfig(t, "relationshipRules: [{name: a, sourceKind: Pod, type: X, target: {kind: Y, name: '{.a[}'}}]"); err == nil || !strings.Contains(err.Error(), "JSONPath") {
+		t.Errorf("Load with bad JSONPath: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/audit"
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/types"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// replayLog writes an audit log to a new directory: web-0 added at 14:30:10,
+// web-1 at 14:31:10, web-0 deleted at 14:32:50 (recorded without its
+// object), plus a corrupt line and a record from before all of them.
+func replayLog(t *testing.T) string {
+	t.Helper()
+	base := time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)
+	record := func(at time.Duration, eventType, name string, withObject bool) string {
+		rec := audit.Record{Time: base.Add(at), Type: eventType, Kind: "Pod", Namespace: "shop", Name: name, UID: name + "-uid", ResourceVersion: "1"}
+		if withObject {
+			rec.Object, _ = json.Marshal(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID(name + "-uid"), ResourceVersion: "1"}})
+		}
+		line, _ := json.Marshal(rec)
+		return string(line)
+	}
+	lines := []string{
+		record(10*time.Second, "ADD", "web-0", true),
+		`{"time":"2024-05-01T14:30:`,
+		record(70*time.Second, "ADD", "web-1", true),
+		record(5*time.Second, "ADD", "late", true),
+		record(170*time.Second, "DELETE", "web-0", false),
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, audit.CurrentFile), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// replayed replays dir with opts and returns the pods cached at every emit.
+func replayed(t *testing.T, opts audit.ReplayOptions) (map[time.Time][]string, []*audit.LineError) {
+	t.Helper()
+	var skipped []*audit.LineError
+	opts.Skipped = func(e *audit.LineError) { skipped = append(skipped, e) }
+	resourceCache := cache.NewResourceCache()
+	states := make(map[time.Time][]string)
+	err := audit.Replay(context.Background(), opts, func(string) *cache.ResourceCache { return resourceCache }, func(_ context.Context, at time.Time) error {
+		var pods []string
+		for _, name := range []string{"web-0", "web-1", "late"} {
+			if _, found := resourceCache.Get(types.EntityKey{Kind: "Pod", Namespace: "shop", Name: name}); found {
+				pods = append(pods, name)
+			}
+		}
+		states[at] = pods
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return states, skipped
+}
+
+// TestReplay_Intervals verifies a replay emits at every interval boundary passed and at the end,
+// and reports the corrupt and out-of-order lines with their line numbers.
+func TestReplay_Intervals(t *testing.T) {
+	dir := replayLog(t)
+	states, skipped := replayed(t, audit.ReplayOptions{Dir: dir, Interval: time.Minute})
+
+	want := map[string]string{
+		"14:31:00": "web-0",
+		"14:32:00": "web-0,web-1",
+		"14:32:50": "web-1", // end of the log
+	}
+	if len(states) != len(want) {
+		t.Errorf("Expected %d emits, got %v", len(want), states)
+	}
+	for at, pods := range states {
+		if got := strings.Join(pods, ","); want[at.Format("15:04:05")] != got {
+			t.Errorf("At %s: pods %q, want %q", at.Format("15:04:05"), got, want[at.Format("15:04:05")])
+		}
+	}
+
+	if len(skipped) != 2 || skipped[0].Line != 2 || skipped[1].Line != 4 {
+		t.Fatalf("Expected lines 2 and 4 skipped, got %v", skipped)
+	}
+	if !strings.Contains(skipped[1].Error(), "older than the previous") {
+		t.Errorf("Unexpected out-of-order error: %v", skipped[1])
+	}
+}
+
+// TestReplay_At verifies a replay stops at the target time and emits the state as of then.
+func TestReplay_At(t *testing.T) {
+	at := time.Date(2024, 5, 1, 14, 31, 30, 0, time.UTC)
+	states, _ := replayed(t, audit.ReplayOptions{Dir: replayLog(t), At: at})
+	if len(states) != 1 || strings.Join(states[at], ",") != "web-0,web-1" {
+		t.Errorf("Expected one emit at 14:31:30 with both pods, got %v", states)
+	}
+}
+
+// TestReplay_RecordedVersions verifies objects replay at the apiVersion they were recorded with: an
+// autoscaling/v2 HPA keeps its metrics and SCALES relationship, and a resource the scheme doesn't know,
+// as watched with --extra-resources, decodes unstructured.
+func TestReplay_RecordedVersions(t *testing.T) {
+	cpu := int32(70)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", UID: "api-hpa-uid", ResourceVersion: "1"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+			MaxReplicas:    10,
+			Metrics: []autoscalingv2.MetricSpec{{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+				Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &cpu},
+			}}},
+		},
+	}
+	dir := t.TempDir()
+	w, err := audit.NewWriter(audit.Options{Dir: dir, Objects: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Run()
+	w.Observe(cache.Event{Type: "ADD", Kind: "HorizontalPodAutoscaler", Object: hpa})
+	w.Observe(cache.Event{Type: "ADD", Kind: "Certificate", Object: certificate("web-cert", "True")})
+	w.Close()
+
+	resourceCache := cache.NewResourceCache()
+	var skipped []*audit.LineError
+	err = audit.Replay(context.Background(), audit.ReplayOptions{Dir: dir, Skipped: func(e *audit.LineError) { skipped = append(skipped, e) }},
+		func(string) *cache.ResourceCache { return resourceCache }, func(context.Context, time.Time) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("Skipped %v", skipped)
+	}
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := make(map[string]map[string]string)
+	for _, n := range g.Nodes {
+		props[n.Key.Kind] = n.Properties
+	}
+	if got := props["HorizontalPodAutoscaler"]["metrics.resource.cpu.target"]; got != "70%" {
+		t.Errorf("Replayed HPA metrics.resource.cpu.target = %q, want 70%%", got)
+	}
+	if got := props["Certificate"]["status.ready"]; got != "True" {
+		t.Errorf("Replayed Certificate status.ready = %q, want True", got)
+	}
+	scales := 0
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "SCALES" {
+			scales++
+		}
+	}
+	if scales != 1 {
+		t.Errorf("Replayed HPA has %d SCALES relationships, want 1", scales)
+	}
+
+	// a DELETE without its object, in a log written before records carried apiVersion
+	obj, err := audit.Record{Type: "DELETE", Kind: "HorizontalPodAutoscaler", Namespace: "shop", Name: "api"}.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj.(*autoscalingv2.HorizontalPodAutoscaler); !ok {
+		t.Errorf("Decoded %T, want the watched autoscaling/v2 type", obj)
+	}
+}
+
+// TestReplay_EmptyDir verifies a directory without an audit log is an error.
+func TestReplay_EmptyDir(t *testing.T) {
+	err := audit.Replay(context.Background(), audit.ReplayOptions{Dir: t.TempDir()}, nil, nil)
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("Expected an error for an empty directory, got %v", err)
+	}
+}
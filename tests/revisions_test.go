@@ -0,0 +1,157 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/server"
+)
+
+func TestRevisionLog_BoundedAndConcurrent(t *testing.T) {
+	l := emitter.NewRevisionLog(5)
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(rev uint64) {
+			defer wg.Done()
+			l.Record(graph.Graph{GraphRevision: rev}, "")
+			_ = l.List()
+		}(uint64(i))
+	}
+	wg.Wait()
+	if n := len(l.List()); n != 5 {
+		t.Fatalf("log holds %d revisions, want 5", n)
+	}
+
+	l = emitter.NewRevisionLog(3)
+	for rev := uint64(1); rev <= 4; rev++ {
+		l.Record(graph.Graph{GraphRevision: rev, Nodes: make([]graph.GraphNode, rev)}, "")
+	}
+	list := l.List()
+	if list[0].Revision != 4 || list[2].Revision != 2 || list[0].Nodes != 4 {
+		t.Errorf("List() = %+v, want revisions 4,3,2 newest first", list)
+	}
+	if _, ok := l.Get(1); ok {
+		t.Error("evicted revision 1 still present")
+	}
+}
+
+func TestGraphServer_Revisions(t *testing.T) {
+	dir := t.TempDir()
+	revisions := emitter.NewRevisionLog(10)
+	sink := emitter.FileSink{Dir: dir, Revisions: revisions}
+	g := fixtureGraph()
+	if err := sink.Emit(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.New()
+	srv.Revisions = revisions
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/revisions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list server.RevisionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil || len(list.Revisions) != 1 {
+		t.Fatalf("/revisions = %+v, %v", list, err)
+	}
+	rec := list.Revisions[0]
+	if rec.Revision != g.GraphRevision || rec.Hash != graph.ContentHash(g) || rec.Nodes != 4 || rec.Relationships != 3 || rec.File == "" {
+		t.Errorf("record = %+v", rec)
+	}
+
+	url := fmt.Sprintf("%s/revisions/%d", ts.URL, g.GraphRevision)
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	onDisk, _ := os.ReadFile(rec.File)
+	if resp.StatusCode != http.StatusOK || string(body) != string(onDisk) {
+		t.Fatalf("/revisions/%d: status %d, body matches file: %v", g.GraphRevision, resp.StatusCode, string(body) == string(onDisk))
+	}
+
+	// rotated away
+	if err := os.Remove(rec.File); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{url, ts.URL + "/revisions/999"} {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", u, resp.StatusCode)
+		}
+	}
+}
+
+func TestGraphServer_Diff(t *testing.T) {
+	revisions := emitter.NewRevisionLog(10)
+	sink := emitter.FileSink{Dir: t.TempDir(), Revisions: revisions}
+	old := fixtureGraph()
+	if err := sink.Emit(context.Background(), old); err != nil {
+		t.Fatal(err)
+	}
+	current := fixtureGraph()
+	current.GraphRevision = old.GraphRevision + 1
+	current.Nodes[1].Properties = map[string]string{"labels": "app=a", "status.phase": "Failed"}
+
+	srv := server.New()
+	srv.Revisions = revisions
+	if err := srv.Update(current); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	get := func(query string) (int, graph.Delta) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/diff?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var d graph.Delta
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, d
+	}
+
+	code, d := get(fmt.Sprintf("from=%d", old.GraphRevision))
+	if code != http.StatusOK || d.FromRevision != old.GraphRevision || d.ToRevision != current.GraphRevision {
+		t.Fatalf("status %d, delta %+v", code, d)
+	}
+	if len(d.UpdatedNodes) != 1 || len(d.UpdatedNodes[0].ChangedKeys) != 1 || d.UpdatedNodes[0].ChangedKeys[0] != "status.phase" {
+		t.Errorf("updated nodes = %+v, want pod-a with status.phase changed", d.UpdatedNodes)
+	}
+	if code, d := get(fmt.Sprintf("from=%d&to=%d", old.GraphRevision, old.GraphRevision)); code != http.StatusOK || !d.Empty() {
+		t.Errorf("identical revisions: status %d, delta %+v; want an empty delta", code, d)
+	}
+	if code, _ := get("from=1"); code != http.StatusNotFound {
+		t.Errorf("unknown revision status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,77 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// rolloutReplicaSet returns a ReplicaSet of the api Deployment created for revision.
+func rolloutReplicaSet(name, revision string, annotations map[string]string) *appsv1.ReplicaSet {
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations["deployment.kubernetes.io/revision"] = revision
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: "shop", UID: apitypes.UID(name + "-uid"), Annotations: annotations,
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"}},
+	}}
+}
+
+// TestRollouts verifies ReplicaSets are ordered by revision, chained with SUPERSEDES and the
+// one of the Deployment's revision is marked current.
+func TestRollouts(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name: "api", Namespace: "shop", UID: "api-uid",
+		Annotations: map[string]string{"deployment.kubernetes.io/revision": "10"},
+	}})
+	// revision 10 sorts before 9 by name and as a string
+	resourceCache.Upsert(rolloutReplicaSet("api-a", "10", map[string]string{"kubernetes.io/change-cause": "kubectl set image deploy/api api=api:v3"}))
+	resourceCache.Upsert(rolloutReplicaSet("api-b", "9", nil))
+	resourceCache.Upsert(rolloutReplicaSet("api-c", "2", nil))
+	resourceCache.Upsert(rolloutReplicaSet("api-d", "", nil))
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := make(map[string]map[string]string)
+	for _, node := range g.Nodes {
+		if node.Key.Kind == "ReplicaSet" {
+			props[node.Key.Name] = node.Properties
+		}
+	}
+	if got := props["api-a"][graph.RolloutRevisionProperty]; got != "10" {
+		t.Errorf("rolloutRevision = %q, want 10", got)
+	}
+	if got := props["api-a"][graph.ChangeCauseProperty]; got != "kubectl set image deploy/api api=api:v3" {
+		t.Errorf("changeCause = %q", got)
+	}
+	if _, ok := props["api-b"][graph.ChangeCauseProperty]; ok {
+		t.Error("ReplicaSet without a change cause got changeCause")
+	}
+	for name, p := range props {
+		if current := p[graph.IsCurrentProperty] == "true"; current != (name == "api-a") {
+			t.Errorf("%s isCurrent = %v", name, current)
+		}
+	}
+
+	var supersedes []string
+	for _, rel := range g.Relationships {
+		if rel.RelationshipType == "SUPERSEDES" {
+			supersedes = append(supersedes, rel.Source.Name+">"+rel.Target.Name)
+		}
+	}
+	want := []string{"api-b>api-c", "api-a>api-b"}
+	if len(supersedes) != len(want) || supersedes[0] != want[0] || supersedes[1] != want[1] {
+		t.Errorf("SUPERSEDES = %v, want %v", supersedes, want)
+	}
+}
@@ -0,0 +1,220 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/config"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/rules"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func podKey(name string) graph.GraphEntityKey {
+	return graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: name}
+}
+
+func serviceKey(name string) graph.GraphEntityKey {
+	return graph.GraphEntityKey{Kind: "Service", Namespace: "shop", Name: name}
+}
+
+func nodeKey(name string) graph.GraphEntityKey {
+	return graph.GraphEntityKey{Kind: "Node", Name: name}
+}
+
+// rulesGraphs returns a graph of two pods on node-1 selected by Service web, and the next build, in
+// which web-0 moved to node-2, web-1 is gone, the ConfigMap of web-0 went missing and a pod was added.
+func rulesGraphs() (prev, next graph.Graph) {
+	nodes := func(keys ...graph.GraphEntityKey) []graph.GraphNode {
+		var out []graph.GraphNode
+		for _, k := range keys {
+			out = append(out, graph.GraphNode{Key: k, Properties: map[string]string{"state": "Running"}})
+		}
+		return out
+	}
+	rel := func(source, target graph.GraphEntityKey, typ string) graph.GraphRelationship {
+		return graph.GraphRelationship{Source: source, Target: target, RelationshipType: typ}
+	}
+	config := graph.GraphEntityKey{Kind: "ConfigMap", Namespace: "shop", Name: "web-config"}
+	prev = graph.Graph{
+		GraphRevision: 1,
+		Nodes:         nodes(podKey("web-0"), podKey("web-1"), serviceKey("web"), serviceKey("idle"), nodeKey("node-1"), nodeKey("node-2"), config),
+		Relationships: []graph.GraphRelationship{
+			rel(podKey("web-0"), nodeKey("node-1"), "SCHEDULED_ON"),
+			rel(podKey("web-1"), nodeKey("node-1"), "SCHEDULED_ON"),
+			rel(serviceKey("web"), podKey("web-0"), "SELECTS"),
+			rel(serviceKey("web"), podKey("web-1"), "SELECTS"),
+			rel(podKey("web-0"), config, "MOUNTS"),
+		},
+	}
+	next = graph.Graph{
+		GraphRevision: 2,
+		Nodes:         nodes(podKey("web-0"), podKey("api-0"), serviceKey("web"), serviceKey("idle"), nodeKey("node-1"), nodeKey("node-2")),
+		Relationships: []graph.GraphRelationship{
+			rel(podKey("web-0"), nodeKey("node-2"), "SCHEDULED_ON"),
+			rel(podKey("api-0"), nodeKey("node-2"), "SCHEDULED_ON"),
+			{Source: podKey("web-0"), Target: config, RelationshipType: "MOUNTS", Properties: map[string]string{graph.MissingTargetProperty: "true"}},
+		},
+	}
+	next.Nodes[0].Properties = map[string]string{"state": "Running", "restarts": "1"}
+	return prev, next
+}
+
+// TestRuleMatching verifies which changes each event type matches.
+func TestRuleMatching(t *testing.T) {
+	prev, next := rulesGraphs()
+	cases := map[string]struct {
+		match rules.Match
+		want  []string // matched node, relationship source or count, per event
+	}{
+		"pod added":          {rules.Match{Event: rules.NodeAdded, Kind: "Pod"}, []string{"api-0"}},
+		"pod removed":        {rules.Match{Event: rules.NodeRemoved, Kind: "Pod"}, []string{"web-1"}},
+		"other namespace":    {rules.Match{Event: rules.NodeRemoved, Namespace: "other"}, nil},
+		"any node removed":   {rules.Match{Event: rules.NodeRemoved}, []string{"web-1", "web-config"}},
+		"restarts changed":   {rules.Match{Event: rules.NodeUpdated, Property: "restarts"}, []string{"web-0"}},
+		"state unchanged":    {rules.Match{Event: rules.NodeUpdated, Property: "state"}, nil},
+		"scheduled":          {rules.Match{Event: rules.RelationshipAdded, RelationshipType: "SCHEDULED_ON"}, []string{"web-0", "api-0"}},
+		"deselected":         {rules.Match{Event: rules.RelationshipRemoved, Kind: "Service"}, []string{"web", "web"}},
+		"pod moved":          {rules.Match{Event: rules.RelationshipMoved, RelationshipType: "SCHEDULED_ON"}, []string{"web-0"}},
+		"backends lost":      {rules.Match{Event: rules.CountBelow, Kind: "Service", RelationshipType: "SELECTS", Below: 1}, []string{"web"}},
+		"backends below 2":   {rules.Match{Event: rules.CountBelow, Kind: "Service", RelationshipType: "SELECTS", Below: 2}, []string{"web"}},
+		"already below 3":    {rules.Match{Event: rules.CountBelow, Kind: "Service", RelationshipType: "SELECTS", Below: 3}, nil},
+		"pods below 2":       {rules.Match{Event: rules.CountBelow, Kind: "Pod", Below: 2}, nil},
+		"configmaps below 1": {rules.Match{Event: rules.CountBelow, Kind: "ConfigMap", Below: 1}, []string{"1->0"}},
+		"dependency missing": {rules.Match{Event: rules.MissingDependency}, []string{"web-0"}},
+		"missing other type": {rules.Match{Event: rules.MissingDependency, RelationshipType: "USES_SECRET"}, nil},
+	}
+	for name, c := range cases {
+		events := rules.Evaluate([]rules.Rule{{ID: name, Match: c.match}}, prev, next)
+		var got []string
+		for _, e := range events {
+			if e.Rule != name || e.Event != c.match.Event || e.Revision != 2 {
+				t.Errorf("%s: event %+v", name, e)
+			}
+			switch {
+			case e.Node != nil:
+				got = append(got, e.Node.Name)
+			case e.Relationship != nil:
+				got = append(got, e.Relationship.Source.Name)
+			case e.Count != nil:
+				got = append(got, fmt.Sprintf("%d->%d", e.Count.Previous, e.Count.Current))
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: matched %v, want %v", name, got, c.want)
+		}
+	}
+
+	moved := rules.Evaluate([]rules.Rule{{ID: "moved", Match: rules.Match{Event: rules.RelationshipMoved, RelationshipType: "SCHEDULED_ON"}}}, prev, next)
+	if len(moved) != 1 || moved[0].From.Name != "node-1" || moved[0].To.Name != "node-2" {
+		t.Errorf("Moved events = %+v", moved)
+	}
+}
+
+// TestRuleEngine verifies actions run from the second build on, receive the event as JSON, and are
+// rate limited per rule.
+func TestRuleEngine(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []rules.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var e rules.Event
+		if err := json.Unmarshal(data, &e); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Webhook got %s (%v)", data, err)
+		}
+		mu.Lock()
+		payloads = append(payloads, e)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "event.json")
+	engine := rules.NewEngine([]rules.Rule{
+		{ID: "scheduled", Match: rules.Match{Event: rules.RelationshipAdded, RelationshipType: "SCHEDULED_ON"}, Action: rules.Action{Webhook: srv.URL},
+			RateLimit: &rules.RateLimit{Events: 1, Interval: metav1.Duration{Duration: time.Hour}}},
+		{ID: "moved", Match: rules.Match{Event: rules.RelationshipMoved, RelationshipType: "SCHEDULED_ON"}, Action: rules.Action{Command: "tee " + out},
+			RateLimit: &rules.RateLimit{Events: 1, Interval: metav1.Duration{Duration: time.Hour}}},
+	})
+	prev, next := rulesGraphs()
+	ctx := context.Background()
+	engine.OnGraphBuilt(ctx, prev, nil)
+	engine.Wait()
+	if len(payloads) != 0 {
+		t.Fatalf("First build fired %d events", len(payloads))
+	}
+	for _, g := range []graph.Graph{next, prev, next} {
+		engine.OnGraphBuilt(ctx, g, nil)
+	}
+	engine.Wait()
+
+	// pods are scheduled, and web-0 moves, in every change, limited to 1 per hour
+	if len(payloads) != 1 || payloads[0].Rule != "scheduled" {
+		t.Errorf("Webhook payloads = %+v", payloads)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moved rules.Event
+	if err := json.Unmarshal(data, &moved); err != nil || moved.Rule != "moved" || moved.To == nil || moved.To.Name != "node-2" {
+		t.Errorf("Command stdin = %s (%v)", data, err)
+	}
+}
+
+// TestConfigLoad_Rules verifies rules are validated when the config is loaded.
+func TestConfigLoad_Rules(t *testing.T) {
+	valid := `
+rules:
+  - id: pod-moved
+    match: {event: relationshipMoved, kind: Pod, relationshipType: SCHEDULED_ON}
+    action: {webhook: "https://hooks.example.com/page"}
+    rateLimit: {events: 5, interval: 10m}
+  - id: no-backends
+    match: {event: countBelow, kind: Service, relationshipType: SELECTS, below: 1}
+    action: {command: /usr/local/bin/page}
+`
+	cfg, err := loadConfig(t, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) != 2 || cfg.Rules[0].RateLimit.Interval.Minutes() != 10 {
+		t.Errorf("Rules = %+v", cfg.Rules)
+	}
+
+	for name, rule := range map[string]string{
+		"duplicate id":  "{id: a, match: {event: nodeAdded}, action: {command: x}}, {id: a, match: {event: nodeAdded}, action: {command: x}}",
+		"unknown event": "{id: a, match: {event: podMoved}, action: {command: x}}",
+		"no below":      "{id: a, match: {event: countBelow, kind: Pod}, action: {command: x}}",
+		"two actions":   "{id: a, match: {event: nodeAdded}, action: {command: x, webhook: 'https://h'}}",
+		"no action":     "{id: a, match: {event: nodeAdded}}",
+		"bad webhook":   "{id: a, match: {event: nodeAdded}, action: {webhook: 'ftp://h'}}",
+		"property":      "{id: a, match: {event: nodeAdded, property: state}, action: {command: x}}",
+		"no move type":  "{id: a, match: {event: relationshipMoved}, action: {command: x}}",
+		"zero rate":     "{id: a, match: {event: nodeAdded}, action: {command: x}, rateLimit: {events: 0, interval: 1m}}",
+		"no id":         "{match: {event: nodeAdded}, action: {command: x}}",
+	} {
+		if _, err := loadConfig(t, "rules: ["+rule+"]"); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func loadConfig(t *testing.T, content string) (*config.Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return config.Load(path)
+}
@@ -0,0 +1,200 @@
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/runner"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// countingBuild returns a BuildFunc producing empty graphs and counting calls.
+func countingBuild(calls *atomic.Int32) runner.BuildFunc {
+	return func(_ context.Context, revision uint64) (graph.Graph, error) {
+		calls.Add(1)
+		return graph.Graph{GraphRevision: revision}, nil
+	}
+}
+
+// TestTrigger_Coalesces verifies triggers fired before the loop gets to them result in a single build.
+func TestTrigger_Coalesces(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, 0)
+	trigger := runner.NewTrigger()
+	var builds atomic.Int32
+	loop := runner.New(countingBuild(&builds), queue, make(chan struct{}), trigger.C())
+
+	for i := 0; i < 5; i++ {
+		trigger.Fire()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	go loop.Run(ctx)
+
+	sink.wait(t, 1)
+	if got := builds.Load(); got != 1 {
+		t.Errorf("Got %d builds for coalesced triggers, want 1", got)
+	}
+}
+
+// TestTrigger_SkipsMinEmitInterval verifies a triggered snapshot is emitted
+// right away, including over a graph already held for the minimum interval.
+func TestTrigger_SkipsMinEmitInterval(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, time.Hour)
+	trigger := runner.NewTrigger()
+	var builds atomic.Int32
+	loop := runner.New(countingBuild(&builds), queue, make(chan struct{}), trigger.C())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	if err := loop.BuildAndSubmit(ctx); err != nil {
+		t.Fatalf("BuildAndSubmit failed: %v", err)
+	}
+	sink.wait(t, 1)
+	// held for the hour-long interval
+	if err := loop.BuildAndSubmit(ctx); err != nil {
+		t.Fatalf("BuildAndSubmit failed: %v", err)
+	}
+	waitFor(t, func() bool { return queue.Stats().Held == 1 })
+
+	go loop.Run(ctx)
+	trigger.Fire()
+	sink.wait(t, 2)
+	if got := sink.snapshot(); got[1] != 3 {
+		t.Errorf("Triggered emit has revision %d, want 3", got[1])
+	}
+}
+
+// TestTrigger_Handler verifies POST /trigger fires the trigger and other methods are rejected.
+func TestTrigger_Handler(t *testing.T) {
+	trigger := runner.NewTrigger()
+	handler := trigger.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trigger", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /trigger = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("POST /trigger = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	select {
+	case <-trigger.C():
+	default:
+		t.Error("POST /trigger did not fire the trigger")
+	}
+}
+
+// TestRunner_SeqCatchesLostNotifications injects a change at each point of a
+// build cycle and swallows its notification; the last emitted graph must
+// still reflect the final cache state.
+func TestRunner_SeqCatchesLostNotifications(t *testing.T) {
+	for _, window := range []string{"before-build", "after-snapshot", "during-emit"} {
+		t.Run(window, func(t *testing.T) {
+			resourceCache := cache.NewResourceCache()
+			resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default", ResourceVersion: "1"}})
+			<-resourceCache.Changed() // the trigger starts the first build
+
+			var injected atomic.Bool
+			inject := func(at string) {
+				if at != window || !injected.CompareAndSwap(false, true) {
+					return
+				}
+				resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "last", Namespace: "default", ResourceVersion: "2"}})
+				// the notification is lost, as when drained by a concurrent wake-up
+				select {
+				case <-resourceCache.Changed():
+				default:
+				}
+			}
+
+			var mu sync.Mutex
+			var lastEmitted graph.Graph
+			queue := emitter.NewQueue(func(_ context.Context, g graph.Graph) error {
+				inject("during-emit")
+				mu.Lock()
+				defer mu.Unlock()
+				lastEmitted = g
+				return nil
+			}, 0)
+			build := func(ctx context.Context, revision uint64) (graph.Graph, error) {
+				inject("before-build")
+				g, err := graph.BuildGraph(ctx, resourceCache, revision)
+				inject("after-snapshot")
+				return g, err
+			}
+			trigger := runner.NewTrigger()
+			loop := runner.New(build, queue, resourceCache.Changed(), trigger.C())
+			loop.Seq = resourceCache.Seq
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go queue.Run(ctx)
+			go loop.Run(ctx)
+			trigger.Fire()
+
+			// the loop itself receives queue.Emitted() once Seq is set
+			waitFor(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, n := range lastEmitted.Nodes {
+					if n.Key.Name == "last" {
+						return true
+					}
+				}
+				return false
+			})
+		})
+	}
+}
+
+// TestRunner_FinalSkipsUnchanged verifies the final emit only builds a new revision if something changed since the last build.
+func TestRunner_FinalSkipsUnchanged(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1"}})
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, 0)
+	var builds atomic.Int32
+	loop := runner.New(countingBuild(&builds), queue, resourceCache.Changed(), nil)
+	loop.Seq = resourceCache.Seq
+
+	if err := loop.BuildAndSubmit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-resourceCache.Changed() // consumed by the loop in a real run
+	if err := loop.Final(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if builds.Load() != 1 || loop.Revision() != 1 {
+		t.Errorf("Unchanged cache: %d builds, revision %d; want 1 build, revision 1", builds.Load(), loop.Revision())
+	}
+	if got := sink.snapshot(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected the held revision 1 to be flushed, got %v", got)
+	}
+
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "2", Labels: map[string]string{"app": "web"}}})
+	if err := loop.Final(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.snapshot(); len(got) != 2 || got[1] != 2 {
+		t.Errorf("Expected a final build of revision 2 after a change, got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main_test
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/runner"
+)
+
+// TestTrigger_SignalForcesEmit verifies SIGUSR1 delivered to the signal channel produces an emit without any cache change.
+func TestTrigger_SignalForcesEmit(t *testing.T) {
+	sink := &recordingSink{}
+	queue := emitter.NewQueue(sink.emit, 0)
+	trigger := runner.NewTrigger()
+	var builds atomic.Int32
+	loop := runner.New(countingBuild(&builds), queue, make(chan struct{}), trigger.C())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	go loop.Run(ctx)
+
+	sigCh := make(chan os.Signal, 1)
+	go trigger.ForwardSignals(ctx, sigCh)
+	sigCh <- syscall.SIGUSR1
+
+	sink.wait(t, 1)
+	if got := sink.snapshot()[0]; got != 1 {
+		t.Errorf("Triggered emit has revision %d, want 1", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// TestScheduling_PodClasses verifies PriorityClass and RuntimeClass properties, the HAS_PRIORITY and
+// USES_RUNTIME relationships of pods naming them, none for a pod whose priority was only defaulted, and that
+// only a missing RuntimeClass is flagged.
+func TestScheduling_PodClasses(t *testing.T) {
+	priority := int32(1000000)
+	gvisor, kata := "gvisor", "kata"
+	pod := func(name, priorityClass string, runtimeClass *string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID(name + "-uid")},
+			Spec: corev1.PodSpec{ServiceAccountName: "default", PriorityClassName: priorityClass, RuntimeClassName: runtimeClass,
+				Priority: &priority},
+		}
+	}
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(defaultServiceAccount("shop"))
+	resourceCache.Upsert(&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high", UID: "high-uid"}, Value: priority, GlobalDefault: true})
+	resourceCache.Upsert(&nodev1.RuntimeClass{ObjectMeta: metav1.ObjectMeta{Name: gvisor, UID: "gvisor-uid"}, Handler: "runsc"})
+	resourceCache.Upsert(pod("sandboxed", "high", &gvisor))
+	resourceCache.Upsert(pod("defaulted", "", nil))
+	resourceCache.Upsert(pod("gone", "retired", &kata))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1,
+		graph.WithWatchedKinds([]string{"Pod", "ServiceAccount", "PriorityClass", "RuntimeClass"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range g.Nodes {
+		var want map[string]string
+		switch n.Key.Kind {
+		case "PriorityClass":
+			want = map[string]string{"value": "1000000", "globalDefault": "true"}
+		case "RuntimeClass":
+			want = map[string]string{"handler": "runsc"}
+		default:
+			continue
+		}
+		if n.Key.Namespace != "" {
+			t.Errorf("%s %s has namespace %q, want none", n.Key.Kind, n.Key.Name, n.Key.Namespace)
+		}
+		for k, v := range want {
+			if n.Properties[k] != v {
+				t.Errorf("%s %s %s = %q, want %q", n.Key.Kind, n.Key.Name, k, n.Properties[k], v)
+			}
+		}
+	}
+
+	got := make(map[string]string)
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "HAS_PRIORITY" || r.RelationshipType == "USES_RUNTIME" {
+			got[r.Source.Name+" "+r.RelationshipType+" "+r.Target.APIGroup+"/"+r.Target.Name] = r.Properties[graph.MissingTargetProperty]
+		}
+	}
+	want := map[string]string{
+		"sandboxed HAS_PRIORITY scheduling.k8s.io/high": "",
+		"sandboxed USES_RUNTIME node.k8s.io/gvisor":     "",
+		"gone HAS_PRIORITY scheduling.k8s.io/retired":   "",
+		"gone USES_RUNTIME node.k8s.io/kata":            "true",
+	}
+	if len(got) != len(want) {
+		t.Errorf("Relationships = %v, want %v", got, want)
+	}
+	for rel, missing := range want {
+		if m, ok := got[rel]; !ok || m != missing {
+			t.Errorf("%s: present=%v missingTarget=%q, want missingTarget=%q", rel, ok, m, missing)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/server"
+)
+
+func TestIndex_SearchRanking(t *testing.T) {
+	key := func(kind, name string) graph.GraphEntityKey {
+		return graph.GraphEntityKey{Kind: kind, Namespace: "default", Name: name}
+	}
+	idx := graph.NewIndex(graph.Graph{Nodes: []graph.GraphNode{
+		{Key: key("Pod", "web-7f9c"), Properties: map[string]string{}},
+		{Key: key("Pod", "api"), Properties: map[string]string{"labels": "app=web"}},
+		{Key: key("Service", "Web"), Properties: map[string]string{}},
+		{Key: key("Deployment", "frontend-web"), Properties: map[string]string{}},
+		{Key: key("Pod", "db"), Properties: map[string]string{"labels": "app=postgres"}},
+	}})
+
+	hits, truncated := idx.Search("WEB", "", 10)
+	want := []struct{ name, match string }{
+		{"Web", graph.MatchExact},
+		{"web-7f9c", graph.MatchPrefix},
+		{"frontend-web", graph.MatchSubstring},
+		{"api", graph.MatchLabel},
+	}
+	if truncated || len(hits) != len(want) {
+		t.Fatalf("hits = %+v (truncated=%v), want %d", hits, truncated, len(want))
+	}
+	for i, w := range want {
+		if hits[i].Node.Key.Name != w.name || hits[i].Match != w.match {
+			t.Errorf("hit %d = %s (%s), want %s (%s)", i, hits[i].Node.Key.Name, hits[i].Match, w.name, w.match)
+		}
+	}
+
+	if hits, _ := idx.Search("web", "Pod", 10); len(hits) != 2 {
+		t.Errorf("kind=Pod: got %d hits, want 2", len(hits))
+	}
+	if hits, truncated := idx.Search("web", "", 2); len(hits) != 2 || !truncated {
+		t.Errorf("limit=2: got %d hits, truncated=%v", len(hits), truncated)
+	}
+}
+
+func TestGraphServer_Search(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	g := fixtureGraph()
+	g.Nodes[1].Properties["status.phase"] = "Running"
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(ts.URL + "/search?q=pod-&kind=Pod&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var body server.SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Results) != 1 || !body.Truncated || body.Results[0].Key.Name != "pod-a" {
+		t.Fatalf("results = %+v, truncated = %v", body.Results, body.Truncated)
+	}
+	if body.Results[0].Properties["status.phase"] != "Running" {
+		t.Errorf("headline properties = %v", body.Results[0].Properties)
+	}
+
+	for _, q := range []string{"", "?q=x&limit=0"} {
+		resp, err := http.Get(ts.URL + "/search" + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("/search%s: status = %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// secretUsingPod returns a pod using the Secrets of canarySecrets, and some missing ones, in every way a pod can.
+func secretUsingPod() *corev1.Pod {
+	optional := true
+	keyRef := func(name string, optional *bool) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: "password", Optional: optional}}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid"},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "shop-tls"}}},
+				{Name: "tokens", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+					{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "tokens"}}},
+				}}}},
+			},
+			InitContainers: []corev1.Container{{Name: "migrate", Env: []corev1.EnvVar{{Name: "DB_PASSWORD", ValueFrom: keyRef("db", nil)}}}},
+			Containers: []corev1.Container{{
+				Name: "web",
+				Env: []corev1.EnvVar{
+					{Name: "LITERAL", Value: secretCanary},
+					{Name: "DB_PASSWORD", ValueFrom: keyRef("db", nil)},
+					{Name: "TLS_PASSWORD", ValueFrom: keyRef("shop-tls", nil)},
+					{Name: "FEATURE", ValueFrom: keyRef("flags", &optional)},
+				},
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}}}},
+			}},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "pull"}, {Name: "old-pull"}},
+		},
+	}
+}
+
+// TestSecrets_PodUsage verifies one relationship per Secret a pod uses, MOUNTS for volumes and USES_SECRET
+// otherwise, with every usage, and that only Secrets the pod needs to start are reported missing.
+func TestSecrets_PodUsage(t *testing.T) {
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		objects := append(canarySecrets(t, time.Now().Add(time.Hour)), secretUsingPod())
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "Secret"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := json.Marshal(g); strings.Contains(string(data), secretCanary) {
+			t.Errorf("trim=%v: secret value emitted: %s", trim, data)
+		}
+
+		type use struct{ relType, usage, missing string }
+		got := make(map[string]use)
+		for _, r := range g.Relationships {
+			if r.Target.Kind != "Secret" {
+				continue
+			}
+			if _, dup := got[r.Target.Name]; dup {
+				t.Errorf("trim=%v: duplicate relationship to %s", trim, r.Target.Name)
+			}
+			got[r.Target.Name] = use{r.RelationshipType, r.Properties[graph.UsageProperty], r.Properties[graph.MissingTargetProperty]}
+		}
+		want := map[string]use{
+			"shop-tls": {"MOUNTS", "env,volume", ""},
+			"tokens":   {"MOUNTS", "volume", "true"},
+			"db":       {"USES_SECRET", "env", ""},
+			"flags":    {"USES_SECRET", "env", ""},
+			"pull":     {"USES_SECRET", "imagePull", ""},
+			"old-pull": {"USES_SECRET", "imagePull", ""},
+		}
+		if len(got) != len(want) {
+			t.Errorf("trim=%v: relationships to Secrets = %v, want %v", trim, got, want)
+		}
+		for name, w := range want {
+			if got[name] != w {
+				t.Errorf("trim=%v: %s = %+v, want %+v", trim, name, got[name], w)
+			}
+		}
+		if g.Metadata == nil || g.Metadata.MissingDependencies != 1 {
+			t.Errorf("trim=%v: metadata = %+v, want 1 missing dependency", trim, g.Metadata)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package main_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/server"
+
+	"github.com/gorilla/websocket"
+)
+
+func newGraphServer(t *testing.T) (*server.Server, *httptest.Server) {
+	t.Helper()
+	srv := server.New()
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func getGraph(t *testing.T, url string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"/graph", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	// the default transport transparently gunzips; keep compression visible
+	tr := &http.Transport{DisableCompression: true}
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestGraphServer_UnavailableBeforeFirstBuild(t *testing.T) {
+	_, ts := newGraphServer(t)
+	if resp := getGraph(t, ts.URL, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestGraphServer_ETagAndGzip(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	g := fixtureGraph()
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := getGraph(t, ts.URL, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+	var decoded graph.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if len(decoded.Nodes) != len(g.Nodes) {
+		t.Errorf("got %d nodes, want %d", len(decoded.Nodes), len(g.Nodes))
+	}
+
+	resp = getGraph(t, ts.URL, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", resp.StatusCode)
+	}
+
+	// a rebuild with identical content but a new revision keeps the ETag
+	g.GraphRevision++
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	resp = getGraph(t, ts.URL, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("unchanged rebuild status = %d, want 304", resp.StatusCode)
+	}
+
+	g.Nodes[0].Properties = map[string]string{"changed": "true"}
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	resp = getGraph(t, ts.URL, map[string]string{"If-None-Match": etag, "Accept-Encoding": "gzip"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("changed graph status = %d, want 200", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == etag {
+		t.Error("ETag did not change with the content")
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+	}
+	gzipETag := resp.Header.Get("ETag")
+	identity := getGraph(t, ts.URL, nil)
+	if identity.Header.Get("ETag") == gzipETag {
+		t.Errorf("gzip and identity responses share ETag %s", gzipETag)
+	}
+	if resp := getGraph(t, ts.URL, map[string]string{"If-None-Match": gzipETag}); resp.StatusCode != http.StatusOK {
+		t.Errorf("identity request with the gzip ETag status = %d, want 200", resp.StatusCode)
+	}
+	if resp := getGraph(t, ts.URL, map[string]string{"If-None-Match": gzipETag, "Accept-Encoding": "gzip"}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("gzip request with the gzip ETag status = %d, want 304", resp.StatusCode)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decoding gzipped body: %v", err)
+	}
+}
+
+func TestGraphServer_Neighbors(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	srv.MaxNeighborDepth = 2
+	if err := srv.Update(fixtureGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) (*http.Response, graph.Graph) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/graph/neighbors?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var g graph.Graph
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, g
+	}
+
+	// svc-a -> pod-a at depth 1, plus pod-a -> node-1 at depth 2
+	resp, g := get("kind=Service&namespace=team-a&name=svc-a")
+	if resp.StatusCode != http.StatusOK || len(g.Nodes) != 2 || len(g.Relationships) != 1 {
+		t.Fatalf("depth 1: status %d, graph %+v", resp.StatusCode, g)
+	}
+	_, g = get("kind=Service&namespace=team-a&name=svc-a&depth=2")
+	if len(g.Nodes) != 3 || len(g.Relationships) != 2 {
+		t.Fatalf("depth 2: got %+v", g)
+	}
+	_, g = get("kind=Node&name=node-1")
+	if len(g.Nodes) != 3 {
+		t.Fatalf("Node depth 1 (incoming edges): got %+v", g.Nodes)
+	}
+
+	if resp, _ := get("kind=Pod&namespace=team-a&name=missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown entity status = %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := get("kind=Node&name=node-1&depth=3"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("depth over the limit status = %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := get("name=node-1"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing kind status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestGraphServer_Stream(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	g := fixtureGraph()
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/graph/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg server.Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	var full graph.Graph
+	if msg.Type != server.MessageSnapshot || json.Unmarshal(msg.Graph, &full) != nil || len(full.Nodes) != len(g.Nodes) {
+		t.Fatalf("first message = %+v, want the full snapshot", msg)
+	}
+
+	next := fixtureGraph()
+	next.GraphRevision = g.GraphRevision + 1
+	next.Nodes = next.Nodes[:3] // drop pod-b
+	next.Relationships = next.Relationships[:1]
+	if err := srv.Update(next); err != nil {
+		t.Fatal(err)
+	}
+	msg = server.Message{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != server.MessageDelta || msg.Delta == nil {
+		t.Fatalf("second message = %+v, want a delta", msg)
+	}
+	if msg.Delta.FromRevision != g.GraphRevision || len(msg.Delta.RemovedNodes) != 1 || len(msg.Delta.RemovedRelationships) != 2 {
+		t.Errorf("delta = %+v", msg.Delta)
+	}
+
+	srv.Close()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("after Close: err = %v, want a going-away close frame", err)
+	}
+}
+
+// sseClient reads "event:" names from a /graph/events response.
+type sseClient struct {
+	events chan string
+}
+
+func openEvents(t *testing.T, url, lastEventID string) *sseClient {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"/graph/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	c := &sseClient{events: make(chan string, 16)}
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				c.events <- name
+			}
+		}
+		close(c.events)
+	}()
+	return c
+}
+
+// next returns the next event name, or "" if none arrives within wait.
+func (c *sseClient) next(wait time.Duration) string {
+	select {
+	case name := <-c.events:
+		return name
+	case <-time.After(wait):
+		return ""
+	}
+}
+
+func TestGraphServer_EventsReconnect(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	g := fixtureGraph()
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	current := strconv.FormatUint(g.GraphRevision, 10)
+	stale := strconv.FormatUint(g.GraphRevision-1, 10)
+
+	fresh := openEvents(t, ts.URL, "")
+	if got := fresh.next(2 * time.Second); got != server.EventSnapshot {
+		t.Fatalf("new client: first event = %q, want snapshot", got)
+	}
+	missed := openEvents(t, ts.URL, stale)
+	if got := missed.next(2 * time.Second); got != server.EventSnapshot {
+		t.Fatalf("client that missed revisions: first event = %q, want snapshot", got)
+	}
+	resumed := openEvents(t, ts.URL, current)
+	if got := resumed.next(200 * time.Millisecond); got != "" {
+		t.Fatalf("up-to-date client: got %q, want no initial event", got)
+	}
+
+	// unchanged content only bumps the revision; changed content is a delta
+	g.GraphRevision++
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.next(2 * time.Second); got != server.EventRevision {
+		t.Errorf("unchanged rebuild: event = %q, want revision", got)
+	}
+	g.GraphRevision++
+	g.Nodes = g.Nodes[1:]
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.next(2 * time.Second); got != server.EventDelta {
+		t.Errorf("changed rebuild: event = %q, want delta", got)
+	}
+}
+
+func TestGraphServer_FilteredGraph(t *testing.T) {
+	srv, ts := newGraphServer(t)
+	if err := srv.Update(fixtureGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) (int, graph.Graph) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/graph?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var g graph.Graph
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, g
+	}
+
+	if _, g := get("kinds=Pod,Service&namespaces=team-a"); len(g.Nodes) != 2 || len(g.Relationships) != 1 {
+		t.Errorf("kinds+namespaces: got %+v", g)
+	}
+	if _, g := get("labelSelector=app%20in%20(a,b)"); len(g.Nodes) != 2 || len(g.Relationships) != 0 {
+		t.Errorf("labelSelector: got %+v", g)
+	}
+	if _, g := get("namespaces=team-b&external=true&relationships=none"); len(g.Nodes) != 2 || len(g.Relationships) != 0 {
+		t.Errorf("relationships=none: got %+v", g)
+	}
+	if _, g := get("relationships=only"); len(g.Nodes) != 0 || len(g.Relationships) != 3 {
+		t.Errorf("relationships=only: got %+v", g)
+	}
+	if code, _ := get("labelSelector=app%20in%20("); code != http.StatusBadRequest {
+		t.Errorf("invalid selector status = %d, want 400", code)
+	}
+	if code, _ := get("relationships=some"); code != http.StatusBadRequest {
+		t.Errorf("invalid relationships status = %d, want 400", code)
+	}
+}
+
+func TestGraphServer_Path(t *testing.T) {
+	node := graph.GraphEntityKey{Kind: "Node", Name: "node-1"}
+	svc := graph.GraphEntityKey{Kind: "Service", Namespace: "shop", Name: "web"}
+	podA := graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: "web-a"}
+	podB := graph.GraphEntityKey{Kind: "Pod", Namespace: "shop", Name: "web-b"}
+	rs := graph.GraphEntityKey{Kind: "ReplicaSet", APIGroup: "apps", Namespace: "shop", Name: "web-rs"}
+	cm := graph.GraphEntityKey{Kind: "ConfigMap", Namespace: "other", Name: "lonely"}
+	g := graph.Graph{
+		GraphRevision: 1,
+		Nodes: []graph.GraphNode{
+			{Key: node}, {Key: svc}, {Key: podA}, {Key: podB}, {Key: rs}, {Key: cm},
+		},
+		Relationships: []graph.GraphRelationship{
+			{Source: svc, Target: podA, RelationshipType: "SELECTS"},
+			{Source: svc, Target: podB, RelationshipType: "SELECTS"},
+			{Source: podA, Target: node, RelationshipType: "SCHEDULED_ON"},
+			{Source: podB, Target: node, RelationshipType: "SCHEDULED_ON"},
+			{Source: podA, Target: rs, RelationshipType: "OWNED_BY"},
+			{Source: podB, Target: rs, RelationshipType: "OWNED_BY"},
+		},
+	}
+	srv, ts := newGraphServer(t)
+	if err := srv.Update(g); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) (int, server.PathResponse) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/graph/path?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var pr server.PathResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, pr
+	}
+
+	// Service -> (web-a | web-b) -> Node: two equally short paths
+	code, pr := get("from=Service/shop/web&to=Node/node-1")
+	if code != http.StatusOK || len(pr.Paths) != 2 {
+		t.Fatalf("status %d, paths %+v; want 2 paths", code, pr.Paths)
+	}
+	via := map[string]bool{}
+	for _, p := range pr.Paths {
+		if len(p.Nodes) != 3 || len(p.Relationships) != 2 || p.Nodes[0].Key != svc || p.Nodes[2].Key != node {
+			t.Fatalf("malformed path %+v", p)
+		}
+		via[p.Nodes[1].Key.Name] = true
+	}
+	if !via["web-a"] || !via["web-b"] {
+		t.Errorf("paths go via %v, want web-a and web-b", via)
+	}
+
+	// reverse direction: ReplicaSet <- Pod -> Node
+	if code, pr := get("from=ReplicaSet/shop/web-rs&to=Node/node-1"); code != http.StatusOK || len(pr.Paths) != 2 {
+		t.Errorf("rs to node: status %d, %d paths; want 2", code, len(pr.Paths))
+	}
+	if code, _ := get("from=Service/shop/web&to=Node/node-1&maxDepth=1"); code != http.StatusNotFound {
+		t.Errorf("depth cutoff status = %d, want 404", code)
+	}
+	if code, _ := get("from=Service/shop/web&to=ConfigMap/other/lonely"); code != http.StatusNotFound {
+		t.Errorf("no path status = %d, want 404", code)
+	}
+	if code, _ := get("from=Service/shop/missing&to=Node/node-1"); code != http.StatusNotFound {
+		t.Errorf("unknown endpoint status = %d, want 404", code)
+	}
+	if code, _ := get("from=Service&to=Node/node-1"); code != http.StatusBadRequest {
+		t.Errorf("malformed ref status = %d, want 400", code)
+	}
+}
@@ -0,0 +1,87 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// defaultServiceAccount returns the ServiceAccount every namespace gets.
+func defaultServiceAccount(namespace string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: namespace, UID: apitypes.UID("sa-default-" + namespace)}}
+}
+
+// TestServiceAccounts_RunsAs verifies each pod RUNS_AS its ServiceAccount, "default" if none is named,
+// always in the pod's own namespace, and the ServiceAccount properties.
+func TestServiceAccounts_RunsAs(t *testing.T) {
+	automount := false
+	deployer := &corev1.ServiceAccount{
+		ObjectMeta:                   metav1.ObjectMeta{Name: "deployer", Namespace: "ci", UID: "sa-deployer"},
+		AutomountServiceAccountToken: &automount,
+		Secrets:                      []corev1.ObjectReference{{Name: "deployer-token"}},
+		ImagePullSecrets:             []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}},
+	}
+	pod := func(name, namespace, serviceAccount string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: apitypes.UID("uid-" + name)},
+			Spec:       corev1.PodSpec{ServiceAccountName: serviceAccount},
+		}
+	}
+	objects := []runtime.Object{
+		deployer, defaultServiceAccount("ci"), defaultServiceAccount("shop"),
+		pod("build", "ci", "deployer"),
+		pod("lint", "ci", ""),
+		// a same-named ServiceAccount in another namespace must not be linked
+		pod("web", "shop", "deployer"),
+	}
+	for _, trim := range []bool{false, true} {
+		resourceCache := cache.NewResourceCache()
+		for _, obj := range objects {
+			if trim {
+				obj = k8s.Trim(obj)
+			}
+			resourceCache.Upsert(obj)
+		}
+		g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "ServiceAccount"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range g.Relationships {
+			if r.RelationshipType == "RUNS_AS" {
+				got = append(got, r.Source.Namespace+"/"+r.Source.Name+" -> "+r.Target.Namespace+"/"+r.Target.Name+" missing="+r.Properties[graph.MissingTargetProperty])
+			}
+		}
+		sort.Strings(got)
+		want := []string{
+			"ci/build -> ci/deployer missing=",
+			"ci/lint -> ci/default missing=",
+			"shop/web -> shop/deployer missing=true",
+		}
+		if len(got) != len(want) {
+			t.Fatalf("trim=%v: RUNS_AS = %q, want %q", trim, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("trim=%v: RUNS_AS %d = %q, want %q", trim, i, got[i], want[i])
+			}
+		}
+		for _, n := range g.Nodes {
+			if n.Key.Kind == "ServiceAccount" && n.Key.Name == "deployer" {
+				p := n.Properties
+				if p["automountServiceAccountToken"] != "false" || p["secrets"] != "deployer-token" || p["imagePullSecrets"] != "registry,mirror" {
+					t.Errorf("trim=%v: ServiceAccount properties = %v", trim, p)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/shard"
+)
+
+// TestShardFor_Stable pins the namespace hash so shard assignments never move between releases.
+func TestShardFor_Stable(t *testing.T) {
+	cases := map[string]int{
+		"default":     0,
+		"kube-system": 2,
+		"team-a":      1,
+		"team-b":      2,
+	}
+	for ns, want := range cases {
+		if got := shard.For(ns, 3); got != want {
+			t.Errorf("For(%q, 3) = %d, want %d", ns, got, want)
+		}
+	}
+}
+
+// TestShardOwns verifies every namespace has exactly one owner and cluster-scoped objects go to shard 0.
+func TestShardOwns(t *testing.T) {
+	const count = 3
+	for _, ns := range []string{"", "default", "kube-system", "payments", "team-a", "team-b"} {
+		owners := 0
+		for i := 0; i < count; i++ {
+			if (shard.Shard{Index: i, Count: count}).Owns(ns) {
+				owners++
+				if ns == "" && i != 0 {
+					t.Errorf("Cluster-scoped objects owned by shard %d, want 0", i)
+				}
+			}
+		}
+		if owners != 1 {
+			t.Errorf("Namespace %q owned by %d shards, want 1", ns, owners)
+		}
+	}
+
+	if !(shard.Shard{Index: 0, Count: 1}).Owns("anything") {
+		t.Error("Unsharded instance must own every namespace")
+	}
+	if err := (shard.Shard{Index: 3, Count: 3}).Validate(); err == nil {
+		t.Error("Expected validation error for index out of range")
+	}
+}
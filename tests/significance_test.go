@@ -0,0 +1,99 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// drainChanged reports whether resourceCache signalled a change, consuming the signal.
+func drainChanged(resourceCache *cache.ResourceCache) bool {
+	select {
+	case <-resourceCache.Changed():
+		return true
+	default:
+		return false
+	}
+}
+
+// TestCache_NodeHeartbeatIsInsignificant verifies heartbeat-only Node updates are stored without triggering a rebuild, while condition changes do.
+func TestCache_NodeHeartbeatIsInsignificant(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	handler := resourceCache.AddEventHandler("Node")
+	node := func(rv string, heartbeat time.Time, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", ResourceVersion: rv},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready, LastHeartbeatTime: metav1.NewTime(heartbeat)},
+			}},
+		}
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1 := node("1", start, corev1.ConditionTrue)
+	handler.OnAdd(v1, false)
+	drainChanged(resourceCache)
+	seq := resourceCache.Seq()
+
+	v2 := node("2", start.Add(10*time.Second), corev1.ConditionTrue)
+	handler.OnUpdate(v1, v2)
+	if drainChanged(resourceCache) || resourceCache.Seq() != seq {
+		t.Errorf("Heartbeat-only update signalled a change")
+	}
+	stored, _ := resourceCache.Get(types.EntityKey{Kind: "Node", Name: "node-1"})
+	if stored.(*corev1.Node).ResourceVersion != "2" {
+		t.Errorf("Heartbeat-only update not stored, cached resourceVersion %s", stored.(*corev1.Node).ResourceVersion)
+	}
+
+	v3 := node("3", start.Add(20*time.Second), corev1.ConditionFalse)
+	handler.OnUpdate(v2, v3)
+	if !drainChanged(resourceCache) || resourceCache.Seq() == seq {
+		t.Errorf("Condition change did not signal a change")
+	}
+}
+
+// TestCache_ManagedFieldsOnlyUpdate verifies managedFields-only updates are insignificant unless the ignore list is cleared.
+func TestCache_ManagedFieldsOnlyUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ignored cache.IgnoredFields
+		want    bool
+	}{
+		{name: "default", ignored: cache.DefaultIgnoredFields, want: false},
+		{name: "nothing ignored", ignored: cache.IgnoredFields{}, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resourceCache := cache.NewResourceCache()
+			resourceCache.IgnoredFields = tc.ignored
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1"}}
+			resourceCache.Upsert(pod)
+			drainChanged(resourceCache)
+
+			updated := pod.DeepCopy()
+			updated.ResourceVersion = "2"
+			updated.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate}}
+			resourceCache.Upsert(updated)
+			if got := drainChanged(resourceCache); got != tc.want {
+				t.Errorf("Changed = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestParseIgnoredFields verifies the flag format round-trips and rejects entries without a kind.
+func TestParseIgnoredFields(t *testing.T) {
+	fields, err := cache.ParseIgnoredFields(cache.DefaultIgnoredFields.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields.String() != cache.DefaultIgnoredFields.String() {
+		t.Errorf("Round trip = %q, want %q", fields.String(), cache.DefaultIgnoredFields.String())
+	}
+	if _, err := cache.ParseIgnoredFields("status.conditions"); err == nil {
+		t.Errorf("Expected an error for an entry without a kind")
+	}
+}
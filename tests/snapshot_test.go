@@ -0,0 +1,90 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestBuildGraph_ConsistentUnderChurn hammers the cache with pod updates and
+// deletes during builds; every relationship must connect nodes of the same graph.
+func TestBuildGraph_ConsistentUnderChurn(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	for i := 0; i < 3; i++ {
+		resourceCache.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i), ResourceVersion: "1"}})
+	}
+	resourceCache.Upsert(defaultServiceAccount("default"))
+	for _, app := range []string{"a", "b"} {
+		resourceCache.Upsert(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc-" + app, Namespace: "default", ResourceVersion: "1"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": app}},
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for rv := 1; ctx.Err() == nil; rv++ {
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            fmt.Sprintf("pod-%d-%d", w, rv%5),
+						Namespace:       "default",
+						ResourceVersion: strconv.Itoa(rv),
+						Labels:          map[string]string{"app": []string{"a", "b"}[rv%2]},
+					},
+					Spec: corev1.PodSpec{NodeName: fmt.Sprintf("node-%d", rv%3)},
+				}
+				if rv%7 == 0 {
+					resourceCache.Delete(pod)
+				} else {
+					resourceCache.Upsert(pod)
+				}
+			}
+		}(w)
+	}
+
+	for rev := uint64(1); rev <= 200; rev++ {
+		g, err := graph.BuildGraph(context.Background(), resourceCache, rev)
+		if err != nil {
+			t.Fatalf("BuildGraph failed: %v", err)
+		}
+		if dangling := graph.DanglingRelationships(g); dangling != 0 {
+			t.Fatalf("Revision %d has %d relationships to nodes missing from the graph", rev, dangling)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+// TestSnapshot_Immutable verifies a snapshot is unaffected by later cache changes.
+func TestSnapshot_Immutable(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default", ResourceVersion: "1"}}
+	resourceCache.Upsert(pod)
+	snap := resourceCache.Snapshot()
+
+	resourceCache.Delete(pod)
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "q", Namespace: "default", ResourceVersion: "2"}})
+
+	if snap.Len() != 1 {
+		t.Fatalf("Snapshot has %d objects, want 1", snap.Len())
+	}
+	g, err := graph.BuildGraphFromSnapshot(context.Background(), snap, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].Key.Name != "p" {
+		t.Errorf("Graph of the snapshot has nodes %+v, want only p", g.Nodes)
+	}
+}
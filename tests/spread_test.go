@@ -0,0 +1,112 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// spreadCache returns a cache of three nodes, two in zone-a and one in zone-b, and the Deployment
+// name whose ReplicaSet runs a pod on each of nodes.
+func spreadCache(nodes ...string) *cache.ResourceCache {
+	c := cache.NewResourceCache()
+	for name, zone := range map[string]string{"node-1": "zone-a", "node-2": "zone-a", "node-3": "zone-b"} {
+		c.Upsert(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, UID: apitypes.UID(name), Labels: map[string]string{"topology.kubernetes.io/zone": zone}}})
+	}
+	c.Upsert(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "deploy-uid"}})
+	c.Upsert(&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-abc", Namespace: "shop", UID: "rs-uid",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}},
+	}})
+	for i, node := range nodes {
+		c.Upsert(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("web-abc-%d", i), Namespace: "shop", UID: apitypes.UID(fmt.Sprintf("pod-%d", i)),
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc"}},
+			},
+			Spec:   corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	return c
+}
+
+// TestWorkloadSpread verifies a Deployment's pods are summarized across nodes and zones through its
+// ReplicaSet, concentrated on one node and spread out.
+func TestWorkloadSpread(t *testing.T) {
+	cases := map[string]struct {
+		nodes []string
+		want  map[string]string
+	}{
+		"one node": {
+			nodes: []string{"node-1", "node-1", "node-1", "node-1"},
+			want:  map[string]string{"spread.pods": "4", "spread.nodes": "1", "spread.zones": "1", "spread.maxPodsPerNode": "4", "singleZone": "true"},
+		},
+		"one zone": {
+			nodes: []string{"node-1", "node-2", "node-2"},
+			want:  map[string]string{"spread.pods": "3", "spread.nodes": "2", "spread.zones": "1", "spread.maxPodsPerNode": "2", "singleZone": "true"},
+		},
+		"spread out": {
+			nodes: []string{"node-1", "node-2", "node-3"},
+			want:  map[string]string{"spread.pods": "3", "spread.nodes": "3", "spread.zones": "2", "spread.maxPodsPerNode": "1", "singleZone": "false"},
+		},
+		"unknown node": {
+			nodes: []string{"node-1", "node-9"},
+			want:  map[string]string{"spread.pods": "2", "spread.nodes": "2", "spread.zones": "1", "spread.maxPodsPerNode": "1", "singleZone": ""},
+		},
+	}
+	for name, c := range cases {
+		g, err := graph.BuildGraph(context.Background(), spreadCache(c.nodes...), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, node := range g.Nodes {
+			if node.Key.Kind == "ReplicaSet" {
+				if _, ok := node.Properties[graph.SpreadPodsProperty]; ok {
+					t.Errorf("%s: spread summarized on the ReplicaSet", name)
+				}
+			}
+			if node.Key.Kind != "Deployment" {
+				continue
+			}
+			for key, value := range c.want {
+				if node.Properties[key] != value {
+					t.Errorf("%s: %s = %q, want %q", name, key, node.Properties[key], value)
+				}
+			}
+		}
+	}
+}
+
+// TestWorkloadSpread_LivePodsOnly verifies finished, terminating and unscheduled pods are left out.
+func TestWorkloadSpread_LivePodsOnly(t *testing.T) {
+	c := spreadCache("node-1", "node-3")
+	now := metav1.Now()
+	extra := []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "done"}, Spec: corev1.PodSpec{NodeName: "node-2"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "leaving", DeletionTimestamp: &now}, Spec: corev1.PodSpec{NodeName: "node-2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pending"}},
+	}
+	for _, pod := range extra {
+		pod.Namespace, pod.UID = "shop", apitypes.UID(pod.Name)
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc"}}
+		c.Upsert(pod)
+	}
+	g, err := graph.BuildGraph(context.Background(), c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range g.Nodes {
+		if node.Key.Kind == "Deployment" && (node.Properties[graph.SpreadPodsProperty] != "2" || node.Properties[graph.SpreadNodesProperty] != "2") {
+			t.Errorf("Deployment spread = %v, want the 2 running pods", node.Properties)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/runner"
+)
+
+// runEmits simulates one process lifetime: it resumes from dir, builds n
+// graphs and flushes each to the file and state sinks. It returns the last
+// emitted revision.
+func runEmits(t *testing.T, dir string, n int) uint64 {
+	t.Helper()
+	last, err := emitter.RecoverRevision(dir)
+	if err != nil {
+		t.Fatalf("RecoverRevision failed: %v", err)
+	}
+	sinks := []emitter.EmitFunc{emitter.FileSink{Dir: dir, WriteLatest: true}.Emit, emitter.StateSink{Dir: dir}.Emit}
+	queue := emitter.NewQueue(func(ctx context.Context, g graph.Graph) error {
+		for _, sink := range sinks {
+			if err := sink(ctx, g); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, 0)
+	var builds atomic.Int32
+	loop := runner.New(countingBuild(&builds), queue, nil, nil)
+	loop.Resume(last)
+	for i := 0; i < n; i++ {
+		if err := loop.BuildAndSubmit(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := queue.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return loop.Revision()
+}
+
+// TestRevision_ResumesAcrossRestarts verifies revisions keep increasing over simulated restarts.
+func TestRevision_ResumesAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	if got := runEmits(t, dir, 3); got != 3 {
+		t.Fatalf("First run ended at revision %d, want 3", got)
+	}
+	if got := runEmits(t, dir, 2); got != 5 {
+		t.Fatalf("Second run ended at revision %d, want 5", got)
+	}
+
+	// without the state file the graph files still carry the revision
+	if err := os.Remove(filepath.Join(dir, emitter.StateFilename)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := emitter.RecoverRevision(dir); err != nil || got != 5 {
+		t.Errorf("RecoverRevision without state file = %d, %v; want 5", got, err)
+	}
+
+	if got, err := emitter.RecoverRevision(t.TempDir()); err != nil || got != 0 {
+		t.Errorf("RecoverRevision of an empty directory = %d, %v; want 0", got, err)
+	}
+}
+
+// TestRevision_CorruptStateFile verifies a corrupt state file falls back to the graph files, then to a timestamp.
+func TestRevision_CorruptStateFile(t *testing.T) {
+	dir := t.TempDir()
+	runEmits(t, dir, 4)
+	statePath := filepath.Join(dir, emitter.StateFilename)
+	if err := os.WriteFile(statePath, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := emitter.ReadState(dir); err == nil {
+		t.Fatal("ReadState accepted a corrupt state file")
+	}
+	if got, err := emitter.RecoverRevision(dir); err != nil || got != 4 {
+		t.Errorf("RecoverRevision with corrupt state = %d, %v; want 4 from the graph files", got, err)
+	}
+
+	// nothing else to go by: recovery fails and the fallback is still larger
+	bare := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bare, emitter.StateFilename), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := emitter.RecoverRevision(bare); err == nil {
+		t.Fatal("RecoverRevision succeeded with only a corrupt state file")
+	}
+	if fallback := emitter.FallbackRevision(time.Now()); fallback <= 4 {
+		t.Errorf("FallbackRevision = %d, not above previously emitted revisions", fallback)
+	}
+	if _, err := emitter.ReadState(t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadState of a fresh directory = %v, want ErrNotExist", err)
+	}
+}
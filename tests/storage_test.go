@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func claim(name, volumeName string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name)},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: volumeName,
+			Resources:  corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")}},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func volume(name string, claimRef *corev1.ObjectReference) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: apitypes.UID("uid-" + name)},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			ClaimRef:                      claimRef,
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+}
+
+func claimPod(name string, claims ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID("uid-" + name)}}
+	for _, c := range claims {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: c, VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: c},
+		}})
+	}
+	return pod
+}
+
+// TestStorage_Bindings verifies pods sharing a claim both USE it, claims are BOUND_TO their volume through
+// volumeName or the volume's claimRef, and unbound claims are kept as Pending nodes.
+func TestStorage_Bindings(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	for _, obj := range []runtime.Object{
+		claimPod("web-a", "shared"),
+		claimPod("web-b", "shared", "scratch"),
+		claim("shared", "pv-shared", corev1.ClaimBound),
+		claim("binding", "", corev1.ClaimPending),
+		claim("scratch", "", corev1.ClaimPending),
+		volume("pv-shared", &corev1.ObjectReference{Namespace: "shop", Name: "shared", UID: "uid-shared"}),
+		volume("pv-binding", &corev1.ObjectReference{Namespace: "shop", Name: "binding", UID: "uid-binding"}),
+		// released by an earlier claim named scratch
+		volume("pv-stale", &corev1.ObjectReference{Namespace: "shop", Name: "scratch", UID: "uid-old"}),
+	} {
+		resourceCache.Upsert(obj)
+	}
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1, graph.WithWatchedKinds([]string{"Pod", "PersistentVolumeClaim", "PersistentVolume"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "USES" || r.RelationshipType == "BOUND_TO" {
+			got = append(got, r.Source.Name+" "+r.RelationshipType+" "+r.Target.Name)
+			if r.Properties[graph.MissingTargetProperty] != "" {
+				t.Errorf("%s reported missing", r.Target.Name)
+			}
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"binding BOUND_TO pv-binding",
+		"shared BOUND_TO pv-shared",
+		"web-a USES shared",
+		"web-b USES scratch",
+		"web-b USES shared",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Relationships = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Relationship %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	props := make(map[string]map[string]string)
+	for _, n := range g.Nodes {
+		props[n.Key.Name] = n.Properties
+	}
+	if p := props["scratch"]; p == nil || p["status.phase"] != "Pending" || p["spec.resources.requests.storage"] != "5Gi" {
+		t.Errorf("Unbound claim = %v", p)
+	}
+	if p := props["pv-shared"]; p["spec.capacity.storage"] != "5Gi" || p["spec.persistentVolumeReclaimPolicy"] != "Retain" || p["spec.claimRef"] != "shop/shared" {
+		t.Errorf("Volume = %v", p)
+	}
+}
@@ -0,0 +1,60 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// fixtureGraph is a small two-namespace graph sharing one cluster-scoped Node.
+func fixtureGraph() graph.Graph {
+	node := graph.GraphEntityKey{Kind: "Node", Name: "node-1"}
+	podA := graph.GraphEntityKey{Kind: "Pod", Namespace: "team-a", Name: "pod-a"}
+	svcA := graph.GraphEntityKey{Kind: "Service", Namespace: "team-a", Name: "svc-a"}
+	podB := graph.GraphEntityKey{Kind: "Pod", Namespace: "team-b", Name: "pod-b"}
+	return graph.Graph{
+		GraphRevision: 3,
+		Nodes: []graph.GraphNode{
+			{Key: node, Properties: map[string]string{"uid": "n"}},
+			{Key: podA, Properties: map[string]string{"labels": "app=a"}},
+			{Key: svcA, Properties: map[string]string{}},
+			{Key: podB, Properties: map[string]string{"labels": "app=b"}},
+		},
+		Relationships: []graph.GraphRelationship{
+			{Source: podA, Target: node, RelationshipType: "SCHEDULED_ON"},
+			{Source: podB, Target: node, RelationshipType: "SCHEDULED_ON"},
+			{Source: svcA, Target: podA, RelationshipType: "SELECTS"},
+		},
+	}
+}
+
+func TestSubgraph_NamespaceWithExternalNodes(t *testing.T) {
+	g := fixtureGraph()
+	sub := graph.Subgraph(g, graph.Filter{Namespaces: []string{"team-a"}, IncludeExternal: true})
+
+	if len(sub.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes (2 in team-a + external Node), got %+v", sub.Nodes)
+	}
+	if len(sub.Relationships) != 2 {
+		t.Fatalf("Expected 2 relationships, got %+v", sub.Relationships)
+	}
+	for _, n := range sub.Nodes {
+		isExternal := n.Properties[graph.ExternalProperty] == "true"
+		if isExternal != (n.Key.Kind == "Node") {
+			t.Errorf("Node %+v external=%v", n.Key, isExternal)
+		}
+	}
+	// the source graph must not be modified
+	for _, n := range g.Nodes {
+		if _, ok := n.Properties[graph.ExternalProperty]; ok {
+			t.Errorf("Subgraph mutated source node %+v", n.Key)
+		}
+	}
+}
+
+func TestSubgraph_WithoutExternal(t *testing.T) {
+	sub := graph.Subgraph(fixtureGraph(), graph.Filter{Namespaces: []string{"team-b"}})
+	if len(sub.Nodes) != 1 || len(sub.Relationships) != 0 {
+		t.Errorf("Expected only pod-b and no relationships, got %+v / %+v", sub.Nodes, sub.Relationships)
+	}
+}
@@ -0,0 +1,114 @@
+package main_test
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// forbiddingClient returns a fake client whose configmaps list is forbidden
+// while the returned flag is set.
+func forbiddingClient() (*fake.Clientset, *atomic.Bool) {
+	client := fake.NewSimpleClientset()
+	var forbidden atomic.Bool
+	forbidden.Store(true)
+	client.PrependReactor("list", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if !forbidden.Load() {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+	})
+	return client, &forbidden
+}
+
+// supervise adds kind, with informers from client, to s.
+func supervise(t *testing.T, s *k8s.KindSupervisor, client *fake.Clientset, kind string, disabled bool, created *atomic.Int32) {
+	t.Helper()
+	err := s.Add(kind, func() (cache.SharedIndexInformer, error) {
+		created.Add(1)
+		inf, _ := k8s.NewInformer(informers.NewSharedInformerFactory(client, 0), kind)
+		return inf, nil
+	}, disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestKindSupervisor_ForbiddenKind verifies a kind whose initial list is forbidden is disabled
+// without holding up the others, retried, and re-enabled once allowed.
+func TestKindSupervisor_ForbiddenKind(t *testing.T) {
+	client, forbidden := forbiddingClient()
+	var changes atomic.Int32
+	s := &k8s.KindSupervisor{
+		RetryInterval: 50 * time.Millisecond,
+		Watch:         &k8s.WatchHealth{},
+		OnChange:      func() { changes.Add(1) },
+	}
+	var pods, configMaps atomic.Int32
+	supervise(t, s, client, "Pod", false, &pods)
+	supervise(t, s, client, "ConfigMap", false, &configMaps)
+
+	s.Start(context.Background())
+	defer s.Shutdown()
+
+	// enabled -> disabled
+	waitFor(t, func() bool { return slices.Equal(s.Disabled(), []string{"ConfigMap"}) })
+	if !s.HasSynced("Pod") || !s.HasSynced("ConfigMap") {
+		t.Errorf("Disabled kind holds up the initial sync")
+	}
+	if changes.Load() != 1 {
+		t.Errorf("Expected 1 change for the disabled kind, got %d", changes.Load())
+	}
+	if stale := s.Watch.StaleKinds(); len(stale) != 0 {
+		t.Errorf("Forbidden initial list counted as a watch failure: %v", stale)
+	}
+
+	// retries while still forbidden create new informers but keep the kind disabled
+	waitFor(t, func() bool { return configMaps.Load() >= 3 })
+	if !slices.Equal(s.Disabled(), []string{"ConfigMap"}) || changes.Load() != 1 {
+		t.Errorf("Failed retry changed the state: disabled %v, %d changes", s.Disabled(), changes.Load())
+	}
+
+	// disabled -> re-enabled
+	forbidden.Store(false)
+	waitFor(t, func() bool { return len(s.Disabled()) == 0 })
+	if !s.HasSynced("ConfigMap") {
+		t.Errorf("Re-enabled kind not synced")
+	}
+	if changes.Load() != 2 {
+		t.Errorf("Expected 2 changes after re-enabling, got %d", changes.Load())
+	}
+	if pods.Load() != 1 {
+		t.Errorf("Allowed kind's informer recreated %d times", pods.Load())
+	}
+}
+
+// TestKindSupervisor_StartsDisabled verifies a kind added disabled is only started by a retry.
+func TestKindSupervisor_StartsDisabled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	s := &k8s.KindSupervisor{RetryInterval: 50 * time.Millisecond}
+	var created atomic.Int32
+	supervise(t, s, client, "Node", true, &created)
+	if created.Load() != 0 || !slices.Equal(s.Disabled(), []string{"Node"}) {
+		t.Fatalf("Disabled kind created an informer before Start")
+	}
+
+	s.Start(context.Background())
+	defer s.Shutdown()
+	waitFor(t, func() bool { return len(s.Disabled()) == 0 })
+	if created.Load() != 1 {
+		t.Errorf("Expected one informer for the re-enabled kind, got %d", created.Load())
+	}
+}
@@ -0,0 +1,83 @@
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+)
+
+// TestCleanTempFiles_RemovesStale verifies only temporary files older than the cutoff are removed, in subdirectories too.
+func TestCleanTempFiles_RemovesStale(t *testing.T) {
+	dir := t.TempDir()
+	nsDir := filepath.Join(dir, "default")
+	if err := os.MkdirAll(nsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	write := func(path string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	staleTop := filepath.Join(dir, "graph-123.json.tmp")
+	staleNs := filepath.Join(nsDir, "graph-456.json.tmp")
+	fresh := filepath.Join(dir, "graph-789.json.tmp")
+	graphFile := filepath.Join(dir, "graph-20240101T000000.000000000Z-1.json")
+	write(staleTop, old)
+	write(staleNs, old)
+	write(fresh, time.Now())
+	write(graphFile, old)
+
+	removed, err := emitter.CleanTempFiles(dir, emitter.DefaultTempMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 removed files, got %d", removed)
+	}
+	for _, path := range []string{staleTop, staleNs} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", path)
+		}
+	}
+	for _, path := range []string{fresh, graphFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should have been kept: %v", path, err)
+		}
+	}
+
+	if removed, err := emitter.CleanTempFiles(filepath.Join(dir, "missing"), emitter.DefaultTempMaxAge); err != nil || removed != 0 {
+		t.Errorf("Missing directory: removed %d, err %v", removed, err)
+	}
+}
+
+// TestFileSink_LeavesNoTempFiles verifies every emit format renames its temporary files into place.
+func TestFileSink_LeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	g := graph.Graph{GraphRevision: 7}
+	if err := (emitter.FileSink{Dir: dir, WriteLatest: true}).Emit(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	if err := (emitter.StateSink{Dir: dir}).Emit(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("Unexpected temporary files after emit: %v", leftovers)
+	}
+	if rev, err := emitter.RecoverRevision(dir); err != nil || rev != 7 {
+		t.Errorf("RecoverRevision = %d, %v; want 7", rev, err)
+	}
+}
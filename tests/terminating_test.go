@@ -0,0 +1,160 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// terminatingPod returns a pod named web selected by the app=web service.
+func terminatingPod(uid, resourceVersion string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "web", Namespace: "default", UID: apitypes.UID(uid), ResourceVersion: resourceVersion,
+		Labels: map[string]string{"app": "web"},
+	}}
+}
+
+// lingerCache returns a cache keeping deleted objects for linger, holding
+// the app=web service.
+func lingerCache(linger time.Duration) *cache.ResourceCache {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Linger = linger
+	resourceCache.Upsert(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "svc-uid", ResourceVersion: "1"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	})
+	return resourceCache
+}
+
+// webPod returns the web pod's node and whether the service still selects it.
+func webPod(t *testing.T, resourceCache *cache.ResourceCache) (*graph.GraphNode, bool) {
+	t.Helper()
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podKey := graph.GraphEntityKey{Kind: "Pod", Namespace: "default", Name: "web"}
+	var node *graph.GraphNode
+	for i := range g.Nodes {
+		if g.Nodes[i].Key == podKey {
+			node = &g.Nodes[i]
+		}
+	}
+	for _, rel := range g.Relationships {
+		if rel.RelationshipType == "SELECTS" && rel.Target == podKey {
+			return node, true
+		}
+	}
+	return node, false
+}
+
+// TestLinger_KeepsDeletedPod verifies a deleted pod stays selected, marked deleted, until the linger expires.
+func TestLinger_KeepsDeletedPod(t *testing.T) {
+	resourceCache := lingerCache(100 * time.Millisecond)
+	handler := resourceCache.AddEventHandler("Pod")
+	pod := terminatingPod("old-uid", "2")
+	handler.OnAdd(pod, false)
+	handler.OnDelete(pod)
+
+	node, selected := webPod(t, resourceCache)
+	if node == nil || !selected {
+		t.Fatalf("Deleted pod dropped before the linger expired (node %v, selected %v)", node, selected)
+	}
+	if node.Properties[graph.DeletedProperty] != "true" || node.Properties[graph.StateProperty] != graph.StateTerminating {
+		t.Errorf("Lingering pod not marked deleted: %v", node.Properties)
+	}
+	if node.Properties[graph.DeletionTimestampProperty] == "" {
+		t.Errorf("Lingering pod has no deletionTimestamp")
+	}
+	if _, found := resourceCache.GetByUID("old-uid"); !found {
+		t.Errorf("Lingering pod not found by UID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := resourceCache.WaitForAbsent(ctx, types.EntityKey{Kind: "Pod", Namespace: "default", Name: "web"}); err != nil {
+		t.Fatalf("Pod still cached after the linger expired: %v", err)
+	}
+	if node, selected := webPod(t, resourceCache); node != nil || selected {
+		t.Errorf("Pod still in the graph after the linger expired")
+	}
+	if _, found := resourceCache.GetByUID("old-uid"); found {
+		t.Errorf("Expired pod still indexed by UID")
+	}
+}
+
+// TestLinger_ReplacementPod verifies a pod recreated under the same name ends the linger and survives its expiry.
+func TestLinger_ReplacementPod(t *testing.T) {
+	resourceCache := lingerCache(50 * time.Millisecond)
+	handler := resourceCache.AddEventHandler("Pod")
+	old := terminatingPod("old-uid", "2")
+	handler.OnAdd(old, false)
+	handler.OnDelete(old)
+	handler.OnAdd(terminatingPod("new-uid", "3"), false)
+
+	node, selected := webPod(t, resourceCache)
+	if node == nil || !selected {
+		t.Fatalf("Replacement pod missing from the graph")
+	}
+	if _, ok := node.Properties[graph.DeletedProperty]; ok || node.Properties["uid"] != "new-uid" {
+		t.Errorf("Replacement pod inherited the deleted mark: %v", node.Properties)
+	}
+	if _, found := resourceCache.GetByUID("old-uid"); found {
+		t.Errorf("Replaced pod still indexed by UID")
+	}
+
+	// the old pod's linger expiring must not remove its replacement
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := resourceCache.Quiesce(ctx, 150*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := resourceCache.Get(types.EntityKey{Kind: "Pod", Namespace: "default", Name: "web"}); !found {
+		t.Fatalf("Replacement pod removed when the old pod's linger expired")
+	}
+	if _, found := resourceCache.GetByUID("new-uid"); !found {
+		t.Errorf("Replacement pod not indexed by UID")
+	}
+
+	// deleting the replacement starts a linger of its own
+	handler.OnDelete(terminatingPod("new-uid", "3"))
+	if node, _ := webPod(t, resourceCache); node == nil || node.Properties[graph.DeletedProperty] != "true" {
+		t.Errorf("Deleted replacement pod not lingering")
+	}
+}
+
+// TestLinger_Disabled verifies a zero linger removes deleted objects at once, and live objects with a deletionTimestamp are TERMINATING.
+func TestLinger_Disabled(t *testing.T) {
+	resourceCache := lingerCache(0)
+	handler := resourceCache.AddEventHandler("Pod")
+	pod := terminatingPod("pod-uid", "2")
+	handler.OnAdd(pod, false)
+
+	terminating := pod.DeepCopy()
+	terminating.ResourceVersion = "3"
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler.OnUpdate(pod, terminating)
+	node, selected := webPod(t, resourceCache)
+	if node == nil || !selected {
+		t.Fatalf("Terminating pod missing from the graph")
+	}
+	if node.Properties[graph.StateProperty] != graph.StateTerminating || node.Properties[graph.DeletionTimestampProperty] != "2024-01-01T00:00:00Z" {
+		t.Errorf("Unexpected terminating properties: %v", node.Properties)
+	}
+	if _, ok := node.Properties[graph.DeletedProperty]; ok {
+		t.Errorf("Live pod marked deleted")
+	}
+
+	handler.OnDelete(terminating)
+	if node, _ := webPod(t, resourceCache); node != nil {
+		t.Errorf("Pod still in the graph with linger disabled")
+	}
+}
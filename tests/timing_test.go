@@ -0,0 +1,105 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+	"github.com/tthuwng/satellite/internal/timing"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestTiming_RollingPercentiles verifies percentiles only cover the newest window samples.
+func TestTiming_RollingPercentiles(t *testing.T) {
+	r := timing.NewRecorder(10, 0)
+	// 100 slow samples that fall out of the window, then 1..10ms
+	for i := 0; i < 100; i++ {
+		r.Observe("phase", time.Second)
+	}
+	for i := 1; i <= 10; i++ {
+		r.Observe("phase", time.Duration(i)*time.Millisecond)
+	}
+
+	sum := r.Summaries()["phase"]
+	want := timing.Summary{Count: 10, P50: 5 * time.Millisecond, P95: 10 * time.Millisecond, Max: 10 * time.Millisecond}
+	if sum != want {
+		t.Errorf("Summary = %+v, want %+v", sum, want)
+	}
+
+	r.Configure(4, 0)
+	if sum := r.Summaries()["phase"]; sum.Count != 4 || sum.P50 != 8*time.Millisecond {
+		t.Errorf("After shrinking the window: %+v, want the newest 4 samples", sum)
+	}
+}
+
+// TestTiming_SlowBuildWarning verifies slow builds are logged with their phase breakdown.
+func TestTiming_SlowBuildWarning(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+
+	r := timing.NewRecorder(10, 50*time.Millisecond)
+	r.Logger = logger
+
+	var fast timing.Breakdown
+	fast.Add(timing.BuildNodes, 10*time.Millisecond)
+	r.RecordBuild(1, 10*time.Millisecond, &fast)
+	if out.Len() != 0 {
+		t.Fatalf("Fast build logged: %s", out.String())
+	}
+
+	var slow timing.Breakdown
+	slow.Add(timing.BuildSnapshot, 5*time.Millisecond)
+	slow.Add(timing.BuildRelationships, 80*time.Millisecond)
+	r.RecordBuild(2, 90*time.Millisecond, &slow)
+	for _, want := range []string{"Slow graph build", "build.relationships=80ms", "build.snapshot=5ms", "revision=2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Warning %q does not contain %q", out.String(), want)
+		}
+	}
+	if got := r.Summaries()[timing.Build].Count; got != 2 {
+		t.Errorf("Recorded %d builds, want 2", got)
+	}
+}
+
+// TestTiming_BuildGraphPhasesExported verifies BuildGraph's phases reach the metrics registry.
+func TestTiming_BuildGraphPhasesExported(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}})
+	if _, err := graph.BuildGraph(context.Background(), resourceCache, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, mf := range families {
+		if mf.GetName() != "satellite_phase_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			seen[labels["phase"]+"/"+labels["quantile"]] = true
+		}
+	}
+	for _, phase := range []string{timing.Build, timing.BuildSnapshot, timing.BuildNodes, timing.BuildRelationships, timing.BuildValidate} {
+		for _, q := range []string{"0.5", "0.95", "1"} {
+			if !seen[phase+"/"+q] {
+				t.Errorf("Missing satellite_phase_duration_seconds{phase=%q,quantile=%q}", phase, q)
+			}
+		}
+	}
+}
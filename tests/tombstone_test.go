@@ -0,0 +1,81 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/types"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcache "k8s.io/client-go/tools/cache"
+)
+
+// TestCache_TombstoneDeletes verifies tombstones remove the cached object whatever their inner object holds.
+func TestCache_TombstoneDeletes(t *testing.T) {
+	cases := []struct {
+		name  string
+		inner interface{}
+	}{
+		{name: "nil object", inner: nil},
+		{name: "typed nil object", inner: (*corev1.Pod)(nil)},
+		{name: "wrong type", inner: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}},
+		{name: "not a runtime.Object", inner: "web"},
+		{name: "stale object", inner: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", UID: "old-uid", ResourceVersion: "1"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resourceCache := cache.NewResourceCache()
+			handler := resourceCache.AddEventHandler("Pod")
+			handler.OnAdd(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-uid", ResourceVersion: "7"}}, false)
+
+			handler.OnDelete(clientcache.DeletedFinalStateUnknown{Key: "default/web", Obj: tc.inner})
+
+			if _, found := resourceCache.Get(types.EntityKey{Kind: "Pod", Namespace: "default", Name: "web"}); found {
+				t.Errorf("Pod still cached after tombstone")
+			}
+			if _, found := resourceCache.GetByUID("pod-uid"); found {
+				t.Errorf("Pod still indexed by UID after tombstone")
+			}
+		})
+	}
+}
+
+// TestCache_TombstoneClusterScoped verifies tombstone keys without a namespace resolve to cluster-scoped objects.
+func TestCache_TombstoneClusterScoped(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	handler := resourceCache.AddEventHandler("Node")
+	handler.OnAdd(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-uid"}}, false)
+
+	handler.OnDelete(clientcache.DeletedFinalStateUnknown{Key: "node-1"})
+
+	if len(resourceCache.List()) != 0 {
+		t.Errorf("Node still cached after tombstone")
+	}
+}
+
+// TestCache_TombstoneDaemonSet verifies a DaemonSet deleted through a tombstone is removed without its type
+// being reported as unknown.
+func TestCache_TombstoneDaemonSet(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	resourceCache := cache.NewResourceCache()
+	handler := resourceCache.AddEventHandler("DaemonSet")
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "fluent-bit", Namespace: "logging", UID: "ds-uid"}}
+	handler.OnAdd(ds, false)
+	handler.OnDelete(clientcache.DeletedFinalStateUnknown{Key: "logging/fluent-bit", Obj: ds})
+
+	if len(resourceCache.List()) != 0 {
+		t.Errorf("DaemonSet still cached after tombstone")
+	}
+	if strings.Contains(logs.String(), "Unknown") {
+		t.Errorf("Tombstone logged an unknown type: %s", logs.String())
+	}
+}
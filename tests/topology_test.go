@@ -0,0 +1,80 @@
+package main_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// topologyNode returns a Node with the given labels.
+func topologyNode(name string, labels map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, UID: apitypes.UID(name + "-uid"), Labels: labels}}
+}
+
+// TestTopology_ZonesAndRegions verifies Zone and Region nodes with their node counts, IN_ZONE and IN_REGION
+// relationships, the beta labels as fallback, and that nodes without a zone label get no topology.
+func TestTopology_ZonesAndRegions(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(topologyNode("a", map[string]string{"topology.kubernetes.io/zone": "eu-west-1a", "topology.kubernetes.io/region": "eu-west-1", "kubernetes.io/hostname": "ip-10-0-0-1"}))
+	resourceCache.Upsert(topologyNode("b", map[string]string{"topology.kubernetes.io/zone": "eu-west-1a", "topology.kubernetes.io/region": "eu-west-1"}))
+	resourceCache.Upsert(topologyNode("c", map[string]string{"failure-domain.beta.kubernetes.io/zone": "eu-west-1b", "failure-domain.beta.kubernetes.io/region": "eu-west-1"}))
+	resourceCache.Upsert(topologyNode("d", map[string]string{"topology.kubernetes.io/zone": "lab"}))
+	resourceCache.Upsert(topologyNode("e", map[string]string{"topology.kubernetes.io/region": "eu-west-1"}))
+	resourceCache.Upsert(topologyNode("f", nil))
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make(map[string]string)
+	for _, n := range g.Nodes {
+		switch n.Key.Kind {
+		case "Zone", "Region":
+			nodes[n.Key.Kind+"/"+n.Key.Name] = n.Properties["nodes"]
+		case "Node":
+			if n.Key.Name == "a" && n.Properties["hostname"] != "ip-10-0-0-1" {
+				t.Errorf("Node a hostname = %q, want ip-10-0-0-1", n.Properties["hostname"])
+			}
+		}
+	}
+	wantNodes := map[string]string{"Zone/eu-west-1a": "2", "Zone/eu-west-1b": "1", "Zone/lab": "1", "Region/eu-west-1": "3"}
+	if len(nodes) != len(wantNodes) {
+		t.Errorf("Topology nodes = %v, want %v", nodes, wantNodes)
+	}
+	for key, want := range wantNodes {
+		if nodes[key] != want {
+			t.Errorf("%s nodes = %q, want %q", key, nodes[key], want)
+		}
+	}
+
+	var rels []string
+	for _, r := range g.Relationships {
+		if r.RelationshipType == "IN_ZONE" || r.RelationshipType == "IN_REGION" {
+			rels = append(rels, r.Source.Kind+"/"+r.Source.Name+" "+r.RelationshipType+" "+r.Target.Kind+"/"+r.Target.Name)
+		}
+	}
+	sort.Strings(rels)
+	want := []string{
+		"Node/a IN_ZONE Zone/eu-west-1a",
+		"Node/b IN_ZONE Zone/eu-west-1a",
+		"Node/c IN_ZONE Zone/eu-west-1b",
+		"Node/d IN_ZONE Zone/lab",
+		"Zone/eu-west-1a IN_REGION Region/eu-west-1",
+		"Zone/eu-west-1b IN_REGION Region/eu-west-1",
+	}
+	if len(rels) != len(want) {
+		t.Fatalf("Topology relationships = %v, want %v", rels, want)
+	}
+	for i := range want {
+		if rels[i] != want[i] {
+			t.Errorf("Relationship %d = %q, want %q", i, rels[i], want[i])
+		}
+	}
+}
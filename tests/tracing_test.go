@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/emitter"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/runner"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestTracing_BuildAndEmitSpans checks the span tree of one build cycle and
+// one emit. The global provider can only be installed once per process, so
+// this is the only test doing it.
+func TestTracing_BuildAndEmitSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	c := cache.NewResourceCache()
+	c.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "traced", ResourceVersion: "1"}})
+
+	var built graph.Graph
+	queue := emitter.NewQueue(emitter.Instrument("file", emitter.FileSink{Dir: t.TempDir()}.Emit), 0)
+	loop := runner.New(func(ctx context.Context, revision uint64) (graph.Graph, error) {
+		g, err := graph.BuildGraph(ctx, c, revision)
+		built = g
+		return g, err
+	}, queue, nil, nil)
+	if err := loop.BuildAndSubmit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(built.Nodes) != 1 {
+		t.Fatalf("built %d nodes, want 1", len(built.Nodes))
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	parents := map[string]string{
+		"graph.BuildGraph":      "runner.BuildCycle",
+		"graph.snapshot":        "graph.BuildGraph",
+		"graph.nodes":           "graph.BuildGraph",
+		"graph.relationships":   "graph.BuildGraph",
+		"graph.validate":        "graph.BuildGraph",
+		"emitter.FileSink.Emit": "emitter.Emit",
+	}
+	for name, parent := range parents {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("missing span %s", name)
+			continue
+		}
+		if p, ok := spans[parent]; !ok || s.Parent().SpanID() != p.SpanContext().SpanID() {
+			t.Errorf("span %s is not a child of %s", name, parent)
+		}
+	}
+
+	attrs := make(map[string]int64)
+	for _, kv := range spans["emitter.Emit"].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInt64()
+	}
+	if attrs["satellite.revision"] != 1 || attrs["satellite.nodes"] != 1 {
+		t.Errorf("emitter.Emit attributes = %v", spans["emitter.Emit"].Attributes())
+	}
+}
@@ -0,0 +1,253 @@
+package main_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// trimFixture returns objects of every watched kind with the fields the graph
+// reads set, alongside plenty it doesn't.
+func trimFixture() []runtime.Object {
+	replicas := int32(3)
+	priority := int32(1000)
+	runtimeClass := "gvisor"
+	started := metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	meta := func(name, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name: name, Namespace: namespace, UID: apitypes.UID("uid-" + name), ResourceVersion: "7",
+			CreationTimestamp: started,
+			Labels:            map[string]string{"app": "web"},
+			Annotations:       map[string]string{"team": "shop"},
+			Finalizers:        []string{"example.com/cleanup"},
+			ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		}
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels:      map[string]string{"app": "web"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpExists}},
+	}
+
+	deploy := &appsv1.Deployment{ObjectMeta: meta("web", "shop"), Spec: appsv1.DeploymentSpec{Replicas: &replicas, Selector: selector}}
+	deploy.Status = appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 1, ObservedGeneration: 4}
+	rs := &appsv1.ReplicaSet{ObjectMeta: meta("web-1", "shop"), Spec: appsv1.ReplicaSetSpec{Replicas: &replicas, Selector: selector}}
+	rs.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}}
+	rs.Status = appsv1.ReplicaSetStatus{Replicas: 3, ReadyReplicas: 2, AvailableReplicas: 1, FullyLabeledReplicas: 3}
+	sts := &appsv1.StatefulSet{ObjectMeta: meta("db", "shop"), Spec: appsv1.StatefulSetSpec{
+		Replicas: &replicas, Selector: selector, ServiceName: "db", PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+		UpdateStrategy:       appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+		VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+	}}
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 2, CurrentReplicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2, CurrentRevision: "db-1"}
+	ds := &appsv1.DaemonSet{ObjectMeta: meta("agent", "shop"), Spec: appsv1.DaemonSetSpec{
+		Selector: selector, UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType}, MinReadySeconds: 10,
+	}}
+	ds.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, CurrentNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberReady: 2, NumberAvailable: 2, NumberMisscheduled: 1, ObservedGeneration: 5}
+	pod := &corev1.Pod{ObjectMeta: meta("web-1-a", "shop")}
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-1"}}
+	pod.Spec = corev1.PodSpec{
+		NodeName: "node-1",
+		Containers: []corev1.Container{{Name: "web", Image: "web:1", Env: []corev1.EnvVar{
+			{Name: "MODE", Value: "prod"},
+			{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "password"}}},
+		}}},
+		Volumes: []corev1.Volume{
+			{Name: "settings", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}}},
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "web-tls"}}},
+		},
+		ImagePullSecrets:  []corev1.LocalObjectReference{{Name: "pull"}},
+		PriorityClassName: "high",
+		RuntimeClassName:  &runtimeClass,
+		Priority:          &priority,
+	}
+	pod.Status = corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.5", HostIP: "192.168.0.1", StartTime: &started,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "web", Image: "web:1", ImageID: "sha256:abc", RestartCount: 2, Ready: true,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: started}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "OOMKilled", ExitCode: 137, FinishedAt: started, Message: strings.Repeat("x", 1024),
+			}},
+		}}}
+	node := &corev1.Node{ObjectMeta: meta("node-1", ""), Spec: corev1.NodeSpec{PodCIDR: "10.0.0.0/24", ProviderID: "aws:///i-1"}}
+	node.Status = corev1.NodeStatus{
+		Capacity:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi")},
+		Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3800m"), corev1.ResourceMemory: resource.MustParse("15Gi")},
+		NodeInfo:    corev1.NodeSystemInfo{KubeletVersion: "v1.33.0", OSImage: "Linux", ContainerRuntimeVersion: "containerd://2.0", MachineID: "abc"},
+		Images:      []corev1.ContainerImage{{Names: []string{"web:1"}, SizeBytes: 1 << 20}},
+	}
+	svc := &corev1.Service{ObjectMeta: meta("web", "shop"), Spec: corev1.ServiceSpec{
+		Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.96.0.10", ClusterIPs: []string{"10.96.0.10"},
+		Selector: map[string]string{"app": "web"}, Ports: []corev1.ServicePort{{Port: 80}},
+	}}
+	cm := &corev1.ConfigMap{ObjectMeta: meta("settings", "shop"),
+		Data:       map[string]string{"app.yaml": strings.Repeat("x", 4096)},
+		BinaryData: map[string][]byte{"blob": make([]byte, 4096)},
+	}
+	ns := &corev1.Namespace{ObjectMeta: meta("shop", ""), Spec: corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{"kubernetes"}}}
+	ns.Status.Phase = corev1.NamespaceActive
+	utilization := int32(80)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: meta("web", "shop"), Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		MinReplicas:    &replicas, MaxReplicas: 10,
+		Metrics: []autoscalingv2.MetricSpec{{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+			Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization},
+		}}},
+		Behavior: &autoscalingv2.HorizontalPodAutoscalerBehavior{},
+	}}
+	hpa.Status = autoscalingv2.HorizontalPodAutoscalerStatus{
+		LastScaleTime: &started, CurrentReplicas: 3, DesiredReplicas: 4, ObservedGeneration: &deploy.Status.ObservedGeneration,
+		CurrentMetrics: []autoscalingv2.MetricStatus{{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricStatus{
+			Name: corev1.ResourceCPU, Current: autoscalingv2.MetricValueStatus{AverageUtilization: &utilization},
+		}}},
+		Conditions: []autoscalingv2.HorizontalPodAutoscalerCondition{{
+			Type: autoscalingv2.ScalingLimited, Status: corev1.ConditionFalse, Reason: "DesiredWithinRange", Message: strings.Repeat("x", 1024),
+		}},
+	}
+	backoff := int32(6)
+	job := &batchv1.Job{ObjectMeta: meta("report-1", "shop"), Spec: batchv1.JobSpec{BackoffLimit: &backoff, Completions: &replicas}}
+	job.Status = batchv1.JobStatus{StartTime: &started, CompletionTime: &started, Succeeded: 3, Failed: 1, Ready: &replicas,
+		Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, Reason: "CompletionsReached", LastTransitionTime: started, Message: "done"}},
+	}
+	suspend, zone := false, "Europe/Paris"
+	cronJob := &batchv1.CronJob{ObjectMeta: meta("report", "shop"), Spec: batchv1.CronJobSpec{
+		Schedule: "0 2 * * *", TimeZone: &zone, Suspend: &suspend, ConcurrencyPolicy: batchv1.ForbidConcurrent,
+	}}
+	cronJob.Status = batchv1.CronJobStatus{Active: []corev1.ObjectReference{{Kind: "Job", Name: "report-1"}}, LastScheduleTime: &started, LastSuccessfulTime: &started}
+	event := &corev1.Event{ObjectMeta: meta("web-1-a.17f", "shop"), Type: corev1.EventTypeWarning, Reason: "BackOff", Message: "Back-off restarting failed container",
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: "web-1-a", UID: pod.UID, FieldPath: "spec.containers{web}"},
+		Source:         corev1.EventSource{Component: "kubelet", Host: "node-1"}, Count: 4, FirstTimestamp: started, LastTimestamp: metav1.Now(),
+	}
+	class := "fast"
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: meta("data", "shop"), Spec: corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, StorageClassName: &class, VolumeName: "pv-1",
+		Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}},
+	}}
+	pvc.Status = corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound, Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}}
+	pv := &corev1.PersistentVolume{ObjectMeta: meta("pv-1", ""), Spec: corev1.PersistentVolumeSpec{
+		Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+		AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		ClaimRef:                      &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "shop", Name: "data", UID: pvc.UID},
+		PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete, StorageClassName: class,
+		PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{Driver: "ebs.csi.aws.com", VolumeHandle: "vol-1"}},
+	}}
+	pv.Status.Phase = corev1.VolumeBound
+	ingress := shopIngress()
+	ingress.ObjectMeta = meta("shop", "shop")
+	prefix := networkingv1.PathTypePrefix
+	ingress.Spec.Rules[0].HTTP.Paths[0].PathType = &prefix
+	ready := true
+	slice := &discoveryv1.EndpointSlice{ObjectMeta: meta("web-abc", "shop"), AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses: []string{"10.0.0.5"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}, NodeName: &node.Name,
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: pod.Name, UID: pod.UID, ResourceVersion: "7"},
+		}},
+		Ports: []discoveryv1.EndpointPort{{Name: &svc.Name}},
+	}
+	slice.Labels = map[string]string{discoveryv1.LabelServiceName: svc.Name}
+	automount := true
+	sa := &corev1.ServiceAccount{ObjectMeta: meta("web", "shop"), AutomountServiceAccountToken: &automount,
+		Secrets:          []corev1.ObjectReference{{Kind: "Secret", Namespace: "shop", Name: "web-token", UID: "uid-web-token"}},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+	}
+	role := &rbacv1.ClusterRole{ObjectMeta: meta("web-reader", ""),
+		Rules:           []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"web"}, Verbs: []string{"get"}}},
+		AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{*selector}},
+	}
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta("web-reader", "shop"),
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role.Name},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: "shop", Name: sa.Name}},
+	}
+	policy := &networkingv1.NetworkPolicy{ObjectMeta: meta("web", "shop"), Spec: networkingv1.NetworkPolicySpec{
+		PodSelector: *selector,
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "shop"}}}},
+		}},
+	}}
+	quota := &corev1.ResourceQuota{ObjectMeta: meta("compute", "shop"),
+		Spec:   corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("4")}, Scopes: []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeNotTerminating}},
+		Status: corev1.ResourceQuotaStatus{Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("4")}, Used: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("1500m")}},
+	}
+	limits := &corev1.LimitRange{ObjectMeta: meta("defaults", "shop"), Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+		Type: corev1.LimitTypeContainer, Default: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+	}}}}
+	preemptNever := corev1.PreemptNever
+	priorityClass := &schedulingv1.PriorityClass{ObjectMeta: meta("high", ""), Value: priority, PreemptionPolicy: &preemptNever, Description: "latency-sensitive"}
+	runtimeHandler := &nodev1.RuntimeClass{ObjectMeta: meta(runtimeClass, ""), Handler: "runsc",
+		Overhead: &nodev1.Overhead{PodFixed: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}}}
+	webhooks := &admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: meta("web-policy", ""), Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+		Name:         "web.policy.io",
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{Namespace: "shop", Name: svc.Name}, CABundle: []byte("ca")},
+		Rules: []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{"apps"}, APIVersions: []string{"v1"}, Resources: []string{"deployments"}},
+		}},
+		SideEffects: new(admissionregistrationv1.SideEffectClass),
+	}}}
+	return []runtime.Object{deploy, rs, pod, node, svc, cm, ns, hpa, job, cronJob, event, sts, ds, pvc, pv, ingress, slice, sa, role, binding, policy, quota, limits, priorityClass, runtimeHandler, webhooks}
+}
+
+// TestTrim_Parity verifies graphs built from trimmed objects are identical to those built from full objects.
+func TestTrim_Parity(t *testing.T) {
+	full, trimmed := cache.NewResourceCache(), cache.NewResourceCache()
+	for _, obj := range trimFixture() {
+		full.Upsert(obj)
+		trimmed.Upsert(k8s.Trim(obj))
+	}
+	fullGraph, err := graph.BuildGraph(context.Background(), full, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trimmedGraph, err := graph.BuildGraph(context.Background(), trimmed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := graph.Diff(fullGraph, trimmedGraph); !d.Empty() {
+		t.Errorf("Trimmed graph differs from the full one: %+v", d)
+	}
+	if len(fullGraph.Relationships) == 0 {
+		t.Errorf("Fixture produced no relationships")
+	}
+}
+
+// TestTrim_DropsUnusedFields verifies trimming drops what the graph doesn't read without modifying the input.
+func TestTrim_DropsUnusedFields(t *testing.T) {
+	objects := trimFixture()
+	pod := objects[2].(*corev1.Pod)
+	trimmedPod := k8s.Trim(pod).(*corev1.Pod)
+	if len(trimmedPod.Spec.Containers) != 1 || len(trimmedPod.ManagedFields) != 0 || len(trimmedPod.Spec.Volumes) != 2 {
+		t.Fatalf("Pod not trimmed: %d containers, %d managedFields, %d volumes", len(trimmedPod.Spec.Containers), len(trimmedPod.ManagedFields), len(trimmedPod.Spec.Volumes))
+	}
+	// only the image and the Secret reference of the container's environment are kept
+	if c := trimmedPod.Spec.Containers[0]; c.Image != "web:1" || len(c.Env) != 1 || c.Env[0].Value != "" {
+		t.Errorf("Container not trimmed to its image and Secret references: %+v", c)
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Errorf("Trim modified its input")
+	}
+	cm := k8s.Trim(objects[5]).(*corev1.ConfigMap)
+	if v, ok := cm.Data["app.yaml"]; !ok || v != "" || cm.BinaryData != nil {
+		t.Errorf("ConfigMap not trimmed to its keys: %v, binaryData %v", cm.Data, cm.BinaryData)
+	}
+}
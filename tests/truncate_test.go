@@ -0,0 +1,180 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+	"github.com/tthuwng/satellite/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// floodConfigMaps adds n ConfigMaps no object references, as a runaway controller would.
+func floodConfigMaps(c *cache.ResourceCache, from, n int) {
+	for i := from; i < from+n; i++ {
+		name := fmt.Sprintf("flood-%d", i)
+		c.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-0", UID: apitypes.UID(name)}})
+	}
+}
+
+// keptConfigMaps returns the ConfigMaps of g by name and whether each has relationships.
+func keptConfigMaps(g graph.Graph) map[string]bool {
+	connected := make(map[graph.GraphEntityKey]bool)
+	for _, r := range g.Relationships {
+		connected[r.Source], connected[r.Target] = true, true
+	}
+	kept := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if n.Key.Kind == "ConfigMap" {
+			kept[n.Key.Name] = connected[n.Key]
+		}
+	}
+	return kept
+}
+
+// TestSizeCaps_PerKind verifies a kind over its cap keeps its connected nodes first, drops the rest
+// with their relationships, and records what was dropped.
+func TestSizeCaps_PerKind(t *testing.T) {
+	c := syntheticCache(1000)
+	full, err := graph.BuildGraph(context.Background(), c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounted := 0
+	for _, isConnected := range keptConfigMaps(full) {
+		if isConnected {
+			mounted++
+		}
+	}
+	floodConfigMaps(c, 0, 500)
+
+	caps := graph.SizeCaps{PerKind: map[string]int{"ConfigMap": mounted + 10}}
+	g, err := graph.BuildGraph(context.Background(), c, 2, graph.WithSizeCaps(caps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := keptConfigMaps(g)
+	connectedKept := 0
+	for _, isConnected := range kept {
+		if isConnected {
+			connectedKept++
+		}
+	}
+	if len(kept) != mounted+10 || connectedKept != mounted {
+		t.Errorf("Kept %d ConfigMaps, %d connected, want %d with all %d mounted", len(kept), connectedKept, mounted+10, mounted)
+	}
+	tr := g.Metadata.Truncation
+	if tr == nil || tr.DroppedNodes["ConfigMap"] != 490 || len(tr.DroppedNodes) != 1 || tr.DroppedRelationships != 0 {
+		t.Fatalf("Truncation = %+v", tr)
+	}
+	if n := testutil.ToFloat64(metrics.TruncatedNodes.WithLabelValues("ConfigMap")); n != 490 {
+		t.Errorf("Truncated ConfigMaps metric = %v, want 490", n)
+	}
+	if len(g.Nodes) != len(full.Nodes)+10 {
+		t.Errorf("Graph has %d nodes, want %d", len(g.Nodes), len(full.Nodes)+10)
+	}
+
+	// capping a connected kind drops its relationships too
+	g, err = graph.BuildGraph(context.Background(), c, 3, graph.WithSizeCaps(graph.SizeCaps{MaxNodesPerKind: 50}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	present := make(map[graph.GraphEntityKey]bool)
+	perKind := make(map[string]int)
+	for _, n := range g.Nodes {
+		present[n.Key] = true
+		perKind[n.Key.Kind]++
+	}
+	for kind, n := range perKind {
+		if n > 50 {
+			t.Errorf("%d %s nodes kept over the cap of 50", n, kind)
+		}
+	}
+	if g.Metadata.Truncation == nil || g.Metadata.Truncation.DroppedRelationships == 0 {
+		t.Fatalf("Truncation = %+v", g.Metadata.Truncation)
+	}
+	if dangling, before := graph.DanglingRelationships(g), graph.DanglingRelationships(full); dangling > before {
+		t.Errorf("%d dangling relationships after truncation, %d before", dangling, before)
+	}
+}
+
+// TestSizeCaps_Stable verifies the sample is the same from one build to the next, and that a new
+// object displaces at most one kept node.
+func TestSizeCaps_Stable(t *testing.T) {
+	c := cache.NewResourceCache()
+	floodConfigMaps(c, 0, 300)
+	caps := graph.SizeCaps{MaxNodes: 100}
+	objects := 300
+	build := func(revision uint64) map[string]bool {
+		g, err := graph.BuildGraph(context.Background(), c, revision, graph.WithSizeCaps(caps))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.Nodes) != 100 || g.Metadata.Truncation.DroppedNodes["ConfigMap"] != objects-100 {
+			t.Fatalf("Graph has %d nodes, truncation %+v", len(g.Nodes), g.Metadata.Truncation)
+		}
+		return keptConfigMaps(g)
+	}
+	first := build(1)
+	for revision := uint64(2); revision < 5; revision++ {
+		if again := build(revision); !sameKeys(first, again) {
+			t.Fatal("Sample changed between builds of the same objects")
+		}
+	}
+
+	floodConfigMaps(c, 300, 1)
+	objects++
+	changed := 0
+	for name := range build(5) {
+		if _, ok := first[name]; !ok {
+			changed++
+		}
+	}
+	if changed > 1 {
+		t.Errorf("One new object displaced %d kept nodes", changed)
+	}
+}
+
+func sameKeys(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSizeCaps_Untruncated verifies a graph under its caps is left as is, without truncation metadata.
+func TestSizeCaps_Untruncated(t *testing.T) {
+	c := syntheticCache(300)
+	g, err := graph.BuildGraph(context.Background(), c, 1, graph.WithSizeCaps(graph.SizeCaps{MaxNodes: 10000, MaxNodesPerKind: 1000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, _ := graph.BuildGraph(context.Background(), c, 1)
+	if g.Metadata != nil && g.Metadata.Truncation != nil || len(g.Nodes) != len(full.Nodes) {
+		t.Errorf("Graph under its caps truncated: %d of %d nodes", len(g.Nodes), len(full.Nodes))
+	}
+}
+
+// TestParseKindCaps verifies the --max-nodes-per-kind syntax.
+func TestParseKindCaps(t *testing.T) {
+	all, perKind, err := graph.ParseKindCaps("10000, ConfigMap=2000,Event=0")
+	if err != nil || all != 10000 || len(perKind) != 2 || perKind["ConfigMap"] != 2000 || perKind["Event"] != 0 {
+		t.Errorf("ParseKindCaps = %d, %v, %v", all, perKind, err)
+	}
+	for _, bad := range []string{"ten", "ConfigMap=-1", "1,2", "=5"} {
+		if _, _, err := graph.ParseKindCaps(bad); err == nil {
+			t.Errorf("ParseKindCaps(%q) succeeded", bad)
+		}
+	}
+}
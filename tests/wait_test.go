@@ -0,0 +1,67 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestWait_KeyAndAbsent verifies the waiters return on the change they wait for, and on ctx otherwise.
+func TestWait_KeyAndAbsent(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-uid", ResourceVersion: "1"}}
+	key := types.EntityKey{Kind: "Pod", Namespace: "default", Name: "web"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go resourceCache.Upsert(pod)
+	if err := resourceCache.WaitForKey(ctx, key); err != nil {
+		t.Fatalf("WaitForKey: %v", err)
+	}
+	go resourceCache.Delete(pod)
+	if err := resourceCache.WaitForAbsent(ctx, key); err != nil {
+		t.Fatalf("WaitForAbsent: %v", err)
+	}
+
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	if err := resourceCache.WaitForKey(expired, key); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForKey on a missing key returned %v, want context.Canceled", err)
+	}
+}
+
+// TestWait_Quiesce verifies Quiesce waits out a burst of changes.
+func TestWait_Quiesce(t *testing.T) {
+	resourceCache := cache.NewResourceCache()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			resourceCache.Upsert(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: "cm", Namespace: "default", UID: "cm-uid", ResourceVersion: string(rune('a' + i)),
+				Labels: map[string]string{"rev": string(rune('a' + i))},
+			}})
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := resourceCache.WaitForKey(ctx, types.EntityKey{Kind: "ConfigMap", Namespace: "default", Name: "cm"}); err != nil {
+		t.Fatalf("WaitForKey: %v", err)
+	}
+	if err := resourceCache.Quiesce(ctx, 50*time.Millisecond); err != nil {
+		t.Fatalf("Quiesce: %v", err)
+	}
+	<-done
+	obj, _ := resourceCache.Get(types.EntityKey{Kind: "ConfigMap", Namespace: "default", Name: "cm"})
+	if obj == nil || obj.(*corev1.ConfigMap).Labels["rev"] != "t" {
+		t.Errorf("Quiesce returned before the burst ended: %v", obj)
+	}
+}
@@ -0,0 +1,87 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/cache"
+	"github.com/tthuwng/satellite/internal/graph"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// warningEvent returns an event of type about the object with uid, last seen at.
+func warningEvent(name string, uid apitypes.UID, typ, reason string, count int32, at time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "shop", UID: apitypes.UID(name + "-uid"), ResourceVersion: "1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: "web", UID: uid},
+		Type:           typ,
+		Reason:         reason,
+		Message:        reason + " happened",
+		Count:          count,
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+// TestWarnings_Summary verifies recent Warning events are counted onto their involved object, Normal
+// and old ones ignored, an updated event adding only its new occurrences.
+func TestWarnings_Summary(t *testing.T) {
+	now := time.Now()
+	resourceCache := cache.NewResourceCache()
+	resourceCache.Upsert(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-uid"}})
+	resourceCache.Upsert(warningEvent("old", "web-uid", corev1.EventTypeWarning, "FailedMount", 9, now.Add(-time.Hour)))
+	resourceCache.Upsert(warningEvent("normal", "web-uid", corev1.EventTypeNormal, "Pulled", 1, now))
+	resourceCache.Upsert(warningEvent("sched", "web-uid", corev1.EventTypeWarning, "FailedScheduling", 2, now.Add(-10*time.Minute)))
+
+	handler := resourceCache.AddEventHandler("Event")
+	backoff := warningEvent("backoff", "web-uid", corev1.EventTypeWarning, "BackOff", 3, now.Add(-2*time.Minute))
+	handler.OnAdd(backoff, false)
+	updated := warningEvent("backoff", "web-uid", corev1.EventTypeWarning, "BackOff", 7, now.Add(-time.Minute))
+	updated.ResourceVersion = "2"
+	updated.Message = strings.Repeat("x", 1000)
+	handler.OnUpdate(backoff, updated)
+	handler.OnUpdate(updated, updated) // resync
+
+	g, err := graph.BuildGraph(context.Background(), resourceCache, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var props map[string]string
+	for _, n := range g.Nodes {
+		if n.Key.Kind == "Pod" {
+			props = n.Properties
+		}
+	}
+	if props[graph.WarningCountProperty] != "9" || props["lastWarningReason"] != "BackOff" {
+		t.Errorf("Unexpected summary: %s=%q lastWarningReason=%q", graph.WarningCountProperty, props[graph.WarningCountProperty], props["lastWarningReason"])
+	}
+	if got := props["lastWarningTime"]; got != now.Add(-time.Minute).UTC().Format(time.RFC3339) {
+		t.Errorf("lastWarningTime = %q", got)
+	}
+	if msg := props["lastWarningMessage"]; len(msg) != cache.MaxWarningMessage || !strings.HasSuffix(msg, "…") {
+		t.Errorf("Message not truncated to %d bytes: %d", cache.MaxWarningMessage, len(msg))
+	}
+}
+
+// TestWarnings_Bounded verifies warnings are kept for at most MaxWarningObjects objects, dropping
+// the least recently warned about.
+func TestWarnings_Bounded(t *testing.T) {
+	now := time.Now()
+	resourceCache := cache.NewResourceCache()
+	for i := 0; i <= cache.MaxWarningObjects; i++ {
+		uid := apitypes.UID(fmt.Sprintf("pod-%d", i))
+		resourceCache.Upsert(warningEvent(fmt.Sprintf("ev-%d", i), uid, corev1.EventTypeWarning, "Unhealthy", 1, now))
+	}
+	snap := resourceCache.Snapshot()
+	if _, ok := snap.Warnings("pod-0"); ok {
+		t.Error("Least recently warned about object kept past the bound")
+	}
+	if summary, ok := snap.Warnings(apitypes.UID(fmt.Sprintf("pod-%d", cache.MaxWarningObjects))); !ok || summary.Count != 1 {
+		t.Errorf("Latest object's summary = %+v, %v", summary, ok)
+	}
+}
@@ -0,0 +1,71 @@
+package main_test
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tthuwng/satellite/internal/k8s"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestWatchHealth_FailingWatch injects watch errors through the fake client and
+// checks the kind turns stale and OnExhausted fires at the threshold.
+func TestWatchHealth_FailingWatch(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var failing atomic.Bool
+	failing.Store(true)
+	client.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+		if !failing.Load() {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+	})
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	inf := factory.Core().V1().Pods().Informer()
+	exhausted := make(chan string, 1)
+	health := &k8s.WatchHealth{
+		RecoveryWindow: 2 * time.Second,
+		ExitThreshold:  2,
+		OnExhausted:    func(kind string, _ int, _ error) { exhausted <- kind },
+	}
+	if err := health.Register("Pod", inf); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	defer cancel()
+
+	select {
+	case kind := <-exhausted:
+		if kind != "Pod" {
+			t.Errorf("exhausted kind = %q", kind)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("OnExhausted not called")
+	}
+	if stale := health.StaleKinds(); !slices.Equal(stale, []string{"Pod"}) {
+		t.Errorf("StaleKinds() = %v, want [Pod]", stale)
+	}
+
+	// once the watch works again, the kind recovers after the window
+	failing.Store(false)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(health.StaleKinds()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("kind still stale after the watch recovered")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}